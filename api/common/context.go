@@ -24,11 +24,15 @@ import (
 	"context"
 
 	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/core/agent"
 )
 
 type ContextKey int
 
-const ctxKeyEngine = ContextKey(1)
+const (
+	ctxKeyEngine = ContextKey(1)
+	ctxKeyAgent  = ContextKey(2)
+)
 
 func WithEngine(ctx context.Context, engine *core.Engine) context.Context {
 	return context.WithValue(ctx, ctxKeyEngine, engine)
@@ -37,3 +41,11 @@ func WithEngine(ctx context.Context, engine *core.Engine) context.Context {
 func GetEngine(ctx context.Context) *core.Engine {
 	return ctx.Value(ctxKeyEngine).(*core.Engine)
 }
+
+func WithAgent(ctx context.Context, a *agent.Agent) context.Context {
+	return context.WithValue(ctx, ctxKeyAgent, a)
+}
+
+func GetAgent(ctx context.Context) *agent.Agent {
+	return ctx.Value(ctxKeyAgent).(*agent.Agent)
+}
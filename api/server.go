@@ -27,6 +27,7 @@ import (
 	"github.com/loadimpact/k6/api/common"
 	"github.com/loadimpact/k6/api/v1"
 	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/core/agent"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/negroni"
 )
@@ -51,6 +52,28 @@ func ListenAndServe(addr string, engine *core.Engine) error {
 	return http.ListenAndServe(addr, n)
 }
 
+// NewAgentHandler returns the API of "k6 agent", which only has the status of its tests, since
+// there's no single test running for the rest of it to be about.
+func NewAgentHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", v1.NewAgentHandler())
+	mux.Handle("/ping", HandlePing())
+	mux.Handle("/", HandlePing())
+	return mux
+}
+
+func ListenAndServeAgent(addr string, a *agent.Agent) error {
+	mux := NewAgentHandler()
+
+	n := negroni.New()
+	n.Use(negroni.NewRecovery())
+	n.UseFunc(WithAgent(a))
+	n.UseFunc(NewLogger(log.StandardLogger()))
+	n.UseHandler(mux)
+
+	return http.ListenAndServe(addr, n)
+}
+
 func NewLogger(l *log.Logger) negroni.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 		next(rw, r)
@@ -67,6 +90,13 @@ func WithEngine(engine *core.Engine) negroni.HandlerFunc {
 	})
 }
 
+func WithAgent(a *agent.Agent) negroni.HandlerFunc {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		r = r.WithContext(common.WithAgent(r.Context(), a))
+		next(rw, r)
+	})
+}
+
 func HandlePing() http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		rw.Header().Add("Content-Type", "text/plain; charset=utf-8")
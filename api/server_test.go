@@ -28,6 +28,7 @@ import (
 
 	"github.com/loadimpact/k6/api/common"
 	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/core/agent"
 	"github.com/loadimpact/k6/lib"
 	log "github.com/sirupsen/logrus"
 	logtest "github.com/sirupsen/logrus/hooks/test"
@@ -85,6 +86,21 @@ func TestWithEngine(t *testing.T) {
 	})
 }
 
+func TestWithAgent(t *testing.T) {
+	a, err := agent.New(agent.Config{Tests: []agent.Test{
+		{Name: "probe", Script: "probe.js", Schedule: "@hourly"},
+	}}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	WithAgent(a)(rw, r, func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, a, common.GetAgent(r.Context()))
+	})
+}
+
 func TestPing(t *testing.T) {
 	mux := NewHandler()
 
@@ -32,6 +32,9 @@ type Check struct {
 	Name   string `json:"name" yaml:"name"`
 	Passes int64  `json:"passes" yaml:"passes"`
 	Fails  int64  `json:"fails" yaml:"fails"`
+
+	Severity       string             `json:"severity" yaml:"severity"`
+	FailureSamples []lib.CheckFailure `json:"failure-samples" yaml:"failure-samples"`
 }
 
 func NewCheck(c *lib.Check) Check {
@@ -41,6 +44,9 @@ func NewCheck(c *lib.Check) Check {
 		Name:   c.Name,
 		Passes: c.Passes,
 		Fails:  c.Fails,
+
+		Severity:       c.GetSeverity(),
+		FailureSamples: c.GetFailureSamples(),
 	}
 }
 
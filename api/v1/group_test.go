@@ -80,6 +80,8 @@ func TestNewGroup(t *testing.T) {
 	t.Run("checks", func(t *testing.T) {
 		og, _ := lib.NewGroup("My Group", nil)
 		check, _ := og.Check("my check")
+		check.SetSeverity(lib.CheckSeverityWarn)
+		check.AddFailure(lib.CheckFailure{VU: 1, Iteration: 2, Expected: 200.0, Actual: 503.0})
 
 		g := NewGroup(og, nil)
 		assert.Equal(t, og.ID, g.ID)
@@ -90,6 +92,8 @@ func TestNewGroup(t *testing.T) {
 
 		assert.Equal(t, check.ID, g.Checks[0].ID)
 		assert.Equal(t, "my check", g.Checks[0].Name)
+		assert.Equal(t, "warn", g.Checks[0].Severity)
+		assert.Equal(t, []lib.CheckFailure{{VU: 1, Iteration: 2, Expected: 200.0, Actual: 503.0}}, g.Checks[0].FailureSamples)
 	})
 }
 
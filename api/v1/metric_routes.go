@@ -21,26 +21,115 @@
 package v1
 
 import (
+	"encoding/json"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/loadimpact/k6/api/common"
+	"github.com/loadimpact/k6/stats"
 	"github.com/manyminds/api2go/jsonapi"
+	"github.com/pkg/errors"
 )
 
+// metricQuery filters and extends the metrics returned by the API, based on the query string:
+//
+//	?name=http_req_*      only metrics whose names match the glob
+//	?type=trend           only metrics of the given type
+//	?tainted=true         only metrics that are (or aren't) failing a threshold
+//	?stats=p(99),p(99.9)  additional stats to compute for each metric
+type metricQuery struct {
+	Name    string
+	Type    NullMetricType
+	Tainted *bool
+	Stats   []string
+}
+
+func parseMetricQuery(r *http.Request) (metricQuery, error) {
+	var q metricQuery
+	values := r.URL.Query()
+
+	if name := values.Get("name"); name != "" {
+		if _, err := path.Match(name, ""); err != nil {
+			return q, errors.Wrapf(err, "invalid name pattern '%s'", name)
+		}
+		q.Name = name
+	}
+	if typ := values.Get("type"); typ != "" {
+		if err := json.Unmarshal([]byte(strconv.Quote(typ)), &q.Type.Type); err != nil {
+			return q, errors.Errorf("invalid metric type '%s'", typ)
+		}
+		q.Type.Valid = true
+	}
+	if tainted := values.Get("tainted"); tainted != "" {
+		b, err := strconv.ParseBool(tainted)
+		if err != nil {
+			return q, errors.Errorf("invalid tainted value '%s'", tainted)
+		}
+		q.Tainted = &b
+	}
+	if s := values.Get("stats"); s != "" {
+		for _, stat := range strings.Split(s, ",") {
+			if stat = strings.TrimSpace(stat); stat != "" {
+				q.Stats = append(q.Stats, stat)
+			}
+		}
+	}
+	return q, nil
+}
+
+// Matches returns whether the metric passes the query's filters.
+func (q metricQuery) Matches(m *stats.Metric) bool {
+	if q.Name != "" {
+		if ok, _ := path.Match(q.Name, m.Name); !ok {
+			return false
+		}
+	}
+	if q.Type.Valid && q.Type.Type != m.Type {
+		return false
+	}
+	if q.Tainted != nil && *q.Tainted != (m.Tainted.Valid && m.Tainted.Bool) {
+		return false
+	}
+	return true
+}
+
+// NewMetric returns an API metric, with any additional stats requested by the query.
+func (q metricQuery) NewMetric(m *stats.Metric, t time.Duration) Metric {
+	metric := NewMetric(m, t)
+	for _, stat := range q.Stats {
+		if _, ok := metric.Sample[stat]; !ok {
+			metric.Sample[stat] = stats.MetricRef{Metric: m.Name, Stat: stat}.Value(m, t)
+		}
+	}
+	return metric
+}
+
 func HandleGetMetrics(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	engine := common.GetEngine(r.Context())
 
+	q, err := parseMetricQuery(r)
+	if err != nil {
+		apiError(rw, "Invalid query", err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var t time.Duration
 	if engine.Executor != nil {
 		t = engine.Executor.GetTime()
 	}
 
+	engine.MetricsLock.Lock()
 	metrics := make([]Metric, 0)
 	for _, m := range engine.Metrics {
-		metrics = append(metrics, NewMetric(m, t))
+		if q.Matches(m) {
+			metrics = append(metrics, q.NewMetric(m, t))
+		}
 	}
+	engine.MetricsLock.Unlock()
 
 	data, err := jsonapi.Marshal(metrics)
 	if err != nil {
@@ -54,20 +143,24 @@ func HandleGetMetric(rw http.ResponseWriter, r *http.Request, p httprouter.Param
 	id := p.ByName("id")
 	engine := common.GetEngine(r.Context())
 
+	q, err := parseMetricQuery(r)
+	if err != nil {
+		apiError(rw, "Invalid query", err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var t time.Duration
 	if engine.Executor != nil {
 		t = engine.Executor.GetTime()
 	}
 
 	var metric Metric
-	var found bool
-	for _, m := range engine.Metrics {
-		if m.Name == id {
-			metric = NewMetric(m, t)
-			found = true
-			break
-		}
+	engine.MetricsLock.Lock()
+	m, found := engine.Metrics[id]
+	if found {
+		metric = q.NewMetric(m, t)
 	}
+	engine.MetricsLock.Unlock()
 
 	if !found {
 		apiError(rw, "Not Found", "No metric with that ID was found", http.StatusNotFound)
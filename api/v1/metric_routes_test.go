@@ -117,3 +117,67 @@ func TestGetMetric(t *testing.T) {
 		})
 	})
 }
+
+func TestGetMetricsQuery(t *testing.T) {
+	engine, err := core.NewEngine(nil, lib.Options{})
+	assert.NoError(t, err)
+
+	engine.Metrics = map[string]*stats.Metric{
+		"http_req_duration": stats.New("http_req_duration", stats.Trend, stats.Time),
+		"http_reqs":         stats.New("http_reqs", stats.Gauge),
+		"my_metric":         stats.New("my_metric", stats.Trend),
+	}
+	engine.Metrics["my_metric"].Tainted = null.BoolFrom(true)
+	for i := 1; i <= 1000; i++ {
+		engine.Metrics["http_req_duration"].Sink.Add(stats.Sample{Value: float64(i)})
+	}
+
+	testdata := map[string][]string{
+		"":                                  {"http_req_duration", "http_reqs", "my_metric"},
+		"?name=http_*":                      {"http_req_duration", "http_reqs"},
+		"?type=trend":                       {"http_req_duration", "my_metric"},
+		"?tainted=true":                     {"my_metric"},
+		"?tainted=false&name=http_req_*":    {"http_req_duration"},
+		"?type=gauge&tainted=0":             {"http_reqs"},
+		"?name=http_req_duration&type=rate": {},
+	}
+	for query, names := range testdata {
+		t.Run(query, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/metrics"+query, nil))
+			if !assert.Equal(t, http.StatusOK, rw.Result().StatusCode) {
+				return
+			}
+
+			var metrics []Metric
+			assert.NoError(t, jsonapi.Unmarshal(rw.Body.Bytes(), &metrics))
+			actual := []string{}
+			for _, m := range metrics {
+				actual = append(actual, m.Name)
+			}
+			assert.ElementsMatch(t, names, actual)
+		})
+	}
+
+	t.Run("stats", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/metrics/http_req_duration?stats=p(99),p(99.9)", nil))
+		if !assert.Equal(t, http.StatusOK, rw.Result().StatusCode) {
+			return
+		}
+
+		var metric Metric
+		assert.NoError(t, jsonapi.Unmarshal(rw.Body.Bytes(), &metric))
+		assert.InDelta(t, 990.01, metric.Sample["p(99)"], 0.001)
+		assert.InDelta(t, 999.001, metric.Sample["p(99.9)"], 0.001)
+		assert.Contains(t, metric.Sample, "p(95)")
+	})
+
+	for _, query := range []string{"?name=[", "?type=bogus", "?tainted=maybe"} {
+		t.Run(query, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/metrics"+query, nil))
+			assert.Equal(t, http.StatusBadRequest, rw.Result().StatusCode)
+		})
+	}
+}
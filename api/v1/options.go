@@ -0,0 +1,71 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Options are the options that can be changed while the test is running.
+type Options struct {
+	Stages     []lib.Stage                 `json:"stages" yaml:"stages"`
+	Duration   types.NullDuration          `json:"duration" yaml:"duration"`
+	RPS        null.Int                    `json:"rps" yaml:"rps"`
+	Thresholds map[string]stats.Thresholds `json:"thresholds" yaml:"thresholds"`
+}
+
+func NewOptions(engine *core.Engine) Options {
+	engine.MetricsLock.Lock()
+	defer engine.MetricsLock.Unlock()
+
+	return Options{
+		Stages:     engine.Options.Stages,
+		Duration:   engine.Options.Duration,
+		RPS:        engine.Options.RPS,
+		Thresholds: engine.Options.Thresholds,
+	}
+}
+
+// LibOptions returns the options to reload the engine with.
+func (o Options) LibOptions() lib.Options {
+	return lib.Options{
+		Stages:     o.Stages,
+		Duration:   o.Duration,
+		RPS:        o.RPS,
+		Thresholds: o.Thresholds,
+	}
+}
+
+func (o Options) GetName() string {
+	return "options"
+}
+
+func (o Options) GetID() string {
+	return "default"
+}
+
+func (o Options) SetID(id string) error {
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/loadimpact/k6/api/common"
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+func HandleGetOptions(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	data, err := jsonapi.Marshal(NewOptions(engine))
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(data)
+}
+
+// HandlePatchOptions reloads the options given in the request; the others are left as they are.
+func HandlePatchOptions(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		apiError(rw, "Couldn't read request", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var options Options
+	if err := jsonapi.Unmarshal(body, &options); err != nil {
+		apiError(rw, "Invalid data", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := engine.ReloadOptions(options.LibOptions()); err != nil {
+		apiError(rw, "Couldn't reload options", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := jsonapi.Marshal(NewOptions(engine))
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(data)
+}
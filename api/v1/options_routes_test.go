@@ -0,0 +1,103 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/manyminds/api2go/jsonapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestGetOptions(t *testing.T) {
+	ths, err := stats.NewThresholds([]string{"value < 1"})
+	require.NoError(t, err)
+	engine, err := core.NewEngine(nil, lib.Options{
+		Duration:   types.NullDurationFrom(time.Minute),
+		Thresholds: map[string]stats.Thresholds{"my_metric": ths},
+	})
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/options", nil))
+	res := rw.Result()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var options Options
+	require.NoError(t, jsonapi.Unmarshal(rw.Body.Bytes(), &options))
+	assert.Equal(t, types.NullDurationFrom(time.Minute), options.Duration)
+	assert.False(t, options.RPS.Valid)
+	if assert.Contains(t, options.Thresholds, "my_metric") {
+		assert.Equal(t, "value < 1", options.Thresholds["my_metric"].Thresholds[0].Source)
+	}
+}
+
+func TestPatchOptions(t *testing.T) {
+	stages := []lib.Stage{{Duration: types.NullDurationFrom(time.Minute), Target: null.IntFrom(10)}}
+	testdata := map[string]struct {
+		StatusCode int
+		Options    Options
+	}{
+		"nothing":  {200, Options{}},
+		"stages":   {200, Options{Stages: stages}},
+		"duration": {200, Options{Duration: types.NullDurationFrom(time.Hour)}},
+		"rps":      {400, Options{RPS: null.IntFrom(10)}},
+	}
+
+	for name, indata := range testdata {
+		t.Run(name, func(t *testing.T) {
+			engine, err := core.NewEngine(nil, lib.Options{Duration: types.NullDurationFrom(time.Minute)})
+			require.NoError(t, err)
+
+			body, err := jsonapi.Marshal(indata.Options)
+			require.NoError(t, err)
+
+			rw := httptest.NewRecorder()
+			NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "PATCH", "/v1/options", bytes.NewReader(body)))
+			res := rw.Result()
+			if !assert.Equal(t, indata.StatusCode, res.StatusCode) || indata.StatusCode != 200 {
+				return
+			}
+
+			options := NewOptions(engine)
+			if indata.Options.Stages != nil {
+				assert.Equal(t, indata.Options.Stages, engine.Executor.GetStages())
+				assert.Equal(t, indata.Options.Stages, options.Stages)
+			}
+			if indata.Options.Duration.Valid {
+				assert.Equal(t, indata.Options.Duration, engine.Executor.GetEndTime())
+				assert.Equal(t, indata.Options.Duration, options.Duration)
+			} else {
+				assert.Equal(t, types.NullDurationFrom(time.Minute), engine.Executor.GetEndTime())
+			}
+		})
+	}
+}
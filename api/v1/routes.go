@@ -32,9 +32,15 @@ func NewHandler() http.Handler {
 	router.GET("/v1/status", HandleGetStatus)
 	router.PATCH("/v1/status", HandlePatchStatus)
 
+	router.GET("/v1/options", HandleGetOptions)
+	router.PATCH("/v1/options", HandlePatchOptions)
+
 	router.GET("/v1/metrics", HandleGetMetrics)
 	router.GET("/v1/metrics/:id", HandleGetMetric)
 
+	router.GET("/v1/thresholds", HandleGetThresholds)
+	router.GET("/v1/thresholds/:id", HandleGetThreshold)
+
 	router.GET("/v1/groups", HandleGetGroups)
 	router.GET("/v1/groups/:id", HandleGetGroup)
 
@@ -46,3 +52,13 @@ func NewHandler() http.Handler {
 
 	return router
 }
+
+// NewAgentHandler returns the routes of the API of "k6 agent".
+func NewAgentHandler() http.Handler {
+	router := httprouter.New()
+
+	router.GET("/v1/schedules", HandleGetSchedules)
+	router.GET("/v1/schedules/:id", HandleGetSchedule)
+
+	return router
+}
@@ -0,0 +1,67 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"time"
+
+	"github.com/loadimpact/k6/core/agent"
+)
+
+// A Schedule is a test run by "k6 agent", and the status of its last run.
+type Schedule struct {
+	Name     string `json:"-" yaml:"name"`
+	Schedule string `json:"schedule" yaml:"schedule"`
+	Script   string `json:"script" yaml:"script"`
+
+	Running bool       `json:"running" yaml:"running"`
+	Next    *time.Time `json:"next" yaml:"next"`
+	Runs    int64      `json:"runs" yaml:"runs"`
+	Skipped int64      `json:"skipped" yaml:"skipped"`
+
+	LastRun *agent.Run `json:"last-run" yaml:"last-run"`
+}
+
+func NewSchedule(s agent.Status) Schedule {
+	schedule := Schedule{
+		Name:     s.Name,
+		Schedule: s.Schedule,
+		Script:   s.Script,
+		Running:  s.Running,
+		Runs:     s.Runs,
+		Skipped:  s.Skipped,
+		LastRun:  s.LastRun,
+	}
+	if !s.Next.IsZero() {
+		next := s.Next
+		schedule.Next = &next
+	}
+	return schedule
+}
+
+func (s Schedule) GetID() string {
+	return s.Name
+}
+
+func (s *Schedule) SetID(id string) error {
+	s.Name = id
+	return nil
+}
@@ -0,0 +1,64 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/loadimpact/k6/api/common"
+	"github.com/manyminds/api2go/jsonapi"
+)
+
+func HandleGetSchedules(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	a := common.GetAgent(r.Context())
+
+	var schedules []Schedule
+	for _, s := range a.Status() {
+		schedules = append(schedules, NewSchedule(s))
+	}
+
+	data, err := jsonapi.Marshal(schedules)
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(data)
+}
+
+func HandleGetSchedule(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	id := p.ByName("id")
+
+	a := common.GetAgent(r.Context())
+
+	s, ok := a.TestStatus(id)
+	if !ok {
+		apiError(rw, "Not Found", "No schedule with that ID was found", http.StatusNotFound)
+		return
+	}
+
+	data, err := jsonapi.Marshal(NewSchedule(s))
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(data)
+}
@@ -0,0 +1,113 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/api/common"
+	"github.com/loadimpact/k6/core/agent"
+	"github.com/manyminds/api2go/jsonapi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSchedules(t *testing.T) {
+	a, err := agent.New(agent.Config{Tests: []agent.Test{
+		{Name: "probe", Script: "probe.js", Schedule: "@every 10ms"},
+		{Name: "nightly", Script: "nightly.js", Schedule: "0 2 * * *"},
+	}}, func(ctx context.Context, test agent.Test) error {
+		return errors.Wrap(agent.ErrThresholdsFailed, "probe")
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = a.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", target, nil)
+		NewAgentHandler().ServeHTTP(rw, r.WithContext(common.WithAgent(r.Context(), a)))
+		return rw
+	}
+
+	t.Run("list", func(t *testing.T) {
+		rw := get("/v1/schedules")
+		assert.Equal(t, http.StatusOK, rw.Result().StatusCode)
+
+		var doc jsonapi.Document
+		assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &doc))
+		if assert.Len(t, doc.Data.DataArray, 2) {
+			assert.Equal(t, "schedules", doc.Data.DataArray[0].Type)
+			assert.Equal(t, "probe", doc.Data.DataArray[0].ID)
+			assert.Equal(t, "nightly", doc.Data.DataArray[1].ID)
+		}
+	})
+
+	t.Run("probe", func(t *testing.T) {
+		rw := get("/v1/schedules/probe")
+		assert.Equal(t, http.StatusOK, rw.Result().StatusCode)
+
+		var s Schedule
+		assert.NoError(t, jsonapi.Unmarshal(rw.Body.Bytes(), &s))
+		assert.Equal(t, "probe", s.Name)
+		assert.Equal(t, "@every 10ms", s.Schedule)
+		assert.Equal(t, "probe.js", s.Script)
+		assert.False(t, s.Running)
+		assert.NotNil(t, s.Next)
+		assert.True(t, s.Runs > 0)
+		if assert.NotNil(t, s.LastRun) {
+			assert.Equal(t, agent.RunFailed, s.LastRun.Result)
+			assert.False(t, s.LastRun.Start.IsZero())
+		}
+	})
+
+	t.Run("nightly", func(t *testing.T) {
+		rw := get("/v1/schedules/nightly")
+		assert.Equal(t, http.StatusOK, rw.Result().StatusCode)
+
+		var s Schedule
+		assert.NoError(t, jsonapi.Unmarshal(rw.Body.Bytes(), &s))
+		assert.Equal(t, int64(0), s.Runs)
+		assert.Nil(t, s.LastRun)
+		if assert.NotNil(t, s.Next) {
+			assert.Equal(t, 2, s.Next.Hour())
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		rw := get("/v1/schedules/missing")
+		assert.Equal(t, http.StatusNotFound, rw.Result().StatusCode)
+	})
+}
@@ -22,6 +22,7 @@ package v1
 
 import (
 	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib/types"
 	"gopkg.in/guregu/null.v3"
 )
 
@@ -31,17 +32,23 @@ type Status struct {
 	VUsMax null.Int  `json:"vus-max" yaml:"vus-max"`
 
 	// Readonly.
-	Running bool `json:"running" yaml:"running"`
-	Tainted bool `json:"tainted" yaml:"tainted"`
+	Running    bool           `json:"running" yaml:"running"`
+	Tainted    bool           `json:"tainted" yaml:"tainted"`
+	Time       types.Duration `json:"time" yaml:"time"`
+	Iterations int64          `json:"iterations" yaml:"iterations"`
+	Progress   float64        `json:"progress" yaml:"progress"`
 }
 
 func NewStatus(engine *core.Engine) Status {
 	return Status{
-		Paused:  null.BoolFrom(engine.Executor.IsPaused()),
-		VUs:     null.IntFrom(engine.Executor.GetVUs()),
-		VUsMax:  null.IntFrom(engine.Executor.GetVUsMax()),
-		Running: engine.Executor.IsRunning(),
-		Tainted: engine.IsTainted(),
+		Paused:     null.BoolFrom(engine.Executor.IsPaused()),
+		VUs:        null.IntFrom(engine.Executor.GetVUs()),
+		VUsMax:     null.IntFrom(engine.Executor.GetVUsMax()),
+		Running:    engine.Executor.IsRunning(),
+		Tainted:    engine.IsTainted(),
+		Time:       types.Duration(engine.Executor.GetTime()),
+		Iterations: engine.Executor.GetIterations(),
+		Progress:   engine.Progress(),
 	}
 }
 
@@ -59,6 +59,8 @@ func TestGetStatus(t *testing.T) {
 		assert.True(t, status.VUs.Valid)
 		assert.True(t, status.VUsMax.Valid)
 		assert.False(t, status.Tainted)
+		assert.Equal(t, int64(0), status.Iterations)
+		assert.Equal(t, float64(0), status.Progress)
 	})
 }
 
@@ -0,0 +1,62 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"github.com/loadimpact/k6/stats"
+	"gopkg.in/guregu/null.v3"
+)
+
+type ThresholdResult struct {
+	Source      string `json:"source" yaml:"source"`
+	AbortOnFail bool   `json:"abortOnFail" yaml:"abortOnFail"`
+	Failed      bool   `json:"failed" yaml:"failed"`
+}
+
+// Threshold is the live pass/fail state of all thresholds defined on a metric. Tainted is null
+// if the metric hasn't received any samples yet, and thus hasn't been evaluated.
+type Threshold struct {
+	Metric string `json:"-" yaml:"metric"`
+
+	Tainted    null.Bool         `json:"tainted" yaml:"tainted"`
+	Thresholds []ThresholdResult `json:"thresholds" yaml:"thresholds"`
+}
+
+func NewThreshold(name string, ths stats.Thresholds, tainted null.Bool) Threshold {
+	results := make([]ThresholdResult, len(ths.Thresholds))
+	for i, th := range ths.Thresholds {
+		results[i] = ThresholdResult{
+			Source:      th.Source,
+			AbortOnFail: th.AbortOnFail,
+			Failed:      th.LastFailed,
+		}
+	}
+	return Threshold{Metric: name, Tainted: tainted, Thresholds: results}
+}
+
+func (t Threshold) GetID() string {
+	return t.Metric
+}
+
+func (t *Threshold) SetID(id string) error {
+	t.Metric = id
+	return nil
+}
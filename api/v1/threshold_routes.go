@@ -0,0 +1,97 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/loadimpact/k6/api/common"
+	"github.com/loadimpact/k6/core"
+	"github.com/manyminds/api2go/jsonapi"
+	"gopkg.in/guregu/null.v3"
+)
+
+// getThresholds returns the state of all thresholds, including those on metrics that haven't
+// received any samples yet, sorted by metric name.
+func getThresholds(engine *core.Engine) []Threshold {
+	engine.MetricsLock.Lock()
+	defer engine.MetricsLock.Unlock()
+
+	thresholds := make([]Threshold, 0)
+	for name, m := range engine.Metrics {
+		if len(m.Thresholds.Thresholds) > 0 {
+			thresholds = append(thresholds, NewThreshold(name, m.Thresholds, m.Tainted))
+		}
+	}
+	for name, ths := range engine.Options.Thresholds {
+		if _, ok := engine.Metrics[name]; !ok && len(ths.Thresholds) > 0 {
+			thresholds = append(thresholds, NewThreshold(name, ths, null.Bool{}))
+		}
+	}
+	sort.Sort(thresholdsByMetric(thresholds))
+	return thresholds
+}
+
+type thresholdsByMetric []Threshold
+
+func (t thresholdsByMetric) Len() int           { return len(t) }
+func (t thresholdsByMetric) Less(i, j int) bool { return t[i].Metric < t[j].Metric }
+func (t thresholdsByMetric) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+func HandleGetThresholds(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	data, err := jsonapi.Marshal(getThresholds(engine))
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(data)
+}
+
+func HandleGetThreshold(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	id := p.ByName("id")
+	engine := common.GetEngine(r.Context())
+
+	var threshold Threshold
+	var found bool
+	for _, th := range getThresholds(engine) {
+		if th.Metric == id {
+			threshold = th
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		apiError(rw, "Not Found", "No thresholds for a metric with that ID were found", http.StatusNotFound)
+		return
+	}
+
+	data, err := jsonapi.Marshal(threshold)
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(data)
+}
@@ -0,0 +1,97 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/manyminds/api2go/jsonapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestGetThresholds(t *testing.T) {
+	ths, err := stats.NewThresholds([]string{"avg<100", "p(95)<200"})
+	require.NoError(t, err)
+	pending, err := stats.NewThresholds([]string{"rate>0.9"})
+	require.NoError(t, err)
+
+	engine, err := core.NewEngine(nil, lib.Options{Thresholds: map[string]stats.Thresholds{
+		"my_metric": ths,
+		"checks":    pending,
+	}})
+	require.NoError(t, err)
+
+	m := stats.New("my_metric", stats.Trend, stats.Time)
+	m.Thresholds = ths
+	m.Thresholds.Thresholds[1].LastFailed = true
+	m.Tainted = null.BoolFrom(true)
+	engine.Metrics = map[string]*stats.Metric{
+		"my_metric":    m,
+		"other_metric": stats.New("other_metric", stats.Counter),
+	}
+
+	t.Run("all", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/thresholds", nil))
+		res := rw.Result()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var thresholds []Threshold
+		require.NoError(t, jsonapi.Unmarshal(rw.Body.Bytes(), &thresholds))
+		require.Len(t, thresholds, 2)
+
+		assert.Equal(t, "checks", thresholds[0].Metric)
+		assert.False(t, thresholds[0].Tainted.Valid)
+		assert.Equal(t, []ThresholdResult{{Source: "rate>0.9"}}, thresholds[0].Thresholds)
+
+		assert.Equal(t, "my_metric", thresholds[1].Metric)
+		assert.Equal(t, null.BoolFrom(true), thresholds[1].Tainted)
+		assert.Equal(t, []ThresholdResult{
+			{Source: "avg<100"},
+			{Source: "p(95)<200", Failed: true},
+		}, thresholds[1].Thresholds)
+	})
+
+	t.Run("nonexistent", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/thresholds/other_metric", nil))
+		assert.Equal(t, http.StatusNotFound, rw.Result().StatusCode)
+	})
+
+	t.Run("real", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/thresholds/my_metric", nil))
+		res := rw.Result()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var threshold Threshold
+		require.NoError(t, jsonapi.Unmarshal(rw.Body.Bytes(), &threshold))
+		assert.Equal(t, "my_metric", threshold.Metric)
+		assert.Len(t, threshold.Thresholds, 2)
+	})
+}
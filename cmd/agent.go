@@ -0,0 +1,207 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/loadimpact/k6/api"
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/core/agent"
+	"github.com/loadimpact/k6/core/local"
+	"github.com/loadimpact/k6/js"
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// agentCmd represents the agent command.
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Run tests on a schedule",
+	Long: `Run tests on a schedule.
+
+This keeps running the tests in a config file on cron schedules, for continuous synthetic
+monitoring, until it's interrupted. Their metrics go to the outputs configured for each test,
+and the status of their last runs is exposed over the REST API, at /v1/schedules.`,
+	Example: `
+  # Run the tests in agent.json, eg.:
+  # {
+  #   "tests": [{
+  #     "name": "homepage",
+  #     "script": "homepage.js",
+  #     "schedule": "*/5 * * * *",
+  #     "options": { "vus": 2, "duration": "30s" },
+  #     "out": ["influxdb=http://localhost:8086/k6"]
+  #   }]
+  # }
+  k6 agent agent.json
+
+  # Get the status of the tests' last runs.
+  curl http://localhost:6565/v1/schedules`[1:],
+	Args: exactArgsWithMsg(1, "arg should be the path to an agent config file"),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fs := afero.NewOsFs()
+		filename, err := filepath.Abs(args[0])
+		if err != nil {
+			return err
+		}
+		conf, err := readAgentConfig(fs, filename)
+		if err != nil {
+			return err
+		}
+
+		runtimeOptions, err := getRuntimeOptions(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		// Scripts are relative to the config file.
+		pwd := filepath.Dir(filename)
+		a, err := agent.New(conf, func(ctx context.Context, test agent.Test) error {
+			return runScheduledTest(ctx, fs, pwd, test, runtimeOptions)
+		})
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			if err := api.ListenAndServeAgent(address, a); err != nil {
+				log.WithError(err).Warn("Error from API server")
+			}
+		}()
+
+		// Stop on Interrupts, SIGINTs and SIGTERMs, after the tests that are running end.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigC := make(chan os.Signal, 1)
+		signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigC)
+		go func() {
+			sig := <-sigC
+			log.WithField("sig", sig).Debug("Stopping in response to signal")
+			cancel()
+		}()
+
+		log.WithFields(log.Fields{"tests": len(conf.Tests), "address": address}).Info("Agent started")
+		return a.Run(ctx)
+	},
+}
+
+// Reads the config file of an agent.
+func readAgentConfig(fs afero.Fs, filename string) (agent.Config, error) {
+	var conf agent.Config
+	data, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return conf, err
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return conf, errors.Wrapf(err, "couldn't parse '%s'", filename)
+	}
+	return conf, conf.Validate()
+}
+
+// Runs a scheduled test once, the way "k6 run" would, but without the UI.
+func runScheduledTest(
+	ctx context.Context, fs afero.Fs, pwd string, test agent.Test, rtOpts lib.RuntimeOptions,
+) error {
+	src, err := readSource(test.Script, pwd, fs, nil)
+	if err != nil {
+		return err
+	}
+
+	// The test's own environment variables override the ones given to the agent.
+	env := make(map[string]string, len(rtOpts.Env)+len(test.Env))
+	for k, v := range rtOpts.Env {
+		env[k] = v
+	}
+	for k, v := range test.Env {
+		env[k] = v
+	}
+	rtOpts.Env = env
+
+	r, err := newRunner(src, "", fs, rtOpts)
+	if err != nil {
+		return err
+	}
+
+	// Start from the defaults of the "k6 run" flags, which aren't set, so they're only used for
+	// the options that aren't set anywhere else either.
+	flags := pflag.NewFlagSet("", 0)
+	flags.AddFlagSet(optionFlagSet())
+	flags.AddFlagSet(configFlagSet())
+	cliConf, err := getConfig(flags)
+	if err != nil {
+		return err
+	}
+	cliConf = cliConf.Apply(Config{Options: test.Options, Out: test.Out})
+	conf, err := getConsolidatedConfig(fs, cliConf, r)
+	if err != nil {
+		return err
+	}
+	conf = applyRunDefaults(conf)
+	conf.Options = conf.Options.WithRunID()
+	conf.Options = conf.Options.WithMetadata(scriptGitMetadata(conf.Options, test.Script, pwd))
+	if err = r.SetOptions(conf.Options); err != nil {
+		return err
+	}
+
+	engine, err := core.NewEngine(local.New(r), conf.Options)
+	if err != nil {
+		return err
+	}
+	if conf.NoThresholds.Valid {
+		engine.NoThresholds = conf.NoThresholds.Bool
+	}
+	if engine.Collectors, err = newCollectors(src, conf); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"test": test.Name, "run_id": conf.RunID.String}).Debug("Running a scheduled test")
+	err = engine.Run(ctx)
+	if jsr, ok := r.(*js.Runner); ok {
+		jsr.CloseConsole()
+		jsr.CloseModules()
+	}
+	switch {
+	case err != nil:
+		return err
+	case ctx.Err() != nil:
+		return errors.New("the run was interrupted")
+	case engine.IsTainted():
+		return agent.ErrThresholdsFailed
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(agentCmd)
+	agentCmd.Flags().SortFlags = false
+	agentCmd.Flags().AddFlagSet(runtimeOptionFlagSet(false))
+}
@@ -0,0 +1,145 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/loadimpact/k6/core/agent"
+	"github.com/loadimpact/k6/lib"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadAgentConfig(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/agent.json", []byte(`{
+		"tests": [{
+			"name": "probe",
+			"script": "probe.js",
+			"schedule": "*/5 * * * *",
+			"env": { "TARGET": "test.loadimpact.com" },
+			"options": { "vus": 2, "duration": "30s" },
+			"out": ["json=probe.json"]
+		}]
+	}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/invalid.json", []byte(`{"tests": [{"name": "probe"}]}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/broken.json", []byte(`{"tests": `), 0644))
+
+	conf, err := readAgentConfig(fs, "/agent.json")
+	require.NoError(t, err)
+	require.Len(t, conf.Tests, 1)
+	test := conf.Tests[0]
+	assert.Equal(t, "probe", test.Name)
+	assert.Equal(t, "probe.js", test.Script)
+	assert.Equal(t, "*/5 * * * *", test.Schedule)
+	assert.Equal(t, map[string]string{"TARGET": "test.loadimpact.com"}, test.Env)
+	assert.Equal(t, int64(2), test.Options.VUs.Int64)
+	assert.Equal(t, []string{"json=probe.json"}, test.Out)
+
+	_, err = readAgentConfig(fs, "/invalid.json")
+	assert.EqualError(t, err, "the test 'probe' needs a script file")
+	_, err = readAgentConfig(fs, "/broken.json")
+	assert.Error(t, err)
+	_, err = readAgentConfig(fs, "/missing.json")
+	assert.Error(t, err)
+}
+
+func TestRunScheduledTest(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/tests/probe.js", []byte(`
+		import { check } from "k6";
+		export let options = { thresholds: { checks: ["rate==1"] } };
+		export default function() {
+			check(__ENV.RESULT, { "passed": (r) => r === "pass" });
+		}
+	`), 0644))
+
+	testdata := map[string]struct {
+		env map[string]string
+		err error
+	}{
+		"passed": {map[string]string{"RESULT": "pass"}, nil},
+		"failed": {map[string]string{"RESULT": "fail"}, agent.ErrThresholdsFailed},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			test := agent.Test{Name: name, Script: "probe.js", Schedule: "@hourly", Env: data.env}
+			err := runScheduledTest(context.Background(), fs, "/tests", test, lib.RuntimeOptions{})
+			assert.Equal(t, data.err, err)
+		})
+	}
+
+	t.Run("missing script", func(t *testing.T) {
+		test := agent.Test{Name: "missing", Script: "missing.js", Schedule: "@hourly"}
+		assert.Error(t, runScheduledTest(context.Background(), fs, "/tests", test, lib.RuntimeOptions{}))
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		test := agent.Test{Name: "interrupted", Script: "probe.js", Schedule: "@hourly"}
+		err := runScheduledTest(ctx, fs, "/tests", test, lib.RuntimeOptions{})
+		assert.EqualError(t, err, "the run was interrupted")
+	})
+}
+
+func TestRunScheduledTestIsolation(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/tests/stateful.js", []byte(`
+		import { check } from "k6";
+		import { Feeder } from "k6/data";
+		import { Store } from "k6/kv";
+		import { register } from "k6/secrets";
+		import { once } from "k6/sync";
+		export let options = { iterations: 1, thresholds: { checks: ["rate==1"] } };
+		let users = new Feeder("users", "name\nalice\nbob");
+		let store = new Store("runs");
+		register("s3cr3t-token");
+		export default function() {
+			let first = once("init", function() {});
+			check(null, {
+				"once() runs in every run": () => first,
+				"the feeder starts over": () => users.next().name === "alice",
+				"the store starts empty": () => store.incr("runs") === 1,
+			});
+		}
+	`), 0644))
+
+	// Run it twice in a row, and twice at the same time; every run starts from scratch.
+	test := agent.Test{Name: "stateful", Script: "stateful.js", Schedule: "@hourly"}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, runScheduledTest(context.Background(), fs, "/tests", test, lib.RuntimeOptions{}))
+		assert.Equal(t, "s3cr3t-token", lib.Secrets.Redact("s3cr3t-token"), "the secrets of the run are removed")
+	}
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- runScheduledTest(context.Background(), fs, "/tests", test, lib.RuntimeOptions{})
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, <-errs)
+	}
+	assert.Equal(t, "s3cr3t-token", lib.Secrets.Redact("s3cr3t-token"))
+}
@@ -35,11 +35,15 @@ var archiveCmd = &cobra.Command{
 	Short: "Create an archive",
 	Long: `Create an archive.
 
-An archive is a fully self-contained test run, and can be executed identically elsewhere.`,
+An archive is a fully self-contained test run, and can be executed identically elsewhere.
+It includes the files the script open()s, and the ones matching the --asset patterns.`,
 	Example: `
   # Archive a test run.
   k6 archive -u 10 -d 10s -O myarchive.tar script.js
 
+  # Archive a test run along with files the script opens depending on the environment.
+  k6 archive --asset "./certs/*.pem" --asset "./protos/*.desc" script.js
+
   # Run the resulting archive.
   k6 run myarchive.tar`[1:],
 	Args: cobra.ExactArgs(1),
@@ -75,6 +79,9 @@ An archive is a fully self-contained test run, and can be executed identically e
 			return err
 		}
 
+		// Keep the git state of the script in the archive, since it's run elsewhere.
+		conf.Options = conf.Options.WithMetadata(scriptGitMetadata(conf.Options, filename, pwd))
+
 		err = r.SetOptions(conf.Options)
 		if err != nil {
 			return err
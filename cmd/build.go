@@ -0,0 +1,164 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const k6CmdPackage = "github.com/loadimpact/k6/cmd"
+
+var (
+	buildExtensions []string
+	buildOutput     = "k6"
+)
+
+var importPathRE = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._~-]*(/[a-zA-Z0-9._~-]+)+$`)
+
+var buildMainTemplate = template.Must(template.New("main.go").Parse(`// Code generated by "k6 build"; DO NOT EDIT.
+
+package main
+
+import (
+	"{{ .Cmd }}"
+{{ range .Extensions }}
+	_ "{{ . }}"
+{{- end }}
+)
+
+func main() {
+	cmd.Execute()
+}
+`))
+
+// A buildStep is a command that's run to build a k6 binary.
+type buildStep struct {
+	Dir  string
+	Args []string
+}
+
+// runBuildStep runs a build step in GOPATH mode, the way k6 itself is built.
+func runBuildStep(step buildStep) error {
+	c := exec.Command(step.Args[0], step.Args[1:]...)
+	c.Dir = step.Dir
+	c.Env = append(os.Environ(), "GO111MODULE=off")
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// buildCmd represents the build command
+var buildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build a k6 binary with extensions",
+	Long: `Build a k6 binary with extensions.
+
+Extensions are Go packages that add JS modules, with modules.Register(), or outputs, with
+lib.RegisterOutput(), in their init(). This generates a main package that imports them along
+with k6, fetches them into the GOPATH, and builds it with the go tool, which must be installed.`,
+	Example: `
+  # Build a k6 binary with a JS module and an output.
+  k6 build --with github.com/example/xk6-redis --with github.com/example/xk6-output-timescale
+
+  # The extensions show up in the version of the new binary.
+  ./k6 version`[1:],
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, err := filepath.Abs(buildOutput)
+		if err != nil {
+			return err
+		}
+		dir, err := ioutil.TempDir("", "k6-build")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+
+		steps, err := prepareBuild(dir, buildExtensions, output)
+		if err != nil {
+			return err
+		}
+		for _, step := range steps {
+			log.WithField("dir", step.Dir).Debug(strings.Join(step.Args, " "))
+			if err := runBuildStep(step); err != nil {
+				return errors.Wrapf(err, "'%s' failed", strings.Join(step.Args, " "))
+			}
+		}
+		log.WithField("extensions", len(buildExtensions)).Infof("Built %s", output)
+		return nil
+	},
+}
+
+// prepareBuild writes the main package of a k6 binary with extensions to dir, and returns the
+// steps to build it to output.
+func prepareBuild(dir string, extensions []string, output string) ([]buildStep, error) {
+	seen := make(map[string]bool)
+	for _, ext := range extensions {
+		if strings.Contains(ext, "@") {
+			return nil, errors.Errorf("invalid extension '%s': versions can't be pinned in GOPATH mode, "+
+				"check out the version to build in the GOPATH instead", ext)
+		}
+		if !importPathRE.MatchString(ext) {
+			return nil, errors.Errorf("invalid extension '%s', it must be the import path of a Go package", ext)
+		}
+		if seen[ext] {
+			return nil, errors.Errorf("the extension '%s' is given more than once", ext)
+		}
+		seen[ext] = true
+	}
+
+	var src bytes.Buffer
+	err := buildMainTemplate.Execute(&src, struct {
+		Cmd        string
+		Extensions []string
+	}{k6CmdPackage, extensions})
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), src.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+
+	// "go get -d" doesn't update packages that are in the GOPATH already.
+	get := append([]string{"go", "get", "-d", k6CmdPackage}, extensions...)
+	return []buildStep{
+		{Dir: dir, Args: get},
+		{Dir: dir, Args: []string{"go", "build", "-o", output, "."}},
+	}, nil
+}
+
+func init() {
+	RootCmd.AddCommand(buildCmd)
+	buildCmd.Flags().SortFlags = false
+	buildCmd.Flags().StringSliceVar(&buildExtensions, "with", nil, "build with the extension in this Go `package`")
+	buildCmd.Flags().StringVarP(&buildOutput, "output", "o", buildOutput, "the `file` to write the binary to")
+}
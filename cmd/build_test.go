@@ -0,0 +1,86 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k6-build-test")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	exts := []string{"github.com/example/xk6-redis", "github.com/example/xk6-output-timescale"}
+	steps, err := prepareBuild(dir, exts, "/tmp/k6")
+	require.NoError(t, err)
+	assert.Equal(t, []buildStep{
+		{Dir: dir, Args: []string{"go", "get", "-d", k6CmdPackage,
+			"github.com/example/xk6-redis", "github.com/example/xk6-output-timescale"}},
+		{Dir: dir, Args: []string{"go", "build", "-o", "/tmp/k6", "."}},
+	}, steps)
+
+	f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, "main.go"), nil, parser.ImportsOnly)
+	require.NoError(t, err)
+	assert.Equal(t, "main", f.Name.Name)
+	imports := make(map[string]string)
+	for _, imp := range f.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		require.NoError(t, err)
+		imports[path] = ""
+		if imp.Name != nil {
+			imports[path] = imp.Name.Name
+		}
+	}
+	assert.Equal(t, map[string]string{
+		k6CmdPackage:                              "",
+		"github.com/example/xk6-redis":            "_",
+		"github.com/example/xk6-output-timescale": "_",
+	}, imports)
+
+	t.Run("Invalid", func(t *testing.T) {
+		testdata := map[string]string{
+			"github.com/example/xk6-redis@v0.1.0": "invalid extension 'github.com/example/xk6-redis@v0.1.0': " +
+				"versions can't be pinned in GOPATH mode, check out the version to build in the GOPATH instead",
+			"xk6-redis":           "invalid extension 'xk6-redis', it must be the import path of a Go package",
+			"./xk6-redis":         "invalid extension './xk6-redis', it must be the import path of a Go package",
+			"github.com/a/b\"; x": "invalid extension 'github.com/a/b\"; x', it must be the import path of a Go package",
+		}
+		for ext, msg := range testdata {
+			t.Run(ext, func(t *testing.T) {
+				_, err := prepareBuild(dir, []string{ext}, "k6")
+				assert.EqualError(t, err, msg)
+			})
+		}
+
+		_, err := prepareBuild(dir, []string{exts[0], exts[0]}, "k6")
+		assert.EqualError(t, err, "the extension 'github.com/example/xk6-redis' is given more than once")
+	})
+}
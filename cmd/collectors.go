@@ -32,6 +32,8 @@ import (
 	"github.com/loadimpact/k6/stats/influxdb"
 	jsonc "github.com/loadimpact/k6/stats/json"
 	"github.com/loadimpact/k6/stats/kafka"
+	"github.com/loadimpact/k6/stats/otlp"
+	"github.com/loadimpact/k6/stats/statsd"
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 )
@@ -40,6 +42,9 @@ const (
 	collectorInfluxDB = "influxdb"
 	collectorJSON     = "json"
 	collectorKafka    = "kafka"
+	collectorOTLP     = "otlp"
+	collectorStatsD   = "statsd"
+	collectorDatadog  = "datadog"
 	collectorCloud    = "cloud"
 )
 
@@ -59,7 +64,18 @@ func newCollector(collectorName, arg string, src *lib.SourceData, conf Config) (
 	getCollector := func() (lib.Collector, error) {
 		switch collectorName {
 		case collectorJSON:
-			return jsonc.New(afero.NewOsFs(), arg)
+			config := jsonc.NewConfig().Apply(conf.Collectors.JSON)
+			if err := envconfig.Process("k6", &config); err != nil {
+				return nil, err
+			}
+			if arg != "" {
+				cmdConfig, err := jsonc.ParseArg(arg)
+				if err != nil {
+					return nil, err
+				}
+				config = config.Apply(cmdConfig)
+			}
+			return jsonc.New(afero.NewOsFs(), config)
 		case collectorInfluxDB:
 			config := influxdb.NewConfig().Apply(conf.Collectors.InfluxDB)
 			if err := envconfig.Process("k6", &config); err != nil {
@@ -93,7 +109,36 @@ func newCollector(collectorName, arg string, src *lib.SourceData, conf Config) (
 				config = config.Apply(cmdConfig)
 			}
 			return kafka.New(config)
+		case collectorOTLP:
+			config := otlp.NewConfig().Apply(conf.Collectors.OTLP)
+			if err := envconfig.Process("k6", &config); err != nil {
+				return nil, err
+			}
+			if arg != "" {
+				cmdConfig, err := otlp.ParseArg(arg)
+				if err != nil {
+					return nil, err
+				}
+				config = config.Apply(cmdConfig)
+			}
+			return otlp.New(config)
+		case collectorStatsD, collectorDatadog:
+			config := statsd.NewConfig().Apply(conf.Collectors.StatsD)
+			if err := envconfig.Process("k6", &config); err != nil {
+				return nil, err
+			}
+			if arg != "" {
+				cmdConfig, err := statsd.ParseArg(arg)
+				if err != nil {
+					return nil, err
+				}
+				config = config.Apply(cmdConfig)
+			}
+			return statsd.New(config, collectorName == collectorDatadog)
 		default:
+			if constructor, ok := lib.GetOutputExtension(collectorName); ok {
+				return constructor(lib.OutputParams{Arg: arg, Options: conf.Options, Source: src, Version: Version})
+			}
 			return nil, errors.Errorf("unknown output type: %s", collectorName)
 		}
 	}
@@ -0,0 +1,371 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/loadimpact/k6/stats"
+	jsonc "github.com/loadimpact/k6/stats/json"
+	"github.com/loadimpact/k6/ui"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareTolerance     = 10.0
+	compareRateTolerance = 1.0
+	compareStats         = []string{"avg", "p(95)"}
+	compareThresholds    []string
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Compare the results of two test runs",
+	Long: `Compare the results of two test runs.
+
+Reads the JSON output (--out json=...) of a baseline and a new run, and reports the changes in
+trend stats, rates and counters between them, along with how thresholds given with --threshold
+fare on each. Gzipped and rotated outputs can be read too; use a quoted glob pattern to read all
+of a run's files.
+
+A change is a regression if a trend stat grew by more than --tolerance percent, a rate changed
+for the worse by more than --rate-tolerance percentage points (lower is better for all rates
+but checks), or a threshold passed on the baseline but fails on the new run. The command exits
+with a non-zero code if there are any, so CI can fail builds on performance regressions.`,
+	Example: `
+  # Compare two runs
+  k6 compare baseline.json new.json
+
+  # Allow trend stats to grow by 20%, and compare the median and p(99) instead
+  k6 compare --tolerance 20 --stats med,"p(99)" baseline.json new.json
+
+  # Also check thresholds on both runs
+  k6 compare --threshold "http_req_duration=p(95)<500" --threshold "checks=rate>0.99" baseline.json new.json
+
+  # Compare rotated, gzipped outputs
+  k6 compare "baseline*.json.gz" "new*.json.gz"`[1:],
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf, err := newCompareConfig(compareTolerance, compareRateTolerance, compareStats, compareThresholds)
+		if err != nil {
+			return err
+		}
+
+		var submetrics []string
+		for _, th := range conf.Thresholds {
+			if strings.Contains(th.Metric, "{") {
+				submetrics = append(submetrics, th.Metric)
+			}
+		}
+		baseline, err := readCompareRun(args[0], submetrics)
+		if err != nil {
+			return err
+		}
+		current, err := readCompareRun(args[1], submetrics)
+		if err != nil {
+			return err
+		}
+
+		report, err := compareRuns(baseline, current, conf)
+		if err != nil {
+			return err
+		}
+		printCompareReport(stdout, report)
+
+		if n := report.Regressions(); n > 0 {
+			return ExitCode{errors.Errorf("%d performance regressions found", n), regressionsFoundErrorCode}
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().SortFlags = false
+	compareCmd.Flags().Float64Var(&compareTolerance, "tolerance", compareTolerance, "allowed growth of trend stats, in `percent`")
+	compareCmd.Flags().Float64Var(&compareRateTolerance, "rate-tolerance", compareRateTolerance, "allowed change of rates for the worse, in percentage `points`")
+	compareCmd.Flags().StringSliceVar(&compareStats, "stats", compareStats, "trend `stats` to compare, eg. 'avg,med,p(99)'")
+	compareCmd.Flags().StringArrayVar(&compareThresholds, "threshold", nil, "check a `threshold` on both runs, as 'metric=expression'")
+}
+
+// Reads all the files matching a glob pattern into a single run.
+func readCompareRun(pattern string, submetrics []string) (*jsonc.Run, error) {
+	fnames, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(fnames) == 0 {
+		return nil, errors.Errorf("no files match '%s'", pattern)
+	}
+
+	run := jsonc.NewRun(submetrics)
+	for _, fname := range fnames {
+		f, err := os.Open(fname)
+		if err != nil {
+			return nil, err
+		}
+		err = run.Read(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, fname)
+		}
+	}
+	return run, nil
+}
+
+type compareThreshold struct {
+	Metric string
+	Source string
+}
+
+type compareConfig struct {
+	Tolerance     float64
+	RateTolerance float64
+	Stats         []string
+	Thresholds    []compareThreshold
+}
+
+func newCompareConfig(tolerance, rateTolerance float64, trendStats, thresholds []string) (compareConfig, error) {
+	conf := compareConfig{Tolerance: tolerance, RateTolerance: rateTolerance, Stats: trendStats}
+	if tolerance < 0 || rateTolerance < 0 {
+		return conf, errors.New("tolerances can't be negative")
+	}
+	for _, stat := range trendStats {
+		if err := ui.VerifyTrendColumnStat(stat); err != nil {
+			return conf, errors.Wrapf(err, "stat '%s'", stat)
+		}
+	}
+	for _, th := range thresholds {
+		idx := strings.IndexRune(th, '=')
+		if idx <= 0 || idx == len(th)-1 {
+			return conf, errors.Errorf("invalid threshold '%s', expected 'metric=expression'", th)
+		}
+		conf.Thresholds = append(conf.Thresholds, compareThreshold{Metric: th[:idx], Source: th[idx+1:]})
+	}
+	return conf, nil
+}
+
+// A single compared value.
+type compareRow struct {
+	Metric     string
+	Stat       string
+	Baseline   float64
+	Current    float64
+	Humanize   func(float64) string
+	Regression bool
+}
+
+// Change returns the relative change of a trend stat or counter, or the absolute one of a rate.
+func (r compareRow) Change(rate bool) string {
+	if rate {
+		return fmt.Sprintf("%+.2fpp", 100*(r.Current-r.Baseline))
+	}
+	if r.Baseline == 0 {
+		if r.Current == 0 {
+			return "+0.00%"
+		}
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.2f%%", 100*(r.Current-r.Baseline)/math.Abs(r.Baseline))
+}
+
+type compareThresholdRow struct {
+	compareThreshold
+	Baseline, Current   bool
+	BaselineErr, CurErr error
+}
+
+type compareReport struct {
+	Trends     []compareRow
+	Rates      []compareRow
+	Counters   []compareRow
+	Thresholds []compareThresholdRow
+
+	// Metrics that only one of the runs has.
+	OnlyBaseline, OnlyCurrent []string
+}
+
+// Regressions returns the number of regressions in the report.
+func (r compareReport) Regressions() int {
+	n := 0
+	for _, rows := range [][]compareRow{r.Trends, r.Rates, r.Counters} {
+		for _, row := range rows {
+			if row.Regression {
+				n++
+			}
+		}
+	}
+	for _, th := range r.Thresholds {
+		if th.Baseline && !th.Current {
+			n++
+		}
+	}
+	return n
+}
+
+func compareRuns(baseline, current *jsonc.Run, conf compareConfig) (compareReport, error) {
+	var report compareReport
+
+	names := make([]string, 0, len(baseline.Metrics))
+	for name, m := range baseline.Metrics {
+		if m.Sub.Parent != "" {
+			continue
+		}
+		if _, ok := current.Metrics[name]; !ok {
+			report.OnlyBaseline = append(report.OnlyBaseline, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	for name, m := range current.Metrics {
+		if _, ok := baseline.Metrics[name]; !ok && m.Sub.Parent == "" {
+			report.OnlyCurrent = append(report.OnlyCurrent, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(report.OnlyBaseline)
+	sort.Strings(report.OnlyCurrent)
+
+	for _, name := range names {
+		b, c := baseline.Metrics[name], current.Metrics[name]
+		if b.Type != c.Type {
+			return report, errors.Errorf("metric '%s' is a %s in one run and a %s in the other", name, b.Type, c.Type)
+		}
+		humanize := func(v float64) string { return b.HumanizeValue(v, "") }
+
+		switch b.Type {
+		case stats.Trend:
+			for _, stat := range conf.Stats {
+				ref := stats.MetricRef{Metric: name, Stat: stat}
+				row := compareRow{
+					Metric: name, Stat: stat, Humanize: humanize,
+					Baseline: ref.Value(b, baseline.Duration()),
+					Current:  ref.Value(c, current.Duration()),
+				}
+				row.Regression = row.Baseline > 0 && row.Current > row.Baseline*(1+conf.Tolerance/100)
+				report.Trends = append(report.Trends, row)
+			}
+		case stats.Rate:
+			row := compareRow{
+				Metric: name, Stat: "rate", Humanize: humanize,
+				Baseline: stats.MetricRef{Metric: name}.Value(b, baseline.Duration()),
+				Current:  stats.MetricRef{Metric: name}.Value(c, current.Duration()),
+			}
+			change := row.Current - row.Baseline
+			if name == "checks" {
+				change = -change
+			}
+			row.Regression = change*100 > conf.RateTolerance
+			report.Rates = append(report.Rates, row)
+		case stats.Counter:
+			// Runs may have different durations, so it's the throughput that's compared.
+			row := compareRow{
+				Metric: name, Stat: "rate", Humanize: func(v float64) string { return humanize(v) + "/s" },
+				Baseline: stats.MetricRef{Metric: name, Stat: "rate"}.Value(b, baseline.Duration()),
+				Current:  stats.MetricRef{Metric: name, Stat: "rate"}.Value(c, current.Duration()),
+			}
+			report.Counters = append(report.Counters, row)
+		}
+	}
+
+	for _, th := range conf.Thresholds {
+		row := compareThresholdRow{compareThreshold: th}
+		row.Baseline, row.BaselineErr = runCompareThreshold(baseline, th)
+		row.Current, row.CurErr = runCompareThreshold(current, th)
+		report.Thresholds = append(report.Thresholds, row)
+	}
+	return report, nil
+}
+
+func runCompareThreshold(run *jsonc.Run, th compareThreshold) (bool, error) {
+	m, ok := run.Metrics[th.Metric]
+	if !ok {
+		return false, errors.Errorf("no metric '%s'", th.Metric)
+	}
+	ths, err := stats.NewThresholds([]string{th.Source})
+	if err != nil {
+		return false, err
+	}
+	return ths.Run(m.Sink, run.Duration())
+}
+
+func printCompareReport(w io.Writer, report compareReport) {
+	mark := func(regression bool) string {
+		if regression {
+			return ui.FailColor.Sprint(ui.FailMark)
+		}
+		return ui.SuccColor.Sprint(ui.SuccMark)
+	}
+
+	printRows := func(title string, rows []compareRow, rate bool) {
+		if len(rows) == 0 {
+			return
+		}
+		fprintf(w, "  %s\n", title)
+		for _, row := range rows {
+			name := row.Metric
+			if row.Stat != "" && !rate {
+				name += " " + row.Stat
+			}
+			fprintf(w, "    %s %s %s -> %s (%s)\n", mark(row.Regression), name,
+				ui.ValueColor.Sprint(row.Humanize(row.Baseline)), ui.ValueColor.Sprint(row.Humanize(row.Current)),
+				ui.ExtraColor.Sprint(row.Change(rate)))
+		}
+		fprintf(w, "\n")
+	}
+	printRows("trends", report.Trends, false)
+	printRows("rates", report.Rates, true)
+	printRows("counters", report.Counters, false)
+
+	if len(report.Thresholds) > 0 {
+		fprintf(w, "  thresholds\n")
+		result := func(ok bool, err error) string {
+			switch {
+			case err != nil:
+				return ui.ErrorColor.Sprint(err.Error())
+			case ok:
+				return ui.SuccColor.Sprint("pass")
+			default:
+				return ui.FailColor.Sprint("fail")
+			}
+		}
+		for _, th := range report.Thresholds {
+			fprintf(w, "    %s %s: %s (%s -> %s)\n", mark(th.Baseline && !th.Current), th.Metric, th.Source,
+				result(th.Baseline, th.BaselineErr), result(th.Current, th.CurErr))
+		}
+		fprintf(w, "\n")
+	}
+
+	if len(report.OnlyBaseline) > 0 {
+		fprintf(w, "  only in the baseline: %s\n", ui.GrayColor.Sprint(strings.Join(report.OnlyBaseline, ", ")))
+	}
+	if len(report.OnlyCurrent) > 0 {
+		fprintf(w, "  only in the new run: %s\n", ui.GrayColor.Sprint(strings.Join(report.OnlyCurrent, ", ")))
+	}
+}
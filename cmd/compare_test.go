@@ -0,0 +1,133 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	jsonc "github.com/loadimpact/k6/stats/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Generates a run's JSON output with the given request durations and check results, one
+// request per second.
+func testCompareRun(t *testing.T, durations []float64, checks []bool, submetrics ...string) *jsonc.Run {
+	var buf bytes.Buffer
+	buf.WriteString(`{"type":"Metric","metric":"http_req_duration","data":{"type":"trend","contains":"time"}}` + "\n")
+	buf.WriteString(`{"type":"Metric","metric":"http_reqs","data":{"type":"counter","contains":"default"}}` + "\n")
+	buf.WriteString(`{"type":"Metric","metric":"checks","data":{"type":"rate","contains":"default"}}` + "\n")
+	for i, d := range durations {
+		ts := fmt.Sprintf("1970-01-01T00:00:%02dZ", i)
+		fmt.Fprintf(&buf, `{"type":"Point","metric":"http_req_duration","data":{"time":"%s","value":%f,"tags":{"status":"200"}}}`+"\n", ts, d)
+		fmt.Fprintf(&buf, `{"type":"Point","metric":"http_reqs","data":{"time":"%s","value":1,"tags":{"status":"200"}}}`+"\n", ts)
+	}
+	for _, ok := range checks {
+		value := 0
+		if ok {
+			value = 1
+		}
+		fmt.Fprintf(&buf, `{"type":"Point","metric":"checks","data":{"time":"1970-01-01T00:00:00Z","value":%d,"tags":null}}`+"\n", value)
+	}
+	run := jsonc.NewRun(submetrics)
+	require.NoError(t, run.Read(&buf))
+	return run
+}
+
+func TestNewCompareConfig(t *testing.T) {
+	conf, err := newCompareConfig(10, 1, []string{"avg", "p(99)"}, []string{"http_req_duration=p(95)<=500", "checks=rate>0.9"})
+	require.NoError(t, err)
+	assert.Equal(t, []compareThreshold{
+		{Metric: "http_req_duration", Source: "p(95)<=500"},
+		{Metric: "checks", Source: "rate>0.9"},
+	}, conf.Thresholds)
+
+	_, err = newCompareConfig(-1, 1, nil, nil)
+	assert.EqualError(t, err, "tolerances can't be negative")
+	_, err = newCompareConfig(10, 1, []string{"p95"}, nil)
+	assert.EqualError(t, err, "stat 'p95': invalid stat, unknown format")
+	for _, th := range []string{"p(95)<500", "=p(95)<500", "http_req_duration="} {
+		_, err = newCompareConfig(10, 1, nil, []string{th})
+		assert.Error(t, err, th)
+	}
+}
+
+func TestCompareRuns(t *testing.T) {
+	baseline := testCompareRun(t, []float64{100, 100, 100, 100}, []bool{true, true, true, true}, "http_req_duration{status:200}")
+
+	t.Run("no regressions", func(t *testing.T) {
+		current := testCompareRun(t, []float64{105, 105, 105, 105}, []bool{true, true, true, true}, "http_req_duration{status:200}")
+		conf, err := newCompareConfig(10, 1, []string{"avg"}, []string{"http_req_duration{status:200}=avg<200"})
+		require.NoError(t, err)
+
+		report, err := compareRuns(baseline, current, conf)
+		require.NoError(t, err)
+		assert.Equal(t, 0, report.Regressions())
+		require.Len(t, report.Trends, 1)
+		assert.InDelta(t, 100, report.Trends[0].Baseline, 1)
+		assert.InDelta(t, 105, report.Trends[0].Current, 1.05)
+		require.Len(t, report.Counters, 1)
+		assert.Equal(t, "+0.00%", report.Counters[0].Change(false))
+		require.Len(t, report.Thresholds, 1)
+		assert.True(t, report.Thresholds[0].Baseline)
+		assert.True(t, report.Thresholds[0].Current)
+		assert.NoError(t, report.Thresholds[0].CurErr)
+	})
+
+	t.Run("regressions", func(t *testing.T) {
+		current := testCompareRun(t, []float64{150, 150, 150, 300}, []bool{true, true, true, false})
+		conf, err := newCompareConfig(10, 1, []string{"avg", "med"}, []string{"http_req_duration=max<200"})
+		require.NoError(t, err)
+
+		report, err := compareRuns(baseline, current, conf)
+		require.NoError(t, err)
+		// avg, med, the checks rate and the threshold.
+		assert.Equal(t, 4, report.Regressions())
+		require.Len(t, report.Rates, 1)
+		assert.Equal(t, "-25.00pp", report.Rates[0].Change(true))
+
+		var buf bytes.Buffer
+		printCompareReport(&buf, report)
+		out := buf.String()
+		assert.Contains(t, out, "http_req_duration avg")
+		assert.Contains(t, out, "http_req_duration: max<200 (pass -> fail)")
+	})
+
+	t.Run("missing metrics", func(t *testing.T) {
+		current := jsonc.NewRun(nil)
+		require.NoError(t, current.Read(strings.NewReader(
+			`{"type":"Metric","metric":"iterations","data":{"type":"counter","contains":"default"}}`,
+		)))
+		conf, err := newCompareConfig(10, 1, nil, []string{"http_req_duration=avg<200"})
+		require.NoError(t, err)
+
+		report, err := compareRuns(baseline, current, conf)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"checks", "http_req_duration", "http_reqs"}, report.OnlyBaseline)
+		assert.Equal(t, []string{"iterations"}, report.OnlyCurrent)
+		require.Len(t, report.Thresholds, 1)
+		assert.EqualError(t, report.Thresholds[0].CurErr, "no metric 'http_req_duration'")
+		assert.Equal(t, 1, report.Regressions())
+	})
+}
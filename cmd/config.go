@@ -29,7 +29,10 @@ import (
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/stats/cloud"
 	"github.com/loadimpact/k6/stats/influxdb"
+	jsonc "github.com/loadimpact/k6/stats/json"
 	"github.com/loadimpact/k6/stats/kafka"
+	"github.com/loadimpact/k6/stats/otlp"
+	"github.com/loadimpact/k6/stats/statsd"
 	"github.com/shibukawa/configdir"
 	"github.com/spf13/afero"
 	"github.com/spf13/pflag"
@@ -72,8 +75,11 @@ type Config struct {
 
 	Collectors struct {
 		InfluxDB influxdb.Config `json:"influxdb"`
+		JSON     jsonc.Config    `json:"json"`
 		Kafka    kafka.Config    `json:"kafka"`
 		Cloud    cloud.Config    `json:"cloud"`
+		OTLP     otlp.Config     `json:"otlp"`
+		StatsD   statsd.Config   `json:"statsd"`
 	} `json:"collectors"`
 }
 
@@ -95,8 +101,11 @@ func (c Config) Apply(cfg Config) Config {
 		c.NoSummary = cfg.NoSummary
 	}
 	c.Collectors.InfluxDB = c.Collectors.InfluxDB.Apply(cfg.Collectors.InfluxDB)
+	c.Collectors.JSON = c.Collectors.JSON.Apply(cfg.Collectors.JSON)
 	c.Collectors.Cloud = c.Collectors.Cloud.Apply(cfg.Collectors.Cloud)
 	c.Collectors.Kafka = c.Collectors.Kafka.Apply(cfg.Collectors.Kafka)
+	c.Collectors.OTLP = c.Collectors.OTLP.Apply(cfg.Collectors.OTLP)
+	c.Collectors.StatsD = c.Collectors.StatsD.Apply(cfg.Collectors.StatsD)
 	return c
 }
 
@@ -164,7 +173,10 @@ func readEnvConfig() (conf Config, err error) {
 		envconfig.Process("k6", &conf),
 		envconfig.Process("k6", &conf.Collectors.Cloud),
 		envconfig.Process("k6", &conf.Collectors.InfluxDB),
+		envconfig.Process("k6", &conf.Collectors.JSON),
 		envconfig.Process("k6", &conf.Collectors.Kafka),
+		envconfig.Process("k6", &conf.Collectors.OTLP),
+		envconfig.Process("k6", &conf.Collectors.StatsD),
 	} {
 		return conf, err
 	}
@@ -181,8 +193,11 @@ func readEnvConfig() (conf Config, err error) {
 // TODO: add better validation, more explicit default values and improve consistency between formats
 func getConsolidatedConfig(fs afero.Fs, cliConf Config, runner lib.Runner) (conf Config, err error) {
 	cliConf.Collectors.InfluxDB = influxdb.NewConfig().Apply(cliConf.Collectors.InfluxDB)
+	cliConf.Collectors.JSON = jsonc.NewConfig().Apply(cliConf.Collectors.JSON)
 	cliConf.Collectors.Cloud = cloud.NewConfig().Apply(cliConf.Collectors.Cloud)
 	cliConf.Collectors.Kafka = kafka.NewConfig().Apply(cliConf.Collectors.Kafka)
+	cliConf.Collectors.OTLP = otlp.NewConfig().Apply(cliConf.Collectors.OTLP)
+	cliConf.Collectors.StatsD = statsd.NewConfig().Apply(cliConf.Collectors.StatsD)
 
 	fileConf, _, err := readDiskConfig(fs)
 	if err != nil {
@@ -21,10 +21,12 @@
 package cmd
 
 import (
+	"crypto/tls"
 	"os"
 	"testing"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/loadimpact/k6/lib"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/guregu/null.v3"
 )
@@ -101,6 +103,15 @@ func TestConfigEnv(t *testing.T) {
 			"":         func(c Config) { assert.Equal(t, []string{""}, c.Out) },
 			"influxdb": func(c Config) { assert.Equal(t, []string{"influxdb"}, c.Out) },
 		},
+		{"TLSGroups", "K6_TLS_GROUPS"}: {
+			"": func(c Config) { assert.Equal(t, lib.TLSGroups(nil), c.TLSGroups) },
+			"X25519,P256": func(c Config) {
+				assert.Equal(t, lib.TLSGroups{tls.X25519, tls.CurveP256}, c.TLSGroups)
+			},
+			"P256, P384": func(c Config) {
+				assert.Equal(t, lib.TLSGroups{tls.CurveP256, tls.CurveP384}, c.TLSGroups)
+			},
+		},
 	}
 	for field, data := range testdata {
 		os.Clearenv()
@@ -21,13 +21,18 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
 	"io/ioutil"
 	"path/filepath"
 
 	"github.com/loadimpact/k6/converter/har"
+	"github.com/loadimpact/k6/converter/openapi"
+	"github.com/loadimpact/k6/converter/postman"
 	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	null "gopkg.in/guregu/null.v3"
 )
@@ -40,16 +45,58 @@ var (
 	enableChecks        bool
 	returnOnFailedCheck bool
 	correlate           bool
+	replayTiming        bool
 	threshold           uint
 	nobatch             bool
 	only                []string
 	skip                []string
+	inputFormat         string
 )
 
+// Input formats supported by the convert command.
+const (
+	inputFormatHAR     = "har"
+	inputFormatOpenAPI = "openapi"
+	inputFormatPostman = "postman"
+)
+
+// detectInputFormat guesses the format of a convert input from its top-level keys. Anything
+// that isn't a JSON object is assumed to be a YAML OpenAPI spec.
+func detectInputFormat(data []byte) (string, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return inputFormatOpenAPI, nil
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &keys); err != nil {
+		return "", err
+	}
+	if _, ok := keys["log"]; ok {
+		return inputFormatHAR, nil
+	}
+	if _, ok := keys["openapi"]; ok {
+		return inputFormatOpenAPI, nil
+	}
+	if _, ok := keys["swagger"]; ok {
+		return "", errors.New("only OpenAPI 3 specs are supported, Swagger 2.0 specs have to be converted first")
+	}
+	_, hasInfo := keys["info"]
+	_, hasItem := keys["item"]
+	if hasInfo && hasItem {
+		return inputFormatPostman, nil
+	}
+	return "", errors.New("couldn't detect the input format, please specify it with --input-format")
+}
+
 var convertCmd = &cobra.Command{
 	Use:   "convert",
-	Short: "Convert a HAR file to a k6 script",
-	Long:  "Convert a HAR (HTTP Archive) file to a k6 script",
+	Short: "Convert a HAR file, OpenAPI spec or Postman collection to a k6 script",
+	Long: `Convert a HAR (HTTP Archive) file, an OpenAPI 3 spec or a Postman collection to a k6 script.
+
+The input format is detected automatically, use --input-format to override it. Scripts
+generated from OpenAPI specs and Postman collections contain a group for each endpoint or
+folder, and read any credentials from environment variables.`,
 	Example: `
   # Convert a HAR file to a k6 script.
   k6 convert -O har-session.js session.har
@@ -60,29 +107,41 @@ var convertCmd = &cobra.Command{
   # Convert a HAR file. Batching requests together as long as idle time between requests <800ms
   k6 convert --batch-threshold 800 session.har
 
+  # Convert a HAR file, reproducing the recorded think time and connection reuse.
+  k6 convert --no-batch --replay-timing session.har
+
+  # Convert an OpenAPI 3 spec, checking the documented status code of every response.
+  k6 convert -O api.js --enable-status-code-checks openapi.yaml
+
+  # Convert a Postman collection.
+  k6 convert -O collection.js collection.postman_collection.json
+
   # Run the k6 script.
   k6 run har-session.js`[1:],
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Parse the HAR file
+		// Read the input file
 		filePath, err := filepath.Abs(args[0])
 		if err != nil {
 			return err
 		}
-		r, err := defaultFs.Open(filePath)
+		data, err := afero.ReadFile(defaultFs, filePath)
 		if err != nil {
 			return err
 		}
-		h, err := har.Decode(r)
-		if err != nil {
-			return err
-		}
-		if err := r.Close(); err != nil {
-			return err
+
+		format := inputFormat
+		if format == "" {
+			if format, err = detectInputFormat(data); err != nil {
+				return err
+			}
 		}
 
-		// recordings include redirections as separate requests, and we dont want to trigger them twice
-		options := lib.Options{MaxRedirects: null.IntFrom(0)}
+		var options lib.Options
+		if format == inputFormatHAR {
+			// recordings include redirections as separate requests, and we dont want to trigger them twice
+			options.MaxRedirects = null.IntFrom(0)
+		}
 
 		if optionsFilePath != "" {
 			optionsFileContents, err := ioutil.ReadFile(optionsFilePath)
@@ -96,10 +155,36 @@ var convertCmd = &cobra.Command{
 			options = options.Apply(injectedOptions)
 		}
 
-		//TODO: refactor...
-		script, err := har.Convert(h, options, minSleep, maxSleep, enableChecks, returnOnFailedCheck, threshold, nobatch, correlate, only, skip)
-		if err != nil {
-			return err
+		var script string
+		switch format {
+		case inputFormatHAR:
+			h, err := har.Decode(bytes.NewReader(data))
+			if err != nil {
+				return err
+			}
+			//TODO: refactor...
+			script, err = har.Convert(h, options, minSleep, maxSleep, enableChecks, returnOnFailedCheck, threshold, nobatch, correlate, replayTiming, only, skip)
+			if err != nil {
+				return err
+			}
+		case inputFormatOpenAPI:
+			spec, err := openapi.Decode(data)
+			if err != nil {
+				return err
+			}
+			if script, err = openapi.Convert(spec, options, enableChecks); err != nil {
+				return err
+			}
+		case inputFormatPostman:
+			collection, err := postman.Decode(data)
+			if err != nil {
+				return err
+			}
+			if script, err = postman.Convert(collection, options, enableChecks); err != nil {
+				return err
+			}
+		default:
+			return errors.Errorf("unknown input format '%s'", format)
 		}
 
 		// Write script content to stdout or file
@@ -131,6 +216,7 @@ func init() {
 	convertCmd.Flags().SortFlags = false
 	convertCmd.Flags().StringVarP(&output, "output", "O", output, "k6 script output filename (stdout by default)")
 	convertCmd.Flags().StringVarP(&optionsFilePath, "options", "", output, "path to a JSON file with options that would be injected in the output script")
+	convertCmd.Flags().StringVarP(&inputFormat, "input-format", "", "", "input format: har, openapi or postman (detected automatically by default)")
 	convertCmd.Flags().StringSliceVarP(&only, "only", "", []string{}, "include only requests from the given domains")
 	convertCmd.Flags().StringSliceVarP(&skip, "skip", "", []string{}, "skip requests from the given domains")
 	convertCmd.Flags().UintVarP(&threshold, "batch-threshold", "", 500, "batch request idle time threshold (see example)")
@@ -138,6 +224,7 @@ func init() {
 	convertCmd.Flags().BoolVarP(&enableChecks, "enable-status-code-checks", "", false, "add a status code check for each HTTP response")
 	convertCmd.Flags().BoolVarP(&returnOnFailedCheck, "return-on-failed-check", "", false, "return from iteration if we get an unexpected response status code")
 	convertCmd.Flags().BoolVarP(&correlate, "correlate", "", false, "detect values in responses being used in subsequent requests and try adapt the script accordingly (only redirects and JSON values for now)")
+	convertCmd.Flags().BoolVarP(&replayTiming, "replay-timing", "", false, "sleep for the recorded think time between requests and derive the connection reuse options from the recording (HAR only)")
 	convertCmd.Flags().UintVarP(&minSleep, "min-sleep", "", 20, "the minimum amount of seconds to sleep after each iteration")
 	convertCmd.Flags().UintVarP(&maxSleep, "max-sleep", "", 40, "the maximum amount of seconds to sleep after each iteration")
 }
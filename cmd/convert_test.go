@@ -121,6 +121,29 @@ export default function() {
 }
 `
 
+const testOpenAPI = `
+openapi: 3.0.0
+info:
+  title: Ping
+  version: "1.0"
+servers:
+  - url: https://example.com
+paths:
+  /ping:
+    get:
+      responses:
+        "200":
+          description: pong
+`
+
+const testPostman = `{
+	"info": {
+		"name": "Ping",
+		"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+	},
+	"item": [{"name": "Ping", "request": "https://example.com/ping"}]
+}`
+
 func TestIntegrationConvertCmd(t *testing.T) {
 	var tmpFile, err = ioutil.TempFile("", "")
 	if err != nil {
@@ -203,6 +226,41 @@ func TestIntegrationConvertCmd(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, testHARConvertResult, string(output))
 	})
+	t.Run("OpenAPI", func(t *testing.T) {
+		defaultFs = afero.NewMemMapFs()
+		err := afero.WriteFile(defaultFs, harFile, []byte(testOpenAPI), 0644)
+		assert.NoError(t, err)
+
+		buf := &bytes.Buffer{}
+		defaultWriter = buf
+
+		assert.NoError(t, convertCmd.Flags().Set("output", ""))
+		err = convertCmd.RunE(convertCmd, []string{harFile})
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), "group(\"/ping\", function() {")
+		assert.Contains(t, buf.String(), "http.get(`${BASE_URL}/ping`")
+	})
+	t.Run("Postman", func(t *testing.T) {
+		defaultFs = afero.NewMemMapFs()
+		err := afero.WriteFile(defaultFs, harFile, []byte(testPostman), 0644)
+		assert.NoError(t, err)
+
+		buf := &bytes.Buffer{}
+		defaultWriter = buf
+
+		assert.NoError(t, convertCmd.Flags().Set("output", ""))
+		err = convertCmd.RunE(convertCmd, []string{harFile})
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), "http.get(`https://example.com/ping`")
+	})
+	t.Run("Swagger", func(t *testing.T) {
+		defaultFs = afero.NewMemMapFs()
+		err := afero.WriteFile(defaultFs, harFile, []byte(`{"swagger": "2.0"}`), 0644)
+		assert.NoError(t, err)
+
+		err = convertCmd.RunE(convertCmd, []string{harFile})
+		assert.EqualError(t, err, "only OpenAPI 3 specs are supported, Swagger 2.0 specs have to be converted first")
+	})
 	// TODO: test options injection; right now that's difficult because when there are multiple
 	// options, they can be emitted in different order in the JSON
 }
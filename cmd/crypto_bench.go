@@ -0,0 +1,279 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"fmt"
+	"hash"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	k6crypto "github.com/loadimpact/k6/js/modules/k6/crypto"
+	"github.com/loadimpact/k6/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cryptoBenchDuration = 1 * time.Second
+	cryptoBenchSize     = 1024
+)
+
+// cryptoBenchCmd represents the crypto-bench command
+var cryptoBenchCmd = &cobra.Command{
+	Use:   "crypto-bench",
+	Short: "Benchmark crypto primitives on this machine",
+	Long: `Benchmark crypto primitives on this machine.
+
+Runs the most common hash and HMAC algorithms of k6/crypto, and AES-GCM, which scripts can't
+use but most TLS connections encrypt their traffic with, each for a fixed amount of time, and
+reports their throughput, along with the hardware acceleration the CPU advertises. Use it to
+size load generators before running tests that hash, sign or encrypt large amounts of traffic.`,
+	Example: `
+  # Benchmark with the defaults (1s per primitive, 1KiB payloads)
+  k6 crypto-bench
+
+  # Benchmark large payloads for longer
+  k6 crypto-bench --duration 5s --size 1048576`[1:],
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cryptoBenchDuration <= 0 {
+			return fmt.Errorf("duration must be positive, got %s", cryptoBenchDuration)
+		}
+		if cryptoBenchSize <= 0 {
+			return fmt.Errorf("size must be positive, got %d", cryptoBenchSize)
+		}
+
+		fprintf(stdout, "  cpu: %s\n", ui.ValueColor.Sprintf("%s/%s, %d cores", runtime.GOOS, runtime.GOARCH, runtime.NumCPU()))
+		fprintf(stdout, "  acceleration: %s\n", ui.ValueColor.Sprint(formatCPUFeatures(detectCPUFeatures())))
+		fprintf(stdout, "  payload: %s, duration: %s per primitive\n\n",
+			ui.ValueColor.Sprint(humanize.IBytes(uint64(cryptoBenchSize))), ui.ValueColor.Sprint(cryptoBenchDuration))
+
+		for _, res := range runCryptoBenchmarks(cryptoBenchmarks(), cryptoBenchSize, cryptoBenchDuration) {
+			printCryptoBenchResult(stdout, res)
+		}
+		return nil
+	},
+}
+
+// A cryptoBenchmark is a single primitive to benchmark. New is called once per run, and must
+// return a function that processes the given payload once.
+type cryptoBenchmark struct {
+	Name string
+	New  func() (func(data []byte), error)
+}
+
+// The result of benchmarking a single primitive.
+type cryptoBenchResult struct {
+	Name     string
+	Ops      int64
+	Bytes    int64
+	Duration time.Duration
+	Err      error
+}
+
+// OpsPerSecond returns the number of operations performed per second.
+func (r cryptoBenchResult) OpsPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Ops) / r.Duration.Seconds()
+}
+
+// BytesPerSecond returns the number of payload bytes processed per second.
+func (r cryptoBenchResult) BytesPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Duration.Seconds()
+}
+
+func hashBenchmark(name string, fn func() hash.Hash) cryptoBenchmark {
+	return cryptoBenchmark{name, func() (func([]byte), error) {
+		h := fn()
+		return func(data []byte) {
+			h.Reset()
+			_, _ = h.Write(data)
+			h.Sum(nil)
+		}, nil
+	}}
+}
+
+func hmacBenchmark(name string, fn func() hash.Hash) cryptoBenchmark {
+	return hashBenchmark(name, func() hash.Hash {
+		return hmac.New(fn, []byte("k6 crypto-bench key"))
+	})
+}
+
+func aesGCMBenchmark(name string, keySize int) cryptoBenchmark {
+	return cryptoBenchmark{name, func() (func([]byte), error) {
+		block, err := aes.NewCipher(make([]byte, keySize))
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		var dst []byte
+		return func(data []byte) {
+			dst = aead.Seal(dst[:0], nonce, data, nil)
+		}, nil
+	}}
+}
+
+// cryptoBenchmarks returns the primitives benchmarked by crypto-bench: every hash that k6/crypto
+// offers, both plain and as an HMAC, followed by AES-GCM.
+func cryptoBenchmarks() []cryptoBenchmark {
+	names := make([]string, 0, len(k6crypto.HashFuncs))
+	for name := range k6crypto.HashFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	benchmarks := make([]cryptoBenchmark, 0, 2*len(names)+2)
+	for _, name := range names {
+		benchmarks = append(benchmarks, hashBenchmark(name, k6crypto.HashFuncs[name]))
+	}
+	for _, name := range names {
+		benchmarks = append(benchmarks, hmacBenchmark("hmac-"+name, k6crypto.HashFuncs[name]))
+	}
+	return append(benchmarks,
+		aesGCMBenchmark("aes-128-gcm", 16),
+		aesGCMBenchmark("aes-256-gcm", 32),
+	)
+}
+
+// runCryptoBenchmarks runs each benchmark against a payload of the given size for (roughly) the
+// given duration each.
+func runCryptoBenchmarks(benchmarks []cryptoBenchmark, size int, duration time.Duration) []cryptoBenchResult {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	results := make([]cryptoBenchResult, len(benchmarks))
+	for i, b := range benchmarks {
+		results[i] = runCryptoBenchmark(b, data, duration)
+	}
+	return results
+}
+
+func runCryptoBenchmark(b cryptoBenchmark, data []byte, duration time.Duration) cryptoBenchResult {
+	res := cryptoBenchResult{Name: b.Name}
+	fn, err := b.New()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	// Checking the clock on every operation would skew results for small payloads, so do it in
+	// batches, which keep doubling in size until a single batch takes at least 10ms.
+	start := time.Now()
+	batch := int64(1)
+	for res.Duration < duration {
+		batchStart := time.Now()
+		for i := int64(0); i < batch; i++ {
+			fn(data)
+		}
+		res.Ops += batch
+		res.Duration = time.Since(start)
+		if time.Since(batchStart) < 10*time.Millisecond {
+			batch *= 2
+		}
+	}
+	res.Bytes = res.Ops * int64(len(data))
+	return res
+}
+
+func printCryptoBenchResult(w io.Writer, res cryptoBenchResult) {
+	if res.Err != nil {
+		fprintf(w, "  %-12s %s\n", res.Name, ui.FailColor.Sprint(res.Err.Error()))
+		return
+	}
+	fprintf(w, "  %-12s %s/s %s\n",
+		res.Name,
+		ui.ValueColor.Sprintf("%10s", humanize.IBytes(uint64(res.BytesPerSecond()))),
+		ui.ExtraColor.Sprintf("%.0f ops/s", res.OpsPerSecond()),
+	)
+}
+
+// A cpuFeature is a hardware acceleration capability relevant to crypto performance.
+type cpuFeature struct {
+	Name      string
+	Supported bool
+}
+
+func formatCPUFeatures(features []cpuFeature) string {
+	if len(features) == 0 {
+		return "unknown"
+	}
+	parts := make([]string, len(features))
+	for i, f := range features {
+		if f.Supported {
+			parts[i] = f.Name
+		} else {
+			parts[i] = "no " + f.Name
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseCPUFlags picks the crypto related features out of a /proc/cpuinfo style flag list.
+func parseCPUFlags(flags []string) []cpuFeature {
+	have := make(map[string]bool, len(flags))
+	for _, flag := range flags {
+		have[flag] = true
+	}
+
+	var features []cpuFeature
+	switch {
+	case have["sse2"]: // x86
+		features = append(features,
+			cpuFeature{"aes-ni", have["aes"]},
+			cpuFeature{"pclmulqdq", have["pclmulqdq"]},
+			cpuFeature{"sha-ni", have["sha_ni"]},
+		)
+	case have["asimd"]: // arm64
+		features = append(features,
+			cpuFeature{"aes", have["aes"]},
+			cpuFeature{"pmull", have["pmull"]},
+			cpuFeature{"sha1", have["sha1"]},
+			cpuFeature{"sha2", have["sha2"]},
+			cpuFeature{"sha512", have["sha512"]},
+		)
+	}
+	return features
+}
+
+func init() {
+	RootCmd.AddCommand(cryptoBenchCmd)
+	cryptoBenchCmd.Flags().SortFlags = false
+	cryptoBenchCmd.Flags().DurationVarP(&cryptoBenchDuration, "duration", "d", cryptoBenchDuration, "time to spend benchmarking each primitive")
+	cryptoBenchCmd.Flags().IntVarP(&cryptoBenchSize, "size", "s", cryptoBenchSize, "payload size in bytes")
+}
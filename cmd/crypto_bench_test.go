@@ -0,0 +1,97 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCryptoBenchmarks(t *testing.T) {
+	results := runCryptoBenchmarks(cryptoBenchmarks(), 64, 5*time.Millisecond)
+	if !assert.Len(t, results, len(cryptoBenchmarks())) {
+		return
+	}
+	for _, res := range results {
+		t.Run(res.Name, func(t *testing.T) {
+			assert.NoError(t, res.Err)
+			assert.True(t, res.Ops > 0)
+			assert.Equal(t, res.Ops*64, res.Bytes)
+			assert.True(t, res.Duration >= 5*time.Millisecond)
+			assert.True(t, res.OpsPerSecond() > 0)
+			assert.True(t, res.BytesPerSecond() > 0)
+		})
+	}
+
+	t.Run("Names", func(t *testing.T) {
+		var names []string
+		for _, b := range cryptoBenchmarks() {
+			names = append(names, b.Name)
+		}
+		assert.Contains(t, names, "sm3")
+		assert.Contains(t, names, "hmac-sm3")
+		assert.Contains(t, names, "aes-256-gcm")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		res := runCryptoBenchmark(cryptoBenchmark{"broken", func() (func([]byte), error) {
+			return nil, errors.New("nope")
+		}}, nil, time.Millisecond)
+		assert.EqualError(t, res.Err, "nope")
+		assert.Equal(t, int64(0), res.Ops)
+		assert.Equal(t, float64(0), res.OpsPerSecond())
+
+		var buf bytes.Buffer
+		printCryptoBenchResult(&buf, res)
+		assert.Contains(t, buf.String(), "nope")
+	})
+}
+
+func TestParseCPUFlags(t *testing.T) {
+	testdata := map[string]struct {
+		flags    []string
+		features []cpuFeature
+		str      string
+	}{
+		"Unknown": {[]string{"fpu", "vme"}, nil, "unknown"},
+		"x86": {
+			[]string{"fpu", "sse2", "aes", "pclmulqdq"},
+			[]cpuFeature{{"aes-ni", true}, {"pclmulqdq", true}, {"sha-ni", false}},
+			"aes-ni, pclmulqdq, no sha-ni",
+		},
+		"arm64": {
+			[]string{"fp", "asimd", "aes", "pmull", "sha1", "sha2"},
+			[]cpuFeature{{"aes", true}, {"pmull", true}, {"sha1", true}, {"sha2", true}, {"sha512", false}},
+			"aes, pmull, sha1, sha2, no sha512",
+		},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			features := parseCPUFlags(data.flags)
+			assert.Equal(t, data.features, features)
+			assert.Equal(t, data.str, formatCPUFeatures(features))
+		})
+	}
+}
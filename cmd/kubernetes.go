@@ -0,0 +1,432 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	// Where the archive is mounted in the pods.
+	kubeArchiveDir  = "/k6"
+	kubeArchiveFile = "archive.tar"
+
+	// Label set on all resources of a run.
+	kubeRunLabel = "k6-run"
+)
+
+var (
+	kubeParallelism = 1
+	kubeName        = ""
+	kubeNamespace   = os.Getenv("K6_KUBERNETES_NAMESPACE")
+	kubeImage       = "loadimpact/k6:" + Version
+	kubectlPath     = "kubectl"
+	kubeKeep        = false
+	kubeOut         []string
+)
+
+var kubernetesCmd = &cobra.Command{
+	Use:     "kubernetes",
+	Aliases: []string{"k8s"},
+	Short:   "Run a test on a Kubernetes cluster",
+	Long: `Run a test on a Kubernetes cluster.
+
+The test is split across a number of Jobs, each running one pod with a share of the VUs,
+iterations, stage targets and RPS limit, and its own execution segment, which feeders, client
+certificate pools and unique IDs are partitioned by. The pods' output is streamed back, and all resources
+are deleted once they're done. kubectl must be installed and configured for the cluster.
+
+Each pod evaluates thresholds and prints an end-of-test summary for its own share of the test,
+so use an output (-o) to look at the results as a whole.`,
+	Example: `
+  # Run a test with 100 VUs on 4 pods, 25 VUs each.
+  k6 kubernetes --parallelism 4 -u 100 -d 10m script.js
+
+  # Send the metrics of all pods to InfluxDB.
+  k6 kubernetes --parallelism 4 -o influxdb=http://influxdb:8086/k6 script.js`[1:],
+	Args: exactArgsWithMsg(1, "arg should either be \"-\", if reading script from stdin, or a path to a script file"),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		filename := args[0]
+		fs := afero.NewOsFs()
+		src, err := readSource(filename, pwd, fs, os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		runtimeOptions, err := getRuntimeOptions(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		r, err := newRunner(src, runType, fs, runtimeOptions)
+		if err != nil {
+			return err
+		}
+
+		cliOpts, err := getOptions(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		conf, err := getConsolidatedConfig(fs, Config{Options: cliOpts}, r)
+		if err != nil {
+			return err
+		}
+		if err := r.SetOptions(conf.Options); err != nil {
+			return err
+		}
+
+		// All pods share a run ID, so their metrics can be told apart from other runs'.
+		if !conf.RunID.Valid || conf.RunID.String == "" {
+			conf.RunID = null.StringFrom(lib.NewRunID())
+		}
+
+		// Work out what each pod runs before creating anything.
+		podArgs := make([][]string, kubeParallelism)
+		for i := range podArgs {
+			if podArgs[i], err = kubePodArgs(conf.Options, kubeParallelism, i, kubeOut); err != nil {
+				return err
+			}
+		}
+
+		f, err := ioutil.TempFile("", "k6-archive")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.Remove(f.Name()) }()
+		if err := r.MakeArchive().Write(f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+		name := kubeName
+		if name == "" {
+			name = fmt.Sprintf("k6-%d", time.Now().Unix())
+		}
+		k := kubectl{path: kubectlPath, namespace: kubeNamespace}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigC := make(chan os.Signal, 1)
+		signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigC)
+		go func() {
+			select {
+			case sig := <-sigC:
+				log.WithField("sig", sig).Debug("Stopping the pods in response to signal")
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		// Always clean up with a fresh context, in case the test was cancelled.
+		if !kubeKeep {
+			defer func() {
+				log.WithField("run", name).Info("Deleting the Kubernetes resources")
+				cleanupCtx := context.Background()
+				if _, err := k.run(cleanupCtx, nil, "delete", "job", "-l", kubeRunLabel+"="+name); err != nil {
+					log.WithError(err).Error("Couldn't delete the jobs")
+				}
+				if _, err := k.run(cleanupCtx, nil, "delete", "configmap", name); err != nil {
+					log.WithError(err).Error("Couldn't delete the config map")
+				}
+			}()
+		}
+
+		log.WithFields(log.Fields{"run": name, "pods": kubeParallelism}).Info("Creating the Kubernetes resources")
+		if _, err := k.run(ctx, nil, "create", "configmap", name, "--from-file="+kubeArchiveFile+"="+f.Name()); err != nil {
+			return err
+		}
+		jobNames := make([]string, kubeParallelism)
+		for i, args := range podArgs {
+			jobNames[i] = fmt.Sprintf("%s-%d", name, i)
+			manifest, err := json.Marshal(newKubeJob(jobNames[i], name, kubeImage, args))
+			if err != nil {
+				return err
+			}
+			if _, err := k.run(ctx, bytes.NewReader(manifest), "create", "-f", "-"); err != nil {
+				return err
+			}
+		}
+
+		// Stream every pod's output, prefixed with its job's name, and collect the exit codes.
+		var outMu sync.Mutex
+		codes := make([]int, len(jobNames))
+		errs := make([]error, len(jobNames))
+		var wg sync.WaitGroup
+		for i, jobName := range jobNames {
+			wg.Add(1)
+			go func(i int, jobName string) {
+				defer wg.Done()
+				if err := k.streamLogs(ctx, jobName, &outMu); err != nil {
+					log.WithError(err).WithField("job", jobName).Warn("Couldn't stream the pod's output")
+				}
+				codes[i], errs[i] = k.waitForExitCode(ctx, jobName)
+			}(i, jobName)
+		}
+		wg.Wait()
+
+		exitCode := 0
+		for i, jobName := range jobNames {
+			switch {
+			case errs[i] != nil:
+				log.WithError(errs[i]).WithField("job", jobName).Error("Couldn't get the pod's exit code")
+				if exitCode == 0 {
+					exitCode = -1
+				}
+			case codes[i] != 0:
+				log.WithFields(log.Fields{"job": jobName, "code": codes[i]}).Error("Pod failed")
+				if exitCode == 0 {
+					exitCode = codes[i]
+				}
+			}
+		}
+		if exitCode != 0 {
+			return ExitCode{errors.New("some pods have failed"), exitCode}
+		}
+		return nil
+	},
+}
+
+// kubeShare returns the i'th of n shares of the given total, as even as possible.
+func kubeShare(total int64, n, i int) int64 {
+	share := total / int64(n)
+	if int64(i) < total%int64(n) {
+		share++
+	}
+	return share
+}
+
+// kubePodArgs returns the arguments for `k6 run` in the i'th of n pods, which runs the i'th
+// execution segment and gets its share of the VUs, iterations, stage targets and RPS limit. The
+// rest of the options are in the archive.
+func kubePodArgs(opts lib.Options, n, i int, out []string) ([]string, error) {
+	if n < 1 {
+		return nil, errors.New("parallelism must be at least 1")
+	}
+
+	// Same as `k6 run` does, if -m/--max isn't specified.
+	vusMax := opts.VUsMax
+	if !vusMax.Valid {
+		vusMax = opts.VUs
+		for _, stage := range opts.Stages {
+			if stage.Target.Valid && stage.Target.Int64 > vusMax.Int64 {
+				vusMax = stage.Target
+			}
+		}
+	}
+	if vusMax.Int64 < int64(n) {
+		return nil, errors.Errorf("can't split %d VUs across %d pods", vusMax.Int64, n)
+	}
+	if opts.Iterations.Valid && opts.Iterations.Int64 < int64(n) {
+		return nil, errors.Errorf("can't split %d iterations across %d pods", opts.Iterations.Int64, n)
+	}
+	if opts.RPS.Valid && opts.RPS.Int64 > 0 && opts.RPS.Int64 < int64(n) {
+		return nil, errors.Errorf("can't split an RPS limit of %d across %d pods", opts.RPS.Int64, n)
+	}
+
+	args := []string{
+		"run",
+		"--execution-segment", lib.ExecutionSegment{Index: int64(i), Count: int64(n)}.String(),
+		"--vus", strconv.FormatInt(kubeShare(opts.VUs.Int64, n, i), 10),
+		"--max", strconv.FormatInt(kubeShare(vusMax.Int64, n, i), 10),
+	}
+	if opts.Iterations.Valid {
+		args = append(args, "--iterations", strconv.FormatInt(kubeShare(opts.Iterations.Int64, n, i), 10))
+	}
+	for _, stage := range opts.Stages {
+		s := time.Duration(stage.Duration.Duration).String() + ":"
+		if stage.Target.Valid {
+			s += strconv.FormatInt(kubeShare(stage.Target.Int64, n, i), 10)
+		}
+		args = append(args, "--stage", s)
+	}
+	if opts.RPS.Valid && opts.RPS.Int64 > 0 {
+		args = append(args, "--rps", strconv.FormatInt(kubeShare(opts.RPS.Int64, n, i), 10))
+	}
+	if opts.RunID.Valid {
+		args = append(args, "--run-id", opts.RunID.String)
+	}
+
+	// Tags given on the command line replace the others, so pass all of them along.
+	tags := map[string]string{}
+	if opts.RunTags != nil {
+		tags = opts.RunTags.CloneTags()
+	}
+	tags["instance"] = strconv.Itoa(i)
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--tag", k+"="+tags[k])
+	}
+
+	for _, o := range out {
+		args = append(args, "--out", o)
+	}
+	return append(args, kubeArchiveDir+"/"+kubeArchiveFile), nil
+}
+
+// newKubeJob returns the manifest of a Job that runs k6 once, with the archive in the given
+// config map.
+func newKubeJob(name, run, image string, args []string) map[string]interface{} {
+	labels := map[string]string{kubeRunLabel: run}
+	return map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]interface{}{"name": name, "labels": labels},
+		"spec": map[string]interface{}{
+			"backoffLimit": 0,
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": labels},
+				"spec": map[string]interface{}{
+					"restartPolicy": "Never",
+					"containers": []interface{}{map[string]interface{}{
+						"name":         "k6",
+						"image":        image,
+						"args":         args,
+						"volumeMounts": []interface{}{map[string]interface{}{"name": "archive", "mountPath": kubeArchiveDir}},
+					}},
+					"volumes": []interface{}{map[string]interface{}{
+						"name":      "archive",
+						"configMap": map[string]interface{}{"name": run},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// kubectl runs kubectl commands in a namespace, or in the current one if it's empty.
+type kubectl struct {
+	path      string
+	namespace string
+}
+
+func (k kubectl) command(ctx context.Context, args ...string) *exec.Cmd {
+	if k.namespace != "" {
+		args = append([]string{"--namespace", k.namespace}, args...)
+	}
+	return exec.CommandContext(ctx, k.path, args...)
+}
+
+// run runs a command and returns its output, or an error with what it wrote to stderr.
+func (k kubectl) run(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := k.command(ctx, args...)
+	cmd.Stdin = stdin
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "kubectl %s: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// streamLogs writes the output of a job's pod to stdout until it's done, prefixing every line
+// with the job's name.
+func (k kubectl) streamLogs(ctx context.Context, job string, mu *sync.Mutex) error {
+	var stderr bytes.Buffer
+	cmd := k.command(ctx, "logs", "--follow", "--pod-running-timeout=5m", "job/"+job)
+	cmd.Stderr = &stderr
+	pipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(pipe)
+	for scanner.Scan() {
+		mu.Lock()
+		fprintf(stdout, "%s | %s\n", job, scanner.Text())
+		mu.Unlock()
+	}
+	if err := cmd.Wait(); err != nil {
+		return errors.Wrap(err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// waitForExitCode waits until a job's pod has terminated, and returns its exit code.
+func (k kubectl) waitForExitCode(ctx context.Context, job string) (int, error) {
+	for {
+		out, err := k.run(ctx, nil, "get", "pods", "-l", "job-name="+job,
+			"-o", "jsonpath={.items[0].status.containerStatuses[0].state.terminated.exitCode}")
+		if err != nil {
+			return 0, err
+		}
+		if s := strings.TrimSpace(string(out)); s != "" {
+			return strconv.Atoi(s)
+		}
+
+		select {
+		case <-time.After(1 * time.Second):
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(kubernetesCmd)
+	kubernetesCmd.Flags().SortFlags = false
+	kubernetesCmd.Flags().AddFlagSet(optionFlagSet())
+	kubernetesCmd.Flags().AddFlagSet(runtimeOptionFlagSet(false))
+	kubernetesCmd.Flags().AddFlagSet(configFileFlagSet())
+	kubernetesCmd.Flags().IntVar(&kubeParallelism, "parallelism", kubeParallelism, "number of pods to split the test across")
+	kubernetesCmd.Flags().StringVar(&kubeName, "name", kubeName, "name of the Kubernetes resources (default k6-<timestamp>)")
+	kubernetesCmd.Flags().StringVar(&kubeNamespace, "namespace", kubeNamespace, "Kubernetes namespace to run the test in (default is kubectl's current one)")
+	kubernetesCmd.Flags().StringVar(&kubeImage, "image", kubeImage, "k6 container image to run")
+	kubernetesCmd.Flags().StringVar(&kubectlPath, "kubectl", kubectlPath, "path to the kubectl binary")
+	kubernetesCmd.Flags().BoolVar(&kubeKeep, "keep", kubeKeep, "don't delete the Kubernetes resources when the test is done")
+	kubernetesCmd.Flags().StringArrayVarP(&kubeOut, "out", "o", nil, "`uri` for an external metrics database, for all pods")
+}
@@ -0,0 +1,122 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestKubeShare(t *testing.T) {
+	var sum int64
+	for i := 0; i < 3; i++ {
+		sum += kubeShare(10, 3, i)
+	}
+	assert.Equal(t, int64(10), sum)
+	assert.Equal(t, int64(4), kubeShare(10, 3, 0))
+	assert.Equal(t, int64(3), kubeShare(10, 3, 1))
+	assert.Equal(t, int64(3), kubeShare(10, 3, 2))
+	assert.Equal(t, int64(0), kubeShare(0, 3, 0))
+}
+
+func TestKubePodArgs(t *testing.T) {
+	opts := lib.Options{
+		VUs:        null.IntFrom(5),
+		Iterations: null.IntFrom(100),
+		RPS:        null.IntFrom(10),
+		Stages: []lib.Stage{
+			{Duration: types.NullDurationFrom(10 * time.Second), Target: null.IntFrom(6)},
+			{Duration: types.NullDurationFrom(20 * time.Second)},
+		},
+		RunTags: stats.IntoSampleTags(&map[string]string{"env": "staging"}),
+		RunID:   null.StringFrom("8f2c1a"),
+	}
+
+	t.Run("Split", func(t *testing.T) {
+		args, err := kubePodArgs(opts, 2, 1, []string{"influxdb=http://influx:8086/k6"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"run",
+			"--execution-segment", "1/2",
+			"--vus", "2",
+			"--max", "3",
+			"--iterations", "50",
+			"--stage", "10s:3",
+			"--stage", "20s:",
+			"--rps", "5",
+			"--run-id", "8f2c1a",
+			"--tag", "env=staging",
+			"--tag", "instance=1",
+			"--out", "influxdb=http://influx:8086/k6",
+			"/k6/archive.tar",
+		}, args)
+	})
+
+	t.Run("Minimal", func(t *testing.T) {
+		args, err := kubePodArgs(lib.Options{VUs: null.IntFrom(1)}, 1, 0, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"run", "--execution-segment", "0/1", "--vus", "1", "--max", "1", "--tag", "instance=0", "/k6/archive.tar"}, args)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := kubePodArgs(opts, 0, 0, nil)
+		assert.EqualError(t, err, "parallelism must be at least 1")
+		_, err = kubePodArgs(opts, 7, 0, nil)
+		assert.EqualError(t, err, "can't split 6 VUs across 7 pods")
+		_, err = kubePodArgs(lib.Options{VUs: null.IntFrom(10), Iterations: null.IntFrom(2)}, 3, 0, nil)
+		assert.EqualError(t, err, "can't split 2 iterations across 3 pods")
+		_, err = kubePodArgs(lib.Options{VUs: null.IntFrom(10), RPS: null.IntFrom(2)}, 3, 0, nil)
+		assert.EqualError(t, err, "can't split an RPS limit of 2 across 3 pods")
+	})
+}
+
+func TestNewKubeJob(t *testing.T) {
+	data, err := json.Marshal(newKubeJob("k6-test-0", "k6-test", "loadimpact/k6", []string{"run", "/k6/archive.tar"}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"apiVersion": "batch/v1",
+		"kind": "Job",
+		"metadata": {"name": "k6-test-0", "labels": {"k6-run": "k6-test"}},
+		"spec": {
+			"backoffLimit": 0,
+			"template": {
+				"metadata": {"labels": {"k6-run": "k6-test"}},
+				"spec": {
+					"restartPolicy": "Never",
+					"containers": [{
+						"name": "k6",
+						"image": "loadimpact/k6",
+						"args": ["run", "/k6/archive.tar"],
+						"volumeMounts": [{"name": "archive", "mountPath": "/k6"}]
+					}],
+					"volumes": [{"name": "archive", "configMap": {"name": "k6-test"}}]
+				}
+			}
+		}
+	}`, string(data))
+}
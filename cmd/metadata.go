@@ -0,0 +1,76 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/loadimpact/k6/lib"
+	log "github.com/sirupsen/logrus"
+)
+
+// Returns the commit, branch and dirty state of the git repository that dir is in, or nil if
+// it isn't in one, or git isn't installed.
+func gitMetadata(dir string) map[string]string {
+	git := func(args ...string) (string, error) {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+		return strings.TrimSpace(string(out)), err
+	}
+
+	commit, err := git("rev-parse", "HEAD")
+	if err != nil {
+		log.WithError(err).WithField("dir", dir).Debug("Couldn't get the git metadata")
+		return nil
+	}
+	metadata := map[string]string{lib.MetadataGitCommit: commit}
+	// It's "HEAD" if no branch is checked out.
+	if branch, err := git("rev-parse", "--abbrev-ref", "HEAD"); err == nil && branch != "HEAD" {
+		metadata[lib.MetadataGitBranch] = branch
+	}
+	if status, err := git("status", "--porcelain"); err == nil {
+		metadata[lib.MetadataGitDirty] = strconv.FormatBool(status != "")
+	}
+	return metadata
+}
+
+// Returns the git metadata of a script that's read from filename, if it's a local file or stdin,
+// unless the noGitMetadata option is set.
+func scriptGitMetadata(opts lib.Options, filename, pwd string) map[string]string {
+	if opts.NoGitMetadata.Bool {
+		return nil
+	}
+	if filename == "-" {
+		return gitMetadata(pwd)
+	}
+	path := filename
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(pwd, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		// Eg. a remote script.
+		return nil
+	}
+	return gitMetadata(filepath.Dir(path))
+}
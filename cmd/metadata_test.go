@@ -0,0 +1,77 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestGitMetadata(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	dir, err := ioutil.TempDir("", "k6-git-metadata")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	assert.Nil(t, gitMetadata(dir))
+
+	git := func(args ...string) string {
+		c := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=k6", "-c", "user.email=k6@example.com"}, args...)...)
+		out, err := c.Output()
+		require.NoError(t, err, "git %v", args)
+		return string(out)
+	}
+	git("init", "-q")
+	git("checkout", "-q", "-b", "master")
+	script := filepath.Join(dir, "script.js")
+	require.NoError(t, ioutil.WriteFile(script, []byte("export default function() {}"), 0644))
+	git("add", "script.js")
+	git("commit", "-q", "-m", "Add a script")
+	commit := git("rev-parse", "HEAD")[:40]
+
+	assert.Equal(t, map[string]string{
+		lib.MetadataGitCommit: commit,
+		lib.MetadataGitBranch: "master",
+		lib.MetadataGitDirty:  "false",
+	}, scriptGitMetadata(lib.Options{}, "script.js", dir))
+
+	require.NoError(t, ioutil.WriteFile(script, []byte("export default function() { }"), 0644))
+	assert.Equal(t, "true", scriptGitMetadata(lib.Options{}, script, "/")[lib.MetadataGitDirty])
+	assert.Equal(t, commit, scriptGitMetadata(lib.Options{}, "-", dir)[lib.MetadataGitCommit])
+
+	git("checkout", "-q", "--detach")
+	metadata := gitMetadata(dir)
+	assert.Equal(t, commit, metadata[lib.MetadataGitCommit])
+	assert.NotContains(t, metadata, lib.MetadataGitBranch)
+
+	assert.Nil(t, scriptGitMetadata(lib.Options{NoGitMetadata: null.BoolFrom(true)}, script, "/"))
+	assert.Nil(t, scriptGitMetadata(lib.Options{}, "https://example.com/script.js", dir))
+}
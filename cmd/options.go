@@ -54,21 +54,53 @@ func optionFlagSet() *pflag.FlagSet {
 	flags.Int64("batch", 20, "max parallel batch reqs")
 	flags.Int64("batch-per-host", 20, "max parallel batch reqs per host")
 	flags.Int64("rps", 0, "limit requests per second")
+	flags.String("resource-limits", "", "shed iterations or stop the test when the load generator uses too much, as `key=value,...` (eg. 'maxMemory=2GB,maxConnections=5000')")
+	flags.String("vu-quotas", "", "fail iterations or stop the test when a VU uses too much, as `key=value,...` (eg. 'maxMemory=64MB,maxHandles=100,maxIterationCPU=500ms')")
+	flags.String("execution-segment", "", "run the `index/count` part of a distributed test, which data is partitioned by (eg. '0/4')")
+	flags.String("adaptive-rate", "", "start iterations at a rate adjusted to hold an SLO, as `key=value,...` (eg. 'target=300ms,percentile=95')")
 	flags.String("user-agent", fmt.Sprintf("k6/%s (https://k6.io/)", Version), "user agent for http requests")
 	flags.String("http-debug", "", "log all HTTP requests and responses. Excludes body by default. To include body use '--http-debug=full'")
 	flags.Lookup("http-debug").NoOptDefVal = "headers"
 	flags.Bool("insecure-skip-tls-verify", false, "skip verification of TLS certificates")
+	flags.Bool("require-stapling", false, "fail requests to servers that don't staple a valid OCSP response")
+	flags.String("tls-session-cache", "", "how TLS sessions are cached for resumption: 'none', 'vu' or 'shared'")
+	flags.String("connection-reuse", "", "how connections are reused: 'vu', 'iteration', 'none' or 'shared'")
 	flags.Bool("no-connection-reuse", false, "disable keep-alive connections")
 	flags.Bool("no-vu-connection-reuse", false, "don't reuse connections between iterations")
+	flags.String("cookie-jar", "", "which cookie jar VUs use: 'iteration', 'vu' or 'shared'")
+	flags.String("http-cache", "", "cache HTTP responses like a browser: 'none', 'iteration' or 'vu'")
+	flags.String("circuit-breaker", "", "stop requesting endpoints that fail too often, as `key=value,...` (eg. 'errorRate=0.5,window=30s')")
+	flags.String("http-retry", "", "retry failed HTTP requests, as `key=value,...` (eg. 'maxAttempts=3,statuses=5xx,backoff=200ms')")
 	flags.Duration("min-iteration-duration", 0, "minimum amount of time k6 will take executing a single iteration")
+	flags.Int64("warmup-iterations", 0, "run this many unmeasured iterations in every VU before the measured ones")
 	flags.BoolP("throw", "w", false, "throw warnings (like failed http requests) as errors")
 	flags.StringSlice("blacklist-ip", nil, "blacklist an `ip range` from being called")
 	flags.StringSlice("summary-trend-stats", nil, "define `stats` for trend metrics (response times), one or more as 'avg,p(95),...'")
 	flags.String("summary-time-unit", "", "define the time unit used to display the trend stats. Possible units are: 's', 'ms' and 'us'")
 	flags.StringSlice("system-tags", lib.DefaultSystemTagList, "only include these system tags in metrics")
+	flags.Bool("trace-context", false, "propagate W3C trace context headers in HTTP requests, for tracing outputs")
+	flags.String("url-grouping", "", "derive request names from URLs by collapsing IDs, as `key=value,...` (eg. 'collapse,maxNames=200')")
+	flags.String("output-aggregation", "", "drop or pre-aggregate samples before outputs, as `key=value,...` (eg. 'period=1s,metrics=http_req_*')")
 	flags.StringSlice("tag", nil, "add a `tag` to be applied to all samples, as `[name]=[value]`")
-	flags.String("console-output", "", "redirects the console logging to the provided output file")
+	flags.String("run-id", "", "identify the test run with this `id` in the run_id system tag, instead of a random one")
+	flags.StringSlice("metadata", nil, "add `metadata` about the test run, as `[name]=[value]`, to the tags of all samples and the summary")
+	flags.Bool("no-git-metadata", false, "don't add the git commit, branch and dirty state of the script to the metadata")
+	flags.String("console-output", "", "redirects the console logging to the provided output file, or pushes it to Loki with 'loki=<url>'")
+	flags.String("console-level", "", "the least severe level of console messages to log: 'debug', 'info', 'warn' or 'error'")
+	flags.Int64("console-rate-limit", 0, "log at most this many console messages per second, dropping the rest")
 	flags.Bool("discard-response-bodies", false, "Read but don't process or save HTTP response bodies")
+	flags.String("baseline", "", "load a previous run's values from this `file`, for thresholds to refer to as 'baseline'")
+	flags.String("baseline-output", "", "write this run's values to this `file` at the end, as a new baseline candidate")
+	flags.String("har-output", "", "record all HTTP requests and responses into a HAR `file`")
+	flags.StringSlice("har-sanitize", nil, "redact the values of these headers, cookies and query parameters in the HAR file")
+	flags.String("artifacts-dir", "", "let scripts read and write files in this `directory` with the k6/fs module")
+	flags.StringSlice("asset", nil, "bundle the files matching this glob `pattern` in archives, for the script to open()")
+	flags.StringSlice("allow-module", nil, "only let scripts import the built-in modules matching this `pattern`, eg. k6/x/*")
+	flags.StringSlice("block-module", nil, "don't let scripts import the built-in modules matching this `pattern`")
+	flags.StringSlice("allow-host", nil, "only let tests connect to the hosts matching this `pattern`, eg. *.example.com")
+	flags.StringSlice("block-host", nil, "don't let tests connect to the hosts matching this `pattern`")
+	flags.String("browser-executable", "", "the Chrome or Chromium `file` the k6/browser module runs, instead of the one in the PATH")
+	flags.Int64("browser-sessions", 1, "the most browser sessions open at once, across all VUs")
 	return flags
 }
 
@@ -85,11 +117,22 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		UserAgent:             getNullString(flags, "user-agent"),
 		HttpDebug:             getNullString(flags, "http-debug"),
 		InsecureSkipTLSVerify: getNullBool(flags, "insecure-skip-tls-verify"),
+		RequireStapling:       getNullBool(flags, "require-stapling"),
 		NoConnectionReuse:     getNullBool(flags, "no-connection-reuse"),
 		NoVUConnectionReuse:   getNullBool(flags, "no-vu-connection-reuse"),
 		MinIterationDuration:  getNullDuration(flags, "min-iteration-duration"),
+		WarmupIterations:      getNullInt64(flags, "warmup-iterations"),
 		Throw:                 getNullBool(flags, "throw"),
 		DiscardResponseBodies: getNullBool(flags, "discard-response-bodies"),
+		TraceContext:          getNullBool(flags, "trace-context"),
+		Baseline:              getNullString(flags, "baseline"),
+		BaselineOutput:        getNullString(flags, "baseline-output"),
+		HAROutput:             getNullString(flags, "har-output"),
+		ArtifactsDir:          getNullString(flags, "artifacts-dir"),
+		RunID:                 getNullString(flags, "run-id"),
+		NoGitMetadata:         getNullBool(flags, "no-git-metadata"),
+		BrowserExecutable:     getNullString(flags, "browser-executable"),
+		BrowserSessions:       getNullInt64(flags, "browser-sessions"),
 		// Default values for options without CLI flags:
 		// TODO: find a saner and more dev-friendly and error-proof way to handle options
 		SetupTimeout:    types.NullDuration{Duration: types.Duration(10 * time.Second), Valid: false},
@@ -117,6 +160,94 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		}
 	}
 
+	if flags.Lookup("adaptive-rate").Changed {
+		adaptiveRateString, err := flags.GetString("adaptive-rate")
+		if err != nil {
+			return opts, err
+		}
+		opts.AdaptiveRate = &lib.AdaptiveRate{}
+		if err := opts.AdaptiveRate.UnmarshalText([]byte(adaptiveRateString)); err != nil {
+			return opts, errors.Wrap(err, "adaptive-rate")
+		}
+	}
+
+	if flags.Lookup("resource-limits").Changed {
+		resourceLimitsString, err := flags.GetString("resource-limits")
+		if err != nil {
+			return opts, err
+		}
+		opts.ResourceLimits = &lib.ResourceLimits{}
+		if err := opts.ResourceLimits.UnmarshalText([]byte(resourceLimitsString)); err != nil {
+			return opts, errors.Wrap(err, "resource-limits")
+		}
+	}
+
+	if flags.Lookup("vu-quotas").Changed {
+		vuQuotasString, err := flags.GetString("vu-quotas")
+		if err != nil {
+			return opts, err
+		}
+		opts.VUQuotas = &lib.VUQuotas{}
+		if err := opts.VUQuotas.UnmarshalText([]byte(vuQuotasString)); err != nil {
+			return opts, errors.Wrap(err, "vu-quotas")
+		}
+	}
+
+	if flags.Lookup("execution-segment").Changed {
+		executionSegmentString, err := flags.GetString("execution-segment")
+		if err != nil {
+			return opts, err
+		}
+		opts.ExecutionSegment = &lib.ExecutionSegment{}
+		if err := opts.ExecutionSegment.UnmarshalText([]byte(executionSegmentString)); err != nil {
+			return opts, errors.Wrap(err, "execution-segment")
+		}
+	}
+
+	if flags.Lookup("url-grouping").Changed {
+		urlGroupingString, err := flags.GetString("url-grouping")
+		if err != nil {
+			return opts, err
+		}
+		opts.URLGrouping = &lib.URLGrouping{}
+		if err := opts.URLGrouping.UnmarshalText([]byte(urlGroupingString)); err != nil {
+			return opts, errors.Wrap(err, "url-grouping")
+		}
+	}
+
+	if flags.Lookup("http-retry").Changed {
+		httpRetryString, err := flags.GetString("http-retry")
+		if err != nil {
+			return opts, err
+		}
+		opts.HTTPRetry = &lib.HTTPRetry{}
+		if err := opts.HTTPRetry.UnmarshalText([]byte(httpRetryString)); err != nil {
+			return opts, errors.Wrap(err, "http-retry")
+		}
+	}
+
+	if flags.Lookup("circuit-breaker").Changed {
+		circuitBreakerString, err := flags.GetString("circuit-breaker")
+		if err != nil {
+			return opts, err
+		}
+		opts.CircuitBreaker = &lib.CircuitBreaker{}
+		if err := opts.CircuitBreaker.UnmarshalText([]byte(circuitBreakerString)); err != nil {
+			return opts, errors.Wrap(err, "circuit-breaker")
+		}
+	}
+
+	if flags.Lookup("output-aggregation").Changed {
+		outputAggregationString, err := flags.GetString("output-aggregation")
+		if err != nil {
+			return opts, err
+		}
+		opts.OutputAggregation = &lib.OutputAggregation{}
+		if err := opts.OutputAggregation.UnmarshalText([]byte(outputAggregationString)); err != nil {
+			return opts, errors.Wrap(err, "output-aggregation")
+		}
+	}
+
 	blacklistIPStrings, err := flags.GetStringSlice("blacklist-ip")
 	if err != nil {
 		return opts, err
@@ -141,6 +272,50 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		opts.SummaryTrendStats = append(opts.SummaryTrendStats, s)
 	}
 
+	tlsSessionCache, err := flags.GetString("tls-session-cache")
+	if err != nil {
+		return opts, err
+	}
+	if tlsSessionCache != "" {
+		if err := lib.ValidateTLSSessionCache(tlsSessionCache); err != nil {
+			return opts, err
+		}
+		opts.TLSSessionCache = null.StringFrom(tlsSessionCache)
+	}
+
+	connectionReuse, err := flags.GetString("connection-reuse")
+	if err != nil {
+		return opts, err
+	}
+	if connectionReuse != "" {
+		if err := lib.ValidateConnectionReuse(connectionReuse); err != nil {
+			return opts, err
+		}
+		opts.ConnectionReuse = null.StringFrom(connectionReuse)
+	}
+
+	cookieJar, err := flags.GetString("cookie-jar")
+	if err != nil {
+		return opts, err
+	}
+	if cookieJar != "" {
+		if err := lib.ValidateCookieJar(cookieJar); err != nil {
+			return opts, err
+		}
+		opts.CookieJar = null.StringFrom(cookieJar)
+	}
+
+	httpCache, err := flags.GetString("http-cache")
+	if err != nil {
+		return opts, err
+	}
+	if httpCache != "" {
+		if err := lib.ValidateHTTPCache(httpCache); err != nil {
+			return opts, err
+		}
+		opts.HTTPCache = null.StringFrom(httpCache)
+	}
+
 	summaryTimeUnit, err := flags.GetString("summary-time-unit")
 	if err != nil {
 		return opts, err
@@ -175,6 +350,54 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		opts.RunTags = stats.IntoSampleTags(&parsedRunTags)
 	}
 
+	metadata, err := flags.GetStringSlice("metadata")
+	if err != nil {
+		return opts, err
+	}
+	if len(metadata) > 0 {
+		opts.Metadata = make(map[string]string, len(metadata))
+		for i, s := range metadata {
+			name, value, err := parseTagNameValue(s)
+			if err != nil {
+				return opts, errors.Wrapf(err, "metadata %d", i)
+			}
+			opts.Metadata[name] = value
+		}
+	}
+
+	// Using Lookup() so that --har-sanitize="" can disable the default sanitization
+	if flags.Lookup("har-sanitize").Changed {
+		harSanitize, err := flags.GetStringSlice("har-sanitize")
+		if err != nil {
+			return opts, err
+		}
+		opts.HARSanitize = append([]string{}, harSanitize...)
+	}
+
+	if flags.Lookup("asset").Changed {
+		assets, err := flags.GetStringSlice("asset")
+		if err != nil {
+			return opts, err
+		}
+		opts.Assets = assets
+	}
+
+	for flag, dst := range map[string]*[]string{
+		"allow-module": &opts.AllowedModules,
+		"block-module": &opts.BlockedModules,
+		"allow-host":   &opts.AllowedHosts,
+		"block-host":   &opts.BlockedHosts,
+	} {
+		if !flags.Lookup(flag).Changed {
+			continue
+		}
+		patterns, err := flags.GetStringSlice(flag)
+		if err != nil {
+			return opts, err
+		}
+		*dst = patterns
+	}
+
 	redirectConFile, err := flags.GetString("console-output")
 	if err != nil {
 		return opts, err
@@ -184,6 +407,28 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		opts.ConsoleOutput = null.StringFrom(redirectConFile)
 	}
 
+	consoleLevel, err := flags.GetString("console-level")
+	if err != nil {
+		return opts, err
+	}
+	if consoleLevel != "" {
+		if err := lib.ValidateConsoleLevel(consoleLevel); err != nil {
+			return opts, err
+		}
+		opts.ConsoleLevel = null.StringFrom(consoleLevel)
+	}
+
+	consoleRateLimit, err := flags.GetInt64("console-rate-limit")
+	if err != nil {
+		return opts, err
+	}
+	if consoleRateLimit != 0 {
+		if consoleRateLimit < 0 {
+			return opts, errors.New("the console rate limit can't be negative")
+		}
+		opts.ConsoleRateLimit = null.IntFrom(consoleRateLimit)
+	}
+
 	return opts, nil
 }
 
@@ -29,6 +29,7 @@ import (
 	"sync"
 
 	"github.com/fatih/color"
+	"github.com/loadimpact/k6/lib"
 	"github.com/mattn/go-colorable"
 	"github.com/mattn/go-isatty"
 	"github.com/shibukawa/configdir"
@@ -131,6 +132,8 @@ func setupLoggers(logFmt string) {
 		log.SetLevel(log.DebugLevel)
 	}
 	log.SetOutput(stderr)
+	// Redact the secrets scripts register from everything that's logged.
+	log.AddHook(lib.Secrets)
 
 	switch logFmt {
 	case "raw":
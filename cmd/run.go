@@ -33,18 +33,23 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/loadimpact/k6/api"
+	"github.com/loadimpact/k6/converter/har"
 	"github.com/loadimpact/k6/core"
 	"github.com/loadimpact/k6/core/local"
 	"github.com/loadimpact/k6/js"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/loader"
+	"github.com/loadimpact/k6/stats"
 	"github.com/loadimpact/k6/ui"
+	"github.com/loadimpact/k6/ui/export"
+	"github.com/loadimpact/k6/ui/report"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
@@ -61,12 +66,18 @@ const (
 	teardownTimeoutErrorCode    = 101
 	genericTimeoutErrorCode     = 102
 	genericEngineErrorCode      = 103
+	regressionsFoundErrorCode   = 104
+	scriptAbortedErrorCode      = 105
 )
 
 var (
 	runType       = os.Getenv("K6_TYPE")
 	runNoSetup    = os.Getenv("K6_NO_SETUP") != ""
 	runNoTeardown = os.Getenv("K6_NO_TEARDOWN") != ""
+	runReport     = os.Getenv("K6_REPORT")
+	runExports    = strings.FieldsFunc(os.Getenv("K6_EXPORT"), func(r rune) bool { return r == ',' })
+
+	runCheckFailures = os.Getenv("K6_CHECK_FAILURES")
 )
 
 // runCmd represents the run command.
@@ -138,20 +149,7 @@ a commandline interface for interacting with it.`,
 			return err
 		}
 
-		// If -m/--max isn't specified, figure out the max that should be needed.
-		if !conf.VUsMax.Valid {
-			conf.VUsMax = null.NewInt(conf.VUs.Int64, conf.VUs.Valid)
-			for _, stage := range conf.Stages {
-				if stage.Target.Valid && stage.Target.Int64 > conf.VUsMax.Int64 {
-					conf.VUsMax = stage.Target
-				}
-			}
-		}
-
-		// If -d/--duration, -i/--iterations and -s/--stage are all unset, run to one iteration.
-		if !conf.Duration.Valid && !conf.Iterations.Valid && len(conf.Stages) == 0 {
-			conf.Iterations = null.IntFrom(1)
-		}
+		conf = applyRunDefaults(conf)
 
 		if conf.Iterations.Valid && conf.Iterations.Int64 < conf.VUsMax.Int64 {
 			log.Warnf(
@@ -160,16 +158,17 @@ a commandline interface for interacting with it.`,
 			)
 		}
 
-		// If duration is explicitly set to 0, it means run forever.
-		if conf.Duration.Valid && conf.Duration.Duration == 0 {
-			conf.Duration = types.NullDuration{}
-		}
-
 		// If summary trend stats are defined, update the UI to reflect them
 		if len(conf.SummaryTrendStats) > 0 {
 			ui.UpdateTrendColumns(conf.SummaryTrendStats)
 		}
 
+		// Tag all metrics with the run ID, if the run_id system tag is enabled.
+		conf.Options = conf.Options.WithRunID()
+
+		// Tag all metrics with the metadata, and the git state of the script.
+		conf.Options = conf.Options.WithMetadata(scriptGitMetadata(conf.Options, filename, pwd))
+
 		// Write options back to the runner too.
 		if err = r.SetOptions(conf.Options); err != nil {
 			return err
@@ -199,19 +198,38 @@ a commandline interface for interacting with it.`,
 		if conf.NoSummary.Valid {
 			engine.NoSummary = conf.NoSummary.Bool
 		}
+		if conf.Baseline.String != "" {
+			baseline, err := readBaseline(conf.Baseline.String)
+			switch {
+			case os.IsNotExist(errors.Cause(err)):
+				log.WithField("file", conf.Baseline.String).Warn("The baseline doesn't exist yet, thresholds referring to it will be skipped")
+			case err != nil:
+				return err
+			default:
+				engine.SetBaseline(baseline)
+			}
+		}
 
 		// Create a collector and assign it to the engine if requested.
 		fprintf(stdout, "%s   collector\r", initBar.String())
-		for _, out := range conf.Out {
-			t, arg := parseCollector(out)
-			collector, err := newCollector(t, arg, src, conf)
-			if err != nil {
-				return err
-			}
-			if err := collector.Init(); err != nil {
-				return err
-			}
-			engine.Collectors = append(engine.Collectors, collector)
+		if engine.Collectors, err = newCollectors(src, conf); err != nil {
+			return err
+		}
+
+		// The HTML report gathers its own data while the test runs, but it's not an output.
+		reportPath, err := parseReport(runReport)
+		if err != nil {
+			return err
+		}
+		var reportCollector *report.Collector
+		if reportPath != "" {
+			reportCollector = report.New()
+		}
+
+		// So are the threshold and check results exported for CI systems.
+		exports, err := parseExports(runExports)
+		if err != nil {
+			return err
 		}
 
 		// Create an API server.
@@ -267,6 +285,9 @@ a commandline interface for interacting with it.`,
 			fprintf(stdout, "         vus: %s,%s max: %s\n", vus, vusPad, max)
 			fprintf(stdout, "\n")
 		}
+		if reportCollector != nil {
+			engine.Collectors = append(engine.Collectors, reportCollector)
+		}
 
 		// Run the engine with a cancellable context.
 		fprintf(stdout, "%s starting\r", initBar.String())
@@ -279,6 +300,11 @@ a commandline interface for interacting with it.`,
 		signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 		defer signal.Stop(sigC)
 
+		// Reload the options that can be changed while the test is running on SIGHUPs.
+		reloadC := make(chan os.Signal, 1)
+		signal.Notify(reloadC, syscall.SIGHUP)
+		defer signal.Stop(reloadC)
+
 		// If the user hasn't opted out: report usage.
 		if !conf.NoUsageReport.Bool {
 			go func() {
@@ -350,6 +376,9 @@ a commandline interface for interacting with it.`,
 		if quiet || conf.HttpDebug.Valid && conf.HttpDebug.String != "" {
 			ticker.Stop()
 		}
+
+		// Set if the script aborted the test, which otherwise ends normally.
+		var abortErr *lib.AbortError
 	mainLoop:
 		for {
 			select {
@@ -371,20 +400,7 @@ a commandline interface for interacting with it.`,
 					break
 				}
 
-				var prog float64
-				if endIt := engine.Executor.GetEndIterations(); endIt.Valid {
-					prog = float64(engine.Executor.GetIterations()) / float64(endIt.Int64)
-				} else {
-					stagesEndT := lib.SumStages(engine.Executor.GetStages())
-					endT := engine.Executor.GetEndTime()
-					if !endT.Valid || (stagesEndT.Valid && endT.Duration > stagesEndT.Duration) {
-						endT = stagesEndT
-					}
-					if endT.Valid {
-						prog = float64(engine.Executor.GetTime()) / float64(endT.Duration)
-					}
-				}
-				progress.Progress = prog
+				progress.Progress = engine.Progress()
 				fprintf(stdout, "%s\x1b[0K\r", progress.String())
 			case err := <-errC:
 				cancel()
@@ -394,6 +410,11 @@ a commandline interface for interacting with it.`,
 				}
 
 				switch e := errors.Cause(err).(type) {
+				case lib.AbortError:
+					// Finish up as usual, so that the results so far are still reported.
+					log.Warn(e.String())
+					abortErr = &e
+					break mainLoop
 				case lib.TimeoutError:
 					switch string(e) {
 					case "setup":
@@ -413,6 +434,10 @@ a commandline interface for interacting with it.`,
 			case sig := <-sigC:
 				log.WithField("sig", sig).Debug("Exiting in response to signal")
 				cancel()
+			case <-reloadC:
+				if err := reloadConfig(fs, engine); err != nil {
+					log.WithError(err).Error("Couldn't reload the options")
+				}
 			}
 		}
 		if quiet || !stdoutTTY {
@@ -435,6 +460,16 @@ a commandline interface for interacting with it.`,
 			log.Warn("No data generated, because no script iterations finished, consider making the test duration longer")
 		}
 
+		// Push the remaining console messages, if they go to Loki.
+		if jsr, ok := r.(*js.Runner); ok {
+			jsr.CloseConsole()
+		}
+
+		// Write out the HTTP traffic recorded for the harOutput option.
+		if err := writeHAR(r, conf.Options); err != nil {
+			log.WithError(err).Error("Couldn't write the HAR file")
+		}
+
 		// Print the end-of-test summary.
 		if !quiet && !conf.NoSummary.Bool {
 			fprintf(stdout, "\n")
@@ -447,13 +482,70 @@ a commandline interface for interacting with it.`,
 			fprintf(stdout, "\n")
 		}
 
+		// Write this run's values as a candidate for a new baseline.
+		if conf.BaselineOutput.String != "" {
+			engine.MetricsLock.Lock()
+			baseline := stats.NewBaseline(engine.Metrics, engine.Executor.GetTime())
+			engine.MetricsLock.Unlock()
+			if err := writeBaseline(conf.BaselineOutput.String, baseline); err != nil {
+				log.WithError(err).Error("Couldn't write the baseline")
+			}
+		}
+
+		// Write the details of the failed checks.
+		if runCheckFailures != "" {
+			if err := writeCheckFailures(runCheckFailures, engine.Executor.GetRunner().GetDefaultGroup()); err != nil {
+				log.WithError(err).Error("Couldn't write the check failures")
+			}
+		}
+
+		// Write the end-of-test HTML report.
+		if reportCollector != nil {
+			err := writeReport(reportPath, reportCollector, report.Data{
+				Script:  filename,
+				Opts:    conf.Options,
+				Root:    engine.Executor.GetRunner().GetDefaultGroup(),
+				Metrics: engine.Metrics,
+				Time:    engine.Executor.GetTime(),
+			})
+			if err != nil {
+				log.WithError(err).Error("Couldn't write the HTML report")
+			}
+		}
+
+		// Export the threshold and check results.
+		for _, format := range sortedKeys(exports) {
+			err := writeExport(format, exports[format], export.Data{
+				Script:  filename,
+				Version: Version,
+				Opts:    conf.Options,
+				Root:    engine.Executor.GetRunner().GetDefaultGroup(),
+				Metrics: engine.Metrics,
+				Time:    engine.Executor.GetTime(),
+			})
+			if err != nil {
+				log.WithError(err).WithField("format", format).Error("Couldn't export the results")
+			}
+		}
+
 		if conf.Linger.Bool {
 			log.Info("Linger set; waiting for Ctrl+C...")
 			<-sigC
 		}
 
+		if abortErr != nil {
+			code := abortErr.ExitCode
+			if code == 0 {
+				code = scriptAbortedErrorCode
+			}
+			return ExitCode{*abortErr, code}
+		}
 		if engine.IsTainted() {
-			return ExitCode{errors.New("some thresholds have failed"), thresholdHaveFailedErroCode}
+			code := engine.ThresholdsExitCode()
+			if code == 0 {
+				code = thresholdHaveFailedErroCode
+			}
+			return ExitCode{errors.New("some thresholds have failed"), code}
 		}
 		return nil
 	},
@@ -469,6 +561,50 @@ func init() {
 	runCmd.Flags().StringVarP(&runType, "type", "t", runType, "override file `type`, \"js\" or \"archive\"")
 	runCmd.Flags().BoolVar(&runNoSetup, "no-setup", runNoSetup, "don't run setup()")
 	runCmd.Flags().BoolVar(&runNoTeardown, "no-teardown", runNoTeardown, "don't run teardown()")
+	runCmd.Flags().StringVar(&runReport, "report", runReport, "write an end-of-test `report`, \"html\" or \"html=<path>\"")
+	runCmd.Flags().StringSliceVar(&runExports, "export", runExports, "export the threshold and check results in a `format`, \"junit\" or \"sarif\", optionally as \"<format>=<path>\"")
+	runCmd.Flags().StringVar(&runCheckFailures, "check-failures", runCheckFailures, "write the details of failed checks to a JSON `file`")
+}
+
+// Fills in the options a test can't run without, from the ones that are set.
+func applyRunDefaults(conf Config) Config {
+	// If -m/--max isn't specified, figure out the max that should be needed.
+	if !conf.VUsMax.Valid {
+		conf.VUsMax = null.NewInt(conf.VUs.Int64, conf.VUs.Valid)
+		for _, stage := range conf.Stages {
+			if stage.Target.Valid && stage.Target.Int64 > conf.VUsMax.Int64 {
+				conf.VUsMax = stage.Target
+			}
+		}
+	}
+
+	// If -d/--duration, -i/--iterations and -s/--stage are all unset, run to one iteration.
+	if !conf.Duration.Valid && !conf.Iterations.Valid && len(conf.Stages) == 0 {
+		conf.Iterations = null.IntFrom(1)
+	}
+
+	// If duration is explicitly set to 0, it means run forever.
+	if conf.Duration.Valid && conf.Duration.Duration == 0 {
+		conf.Duration = types.NullDuration{}
+	}
+	return conf
+}
+
+// Creates and initializes the collectors for the outputs in conf.
+func newCollectors(src *lib.SourceData, conf Config) ([]lib.Collector, error) {
+	var collectors []lib.Collector
+	for _, out := range conf.Out {
+		t, arg := parseCollector(out)
+		collector, err := newCollector(t, arg, src, conf)
+		if err != nil {
+			return nil, err
+		}
+		if err := collector.Init(); err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, collector)
+	}
+	return collectors, nil
 }
 
 // Reads a source file from any supported destination.
@@ -487,6 +623,208 @@ func readSource(src, pwd string, fs afero.Fs, stdin io.Reader) (*lib.SourceData,
 	return loader.Load(fs, pwd, src)
 }
 
+// Re-reads the config file and reloads the options in it that can be changed
+// while the test is running: stages, duration, rps and thresholds.
+func reloadConfig(fs afero.Fs, engine *core.Engine) error {
+	conf, _, err := readDiskConfig(fs)
+	if err != nil {
+		return err
+	}
+	log.WithField("config", configFile).Info("Reloading options")
+	return engine.ReloadOptions(conf.Options)
+}
+
+// Writes the HTTP requests and responses recorded by the runner into the
+// harOutput file, if it's set.
+func writeHAR(r lib.Runner, opts lib.Options) error {
+	jsr, ok := r.(*js.Runner)
+	if !ok || jsr.HTTPRecorder() == nil {
+		return nil
+	}
+
+	sanitize := opts.HARSanitize
+	if sanitize == nil {
+		sanitize = lib.DefaultHARSanitize
+	}
+	data := har.FromExchanges(jsr.HTTPRecorder().Exchanges(), sanitize, &har.Creator{Name: "k6", Version: Version})
+
+	f, err := os.Create(opts.HAROutput.String)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Reads a baseline written by writeBaseline.
+func readBaseline(path string) (stats.Baseline, error) {
+	var baseline stats.Baseline
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return baseline, err
+	}
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return baseline, errors.Wrapf(err, "couldn't parse the baseline %s", path)
+	}
+	return baseline, nil
+}
+
+// Writes a baseline for the baselineOutput option.
+func writeBaseline(path string, baseline stats.Baseline) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// A failedCheck is an entry of the --check-failures file.
+type failedCheck struct {
+	Path           string             `json:"path"`
+	Severity       string             `json:"severity"`
+	Passes         int64              `json:"passes"`
+	Fails          int64              `json:"fails"`
+	FailureSamples []lib.CheckFailure `json:"failureSamples"`
+}
+
+// Writes the checks that have failed, with the details of their first failures, as JSON.
+func writeCheckFailures(path string, root *lib.Group) error {
+	checks := failedChecks(root, []failedCheck{})
+	data, err := json.MarshalIndent(checks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func failedChecks(group *lib.Group, checks []failedCheck) []failedCheck {
+	checkNames := make([]string, 0, len(group.Checks))
+	for name := range group.Checks {
+		checkNames = append(checkNames, name)
+	}
+	sort.Strings(checkNames)
+	for _, name := range checkNames {
+		check := group.Checks[name]
+		if check.Fails == 0 {
+			continue
+		}
+		checks = append(checks, failedCheck{
+			Path:           check.Path,
+			Severity:       check.GetSeverity(),
+			Passes:         check.Passes,
+			Fails:          check.Fails,
+			FailureSamples: check.GetFailureSamples(),
+		})
+	}
+
+	groupNames := make([]string, 0, len(group.Groups))
+	for name := range group.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+	for _, name := range groupNames {
+		checks = failedChecks(group.Groups[name], checks)
+	}
+	return checks
+}
+
+// Parses the --report flag value into the path of the HTML report, or "" if none was requested.
+func parseReport(arg string) (string, error) {
+	if arg == "" {
+		return "", nil
+	}
+	typ, path := arg, ""
+	if idx := strings.IndexRune(arg, '='); idx != -1 {
+		typ, path = arg[:idx], arg[idx+1:]
+	}
+	if typ != "html" {
+		return "", errors.Errorf("unknown report type: %s", typ)
+	}
+	if path == "" {
+		path = "report.html"
+	}
+	return path, nil
+}
+
+// Writes the end-of-test HTML report for the --report flag.
+func writeReport(path string, c *report.Collector, data report.Data) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.Render(f, data, c); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// The formats results can be exported in with the --export flag, and the files they're written
+// to by default.
+var exportFormats = map[string]string{
+	"junit": "junit.xml",
+	"sarif": "results.sarif",
+}
+
+// Parses the values of the --export flag, "<format>" or "<format>=<path>", into the paths to
+// write each format to.
+func parseExports(args []string) (map[string]string, error) {
+	exports := make(map[string]string, len(args))
+	for _, arg := range args {
+		format, path := arg, ""
+		if idx := strings.IndexRune(arg, '='); idx != -1 {
+			format, path = arg[:idx], arg[idx+1:]
+		}
+		defaultPath, ok := exportFormats[format]
+		if !ok {
+			return nil, errors.Errorf("unknown export format: %s", format)
+		}
+		if _, ok := exports[format]; ok {
+			return nil, errors.Errorf("the export format %s is given more than once", format)
+		}
+		if path == "" {
+			path = defaultPath
+		}
+		exports[format] = path
+	}
+	return exports, nil
+}
+
+// Writes the threshold and check results in an export format, for the --export flag.
+func writeExport(format, path string, data export.Data) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "junit":
+		err = export.JUnit(f, data)
+	case "sarif":
+		err = export.SARIF(f, data)
+	default:
+		err = errors.Errorf("unknown export format: %s", format)
+	}
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // Creates a new runner.
 func newRunner(src *lib.SourceData, typ string, fs afero.Fs, rtOpts lib.RuntimeOptions) (lib.Runner, error) {
 	switch typ {
@@ -0,0 +1,170 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReport(t *testing.T) {
+	testdata := map[string]struct {
+		path string
+		err  bool
+	}{
+		"":                 {"", false},
+		"html":             {"report.html", false},
+		"html=":            {"report.html", false},
+		"html=out/k6.html": {"out/k6.html", false},
+		"pdf":              {"", true},
+		"pdf=report.pdf":   {"", true},
+	}
+	for arg, data := range testdata {
+		t.Run(arg, func(t *testing.T) {
+			path, err := parseReport(arg)
+			if data.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, data.path, path)
+		})
+	}
+}
+
+func TestParseExports(t *testing.T) {
+	testdata := map[string]struct {
+		args    []string
+		exports map[string]string
+		err     string
+	}{
+		"none":     {nil, map[string]string{}, ""},
+		"defaults": {[]string{"junit", "sarif="}, map[string]string{"junit": "junit.xml", "sarif": "results.sarif"}, ""},
+		"paths":    {[]string{"junit=out/k6.xml"}, map[string]string{"junit": "out/k6.xml"}, ""},
+		"unknown":  {[]string{"html"}, nil, "unknown export format: html"},
+		"twice":    {[]string{"junit", "junit=k6.xml"}, nil, "the export format junit is given more than once"},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			exports, err := parseExports(data.args)
+			if data.err != "" {
+				assert.EqualError(t, err, data.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, data.exports, exports)
+		})
+	}
+}
+
+func TestBaselineFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k6-baseline")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "baseline.json")
+
+	_, err = readBaseline(path)
+	assert.True(t, os.IsNotExist(errors.Cause(err)))
+
+	trend := stats.New("trend", stats.Trend)
+	trend.Sink.Add(stats.Sample{Value: 100})
+	baseline := stats.NewBaseline(map[string]*stats.Metric{"trend": trend}, 0)
+	require.NoError(t, writeBaseline(path, baseline))
+
+	read, err := readBaseline(path)
+	require.NoError(t, err)
+	assert.True(t, baseline.Created.Equal(read.Created))
+	assert.Equal(t, baseline.Metrics, read.Metrics)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0644))
+	_, err = readBaseline(path)
+	assert.Error(t, err)
+}
+
+func TestCheckFailuresFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k6-check-failures")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "failures.json")
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	passing, err := root.Check("passing")
+	require.NoError(t, err)
+	passing.Passes = 2
+	group, err := root.Group("group")
+	require.NoError(t, err)
+	failing, err := group.Check("failing")
+	require.NoError(t, err)
+	failing.Passes, failing.Fails = 1, 1
+	failing.SetSeverity(lib.CheckSeverityWarn)
+	failing.AddFailure(lib.CheckFailure{VU: 3, Iteration: 4, Expected: "ok", Actual: "error", Message: "bad body"})
+
+	require.NoError(t, writeCheckFailures(path, root))
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	var checks []failedCheck
+	require.NoError(t, json.Unmarshal(data, &checks))
+	assert.Equal(t, []failedCheck{{
+		Path:     "::group::failing",
+		Severity: "warn",
+		Passes:   1,
+		Fails:    1,
+		FailureSamples: []lib.CheckFailure{{
+			Time: time.Time{}, VU: 3, Iteration: 4, Expected: "ok", Actual: "error", Message: "bad body",
+		}},
+	}}, checks)
+}
+
+func TestReloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k6-reload")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	oldConfigFile := configFile
+	defer func() { configFile = oldConfigFile }()
+	configFile = filepath.Join(dir, "config.json")
+
+	engine, err := core.NewEngine(nil, lib.Options{Duration: types.NullDurationFrom(time.Minute)})
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(`{"duration": "1h", "vus": 100}`), 0644))
+	require.NoError(t, reloadConfig(afero.NewMemMapFs(), engine))
+	assert.Equal(t, types.NullDurationFrom(time.Hour), engine.Executor.GetEndTime())
+	assert.Equal(t, int64(0), engine.Executor.GetVUs(), "only some options can be reloaded")
+
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(`{`), 0644))
+	assert.Error(t, reloadConfig(afero.NewMemMapFs(), engine))
+}
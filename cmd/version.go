@@ -23,6 +23,8 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/loadimpact/k6/js/modules"
+	"github.com/loadimpact/k6/lib"
 	"github.com/spf13/cobra"
 )
 
@@ -30,9 +32,15 @@ import (
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show application version",
-	Long:  `Show the application version and exit.`,
+	Long:  `Show the application version, and the extensions built into it, and exit.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("k6 v" + Version)
+		for _, name := range modules.Extensions() {
+			fmt.Printf("  %s (JS module)\n", name)
+		}
+		for _, name := range lib.OutputExtensions() {
+			fmt.Printf("  %s (output)\n", name)
+		}
 	},
 }
 
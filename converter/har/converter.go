@@ -58,7 +58,7 @@ func fprintf(w io.Writer, format string, a ...interface{}) int {
 }
 
 // TODO: refactor this to have fewer parameters... or just refactor in general...
-func Convert(h HAR, options lib.Options, minSleep, maxSleep uint, enableChecks bool, returnOnFailedCheck bool, batchTime uint, nobatch bool, correlate bool, only, skip []string) (result string, convertErr error) {
+func Convert(h HAR, options lib.Options, minSleep, maxSleep uint, enableChecks bool, returnOnFailedCheck bool, batchTime uint, nobatch bool, correlate bool, replayTiming bool, only, skip []string) (result string, convertErr error) {
 	var b bytes.Buffer
 	w := bufio.NewWriter(&b)
 
@@ -90,6 +90,11 @@ func Convert(h HAR, options lib.Options, minSleep, maxSleep uint, enableChecks b
 		fprintf(w, "// %v\n", h.Log.Comment)
 	}
 
+	if replayTiming {
+		// Explicitly supplied options take precedence over the ones derived from the recording
+		options = ReplayOptions(h.Log.Entries).Apply(options)
+	}
+
 	fprint(w, "\nexport let options = {\n")
 	options.ForEachValid("json", func(key string, val interface{}) {
 		if valJSON, err := json.MarshalIndent(val, "    ", "    "); err != nil {
@@ -170,6 +175,12 @@ func Convert(h HAR, options lib.Options, minSleep, maxSleep uint, enableChecks b
 				var cookies []string
 				var body string
 
+				if replayTiming && entryIndex > 0 {
+					if t := ThinkTime(entries[entryIndex-1:entryIndex], e.StartedDateTime); t >= 0.01 {
+						fprintf(w, "\t\tsleep(%.2f);\n", t)
+					}
+				}
+
 				fprintf(w, "\t\t// Request #%d\n", entryIndex)
 
 				if e.Request.PostData != nil {
@@ -301,10 +312,15 @@ func Convert(h HAR, options lib.Options, minSleep, maxSleep uint, enableChecks b
 					lastBatchEntry := batchEntries[len(batchEntries)-1]
 					firstBatchEntry := batches[j+1][0]
 					t := firstBatchEntry.StartedDateTime.Sub(lastBatchEntry.StartedDateTime).Seconds()
+					if replayTiming {
+						t = ThinkTime(batchEntries, firstBatchEntry.StartedDateTime)
+					}
 					fprintf(w, "\t\tsleep(%.2f);\n", t)
 				}
 			}
+		}
 
+		if !nobatch || replayTiming {
 			if i == len(pages)-1 {
 				// Last page; add random sleep time at the group completion
 				fprintf(w, "\t\t// Random sleep between %ds and %ds\n", minSleep, maxSleep)
@@ -316,6 +332,9 @@ func Convert(h HAR, options lib.Options, minSleep, maxSleep uint, enableChecks b
 				if len(entries) > 0 {
 					lastEntry := entries[len(entries)-1]
 					t := nextPage.StartedDateTime.Sub(lastEntry.StartedDateTime).Seconds()
+					if replayTiming {
+						t = ThinkTime(entries, nextPage.StartedDateTime)
+					}
 					if t >= 0.01 {
 						sleepTime = t
 					}
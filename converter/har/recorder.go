@@ -0,0 +1,212 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/loadimpact/k6/lib/netext"
+)
+
+// Redacted replaces the values of sanitized headers, cookies and query parameters
+const Redacted = "[REDACTED]"
+
+// FromExchanges builds a HAR out of the HTTP exchanges recorded during a test
+// run, with the values of the headers, cookies and query parameters named in
+// sanitize (case-insensitively) redacted. Sanitizing the Cookie or Set-Cookie
+// headers also redacts the values of all request or response cookies.
+func FromExchanges(exchanges []*netext.HTTPExchange, sanitize []string, creator *Creator) *HAR {
+	s := sanitizer{}
+	for _, name := range sanitize {
+		s[strings.ToLower(name)] = true
+	}
+
+	entries := make([]*Entry, 0, len(exchanges))
+	for i, exchange := range exchanges {
+		entries = append(entries, s.entry(i, exchange))
+	}
+	sort.Stable(EntryByStarted(entries))
+
+	return &HAR{Log: &Log{
+		Version: "1.2",
+		Creator: creator,
+		Entries: entries,
+	}}
+}
+
+type sanitizer map[string]bool
+
+func (s sanitizer) entry(i int, exchange *netext.HTTPExchange) *Entry {
+	trail := exchange.Trail
+	timings := &Timings{
+		Blocked: durationMs(trail.Blocked),
+		Connect: durationMs(trail.Connecting),
+		SSL:     durationMs(trail.TLSHandshaking),
+		Send:    durationMs(trail.Sending),
+		Wait:    durationMs(trail.Waiting),
+		Receive: durationMs(trail.Receiving),
+	}
+	entry := &Entry{
+		ID:              strconv.Itoa(i),
+		StartedDateTime: trail.StartTime,
+		Time:            timings.Blocked + timings.Connect + timings.SSL + timings.Send + timings.Wait + timings.Receive,
+		Request:         s.request(exchange),
+		Response:        s.response(exchange),
+		Cache:           &Cache{},
+		Timings:         timings,
+		Comment:         exchange.Error,
+	}
+	if trail.ConnRemoteAddr != nil {
+		if ip, _, err := net.SplitHostPort(trail.ConnRemoteAddr.String()); err == nil {
+			entry.ServerIPAddress = ip
+		}
+	}
+	return entry
+}
+
+func (s sanitizer) request(exchange *netext.HTTPExchange) *Request {
+	req := &Request{
+		Method:      exchange.Method,
+		URL:         exchange.URL,
+		HTTPVersion: exchange.Proto,
+		Cookies:     []Cookie{},
+		Headers:     s.headers(exchange.RequestHeaders),
+		QueryString: []QueryString{},
+		HeadersSize: -1,
+		BodySize:    int64(len(exchange.RequestBody)),
+	}
+
+	for _, c := range (&http.Request{Header: exchange.RequestHeaders}).Cookies() {
+		req.Cookies = append(req.Cookies, s.cookie(c, "cookie"))
+	}
+
+	if u, err := url.Parse(exchange.URL); err == nil {
+		query := u.Query()
+		redacted := false
+		for _, name := range sortedKeys(query) {
+			values := query[name]
+			for i := range values {
+				if s[strings.ToLower(name)] {
+					values[i] = Redacted
+					redacted = true
+				}
+				req.QueryString = append(req.QueryString, QueryString{Name: name, Value: values[i]})
+			}
+		}
+		if redacted {
+			u.RawQuery = query.Encode()
+			req.URL = u.String()
+		}
+	}
+
+	if exchange.RequestBody != "" {
+		req.PostData = &PostData{
+			MimeType: exchange.RequestHeaders.Get("Content-Type"),
+			Params:   []Param{},
+			Text:     exchange.RequestBody,
+		}
+	}
+	return req
+}
+
+func (s sanitizer) response(exchange *netext.HTTPExchange) *Response {
+	res := &Response{
+		Status:      exchange.Status,
+		StatusText:  http.StatusText(exchange.Status),
+		HTTPVersion: exchange.Proto,
+		Cookies:     []Cookie{},
+		Headers:     s.headers(exchange.ResponseHeaders),
+		Content: &Content{
+			Size:     exchange.ResponseBodySize,
+			MimeType: exchange.ResponseHeaders.Get("Content-Type"),
+		},
+		RedirectURL: exchange.ResponseHeaders.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    exchange.ResponseBodySize,
+	}
+
+	for _, c := range (&http.Response{Header: exchange.ResponseHeaders}).Cookies() {
+		res.Cookies = append(res.Cookies, s.cookie(c, "set-cookie"))
+	}
+
+	if body := exchange.ResponseBody; body != nil {
+		if utf8.Valid(body) {
+			res.Content.Text = string(body)
+		} else {
+			res.Content.Text = base64.StdEncoding.EncodeToString(body)
+			res.Content.Encoding = "base64"
+		}
+	}
+	return res
+}
+
+func (s sanitizer) headers(h http.Header) []Header {
+	headers := []Header{}
+	for _, name := range sortedKeys(h) {
+		for _, value := range h[name] {
+			if s[strings.ToLower(name)] {
+				value = Redacted
+			}
+			headers = append(headers, Header{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func (s sanitizer) cookie(c *http.Cookie, header string) Cookie {
+	cookie := Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		HTTPOnly: c.HttpOnly,
+		Secure:   c.Secure,
+	}
+	if !c.Expires.IsZero() {
+		cookie.Expires = c.Expires
+		cookie.Expires8601 = c.Expires.Format(time.RFC3339)
+	}
+	if s[header] || s[strings.ToLower(c.Name)] {
+		cookie.Value = Redacted
+	}
+	return cookie
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func durationMs(d time.Duration) float32 {
+	return float32(d) / float32(time.Millisecond)
+}
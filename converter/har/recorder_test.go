@@ -0,0 +1,123 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromExchanges(t *testing.T) {
+	start := time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)
+	exchanges := []*netext.HTTPExchange{
+		{
+			Method: "POST",
+			URL:    "https://example.com/login?token=secret&page=1",
+			RequestHeaders: http.Header{
+				"Authorization": {"Bearer secret"},
+				"Content-Type":  {"application/x-www-form-urlencoded"},
+				"Cookie":        {"session=abc; theme=dark"},
+			},
+			RequestBody: "user=admin",
+			Status:      200,
+			Proto:       "HTTP/1.1",
+			ResponseHeaders: http.Header{
+				"Content-Type": {"text/plain"},
+				"Set-Cookie":   {"session=def; Path=/; HttpOnly"},
+			},
+			ResponseBody:     []byte("welcome"),
+			ResponseBodySize: 7,
+			Trail: &netext.Trail{
+				StartTime:      start.Add(time.Second),
+				Blocked:        1 * time.Millisecond,
+				Connecting:     2 * time.Millisecond,
+				TLSHandshaking: 3 * time.Millisecond,
+				Sending:        4 * time.Millisecond,
+				Waiting:        5 * time.Millisecond,
+				Receiving:      6 * time.Millisecond,
+				ConnRemoteAddr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 443},
+			},
+		},
+		{
+			Method:           "GET",
+			URL:              "https://example.com/image.png",
+			Status:           200,
+			Proto:            "HTTP/2.0",
+			ResponseHeaders:  http.Header{"Content-Type": {"image/png"}},
+			ResponseBody:     []byte{0x89, 0x50, 0x4e, 0x47, 0xff},
+			ResponseBodySize: 5,
+			Trail:            &netext.Trail{StartTime: start},
+		},
+		{
+			Method: "GET",
+			URL:    "https://example.com/down",
+			Error:  "dial tcp: connection refused",
+			Trail:  &netext.Trail{StartTime: start.Add(2 * time.Second)},
+		},
+	}
+
+	h := FromExchanges(exchanges, []string{"authorization", "Cookie", "Set-Cookie", "token"}, &Creator{Name: "k6", Version: "test"})
+	require.NotNil(t, h.Log)
+	assert.Equal(t, "1.2", h.Log.Version)
+	assert.Equal(t, &Creator{Name: "k6", Version: "test"}, h.Log.Creator)
+	require.Len(t, h.Log.Entries, 3)
+
+	// Sorted by their start time
+	image, login, down := h.Log.Entries[0], h.Log.Entries[1], h.Log.Entries[2]
+
+	t.Run("Sanitized", func(t *testing.T) {
+		assert.Equal(t, "https://example.com/login?page=1&token=%5BREDACTED%5D", login.Request.URL)
+		assert.Equal(t, []QueryString{{"page", "1"}, {"token", Redacted}}, login.Request.QueryString)
+		assert.Equal(t, []Header{
+			{"Authorization", Redacted},
+			{"Content-Type", "application/x-www-form-urlencoded"},
+			{"Cookie", Redacted},
+		}, login.Request.Headers)
+		assert.Equal(t, []Cookie{{Name: "session", Value: Redacted}, {Name: "theme", Value: Redacted}}, login.Request.Cookies)
+		assert.Equal(t, []Header{{"Content-Type", "text/plain"}, {"Set-Cookie", Redacted}}, login.Response.Headers)
+		assert.Equal(t, []Cookie{{Name: "session", Value: Redacted, Path: "/", HTTPOnly: true}}, login.Response.Cookies)
+	})
+
+	t.Run("Content", func(t *testing.T) {
+		assert.Equal(t, &PostData{MimeType: "application/x-www-form-urlencoded", Params: []Param{}, Text: "user=admin"}, login.Request.PostData)
+		assert.Equal(t, &Content{Size: 7, MimeType: "text/plain", Text: "welcome"}, login.Response.Content)
+		assert.Equal(t, &Content{Size: 5, MimeType: "image/png", Text: "iVBOR/8=", Encoding: "base64"}, image.Response.Content)
+	})
+
+	t.Run("Timings", func(t *testing.T) {
+		assert.Equal(t, &Timings{Blocked: 1, Connect: 2, SSL: 3, Send: 4, Wait: 5, Receive: 6}, login.Timings)
+		assert.Equal(t, float32(21), login.Time)
+		assert.Equal(t, "192.0.2.1", login.ServerIPAddress)
+		assert.Equal(t, start.Add(time.Second), login.StartedDateTime)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		assert.Equal(t, "dial tcp: connection refused", down.Comment)
+		assert.Equal(t, 0, down.Response.Status)
+		assert.Equal(t, "", down.Response.Content.Text)
+	})
+}
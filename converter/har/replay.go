@@ -0,0 +1,100 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// entryEnd returns the time at which the response to an entry was fully received.
+func entryEnd(e *Entry) time.Time {
+	return e.StartedDateTime.Add(time.Duration(float64(e.Time) * float64(time.Millisecond)))
+}
+
+// ThinkTime returns the idle time in seconds between the last of the given entries to finish
+// and the start of the next one, i.e. the time the recorded user spent before the next request.
+func ThinkTime(previous []*Entry, next time.Time) float64 {
+	var last time.Time
+	for _, e := range previous {
+		if end := entryEnd(e); end.After(last) {
+			last = end
+		}
+	}
+	if last.IsZero() || !next.After(last) {
+		return 0
+	}
+	return next.Sub(last).Seconds()
+}
+
+// ReplayOptions derives the connection handling options that reproduce the connection reuse
+// pattern of a recording: noConnectionReuse if no connection carried more than one request,
+// and otherwise batchPerHost set to the highest number of connections opened to a single host.
+// Entries without a connection ID are ignored.
+func ReplayOptions(entries []*Entry) lib.Options {
+	var opts lib.Options
+
+	requests := make(map[string]int)
+	hostConns := make(map[string]map[string]bool)
+	for _, e := range entries {
+		if e.Connection == "" || e.Request == nil {
+			continue
+		}
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			continue
+		}
+		// Some tools only keep connection IDs unique per server, so key them by host as well
+		conn := u.Host + "|" + e.Connection
+		requests[conn]++
+		if hostConns[u.Host] == nil {
+			hostConns[u.Host] = make(map[string]bool)
+		}
+		hostConns[u.Host][conn] = true
+	}
+	if len(requests) == 0 {
+		return opts
+	}
+
+	reused := false
+	for _, n := range requests {
+		if n > 1 {
+			reused = true
+			break
+		}
+	}
+	if !reused {
+		opts.NoConnectionReuse = null.BoolFrom(true)
+		return opts
+	}
+
+	maxConns := 0
+	for _, conns := range hostConns {
+		if len(conns) > maxConns {
+			maxConns = len(conns)
+		}
+	}
+	opts.BatchPerHost = null.IntFrom(int64(maxConns))
+	return opts
+}
@@ -0,0 +1,101 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestThinkTime(t *testing.T) {
+	t0 := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []*Entry{
+		{StartedDateTime: t0, Time: 500},
+		{StartedDateTime: t0.Add(100 * time.Millisecond), Time: 1500},
+	}
+
+	assert.Equal(t, 0.0, ThinkTime(nil, t0))
+	assert.Equal(t, 1.5, ThinkTime(entries[:1], t0.Add(2*time.Second)))
+	// The think time starts when the slowest response has been received
+	assert.Equal(t, 0.4, ThinkTime(entries, t0.Add(2*time.Second)))
+	// Requests started before the previous one completed don't have any think time
+	assert.Equal(t, 0.0, ThinkTime(entries, t0.Add(time.Second)))
+}
+
+func TestReplayOptions(t *testing.T) {
+	entry := func(u, conn string) *Entry {
+		return &Entry{Request: &Request{URL: u}, Connection: conn}
+	}
+
+	assert.Equal(t, lib.Options{}, ReplayOptions([]*Entry{
+		entry("https://example.com/", ""),
+		entry("https://example.com/", ""),
+	}))
+	assert.Equal(t, lib.Options{NoConnectionReuse: null.BoolFrom(true)}, ReplayOptions([]*Entry{
+		entry("https://example.com/", "1"),
+		entry("https://example.com/a", "2"),
+		entry("https://cdn.example.com/", "1"),
+	}))
+	assert.Equal(t, lib.Options{BatchPerHost: null.IntFrom(3)}, ReplayOptions([]*Entry{
+		entry("https://example.com/", "1"),
+		entry("https://example.com/a", "1"),
+		entry("https://cdn.example.com/", "2"),
+		entry("https://cdn.example.com/a", "3"),
+		entry("https://cdn.example.com/b", "4"),
+		entry("https://cdn.example.com/c", "4"),
+	}))
+}
+
+func TestConvertReplayTiming(t *testing.T) {
+	t0 := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := HAR{Log: &Log{
+		Creator: &Creator{Name: "test"},
+		Pages: []Page{
+			{ID: "page_1", Title: "Home", StartedDateTime: t0},
+			{ID: "page_2", Title: "Login", StartedDateTime: t0.Add(10 * time.Second)},
+		},
+		Entries: []*Entry{
+			{Pageref: "page_1", StartedDateTime: t0, Time: 250, Connection: "1",
+				Request: &Request{Method: "GET", URL: "https://example.com/"}},
+			{Pageref: "page_1", StartedDateTime: t0.Add(3 * time.Second), Time: 500, Connection: "1",
+				Request: &Request{Method: "GET", URL: "https://example.com/about"}},
+			{Pageref: "page_2", StartedDateTime: t0.Add(10 * time.Second), Time: 100, Connection: "1",
+				Request: &Request{Method: "GET", URL: "https://example.com/login"}},
+		},
+	}}
+
+	script, err := Convert(h, lib.Options{}, 20, 40, false, false, 500, true, false, true, nil, nil)
+	require.NoError(t, err)
+	assert.Contains(t, script, "    batchPerHost: 1,\n")
+	assert.Contains(t, script, "\t\tsleep(2.75);\n\t\t// Request #1\n")
+	assert.Contains(t, script, "\t\tsleep(6.50);\n\t});\n")
+	assert.Contains(t, script, "\t\tsleep(Math.floor(Math.random()*20+20));\n")
+
+	script, err = Convert(h, lib.Options{}, 20, 40, false, false, 500, true, false, false, nil, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, script, "sleep(")
+	assert.NotContains(t, script, "batchPerHost")
+}
@@ -102,6 +102,12 @@ type Entry struct {
 	// Timings describes various phases within request-response round trip. All
 	// times are specified in milliseconds.
 	Timings *Timings `json:"timings"`
+	// ServerIPAddress is the IP address of the server that was connected to.
+	ServerIPAddress string `json:"serverIPAddress,omitempty"`
+	// Connection is the unique ID of the parent TCP/IP connection.
+	Connection string `json:"connection,omitempty"`
+	// Comment provided by the user or the application.
+	Comment string `json:"comment,omitempty"`
 }
 
 // Request holds data about an individual HTTP request.
@@ -165,6 +171,12 @@ type Cache struct {
 // Timings describes various phases within request-response round trip. All
 // times are specified in milliseconds
 type Timings struct {
+	// Blocked is the time spent in a queue waiting for a network connection.
+	Blocked float32 `json:"blocked,omitempty"`
+	// Connect is the time required to create the TCP connection.
+	Connect float32 `json:"connect,omitempty"`
+	// SSL is the time required for the TLS handshake.
+	SSL float32 `json:"ssl,omitempty"`
 	// Send is the time required to send HTTP request to the server.
 	Send float32 `json:"send"`
 	// Wait is the time spent waiting for a response from the server.
@@ -0,0 +1,526 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package openapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+)
+
+// Limits how deep example values are generated for recursive schemas
+const maxSchemaDepth = 8
+
+var (
+	pathParamRE  = regexp.MustCompile(`\{([^}]+)\}`)
+	nonIdentRE   = regexp.MustCompile(`[^A-Za-z0-9_$]+`)
+	nonEnvNameRE = regexp.MustCompile(`[^A-Z0-9]+`)
+)
+
+// Decode parses an OpenAPI 3 spec, in either YAML or JSON format.
+func Decode(data []byte) (*Spec, error) {
+	// JSON is valid YAML, but tab indentation isn't, so JSON is decoded directly
+	jsonData := data
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		var err error
+		if jsonData, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+	var spec Spec
+	if err := json.Unmarshal(jsonData, &spec); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, errors.Errorf("unsupported OpenAPI version '%s', only 3.x specs are supported", spec.OpenAPI)
+	}
+	return &spec, nil
+}
+
+// fprintf panics when where's an error writing to the supplied io.Writer
+// since this will be used on in-memory expandable buffers, that should
+// happen only when we run out of memory...
+func fprintf(w io.Writer, format string, a ...interface{}) {
+	if _, err := fmt.Fprintf(w, format, a...); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Convert generates a script that calls every operation of the spec once,
+// grouped by path. Parameters and request bodies are filled in with the
+// examples from the spec or with placeholders generated from their schemas,
+// and credentials for the security schemes are read from environment variables.
+func Convert(spec *Spec, options lib.Options, enableChecks bool) (result string, convertErr error) {
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	c := converter{spec: spec}
+
+	paths := make([]string, 0, len(spec.Paths))
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// Collect the security schemes used by the operations, so only the
+	// needed credentials are declared
+	usedSchemes := map[string]bool{}
+	for _, path := range paths {
+		for _, op := range operations(spec.Paths[path]) {
+			for _, name := range c.securitySchemes(op.op) {
+				usedSchemes[name] = true
+			}
+		}
+	}
+	schemeNames := make([]string, 0, len(usedSchemes))
+	for name := range usedSchemes {
+		schemeNames = append(schemeNames, name)
+	}
+	sort.Strings(schemeNames)
+
+	usesBasic := false
+	for _, name := range schemeNames {
+		scheme := spec.Components.SecuritySchemes[name]
+		usesBasic = usesBasic || (scheme.Type == "http" && strings.ToLower(scheme.Scheme) == "basic")
+	}
+
+	if enableChecks {
+		fprintf(w, "import { group, check } from 'k6';\n")
+	} else {
+		fprintf(w, "import { group } from 'k6';\n")
+	}
+	fprintf(w, "import http from 'k6/http';\n")
+	if usesBasic {
+		fprintf(w, "import encoding from 'k6/encoding';\n")
+	}
+	fprintf(w, "\n")
+
+	if spec.Info.Title != "" {
+		fprintf(w, "// %s\n", spec.Info.Title)
+	}
+	if spec.Info.Version != "" {
+		fprintf(w, "// Version: %s\n", spec.Info.Version)
+	}
+	fprintf(w, "\nconst BASE_URL = __ENV.BASE_URL || %q;\n", c.baseURL())
+
+	if len(schemeNames) > 0 {
+		fprintf(w, "\n// Credentials, e.g. k6 run -e NAME=value script.js\n")
+		for _, name := range schemeNames {
+			for _, v := range c.credentialVars(name) {
+				fprintf(w, "const %s = __ENV.%s;\n", v, v)
+			}
+		}
+	}
+
+	fprintf(w, "\nexport let options = {\n")
+	options.ForEachValid("json", func(key string, val interface{}) {
+		if valJSON, err := json.MarshalIndent(val, "    ", "    "); err != nil {
+			convertErr = err
+		} else {
+			fprintf(w, "    %s: %s,\n", key, valJSON)
+		}
+	})
+	if convertErr != nil {
+		return "", convertErr
+	}
+	fprintf(w, "};\n\n")
+
+	fprintf(w, "export default function() {\n")
+	for _, path := range paths {
+		item := spec.Paths[path]
+		ops := operations(item)
+		if len(ops) == 0 {
+			continue
+		}
+
+		fprintf(w, "\tgroup(%q, function() {\n", path)
+
+		// Declare all of the path parameters once for the whole group
+		declared := map[string]bool{}
+		for _, op := range ops {
+			for _, p := range c.parameters(item.Parameters, op.op.Parameters) {
+				if p.In != "path" || declared[p.Name] {
+					continue
+				}
+				declared[p.Name] = true
+				value, err := json.Marshal(c.parameterValue(p))
+				if err != nil {
+					return "", err
+				}
+				fprintf(w, "\t\tlet %s = %s;\n", jsIdent(p.Name), value)
+			}
+		}
+		fprintf(w, "\t\tlet res;\n")
+
+		for _, op := range ops {
+			if err := c.writeRequest(w, path, item, op, enableChecks); err != nil {
+				return "", errors.Wrapf(err, "%s %s", op.method, path)
+			}
+		}
+		fprintf(w, "\t});\n")
+	}
+	fprintf(w, "}\n")
+
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+type namedOperation struct {
+	method string
+	op     *Operation
+}
+
+func operations(item PathItem) []namedOperation {
+	var ops []namedOperation
+	for _, op := range []namedOperation{
+		{"GET", item.Get}, {"POST", item.Post}, {"PUT", item.Put}, {"PATCH", item.Patch},
+		{"DELETE", item.Delete}, {"HEAD", item.Head}, {"OPTIONS", item.Options}, {"TRACE", item.Trace},
+	} {
+		if op.op != nil {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+type converter struct {
+	spec *Spec
+}
+
+func (c converter) baseURL() string {
+	if len(c.spec.Servers) == 0 {
+		return "http://localhost"
+	}
+	server := c.spec.Servers[0]
+	base := pathParamRE.ReplaceAllStringFunc(server.URL, func(m string) string {
+		if v, ok := server.Variables[m[1:len(m)-1]]; ok {
+			return v.Default
+		}
+		return m
+	})
+	return strings.TrimSuffix(base, "/")
+}
+
+// The names of the security schemes of the first requirement that applies to op
+func (c converter) securitySchemes(op *Operation) []string {
+	requirements := c.spec.Security
+	if op.Security != nil {
+		requirements = *op.Security
+	}
+	if len(requirements) == 0 {
+		return nil
+	}
+	var names []string
+	for name := range requirements[0] {
+		if _, ok := c.spec.Components.SecuritySchemes[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c converter) credentialVars(name string) []string {
+	prefix := strings.Trim(nonEnvNameRE.ReplaceAllString(strings.ToUpper(name), "_"), "_")
+	scheme := c.spec.Components.SecuritySchemes[name]
+	switch {
+	case scheme.Type == "http" && strings.ToLower(scheme.Scheme) == "basic":
+		return []string{prefix + "_USERNAME", prefix + "_PASSWORD"}
+	case scheme.Type == "apiKey":
+		return []string{prefix + "_KEY"}
+	default:
+		return []string{prefix + "_TOKEN"}
+	}
+}
+
+// Merges the path-level and operation-level parameters, resolving references
+func (c converter) parameters(pathParams, opParams []Parameter) []Parameter {
+	var params []Parameter
+	index := map[string]int{}
+	for _, p := range append(append([]Parameter{}, pathParams...), opParams...) {
+		if p.Ref != "" {
+			p = c.spec.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+		}
+		key := p.In + ":" + p.Name
+		if i, ok := index[key]; ok {
+			params[i] = p
+			continue
+		}
+		index[key] = len(params)
+		params = append(params, p)
+	}
+	return params
+}
+
+func (c converter) parameterValue(p Parameter) interface{} {
+	if p.Example != nil {
+		return p.Example
+	}
+	if p.Schema == nil {
+		return "string"
+	}
+	return c.example(p.Schema, 0)
+}
+
+// Generates an example value for a schema
+func (c converter) example(s *Schema, depth int) interface{} {
+	if s == nil || depth > maxSchemaDepth {
+		return nil
+	}
+	if s.Ref != "" {
+		return c.example(c.spec.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")], depth+1)
+	}
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	case len(s.AllOf) > 0:
+		merged := map[string]interface{}{}
+		for _, sub := range s.AllOf {
+			if obj, ok := c.example(sub, depth+1).(map[string]interface{}); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	case len(s.OneOf) > 0:
+		return c.example(s.OneOf[0], depth+1)
+	case len(s.AnyOf) > 0:
+		return c.example(s.AnyOf[0], depth+1)
+	}
+
+	switch s.Type {
+	case "object", "":
+		obj := map[string]interface{}{}
+		for name, prop := range s.Properties {
+			obj[name] = c.example(prop, depth+1)
+		}
+		return obj
+	case "array":
+		return []interface{}{c.example(s.Items, depth+1)}
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	default:
+		return "string"
+	}
+}
+
+func (c converter) requestBody(body *RequestBody) (contentType string, value interface{}, ok bool) {
+	if body == nil {
+		return "", nil, false
+	}
+	if body.Ref != "" {
+		resolved := c.spec.Components.RequestBodies[strings.TrimPrefix(body.Ref, "#/components/requestBodies/")]
+		body = &resolved
+	}
+	if len(body.Content) == 0 {
+		return "", nil, false
+	}
+
+	contentType = "application/json"
+	if _, ok := body.Content[contentType]; !ok {
+		contentType = "application/x-www-form-urlencoded"
+		if _, ok := body.Content[contentType]; !ok {
+			types := make([]string, 0, len(body.Content))
+			for t := range body.Content {
+				types = append(types, t)
+			}
+			sort.Strings(types)
+			contentType = types[0]
+		}
+	}
+
+	media := body.Content[contentType]
+	if media.Example != nil {
+		return contentType, media.Example, true
+	}
+	return contentType, c.example(media.Schema, 0), true
+}
+
+func (c converter) writeRequest(w io.Writer, path string, item PathItem, op namedOperation, enableChecks bool) error {
+	if op.op.Summary != "" {
+		fprintf(w, "\t\t// %s\n", strings.Replace(op.op.Summary, "\n", " ", -1))
+	} else if op.op.OperationID != "" {
+		fprintf(w, "\t\t// %s\n", op.op.OperationID)
+	}
+
+	headers := map[string]string{}
+	cookies := map[string]string{}
+	query := map[string]string{}
+	for _, p := range c.parameters(item.Parameters, op.op.Parameters) {
+		if !p.Required && p.Example == nil && (p.Schema == nil || (p.Schema.Example == nil && p.Schema.Default == nil)) {
+			continue
+		}
+		value := fmt.Sprint(c.parameterValue(p))
+		switch p.In {
+		case "query":
+			query[url.QueryEscape(p.Name)] = escapeTemplate(url.QueryEscape(value))
+		case "header":
+			headers[p.Name] = strconv.Quote(value)
+		case "cookie":
+			cookies[p.Name] = strconv.Quote(value)
+		}
+	}
+
+	for _, name := range c.securitySchemes(op.op) {
+		scheme := c.spec.Components.SecuritySchemes[name]
+		vars := c.credentialVars(name)
+		switch {
+		case scheme.Type == "http" && strings.ToLower(scheme.Scheme) == "basic":
+			headers["Authorization"] = fmt.Sprintf("`Basic ${encoding.b64encode(%s + \":\" + %s)}`", vars[0], vars[1])
+		case scheme.Type == "apiKey" && scheme.In == "query":
+			query[url.QueryEscape(scheme.Name)] = "${encodeURIComponent(" + vars[0] + ")}"
+		case scheme.Type == "apiKey" && scheme.In == "cookie":
+			cookies[scheme.Name] = vars[0]
+		case scheme.Type == "apiKey":
+			headers[scheme.Name] = vars[0]
+		default:
+			headers["Authorization"] = fmt.Sprintf("`Bearer ${%s}`", vars[0])
+		}
+	}
+
+	reqURL := "${BASE_URL}" + pathParamRE.ReplaceAllStringFunc(escapeTemplate(path), func(m string) string {
+		return "${" + jsIdent(m[1:len(m)-1]) + "}"
+	})
+	if len(query) > 0 {
+		names := make([]string, 0, len(query))
+		for name := range query {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pairs := make([]string, len(names))
+		for i, name := range names {
+			pairs[i] = name + "=" + query[name]
+		}
+		reqURL += "?" + strings.Join(pairs, "&")
+	}
+
+	var body string
+	if contentType, value, ok := c.requestBody(op.op.RequestBody); ok {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		switch contentType {
+		case "application/json":
+			body = fmt.Sprintf("JSON.stringify(%s)", data)
+			headers["Content-Type"] = strconv.Quote(contentType)
+		case "application/x-www-form-urlencoded":
+			body = string(data)
+		default:
+			body = strconv.Quote(fmt.Sprint(value))
+			headers["Content-Type"] = strconv.Quote(contentType)
+		}
+	}
+
+	var params []string
+	if len(headers) > 0 {
+		params = append(params, "headers: "+jsObject(headers))
+	}
+	if len(cookies) > 0 {
+		params = append(params, "cookies: "+jsObject(cookies))
+	}
+
+	args := []string{"`" + reqURL + "`"}
+	if op.method != "GET" && op.method != "HEAD" {
+		if body == "" {
+			body = "null"
+		}
+		args = append(args, body)
+	}
+	if len(params) > 0 {
+		args = append(args, "{ "+strings.Join(params, ", ")+" }")
+	}
+
+	switch op.method {
+	case "GET", "POST", "PUT", "PATCH", "HEAD", "OPTIONS":
+		fprintf(w, "\t\tres = http.%s(%s);\n", strings.ToLower(op.method), strings.Join(args, ", "))
+	case "DELETE":
+		fprintf(w, "\t\tres = http.del(%s);\n", strings.Join(args, ", "))
+	default:
+		fprintf(w, "\t\tres = http.request(%q, %s);\n", op.method, strings.Join(args, ", "))
+	}
+
+	if enableChecks {
+		if status := expectedStatus(op.op.Responses); status != 0 {
+			fprintf(w, "\t\tcheck(res, {\"status is %d\": (r) => r.status === %d });\n", status, status)
+		}
+	}
+	return nil
+}
+
+// The lowest documented success status code, or 0 if there's none
+func expectedStatus(responses map[string]json.RawMessage) int {
+	expected := 0
+	for code := range responses {
+		status, err := strconv.Atoi(code)
+		if err != nil || status < 200 || status >= 300 {
+			continue
+		}
+		if expected == 0 || status < expected {
+			expected = status
+		}
+	}
+	return expected
+}
+
+func jsObject(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%q: %s", k, m[k])
+	}
+	return "{ " + strings.Join(pairs, ", ") + " }"
+}
+
+func jsIdent(name string) string {
+	ident := nonIdentRE.ReplaceAllString(name, "_")
+	if ident == "" || (ident[0] >= '0' && ident[0] <= '9') {
+		ident = "_" + ident
+	}
+	return ident
+}
+
+func escapeTemplate(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`", "${", "\\${").Replace(s)
+}
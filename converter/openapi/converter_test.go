@@ -0,0 +1,189 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package openapi
+
+import (
+	"testing"
+
+	"github.com/loadimpact/k6/js"
+	"github.com/loadimpact/k6/lib"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+const testSpec = `
+openapi: 3.0.0
+info:
+  title: Pet Store
+  version: 1.0.0
+servers:
+  - url: https://{env}.example.com/v1/
+    variables:
+      env:
+        default: api
+security:
+  - bearerAuth: []
+paths:
+  /pets:
+    get:
+      summary: List all pets
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            example: 20
+        - name: cursor
+          in: query
+          schema:
+            type: string
+      responses:
+        "200":
+          description: A list of pets
+    post:
+      operationId: createPet
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Pet"
+      responses:
+        "201":
+          description: Created
+        default:
+          description: Error
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema:
+          type: integer
+    get:
+      summary: Info for a specific pet
+      responses:
+        "200":
+          description: A pet
+    delete:
+      security:
+        - basicAuth: []
+      parameters:
+        - $ref: "#/components/parameters/RequestID"
+      responses:
+        "204":
+          description: Deleted
+  /health:
+    get:
+      security: []
+      responses:
+        "200":
+          description: OK
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name:
+          type: string
+          example: Rex
+        tag:
+          type: string
+          enum: [dog, cat]
+        owner:
+          $ref: "#/components/schemas/Owner"
+    Owner:
+      type: object
+      properties:
+        id:
+          type: integer
+  parameters:
+    RequestID:
+      name: X-Request-ID
+      in: header
+      required: true
+      schema:
+        type: string
+  securitySchemes:
+    bearerAuth:
+      type: http
+      scheme: bearer
+    basicAuth:
+      type: http
+      scheme: basic
+`
+
+func TestConvert(t *testing.T) {
+	spec, err := Decode([]byte(testSpec))
+	require.NoError(t, err)
+
+	script, err := Convert(spec, lib.Options{MaxRedirects: null.IntFrom(0)}, true)
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		"import { group, check } from 'k6';",
+		"import encoding from 'k6/encoding';",
+		"// Pet Store",
+		`const BASE_URL = __ENV.BASE_URL || "https://api.example.com/v1";`,
+		"const BASICAUTH_USERNAME = __ENV.BASICAUTH_USERNAME;",
+		"const BASICAUTH_PASSWORD = __ENV.BASICAUTH_PASSWORD;",
+		"const BEARERAUTH_TOKEN = __ENV.BEARERAUTH_TOKEN;",
+		`    maxRedirects: 0,`,
+		`	group("/health", function() {`,
+		"		res = http.get(`${BASE_URL}/health`);",
+		`	group("/pets", function() {`,
+		"		// List all pets",
+		"		res = http.get(`${BASE_URL}/pets?limit=20`, { headers: { \"Authorization\": `Bearer ${BEARERAUTH_TOKEN}` } });",
+		`		check(res, {"status is 200": (r) => r.status === 200 });`,
+		"		// createPet",
+		"		res = http.post(`${BASE_URL}/pets`, JSON.stringify({\"name\":\"Rex\",\"owner\":{\"id\":0},\"tag\":\"dog\"}), " +
+			"{ headers: { \"Authorization\": `Bearer ${BEARERAUTH_TOKEN}`, \"Content-Type\": \"application/json\" } });",
+		`		check(res, {"status is 201": (r) => r.status === 201 });`,
+		`	group("/pets/{petId}", function() {`,
+		"		let petId = 0;",
+		"		res = http.get(`${BASE_URL}/pets/${petId}`, { headers: { \"Authorization\": `Bearer ${BEARERAUTH_TOKEN}` } });",
+		"		res = http.del(`${BASE_URL}/pets/${petId}`, null, { headers: { \"Authorization\": " +
+			"`Basic ${encoding.b64encode(BASICAUTH_USERNAME + \":\" + BASICAUTH_PASSWORD)}`, \"X-Request-ID\": \"string\" } });",
+		`		check(res, {"status is 204": (r) => r.status === 204 });`,
+	} {
+		assert.Contains(t, script, line+"\n")
+	}
+
+	// The generated script has to be valid
+	_, err = js.New(&lib.SourceData{
+		Filename: "/script.js",
+		Data:     []byte(script),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	assert.NoError(t, err, script)
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		spec, err := Decode([]byte("{\n\t\"openapi\": \"3.0.1\",\n\t\"info\": {\"title\": \"API\"},\n\t\"paths\": {}\n}"))
+		require.NoError(t, err)
+		assert.Equal(t, "API", spec.Info.Title)
+	})
+	t.Run("Swagger", func(t *testing.T) {
+		_, err := Decode([]byte(`{"swagger": "2.0", "paths": {}}`))
+		assert.EqualError(t, err, "unsupported OpenAPI version '', only 3.x specs are supported")
+	})
+}
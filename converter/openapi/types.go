@@ -0,0 +1,130 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package openapi
+
+import "encoding/json"
+
+// Spec is the subset of an OpenAPI 3 document needed to generate a script.
+type Spec struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security"`
+}
+
+// Info is the metadata about the API.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Server is a base URL of the API, possibly with variables.
+type Server struct {
+	URL       string                    `json:"url"`
+	Variables map[string]ServerVariable `json:"variables"`
+}
+
+// ServerVariable is a variable used in a server URL.
+type ServerVariable struct {
+	Default string `json:"default"`
+}
+
+// PathItem describes the operations available on a single path.
+type PathItem struct {
+	Parameters []Parameter `json:"parameters"`
+	Get        *Operation  `json:"get"`
+	Put        *Operation  `json:"put"`
+	Post       *Operation  `json:"post"`
+	Delete     *Operation  `json:"delete"`
+	Options    *Operation  `json:"options"`
+	Head       *Operation  `json:"head"`
+	Patch      *Operation  `json:"patch"`
+	Trace      *Operation  `json:"trace"`
+}
+
+// Operation is a single API operation on a path.
+type Operation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Parameters  []Parameter                `json:"parameters"`
+	RequestBody *RequestBody               `json:"requestBody"`
+	Responses   map[string]json.RawMessage `json:"responses"`
+	// A nil Security means the top-level one applies, an empty one disables it.
+	Security *[]SecurityRequirement `json:"security"`
+}
+
+// Parameter is a path, query, header or cookie parameter of an operation.
+type Parameter struct {
+	Ref      string      `json:"$ref"`
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required"`
+	Schema   *Schema     `json:"schema"`
+	Example  interface{} `json:"example"`
+}
+
+// RequestBody describes the body of an operation's request.
+type RequestBody struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+}
+
+// MediaType describes a request body for a specific content type.
+type MediaType struct {
+	Schema  *Schema     `json:"schema"`
+	Example interface{} `json:"example"`
+}
+
+// Schema is the subset of a JSON schema used to generate example values.
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Properties map[string]*Schema `json:"properties"`
+	Items      *Schema            `json:"items"`
+	AllOf      []*Schema          `json:"allOf"`
+	OneOf      []*Schema          `json:"oneOf"`
+	AnyOf      []*Schema          `json:"anyOf"`
+	Enum       []interface{}      `json:"enum"`
+	Example    interface{}        `json:"example"`
+	Default    interface{}        `json:"default"`
+}
+
+// Components holds the reusable objects referenced from the rest of the spec.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	Parameters      map[string]Parameter      `json:"parameters"`
+	RequestBodies   map[string]RequestBody    `json:"requestBodies"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme describes an authentication method used by the API.
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+	In     string `json:"in"`
+	Name   string `json:"name"`
+}
+
+// SecurityRequirement maps the names of security schemes to their scopes.
+type SecurityRequirement map[string][]string
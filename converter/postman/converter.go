@@ -0,0 +1,315 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package postman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+)
+
+var variableRE = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+// Dynamic variables that are generated by Postman for every request
+var dynamicVariables = map[string]string{
+	"$timestamp": "Math.floor(Date.now() / 1000)",
+	"$randomInt": "Math.floor(Math.random() * 1001)",
+}
+
+// Decode parses a Postman v2.0 or v2.1 collection.
+func Decode(data []byte) (*Collection, error) {
+	var c Collection
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if c.Info.Schema != "" && !strings.Contains(c.Info.Schema, "/v2.") {
+		return nil, errors.Errorf("unsupported Postman collection schema '%s', only v2.x collections are supported", c.Info.Schema)
+	}
+	return &c, nil
+}
+
+// fprintf panics when where's an error writing to the supplied io.Writer
+// since this will be used on in-memory expandable buffers, that should
+// happen only when we run out of memory...
+func fprintf(w io.Writer, format string, a ...interface{}) {
+	if _, err := fmt.Fprintf(w, format, a...); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Convert generates a script that makes all of the requests in the collection,
+// with folders turned into groups. Collection variables become entries of a
+// vars object, which can be overridden with environment variables, and the
+// authentication of the collection, folders and requests is carried over.
+func Convert(collection *Collection, options lib.Options, enableChecks bool) (result string, convertErr error) {
+	c := &converter{variables: map[string]bool{}}
+
+	// The requests are generated first, to know which variables they use
+	var body bytes.Buffer
+	bw := bufio.NewWriter(&body)
+	for _, item := range collection.Item {
+		if err := c.writeItem(bw, item, collection.Auth, 1, enableChecks); err != nil {
+			return "", err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+
+	if enableChecks {
+		fprintf(w, "import { group, check } from 'k6';\n")
+	} else {
+		fprintf(w, "import { group } from 'k6';\n")
+	}
+	fprintf(w, "import http from 'k6/http';\n")
+	if c.usesEncoding {
+		fprintf(w, "import encoding from 'k6/encoding';\n")
+	}
+	fprintf(w, "\n")
+	if collection.Info.Name != "" {
+		fprintf(w, "// %s\n\n", collection.Info.Name)
+	}
+
+	values := map[string]string{}
+	for _, v := range collection.Variable {
+		values[v.Key] = fmt.Sprint(v.Value)
+		c.variables[v.Key] = true
+	}
+	if len(c.variables) > 0 {
+		names := make([]string, 0, len(c.variables))
+		for name := range c.variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fprintf(w, "// Variables, which can be overridden with -e NAME=value\n")
+		fprintf(w, "const vars = {\n")
+		for _, name := range names {
+			fprintf(w, "\t%q: __ENV[%q] || %q,\n", name, name, values[name])
+		}
+		fprintf(w, "};\n\n")
+	}
+
+	fprintf(w, "export let options = {\n")
+	options.ForEachValid("json", func(key string, val interface{}) {
+		if valJSON, err := json.MarshalIndent(val, "    ", "    "); err != nil {
+			convertErr = err
+		} else {
+			fprintf(w, "    %s: %s,\n", key, valJSON)
+		}
+	})
+	if convertErr != nil {
+		return "", convertErr
+	}
+	fprintf(w, "};\n\n")
+
+	fprintf(w, "export default function() {\n")
+	fprintf(w, "\tlet res;\n")
+	fprintf(w, "%s", body.String())
+	fprintf(w, "}\n")
+
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+type converter struct {
+	// The names of the variables referenced in the requests
+	variables    map[string]bool
+	usesEncoding bool
+}
+
+func (c *converter) writeItem(w io.Writer, item Item, auth *Auth, depth int, enableChecks bool) error {
+	indent := strings.Repeat("\t", depth)
+	if item.Auth != nil {
+		auth = item.Auth
+	}
+
+	if item.Request == nil {
+		fprintf(w, "%sgroup(%q, function() {\n", indent, item.Name)
+		for _, child := range item.Item {
+			if err := c.writeItem(w, child, auth, depth+1, enableChecks); err != nil {
+				return err
+			}
+		}
+		fprintf(w, "%s});\n", indent)
+		return nil
+	}
+
+	req := item.Request
+	if req.Auth != nil {
+		auth = req.Auth
+	}
+	if item.Name != "" {
+		fprintf(w, "%s// %s\n", indent, strings.Replace(item.Name, "\n", " ", -1))
+	}
+
+	headers := map[string]string{}
+	hasContentType := false
+	for _, h := range req.Header {
+		if h.Disabled {
+			continue
+		}
+		headers[h.Key] = c.quote(h.Value)
+		hasContentType = hasContentType || strings.EqualFold(h.Key, "Content-Type")
+	}
+
+	reqURL := c.template(req.URL.Raw)
+	if auth != nil {
+		switch auth.Type {
+		case "bearer":
+			headers["Authorization"] = "`Bearer " + c.template(auth.Bearer["token"]) + "`"
+		case "basic":
+			c.usesEncoding = true
+			headers["Authorization"] = "`Basic ${encoding.b64encode(`" +
+				c.template(auth.Basic["username"]) + ":" + c.template(auth.Basic["password"]) + "`)}`"
+		case "apikey":
+			key, value := auth.APIKey["key"], auth.APIKey["value"]
+			if auth.APIKey["in"] == "query" {
+				sep := "?"
+				if strings.Contains(req.URL.Raw, "?") {
+					sep = "&"
+				}
+				reqURL += sep + c.template(key) + "=" + c.template(value)
+			} else {
+				headers[key] = c.quote(value)
+			}
+		}
+	}
+
+	body := "null"
+	if b := req.Body; b != nil {
+		switch b.Mode {
+		case "raw":
+			body = c.quote(b.Raw)
+			if b.Options.Raw.Language == "json" && !hasContentType {
+				headers["Content-Type"] = `"application/json"`
+			}
+		case "urlencoded":
+			body = c.fields(b.URLEncoded)
+		case "formdata":
+			for _, f := range b.FormData {
+				if f.Type == "file" && !f.Disabled {
+					fprintf(w, "%s// TODO: the %q file field has to be added with http.file()\n", indent, f.Key)
+				}
+			}
+			body = c.fields(b.FormData)
+		case "graphql":
+			if b.GraphQL != nil {
+				variables := "{}"
+				if v := strings.TrimSpace(b.GraphQL.Variables); v != "" && json.Valid([]byte(v)) {
+					variables = v
+				}
+				body = fmt.Sprintf("JSON.stringify({ query: %s, variables: %s })", c.quote(b.GraphQL.Query), variables)
+				if !hasContentType {
+					headers["Content-Type"] = `"application/json"`
+				}
+			}
+		}
+	}
+
+	args := "`" + reqURL + "`"
+	method := strings.ToUpper(req.Method)
+	if method != "GET" && method != "HEAD" {
+		args += ", " + body
+	}
+	if len(headers) > 0 {
+		args += ", { headers: " + jsObject(headers) + " }"
+	}
+
+	switch method {
+	case "GET", "POST", "PUT", "PATCH", "HEAD", "OPTIONS":
+		fprintf(w, "%sres = http.%s(%s);\n", indent, strings.ToLower(method), args)
+	case "DELETE":
+		fprintf(w, "%sres = http.del(%s);\n", indent, args)
+	default:
+		fprintf(w, "%sres = http.request(%q, %s);\n", indent, method, args)
+	}
+
+	if enableChecks {
+		for _, res := range item.Response {
+			if res.Code != 0 {
+				fprintf(w, "%scheck(res, {\"status is %d\": (r) => r.status === %d });\n", indent, res.Code, res.Code)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the contents of a template literal for s, with its {{variables}}
+// replaced by references to vars
+func (c *converter) template(s string) string {
+	escaped := strings.NewReplacer("\\", "\\\\", "`", "\\`", "${", "\\${").Replace(s)
+	return variableRE.ReplaceAllStringFunc(escaped, func(m string) string {
+		name := strings.TrimSpace(m[2 : len(m)-2])
+		if expr, ok := dynamicVariables[name]; ok {
+			return "${" + expr + "}"
+		}
+		c.variables[name] = true
+		return "${vars[" + strconv.Quote(name) + "]}"
+	})
+}
+
+// Returns s as a JS string, which is only a template literal if it has variables
+func (c *converter) quote(s string) string {
+	if !variableRE.MatchString(s) {
+		return strconv.Quote(s)
+	}
+	return "`" + c.template(s) + "`"
+}
+
+func (c *converter) fields(kvs []KeyValue) string {
+	fields := map[string]string{}
+	for _, kv := range kvs {
+		if kv.Disabled || kv.Type == "file" {
+			continue
+		}
+		fields[kv.Key] = c.quote(kv.Value)
+	}
+	return jsObject(fields)
+}
+
+func jsObject(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%q: %s", k, m[k])
+	}
+	return "{ " + strings.Join(pairs, ", ") + " }"
+}
@@ -0,0 +1,159 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package postman
+
+import (
+	"testing"
+
+	"github.com/loadimpact/k6/js"
+	"github.com/loadimpact/k6/lib"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCollection = `{
+	"info": {
+		"name": "Users API",
+		"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+	},
+	"auth": {
+		"type": "bearer",
+		"bearer": [{"key": "token", "value": "{{token}}", "type": "string"}]
+	},
+	"variable": [
+		{"key": "baseUrl", "value": "https://api.example.com"}
+	],
+	"item": [
+		{
+			"name": "Users",
+			"item": [
+				{
+					"name": "List users",
+					"request": {
+						"method": "GET",
+						"header": [
+							{"key": "Accept", "value": "application/json"},
+							{"key": "X-Debug", "value": "1", "disabled": true}
+						],
+						"url": {"raw": "{{baseUrl}}/users?page=1", "host": ["{{baseUrl}}"]}
+					},
+					"response": [{"name": "OK", "code": 200}]
+				},
+				{
+					"name": "Create user",
+					"request": {
+						"method": "POST",
+						"body": {
+							"mode": "raw",
+							"raw": "{\"name\": \"{{name}}\", \"at\": {{$timestamp}}}",
+							"options": {"raw": {"language": "json"}}
+						},
+						"url": "{{baseUrl}}/users"
+					}
+				}
+			]
+		},
+		{
+			"name": "Login",
+			"request": {
+				"method": "POST",
+				"auth": {
+					"type": "basic",
+					"basic": {"username": "admin", "password": "{{password}}"}
+				},
+				"body": {
+					"mode": "urlencoded",
+					"urlencoded": [{"key": "remember", "value": "true"}]
+				},
+				"url": "{{baseUrl}}/login"
+			}
+		},
+		{
+			"name": "Health",
+			"request": "https://status.example.com/health"
+		},
+		{
+			"name": "Search",
+			"auth": {"type": "apikey", "apikey": [{"key": "key", "value": "api_key"}, {"key": "value", "value": "{{apiKey}}"}, {"key": "in", "value": "query"}]},
+			"request": {
+				"method": "QUERY",
+				"body": {"mode": "graphql", "graphql": {"query": "{ users { id } }", "variables": ""}},
+				"url": "{{baseUrl}}/graphql?v=1"
+			}
+		}
+	]
+}`
+
+func TestConvert(t *testing.T) {
+	collection, err := Decode([]byte(testCollection))
+	require.NoError(t, err)
+
+	script, err := Convert(collection, lib.Options{}, true)
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		"import { group, check } from 'k6';",
+		"import encoding from 'k6/encoding';",
+		"// Users API",
+		"const vars = {",
+		`	"apiKey": __ENV["apiKey"] || "",`,
+		`	"baseUrl": __ENV["baseUrl"] || "https://api.example.com",`,
+		`	"name": __ENV["name"] || "",`,
+		`	"password": __ENV["password"] || "",`,
+		`	"token": __ENV["token"] || "",`,
+		`	group("Users", function() {`,
+		"		// List users",
+		"		res = http.get(`${vars[\"baseUrl\"]}/users?page=1`, " +
+			"{ headers: { \"Accept\": \"application/json\", \"Authorization\": `Bearer ${vars[\"token\"]}` } });",
+		`		check(res, {"status is 200": (r) => r.status === 200 });`,
+		"		res = http.post(`${vars[\"baseUrl\"]}/users`, `{\"name\": \"${vars[\"name\"]}\", \"at\": ${Math.floor(Date.now() / 1000)}}`, " +
+			"{ headers: { \"Authorization\": `Bearer ${vars[\"token\"]}`, \"Content-Type\": \"application/json\" } });",
+		"	res = http.post(`${vars[\"baseUrl\"]}/login`, { \"remember\": \"true\" }, " +
+			"{ headers: { \"Authorization\": `Basic ${encoding.b64encode(`admin:${vars[\"password\"]}`)}` } });",
+		"	res = http.get(`https://status.example.com/health`, { headers: { \"Authorization\": `Bearer ${vars[\"token\"]}` } });",
+		"	res = http.request(\"QUERY\", `${vars[\"baseUrl\"]}/graphql?v=1&api_key=${vars[\"apiKey\"]}`, " +
+			"JSON.stringify({ query: \"{ users { id } }\", variables: {} }), { headers: { \"Content-Type\": \"application/json\" } });",
+	} {
+		assert.Contains(t, script, line+"\n")
+	}
+	assert.NotContains(t, script, "X-Debug")
+
+	// The generated script has to be valid
+	_, err = js.New(&lib.SourceData{
+		Filename: "/script.js",
+		Data:     []byte(script),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	assert.NoError(t, err, script)
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("v1", func(t *testing.T) {
+		_, err := Decode([]byte(`{"info": {"schema": "https://schema.getpostman.com/json/collection/v1.0.0/collection.json"}}`))
+		assert.EqualError(t, err, "unsupported Postman collection schema "+
+			"'https://schema.getpostman.com/json/collection/v1.0.0/collection.json', only v2.x collections are supported")
+	})
+	t.Run("v2.0 auth", func(t *testing.T) {
+		collection, err := Decode([]byte(`{"auth": {"type": "bearer", "bearer": {"token": "abc"}}}`))
+		require.NoError(t, err)
+		assert.Equal(t, AuthParams{"token": "abc"}, collection.Auth.Bearer)
+	})
+}
@@ -0,0 +1,172 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package postman
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Collection is the subset of a Postman v2.0 or v2.1 collection needed to
+// generate a script.
+type Collection struct {
+	Info     Info       `json:"info"`
+	Item     []Item     `json:"item"`
+	Auth     *Auth      `json:"auth"`
+	Variable []Variable `json:"variable"`
+}
+
+// Info is the metadata about the collection.
+type Info struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+// Item is either a folder, which has items of its own, or a request.
+type Item struct {
+	Name     string     `json:"name"`
+	Item     []Item     `json:"item"`
+	Request  *Request   `json:"request"`
+	Response []Response `json:"response"`
+	Auth     *Auth      `json:"auth"`
+}
+
+// Request is an HTTP request, which can also be given as just an URL.
+type Request struct {
+	Method string     `json:"method"`
+	Header []KeyValue `json:"header"`
+	Body   *Body      `json:"body"`
+	URL    URL        `json:"url"`
+	Auth   *Auth      `json:"auth"`
+}
+
+// UnmarshalJSON accepts both request objects and plain URL strings.
+func (r *Request) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*r = Request{Method: "GET", URL: URL{Raw: raw}}
+		return nil
+	}
+	type request Request
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return err
+	}
+	*r = Request(req)
+	if r.Method == "" {
+		r.Method = "GET"
+	}
+	return nil
+}
+
+// URL is the URL of a request, which can be an object or a string.
+type URL struct {
+	Raw string `json:"raw"`
+}
+
+// UnmarshalJSON accepts both URL objects and plain strings.
+func (u *URL) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &u.Raw); err == nil {
+		return nil
+	}
+	var obj struct {
+		Raw string `json:"raw"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	u.Raw = obj.Raw
+	return nil
+}
+
+// KeyValue is a header, an URL encoded field or a multipart form field.
+type KeyValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Type     string `json:"type"`
+	Disabled bool   `json:"disabled"`
+}
+
+// Body is the body of a request.
+type Body struct {
+	Mode       string     `json:"mode"`
+	Raw        string     `json:"raw"`
+	URLEncoded []KeyValue `json:"urlencoded"`
+	FormData   []KeyValue `json:"formdata"`
+	GraphQL    *GraphQL   `json:"graphql"`
+	Options    struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+}
+
+// GraphQL is the body of a GraphQL request.
+type GraphQL struct {
+	Query     string `json:"query"`
+	Variables string `json:"variables"`
+}
+
+// Auth is the authentication used for a request, a folder or the whole collection.
+type Auth struct {
+	Type   string     `json:"type"`
+	Bearer AuthParams `json:"bearer"`
+	Basic  AuthParams `json:"basic"`
+	APIKey AuthParams `json:"apikey"`
+}
+
+// AuthParams are the parameters of an authentication method.
+type AuthParams map[string]string
+
+// UnmarshalJSON accepts both the v2.1 list of key-value pairs and the v2.0 object.
+func (p *AuthParams) UnmarshalJSON(data []byte) error {
+	var list []struct {
+		Key   string      `json:"key"`
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(data, &list); err == nil {
+		*p = AuthParams{}
+		for _, kv := range list {
+			(*p)[kv.Key] = fmt.Sprint(kv.Value)
+		}
+		return nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	*p = AuthParams{}
+	for k, v := range obj {
+		(*p)[k] = fmt.Sprint(v)
+	}
+	return nil
+}
+
+// Variable is a collection variable.
+type Variable struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// Response is a saved example response of a request.
+type Response struct {
+	Code int `json:"code"`
+}
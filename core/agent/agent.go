@@ -0,0 +1,282 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package agent runs tests on a schedule, for continuous synthetic monitoring, and keeps the
+// status of their last runs.
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// The results of a run.
+const (
+	RunPassed  = "passed"
+	RunFailed  = "failed"
+	RunErrored = "error"
+)
+
+// ErrThresholdsFailed is returned by a RunFunc when a test ran, but some of its thresholds failed.
+var ErrThresholdsFailed = errors.New("some thresholds have failed")
+
+// A Test is a test the agent runs on a schedule.
+type Test struct {
+	// The name of the test, which has to be unique.
+	Name string `json:"name"`
+
+	// The script or archive to run, and the environment variables passed to it.
+	Script string            `json:"script"`
+	Env    map[string]string `json:"env"`
+
+	// When to run it, as a cron expression; see Schedule.
+	Schedule string `json:"schedule"`
+
+	// The options to run it with, and the outputs to send its metrics to.
+	Options lib.Options `json:"options"`
+	Out     []string    `json:"out"`
+}
+
+// Config is the configuration of an agent.
+type Config struct {
+	Tests []Test `json:"tests"`
+}
+
+// Validate checks that there are tests, that they're named uniquely and that their schedules
+// are valid.
+func (c Config) Validate() error {
+	if len(c.Tests) == 0 {
+		return errors.New("there are no tests to run")
+	}
+	seen := make(map[string]bool, len(c.Tests))
+	for _, test := range c.Tests {
+		if test.Name == "" {
+			return errors.New("all tests need a name")
+		}
+		if seen[test.Name] {
+			return errors.Errorf("there's more than one test named '%s'", test.Name)
+		}
+		seen[test.Name] = true
+		if test.Script == "" || test.Script == "-" {
+			return errors.Errorf("the test '%s' needs a script file", test.Name)
+		}
+		if _, err := ParseSchedule(test.Schedule); err != nil {
+			return errors.Wrapf(err, "test '%s'", test.Name)
+		}
+	}
+	return nil
+}
+
+// A RunFunc runs a test once, until it's done or the context is cancelled. It returns
+// ErrThresholdsFailed if the test ran but failed its thresholds.
+type RunFunc func(ctx context.Context, test Test) error
+
+// A Run is a finished run of a test.
+type Run struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Status is the status of a scheduled test.
+type Status struct {
+	Name     string
+	Schedule string
+	Script   string
+
+	// Whether it's running, and when it'll next run, or a zero time if it won't.
+	Running bool
+	Next    time.Time
+
+	// How many times it ran, and how many runs were skipped because the previous one was still
+	// going when they were due.
+	Runs    int64
+	Skipped int64
+
+	// The last finished run, if there's been one.
+	LastRun *Run
+}
+
+type scheduledTest struct {
+	test     Test
+	schedule Schedule
+
+	next    time.Time
+	running bool
+	runs    int64
+	skipped int64
+	lastRun *Run
+}
+
+// An Agent runs tests on their schedules.
+type Agent struct {
+	run RunFunc
+
+	mu    sync.Mutex
+	tests []*scheduledTest
+}
+
+// New returns an agent that runs the configured tests with run.
+func New(conf Config, run RunFunc) (*Agent, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	a := &Agent{run: run}
+	for _, test := range conf.Tests {
+		schedule, _ := ParseSchedule(test.Schedule)
+		a.tests = append(a.tests, &scheduledTest{test: test, schedule: schedule})
+	}
+	return a, nil
+}
+
+// Run starts the tests whenever they're due, until the context is cancelled, and then waits for
+// the ones that are running to stop. A test that's still running when it's due again is skipped.
+func (a *Agent) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	a.mu.Lock()
+	now := time.Now()
+	for _, t := range a.tests {
+		t.next = t.schedule.Next(now)
+	}
+	a.mu.Unlock()
+
+	for {
+		a.mu.Lock()
+		var next time.Time
+		for _, t := range a.tests {
+			if !t.next.IsZero() && (next.IsZero() || t.next.Before(next)) {
+				next = t.next
+			}
+		}
+		a.mu.Unlock()
+		if next.IsZero() {
+			<-ctx.Done()
+			return nil
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		a.mu.Lock()
+		now := time.Now()
+		for _, t := range a.tests {
+			if t.next.IsZero() || t.next.After(now) {
+				continue
+			}
+			t.next = t.schedule.Next(now)
+			if t.running {
+				t.skipped++
+				log.WithField("test", t.test.Name).Warn("Skipping a scheduled run, the previous one is still running")
+				continue
+			}
+			t.running = true
+			wg.Add(1)
+			go func(t *scheduledTest) {
+				defer wg.Done()
+				a.runTest(ctx, t)
+			}(t)
+		}
+		a.mu.Unlock()
+	}
+}
+
+func (a *Agent) runTest(ctx context.Context, t *scheduledTest) {
+	l := log.WithField("test", t.test.Name)
+	l.Info("Starting a scheduled run")
+
+	run := &Run{Start: time.Now()}
+	err := a.run(ctx, t.test)
+	run.End = time.Now()
+	switch {
+	case err == nil:
+		run.Result = RunPassed
+	case errors.Cause(err) == ErrThresholdsFailed:
+		run.Result = RunFailed
+	default:
+		run.Result = RunErrored
+		run.Error = err.Error()
+	}
+	l.WithFields(log.Fields{
+		"result":   run.Result,
+		"duration": run.End.Sub(run.Start),
+	}).Info("Finished a scheduled run")
+	if run.Result == RunErrored {
+		l.WithError(err).Error("The scheduled run failed")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t.running = false
+	t.runs++
+	t.lastRun = run
+}
+
+// Status returns the status of all tests, in the order they're configured in.
+func (a *Agent) Status() []Status {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	statuses := make([]Status, len(a.tests))
+	for i, t := range a.tests {
+		statuses[i] = t.status()
+	}
+	return statuses
+}
+
+// TestStatus returns the status of the named test.
+func (a *Agent) TestStatus(name string) (Status, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, t := range a.tests {
+		if t.test.Name == name {
+			return t.status(), true
+		}
+	}
+	return Status{}, false
+}
+
+func (t *scheduledTest) status() Status {
+	s := Status{
+		Name:     t.test.Name,
+		Schedule: t.schedule.String(),
+		Script:   t.test.Script,
+		Running:  t.running,
+		Next:     t.next,
+		Runs:     t.runs,
+		Skipped:  t.skipped,
+	}
+	if t.lastRun != nil {
+		run := *t.lastRun
+		s.LastRun = &run
+	}
+	return s
+}
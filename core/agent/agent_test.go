@@ -0,0 +1,154 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := Test{Name: "probe", Script: "probe.js", Schedule: "@every 1m"}
+	assert.NoError(t, Config{Tests: []Test{valid}}.Validate())
+
+	unnamed, noScript, stdin, badSchedule := valid, valid, valid, valid
+	unnamed.Name = ""
+	noScript.Script = ""
+	stdin.Script = "-"
+	badSchedule.Schedule = "* *"
+	testdata := map[string]struct {
+		tests []Test
+		msg   string
+	}{
+		"empty":        {nil, "there are no tests to run"},
+		"unnamed":      {[]Test{unnamed}, "all tests need a name"},
+		"duplicate":    {[]Test{valid, valid}, "more than one test named 'probe'"},
+		"no script":    {[]Test{noScript}, "needs a script file"},
+		"stdin":        {[]Test{stdin}, "needs a script file"},
+		"bad schedule": {[]Test{badSchedule}, "test 'probe': invalid schedule"},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			err := Config{Tests: data.tests}.Validate()
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), data.msg)
+			}
+			_, err = New(Config{Tests: data.tests}, nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAgent(t *testing.T) {
+	conf := Config{Tests: []Test{
+		{Name: "passing", Script: "passing.js", Schedule: "@every 20ms"},
+		{Name: "failing", Script: "failing.js", Schedule: "@every 20ms"},
+		{Name: "erroring", Script: "erroring.js", Schedule: "@every 20ms"},
+		{Name: "slow", Script: "slow.js", Schedule: "@every 20ms"},
+		{Name: "never", Script: "never.js", Schedule: "0 0 30 2 *"},
+	}}
+
+	var mu sync.Mutex
+	started := make(map[string]int)
+	a, err := New(conf, func(ctx context.Context, test Test) error {
+		mu.Lock()
+		started[test.Name]++
+		mu.Unlock()
+		switch test.Name {
+		case "failing":
+			return errors.Wrap(ErrThresholdsFailed, "test")
+		case "erroring":
+			return errors.New("the script threw")
+		case "slow":
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	for _, s := range a.Status() {
+		assert.False(t, s.Running)
+		assert.Nil(t, s.LastRun)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- a.Run(ctx) }()
+	time.Sleep(200 * time.Millisecond)
+
+	s, ok := a.TestStatus("slow")
+	require.True(t, ok)
+	assert.True(t, s.Running)
+	assert.Nil(t, s.LastRun)
+	assert.True(t, s.Skipped > 0)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the agent didn't stop")
+	}
+
+	mu.Lock()
+	assert.Equal(t, 1, started["slow"])
+	assert.Equal(t, 0, started["never"])
+	mu.Unlock()
+
+	results := map[string]string{
+		"passing":  RunPassed,
+		"failing":  RunFailed,
+		"erroring": RunErrored,
+		"slow":     RunErrored,
+	}
+	statuses := a.Status()
+	require.Len(t, statuses, len(conf.Tests))
+	for i, s := range statuses {
+		assert.Equal(t, conf.Tests[i].Name, s.Name)
+		assert.Equal(t, conf.Tests[i].Schedule, s.Schedule)
+		assert.False(t, s.Running)
+		if s.Name == "never" {
+			assert.True(t, s.Next.IsZero())
+			assert.Nil(t, s.LastRun)
+			continue
+		}
+		assert.False(t, s.Next.IsZero())
+		assert.True(t, s.Runs > 0, s.Name)
+		if assert.NotNil(t, s.LastRun, s.Name) {
+			assert.Equal(t, results[s.Name], s.LastRun.Result, s.Name)
+			assert.False(t, s.LastRun.End.Before(s.LastRun.Start))
+		}
+	}
+	s, _ = a.TestStatus("erroring")
+	assert.Equal(t, "the script threw", s.LastRun.Error)
+	s, _ = a.TestStatus("failing")
+	assert.Empty(t, s.LastRun.Error)
+
+	_, ok = a.TestStatus("missing")
+	assert.False(t, ok)
+}
@@ -0,0 +1,191 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package agent
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Shorthands for common schedules.
+var scheduleMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// A Schedule is a cron expression: five fields, for the minute, hour, day of the month, month
+// and day of the week, each of which is "*", a number, a range, eg. "1-5", a step, eg. "*/15" or
+// "0-30/10", or a comma-separated list of them. It can also be one of the macros "@hourly",
+// "@daily", "@weekly", "@monthly" and "@yearly", or "@every" followed by a duration, eg.
+// "@every 10m", to run at a fixed interval instead.
+type Schedule struct {
+	spec  string
+	every time.Duration
+
+	minutes, hours, days, months, weekdays uint64
+
+	// A day matches if either of the day of the month and the day of the week do, unless one of
+	// them is "*", like in cron.
+	anyDay, anyWeekday bool
+}
+
+// ParseSchedule parses a cron expression.
+func ParseSchedule(spec string) (Schedule, error) {
+	s := Schedule{spec: spec}
+	expr := strings.TrimSpace(spec)
+	if strings.HasPrefix(expr, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(expr[len("@every "):]))
+		if err != nil {
+			return s, errors.Wrapf(err, "invalid schedule '%s'", spec)
+		}
+		if every <= 0 {
+			return s, errors.Errorf("invalid schedule '%s', the interval must be positive", spec)
+		}
+		s.every = every
+		return s, nil
+	}
+	if macro, ok := scheduleMacros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return s, errors.Errorf("invalid schedule '%s', expected 5 fields: minute, hour, "+
+			"day of the month, month and day of the week", spec)
+	}
+	var err error
+	if s.minutes, err = parseScheduleField(fields[0], 0, 59); err != nil {
+		return s, errors.Wrapf(err, "invalid minute in schedule '%s'", spec)
+	}
+	if s.hours, err = parseScheduleField(fields[1], 0, 23); err != nil {
+		return s, errors.Wrapf(err, "invalid hour in schedule '%s'", spec)
+	}
+	if s.days, err = parseScheduleField(fields[2], 1, 31); err != nil {
+		return s, errors.Wrapf(err, "invalid day of the month in schedule '%s'", spec)
+	}
+	if s.months, err = parseScheduleField(fields[3], 1, 12); err != nil {
+		return s, errors.Wrapf(err, "invalid month in schedule '%s'", spec)
+	}
+	if s.weekdays, err = parseScheduleField(fields[4], 0, 7); err != nil {
+		return s, errors.Wrapf(err, "invalid day of the week in schedule '%s'", spec)
+	}
+	// Both 0 and 7 are Sunday.
+	if s.weekdays&(1<<7) != 0 {
+		s.weekdays |= 1
+	}
+	s.anyDay = strings.HasPrefix(fields[2], "*")
+	s.anyWeekday = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// parseScheduleField returns the values a field of a cron expression matches as a bitset.
+func parseScheduleField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			expr = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, errors.Errorf("invalid step in '%s'", part)
+			}
+		}
+
+		lo, hi := min, max
+		switch {
+		case expr == "*":
+		case strings.Contains(expr, "-"):
+			bounds := strings.SplitN(expr, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, errors.Errorf("invalid range '%s'", part)
+			}
+		default:
+			var err error
+			if lo, err = strconv.Atoi(expr); err != nil {
+				return 0, errors.Errorf("invalid value '%s'", part)
+			}
+			// A single value with a step, eg. "5/10", starts at it and goes on to the max.
+			hi = lo
+			if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, errors.Errorf("'%s' is out of range, it must be between %d and %d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// String returns the expression the schedule was parsed from.
+func (s Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first time after t that the schedule matches, in t's location, or a zero time
+// if there's none in the next five years, eg. because it's only on the 31st of February.
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s Schedule) matchDay(t time.Time) bool {
+	day := s.days&(1<<uint(t.Day())) != 0
+	weekday := s.weekdays&(1<<uint(t.Weekday())) != 0
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
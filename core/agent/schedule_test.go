@@ -0,0 +1,112 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, spec := range []string{
+		"* * * * *",
+		"*/15 * * * *",
+		"0 9-17 * * 1-5",
+		"0,30 * 1,15 * *",
+		"5/10 * * * 7",
+		"@hourly",
+		"@daily",
+		"@every 10m",
+	} {
+		t.Run(spec, func(t *testing.T) {
+			s, err := ParseSchedule(spec)
+			assert.NoError(t, err)
+			assert.Equal(t, spec, s.String())
+		})
+	}
+
+	for spec, msg := range map[string]string{
+		"":               "expected 5 fields",
+		"* * * *":        "expected 5 fields",
+		"60 * * * *":     "invalid minute",
+		"* 24 * * *":     "invalid hour",
+		"* * 0 * *":      "invalid day of the month",
+		"* * * 13 *":     "invalid month",
+		"* * * * 8":      "invalid day of the week",
+		"5-1 * * * *":    "out of range",
+		"*/0 * * * *":    "invalid step",
+		"a * * * *":      "invalid value",
+		"1-a * * * *":    "invalid range",
+		"@every 1x":      "invalid schedule",
+		"@every -1m":     "must be positive",
+		"@fortnightly":   "expected 5 fields",
+		"* * * JAN *":    "invalid month",
+		"0 0 1 1 * 2020": "expected 5 fields",
+	} {
+		t.Run("invalid "+spec, func(t *testing.T) {
+			_, err := ParseSchedule(spec)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2018, 5, 16, 10, 7, 30, 0, time.UTC)
+	testdata := map[string]time.Time{
+		"* * * * *":      time.Date(2018, 5, 16, 10, 8, 0, 0, time.UTC),
+		"*/15 * * * *":   time.Date(2018, 5, 16, 10, 15, 0, 0, time.UTC),
+		"0 * * * *":      time.Date(2018, 5, 16, 11, 0, 0, 0, time.UTC),
+		"30 9 * * *":     time.Date(2018, 5, 17, 9, 30, 0, 0, time.UTC),
+		"0 9-17 * * 1-5": time.Date(2018, 5, 16, 11, 0, 0, 0, time.UTC),
+		"0 0 * * 0":      time.Date(2018, 5, 20, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":      time.Date(2018, 5, 20, 0, 0, 0, 0, time.UTC),
+		"0 0 1 * *":      time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 31 * *":     time.Date(2018, 5, 31, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":     time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC),
+		"@yearly":        time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		"@every 90s":     now.Add(90 * time.Second),
+		"0 0 30 2 *":     {},
+
+		// Either the day of the month or the day of the week.
+		"0 0 1 * 5": time.Date(2018, 5, 18, 0, 0, 0, 0, time.UTC),
+	}
+	for spec, next := range testdata {
+		t.Run(spec, func(t *testing.T) {
+			s, err := ParseSchedule(spec)
+			if assert.NoError(t, err) {
+				assert.Equal(t, next, s.Next(now))
+			}
+		})
+	}
+
+	t.Run("location", func(t *testing.T) {
+		loc := time.FixedZone("IST", 5*3600+1800)
+		s, err := ParseSchedule("0 9 * * *")
+		if assert.NoError(t, err) {
+			assert.Equal(t, time.Date(2018, 5, 17, 9, 0, 0, 0, loc), s.Next(now.In(loc)))
+		}
+	})
+}
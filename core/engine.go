@@ -22,6 +22,7 @@ package core
 
 import (
 	"context"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -29,7 +30,9 @@ import (
 	"github.com/loadimpact/k6/core/local"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/guregu/null.v3"
 )
@@ -66,6 +69,18 @@ type Engine struct {
 	thresholds map[string]stats.Thresholds
 	submetrics map[string][]*stats.Submetric
 
+	// Turned into metrics once all the metrics they reference have been received.
+	thresholdExpressions map[string]stats.ThresholdExpressions
+
+	// Values of a previous run that thresholds can refer to, if set.
+	baseline *stats.Baseline
+
+	// Drops and pre-aggregates samples before they're passed to the collectors, if enabled.
+	outputAggregator *outputAggregator
+
+	// Measures the health of the load generator, emitted along with the VU metrics.
+	generator *generatorMonitor
+
 	// Are thresholds tainted?
 	thresholdsTainted bool
 }
@@ -80,6 +95,8 @@ func NewEngine(ex lib.Executor, o lib.Options) (*Engine, error) {
 		Options:  o,
 		Metrics:  make(map[string]*stats.Metric),
 		Samples:  make(chan stats.SampleContainer, o.MetricSamplesBufferSize.Int64),
+
+		generator: newGeneratorMonitor(),
 	}
 	e.SetLogger(log.StandardLogger())
 
@@ -94,9 +111,25 @@ func NewEngine(ex lib.Executor, o lib.Options) (*Engine, error) {
 	ex.SetEndTime(o.Duration)
 	ex.SetEndIterations(o.Iterations)
 
+	if o.OutputAggregation != nil && o.OutputAggregation.IsSet() {
+		e.outputAggregator = newOutputAggregator(*o.OutputAggregation)
+	}
+
 	e.thresholds = o.Thresholds
-	e.submetrics = make(map[string][]*stats.Submetric)
+	e.thresholdExpressions = make(map[string]stats.ThresholdExpressions, len(o.ThresholdExpressions))
+	submetricNames := make(map[string]bool)
 	for name := range e.thresholds {
+		submetricNames[name] = true
+	}
+	for name, exprs := range o.ThresholdExpressions {
+		e.thresholdExpressions[name] = exprs
+		for _, ref := range exprs.Refs {
+			submetricNames[ref.Metric] = true
+		}
+	}
+
+	e.submetrics = make(map[string][]*stats.Submetric)
+	for name := range submetricNames {
 		if !strings.Contains(name, "{") {
 			continue
 		}
@@ -108,6 +141,105 @@ func NewEngine(ex lib.Executor, o lib.Options) (*Engine, error) {
 	return e, nil
 }
 
+// SetBaseline makes the values of a previous run available to the thresholds, which can refer to
+// them as "baseline". Thresholds that do are skipped for metrics the baseline doesn't have.
+func (e *Engine) SetBaseline(baseline stats.Baseline) {
+	e.baseline = &baseline
+	e.applyBaseline()
+}
+
+func (e *Engine) applyBaseline() {
+	baseline := e.baseline
+	for name, ths := range e.thresholds {
+		if !ths.UsesBaseline() {
+			continue
+		}
+		values, ok := baseline.Metrics[name]
+		if !ok {
+			e.logger.WithField("m", name).Warn("The baseline doesn't have this metric, thresholds referring to it will be skipped")
+			continue
+		}
+		ths.SetBaseline(values)
+		e.thresholds[name] = ths
+	}
+}
+
+// ReloadOptions changes the options that can be changed while the test is running: the stages,
+// the duration, the RPS limit and the thresholds. Options that aren't set are left as they are.
+// Stages are still counted from the start of the test, and new thresholds on submetrics only
+// see samples from here on. The options are changed with MetricsLock held, like they're read.
+func (e *Engine) ReloadOptions(o lib.Options) error {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+
+	if o.Stages != nil {
+		for _, stage := range o.Stages {
+			if stage.Target.Valid && stage.Target.Int64 > e.Executor.GetVUsMax() {
+				if err := e.Executor.SetVUsMax(stage.Target.Int64); err != nil {
+					return err
+				}
+			}
+		}
+		e.Executor.SetStages(o.Stages)
+		e.Options.Stages = o.Stages
+	}
+	if o.Duration.Valid {
+		d := o.Duration
+		if d.Duration == 0 {
+			d = types.NullDuration{}
+		}
+		e.Executor.SetEndTime(d)
+		e.Options.Duration = o.Duration
+	}
+	if o.RPS.Valid {
+		limiter, ok := e.Executor.GetRunner().(lib.RPSLimiter)
+		if !ok {
+			return errors.New("the RPS limit can't be changed for this runner")
+		}
+		limiter.SetRPSLimit(o.RPS.Int64)
+		e.Options.RPS = o.RPS
+	}
+	if o.Thresholds != nil {
+		e.reloadThresholds(o.Thresholds)
+	}
+	return nil
+}
+
+// reloadThresholds replaces the thresholds of all metrics. Must be called with MetricsLock held.
+func (e *Engine) reloadThresholds(thresholds map[string]stats.Thresholds) {
+	e.thresholds = thresholds
+	e.Options.Thresholds = thresholds
+	if e.baseline != nil {
+		e.applyBaseline()
+	}
+
+	for name := range thresholds {
+		if !strings.Contains(name, "{") {
+			continue
+		}
+		parent, sm := stats.NewSubmetric(name)
+		known := false
+		for _, other := range e.submetrics[parent] {
+			known = known || other.Name == sm.Name
+		}
+		if known {
+			continue
+		}
+		e.submetrics[parent] = append(e.submetrics[parent], sm)
+		if m, ok := e.Metrics[parent]; ok {
+			m.Submetrics = e.submetrics[parent]
+		}
+	}
+
+	for name, m := range e.Metrics {
+		if _, ok := m.Sink.(*stats.ExpressionSink); ok {
+			continue
+		}
+		m.Thresholds = e.thresholds[name]
+		m.Tainted = null.Bool{}
+	}
+}
+
 func (e *Engine) setRunStatus(status lib.RunStatus) {
 	if len(e.Collectors) == 0 {
 		return
@@ -166,6 +298,14 @@ func (e *Engine) Run(ctx context.Context) error {
 		subwg.Done()
 	}()
 
+	// Run the generator health monitor.
+	subwg.Add(1)
+	go func() {
+		e.generator.Run(subctx)
+		e.logger.Debug("Engine: Generator monitor terminated")
+		subwg.Done()
+	}()
+
 	// Run thresholds.
 	if !e.NoThresholds {
 		subwg.Add(1)
@@ -216,6 +356,9 @@ func (e *Engine) Run(ctx context.Context) error {
 			e.processThresholds(nil)
 		}
 
+		// Pass on the aggregation windows that haven't ended yet.
+		e.flushOutputAggregation()
+
 		// Finally, shut down collector.
 		collectorcancel()
 		collectorwg.Wait()
@@ -235,7 +378,11 @@ func (e *Engine) Run(ctx context.Context) error {
 			errC = nil
 			if err != nil {
 				e.logger.WithError(err).Debug("run: executor returned an error")
-				e.setRunStatus(lib.RunStatusAbortedSystem)
+				if _, ok := errors.Cause(err).(lib.AbortError); ok {
+					e.setRunStatus(lib.RunStatusAbortedUser)
+				} else {
+					e.setRunStatus(lib.RunStatusAbortedSystem)
+				}
 				return err
 			}
 			e.logger.Debug("run: executor terminated")
@@ -248,10 +395,47 @@ func (e *Engine) Run(ctx context.Context) error {
 	}
 }
 
+// Progress returns how far along the test is, from 0 to 1, based on its end conditions; it's 0
+// if the test has none.
+func (e *Engine) Progress() float64 {
+	if endIt := e.Executor.GetEndIterations(); endIt.Valid {
+		return float64(e.Executor.GetIterations()) / float64(endIt.Int64)
+	}
+
+	stagesEndT := lib.SumStages(e.Executor.GetStages())
+	endT := e.Executor.GetEndTime()
+	if !endT.Valid || (stagesEndT.Valid && endT.Duration > stagesEndT.Duration) {
+		endT = stagesEndT
+	}
+	if endT.Valid && endT.Duration > 0 {
+		return float64(e.Executor.GetTime()) / float64(endT.Duration)
+	}
+	return 0
+}
+
 func (e *Engine) IsTainted() bool {
 	return e.thresholdsTainted
 }
 
+// ThresholdsExitCode returns the exit code of the first failed threshold that has one, going by
+// metric name, or 0 if none do.
+func (e *Engine) ThresholdsExitCode() int {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+
+	names := make([]string, 0, len(e.Metrics))
+	for name := range e.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if code := e.Metrics[name].Thresholds.ExitCode(); code != 0 {
+			return code
+		}
+	}
+	return 0
+}
+
 func (e *Engine) SetLogger(l *log.Logger) {
 	e.logger = l
 	e.Executor.SetLogger(l)
@@ -276,22 +460,25 @@ func (e *Engine) runMetricsEmission(ctx context.Context) {
 func (e *Engine) emitMetrics() {
 	t := time.Now()
 
-	e.processSamples([]stats.SampleContainer{stats.ConnectedSamples{
-		Samples: []stats.Sample{
-			{
-				Time:   t,
-				Metric: metrics.VUs,
-				Value:  float64(e.Executor.GetVUs()),
-				Tags:   e.Options.RunTags,
-			}, {
-				Time:   t,
-				Metric: metrics.VUsMax,
-				Value:  float64(e.Executor.GetVUsMax()),
-				Tags:   e.Options.RunTags,
-			},
+	samples := []stats.Sample{
+		{
+			Time:   t,
+			Metric: metrics.VUs,
+			Value:  float64(e.Executor.GetVUs()),
+			Tags:   e.Options.RunTags,
+		}, {
+			Time:   t,
+			Metric: metrics.VUsMax,
+			Value:  float64(e.Executor.GetVUsMax()),
+			Tags:   e.Options.RunTags,
 		},
-		Tags: e.Options.RunTags,
-		Time: t,
+	}
+	samples = append(samples, e.generator.Samples(t, e.Options.RunTags)...)
+
+	e.processSamples([]stats.SampleContainer{stats.ConnectedSamples{
+		Samples: samples,
+		Tags:    e.Options.RunTags,
+		Time:    t,
 	}})
 }
 
@@ -314,6 +501,8 @@ func (e *Engine) processThresholds(abort func()) {
 	t := e.Executor.GetTime()
 	abortOnFail := false
 
+	e.resolveThresholdExpressions()
+
 	e.thresholdsTainted = false
 	for _, m := range e.Metrics {
 		if len(m.Thresholds.Thresholds) == 0 {
@@ -344,6 +533,28 @@ func (e *Engine) processThresholds(abort func()) {
 	}
 }
 
+// resolveThresholdExpressions adds a metric for every threshold expression whose referenced
+// metrics have all received samples. Must be called with MetricsLock held.
+func (e *Engine) resolveThresholdExpressions() {
+outer:
+	for name, exprs := range e.thresholdExpressions {
+		metrics := make([]*stats.Metric, len(exprs.Refs))
+		for i, ref := range exprs.Refs {
+			m, ok := e.Metrics[ref.Metric]
+			if !ok {
+				continue outer
+			}
+			metrics[i] = m
+		}
+
+		m := stats.New(name, stats.Gauge)
+		m.Sink = &stats.ExpressionSink{Refs: exprs.Refs, Metrics: metrics}
+		m.Thresholds = exprs.Thresholds
+		e.Metrics[name] = m
+		delete(e.thresholdExpressions, name)
+	}
+}
+
 func (e *Engine) processSamplesForMetrics(sampleCointainers []stats.SampleContainer) {
 	for _, sampleCointainer := range sampleCointainers {
 		samples := sampleCointainer.GetSamples()
@@ -384,6 +595,9 @@ func (e *Engine) processSamples(sampleCointainers []stats.SampleContainer) {
 		return
 	}
 
+	// Keep the secrets scripts register out of the summary, the API and the outputs.
+	sampleCointainers = lib.Secrets.RedactSamples(sampleCointainers)
+
 	// TODO: optimize this...
 	e.MetricsLock.Lock()
 	defer e.MetricsLock.Unlock()
@@ -394,8 +608,28 @@ func (e *Engine) processSamples(sampleCointainers []stats.SampleContainer) {
 	}
 
 	if len(e.Collectors) > 0 {
+		if e.outputAggregator != nil {
+			sampleCointainers = e.outputAggregator.process(sampleCointainers, time.Now())
+		}
 		for _, collector := range e.Collectors {
 			collector.Collect(sampleCointainers)
 		}
 	}
 }
+
+func (e *Engine) flushOutputAggregation() {
+	if e.outputAggregator == nil || len(e.Collectors) == 0 {
+		return
+	}
+
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+
+	samples := e.outputAggregator.flush(time.Time{})
+	if len(samples) == 0 {
+		return
+	}
+	for _, collector := range e.Collectors {
+		collector.Collect([]stats.SampleContainer{samples})
+	}
+}
@@ -22,7 +22,9 @@ package core
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -335,6 +337,41 @@ func TestEngineCollector(t *testing.T) {
 	}
 }
 
+func TestEngineOutputAggregation(t *testing.T) {
+	counter := stats.New("test_counter", stats.Counter)
+	dropped := stats.New("test_dropped", stats.Gauge)
+
+	e, err := newTestEngine(LF(func(ctx context.Context, out chan<- stats.SampleContainer) error {
+		now := time.Now()
+		for i := 0; i < 10; i++ {
+			out <- stats.Samples{
+				{Metric: counter, Time: now, Value: 1},
+				{Metric: dropped, Time: now, Value: float64(i)},
+			}
+		}
+		return nil
+	}), lib.Options{
+		VUs: null.IntFrom(1), VUsMax: null.IntFrom(1), Iterations: null.IntFrom(1),
+		OutputAggregation: &lib.OutputAggregation{OutputAggregationFields: lib.OutputAggregationFields{
+			Period:  types.NullDurationFrom(time.Minute),
+			Metrics: []string{"test_counter"},
+			Drop:    []string{"test_dropped"},
+		}},
+	})
+	require.NoError(t, err)
+
+	c := &dummy.Collector{}
+	e.Collectors = []lib.Collector{c}
+	require.NoError(t, e.Run(context.Background()))
+
+	// The engine still sees every sample, the collector only the aggregated ones.
+	assert.Equal(t, 10.0, e.Metrics["test_counter"].Sink.(*stats.CounterSink).Value)
+	assert.Equal(t, 9.0, e.Metrics["test_dropped"].Sink.(*stats.GaugeSink).Value)
+	assert.Equal(t, uint(0), getMetricCount(c, "test_dropped"))
+	assert.Equal(t, 10.0, getMetricSum(c, "test_counter"))
+	assert.True(t, getMetricCount(c, "test_counter") < 10)
+}
+
 func TestEngine_processSamples(t *testing.T) {
 	metric := stats.New("my_metric", stats.Gauge)
 
@@ -482,6 +519,221 @@ func TestEngine_processThresholds(t *testing.T) {
 	}
 }
 
+func TestEngine_SetBaseline(t *testing.T) {
+	metric := stats.New("my_metric", stats.Gauge)
+	baseline := stats.Baseline{Metrics: map[string]stats.BaselineValues{
+		"my_metric":      {"value": 1},
+		"my_metric{a:1}": {"value": 2},
+	}}
+
+	testdata := map[string]struct {
+		pass bool
+		ths  map[string][]string
+	}{
+		"passing":           {true, map[string][]string{"my_metric": {"value < baseline.value * 1.5"}}},
+		"failing":           {false, map[string][]string{"my_metric": {"value < baseline.value * 1.1"}}},
+		"submetric":         {true, map[string][]string{"my_metric{a:1}": {"value < baseline.value"}}},
+		"not in baseline":   {true, map[string][]string{"my_metric{a:2}": {"value < baseline.value"}}},
+		"without reference": {false, map[string][]string{"my_metric": {"value < 1"}}},
+	}
+
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			thresholds := make(map[string]stats.Thresholds, len(data.ths))
+			for m, srcs := range data.ths {
+				ths, err := stats.NewThresholds(srcs)
+				require.NoError(t, err)
+				thresholds[m] = ths
+			}
+
+			e, err := newTestEngine(nil, lib.Options{Thresholds: thresholds})
+			require.NoError(t, err)
+			e.SetBaseline(baseline)
+
+			e.processSamples([]stats.SampleContainer{stats.Sample{
+				Metric: metric, Value: 1.25, Tags: stats.IntoSampleTags(&map[string]string{"a": "1"}),
+			}})
+			e.processThresholds(nil)
+			assert.Equal(t, data.pass, !e.IsTainted())
+		})
+	}
+}
+
+func TestEngine_ThresholdsExitCode(t *testing.T) {
+	metric := stats.New("my_metric", stats.Gauge)
+
+	testdata := map[string]struct {
+		code int
+		ths  string
+	}{
+		"passing":      {0, `{"my_metric": [{"threshold": "value < 2", "exitCode": 10}]}`},
+		"failing":      {10, `{"my_metric": [{"threshold": "value < 1", "exitCode": 10}]}`},
+		"default":      {0, `{"my_metric": ["value < 1"]}`},
+		"first failed": {20, `{"my_metric": [{"threshold": "value < 2", "exitCode": 10}, {"threshold": "value < 1", "exitCode": 20}]}`},
+		"by name": {30, `{
+			"my_metric": ["value < 1"],
+			"my_metric{a:1}": [{"threshold": "value < 1", "exitCode": 40}],
+			"my_metric{a:0}": [{"threshold": "value < 1", "exitCode": 30}]
+		}`},
+	}
+
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			var thresholds map[string]stats.Thresholds
+			require.NoError(t, json.Unmarshal([]byte(data.ths), &thresholds))
+
+			e, err := newTestEngine(nil, lib.Options{Thresholds: thresholds})
+			require.NoError(t, err)
+
+			e.processSamples([]stats.SampleContainer{
+				stats.Sample{Metric: metric, Value: 1.25, Tags: stats.IntoSampleTags(&map[string]string{"a": "0"})},
+				stats.Sample{Metric: metric, Value: 1.25, Tags: stats.IntoSampleTags(&map[string]string{"a": "1"})},
+			})
+			e.processThresholds(nil)
+			assert.Equal(t, data.code, e.ThresholdsExitCode())
+		})
+	}
+}
+
+func TestEngine_ReloadOptions(t *testing.T) {
+	metric := stats.New("my_metric", stats.Gauge)
+	ths, err := stats.NewThresholds([]string{"value < 1"})
+	require.NoError(t, err)
+
+	e, err := newTestEngine(nil, lib.Options{
+		VUsMax:     null.IntFrom(10),
+		Thresholds: map[string]stats.Thresholds{"my_metric": ths},
+	})
+	require.NoError(t, err)
+
+	process := func() {
+		e.processSamples([]stats.SampleContainer{stats.Sample{
+			Metric: metric, Value: 2, Tags: stats.IntoSampleTags(&map[string]string{"a": "1"}),
+		}})
+		e.processThresholds(nil)
+	}
+	process()
+	assert.True(t, e.IsTainted())
+
+	t.Run("nothing", func(t *testing.T) {
+		assert.NoError(t, e.ReloadOptions(lib.Options{}))
+		assert.Nil(t, e.Executor.GetStages())
+		assert.False(t, e.Executor.GetEndTime().Valid)
+	})
+
+	t.Run("stages", func(t *testing.T) {
+		stages := []lib.Stage{
+			{Duration: types.NullDurationFrom(10 * time.Second), Target: null.IntFrom(5)},
+			{Duration: types.NullDurationFrom(10 * time.Second), Target: null.IntFrom(20)},
+		}
+		assert.NoError(t, e.ReloadOptions(lib.Options{Stages: stages}))
+		assert.Equal(t, stages, e.Executor.GetStages())
+		assert.Equal(t, stages, e.Options.Stages)
+		assert.Equal(t, int64(20), e.Executor.GetVUsMax())
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		assert.NoError(t, e.ReloadOptions(lib.Options{Duration: types.NullDurationFrom(time.Hour)}))
+		assert.Equal(t, types.NullDurationFrom(time.Hour), e.Executor.GetEndTime())
+
+		assert.NoError(t, e.ReloadOptions(lib.Options{Duration: types.NullDurationFrom(0)}))
+		assert.False(t, e.Executor.GetEndTime().Valid)
+	})
+
+	t.Run("rps", func(t *testing.T) {
+		assert.Error(t, e.ReloadOptions(lib.Options{RPS: null.IntFrom(10)}))
+	})
+
+	t.Run("thresholds", func(t *testing.T) {
+		var thresholds map[string]stats.Thresholds
+		require.NoError(t, json.Unmarshal([]byte(`{"my_metric": ["value < 3"], "my_metric{a:1}": ["value < 1"]}`), &thresholds))
+		assert.NoError(t, e.ReloadOptions(lib.Options{Thresholds: thresholds}))
+		assert.Equal(t, thresholds, e.Options.Thresholds)
+
+		process()
+		assert.True(t, e.IsTainted())
+		assert.Equal(t, null.BoolFrom(false), e.Metrics["my_metric"].Tainted)
+		if assert.Contains(t, e.Metrics, "my_metric{a:1}") {
+			assert.Equal(t, null.BoolFrom(true), e.Metrics["my_metric{a:1}"].Tainted)
+		}
+
+		var reloaded map[string]stats.Thresholds
+		require.NoError(t, json.Unmarshal([]byte(`{"my_metric": ["value < 3"]}`), &reloaded))
+		assert.NoError(t, e.ReloadOptions(lib.Options{Thresholds: reloaded}))
+		e.processThresholds(nil)
+		assert.False(t, e.IsTainted())
+		assert.False(t, e.Metrics["my_metric{a:1}"].Tainted.Valid)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 1; i <= 10; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				assert.NoError(t, e.ReloadOptions(lib.Options{
+					Stages:     []lib.Stage{{Duration: types.NullDurationFrom(time.Second), Target: null.IntFrom(int64(i))}},
+					Duration:   types.NullDurationFrom(time.Duration(i) * time.Minute),
+					Thresholds: map[string]stats.Thresholds{"my_metric": ths},
+				}))
+			}(i)
+			go func() {
+				defer wg.Done()
+				process()
+				e.MetricsLock.Lock()
+				_, _ = e.Options.Stages, e.Options.Duration
+				e.MetricsLock.Unlock()
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestEngine_processThresholdExpressions(t *testing.T) {
+	reqs := stats.New("reqs", stats.Counter)
+	failed := stats.New("failed", stats.Counter)
+
+	testdata := map[string]struct {
+		pass     bool
+		src      string
+		resolved bool
+	}{
+		"passing":           {true, "failed / reqs < 0.5", true},
+		"failing":           {false, "failed / reqs < 0.1", true},
+		"submetric,passing": {true, "failed{a:1} / reqs{a:1} == 1", true},
+		"submetric,failing": {false, "failed{a:1} / reqs < 0.1", true},
+		"stat":              {true, "reqs.count == 4", true},
+		"missing":           {true, "failed / other < 0.1", false},
+	}
+
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			exprs, err := stats.NewThresholdExpressions([]string{data.src})
+			require.NoError(t, err)
+
+			e, err := newTestEngine(nil, lib.Options{
+				ThresholdExpressions: map[string]stats.ThresholdExpressions{"ratio": exprs},
+			})
+			require.NoError(t, err)
+
+			tags := stats.IntoSampleTags(&map[string]string{"a": "1"})
+			e.processSamples([]stats.SampleContainer{
+				stats.Sample{Metric: reqs, Value: 3},
+				stats.Sample{Metric: reqs, Value: 1, Tags: tags},
+				stats.Sample{Metric: failed, Value: 1, Tags: tags},
+			})
+			e.processThresholds(nil)
+
+			assert.Equal(t, data.pass, !e.IsTainted())
+			m, ok := e.Metrics["ratio"]
+			assert.Equal(t, data.resolved, ok)
+			if ok {
+				assert.Equal(t, null.BoolFrom(!data.pass), m.Tainted)
+			}
+		})
+	}
+}
+
 func getMetricSum(collector *dummy.Collector, name string) (result float64) {
 	for _, sc := range collector.SampleContainers {
 		for _, s := range sc.GetSamples() {
@@ -736,6 +988,8 @@ func TestRunTags(t *testing.T) {
 	systemMetrics := []*stats.Metric{
 		metrics.VUs, metrics.VUsMax, metrics.Iterations, metrics.IterationDuration,
 		metrics.GroupDuration, metrics.DataSent, metrics.DataReceived,
+		metrics.GeneratorCPU, metrics.GeneratorMemory, metrics.GeneratorGoroutines,
+		metrics.GeneratorGCPause, metrics.GeneratorEventLoopLag,
 	}
 
 	getExpectedOverVal := func(metricName string) string {
@@ -0,0 +1,128 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+)
+
+// How often the generatorMonitor checks how late it's woken up. A generator that's short on CPU
+// can't schedule goroutines on time, which delays VUs just as much as the monitor.
+const eventLoopLagInterval = 100 * time.Millisecond
+
+// A generatorMonitor measures the health of the load generator itself: CPU and memory usage, GC
+// pauses, goroutines and scheduling lag. If any of them is high, the other metrics of the test
+// may reflect k6's limits rather than the target's.
+type generatorMonitor struct {
+	mutex  sync.Mutex
+	maxLag time.Duration
+
+	lastCPU  time.Duration
+	lastTime time.Time
+	lastGC   uint32
+}
+
+func newGeneratorMonitor() *generatorMonitor {
+	m := &generatorMonitor{lastTime: time.Now()}
+	m.lastCPU, _ = processCPUTime()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	m.lastGC = mem.NumGC
+	return m
+}
+
+// Run measures the scheduling lag until the context is cancelled.
+func (m *generatorMonitor) Run(ctx context.Context) {
+	timer := time.NewTimer(eventLoopLagInterval)
+	defer timer.Stop()
+	for {
+		start := time.Now()
+		select {
+		case <-timer.C:
+			m.recordLag(time.Since(start) - eventLoopLagInterval)
+			timer.Reset(eventLoopLagInterval)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *generatorMonitor) recordLag(lag time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if lag > m.maxLag {
+		m.maxLag = lag
+	}
+}
+
+// Samples returns the generator metrics since the previous call. GC pauses are emitted one
+// sample per collection, the scheduling lag as the highest one measured.
+func (m *generatorMonitor) Samples(t time.Time, tags *stats.SampleTags) []stats.Sample {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	samples := []stats.Sample{
+		{Time: t, Metric: metrics.GeneratorMemory, Value: float64(mem.HeapAlloc), Tags: tags},
+		{Time: t, Metric: metrics.GeneratorGoroutines, Value: float64(runtime.NumGoroutine()), Tags: tags},
+	}
+
+	// Only the last 256 pauses are kept, in a ring buffer; any older ones are lost.
+	ring := uint32(len(mem.PauseNs))
+	from := m.lastGC + 1
+	if mem.NumGC >= ring && from <= mem.NumGC-ring {
+		from = mem.NumGC - ring + 1
+	}
+	for n := from; n <= mem.NumGC; n++ {
+		pause := time.Duration(mem.PauseNs[(n+ring-1)%ring])
+		samples = append(samples, stats.Sample{
+			Time: t, Metric: metrics.GeneratorGCPause, Value: stats.D(pause), Tags: tags,
+		})
+	}
+	m.lastGC = mem.NumGC
+
+	if cpu, ok := processCPUTime(); ok {
+		if wall := t.Sub(m.lastTime); wall > 0 {
+			usage := float64(cpu-m.lastCPU) / float64(wall) / float64(runtime.NumCPU()) * 100
+			samples = append(samples, stats.Sample{
+				Time: t, Metric: metrics.GeneratorCPU, Value: usage, Tags: tags,
+			})
+		}
+		m.lastCPU = cpu
+	}
+	m.lastTime = t
+
+	m.mutex.Lock()
+	lag := m.maxLag
+	m.maxLag = 0
+	m.mutex.Unlock()
+	samples = append(samples, stats.Sample{
+		Time: t, Metric: metrics.GeneratorEventLoopLag, Value: stats.D(lag), Tags: tags,
+	})
+
+	return samples
+}
@@ -0,0 +1,46 @@
+// +build windows
+
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime returns the user and kernel CPU time the process has used so far.
+func processCPUTime() (time.Duration, bool) {
+	h, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0, false
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return 0, false
+	}
+	return filetimeDuration(kernel) + filetimeDuration(user), true
+}
+
+// Process times are counted in 100ns intervals.
+func filetimeDuration(ft syscall.Filetime) time.Duration {
+	return time.Duration(int64(ft.HighDateTime)<<32|int64(ft.LowDateTime)) * 100
+}
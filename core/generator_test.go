@@ -0,0 +1,104 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratorMonitor(t *testing.T) {
+	tags := stats.IntoSampleTags(&map[string]string{"foo": "bar"})
+
+	count := func(samples []stats.Sample, m *stats.Metric) int {
+		n := 0
+		for _, s := range samples {
+			if s.Metric == m {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("Samples", func(t *testing.T) {
+		m := newGeneratorMonitor()
+		runtime.GC()
+		runtime.GC()
+
+		now := time.Now()
+		samples := m.Samples(now, tags)
+		for _, s := range samples {
+			assert.Equal(t, now, s.Time)
+			assert.Equal(t, tags, s.Tags)
+		}
+		assert.Equal(t, 1, count(samples, metrics.GeneratorMemory))
+		assert.Equal(t, 1, count(samples, metrics.GeneratorGoroutines))
+		assert.Equal(t, 1, count(samples, metrics.GeneratorEventLoopLag))
+		assert.True(t, count(samples, metrics.GeneratorGCPause) >= 2)
+		if runtime.GOOS != "windows" {
+			assert.Equal(t, 1, count(samples, metrics.GeneratorCPU))
+		}
+
+		// Pauses are only emitted once.
+		samples = m.Samples(time.Now(), tags)
+		assert.Equal(t, 0, count(samples, metrics.GeneratorGCPause))
+	})
+
+	t.Run("Lag", func(t *testing.T) {
+		m := newGeneratorMonitor()
+		m.recordLag(5 * time.Millisecond)
+		m.recordLag(20 * time.Millisecond)
+		m.recordLag(10 * time.Millisecond)
+
+		for _, s := range m.Samples(time.Now(), tags) {
+			if s.Metric == metrics.GeneratorEventLoopLag {
+				assert.Equal(t, stats.D(20*time.Millisecond), s.Value)
+			}
+		}
+		for _, s := range m.Samples(time.Now(), tags) {
+			if s.Metric == metrics.GeneratorEventLoopLag {
+				assert.Equal(t, 0.0, s.Value)
+			}
+		}
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		m := newGeneratorMonitor()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			m.Run(ctx)
+			close(done)
+		}()
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Run didn't return")
+		}
+	})
+}
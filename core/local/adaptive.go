@@ -0,0 +1,134 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package local
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+)
+
+// A rateController paces iteration starts for an AdaptiveRate. After every interval it checks
+// whether the SLO held, and adjusts the rate with additive increase, multiplicative decrease:
+// the rate goes up by a step while the system keeps up, and is halved when it doesn't.
+type rateController struct {
+	config lib.AdaptiveRate
+
+	rate   float64 // Target iterations per second
+	tokens float64 // Iterations that may be started right now
+
+	// Observations for the current interval.
+	elapsed  time.Duration
+	started  int64
+	latency  stats.TrendSink
+	requests int64
+	failed   int64
+
+	// The highest achieved rate at which the SLO held.
+	capacity float64
+}
+
+func newRateController(config lib.AdaptiveRate) *rateController {
+	return &rateController{config: config, rate: float64(config.GetStartRate())}
+}
+
+// CanStart returns whether an iteration may be started now.
+func (c *rateController) CanStart() bool {
+	return c.tokens >= 1
+}
+
+// Start records that an iteration has been started.
+func (c *rateController) Start() {
+	c.tokens--
+	c.started++
+}
+
+// Observe collects the latency and error samples the SLO is evaluated on.
+func (c *rateController) Observe(samples []stats.Sample) {
+	metric := c.config.GetMetric()
+	for _, s := range samples {
+		if s.Metric.Name == metric {
+			c.latency.Add(s)
+		}
+		if s.Metric == metrics.HTTPReqs {
+			c.requests++
+			if status, ok := s.Tags.Get("status"); ok {
+				if code, err := strconv.Atoi(status); err == nil && (code == 0 || code >= 400) {
+					c.failed++
+				}
+			}
+		}
+	}
+}
+
+// Advance moves the controller forward by d, returning the metric samples to emit if the rate
+// was adjusted.
+func (c *rateController) Advance(t time.Time, d time.Duration, tags *stats.SampleTags) []stats.Sample {
+	// Cap the tokens at a tenth of a second's worth, so iterations that were delayed because no
+	// VU was free don't all start at once as soon as one is.
+	c.tokens = math.Min(c.tokens+c.rate*d.Seconds(), math.Max(1, c.rate/10))
+
+	c.elapsed += d
+	if c.elapsed < c.config.GetInterval() {
+		return nil
+	}
+
+	achieved := float64(c.started) / c.elapsed.Seconds()
+	if c.sloHolds() {
+		c.capacity = math.Max(c.capacity, achieved)
+		c.rate += float64(c.config.GetStep())
+		if maxRate := c.config.MaxRate; maxRate.Valid && maxRate.Int64 > 0 {
+			c.rate = math.Min(c.rate, float64(maxRate.Int64))
+		}
+	} else {
+		c.rate = math.Max(1, c.rate/2)
+	}
+
+	c.elapsed = 0
+	c.started = 0
+	c.latency = stats.TrendSink{}
+	c.requests = 0
+	c.failed = 0
+
+	return []stats.Sample{
+		{Time: t, Metric: metrics.AdaptiveRate, Value: c.rate, Tags: tags},
+		{Time: t, Metric: metrics.AdaptiveRateCapacity, Value: c.capacity, Tags: tags},
+	}
+}
+
+func (c *rateController) sloHolds() bool {
+	if target := c.config.Target; target.Valid && c.latency.Count > 0 {
+		targetMs := float64(time.Duration(target.Duration)) / float64(time.Millisecond)
+		if c.latency.P(c.config.GetPercentile()/100) > targetMs {
+			return false
+		}
+	}
+	if maxErrorRate := c.config.MaxErrorRate; maxErrorRate.Valid && c.requests > 0 {
+		if float64(c.failed)/float64(c.requests) > maxErrorRate.Float64 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package local
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestRateController(t *testing.T) {
+	now := time.Now()
+	latency := func(ms float64) stats.Sample {
+		return stats.Sample{Metric: metrics.HTTPReqDuration, Value: ms}
+	}
+	request := func(status string) stats.Sample {
+		return stats.Sample{Metric: metrics.HTTPReqs, Value: 1, Tags: stats.IntoSampleTags(&map[string]string{"status": status})}
+	}
+
+	t.Run("pacing", func(t *testing.T) {
+		c := newRateController(lib.AdaptiveRate{StartRate: null.IntFrom(100), Target: types.NullDurationFrom(time.Second)})
+		assert.False(t, c.CanStart())
+
+		assert.Nil(t, c.Advance(now, 25*time.Millisecond, nil))
+		for i := 0; i < 2; i++ {
+			assert.True(t, c.CanStart())
+			c.Start()
+		}
+		assert.False(t, c.CanStart())
+
+		// Tokens don't pile up while no VUs are free
+		c.Advance(now, time.Second, nil)
+		assert.Equal(t, 10.0, c.tokens)
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		c := newRateController(lib.AdaptiveRate{
+			StartRate: null.IntFrom(10),
+			MaxRate:   null.IntFrom(25),
+			Target:    types.NullDurationFrom(300 * time.Millisecond),
+			Interval:  types.NullDurationFrom(time.Second),
+		})
+		tags := stats.IntoSampleTags(&map[string]string{"test": "adaptive"})
+
+		c.started = 10
+		c.Observe([]stats.Sample{latency(100), latency(200)})
+		samples := c.Advance(now, time.Second, tags)
+		assert.Equal(t, []stats.Sample{
+			{Time: now, Metric: metrics.AdaptiveRate, Value: 20, Tags: tags},
+			{Time: now, Metric: metrics.AdaptiveRateCapacity, Value: 10, Tags: tags},
+		}, samples)
+
+		c.started = 20
+		c.Observe([]stats.Sample{latency(200)})
+		c.Advance(now, time.Second, nil)
+		assert.Equal(t, 25.0, c.rate, "capped to the max rate")
+		assert.Equal(t, 20.0, c.capacity)
+
+		c.started = 25
+		c.Observe([]stats.Sample{latency(200), latency(250), latency(400), latency(500)})
+		c.Advance(now, time.Second, nil)
+		assert.Equal(t, 12.5, c.rate)
+		assert.Equal(t, 20.0, c.capacity, "the capacity only counts rates at which the SLO held")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		c := newRateController(lib.AdaptiveRate{
+			StartRate:    null.IntFrom(10),
+			Step:         null.IntFrom(1),
+			MaxErrorRate: null.FloatFrom(0.25),
+			Interval:     types.NullDurationFrom(time.Second),
+		})
+
+		c.Observe([]stats.Sample{request("200"), request("200"), request("200"), request("503")})
+		c.Advance(now, time.Second, nil)
+		assert.Equal(t, 11.0, c.rate)
+
+		c.Observe([]stats.Sample{request("200"), request("0"), request("404")})
+		c.Advance(now, time.Second, nil)
+		assert.Equal(t, 5.5, c.rate)
+	})
+}
@@ -51,17 +51,23 @@ type vuHandle struct {
 	cancel context.CancelFunc
 }
 
-func (h *vuHandle) run(logger *log.Logger, flow <-chan int64, iterDone chan<- struct{}) {
+func (h *vuHandle) run(
+	logger *log.Logger, flow <-chan int64, iterDone, iterShed chan<- struct{}, abort chan<- lib.AbortError,
+) {
 	h.RLock()
 	ctx := h.ctx
 	h.RUnlock()
 
 	for {
 		select {
-		case _, ok := <-flow:
+		case iter, ok := <-flow:
 			if !ok {
 				return
 			}
+			if iter == shedIteration {
+				iterShed <- struct{}{}
+				continue
+			}
 		case <-ctx.Done():
 			return
 		}
@@ -72,6 +78,13 @@ func (h *vuHandle) run(logger *log.Logger, flow <-chan int64, iterDone chan<- st
 			case <-ctx.Done():
 			// Don't log errors or emit iterations metrics from cancelled iterations
 			default:
+				if aerr, ok := err.(lib.AbortError); ok {
+					select {
+					case abort <- aerr:
+					case <-ctx.Done():
+					}
+					return
+				}
 				if err != nil {
 					if s, ok := err.(fmt.Stringer); ok {
 						logger.Error(s.String())
@@ -113,7 +126,8 @@ type Executor struct {
 	pauseLock sync.RWMutex
 	pause     chan interface{}
 
-	stages []lib.Stage
+	stagesLock sync.RWMutex
+	stages     []lib.Stage
 
 	// Lock for: ctx, flow, out
 	lock sync.RWMutex
@@ -127,6 +141,12 @@ type Executor struct {
 	// Channel on which VUs sigal that iterations are completed
 	iterDone chan struct{}
 
+	// Channel on which VUs signal that iterations were shed because of the resource limits
+	iterShed chan struct{}
+
+	// Channel on which VUs signal that the script aborted the test
+	abort chan lib.AbortError
+
 	// Flow control for VUs; iterations are run only after reading from this channel.
 	flow chan int64
 }
@@ -146,6 +166,8 @@ func New(r lib.Runner) *Executor {
 		endTime:     -1,
 		vuOut:       make(chan stats.SampleContainer, bufferSize),
 		iterDone:    make(chan struct{}),
+		iterShed:    make(chan struct{}),
+		abort:       make(chan lib.AbortError),
 	}
 }
 
@@ -164,6 +186,7 @@ func (e *Executor) Run(parent context.Context, engineOut chan<- stats.SampleCont
 	e.lock.Lock()
 	vuOut := e.vuOut
 	iterDone := e.iterDone
+	iterShed := e.iterShed
 	e.ctx = ctx
 	e.flow = vuFlow
 	e.lock.Unlock()
@@ -198,6 +221,7 @@ func (e *Executor) Run(parent context.Context, engineOut chan<- stats.SampleCont
 			select {
 			case <-iterDone:
 				// Spool through all remaining iterations, do not emit stats since the Run() is over
+			case <-iterShed:
 			case newSampleContainer := <-vuOut:
 				if cutoff.IsZero() {
 					engineOut <- newSampleContainer
@@ -226,6 +250,22 @@ func (e *Executor) Run(parent context.Context, engineOut chan<- stats.SampleCont
 		return err
 	}
 
+	// With an adaptive rate, iterations are only started when the rate controller allows it.
+	// With resource limits, iterations are shed or the test is stopped while they're exceeded.
+	var rate *rateController
+	var guard *resourceGuard
+	var runTags *stats.SampleTags
+	if e.Runner != nil {
+		opts := e.Runner.GetOptions()
+		runTags = opts.RunTags
+		if opts.AdaptiveRate != nil {
+			rate = newRateController(*opts.AdaptiveRate)
+		}
+		if opts.ResourceLimits != nil && *opts.ResourceLimits != (lib.ResourceLimits{}) {
+			guard = newResourceGuard(*opts.ResourceLimits, e.Runner)
+		}
+	}
+
 	ticker := time.NewTicker(1 * time.Millisecond)
 	defer ticker.Stop()
 
@@ -258,11 +298,27 @@ func (e *Executor) Run(parent context.Context, engineOut chan<- stats.SampleCont
 		if end >= 0 && partials >= end {
 			flow = nil
 		}
+		if rate != nil && !rate.CanStart() {
+			flow = nil
+		}
+		next := partials
+		if guard != nil && guard.Exceeded() != "" {
+			next = shedIteration
+			if !guard.CanShed() {
+				flow = nil
+			}
+		}
 
 		select {
-		case flow <- partials:
+		case flow <- next:
 			// Start an iteration if there's a VU waiting. See also: the big comment block above.
+			// A shed iteration counts towards the iteration limit like any other.
 			atomic.AddInt64(&e.partIters, 1)
+			if next == shedIteration {
+				guard.Shed()
+			} else if rate != nil {
+				rate.Start()
+			}
 		case t := <-ticker.C:
 			// Every tick, increment the clock, see if we passed the end point, and process stages.
 			// If the test ends this way, set a cutoff point; any samples collected past the cutoff
@@ -278,7 +334,26 @@ func (e *Executor) Run(parent context.Context, engineOut chan<- stats.SampleCont
 				return nil
 			}
 
-			stages := e.stages
+			if rate != nil {
+				for _, sample := range rate.Advance(t, d, runTags) {
+					engineOut <- sample
+				}
+			}
+
+			if guard != nil && guard.Advance(d, atomic.LoadInt64(&e.numVUs), runTags) {
+				reason := guard.Exceeded()
+				switch {
+				case reason == "":
+					e.Logger.Info("Usage is back under the resource limits, no longer shedding iterations")
+				case guard.limits.GetPolicy() == lib.ResourcePolicyAbort:
+					cutoff = time.Now()
+					return errors.Errorf("the %s resource limit was exceeded", reason)
+				default:
+					e.Logger.WithField("reason", reason).Warn("A resource limit was exceeded, shedding iterations")
+				}
+			}
+
+			stages := e.GetStages()
 			if len(stages) > 0 {
 				vus, keepRunning := ProcessStages(startVUs, stages, at)
 				if !keepRunning {
@@ -293,19 +368,32 @@ func (e *Executor) Run(parent context.Context, engineOut chan<- stats.SampleCont
 				}
 			}
 		case sampleContainer := <-vuOut:
+			if rate != nil {
+				rate.Observe(sampleContainer.GetSamples())
+			}
 			engineOut <- sampleContainer
 		case <-iterDone:
 			// Every iteration ends with a write to iterDone. Check if we've hit the end point.
 			// If not, make sure to include an Iterations bump in the list!
-			var tags *stats.SampleTags
-			if e.Runner != nil {
-				tags = e.Runner.GetOptions().RunTags
-			}
 			engineOut <- stats.Sample{
 				Time:   time.Now(),
 				Metric: metrics.Iterations,
 				Value:  1,
-				Tags:   tags,
+				Tags:   runTags,
+			}
+
+			end := atomic.LoadInt64(&e.endIters)
+			at := atomic.AddInt64(&e.iters, 1)
+			if end >= 0 && at >= end {
+				e.Logger.WithFields(log.Fields{"at": at, "end": end}).Debug("Local: Hit iteration limit")
+				return nil
+			}
+		case <-iterShed:
+			engineOut <- stats.Sample{
+				Time:   time.Now(),
+				Metric: metrics.DroppedIterations,
+				Value:  1,
+				Tags:   guard.Tags(),
 			}
 
 			end := atomic.LoadInt64(&e.endIters)
@@ -314,6 +402,12 @@ func (e *Executor) Run(parent context.Context, engineOut chan<- stats.SampleCont
 				e.Logger.WithFields(log.Fields{"at": at, "end": end}).Debug("Local: Hit iteration limit")
 				return nil
 			}
+		case err := <-e.abort:
+			// The script aborted the test; stop it the same way as when it's cancelled, but
+			// pass on the reason.
+			e.Logger.WithError(err).Debug("Local: Aborted by the script")
+			cutoff = time.Now()
+			return err
 		case <-ctx.Done():
 			// If the test is cancelled, just set the cutoff point to now and proceed down the same
 			// logic as if the time limit was hit.
@@ -333,6 +427,8 @@ func (e *Executor) scale(ctx context.Context, num int64) error {
 	e.lock.RLock()
 	flow := e.flow
 	iterDone := e.iterDone
+	iterShed := e.iterShed
+	abort := e.abort
 	e.lock.RUnlock()
 
 	for i, handle := range e.vus {
@@ -343,7 +439,7 @@ func (e *Executor) scale(ctx context.Context, num int64) error {
 
 		if i < int(num) {
 			if cancel == nil {
-				vuctx, cancel := context.WithCancel(ctx)
+				vuctx, cancel := context.WithCancel(lib.WithExecutor(ctx, e))
 				handle.Lock()
 				handle.ctx = vuctx
 				handle.cancel = cancel
@@ -357,7 +453,7 @@ func (e *Executor) scale(ctx context.Context, num int64) error {
 
 				e.wg.Add(1)
 				go func() {
-					handle.run(e.Logger, flow, iterDone)
+					handle.run(e.Logger, flow, iterDone, iterShed, abort)
 					e.wg.Done()
 				}()
 			}
@@ -392,10 +488,14 @@ func (e *Executor) GetLogger() *log.Logger {
 }
 
 func (e *Executor) GetStages() []lib.Stage {
+	e.stagesLock.RLock()
+	defer e.stagesLock.RUnlock()
 	return e.stages
 }
 
 func (e *Executor) SetStages(s []lib.Stage) {
+	e.stagesLock.Lock()
+	defer e.stagesLock.Unlock()
 	e.stages = s
 }
 
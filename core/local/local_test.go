@@ -23,6 +23,7 @@ package local
 import (
 	"context"
 	"net"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -288,6 +289,148 @@ func TestExecutorEndIterations(t *testing.T) {
 	}
 }
 
+func TestExecutorVUContext(t *testing.T) {
+	var executors []lib.Executor
+	var mu sync.Mutex
+	e := New(&lib.MiniRunner{
+		Fn: func(ctx context.Context, out chan<- stats.SampleContainer) error {
+			mu.Lock()
+			executors = append(executors, lib.GetExecutor(ctx))
+			mu.Unlock()
+			return nil
+		},
+		SetupFn: func(ctx context.Context, out chan<- stats.SampleContainer) ([]byte, error) {
+			assert.Nil(t, lib.GetExecutor(ctx))
+			return nil, nil
+		},
+	})
+	assert.NoError(t, e.SetVUsMax(2))
+	assert.NoError(t, e.SetVUs(2))
+	e.SetEndIterations(null.IntFrom(10))
+	assert.NoError(t, e.Run(context.Background(), make(chan stats.SampleContainer, 100)))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.NotEmpty(t, executors)
+	for _, ex := range executors {
+		assert.Equal(t, e, ex)
+	}
+}
+
+func TestExecutorAbort(t *testing.T) {
+	var i int64
+	e := New(&lib.MiniRunner{Fn: func(ctx context.Context, out chan<- stats.SampleContainer) error {
+		if atomic.AddInt64(&i, 1) == 10 {
+			return lib.NewAbortError("enough", 42)
+		}
+		return nil
+	}})
+	assert.NoError(t, e.SetVUsMax(2))
+	assert.NoError(t, e.SetVUs(2))
+
+	samples := make(chan stats.SampleContainer, 1000)
+	err := e.Run(context.Background(), samples)
+	assert.Equal(t, lib.NewAbortError("enough", 42), err)
+	assert.True(t, e.GetIterations() < 10)
+}
+
+func TestExecutorAdaptiveRate(t *testing.T) {
+	var iterations int64
+	e := New(&lib.MiniRunner{
+		Fn: func(ctx context.Context, out chan<- stats.SampleContainer) error {
+			atomic.AddInt64(&iterations, 1)
+			out <- stats.Sample{Time: time.Now(), Metric: metrics.HTTPReqDuration, Value: 10}
+			return nil
+		},
+		Options: lib.Options{
+			MetricSamplesBufferSize: null.IntFrom(1000),
+			AdaptiveRate: &lib.AdaptiveRate{
+				StartRate: null.IntFrom(50),
+				Target:    types.NullDurationFrom(100 * time.Millisecond),
+				Interval:  types.NullDurationFrom(200 * time.Millisecond),
+			},
+		},
+	})
+	assert.NoError(t, e.SetVUsMax(10))
+	assert.NoError(t, e.SetVUs(10))
+	e.SetEndTime(types.NullDurationFrom(1 * time.Second))
+
+	samples := make(chan stats.SampleContainer, 10000)
+	assert.NoError(t, e.Run(context.Background(), samples))
+	close(samples)
+
+	// Without the rate, 10 VUs with instant iterations would run many thousands of them
+	n := atomic.LoadInt64(&iterations)
+	assert.True(t, n > 10 && n < 200, "unexpected number of iterations: %d", n)
+
+	var rates []float64
+	for container := range samples {
+		for _, sample := range container.GetSamples() {
+			if sample.Metric == metrics.AdaptiveRate {
+				rates = append(rates, sample.Value)
+			}
+		}
+	}
+	if assert.NotEmpty(t, rates) {
+		assert.Equal(t, float64(100), rates[0])
+	}
+}
+
+func TestExecutorResourceLimits(t *testing.T) {
+	newExecutor := func(policy string) (*Executor, *int64) {
+		var iterations int64
+		e := New(&lib.MiniRunner{
+			Fn: func(ctx context.Context, out chan<- stats.SampleContainer) error {
+				atomic.AddInt64(&iterations, 1)
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			},
+			Options: lib.Options{
+				MetricSamplesBufferSize: null.IntFrom(1000),
+				ResourceLimits: &lib.ResourceLimits{
+					// Always exceeded, but only noticed after the first check.
+					MaxMemory: null.StringFrom("1B"),
+					Policy:    null.StringFrom(policy),
+				},
+			},
+		})
+		assert.NoError(t, e.SetVUsMax(5))
+		assert.NoError(t, e.SetVUs(5))
+		e.SetEndTime(types.NullDurationFrom(500 * time.Millisecond))
+		return e, &iterations
+	}
+
+	t.Run("Shed", func(t *testing.T) {
+		e, iterations := newExecutor(lib.ResourcePolicyShed)
+		samples := make(chan stats.SampleContainer, 10000)
+		assert.NoError(t, e.Run(context.Background(), samples))
+		close(samples)
+
+		// Iterations only run until the first check, ~10 per VU
+		n := atomic.LoadInt64(iterations)
+		assert.True(t, n > 0 && n < 100, "unexpected number of iterations: %d", n)
+
+		var dropped int
+		for container := range samples {
+			for _, sample := range container.GetSamples() {
+				if sample.Metric == metrics.DroppedIterations {
+					dropped++
+					reason, _ := sample.Tags.Get("reason")
+					assert.Equal(t, "memory", reason)
+				}
+			}
+		}
+		// Every VU sheds one iteration per check, and there are ~4 checks left.
+		assert.True(t, dropped >= 5 && dropped <= 25, "unexpected number of dropped iterations: %d", dropped)
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		e, _ := newExecutor(lib.ResourcePolicyAbort)
+		samples := make(chan stats.SampleContainer, 10000)
+		assert.EqualError(t, e.Run(context.Background(), samples), "the memory resource limit was exceeded")
+	})
+}
+
 func TestExecutorIsRunning(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	e := New(nil)
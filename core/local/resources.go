@@ -0,0 +1,124 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package local
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+)
+
+// How often a resourceGuard checks usage; reading the memory stats stops the world, so it's
+// not done on every tick.
+const resourceCheckInterval = 100 * time.Millisecond
+
+// Sent to a VU instead of an iteration number, to make it skip the iteration.
+const shedIteration = -1
+
+// A resourceGuard checks the load generator's usage against ResourceLimits. While they're
+// exceeded, every VU may shed one iteration per check instead of running it.
+type resourceGuard struct {
+	limits    lib.ResourceLimits
+	maxMemory uint64
+
+	// Usage readers; the connection count is nil if the runner doesn't keep one.
+	memory func() uint64
+	conns  func() int64
+
+	elapsed time.Duration
+
+	// Why iterations are shed, or "" if usage is under the limits.
+	reason string
+	tags   *stats.SampleTags
+
+	// Iterations that may be shed until the next check.
+	budget int64
+}
+
+func newResourceGuard(limits lib.ResourceLimits, r lib.Runner) *resourceGuard {
+	g := &resourceGuard{
+		limits:    limits,
+		maxMemory: limits.GetMaxMemory(),
+		memory: func() uint64 {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			return m.HeapAlloc
+		},
+	}
+	if c, ok := r.(lib.ConnectionCounter); ok {
+		g.conns = c.OpenConnections
+	}
+	return g
+}
+
+// Advance moves the guard forward by d, and checks usage if it's time to. It returns whether a
+// limit started or stopped being exceeded.
+func (g *resourceGuard) Advance(d time.Duration, vus int64, tags *stats.SampleTags) bool {
+	g.elapsed += d
+	if g.elapsed < resourceCheckInterval {
+		return false
+	}
+	g.elapsed = 0
+
+	reason := ""
+	if g.maxMemory > 0 && g.memory() > g.maxMemory {
+		reason = "memory"
+	} else if g.conns != nil && g.limits.MaxConnections.Valid && g.conns() > g.limits.MaxConnections.Int64 {
+		reason = "connections"
+	}
+
+	g.budget = 0
+	if reason != "" {
+		g.budget = vus
+	}
+	if reason == g.reason {
+		return false
+	}
+	g.reason = reason
+	g.tags = nil
+	if reason != "" {
+		tagMap := tags.CloneTags()
+		tagMap["reason"] = reason
+		g.tags = stats.IntoSampleTags(&tagMap)
+	}
+	return true
+}
+
+// Exceeded returns which limit is exceeded, or "" if none is.
+func (g *resourceGuard) Exceeded() string {
+	return g.reason
+}
+
+// CanShed returns whether an iteration should be shed rather than started now.
+func (g *resourceGuard) CanShed() bool {
+	return g.budget > 0
+}
+
+// Shed records that an iteration has been shed.
+func (g *resourceGuard) Shed() {
+	g.budget--
+}
+
+// Tags returns the tags for dropped iterations, which include the reason.
+func (g *resourceGuard) Tags() *stats.SampleTags {
+	return g.tags
+}
@@ -0,0 +1,77 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package local
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestResourceGuard(t *testing.T) {
+	var memory uint64
+	var conns int64
+	g := newResourceGuard(lib.ResourceLimits{
+		MaxMemory:      null.StringFrom("1KB"),
+		MaxConnections: null.IntFrom(10),
+	}, nil)
+	g.memory = func() uint64 { return memory }
+	g.conns = func() int64 { return conns }
+	tags := stats.IntoSampleTags(&map[string]string{"env": "test"})
+
+	// Usage is only checked once per interval.
+	memory = 2000
+	assert.False(t, g.Advance(resourceCheckInterval/2, 3, tags))
+	assert.Equal(t, "", g.Exceeded())
+	assert.True(t, g.Advance(resourceCheckInterval/2, 3, tags))
+	assert.Equal(t, "memory", g.Exceeded())
+	assert.Equal(t, map[string]string{"env": "test", "reason": "memory"}, g.Tags().CloneTags())
+
+	// Every VU may shed one iteration per check.
+	for i := 0; i < 3; i++ {
+		assert.True(t, g.CanShed())
+		g.Shed()
+	}
+	assert.False(t, g.CanShed())
+	assert.False(t, g.Advance(resourceCheckInterval, 3, tags))
+	assert.True(t, g.CanShed())
+
+	memory = 0
+	conns = 11
+	assert.True(t, g.Advance(resourceCheckInterval, 3, tags))
+	assert.Equal(t, "connections", g.Exceeded())
+
+	conns = 10
+	assert.True(t, g.Advance(resourceCheckInterval, 3, tags))
+	assert.Equal(t, "", g.Exceeded())
+	assert.False(t, g.CanShed())
+	assert.Nil(t, g.Tags())
+}
+
+func TestResourceGuardNoConnectionCounter(t *testing.T) {
+	g := newResourceGuard(lib.ResourceLimits{MaxConnections: null.IntFrom(1)}, &lib.MiniRunner{})
+	assert.Nil(t, g.conns)
+	assert.False(t, g.Advance(time.Second, 1, nil))
+	assert.Equal(t, "", g.Exceeded())
+}
@@ -0,0 +1,195 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+)
+
+// OutputAggregationDelay is how long after the end of an aggregation window it's passed on to
+// the outputs, so that samples that were still buffered in VUs when it ended make it in.
+const OutputAggregationDelay = 1 * time.Second
+
+// The stats aggregated trend samples are turned into, tagged with "stat".
+var outputAggregationTrendStats = []string{"min", "max", "avg", "med", "p(90)", "p(95)", "p(99)"}
+
+// outputAggregator applies the outputAggregation option to the samples passed to collectors.
+type outputAggregator struct {
+	conf   lib.OutputAggregation
+	period time.Duration
+
+	// What to do with each metric, so the patterns don't have to be matched for every sample.
+	actions map[string]outputAggregationAction
+
+	// Windows that haven't been passed on yet, by start time, then by metric and tags.
+	windows map[int64]map[string]*outputAggregationBucket
+}
+
+type outputAggregationAction int
+
+const (
+	outputAggregationPass outputAggregationAction = iota
+	outputAggregationAggregate
+	outputAggregationDrop
+)
+
+type outputAggregationBucket struct {
+	metric *stats.Metric
+	tags   *stats.SampleTags
+	sink   stats.Sink
+}
+
+func newOutputAggregator(conf lib.OutputAggregation) *outputAggregator {
+	return &outputAggregator{
+		conf:    conf,
+		period:  conf.GetPeriod(),
+		actions: make(map[string]outputAggregationAction),
+		windows: make(map[int64]map[string]*outputAggregationBucket),
+	}
+}
+
+func (a *outputAggregator) action(metric string) outputAggregationAction {
+	action, ok := a.actions[metric]
+	if !ok {
+		switch {
+		case a.conf.ShouldDrop(metric):
+			action = outputAggregationDrop
+		case a.conf.ShouldAggregate(metric):
+			action = outputAggregationAggregate
+		default:
+			action = outputAggregationPass
+		}
+		a.actions[metric] = action
+	}
+	return action
+}
+
+// process takes the sample containers that would be passed to the collectors, and returns the
+// ones that should be instead: containers without any dropped or aggregated samples are passed
+// through as they are, the rest are replaced by their remaining samples, plus the samples of
+// all windows that ended at least OutputAggregationDelay before now.
+func (a *outputAggregator) process(containers []stats.SampleContainer, now time.Time) []stats.SampleContainer {
+	result := make([]stats.SampleContainer, 0, len(containers)+1)
+	for _, sc := range containers {
+		samples := sc.GetSamples()
+		var kept stats.Samples
+		for i, sample := range samples {
+			action := a.action(sample.Metric.Name)
+			if action == outputAggregationPass {
+				if kept != nil {
+					kept = append(kept, sample)
+				}
+				continue
+			}
+
+			if kept == nil {
+				kept = append(make(stats.Samples, 0, len(samples)), samples[:i]...)
+			}
+			if action == outputAggregationAggregate {
+				a.add(sample)
+			}
+		}
+
+		switch {
+		case kept == nil:
+			result = append(result, sc)
+		case len(kept) > 0:
+			result = append(result, kept)
+		}
+	}
+
+	if flushed := a.flush(now.Add(-OutputAggregationDelay)); len(flushed) > 0 {
+		result = append(result, flushed)
+	}
+	return result
+}
+
+func (a *outputAggregator) add(sample stats.Sample) {
+	start := sample.Time.Truncate(a.period).UnixNano()
+	buckets, ok := a.windows[start]
+	if !ok {
+		buckets = make(map[string]*outputAggregationBucket)
+		a.windows[start] = buckets
+	}
+
+	tagsKey, _ := sample.Tags.MarshalJSON()
+	key := sample.Metric.Name + string(tagsKey)
+	bucket, ok := buckets[key]
+	if !ok {
+		bucket = &outputAggregationBucket{metric: sample.Metric, tags: sample.Tags}
+		switch sample.Metric.Type {
+		case stats.Trend:
+			// Trends can have lots of samples per window, which histograms keep in bounded memory.
+			bucket.sink = &stats.HistogramSink{}
+		default:
+			bucket.sink = stats.New(sample.Metric.Name, sample.Metric.Type).Sink
+		}
+		buckets[key] = bucket
+	}
+	bucket.sink.Add(sample)
+}
+
+// flush returns the samples of all windows that ended before the given time, and forgets them.
+// A zero time flushes everything.
+func (a *outputAggregator) flush(before time.Time) stats.Samples {
+	var samples stats.Samples
+	for start, buckets := range a.windows {
+		t := time.Unix(0, start)
+		if !before.IsZero() && t.Add(a.period).After(before) {
+			continue
+		}
+		for _, bucket := range buckets {
+			samples = append(samples, bucket.samples(t)...)
+		}
+		delete(a.windows, start)
+	}
+	return samples
+}
+
+// samples turns the bucket into samples of its metric, timestamped with the window's start.
+func (b *outputAggregationBucket) samples(t time.Time) []stats.Sample {
+	switch sink := b.sink.(type) {
+	case *stats.CounterSink:
+		return []stats.Sample{{Metric: b.metric, Tags: b.tags, Time: t, Value: sink.Value}}
+	case *stats.GaugeSink:
+		return []stats.Sample{{Metric: b.metric, Tags: b.tags, Time: t, Value: sink.Value}}
+	case *stats.RateSink:
+		value := 0.0
+		if sink.Total > 0 {
+			value = float64(sink.Trues) / float64(sink.Total)
+		}
+		return []stats.Sample{{Metric: b.metric, Tags: b.tags, Time: t, Value: value}}
+	case *stats.HistogramSink:
+		values := sink.Format(0)
+		tags := b.tags.CloneTags()
+		samples := make([]stats.Sample, len(outputAggregationTrendStats))
+		for i, stat := range outputAggregationTrendStats {
+			tags["stat"] = stat
+			samples[i] = stats.Sample{Metric: b.metric, Tags: stats.NewSampleTags(tags), Time: t, Value: values[stat]}
+		}
+		return samples
+	default:
+		return nil
+	}
+}
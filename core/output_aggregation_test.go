@@ -0,0 +1,117 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputAggregator(t *testing.T) {
+	reqs := stats.New("http_reqs", stats.Counter)
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	failed := stats.New("failed", stats.Rate)
+	vus := stats.New("vus", stats.Gauge)
+	other := stats.New("other", stats.Counter)
+
+	a := newOutputAggregator(lib.OutputAggregation{OutputAggregationFields: lib.OutputAggregationFields{
+		Period:  types.NullDurationFrom(time.Second),
+		Metrics: []string{"http_*", "failed"},
+		Drop:    []string{"vus"},
+	}})
+
+	t0 := time.Unix(100, 0)
+	get := stats.NewSampleTags(map[string]string{"method": "GET"})
+	post := stats.NewSampleTags(map[string]string{"method": "POST"})
+	untouched := stats.Samples{{Metric: other, Time: t0, Value: 1}}
+	out := a.process([]stats.SampleContainer{
+		untouched,
+		stats.Samples{
+			{Metric: reqs, Time: t0, Tags: get, Value: 1},
+			{Metric: other, Time: t0, Value: 2},
+			{Metric: duration, Time: t0, Tags: get, Value: 100},
+			{Metric: vus, Time: t0, Value: 10},
+		},
+		stats.Samples{
+			{Metric: reqs, Time: t0.Add(100 * time.Millisecond), Tags: get, Value: 1},
+			{Metric: reqs, Time: t0.Add(200 * time.Millisecond), Tags: post, Value: 1},
+			{Metric: duration, Time: t0.Add(300 * time.Millisecond), Tags: get, Value: 300},
+			{Metric: failed, Time: t0.Add(300 * time.Millisecond), Value: 1},
+			{Metric: failed, Time: t0.Add(400 * time.Millisecond), Value: 0},
+			{Metric: reqs, Time: t0.Add(1500 * time.Millisecond), Tags: get, Value: 1},
+		},
+	}, t0.Add(1500*time.Millisecond))
+
+	// The window hasn't been over for OutputAggregationDelay yet, so only the passed through
+	// samples come out, and containers without aggregated samples aren't touched.
+	require.Len(t, out, 2)
+	assert.Equal(t, untouched, out[0])
+	assert.Equal(t, stats.Samples{{Metric: other, Time: t0, Value: 2}}, out[1])
+
+	out = a.process(nil, t0.Add(2*time.Second))
+	require.Len(t, out, 1)
+	values := make(map[string]float64)
+	for _, sample := range out[0].GetSamples() {
+		assert.Equal(t, t0, sample.Time)
+		key := sample.Metric.Name
+		if method, ok := sample.Tags.Get("method"); ok {
+			key += "," + method
+		}
+		if stat, ok := sample.Tags.Get("stat"); ok {
+			key += "," + stat
+		}
+		values[key] = sample.Value
+	}
+	assert.Equal(t, 2.0, values["http_reqs,GET"])
+	assert.Equal(t, 1.0, values["http_reqs,POST"])
+	assert.Equal(t, 0.5, values["failed"])
+	assert.Equal(t, 100.0, values["http_req_duration,GET,min"])
+	assert.Equal(t, 300.0, values["http_req_duration,GET,max"])
+	assert.Equal(t, 200.0, values["http_req_duration,GET,avg"])
+	assert.Len(t, values, 3+len(outputAggregationTrendStats))
+
+	samples := a.flush(time.Time{})
+	require.Len(t, samples, 1)
+	assert.Equal(t, t0.Add(time.Second), samples[0].Time)
+	assert.Equal(t, 1.0, samples[0].Value)
+	assert.Empty(t, a.windows)
+}
+
+func TestOutputAggregatorDropOnly(t *testing.T) {
+	vus := stats.New("vus", stats.Gauge)
+	reqs := stats.New("http_reqs", stats.Counter)
+
+	a := newOutputAggregator(lib.OutputAggregation{OutputAggregationFields: lib.OutputAggregationFields{
+		Drop: []string{"vus*"},
+	}})
+	out := a.process([]stats.SampleContainer{
+		stats.Samples{{Metric: vus, Value: 1}},
+		stats.Samples{{Metric: reqs, Value: 1}},
+	}, time.Now())
+	assert.Equal(t, []stats.SampleContainer{stats.Samples{{Metric: reqs, Value: 1}}}, out)
+	assert.Empty(t, a.windows)
+}
@@ -204,7 +204,7 @@ func (b *Bundle) Instantiate() (bi *BundleInstance, instErr error) {
 	// Instantiate the bundle into a new VM using a bound init context. This uses a context with a
 	// runtime, but no state, to allow module-provided types to function within the init context.
 	rt := goja.New()
-	init := newBoundInitContext(b.BaseInitContext, ctxPtr, rt)
+	init := newBoundInitContext(b.BaseInitContext, ctxPtr, rt, &b.Options)
 	if err := b.instantiate(rt, init); err != nil {
 		return nil, err
 	}
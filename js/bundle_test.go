@@ -21,6 +21,7 @@
 package js
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"testing"
@@ -31,6 +32,7 @@ import (
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v3"
 )
 
@@ -475,3 +477,137 @@ func TestBundleEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestBundleTypeScript(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/path/to/greet.ts", []byte(`
+		export interface Greeting { name: string; punctuation?: string }
+		export function greet({ name, punctuation = "!" }: Greeting): string {
+			return "hi " + name + punctuation;
+		}
+	`), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/path/to/names.mjs", []byte(`
+		export const first = (names) => names?.[0] ?? "nobody";
+	`), 0644))
+
+	b1, err := NewBundle(&lib.SourceData{
+		Filename: "/path/to/script.ts",
+		Data: []byte(`
+			import { greet, Greeting } from "./greet.ts";
+			import { first } from "./names.mjs";
+			export let options: { vus: number } = { vus: 3 };
+			export default function(): string {
+				const greeting: Greeting = { name: first(["k6"]) };
+				return greet(greeting);
+			}
+		`),
+	}, fs, lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, null.IntFrom(3), b1.Options.VUs)
+
+	// Archives keep the TypeScript sources, and compile them again.
+	arc := b1.makeArchive()
+	assert.Contains(t, string(arc.Scripts["/path/to/greet.ts"]), "punctuation?: string")
+	b2, err := NewBundleFromArchive(arc, lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	bundles := map[string]*Bundle{"Source": b1, "Archive": b2}
+	for name, b := range bundles {
+		t.Run(name, func(t *testing.T) {
+			bi, err := b.Instantiate()
+			if assert.NoError(t, err) {
+				v, err := bi.Default(goja.Undefined())
+				if assert.NoError(t, err) {
+					assert.Equal(t, "hi k6!", v.Export())
+				}
+			}
+		})
+	}
+}
+
+func TestBundleNPMPackages(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	files := map[string]string{
+		"/proj/package-lock.json": `{"lockfileVersion": 3, "packages": {
+			"node_modules/chunk": {"version": "1.0.0"},
+			"node_modules/schema": {"version": "2.1.0"}
+		}}`,
+		"/proj/node_modules/chunk/package.json": `{"name": "chunk", "version": "1.0.0", "main": "lib/chunk"}`,
+		"/proj/node_modules/chunk/lib/chunk.js": `
+			var size = require("./size");
+			module.exports = function chunk(list) {
+				var res = [];
+				for (var i = 0; i < list.length; i += size) { res.push(list.slice(i, i + size)); }
+				return res;
+			};`,
+		"/proj/node_modules/chunk/lib/size/index.js": `module.exports = 2;`,
+		"/proj/node_modules/schema/package.json": `{"name": "schema", "version": "2.1.0",
+			"exports": {".": {"import": "./index.mjs", "require": "./index.cjs"}}}`,
+		"/proj/node_modules/schema/index.cjs": `
+			class Schema {
+				#fields;
+				constructor(fields) { this.#fields = fields; }
+				parse(obj) { return Object.keys(this.#fields).every((k) => typeof obj?.[k] === this.#fields[k]); }
+			}
+			exports.object = (fields) => new Schema(fields ?? {});`,
+	}
+	for name, data := range files {
+		require.NoError(t, afero.WriteFile(fs, name, []byte(data), 0644))
+	}
+
+	b1, err := NewBundle(&lib.SourceData{
+		Filename: "/proj/tests/script.js",
+		Data: []byte(`
+			import chunk from "chunk";
+			import { object } from "schema";
+			const user = object({ name: "string", age: "number" });
+			export default function() {
+				if (!user.parse({ name: "k6", age: 3 }) || user.parse({ name: "k6" })) {
+					throw new Error("unexpected schema result");
+				}
+				return JSON.stringify(chunk([1, 2, 3]));
+			}
+		`),
+	}, fs, lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The archive has the package.json and lockfiles to resolve the packages the same way.
+	arc := b1.makeArchive()
+	assert.Contains(t, arc.Files, "/proj/node_modules/chunk/package.json")
+	assert.Contains(t, arc.Files, "/proj/package-lock.json")
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, arc.Write(buf))
+	arc, err = lib.ReadArchive(buf)
+	require.NoError(t, err)
+	b2, err := NewBundleFromArchive(arc, lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	bundles := map[string]*Bundle{"Source": b1, "Archive": b2}
+	for name, b := range bundles {
+		t.Run(name, func(t *testing.T) {
+			bi, err := b.Instantiate()
+			if assert.NoError(t, err) {
+				v, err := bi.Default(goja.Undefined())
+				if assert.NoError(t, err) {
+					assert.Equal(t, "[[1,2],[3]]", v.Export())
+				}
+			}
+		})
+	}
+
+	t.Run("Builtin", func(t *testing.T) {
+		_, err := NewBundle(&lib.SourceData{
+			Filename: "/proj/script.js",
+			Data:     []byte(`import fs from "fs"; export default function() {}`),
+		}, fs, lib.RuntimeOptions{})
+		assert.Contains(t, err.Error(), "'fs' is a Node.js built-in module, which isn't available in k6")
+	})
+}
@@ -68,22 +68,29 @@ func FieldName(t reflect.Type, f reflect.StructField) string {
 // if a methodName is the key of this map exactly than the value for the given key should be used as
 // the name of the method in js
 var methodNameExceptions map[string]string = map[string]string{
-	"JSON": "json",
-	"HTML": "html",
+	"JSON":        "json",
+	"HTML":        "html",
+	"JSONPath":    "jsonPath",
+	"JSONPathAll": "jsonPathAll",
+	"XPath":       "xpath",
+	"XPathAll":    "xpathAll",
+	"CSS":         "css",
+	"CSSAll":      "cssAll",
 }
 
 // Returns the JS name for an exported method. The first letter of the method's name is
 // lowercased, otherwise it is unaltered.
 func MethodName(t reflect.Type, m reflect.Method) string {
+	// Exceptions come first, since some of them, like XPath, begin with an X.
+	if exception, ok := methodNameExceptions[m.Name]; ok {
+		return exception
+	}
+
 	// A field with a name beginning with an X is a constructor, and just gets the prefix stripped.
 	// Note: They also get some special treatment from Bridge(), see further down.
 	if m.Name[0] == 'X' {
 		return m.Name[1:]
 	}
-
-	if exception, ok := methodNameExceptions[m.Name]; ok {
-		return exception
-	}
 	// Lowercase the first character of the method name.
 	return strings.ToLower(m.Name[0:1]) + m.Name[1:]
 }
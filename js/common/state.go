@@ -23,7 +23,7 @@ package common
 import (
 	"crypto/tls"
 	"net/http"
-	"net/http/cookiejar"
+	"time"
 
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/netext"
@@ -47,12 +47,27 @@ type State struct {
 	// Networking equipment.
 	Transport http.RoundTripper
 	Dialer    *netext.Dialer
-	CookieJar *cookiejar.Jar
+	CookieJar *netext.CookieJar
 	TLSConfig *tls.Config
 
+	// Caches HTTP responses if the httpCache option is set.
+	HTTPCache *netext.HTTPCache
+
+	// Limits the HTTP retries of the VU to a fraction of its requests.
+	HTTPRetryBudget *lib.HTTPRetryBudget
+
 	// Rate limits.
 	RPSLimit *rate.Limiter
 
+	// Records all HTTP requests and responses if the harOutput option is set.
+	HTTPRecorder *netext.Recorder
+
+	// Counts the connections and files the VU has open against its quota, if not nil.
+	Handles *lib.HandleCounter
+
+	// The faults injected into HTTP requests with the k6/chaos module, shared by all VUs.
+	Faults *lib.FaultInjector
+
 	// Sample channel, possibly buffered
 	Samples chan<- stats.SampleContainer
 
@@ -61,4 +76,7 @@ type State struct {
 	BPool *bpool.BufferPool
 
 	Vu, Iteration int64
+
+	// When the current iteration started, for pace().
+	IterationStart time.Time
 }
@@ -123,8 +123,16 @@ func (c *Compiler) Transform(src, filename string) (code string, srcmap SourceMa
 	return code, srcmap, nil
 }
 
-// Compiles the program, first trying ES5, then ES6.
+// Compiles the program, first trying ES5, then ES6. TypeScript and modern ES modules are
+// transformed with esbuild first.
 func (c *Compiler) Compile(src, filename string, pre, post string, strict bool) (*goja.Program, string, error) {
+	if needsESBuild(filename) {
+		code, err := transformESBuild(src, filename)
+		if err != nil {
+			return nil, src, err
+		}
+		src = code
+	}
 	return c.compile(src, filename, pre, post, strict, true)
 }
 
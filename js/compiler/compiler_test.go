@@ -24,7 +24,9 @@ import (
 	"testing"
 
 	"github.com/dop251/goja"
+	jslib "github.com/loadimpact/k6/js/lib"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNew(t *testing.T) {
@@ -159,4 +161,61 @@ func TestCompile(t *testing.T) {
 > 1 | 1+(=>2)()`)
 		})
 	})
+	t.Run("TypeScript", func(t *testing.T) {
+		src := `
+		interface Check { name: string; ok: boolean }
+		enum Level { Low = 1, High }
+		function count(checks: Check[], level: Level = Level.High): number {
+			return checks.filter((c) => c.ok).length * (level as number);
+		}
+		export const result: number = count([{ name: "a", ok: true }, { name: "b", ok: false }]);
+		`
+		pgm, _, err := c.Compile(src, "script.ts", "(function(exports){", "})", true)
+		if !assert.NoError(t, err) {
+			return
+		}
+		rt := goja.New()
+		v, err := rt.RunProgram(pgm)
+		if assert.NoError(t, err) {
+			fn, ok := goja.AssertFunction(v)
+			if assert.True(t, ok, "not a function") {
+				exports := rt.NewObject()
+				_, err := fn(goja.Undefined(), exports)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(2), exports.Get("result").Export())
+			}
+		}
+
+		t.Run("Invalid", func(t *testing.T) {
+			_, _, err := c.Compile("let a: number = ;", "script.ts", "", "", true)
+			assert.EqualError(t, err, `script.ts:1:17: Unexpected ";"`)
+		})
+	})
+	t.Run("ESM", func(t *testing.T) {
+		src := `
+		const config = { retries: { max: 3 } };
+		class Counter { count = 0; incr() { return ++this.count; } }
+		export const max = config?.retries?.max ?? 1;
+		export const total = { ...{ a: 1 }, b: new Counter().incr() };
+		`
+		pgm, _, err := c.Compile(src, "lib.mjs", "(function(exports){", "})", true)
+		if !assert.NoError(t, err) {
+			return
+		}
+		// The lowered syntax relies on ES2017 built-ins, which core-js provides in VUs.
+		rt := goja.New()
+		_, err = rt.RunProgram(jslib.GetCoreJS())
+		require.NoError(t, err)
+		v, err := rt.RunProgram(pgm)
+		if assert.NoError(t, err) {
+			fn, ok := goja.AssertFunction(v)
+			if assert.True(t, ok, "not a function") {
+				exports := rt.NewObject()
+				_, err := fn(goja.Undefined(), exports)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(3), exports.Get("max").Export())
+				assert.Equal(t, map[string]interface{}{"a": int64(1), "b": int64(1)}, exports.Get("total").Export())
+			}
+		}
+	})
 }
@@ -0,0 +1,81 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package compiler
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// esbuildLoaders are the esbuild loaders for the extensions of the files that are transformed
+// with esbuild before Babel: TypeScript, and modern ES modules with syntax Babel can't parse.
+var esbuildLoaders = map[string]api.Loader{
+	".ts":  api.LoaderTS,
+	".mts": api.LoaderTS,
+	".mjs": api.LoaderJS,
+}
+
+// needsESBuild returns whether a file is transformed with esbuild, based on its extension. The
+// files of npm packages always are, since they're often published with modern syntax.
+func needsESBuild(filename string) bool {
+	_, ok := esbuildLoaders[strings.ToLower(path.Ext(filename))]
+	return ok || strings.Contains(filepath.ToSlash(filename), "/node_modules/")
+}
+
+// transformESBuild strips the types from TypeScript and lowers modern syntax to ES2015, which
+// Babel can then compile to ES5. Import and export statements are kept as they are.
+func transformESBuild(src, filename string) (string, error) {
+	loader, ok := esbuildLoaders[strings.ToLower(path.Ext(filename))]
+	if !ok {
+		loader = api.LoaderJS
+	}
+
+	startTime := time.Now()
+	res := api.Transform(src, api.TransformOptions{
+		Loader:     loader,
+		Target:     api.ES2015,
+		Sourcefile: filename,
+		LogLevel:   api.LogLevelSilent,
+	})
+	if len(res.Errors) > 0 {
+		msgs := make([]string, len(res.Errors))
+		for i, msg := range res.Errors {
+			msgs[i] = formatESBuildMessage(msg)
+		}
+		return "", errors.New(strings.Join(msgs, "\n"))
+	}
+	log.WithField("t", time.Since(startTime)).Debug("esbuild: Transformed")
+	return string(res.Code), nil
+}
+
+func formatESBuildMessage(msg api.Message) string {
+	if msg.Location == nil {
+		return msg.Text
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", msg.Location.File, msg.Location.Line, msg.Location.Column+1, msg.Text)
+}
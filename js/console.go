@@ -22,21 +22,47 @@ package js
 
 import (
 	"context"
+	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 )
 
 // console represents a JS console implemented as a logrus.Logger.
 type console struct {
 	Logger *log.Logger
+
+	// Fields added to every message, from console.with().
+	fields log.Fields
+
+	// Limits the messages of all VUs if the consoleRateLimit option is set.
+	limiter *consoleLimiter
+
+	// Pushes the messages to Loki if the consoleOutput option is a Loki URL.
+	loki *lokiHook
+}
+
+// consoleLimiter drops the messages over a rate, and reports how many were dropped with the
+// next one that isn't.
+type consoleLimiter struct {
+	limiter *rate.Limiter
+	dropped int64
+}
+
+func newConsoleLimiter(perSecond int64) *consoleLimiter {
+	return &consoleLimiter{limiter: rate.NewLimiter(rate.Limit(perSecond), int(perSecond))}
 }
 
 // Creates a console with the standard logrus logger.
 func newConsole() *console {
-	return &console{log.StandardLogger()}
+	return &console{Logger: log.StandardLogger()}
 }
 
 // Creates a console logger with its output set to the file at the provided `filepath`.
@@ -49,7 +75,46 @@ func newFileConsole(filepath string) (*console, error) {
 	l := log.New()
 	l.SetOutput(f)
 
-	return &console{l}, nil
+	return &console{Logger: l}, nil
+}
+
+// newOutputConsole creates a console logger for the consoleOutput option: a file, which may be
+// given as "file=<path>", or a Loki instance, given as "loki=<url>" followed by its parameters.
+func newOutputConsole(output string) (*console, error) {
+	switch {
+	case strings.HasPrefix(output, "loki="):
+		hook, err := newLokiHook(strings.TrimPrefix(output, "loki="))
+		if err != nil {
+			return nil, err
+		}
+		l := log.New()
+		l.SetOutput(ioutil.Discard)
+		l.AddHook(hook)
+		return &console{Logger: l, loki: hook}, nil
+	case strings.HasPrefix(output, "file="):
+		return newFileConsole(strings.TrimPrefix(output, "file="))
+	default:
+		return newFileConsole(output)
+	}
+}
+
+// setLevel sets the least severe level of the messages that are logged. The standard logger is
+// shared with the rest of k6, so it's copied rather than changed.
+func (c *console) setLevel(level log.Level) {
+	if c.Logger == log.StandardLogger() {
+		l := log.New()
+		l.Out, l.Formatter, l.Hooks = c.Logger.Out, c.Logger.Formatter, c.Logger.Hooks
+		c.Logger = l
+	}
+	c.Logger.SetLevel(level)
+}
+
+// close pushes the messages that haven't been yet, if they go to Loki.
+func (c *console) close() {
+	if c.loki != nil {
+		c.loki.Close()
+		c.loki = nil
+	}
 }
 
 func (c console) log(ctx *context.Context, level log.Level, msgobj goja.Value, args ...goja.Value) {
@@ -61,11 +126,43 @@ func (c console) log(ctx *context.Context, level log.Level, msgobj goja.Value, a
 		}
 	}
 
-	fields := make(log.Fields)
+	if !c.Logger.IsLevelEnabled(level) {
+		return
+	}
+	if c.limiter != nil {
+		if !c.limiter.limiter.Allow() {
+			atomic.AddInt64(&c.limiter.dropped, 1)
+			return
+		}
+		if dropped := atomic.SwapInt64(&c.limiter.dropped, 0); dropped > 0 {
+			c.Logger.WithField("dropped", dropped).Warn("Console messages were dropped because of the consoleRateLimit option")
+		}
+	}
+
+	fields := make(log.Fields, len(c.fields)+len(args)+3)
+	for k, v := range c.fields {
+		fields[k] = lib.Secrets.RedactValue(v)
+	}
+	if ctx != nil && *ctx != nil {
+		if state := common.GetState(*ctx); state != nil {
+			fields["vu"] = state.Vu
+			fields["iter"] = state.Iteration
+			if state.Group != nil && state.Group.Path != "" {
+				fields["group"] = state.Group.Path
+			}
+		}
+	}
+	// Objects are logged as structured fields, everything else by its position.
 	for i, arg := range args {
-		fields[strconv.Itoa(i)] = arg.String()
+		if obj, ok := arg.Export().(map[string]interface{}); ok {
+			for k, v := range obj {
+				fields[k] = lib.Secrets.RedactValue(v)
+			}
+			continue
+		}
+		fields[strconv.Itoa(i)] = lib.Secrets.Redact(arg.String())
 	}
-	msg := msgobj.ToString()
+	msg := lib.Secrets.Redact(msgobj.String())
 	e := c.Logger.WithFields(fields)
 	switch level {
 	case log.DebugLevel:
@@ -98,3 +195,16 @@ func (c console) Warn(ctx *context.Context, msg goja.Value, args ...goja.Value)
 func (c console) Error(ctx *context.Context, msg goja.Value, args ...goja.Value) {
 	c.log(ctx, log.ErrorLevel, msg, args...)
 }
+
+// With returns a console that adds the given fields to every message.
+func (c console) With(ctxPtr *context.Context, fields map[string]interface{}) interface{} {
+	child := c
+	child.fields = make(log.Fields, len(c.fields)+len(fields))
+	for k, v := range c.fields {
+		child.fields[k] = v
+	}
+	for k, v := range fields {
+		child.fields[k] = v
+	}
+	return common.Bind(common.GetRuntime(*ctxPtr), &child, ctxPtr)
+}
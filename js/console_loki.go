@@ -0,0 +1,217 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	lokiPushPath           = "/loki/api/v1/push"
+	defaultLokiPushPeriod  = time.Second
+	maxLokiBufferedEntries = 10000
+)
+
+// lokiHook is a logrus hook that pushes the entries logged to Loki, with the "level" label and
+// any configured ones, every push period.
+type lokiHook struct {
+	url        string
+	labels     map[string]string
+	pushPeriod time.Duration
+	client     *http.Client
+	formatter  log.Formatter
+
+	mu      sync.Mutex
+	entries []lokiEntry
+	dropped int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type lokiEntry struct {
+	level string
+	time  time.Time
+	line  string
+}
+
+// newLokiHook parses a Loki console output, "<url>[,label.<name>=<value>...][,pushPeriod=<d>]",
+// and starts pushing the entries logged to it. A URL without a path pushes to the usual one.
+func newLokiHook(spec string) (*lokiHook, error) {
+	parts := strings.Split(spec, ",")
+	u, err := url.Parse(parts[0])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.Errorf("invalid Loki URL '%s'", parts[0])
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = lokiPushPath
+	}
+
+	h := &lokiHook{
+		url:        u.String(),
+		labels:     make(map[string]string),
+		pushPeriod: defaultLokiPushPeriod,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		formatter:  &log.TextFormatter{DisableColors: true, DisableTimestamp: true},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	for _, part := range parts[1:] {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid Loki console output parameter '%s', expected key=value", part)
+		}
+		switch key := kv[0]; {
+		case strings.HasPrefix(key, "label."):
+			h.labels[strings.TrimPrefix(key, "label.")] = kv[1]
+		case key == "pushPeriod":
+			d, err := time.ParseDuration(kv[1])
+			if err != nil || d <= 0 {
+				return nil, errors.Errorf("invalid Loki push period '%s'", kv[1])
+			}
+			h.pushPeriod = d
+		default:
+			return nil, errors.Errorf("unknown Loki console output parameter '%s'", key)
+		}
+	}
+
+	go h.run()
+	return h, nil
+}
+
+// Levels implements log.Hook.
+func (h *lokiHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements log.Hook. If Loki can't keep up, the oldest entries are dropped rather than
+// using up all memory.
+func (h *lokiHook) Fire(e *log.Entry) error {
+	line, err := h.formatter.Format(e)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.entries) >= maxLokiBufferedEntries {
+		h.entries = h.entries[1:]
+		h.dropped++
+	}
+	h.entries = append(h.entries, lokiEntry{
+		level: e.Level.String(),
+		time:  e.Time,
+		line:  strings.TrimSuffix(string(line), "\n"),
+	})
+	return nil
+}
+
+func (h *lokiHook) run() {
+	defer close(h.done)
+	ticker := time.NewTicker(h.pushPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.push()
+		case <-h.stop:
+			h.push()
+			return
+		}
+	}
+}
+
+// Close pushes the remaining entries and stops pushing.
+func (h *lokiHook) Close() {
+	close(h.stop)
+	<-h.done
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (h *lokiHook) push() {
+	h.mu.Lock()
+	entries, dropped := h.entries, h.dropped
+	h.entries, h.dropped = nil, 0
+	h.mu.Unlock()
+
+	if dropped > 0 {
+		log.WithField("dropped", dropped).Warn("Loki: Console messages were dropped because they couldn't be pushed fast enough")
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	// A stream per level, in a stable order.
+	streams := make(map[string]*lokiStream)
+	var levels []string
+	for _, e := range entries {
+		s, ok := streams[e.level]
+		if !ok {
+			labels := make(map[string]string, len(h.labels)+1)
+			for k, v := range h.labels {
+				labels[k] = v
+			}
+			labels["level"] = e.level
+			s = &lokiStream{Stream: labels}
+			streams[e.level] = s
+			levels = append(levels, e.level)
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(e.time.UnixNano(), 10), e.line})
+	}
+	sort.Strings(levels)
+	payload := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, level := range levels {
+		payload.Streams = append(payload.Streams, streams[level])
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.WithError(err).Error("Loki: Couldn't encode the console messages")
+		return
+	}
+	res, err := h.client.Post(h.url, "application/json", bytes.NewReader(body))
+	if err == nil {
+		_ = res.Body.Close()
+		if res.StatusCode/100 != 2 {
+			err = errors.Errorf("unexpected response status %s", res.Status)
+		}
+	}
+	if err != nil {
+		log.WithError(err).WithField("url", h.url).Error("Loki: Couldn't push the console messages")
+	}
+}
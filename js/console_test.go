@@ -22,9 +22,13 @@ package js
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 
 	null "gopkg.in/guregu/null.v3"
@@ -45,7 +49,7 @@ func TestConsoleContext(t *testing.T) {
 
 	ctxPtr := new(context.Context)
 	logger, hook := logtest.NewNullLogger()
-	rt.Set("console", common.Bind(rt, &console{logger}, ctxPtr))
+	rt.Set("console", common.Bind(rt, &console{Logger: logger}, ctxPtr))
 
 	_, err := common.RunString(rt, `console.log("a")`)
 	assert.NoError(t, err)
@@ -115,9 +119,10 @@ func TestConsole(t *testing.T) {
 						assert.Equal(t, level, entry.Level)
 						assert.Equal(t, result.Message, entry.Message)
 
-						data := result.Data
-						if data == nil {
-							data = make(log.Fields)
+						// Messages logged by VUs are tagged with them.
+						data := log.Fields{"vu": int64(0), "iter": int64(0)}
+						for k, v := range result.Data {
+							data[k] = v
 						}
 						assert.Equal(t, data, entry.Data)
 					}
@@ -212,9 +217,10 @@ func TestFileConsole(t *testing.T) {
 								assert.Equal(t, level, entry.Level)
 								assert.Equal(t, result.Message, entry.Message)
 
-								data := result.Data
-								if data == nil {
-									data = make(log.Fields)
+								// Messages logged by VUs are tagged with them.
+								data := log.Fields{"vu": int64(0), "iter": int64(0)}
+								for k, v := range result.Data {
+									data[k] = v
 								}
 								assert.Equal(t, data, entry.Data)
 
@@ -243,3 +249,164 @@ func TestFileConsole(t *testing.T) {
 		})
 	}
 }
+
+func TestConsoleStructured(t *testing.T) {
+	r, err := New(&lib.SourceData{
+		Filename: "/script",
+		Data: []byte(`
+			import { group } from "k6";
+			export default function() {
+				let logger = console.with({ service: "cart" });
+				group("checkout", function() {
+					logger.with({ step: 2 }).warn("slow", { duration: 1.5, user: "bob" }, "extra");
+				});
+			}
+		`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	vu, err := r.newVU(make(chan stats.SampleContainer, 100))
+	if !assert.NoError(t, err) {
+		return
+	}
+	logger, hook := logtest.NewNullLogger()
+	vu.Console.Logger = logger
+	assert.NoError(t, vu.RunOnce(context.Background()))
+
+	if entry := hook.LastEntry(); assert.NotNil(t, entry, "nothing logged") {
+		assert.Equal(t, log.WarnLevel, entry.Level)
+		assert.Equal(t, "slow", entry.Message)
+		assert.Equal(t, log.Fields{
+			"service":  "cart",
+			"step":     int64(2),
+			"duration": 1.5,
+			"user":     "bob",
+			"1":        "extra",
+			"vu":       int64(0),
+			"iter":     int64(0),
+			"group":    "::checkout",
+		}, entry.Data)
+	}
+}
+
+func TestConsoleLevelAndRateLimit(t *testing.T) {
+	r, err := New(&lib.SourceData{
+		Filename: "/script",
+		Data: []byte(`export default function() {
+			console.debug("debug");
+			for (let i = 0; i < 10; i++) { console.info("info " + i); }
+		}`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	logger, hook := logtest.NewNullLogger()
+	r.console = &console{Logger: logger}
+	assert.NoError(t, r.SetOptions(lib.Options{
+		ConsoleLevel:     null.StringFrom("debug"),
+		ConsoleRateLimit: null.IntFrom(3),
+	}))
+
+	vu, err := r.newVU(make(chan stats.SampleContainer, 100))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, vu.RunOnce(context.Background()))
+
+	var messages []string
+	for _, entry := range hook.AllEntries() {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"debug", "info 0", "info 1"}, messages)
+
+	assert.EqualError(t, r.SetOptions(lib.Options{ConsoleLevel: null.StringFrom("verbose")}),
+		"invalid console level 'verbose', use: 'debug', 'info', 'warn' or 'error'")
+}
+
+func TestLokiConsole(t *testing.T) {
+	var mu sync.Mutex
+	var pushes []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/loki/api/v1/push", req.URL.Path)
+		var push map[string]interface{}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&push))
+		mu.Lock()
+		pushes = append(pushes, push)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	r, err := New(&lib.SourceData{
+		Filename: "/script",
+		Data:     []byte(`export default function() { console.warn("oops", { code: 42 }); console.debug("hidden"); }`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, r.SetOptions(lib.Options{
+		ConsoleOutput: null.StringFrom("loki=" + srv.URL + ",label.app=shop,pushPeriod=1h"),
+	}))
+
+	vu, err := r.newVU(make(chan stats.SampleContainer, 100))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, vu.RunOnce(context.Background()))
+	r.CloseConsole()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, pushes, 1) {
+		streams := pushes[0]["streams"].([]interface{})
+		if assert.Len(t, streams, 1) {
+			stream := streams[0].(map[string]interface{})
+			assert.Equal(t, map[string]interface{}{"app": "shop", "level": "warning"}, stream["stream"])
+			values := stream["values"].([]interface{})
+			if assert.Len(t, values, 1) {
+				assert.Equal(t, `level=warning msg=oops code=42 iter=0 vu=0`, values[0].([]interface{})[1])
+			}
+		}
+	}
+
+	for spec, msg := range map[string]string{
+		"loki=localhost:3100":                       "invalid Loki URL 'localhost:3100'",
+		"loki=http://localhost:3100,pushPeriod=-1s": "invalid Loki push period '-1s'",
+		"loki=http://localhost:3100,tenant=a":       "unknown Loki console output parameter 'tenant'",
+	} {
+		assert.EqualError(t, r.SetOptions(lib.Options{ConsoleOutput: null.StringFrom(spec)}), msg)
+	}
+}
+
+func TestConsoleSecrets(t *testing.T) {
+	defer lib.Secrets.Reset()
+	r, err := New(&lib.SourceData{
+		Filename: "/script",
+		Data: []byte(`
+			import { register } from "k6/secrets";
+			let token = register("console-s3cr3t");
+			export default function() {
+				console.log("token: " + token, { auth: { token: token } }, token);
+			}
+		`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	vu, err := r.newVU(make(chan stats.SampleContainer, 100))
+	if !assert.NoError(t, err) {
+		return
+	}
+	logger, hook := logtest.NewNullLogger()
+	vu.Console.Logger = logger
+	assert.NoError(t, vu.RunOnce(context.Background()))
+
+	if entry := hook.LastEntry(); assert.NotNil(t, entry, "nothing logged") {
+		assert.Equal(t, "token: [SECRET]", entry.Message)
+		assert.Equal(t, map[string]interface{}{"token": "[SECRET]"}, entry.Data["auth"])
+		assert.Equal(t, "[SECRET]", entry.Data["1"])
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// hasThreadCPUClock is whether the maxIterationCPU VU quota can be used.
+const hasThreadCPUClock = true
+
+// Flags of a clock ID that refers to the CPU time of a thread; see clock_getcpuclockid(3).
+const (
+	cpuClockPerThread = 4
+	cpuClockSched     = 2
+)
+
+// lockThreadCPUClock locks the calling goroutine to its thread, and returns a function that reads
+// the CPU time the thread has used, which other goroutines may call, and one that unlocks it.
+func lockThreadCPUClock() (func() time.Duration, func()) {
+	runtime.LockOSThread()
+	clockID := int32(^unix.Gettid()<<3 | cpuClockPerThread | cpuClockSched)
+	clock := func() time.Duration {
+		var ts unix.Timespec
+		if err := unix.ClockGettime(clockID, &ts); err != nil {
+			return 0
+		}
+		return time.Duration(ts.Nano())
+	}
+	return clock, runtime.UnlockOSThread
+}
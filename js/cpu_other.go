@@ -0,0 +1,34 @@
+// +build !linux
+
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import "time"
+
+// hasThreadCPUClock is whether the maxIterationCPU VU quota can be used.
+const hasThreadCPUClock = false
+
+// lockThreadCPUClock isn't implemented outside of Linux, where there's no way to read the CPU
+// time of another thread without cgo; the maxIterationCPU VU quota can't be used there.
+func lockThreadCPUClock() (func() time.Duration, func()) {
+	return nil, func() {}
+}
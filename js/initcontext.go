@@ -22,13 +22,16 @@ package js
 
 import (
 	"context"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
 	"github.com/loadimpact/k6/js/compiler"
 	"github.com/loadimpact/k6/js/modules"
+	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/loader"
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
@@ -55,9 +58,20 @@ type InitContext struct {
 	// Cache of loaded programs and files.
 	programs map[string]programWithSource
 	files    map[string][]byte
+
+	// Instances of the built-in modules, which all VUs of the bundle share, and the function that
+	// releases them.
+	modules      map[string]interface{}
+	closeModules func()
+
+	// Options of the test, which restrict the built-in modules that can be imported, if set. The
+	// base context runs before they're known, so it keeps the ones imported for them to check.
+	options        *lib.Options
+	builtinImports map[string]bool
 }
 
 func NewInitContext(rt *goja.Runtime, compiler *compiler.Compiler, ctxPtr *context.Context, fs afero.Fs, pwd string) *InitContext {
+	mods, closeModules := modules.New()
 	return &InitContext{
 		runtime:  rt,
 		compiler: compiler,
@@ -65,15 +79,21 @@ func NewInitContext(rt *goja.Runtime, compiler *compiler.Compiler, ctxPtr *conte
 		fs:       fs,
 		pwd:      filepath.ToSlash(pwd),
 
-		programs: make(map[string]programWithSource),
-		files:    make(map[string][]byte),
+		programs:       make(map[string]programWithSource),
+		files:          make(map[string][]byte),
+		modules:        mods,
+		closeModules:   closeModules,
+		builtinImports: make(map[string]bool),
 	}
 }
 
-func newBoundInitContext(base *InitContext, ctxPtr *context.Context, rt *goja.Runtime) *InitContext {
+func newBoundInitContext(
+	base *InitContext, ctxPtr *context.Context, rt *goja.Runtime, options *lib.Options,
+) *InitContext {
 	return &InitContext{
 		runtime: rt,
 		ctxPtr:  ctxPtr,
+		options: options,
 
 		fs:       base.fs,
 		pwd:      base.pwd,
@@ -81,6 +101,7 @@ func newBoundInitContext(base *InitContext, ctxPtr *context.Context, rt *goja.Ru
 
 		programs: base.programs,
 		files:    base.files,
+		modules:  base.modules,
 	}
 }
 
@@ -105,16 +126,48 @@ func (i *InitContext) Require(arg string) goja.Value {
 }
 
 func (i *InitContext) requireModule(name string) (goja.Value, error) {
-	mod, ok := modules.Index[name]
+	mod, ok := i.modules[name]
 	if !ok {
 		return nil, errors.Errorf("unknown builtin module: %s", name)
 	}
+	if i.options != nil {
+		if err := lib.CheckModule(i.options.AllowedModules, i.options.BlockedModules, name); err != nil {
+			return nil, err
+		}
+	} else {
+		i.builtinImports[name] = true
+	}
 	return i.runtime.ToValue(common.Bind(i.runtime, mod, i.ctxPtr)), nil
 }
 
+// checkBuiltinImports returns an error if the options don't allow one of the built-in modules
+// imported by the base context.
+func (i *InitContext) checkBuiltinImports(opts lib.Options) error {
+	names := make([]string, 0, len(i.builtinImports))
+	for name := range i.builtinImports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := lib.CheckModule(opts.AllowedModules, opts.BlockedModules, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (i *InitContext) requireFile(name string) (goja.Value, error) {
-	// Resolve the file path, push the target directory as pwd to make relative imports work.
+	// npm packages are resolved to the files in node_modules first.
 	pwd := i.pwd
+	resolved, err := loader.ResolvePackage(i.fs, i.readPackageFile, pwd, name)
+	if err != nil {
+		return goja.Undefined(), err
+	}
+	if resolved != "" {
+		name = resolved
+	}
+
+	// Resolve the file path, push the target directory as pwd to make relative imports work.
 	filename := loader.Resolve(pwd, name)
 	i.pwd = loader.Dir(filename)
 	defer func() { i.pwd = pwd }()
@@ -159,6 +212,20 @@ func (i *InitContext) requireFile(name string) (goja.Value, error) {
 	return module.Get("exports"), nil
 }
 
+// readPackageFile reads the package.json and lockfiles of npm packages like open() does, so
+// that archives have them to resolve the packages the same way.
+func (i *InitContext) readPackageFile(filename string) ([]byte, error) {
+	if data, ok := i.files[filename]; ok {
+		return data, nil
+	}
+	data, err := afero.ReadFile(i.fs, filename)
+	if err != nil {
+		return nil, err
+	}
+	i.files[filename] = data
+	return data, nil
+}
+
 func (i *InitContext) compileImport(src, filename string) (*goja.Program, error) {
 	pgm, _, err := i.compiler.Compile(src, filename, "(function(){\n", "\n})()\n", true)
 	return pgm, err
@@ -181,3 +248,39 @@ func (i *InitContext) Open(name string, args ...string) (goja.Value, error) {
 	}
 	return i.runtime.ToValue(string(data)), nil
 }
+
+// loadAssets reads the files matching glob patterns, relative to the script, into the cache of
+// opened files, so that they're bundled in archives whether the script happens to open() them
+// or not. Every pattern has to match at least one file.
+func (i *InitContext) loadAssets(patterns []string) error {
+	for _, pattern := range patterns {
+		if !path.IsAbs(filepath.ToSlash(pattern)) {
+			pattern = path.Join(i.pwd, filepath.ToSlash(pattern))
+		}
+		matches, err := afero.Glob(i.fs, filepath.FromSlash(pattern))
+		if err != nil {
+			return errors.Wrapf(err, "invalid asset pattern '%s'", pattern)
+		}
+		found := false
+		for _, match := range matches {
+			filename := filepath.ToSlash(match)
+			if _, ok := i.files[filename]; ok {
+				found = true
+				continue
+			}
+			if fi, err := i.fs.Stat(match); err != nil || fi.IsDir() {
+				continue
+			}
+			data, err := afero.ReadFile(i.fs, match)
+			if err != nil {
+				return err
+			}
+			i.files[filename] = data
+			found = true
+		}
+		if !found {
+			return errors.Errorf("the asset pattern '%s' doesn't match any files", pattern)
+		}
+	}
+	return nil
+}
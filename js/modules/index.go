@@ -22,21 +22,78 @@ package modules
 
 import (
 	"github.com/loadimpact/k6/js/modules/k6"
+	"github.com/loadimpact/k6/js/modules/k6/amqp"
+	"github.com/loadimpact/k6/js/modules/k6/browser"
+	"github.com/loadimpact/k6/js/modules/k6/chaos"
+	"github.com/loadimpact/k6/js/modules/k6/coap"
 	"github.com/loadimpact/k6/js/modules/k6/crypto"
+	"github.com/loadimpact/k6/js/modules/k6/crypto/openpgp"
+	"github.com/loadimpact/k6/js/modules/k6/crypto/ssh"
+	"github.com/loadimpact/k6/js/modules/k6/data"
 	"github.com/loadimpact/k6/js/modules/k6/encoding"
+	"github.com/loadimpact/k6/js/modules/k6/execution"
+	"github.com/loadimpact/k6/js/modules/k6/expect"
+	"github.com/loadimpact/k6/js/modules/k6/fs"
 	"github.com/loadimpact/k6/js/modules/k6/html"
 	"github.com/loadimpact/k6/js/modules/k6/http"
+	"github.com/loadimpact/k6/js/modules/k6/kv"
+	"github.com/loadimpact/k6/js/modules/k6/ldap"
 	"github.com/loadimpact/k6/js/modules/k6/metrics"
+	"github.com/loadimpact/k6/js/modules/k6/mqtt"
+	"github.com/loadimpact/k6/js/modules/k6/net"
+	"github.com/loadimpact/k6/js/modules/k6/secrets"
+	"github.com/loadimpact/k6/js/modules/k6/smtp"
+	"github.com/loadimpact/k6/js/modules/k6/sql"
+	"github.com/loadimpact/k6/js/modules/k6/sync"
+	"github.com/loadimpact/k6/js/modules/k6/time"
 	"github.com/loadimpact/k6/js/modules/k6/ws"
+	"github.com/loadimpact/k6/js/modules/k6/xml"
 )
 
-// Index of module implementations.
-var Index = map[string]interface{}{
-	"k6":          k6.New(),
-	"k6/crypto":   crypto.New(),
-	"k6/encoding": encoding.New(),
-	"k6/http":     http.New(),
-	"k6/metrics":  metrics.New(),
-	"k6/html":     html.New(),
-	"k6/ws":       ws.New(),
+// New returns new instances of the module implementations, for the VUs of one test to share,
+// and a function that releases what they hold on to once the test is over. Modules with state,
+// eg. the primitives of k6/sync or the feeds of k6/data, keep it in their instance, so that
+// tests running in the same process, one after another or at the same time, don't share it.
+// The modules registered by extensions are the exception: there's only one instance of them.
+func New() (map[string]interface{}, func()) {
+	kvModule := kv.New()
+	secretsModule := secrets.New()
+	index := map[string]interface{}{
+		"k6":                k6.New(),
+		"k6/amqp":           amqp.New(),
+		"k6/browser":        browser.New(),
+		"k6/chaos":          chaos.New(),
+		"k6/coap":           coap.New(),
+		"k6/crypto":         crypto.New(),
+		"k6/crypto/openpgp": openpgp.New(),
+		"k6/crypto/ssh":     ssh.New(),
+		"k6/data":           data.New(),
+		"k6/encoding":       encoding.New(),
+		"k6/execution":      execution.New(),
+		"k6/expect":         expect.New(),
+		"k6/fs":             fs.New(),
+		"k6/http":           http.New(),
+		"k6/metrics":        metrics.New(),
+		"k6/mqtt":           mqtt.New(),
+		"k6/html":           html.New(),
+		"k6/kv":             kvModule,
+		"k6/ldap":           ldap.New(),
+		"k6/net":            net.New(),
+		"k6/secrets":        secretsModule,
+		"k6/smtp":           smtp.New(),
+		"k6/sql":            sql.New(),
+		"k6/sync":           sync.New(),
+		"k6/time":           time.New(),
+		"k6/ws":             ws.New(),
+		"k6/xml":            xml.New(),
+	}
+	mu.Lock()
+	for name, mod := range extensions {
+		index[name] = mod
+	}
+	mu.Unlock()
+	return index, func() {
+		kv.Close(kvModule)
+		secrets.Close(secretsModule)
+	}
 }
@@ -0,0 +1,691 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package amqp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	goamqp "github.com/streadway/amqp"
+)
+
+// ErrAMQPInInitContext is returned when connecting to a broker from the init context
+var ErrAMQPInInitContext = common.NewInitContextError("using AMQP in the init context is not supported")
+
+// How long connecting, including the TLS and AMQP handshakes, may take if the script doesn't say.
+const defaultConnectTimeout = 30 * time.Second
+
+type AMQP struct{}
+
+// A Queue is a declared queue.
+type Queue struct {
+	Name      string `js:"name"`
+	Messages  int    `js:"messages"`
+	Consumers int    `js:"consumers"`
+}
+
+// A Message is a message delivered by the broker, to a consumer or by get().
+type Message struct {
+	Body          string                 `js:"body"`
+	Exchange      string                 `js:"exchange"`
+	RoutingKey    string                 `js:"routingKey"`
+	Headers       map[string]interface{} `js:"headers"`
+	ContentType   string                 `js:"contentType"`
+	CorrelationID string                 `js:"correlationId"`
+	ReplyTo       string                 `js:"replyTo"`
+	MessageID     string                 `js:"messageId"`
+	Redelivered   bool                   `js:"redelivered"`
+
+	delivery goamqp.Delivery
+}
+
+type connectParams struct {
+	heartbeat time.Duration
+	confirms  bool
+	cert      string
+	key       string
+	ca        string
+	timeout   time.Duration
+	tags      map[string]string
+}
+
+// Conn is a connection to a broker opened by amqp.connect(); it's only valid inside the
+// callback. All of its operations are done on one channel.
+type Conn struct {
+	ctx     context.Context
+	rt      *goja.Runtime
+	state   *common.State
+	tags    *stats.SampleTags
+	conn    *goamqp.Connection
+	ch      *goamqp.Channel
+	timeout time.Duration
+
+	// Publisher confirms, if they're enabled.
+	confirms chan goamqp.Confirmation
+
+	mu sync.Mutex
+	// Messages delivered to the consumers, but not yet returned by receive().
+	messages []*Message
+	arrived  chan struct{}
+	// Closed when the channel is closed, by the script or the broker.
+	done chan struct{}
+	err  error
+}
+
+func New() *AMQP {
+	return &AMQP{}
+}
+
+// Connect connects to the broker at the given amqp:// or amqps:// URL, passes the connection to
+// the given function, and closes it when the function returns. It returns whatever the function
+// returns.
+func (*AMQP) Connect(ctx context.Context, url string, args ...goja.Value) (goja.Value, error) {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, ErrAMQPInInitContext
+	}
+
+	// The params argument is optional
+	var callableV, paramsV goja.Value
+	switch len(args) {
+	case 2:
+		paramsV = args[0]
+		callableV = args[1]
+	case 1:
+		paramsV = goja.Undefined()
+		callableV = args[0]
+	default:
+		return nil, errors.New("invalid number of arguments to amqp.connect")
+	}
+
+	fn, isFunc := goja.AssertFunction(callableV)
+	if !isFunc {
+		return nil, errors.New("last argument to amqp.connect must be a function")
+	}
+
+	params, err := parseConnectParams(rt, paramsV, state)
+	if err != nil {
+		return nil, err
+	}
+	if state.Options.SystemTags["group"] {
+		params.tags["group"] = state.Group.Path
+	}
+
+	start := time.Now()
+	c, err := dial(ctx, url, params)
+	if err != nil {
+		return nil, err
+	}
+	connectionEnd := time.Now()
+	c.tags = stats.IntoSampleTags(&params.tags)
+
+	// Unblock any pending operations if the VU is interrupted.
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.conn.Close()
+		case <-c.done:
+		}
+	}()
+
+	ret, fnErr := fn(goja.Undefined(), rt.ToValue(c))
+	_ = c.conn.Close()
+
+	end := time.Now()
+	stats.PushIfNotCancelled(ctx, state.Samples, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Metric: metrics.AMQPSessions, Time: start, Tags: c.tags, Value: 1},
+			{Metric: metrics.AMQPConnecting, Time: start, Tags: c.tags, Value: stats.D(connectionEnd.Sub(start))},
+			{Metric: metrics.AMQPSessionDuration, Time: start, Tags: c.tags, Value: stats.D(end.Sub(start))},
+		},
+		Tags: c.tags,
+		Time: start,
+	})
+
+	if fnErr != nil {
+		return nil, fnErr
+	}
+	return ret, nil
+}
+
+func parseConnectParams(rt *goja.Runtime, paramsV goja.Value, state *common.State) (*connectParams, error) {
+	params := &connectParams{tags: state.Options.RunTags.CloneTags()}
+	if goja.IsUndefined(paramsV) || goja.IsNull(paramsV) {
+		return params, nil
+	}
+	paramsObj := paramsV.ToObject(rt)
+	for _, k := range paramsObj.Keys() {
+		v := paramsObj.Get(k)
+		if goja.IsUndefined(v) || goja.IsNull(v) {
+			continue
+		}
+		switch k {
+		case "heartbeat":
+			params.heartbeat = time.Duration(v.ToFloat() * float64(time.Millisecond))
+		case "confirms":
+			params.confirms = v.ToBoolean()
+		case "tls":
+			// The x509 material to use instead of the test's, for amqps:// URLs.
+			tlsObj := v.ToObject(rt)
+			for _, key := range tlsObj.Keys() {
+				switch key {
+				case "cert":
+					params.cert = tlsObj.Get(key).String()
+				case "key":
+					params.key = tlsObj.Get(key).String()
+				case "ca":
+					params.ca = tlsObj.Get(key).String()
+				}
+			}
+		case "timeout":
+			params.timeout = time.Duration(v.ToFloat() * float64(time.Millisecond))
+		case "tags":
+			tagObj := v.ToObject(rt)
+			if tagObj == nil {
+				continue
+			}
+			for _, key := range tagObj.Keys() {
+				params.tags[key] = tagObj.Get(key).String()
+			}
+		}
+	}
+	if (params.cert == "") != (params.key == "") {
+		return nil, errors.New("a client certificate needs both a cert and a key")
+	}
+	return params, nil
+}
+
+// dial connects to the broker and opens the channel.
+func dial(ctx context.Context, url string, params *connectParams) (*Conn, error) {
+	state := common.GetState(ctx)
+	uri, err := goamqp.ParseURI(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+	if state.TLSConfig != nil {
+		tlsConfig = state.TLSConfig.Clone()
+		tlsConfig.NextProtos = nil
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = uri.Host
+	}
+	if params.cert != "" {
+		cert, err := tls.X509KeyPair([]byte(params.cert), []byte(params.key))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		tlsConfig.GetClientCertificate = nil
+	}
+	if params.ca != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(params.ca)) {
+			return nil, errors.New("the ca doesn't contain any valid PEM-encoded certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	connectTimeout := params.timeout
+	if connectTimeout <= 0 {
+		connectTimeout = defaultConnectTimeout
+	}
+	conn, err := goamqp.DialConfig(url, goamqp.Config{
+		Heartbeat:       params.heartbeat,
+		TLSClientConfig: tlsConfig,
+		Dial: func(network, addr string) (net.Conn, error) {
+			dialCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+			defer cancel()
+			conn, err := state.Dialer.DialContext(dialCtx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			// The library clears the deadline once the AMQP handshake is done.
+			_ = conn.SetDeadline(time.Now().Add(connectTimeout))
+			return conn, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	c := &Conn{
+		ctx:     ctx,
+		rt:      common.GetRuntime(ctx),
+		state:   state,
+		conn:    conn,
+		ch:      ch,
+		timeout: params.timeout,
+		arrived: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if params.confirms {
+		if err := ch.Confirm(false); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		c.confirms = ch.NotifyPublish(make(chan goamqp.Confirmation, 1))
+	}
+
+	closed := ch.NotifyClose(make(chan *goamqp.Error, 1))
+	go func() {
+		if err, ok := <-closed; ok && err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+		}
+		close(c.done)
+	}()
+	return c, nil
+}
+
+// DeclareQueue declares a queue, creating it if it doesn't exist, and returns its name, which
+// the broker picks if it's empty, and how many messages and consumers it has.
+func (c *Conn) DeclareQueue(name string, paramsV goja.Value) (*Queue, error) {
+	var durable, autoDelete, exclusive, passive bool
+	var args goamqp.Table
+	err := c.eachParam(paramsV, func(k string, v goja.Value) (err error) {
+		switch k {
+		case "durable":
+			durable = v.ToBoolean()
+		case "autoDelete":
+			autoDelete = v.ToBoolean()
+		case "exclusive":
+			exclusive = v.ToBoolean()
+		case "passive":
+			passive = v.ToBoolean()
+		case "arguments":
+			args, err = toTable(v.Export())
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	declare := c.ch.QueueDeclare
+	if passive {
+		declare = c.ch.QueueDeclarePassive
+	}
+	q, err := declare(name, durable, autoDelete, exclusive, false, args)
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{Name: q.Name, Messages: q.Messages, Consumers: q.Consumers}, nil
+}
+
+// DeclareExchange declares an exchange of the given kind, eg. "direct", "fanout" or "topic",
+// creating it if it doesn't exist.
+func (c *Conn) DeclareExchange(name, kind string, paramsV goja.Value) error {
+	var durable, autoDelete, internal bool
+	var args goamqp.Table
+	err := c.eachParam(paramsV, func(k string, v goja.Value) (err error) {
+		switch k {
+		case "durable":
+			durable = v.ToBoolean()
+		case "autoDelete":
+			autoDelete = v.ToBoolean()
+		case "internal":
+			internal = v.ToBoolean()
+		case "arguments":
+			args, err = toTable(v.Export())
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return c.ch.ExchangeDeclare(name, kind, durable, autoDelete, internal, false, args)
+}
+
+// BindQueue routes the messages published to the exchange with the routing key to the queue.
+func (c *Conn) BindQueue(queue, exchange, routingKey string) error {
+	return c.ch.QueueBind(queue, routingKey, exchange, false, nil)
+}
+
+// Publish publishes a message, a string or an array of bytes, to the exchange with the routing
+// key. If publisher confirms are enabled, it waits until the broker has confirmed it, and throws
+// if the broker rejects it.
+func (c *Conn) Publish(exchange, routingKey string, body goja.Value, paramsV goja.Value) error {
+	data, err := toBytes(body)
+	if err != nil {
+		return err
+	}
+	msg := goamqp.Publishing{Body: data}
+	err = c.eachParam(paramsV, func(k string, v goja.Value) (err error) {
+		switch k {
+		case "contentType":
+			msg.ContentType = v.String()
+		case "contentEncoding":
+			msg.ContentEncoding = v.String()
+		case "headers":
+			msg.Headers, err = toTable(v.Export())
+		case "persistent":
+			if v.ToBoolean() {
+				msg.DeliveryMode = goamqp.Persistent
+			}
+		case "priority":
+			msg.Priority = uint8(v.ToInteger())
+		case "correlationId":
+			msg.CorrelationId = v.String()
+		case "replyTo":
+			msg.ReplyTo = v.String()
+		case "messageId":
+			msg.MessageId = v.String()
+		case "expiration":
+			// In milliseconds, as a string on the wire.
+			msg.Expiration = strconv.FormatInt(v.ToInteger(), 10)
+		case "type":
+			msg.Type = v.String()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err := c.ch.Publish(exchange, routingKey, false, false, msg); err != nil {
+		return err
+	}
+	if c.confirms != nil {
+		var expired <-chan time.Time
+		if c.timeout > 0 {
+			t := time.NewTimer(c.timeout)
+			defer t.Stop()
+			expired = t.C
+		}
+		select {
+		case confirmation, ok := <-c.confirms:
+			if !ok {
+				return c.closedError()
+			}
+			if !confirmation.Ack {
+				return errors.New("amqp: the broker rejected the message")
+			}
+		case <-expired:
+			return errors.New("amqp: timed out waiting for the broker to confirm the message")
+		}
+	}
+	end := time.Now()
+
+	stats.PushIfNotCancelled(c.ctx, c.state.Samples, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Metric: metrics.AMQPMessagesPublished, Time: start, Tags: c.tags, Value: 1},
+			{Metric: metrics.AMQPPublishDuration, Time: start, Tags: c.tags, Value: stats.D(end.Sub(start))},
+		},
+		Tags: c.tags,
+		Time: start,
+	})
+	return nil
+}
+
+// Consume starts consuming the queue, and returns the consumer tag. The messages are returned by
+// receive(); unless autoAck is set, each of them has to be acknowledged.
+func (c *Conn) Consume(queue string, paramsV goja.Value) (string, error) {
+	var autoAck, exclusive bool
+	var tag string
+	prefetch := -1
+	err := c.eachParam(paramsV, func(k string, v goja.Value) error {
+		switch k {
+		case "autoAck":
+			autoAck = v.ToBoolean()
+		case "exclusive":
+			exclusive = v.ToBoolean()
+		case "consumerTag":
+			tag = v.String()
+		case "prefetch":
+			prefetch = int(v.ToInteger())
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if prefetch >= 0 {
+		if err := c.ch.Qos(prefetch, 0, false); err != nil {
+			return "", err
+		}
+	}
+	if tag == "" {
+		tag = "k6-" + strconv.FormatUint(uint64(time.Now().UnixNano()), 36)
+	}
+
+	deliveries, err := c.ch.Consume(queue, tag, autoAck, exclusive, false, false, nil)
+	if err != nil {
+		return "", err
+	}
+	go func() {
+		for d := range deliveries {
+			stats.PushIfNotCancelled(c.ctx, c.state.Samples, stats.Sample{
+				Metric: metrics.AMQPMessagesReceived, Time: time.Now(), Tags: c.tags, Value: 1,
+			})
+			c.mu.Lock()
+			c.messages = append(c.messages, newMessage(d))
+			c.mu.Unlock()
+			select {
+			case c.arrived <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return tag, nil
+}
+
+// Cancel stops a consumer. Messages it already received are still returned by receive().
+func (c *Conn) Cancel(consumerTag string) error {
+	return c.ch.Cancel(consumerTag, false)
+}
+
+// Receive returns the next message delivered to the consumers, waiting for up to timeout
+// milliseconds for one to arrive, or as long as the timeout of the connection if none is given.
+// It returns null if none arrived in time.
+func (c *Conn) Receive(timeout float64) (goja.Value, error) {
+	wait := time.Duration(timeout * float64(time.Millisecond))
+	if wait <= 0 {
+		wait = c.timeout
+	}
+	var expired <-chan time.Time
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		expired = t.C
+	}
+	for {
+		c.mu.Lock()
+		if len(c.messages) > 0 {
+			msg := c.messages[0]
+			c.messages[0] = nil
+			c.messages = c.messages[1:]
+			c.mu.Unlock()
+			return c.rt.ToValue(msg), nil
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.arrived:
+		case <-c.done:
+			// Messages that arrived before the channel was closed are still returned.
+			c.mu.Lock()
+			pending := len(c.messages)
+			c.mu.Unlock()
+			if pending == 0 {
+				return nil, c.closedError()
+			}
+		case <-expired:
+			return goja.Null(), nil
+		}
+	}
+}
+
+// Get fetches a message from the queue, and returns null if it's empty.
+func (c *Conn) Get(queue string, paramsV goja.Value) (goja.Value, error) {
+	var autoAck bool
+	_ = c.eachParam(paramsV, func(k string, v goja.Value) error {
+		if k == "autoAck" {
+			autoAck = v.ToBoolean()
+		}
+		return nil
+	})
+	d, ok, err := c.ch.Get(queue, autoAck)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return goja.Null(), nil
+	}
+	stats.PushIfNotCancelled(c.ctx, c.state.Samples, stats.Sample{
+		Metric: metrics.AMQPMessagesReceived, Time: time.Now(), Tags: c.tags, Value: 1,
+	})
+	return c.rt.ToValue(newMessage(d)), nil
+}
+
+// closedError returns why the channel was closed.
+func (c *Conn) closedError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return errors.New("amqp: the connection is closed")
+}
+
+// eachParam calls fn with every param that's set.
+func (c *Conn) eachParam(paramsV goja.Value, fn func(k string, v goja.Value) error) error {
+	if paramsV == nil || goja.IsUndefined(paramsV) || goja.IsNull(paramsV) {
+		return nil
+	}
+	params := paramsV.ToObject(c.rt)
+	for _, k := range params.Keys() {
+		v := params.Get(k)
+		if goja.IsUndefined(v) || goja.IsNull(v) {
+			continue
+		}
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newMessage(d goamqp.Delivery) *Message {
+	msg := &Message{
+		Body:          string(d.Body),
+		Exchange:      d.Exchange,
+		RoutingKey:    d.RoutingKey,
+		Headers:       make(map[string]interface{}, len(d.Headers)),
+		ContentType:   d.ContentType,
+		CorrelationID: d.CorrelationId,
+		ReplyTo:       d.ReplyTo,
+		MessageID:     d.MessageId,
+		Redelivered:   d.Redelivered,
+		delivery:      d,
+	}
+	for k, v := range d.Headers {
+		msg.Headers[k] = v
+	}
+	return msg
+}
+
+// Ack acknowledges the message.
+func (m *Message) Ack() error {
+	return m.delivery.Ack(false)
+}
+
+// Nack rejects the message; if requeue is set, the broker delivers it again.
+func (m *Message) Nack(requeue bool) error {
+	return m.delivery.Nack(false, requeue)
+}
+
+// Reject rejects the message like nack() does, with the method from the AMQP 0-9-1 spec rather
+// than the RabbitMQ extension.
+func (m *Message) Reject(requeue bool) error {
+	return m.delivery.Reject(requeue)
+}
+
+// toTable converts an object to an AMQP field table.
+func toTable(v interface{}) (goamqp.Table, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("expected an object, not %T", v)
+	}
+	table := make(goamqp.Table, len(obj))
+	for k, field := range obj {
+		if nested, ok := field.(map[string]interface{}); ok {
+			var err error
+			if table[k], err = toTable(nested); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		table[k] = field
+	}
+	if err := table.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid field table")
+	}
+	return table, nil
+}
+
+// toBytes returns the bytes of a string or an array of bytes.
+func toBytes(data goja.Value) ([]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+	switch v := data.Export().(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case []interface{}:
+		b := make([]byte, len(v))
+		for i, e := range v {
+			n, ok := e.(int64)
+			if !ok || n < 0 || n > 255 {
+				return nil, errors.Errorf("invalid byte at index %d: %v", i, e)
+			}
+			b[i] = byte(n)
+		}
+		return b, nil
+	default:
+		return nil, errors.Errorf("can only publish strings and arrays of bytes, not %T", v)
+	}
+}
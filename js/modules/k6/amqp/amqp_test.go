@@ -0,0 +1,718 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package amqp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/testutils"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Frame types.
+const (
+	frameMethod    = 1
+	frameHeader    = 2
+	frameBody      = 3
+	frameHeartbeat = 8
+	frameEnd       = 0xCE
+)
+
+// A message as the broker keeps it: the properties are passed on to consumers as they were
+// published, without decoding them.
+type message struct {
+	exchange, key string
+	props, body   []byte
+	redelivered   bool
+}
+
+// A broker is just enough of an AMQP 0-9-1 broker to test against. Every connection has its own
+// queues, exchanges and bindings. It refuses the password "wrong", nacks the messages published
+// to the exchange "reject", and drops the connection on messages published to "kick".
+type broker struct {
+	mu    sync.Mutex
+	users []string
+	acks  int
+}
+
+func (b *broker) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer func() { _ = conn.Close() }()
+			s := &session{broker: b, r: bufio.NewReader(conn), w: conn}
+			_ = s.run()
+		}()
+	}
+}
+
+type session struct {
+	broker *broker
+	r      *bufio.Reader
+	w      io.Writer
+
+	queues    map[string][]*message
+	bindings  map[string]map[string]string // exchange -> routing key -> queue
+	consumers map[string]string            // queue -> consumer tag
+	noAck     map[string]bool              // consumer tag -> no-ack
+
+	deliveryTag uint64
+	unacked     map[uint64]*message
+	unackedQ    map[uint64]string
+	confirms    bool
+	published   uint64
+}
+
+func (s *session) run() error {
+	s.queues = map[string][]*message{}
+	s.bindings = map[string]map[string]string{}
+	s.consumers = map[string]string{}
+	s.noAck = map[string]bool{}
+	s.unacked = map[uint64]*message{}
+	s.unackedQ = map[uint64]string{}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(s.r, header); err != nil || string(header) != "AMQP\x00\x00\x09\x01" {
+		return err
+	}
+
+	// connection.start: version 0-9, no server properties, PLAIN, en_US.
+	if err := s.method(0, 10, 10, []byte{0, 9}, table(), longstr("PLAIN"), longstr("en_US")); err != nil {
+		return err
+	}
+	_, args, err := s.readMethod()
+	if err != nil {
+		return err
+	}
+	args.table()
+	args.shortstr()
+	// The PLAIN response is "\x00user\x00password".
+	creds := strings.Split(args.longstr(), "\x00")
+	if len(creds) != 3 || creds[2] == "wrong" {
+		return nil
+	}
+	s.broker.mu.Lock()
+	s.broker.users = append(s.broker.users, creds[1])
+	s.broker.mu.Unlock()
+
+	// connection.tune: no limits, no heartbeats.
+	if err := s.method(0, 10, 30, u16(0), u32(0), u16(0)); err != nil {
+		return err
+	}
+	for {
+		id, args, err := s.readMethod()
+		if err != nil {
+			return err
+		}
+		if err := s.handle(id, args); err != nil {
+			return err
+		}
+		if id == 10<<16|50 {
+			return nil
+		}
+	}
+}
+
+// handle handles a method, identified by its class and method ids.
+func (s *session) handle(id uint32, args *decoder) error {
+	switch id {
+	case 10<<16 | 31: // connection.tune-ok
+		return nil
+	case 10<<16 | 40: // connection.open
+		return s.method(0, 10, 41, shortstr(""))
+	case 10<<16 | 50: // connection.close
+		return s.method(0, 10, 51)
+	case 20<<16 | 10: // channel.open
+		return s.method(1, 20, 11, longstr(""))
+	case 20<<16 | 40: // channel.close
+		return s.method(1, 20, 41)
+	case 20<<16 | 41: // channel.close-ok
+		return nil
+	case 85<<16 | 10: // confirm.select
+		s.confirms = true
+		return s.method(1, 85, 11)
+	case 40<<16 | 10: // exchange.declare
+		return s.method(1, 40, 11)
+	case 50<<16 | 10: // queue.declare
+		args.uint16()
+		name := args.shortstr()
+		passive := args.byte()&1 != 0
+		if _, ok := s.queues[name]; !ok {
+			if passive {
+				return s.method(1, 20, 40, u16(404), shortstr("NOT_FOUND - no queue '"+name+"'"), u16(50), u16(10))
+			}
+			if name == "" {
+				name = "amq.gen-" + string(rune('a'+len(s.queues)))
+			}
+			s.queues[name] = nil
+		}
+		consumers := 0
+		if _, ok := s.consumers[name]; ok {
+			consumers = 1
+		}
+		return s.method(1, 50, 11, shortstr(name), u32(uint32(len(s.queues[name]))), u32(uint32(consumers)))
+	case 50<<16 | 20: // queue.bind
+		args.uint16()
+		queue, exchange, key := args.shortstr(), args.shortstr(), args.shortstr()
+		if s.bindings[exchange] == nil {
+			s.bindings[exchange] = map[string]string{}
+		}
+		s.bindings[exchange][key] = queue
+		return s.method(1, 50, 21)
+	case 60<<16 | 10: // basic.qos
+		return s.method(1, 60, 11)
+	case 60<<16 | 20: // basic.consume
+		args.uint16()
+		queue, tag := args.shortstr(), args.shortstr()
+		s.consumers[queue] = tag
+		s.noAck[tag] = args.byte()&2 != 0
+		if err := s.method(1, 60, 21, shortstr(tag)); err != nil {
+			return err
+		}
+		return s.dispatch(queue)
+	case 60<<16 | 30: // basic.cancel
+		tag := args.shortstr()
+		for queue, t := range s.consumers {
+			if t == tag {
+				delete(s.consumers, queue)
+			}
+		}
+		return s.method(1, 60, 31, shortstr(tag))
+	case 60<<16 | 40: // basic.publish
+		args.uint16()
+		msg := &message{exchange: args.shortstr(), key: args.shortstr()}
+		if err := s.readContent(msg); err != nil {
+			return err
+		}
+		return s.publish(msg)
+	case 60<<16 | 70: // basic.get
+		args.uint16()
+		queue := args.shortstr()
+		noAck := args.byte()&1 != 0
+		if len(s.queues[queue]) == 0 {
+			return s.method(1, 60, 72, shortstr(""))
+		}
+		msg := s.queues[queue][0]
+		s.queues[queue] = s.queues[queue][1:]
+		tag := s.track(queue, msg, noAck)
+		if err := s.method(1, 60, 71, u64(tag), redelivered(msg), shortstr(msg.exchange), shortstr(msg.key),
+			u32(uint32(len(s.queues[queue])))); err != nil {
+			return err
+		}
+		return s.content(msg)
+	case 60<<16 | 80: // basic.ack
+		s.broker.mu.Lock()
+		s.broker.acks++
+		s.broker.mu.Unlock()
+		delete(s.unacked, args.uint64())
+		return nil
+	case 60<<16 | 90, 60<<16 | 120: // basic.reject, basic.nack
+		tag := args.uint64()
+		bits := args.byte()
+		requeue := bits&1 != 0
+		if id == 60<<16|120 {
+			requeue = bits&2 != 0
+		}
+		msg, queue := s.unacked[tag], s.unackedQ[tag]
+		delete(s.unacked, tag)
+		if msg == nil || !requeue {
+			return nil
+		}
+		msg.redelivered = true
+		s.queues[queue] = append([]*message{msg}, s.queues[queue]...)
+		return s.dispatch(queue)
+	default:
+		return s.method(0, 10, 50, u16(540), shortstr("NOT_IMPLEMENTED"), u16(uint16(id>>16)), u16(uint16(id)))
+	}
+}
+
+func (s *session) publish(msg *message) error {
+	if msg.exchange == "kick" {
+		return io.EOF
+	}
+	if s.confirms {
+		s.published++
+		method := uint16(80) // basic.ack
+		if msg.exchange == "reject" {
+			method = 120 // basic.nack
+		}
+		if err := s.method(1, 60, method, u64(s.published), []byte{0}); err != nil {
+			return err
+		}
+	}
+
+	queue := msg.key
+	if msg.exchange != "" {
+		queue = s.bindings[msg.exchange][msg.key]
+	}
+	if _, ok := s.queues[queue]; !ok {
+		return nil
+	}
+	s.queues[queue] = append(s.queues[queue], msg)
+	return s.dispatch(queue)
+}
+
+// dispatch delivers the messages in the queue to its consumer, if it has one.
+func (s *session) dispatch(queue string) error {
+	consumer, ok := s.consumers[queue]
+	if !ok {
+		return nil
+	}
+	for len(s.queues[queue]) > 0 {
+		msg := s.queues[queue][0]
+		s.queues[queue] = s.queues[queue][1:]
+		tag := s.track(queue, msg, s.noAck[consumer])
+		if err := s.method(1, 60, 60, shortstr(consumer), u64(tag), redelivered(msg),
+			shortstr(msg.exchange), shortstr(msg.key)); err != nil {
+			return err
+		}
+		if err := s.content(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *session) track(queue string, msg *message, noAck bool) uint64 {
+	s.deliveryTag++
+	if !noAck {
+		s.unacked[s.deliveryTag] = msg
+		s.unackedQ[s.deliveryTag] = queue
+	}
+	return s.deliveryTag
+}
+
+// readContent reads the header and body frames that follow basic.publish.
+func (s *session) readContent(msg *message) error {
+	kind, _, payload, err := s.readFrame()
+	if err != nil {
+		return err
+	}
+	if kind != frameHeader || len(payload) < 14 {
+		return io.ErrUnexpectedEOF
+	}
+	size := binary.BigEndian.Uint64(payload[4:12])
+	msg.props = payload[12:]
+	for uint64(len(msg.body)) < size {
+		kind, _, payload, err := s.readFrame()
+		if err != nil {
+			return err
+		}
+		if kind != frameBody {
+			return io.ErrUnexpectedEOF
+		}
+		msg.body = append(msg.body, payload...)
+	}
+	return nil
+}
+
+// content writes the header and body frames of a delivered message.
+func (s *session) content(msg *message) error {
+	header := append(append(u16(60), u16(0)...), u64(uint64(len(msg.body)))...)
+	if err := s.frame(frameHeader, 1, append(header, msg.props...)); err != nil {
+		return err
+	}
+	if len(msg.body) == 0 {
+		return nil
+	}
+	return s.frame(frameBody, 1, msg.body)
+}
+
+func (s *session) readFrame() (kind byte, channel uint16, payload []byte, err error) {
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(s.r, header); err != nil {
+		return 0, 0, nil, err
+	}
+	payload = make([]byte, binary.BigEndian.Uint32(header[3:])+1)
+	if _, err := io.ReadFull(s.r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	if payload[len(payload)-1] != frameEnd {
+		return 0, 0, nil, io.ErrUnexpectedEOF
+	}
+	return header[0], binary.BigEndian.Uint16(header[1:]), payload[:len(payload)-1], nil
+}
+
+// readMethod reads the next method, skipping heartbeats, and returns its class and method ids
+// and its arguments.
+func (s *session) readMethod() (uint32, *decoder, error) {
+	for {
+		kind, _, payload, err := s.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		if kind == frameHeartbeat {
+			continue
+		}
+		if kind != frameMethod || len(payload) < 4 {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return binary.BigEndian.Uint32(payload), &decoder{b: payload[4:]}, nil
+	}
+}
+
+func (s *session) frame(kind byte, channel uint16, payload []byte) error {
+	buf := append([]byte{kind}, u16(channel)...)
+	buf = append(append(append(buf, u32(uint32(len(payload)))...), payload...), frameEnd)
+	_, err := s.w.Write(buf)
+	return err
+}
+
+func (s *session) method(channel, class, method uint16, args ...[]byte) error {
+	payload := append(u16(class), u16(method)...)
+	for _, arg := range args {
+		payload = append(payload, arg...)
+	}
+	return s.frame(frameMethod, channel, payload)
+}
+
+type decoder struct{ b []byte }
+
+func (d *decoder) next(n int) []byte {
+	if n > len(d.b) {
+		n = len(d.b)
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if b := d.next(1); len(b) == 1 {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.next(2); len(b) == 2 {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.next(4); len(b) == 4 {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if b := d.next(8); len(b) == 8 {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) shortstr() string { return string(d.next(int(d.byte()))) }
+func (d *decoder) longstr() string  { return string(d.next(int(d.uint32()))) }
+func (d *decoder) table()           { d.next(int(d.uint32())) }
+
+func u16(n uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, n)
+	return b
+}
+
+func u32(n uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, n)
+	return b
+}
+
+func u64(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b
+}
+
+func shortstr(s string) []byte { return append([]byte{byte(len(s))}, s...) }
+func longstr(s string) []byte  { return append(u32(uint32(len(s))), s...) }
+func table() []byte            { return u32(0) }
+
+func redelivered(msg *message) []byte {
+	if msg.redelivered {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+func TestConnect(t *testing.T) {
+	b := &broker{}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+	go b.serve(l)
+
+	certs := testutils.NewTestCerts(t)
+	tlsL, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{certs.Server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    certs.CAPool(),
+	})
+	require.NoError(t, err)
+	defer func() { _ = tlsL.Close() }()
+	go b.serve(tlsL)
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &common.State{
+		Group:  root,
+		Dialer: netext.NewDialer(net.Dialer{Timeout: 10 * time.Second}),
+		Options: lib.Options{
+			SystemTags: lib.GetTagSet("group"),
+		},
+		Samples: samples,
+	}
+
+	ctx := context.Background()
+	ctx = common.WithState(ctx, state)
+	ctx = common.WithRuntime(ctx, rt)
+
+	rt.Set("amqp", common.Bind(rt, New(), &ctx))
+	rt.Set("url", "amqp://k6:k6@"+l.Addr().String()+"/")
+	rt.Set("tlsURL", "amqps://k6:k6@"+tlsL.Addr().String()+"/")
+
+	t.Run("Publish and consume", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		amqp.connect(url, { confirms: true, timeout: 2000 }, function(conn) {
+			let q = conn.declareQueue("k6", { durable: true });
+			if (q.name !== "k6" || q.messages !== 0) {
+				throw new Error("Unexpected queue: " + JSON.stringify(q));
+			}
+			conn.declareExchange("events", "topic");
+			conn.bindQueue("k6", "events", "k6.test");
+
+			conn.publish("events", "k6.test", "hello", {
+				contentType: "text/plain",
+				correlationId: "1",
+				headers: { n: 1, s: "str" },
+				persistent: true,
+			});
+			conn.publish("", "k6", [104, 105]);
+			conn.publish("events", "nowhere", "lost");
+
+			conn.consume("k6", { prefetch: 10 });
+			let msg = conn.receive();
+			if (msg.body !== "hello" || msg.exchange !== "events" || msg.routingKey !== "k6.test" ||
+				msg.contentType !== "text/plain" || msg.correlationId !== "1" ||
+				msg.headers.n !== 1 || msg.headers.s !== "str" || msg.redelivered) {
+				throw new Error("Unexpected message: " + JSON.stringify(msg));
+			}
+			msg.ack();
+
+			msg = conn.receive();
+			if (msg.body !== "hi" || msg.exchange !== "" || msg.routingKey !== "k6") {
+				throw new Error("Unexpected message: " + JSON.stringify(msg));
+			}
+			msg.nack(true);
+			msg = conn.receive();
+			if (msg.body !== "hi" || !msg.redelivered) {
+				throw new Error("Message wasn't redelivered: " + JSON.stringify(msg));
+			}
+			msg.ack();
+
+			if (conn.receive(50) !== null) {
+				throw new Error("Received a message that wasn't routed to the queue");
+			}
+		});`)
+		assert.NoError(t, err)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		assert.Equal(t, "k6", b.users[0])
+		assert.Equal(t, 2, b.acks)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		amqp.connect(url, function(conn) {
+			let q = conn.declareQueue("", { exclusive: true, autoDelete: true });
+			if (conn.get(q.name) !== null) {
+				throw new Error("Got a message from an empty queue");
+			}
+			conn.publish("", q.name, "hello");
+			let msg = conn.get(q.name, { autoAck: true });
+			if (msg.body !== "hello") {
+				throw new Error("Unexpected message: " + JSON.stringify(msg));
+			}
+		});`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		amqp.connect(url, function(conn) {
+			conn.declareQueue("k6");
+			let tag = conn.consume("k6", { autoAck: true, consumerTag: "k6-consumer" });
+			if (tag !== "k6-consumer") {
+				throw new Error("Unexpected consumer tag: " + tag);
+			}
+			conn.cancel(tag);
+			conn.publish("", "k6", "hello");
+			if (conn.receive(50) !== null) {
+				throw new Error("Received a message after cancelling");
+			}
+			if (conn.get("k6", { autoAck: true }).body !== "hello") {
+				throw new Error("The message wasn't queued");
+			}
+		});`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		amqp.connect(url, { confirms: true }, function(conn) {
+			conn.publish("reject", "k6", "hello");
+		});`)
+		assert.EqualError(t, err, "GoError: amqp: the broker rejected the message")
+
+		// Without confirms, the publisher doesn't find out.
+		_, err = common.RunString(rt, `
+		amqp.connect(url, function(conn) {
+			conn.publish("reject", "k6", "hello");
+		});`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Refused", func(t *testing.T) {
+		_, err := common.RunString(rt, `amqp.connect("amqp://k6:wrong@" + url.split("@")[1], function() {});`)
+		assert.EqualError(t, err, `GoError: Exception (403) Reason: "username or password not allowed"`)
+	})
+
+	t.Run("Channel closed", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		amqp.connect(url, function(conn) {
+			conn.declareQueue("missing", { passive: true });
+		});`)
+		assert.EqualError(t, err, `GoError: Exception (404) Reason: "NOT_FOUND - no queue 'missing'"`)
+	})
+
+	t.Run("Connection lost", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		amqp.connect(url, { timeout: 2000 }, function(conn) {
+			conn.declareQueue("k6");
+			conn.consume("k6");
+			conn.publish("kick", "k6", "bye");
+			conn.receive();
+		});`)
+		assert.EqualError(t, err, `GoError: Exception (501) Reason: "EOF"`)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		amqp.connect(url, function(conn) {
+			conn.publish("", "k6", { not: "bytes" });
+		});`)
+		assert.EqualError(t, err, "GoError: can only publish strings and arrays of bytes, not map[string]interface {}")
+
+		_, err = common.RunString(rt, `
+		amqp.connect(url, function(conn) {
+			conn.publish("", "k6", "hello", { headers: { f: function() {} } });
+		});`)
+		assert.Contains(t, err.Error(), "invalid field table")
+
+		_, err = common.RunString(rt, `amqp.connect("http://localhost", function() {});`)
+		assert.EqualError(t, err, "GoError: AMQP scheme must be either 'amqp://' or 'amqps://'")
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		certPEM, keyPEM := testutils.CertificatePEM(t, certs.Client)
+		rt.Set("tlsParams", map[string]string{"ca": certs.CAPEM(), "cert": certPEM, "key": keyPEM})
+
+		_, err := common.RunString(rt, `
+		amqp.connect(tlsURL, { tls: tlsParams, timeout: 2000 }, function(conn) {
+			conn.declareQueue("k6");
+			conn.publish("", "k6", "hello");
+			if (conn.get("k6", { autoAck: true }).body !== "hello") {
+				throw new Error("Message mismatch");
+			}
+		});`)
+		assert.NoError(t, err)
+
+		// Without the client certificate, the broker hangs up during the handshake.
+		_, err = common.RunString(rt, `
+		amqp.connect(tlsURL, { tls: { ca: tlsParams.ca }, timeout: 2000 }, function() {});`)
+		assert.Error(t, err)
+
+		// Without the CA, the broker's certificate isn't trusted.
+		_, err = common.RunString(rt, `amqp.connect(tlsURL, { timeout: 2000 }, function() {});`)
+		assert.Contains(t, err.Error(), "certificate")
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		for len(samples) > 0 {
+			<-samples
+		}
+		_, err := common.RunString(rt, `
+		amqp.connect(url, { confirms: true, tags: { broker: "test" } }, function(conn) {
+			conn.declareQueue("k6");
+			conn.consume("k6", { autoAck: true });
+			conn.publish("", "k6", "hello");
+			conn.receive(2000);
+		});`)
+		assert.NoError(t, err)
+
+		counts := map[string]float64{}
+		seen := map[string]bool{}
+		for len(samples) > 0 {
+			for _, sample := range (<-samples).GetSamples() {
+				seen[sample.Metric.Name] = true
+				if sample.Metric.Type == stats.Counter {
+					counts[sample.Metric.Name] += sample.Value
+				}
+				tag, _ := sample.Tags.Get("broker")
+				assert.Equal(t, "test", tag)
+			}
+		}
+		assert.Equal(t, map[string]float64{
+			metrics.AMQPSessions.Name:          1,
+			metrics.AMQPMessagesPublished.Name: 1,
+			metrics.AMQPMessagesReceived.Name:  1,
+		}, counts)
+		for _, m := range []*stats.Metric{metrics.AMQPConnecting, metrics.AMQPSessionDuration, metrics.AMQPPublishDuration} {
+			assert.True(t, seen[m.Name], m.Name)
+		}
+	})
+}
@@ -0,0 +1,551 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// ErrBrowserInInitContext is returned when a browser is used in the init context.
+var ErrBrowserInInitContext = common.NewInitContextError("Browsers can't be used in the init context")
+
+const (
+	// DefaultSessions is how many browser sessions may be open at once, if the browserSessions
+	// option isn't set. Browsers take a lot more resources than VUs, so it's only one.
+	DefaultSessions = 1
+
+	// How long navigating and waiting for elements may take by default.
+	defaultTimeout = 30 * time.Second
+
+	// How often waitForSelector() looks for the element.
+	selectorPollInterval = 100 * time.Millisecond
+)
+
+// vitalsScript is evaluated in every document a page loads, to keep track of its Web Vitals.
+const vitalsScript = `(function() {
+	var v = window.__k6Vitals = {};
+	function observe(type, fn) {
+		try {
+			new PerformanceObserver(function(list) { list.getEntries().forEach(fn); }).observe({type: type, buffered: true});
+		} catch (e) {}
+	}
+	observe("paint", function(e) { if (e.name === "first-contentful-paint") { v.fcp = e.startTime; } });
+	observe("largest-contentful-paint", function(e) { v.lcp = e.renderTime || e.loadTime || e.startTime; });
+	observe("layout-shift", function(e) { if (!e.hadRecentInput) { v.cls = (v.cls || 0) + e.value; } });
+	observe("navigation", function(e) { v.ttfb = e.responseStart; });
+})();`
+
+// The Web Vitals that are reported, with their metrics.
+var webVitals = []struct {
+	name   string
+	metric *stats.Metric
+}{
+	{"fcp", metrics.BrowserFCP},
+	{"lcp", metrics.BrowserLCP},
+	{"cls", metrics.BrowserCLS},
+	{"ttfb", metrics.BrowserTTFB},
+}
+
+// Browser is the k6/browser module. It runs real browser sessions, with headless Chrome or
+// Chromium driven over the DevTools protocol, alongside the protocol-level VUs.
+type Browser struct {
+	launch launcher
+
+	mu       sync.Mutex
+	sessions chan struct{}
+}
+
+// New returns a new k6/browser module.
+func New() *Browser {
+	return &Browser{launch: launchChrome}
+}
+
+// NewPage starts a browser session with a blank page. It waits for one of the sessions allowed
+// by the browserSessions option to be free. Pages should be closed when they aren't needed
+// anymore, or they're closed at the end of the test. The tags given in the params are applied to
+// all of the page's metrics, eg. to tell its scenario apart from the protocol-level ones.
+func (b *Browser) NewPage(ctxPtr *context.Context, paramsV goja.Value) (interface{}, error) {
+	ctx := *ctxPtr
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, ErrBrowserInInitContext
+	}
+	rt := common.GetRuntime(ctx)
+	tags := map[string]string{}
+	if paramsV != nil && !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		if tagsV := paramsV.ToObject(rt).Get("tags"); tagsV != nil && !goja.IsUndefined(tagsV) && !goja.IsNull(tagsV) {
+			tagsObj := tagsV.ToObject(rt)
+			for _, key := range tagsObj.Keys() {
+				tags[key] = tagsObj.Get(key).String()
+			}
+		}
+	}
+	if len(state.Options.AllowedHosts) > 0 || len(state.Options.BlockedHosts) > 0 {
+		return nil, errors.New("browsers can't be used with the allowedHosts and blockedHosts options, " +
+			"since their connections don't go through k6")
+	}
+
+	sessions := b.getSessions(state.Options.BrowserSessions.Int64)
+	select {
+	case sessions <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if state.Handles != nil {
+		if err := state.Handles.Acquire(); err != nil {
+			<-sessions
+			return nil, err
+		}
+	}
+
+	page := &Page{tags: tags, sessions: sessions, handles: state.Handles, closed: make(chan struct{})}
+	if err := page.start(ctx, b.launch, state.Options.BrowserExecutable.String); err != nil {
+		page.release()
+		return nil, err
+	}
+	stats.PushIfNotCancelled(ctx, state.Samples, stats.Sample{
+		Time:   time.Now(),
+		Metric: metrics.BrowserSessions,
+		Tags:   page.sampleTags(state),
+		Value:  1,
+	})
+
+	// The page is closed at the end of the test, if the script doesn't close it.
+	go func() {
+		select {
+		case <-ctx.Done():
+			page.release()
+		case <-page.closed:
+		}
+	}()
+	return common.Bind(rt, page, ctxPtr), nil
+}
+
+func (b *Browser) getSessions(max int64) chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sessions == nil {
+		if max <= 0 {
+			max = DefaultSessions
+		}
+		b.sessions = make(chan struct{}, max)
+	}
+	return b.sessions
+}
+
+// A Page is a browser session, with a single tab.
+type Page struct {
+	conn      *cdpConn
+	stop      func()
+	sessionID string
+
+	// The URL of the document the page loaded last, which its Web Vitals are tagged with.
+	url string
+
+	// The tags given to newPage().
+	tags map[string]string
+
+	sessions  chan struct{}
+	handles   *lib.HandleCounter
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (p *Page) start(ctx context.Context, launch launcher, executable string) error {
+	url, stop, err := launch(ctx, executable)
+	if err != nil {
+		return err
+	}
+	p.stop = stop
+	if p.conn, err = dialCDP(ctx, url); err != nil {
+		return err
+	}
+
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	if err := p.conn.Call(ctx, "", "Target.createTarget", map[string]interface{}{"url": "about:blank"}, &target); err != nil {
+		return err
+	}
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	err = p.conn.Call(ctx, "", "Target.attachToTarget",
+		map[string]interface{}{"targetId": target.TargetID, "flatten": true}, &session)
+	if err != nil {
+		return err
+	}
+	p.sessionID = session.SessionID
+
+	for _, method := range []string{"Page.enable", "Network.enable", "Runtime.enable"} {
+		if err := p.conn.Call(ctx, p.sessionID, method, nil, nil); err != nil {
+			return err
+		}
+	}
+	return p.conn.Call(ctx, p.sessionID, "Page.addScriptToEvaluateOnNewDocument",
+		map[string]interface{}{"source": vitalsScript}, nil)
+}
+
+// Goto loads a URL, and waits for its load event. It returns the HTTP status of the document.
+func (p *Page) Goto(ctx context.Context, url string, paramsV goja.Value) (int, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return 0, ErrBrowserInInitContext
+	}
+	timeout, err := timeoutParam(common.GetRuntime(ctx), paramsV)
+	if err != nil {
+		return 0, err
+	}
+
+	// The Web Vitals of the previous document are final once it's left.
+	p.reportVitals(ctx, state)
+
+	loads, unsubscribeLoads := p.conn.Subscribe(p.sessionID, "Page.loadEventFired")
+	defer unsubscribeLoads()
+	responses, unsubscribeResponses := p.conn.Subscribe(p.sessionID, "Network.responseReceived")
+	defer unsubscribeResponses()
+
+	// The page's requests are part of the same trace until the next navigation, like the ones of
+	// an HTTP request that's redirected.
+	var traceID, spanID string
+	if state.Options.TraceContext.Bool {
+		traceID, spanID = netext.NewTraceID(), netext.NewSpanID()
+		headers := map[string]interface{}{"traceparent": netext.TraceParent(traceID, spanID)}
+		err := p.conn.Call(ctx, p.sessionID, "Network.setExtraHTTPHeaders", map[string]interface{}{"headers": headers}, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	var nav struct {
+		LoaderID  string `json:"loaderId"`
+		ErrorText string `json:"errorText"`
+	}
+	if err := p.conn.Call(ctx, p.sessionID, "Page.navigate", map[string]interface{}{"url": url}, &nav); err != nil {
+		return 0, err
+	}
+	if nav.ErrorText != "" {
+		return 0, errors.Errorf("couldn't load '%s': %s", url, nav.ErrorText)
+	}
+
+	status := 0
+	checkResponse := func(data json.RawMessage) {
+		var res struct {
+			RequestID string `json:"requestId"`
+			Response  struct {
+				Status int `json:"status"`
+			} `json:"response"`
+		}
+		if json.Unmarshal(data, &res) == nil && res.RequestID == nav.LoaderID {
+			status = res.Response.Status
+		}
+	}
+	for loaded := false; !loaded; {
+		select {
+		case <-loads:
+			loaded = true
+		case data := <-responses:
+			checkResponse(data)
+		case <-p.conn.Done():
+			return 0, errors.New("the browser closed while loading the page")
+		case <-ctx.Done():
+			return 0, errors.Errorf("'%s' didn't load within %s", url, timeout)
+		}
+	}
+	end := time.Now()
+
+	// Events are delivered in order, so the document's response is already waiting if it
+	// wasn't seen before the load event.
+	for drained := false; !drained; {
+		select {
+		case data := <-responses:
+			checkResponse(data)
+		default:
+			drained = true
+		}
+	}
+
+	p.url = url
+	tags := p.tagMap(state)
+	if state.Options.SystemTags["status"] {
+		tags["status"] = strconv.Itoa(status)
+	}
+	stats.PushIfNotCancelled(ctx, state.Samples, pageLoad{
+		Sample: stats.Sample{
+			Time:   end,
+			Metric: metrics.BrowserPageLoad,
+			Tags:   stats.IntoSampleTags(&tags),
+			Value:  stats.D(end.Sub(start)),
+		},
+		traceID: traceID,
+		spanID:  spanID,
+		start:   start,
+	})
+	return status, nil
+}
+
+// A pageLoad is the sample of a page load, which is traced if it propagated a trace context.
+type pageLoad struct {
+	stats.Sample
+	traceID, spanID string
+	start           time.Time
+}
+
+// GetSpan implements the stats.TracedSampleContainer interface.
+func (pl pageLoad) GetSpan() (stats.Span, bool) {
+	return stats.Span{
+		TraceID: pl.traceID,
+		SpanID:  pl.spanID,
+		Name:    "Page load",
+		Start:   pl.start,
+		End:     pl.Time,
+	}, pl.traceID != ""
+}
+
+// Evaluate evaluates a JS expression in the page, waiting for it if it's a promise, and returns
+// its value, which has to be serializable as JSON.
+func (p *Page) Evaluate(ctx context.Context, expression string) (interface{}, error) {
+	return p.evaluate(ctx, expression)
+}
+
+// Click clicks the first element that matches a CSS selector.
+func (p *Page) Click(ctx context.Context, selector string) (goja.Value, error) {
+	return goja.Undefined(), p.withElement(ctx, selector, "el.click();")
+}
+
+// Fill sets the value of the first input element that matches a CSS selector, like typing it in.
+func (p *Page) Fill(ctx context.Context, selector, value string) (goja.Value, error) {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return goja.Undefined(), p.withElement(ctx, selector, `el.focus(); el.value = `+string(v)+`;
+		el.dispatchEvent(new Event("input", {bubbles: true}));
+		el.dispatchEvent(new Event("change", {bubbles: true}));`)
+}
+
+// WaitForSelector waits for an element that matches a CSS selector to be in the page.
+func (p *Page) WaitForSelector(ctx context.Context, selector string, paramsV goja.Value) (goja.Value, error) {
+	timeout, err := timeoutParam(common.GetRuntime(ctx), paramsV)
+	if err != nil {
+		return nil, err
+	}
+	sel, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		found, err := p.evaluate(ctx, "document.querySelector("+string(sel)+") !== null")
+		if err != nil {
+			return nil, err
+		}
+		if found == true {
+			return goja.Undefined(), nil
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Errorf("no element matched the selector '%s' within %s", selector, timeout)
+		}
+		select {
+		case <-time.After(selectorPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Content returns the HTML of the page.
+func (p *Page) Content(ctx context.Context) (string, error) {
+	html, err := p.evaluate(ctx, "document.documentElement.outerHTML")
+	if err != nil {
+		return "", err
+	}
+	s, _ := html.(string)
+	return s, nil
+}
+
+// Close reports the Web Vitals of the page, and ends the browser session.
+func (p *Page) Close(ctx context.Context) {
+	if state := common.GetState(ctx); state != nil {
+		p.reportVitals(ctx, state)
+	}
+	if p.conn != nil {
+		_ = p.conn.Call(ctx, "", "Browser.close", nil, nil)
+	}
+	p.release()
+}
+
+// release stops the browser, and frees its session.
+func (p *Page) release() {
+	p.closeOnce.Do(func() {
+		if p.conn != nil {
+			_ = p.conn.Close()
+		}
+		if p.stop != nil {
+			p.stop()
+		}
+		if p.handles != nil {
+			p.handles.Release()
+		}
+		<-p.sessions
+		close(p.closed)
+	})
+}
+
+func (p *Page) evaluate(ctx context.Context, expression string) (interface{}, error) {
+	if p.conn == nil {
+		return nil, errors.New("the page is closed")
+	}
+	select {
+	case <-p.closed:
+		return nil, errors.New("the page is closed")
+	default:
+	}
+
+	var res struct {
+		Result struct {
+			Value interface{} `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text      string `json:"text"`
+			Exception struct {
+				Description string `json:"description"`
+			} `json:"exception"`
+		} `json:"exceptionDetails"`
+	}
+	err := p.conn.Call(ctx, p.sessionID, "Runtime.evaluate", map[string]interface{}{
+		"expression": expression, "returnByValue": true, "awaitPromise": true,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	if d := res.ExceptionDetails; d != nil {
+		if d.Exception.Description != "" {
+			return nil, errors.New(d.Exception.Description)
+		}
+		return nil, errors.New(d.Text)
+	}
+	return res.Result.Value, nil
+}
+
+// withElement runs JS code with the first element that matches a CSS selector as "el".
+func (p *Page) withElement(ctx context.Context, selector, code string) error {
+	sel, err := json.Marshal(selector)
+	if err != nil {
+		return err
+	}
+	found, err := p.evaluate(ctx, `(function() {
+		var el = document.querySelector(`+string(sel)+`);
+		if (el === null) { return false; }
+		`+code+`
+		return true;
+	})()`)
+	if err != nil {
+		return err
+	}
+	if found != true {
+		return errors.Errorf("no element matches the selector '%s'", selector)
+	}
+	return nil
+}
+
+func (p *Page) reportVitals(ctx context.Context, state *common.State) {
+	if p.url == "" || ctx.Err() != nil {
+		return
+	}
+	v, err := p.evaluate(ctx, "window.__k6Vitals || {}")
+	if err != nil {
+		state.Logger.WithError(err).Debug("Couldn't get the Web Vitals of the page")
+		return
+	}
+	values, _ := v.(map[string]interface{})
+
+	now := time.Now()
+	tags := p.sampleTags(state)
+	var samples []stats.Sample
+	for _, vital := range webVitals {
+		if value, ok := values[vital.name].(float64); ok {
+			samples = append(samples, stats.Sample{Time: now, Metric: vital.metric, Tags: tags, Value: value})
+		}
+	}
+	if len(samples) > 0 {
+		stats.PushIfNotCancelled(ctx, state.Samples, stats.Samples(samples))
+	}
+}
+
+func (p *Page) sampleTags(state *common.State) *stats.SampleTags {
+	tags := p.tagMap(state)
+	return stats.IntoSampleTags(&tags)
+}
+
+// tagMap returns the tags of the page's metrics, which it shares with the protocol-level ones of
+// its VU: the run tags, which include the run ID with the run_id system tag, and the vu, iter and
+// group system tags.
+func (p *Page) tagMap(state *common.State) map[string]string {
+	tags := state.Options.RunTags.CloneTags()
+	for k, v := range p.tags {
+		tags[k] = v
+	}
+	if state.Options.SystemTags["vu"] {
+		tags["vu"] = strconv.FormatInt(state.Vu, 10)
+	}
+	if state.Options.SystemTags["iter"] {
+		tags["iter"] = strconv.FormatInt(state.Iteration, 10)
+	}
+	if state.Options.SystemTags["url"] && p.url != "" {
+		tags["url"] = p.url
+	}
+	if state.Options.SystemTags["group"] {
+		tags["group"] = state.Group.Path
+	}
+	return tags
+}
+
+func timeoutParam(rt *goja.Runtime, paramsV goja.Value) (time.Duration, error) {
+	if paramsV == nil || goja.IsUndefined(paramsV) || goja.IsNull(paramsV) {
+		return defaultTimeout, nil
+	}
+	timeoutV := paramsV.ToObject(rt).Get("timeout")
+	if timeoutV == nil || goja.IsUndefined(timeoutV) {
+		return defaultTimeout, nil
+	}
+	timeout := time.Duration(timeoutV.ToFloat() * float64(time.Millisecond))
+	if timeout <= 0 {
+		return 0, errors.New("the timeout must be positive")
+	}
+	return timeout, nil
+}
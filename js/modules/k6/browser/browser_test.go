@@ -0,0 +1,322 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/gorilla/websocket"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// A fakeBrowser is just enough of a DevTools endpoint to drive a page that always loads.
+type fakeBrowser struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	methods     []string
+	traceparent string
+	launches    int
+	stops       int
+}
+
+func newFakeBrowser() *fakeBrowser {
+	b := &fakeBrowser{}
+	b.Server = httptest.NewServer(http.HandlerFunc(b.serve))
+	return b
+}
+
+func (b *fakeBrowser) launch(ctx context.Context, executable string) (string, func(), error) {
+	b.mu.Lock()
+	b.launches++
+	b.mu.Unlock()
+	return "ws" + strings.TrimPrefix(b.URL, "http"), func() {
+		b.mu.Lock()
+		b.stops++
+		b.mu.Unlock()
+	}, nil
+}
+
+func (b *fakeBrowser) serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	send := func(msg cdpMessage, v interface{}) {
+		if v != nil {
+			msg.Result, _ = json.Marshal(v)
+		}
+		_ = conn.WriteJSON(msg)
+	}
+	event := func(sessionID, method string, v interface{}) {
+		params, _ := json.Marshal(v)
+		_ = conn.WriteJSON(cdpMessage{Method: method, SessionID: sessionID, Params: params})
+	}
+	for {
+		var msg cdpMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.methods = append(b.methods, msg.Method)
+		b.mu.Unlock()
+
+		res := cdpMessage{ID: msg.ID, SessionID: msg.SessionID}
+		switch msg.Method {
+		case "Target.createTarget":
+			send(res, map[string]string{"targetId": "T1"})
+		case "Target.attachToTarget":
+			send(res, map[string]string{"sessionId": "S1"})
+		case "Page.navigate":
+			send(res, map[string]string{"frameId": "F1", "loaderId": "L1"})
+			event(msg.SessionID, "Network.responseReceived", map[string]interface{}{
+				"requestId": "L2", "response": map[string]int{"status": 404},
+			})
+			event(msg.SessionID, "Network.responseReceived", map[string]interface{}{
+				"requestId": "L1", "response": map[string]int{"status": 200},
+			})
+			event(msg.SessionID, "Page.loadEventFired", map[string]float64{"timestamp": 1})
+		case "Network.setExtraHTTPHeaders":
+			var params struct {
+				Headers map[string]string `json:"headers"`
+			}
+			_ = json.Unmarshal(msg.Params, &params)
+			b.mu.Lock()
+			b.traceparent = params.Headers["traceparent"]
+			b.mu.Unlock()
+			send(res, map[string]string{})
+		case "Runtime.evaluate":
+			var params struct {
+				Expression string `json:"expression"`
+			}
+			_ = json.Unmarshal(msg.Params, &params)
+			send(res, evaluate(params.Expression))
+		default:
+			send(res, map[string]string{})
+		}
+	}
+}
+
+func evaluate(expr string) interface{} {
+	value := func(v interface{}) interface{} {
+		return map[string]interface{}{"result": map[string]interface{}{"value": v}}
+	}
+	switch {
+	case strings.Contains(expr, "__k6Vitals"):
+		return value(map[string]float64{"fcp": 120, "lcp": 250, "cls": 0.05, "ttfb": 40})
+	case strings.Contains(expr, "outerHTML"):
+		return value("<html><body>hi</body></html>")
+	case strings.Contains(expr, "#missing"):
+		return value(false)
+	case strings.Contains(expr, "querySelector"):
+		return value(true)
+	case expr == "1 + 1":
+		return value(2)
+	default:
+		return map[string]interface{}{
+			"result":           map[string]interface{}{},
+			"exceptionDetails": map[string]interface{}{"exception": map[string]string{"description": "Error: boom"}},
+		}
+	}
+}
+
+func (b *fakeBrowser) reset() ([]string, int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	methods, launches, stops := b.methods, b.launches, b.stops
+	b.methods, b.launches, b.stops = nil, 0, 0
+	return methods, launches, stops
+}
+
+func TestBrowser(t *testing.T) {
+	fake := newFakeBrowser()
+	defer fake.Close()
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &common.State{
+		Group:   root,
+		Options: lib.Options{SystemTags: lib.GetTagSet("url", "group")},
+		Samples: samples,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = common.WithRuntime(ctx, rt)
+	ctx = common.WithState(ctx, state)
+
+	rt.Set("browser", common.Bind(rt, &Browser{launch: fake.launch}, &ctx))
+
+	t.Run("Page", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let page = browser.newPage();
+		try {
+			let status = page.goto("https://example.com/");
+			if (status !== 200) { throw new Error("wrong status: " + status); }
+			if (page.evaluate("1 + 1") !== 2) { throw new Error("wrong value"); }
+			page.waitForSelector("#login");
+			page.fill("#user", "k6");
+			page.click("#login");
+			if (page.content().indexOf("hi") < 0) { throw new Error("wrong content"); }
+		} finally {
+			page.close();
+		}
+		`)
+		require.NoError(t, err)
+
+		methods, launches, stops := fake.reset()
+		assert.Equal(t, 1, launches)
+		assert.Equal(t, 1, stops)
+		assert.Equal(t, []string{
+			"Target.createTarget", "Target.attachToTarget", "Page.enable", "Network.enable", "Runtime.enable",
+			"Page.addScriptToEvaluateOnNewDocument", "Page.navigate",
+		}, methods[:7])
+		assert.Equal(t, "Browser.close", methods[len(methods)-1])
+
+		values := map[*stats.Metric]float64{}
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				values[sample.Metric] = sample.Value
+				if sample.Metric != metrics.BrowserSessions {
+					assert.Equal(t, "https://example.com/", sample.Tags.CloneTags()["url"])
+				}
+			}
+		}
+		assert.Equal(t, map[*stats.Metric]float64{
+			metrics.BrowserSessions: 1,
+			metrics.BrowserPageLoad: values[metrics.BrowserPageLoad],
+			metrics.BrowserFCP:      120,
+			metrics.BrowserLCP:      250,
+			metrics.BrowserCLS:      0.05,
+			metrics.BrowserTTFB:     40,
+		}, values)
+	})
+	t.Run("Errors", func(t *testing.T) {
+		testdata := map[string]string{
+			"exception":        `page.evaluate("boom")`,
+			"no element":       `page.click("#missing")`,
+			"wait timeout":     `page.waitForSelector("#missing", { timeout: 200 })`,
+			"negative timeout": `page.goto("https://example.com/", { timeout: -1 })`,
+		}
+		for name, script := range testdata {
+			t.Run(name, func(t *testing.T) {
+				_, err := common.RunString(rt, "let page = browser.newPage(); try { "+script+" } finally { page.close(); }")
+				assert.Error(t, err)
+			})
+		}
+		fake.reset()
+		stats.GetBufferedSamples(samples)
+	})
+	t.Run("Sessions", func(t *testing.T) {
+		// The only session is freed when the page is closed, so a second page can be opened.
+		_, err := common.RunString(rt, `
+		browser.newPage().close();
+		browser.newPage().close();
+		`)
+		require.NoError(t, err)
+		_, launches, stops := fake.reset()
+		assert.Equal(t, 2, launches)
+		assert.Equal(t, 2, stops)
+		stats.GetBufferedSamples(samples)
+	})
+	t.Run("Correlation", func(t *testing.T) {
+		state.Options.SystemTags = lib.GetTagSet("url", "group", "vu", "iter", "status", "run_id")
+		state.Options.RunTags = stats.IntoSampleTags(&map[string]string{"run_id": "8f2c1a"})
+		state.Options.TraceContext = null.BoolFrom(true)
+		state.Vu, state.Iteration = 3, 7
+		defer func() {
+			state.Options = lib.Options{SystemTags: lib.GetTagSet("url", "group")}
+			state.Vu, state.Iteration = 0, 0
+		}()
+
+		_, err := common.RunString(rt, `
+		let page = browser.newPage({ tags: { scenario: "checkout" } });
+		try {
+			page.goto("https://example.com/");
+		} finally {
+			page.close();
+		}
+		`)
+		require.NoError(t, err)
+
+		methods, _, _ := fake.reset()
+		assert.Contains(t, methods, "Network.setExtraHTTPHeaders")
+		fake.mu.Lock()
+		traceparent := fake.traceparent
+		fake.mu.Unlock()
+
+		var traced int
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				tags := sample.Tags.CloneTags()
+				assert.Equal(t, "checkout", tags["scenario"])
+				assert.Equal(t, "8f2c1a", tags["run_id"])
+				assert.Equal(t, "3", tags["vu"])
+				assert.Equal(t, "7", tags["iter"])
+			}
+			if tc, ok := container.(stats.TracedSampleContainer); ok {
+				span, ok := tc.GetSpan()
+				require.True(t, ok)
+				traced++
+				assert.Equal(t, "00-"+span.TraceID+"-"+span.SpanID+"-01", traceparent)
+				assert.Equal(t, "200", tc.GetTags().CloneTags()["status"])
+			}
+		}
+		assert.Equal(t, 1, traced)
+	})
+	t.Run("Blocked hosts", func(t *testing.T) {
+		state.Options.BlockedHosts = []string{"169.254.169.254"}
+		defer func() { state.Options.BlockedHosts = nil }()
+		_, err := common.RunString(rt, `browser.newPage()`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "blockedHosts")
+		}
+	})
+}
+
+func TestBrowserInitContext(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := common.WithRuntime(context.Background(), rt)
+	rt.Set("browser", common.Bind(rt, &Browser{launch: launchChrome}, &ctx))
+
+	_, err := common.RunString(rt, `browser.newPage()`)
+	assert.Contains(t, err.Error(), ErrBrowserInInitContext.Error())
+}
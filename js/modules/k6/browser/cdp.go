@@ -0,0 +1,196 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// How many events a subscriber may have waiting before more are dropped.
+const cdpEventBuffer = 64
+
+// A cdpMessage is a command, a response to one, or an event of the Chrome DevTools Protocol.
+type cdpMessage struct {
+	ID        int64           `json:"id,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *cdpError       `json:"error,omitempty"`
+}
+
+type cdpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type cdpSubscriber struct {
+	sessionID, method string
+	events            chan json.RawMessage
+}
+
+// A cdpConn is a connection to a browser's DevTools endpoint, over which commands are sent to
+// it, or to one of its targets with their session ID, and events are received.
+type cdpConn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+
+	mu          sync.Mutex
+	nextID      int64
+	pending     map[int64]chan cdpMessage
+	subscribers map[*cdpSubscriber]bool
+	err         error
+	done        chan struct{}
+}
+
+func dialCDP(ctx context.Context, url string) (*cdpConn, error) {
+	// The browser runs on this machine, so it's connected to directly, not through k6's dialer.
+	var netDialer net.Dialer
+	d := websocket.Dialer{NetDial: func(network, addr string) (net.Conn, error) {
+		return netDialer.DialContext(ctx, network, addr)
+	}}
+	ws, _, err := d.Dial(url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't connect to the browser")
+	}
+	c := &cdpConn{
+		ws:          ws,
+		pending:     make(map[int64]chan cdpMessage),
+		subscribers: make(map[*cdpSubscriber]bool),
+		done:        make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *cdpConn) readLoop() {
+	var err error
+	for {
+		var msg cdpMessage
+		if err = c.ws.ReadJSON(&msg); err != nil {
+			break
+		}
+
+		c.mu.Lock()
+		if msg.ID != 0 {
+			if ch, ok := c.pending[msg.ID]; ok {
+				delete(c.pending, msg.ID)
+				ch <- msg
+			}
+		} else {
+			for s := range c.subscribers {
+				if s.method == msg.Method && s.sessionID == msg.SessionID {
+					select {
+					case s.events <- msg.Params:
+					default:
+					}
+				}
+			}
+		}
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	c.err = errors.Wrap(err, "the connection to the browser was lost")
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// Call sends a command, to a target if sessionID isn't empty, and unmarshals its result into
+// result, if it isn't nil.
+func (c *cdpConn) Call(ctx context.Context, sessionID, method string, params, result interface{}) error {
+	msg := cdpMessage{Method: method, SessionID: sessionID}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		msg.Params = data
+	}
+
+	ch := make(chan cdpMessage, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	msg.ID = c.nextID
+	c.pending[msg.ID] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, msg.ID)
+		c.mu.Unlock()
+	}()
+
+	c.writeMu.Lock()
+	err := c.ws.WriteJSON(msg)
+	c.writeMu.Unlock()
+	if err != nil {
+		return errors.Wrap(err, "couldn't send a command to the browser")
+	}
+
+	select {
+	case res := <-ch:
+		if res.Error != nil {
+			return errors.Errorf("%s failed: %s", method, res.Error.Message)
+		}
+		if result != nil && len(res.Result) > 0 {
+			return json.Unmarshal(res.Result, result)
+		}
+		return nil
+	case <-c.done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Subscribe returns the events of a method from a target, until the returned function is called.
+func (c *cdpConn) Subscribe(sessionID, method string) (<-chan json.RawMessage, func()) {
+	s := &cdpSubscriber{sessionID: sessionID, method: method, events: make(chan json.RawMessage, cdpEventBuffer)}
+	c.mu.Lock()
+	c.subscribers[s] = true
+	c.mu.Unlock()
+	return s.events, func() {
+		c.mu.Lock()
+		delete(c.subscribers, s)
+		c.mu.Unlock()
+	}
+}
+
+// Done is closed when the connection is lost or closed.
+func (c *cdpConn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *cdpConn) Close() error {
+	return c.ws.Close()
+}
@@ -0,0 +1,122 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package browser
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// How long a browser may take to start listening for DevTools connections.
+const launchTimeout = 30 * time.Second
+
+// The names of the Chrome and Chromium executables that are looked for in the PATH, if the
+// browserExecutable option isn't set.
+var browserExecutables = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome",
+}
+
+var devToolsURLRE = regexp.MustCompile(`DevTools listening on (ws://\S+)`)
+
+// A launcher starts a browser, and returns the URL of its DevTools endpoint and a function that
+// stops it.
+type launcher func(ctx context.Context, executable string) (string, func(), error)
+
+// launchChrome starts a headless Chrome or Chromium with its own profile, which is removed when
+// it's stopped.
+func launchChrome(ctx context.Context, executable string) (string, func(), error) {
+	if executable == "" {
+		for _, name := range browserExecutables {
+			if path, err := exec.LookPath(name); err == nil {
+				executable = path
+				break
+			}
+		}
+		if executable == "" {
+			return "", nil, errors.New("couldn't find Chrome or Chromium, set the browserExecutable option")
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "k6-browser")
+	if err != nil {
+		return "", nil, err
+	}
+	args := []string{
+		"--headless", "--remote-debugging-port=0", "--user-data-dir=" + dir,
+		"--no-first-run", "--no-default-browser-check", "--disable-gpu", "--mute-audio",
+	}
+	if os.Geteuid() == 0 {
+		// Chrome refuses to run as root with its sandbox, eg. in containers.
+		args = append(args, "--no-sandbox")
+	}
+	cmd := exec.Command(executable, append(args, "about:blank")...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, errors.Wrap(err, "couldn't start the browser")
+	}
+	stop := func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		_ = os.RemoveAll(dir)
+	}
+
+	urls := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			if m := devToolsURLRE.FindStringSubmatch(scanner.Text()); m != nil {
+				urls <- m[1]
+				break
+			}
+		}
+		close(urls)
+		// Keep reading, so that the browser doesn't block on a full pipe.
+		for scanner.Scan() {
+		}
+	}()
+
+	select {
+	case url, ok := <-urls:
+		if !ok {
+			stop()
+			return "", nil, errors.Errorf("the browser %s exited without listening for DevTools connections", executable)
+		}
+		return url, stop, nil
+	case <-time.After(launchTimeout):
+		stop()
+		return "", nil, errors.Errorf("the browser %s didn't start in %s", executable, launchTimeout)
+	case <-ctx.Done():
+		stop()
+		return "", nil, ctx.Err()
+	}
+}
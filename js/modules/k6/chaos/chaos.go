@@ -0,0 +1,183 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// ErrChaosInInitContext is returned when faults are injected in the init context.
+var ErrChaosInInitContext = common.NewInitContextError("Faults can't be injected in the init context")
+
+// The status requests are responded to with in error mode, by default.
+const defaultErrorStatus = 503
+
+// Chaos is the k6/chaos module, which injects faults into the HTTP requests of all VUs, for
+// resilience experiments that are scripted along with the load, and reported to outputs in the
+// fault_injections metric.
+type Chaos struct{}
+
+// New returns a new k6/chaos module.
+func New() *Chaos {
+	return &Chaos{}
+}
+
+// AbortRequests makes a fraction of the requests fail with an error, without sending them. It
+// returns the ID of the fault, for stop().
+func (*Chaos) AbortRequests(ctx context.Context, rate float64, paramsV goja.Value) (int64, error) {
+	f := lib.Fault{Kind: lib.FaultAbort, Rate: rate}
+	if err := parseParams(ctx, paramsV, &f); err != nil {
+		return 0, err
+	}
+	return start(ctx, f)
+}
+
+// InjectLatency delays requests by some milliseconds, all of them unless a rate is given.
+func (*Chaos) InjectLatency(ctx context.Context, delay float64, paramsV goja.Value) (int64, error) {
+	f := lib.Fault{Kind: lib.FaultLatency, Rate: 1, Delay: time.Duration(delay * float64(time.Millisecond))}
+	if err := parseParams(ctx, paramsV, &f); err != nil {
+		return 0, err
+	}
+	return start(ctx, f)
+}
+
+// ErrorMode responds to requests with an error status, 503 unless another one is given, without
+// sending them; usually to the ones with some tags, eg. to flip a scenario to error mode.
+func (*Chaos) ErrorMode(ctx context.Context, paramsV goja.Value) (int64, error) {
+	f := lib.Fault{Kind: lib.FaultError, Rate: 1, Status: defaultErrorStatus}
+	if err := parseParams(ctx, paramsV, &f); err != nil {
+		return 0, err
+	}
+	return start(ctx, f)
+}
+
+// Stop stops injecting a fault. It returns false if the fault was already stopped, or over.
+func (*Chaos) Stop(ctx context.Context, id int64) (bool, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return false, ErrChaosInInitContext
+	}
+	f, ok := state.Faults.Remove(id)
+	if ok {
+		push(ctx, state, "stop", f)
+	}
+	return ok, nil
+}
+
+// Clear stops injecting all faults.
+func (*Chaos) Clear(ctx context.Context) {
+	state := common.GetState(ctx)
+	if state == nil {
+		common.Throw(common.GetRuntime(ctx), ErrChaosInInitContext)
+	}
+	for _, f := range state.Faults.Clear() {
+		push(ctx, state, "stop", f)
+	}
+}
+
+func start(ctx context.Context, f lib.Fault) (int64, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return 0, ErrChaosInInitContext
+	}
+	id, err := state.Faults.Add(f)
+	if err != nil {
+		return 0, err
+	}
+	push(ctx, state, "start", f)
+	return id, nil
+}
+
+// parseParams sets the rate, duration (in milliseconds), status and tags of a fault.
+func parseParams(ctx context.Context, paramsV goja.Value, f *lib.Fault) error {
+	if paramsV == nil || goja.IsUndefined(paramsV) || goja.IsNull(paramsV) {
+		return nil
+	}
+	rt := common.GetRuntime(ctx)
+	params := paramsV.ToObject(rt)
+	for _, k := range params.Keys() {
+		v := params.Get(k)
+		switch k {
+		case "rate":
+			f.Rate = v.ToFloat()
+		case "duration":
+			d := time.Duration(v.ToFloat() * float64(time.Millisecond))
+			if d <= 0 {
+				return errors.New("the duration of a fault must be positive")
+			}
+			f.Until = time.Now().Add(d)
+		case "status":
+			f.Status = int(v.ToInteger())
+		case "tags":
+			if goja.IsUndefined(v) || goja.IsNull(v) {
+				continue
+			}
+			tagsObj := v.ToObject(rt)
+			f.Tags = make(map[string]string)
+			for _, key := range tagsObj.Keys() {
+				f.Tags[key] = tagsObj.Get(key).String()
+			}
+		default:
+			return errors.Errorf("unknown fault parameter '%s'", k)
+		}
+	}
+	return nil
+}
+
+// push emits a fault_injections sample for a fault that's started or stopped, tagged with its
+// parameters and the tags it matches, so the experiment can be reproduced from the output.
+func push(ctx context.Context, state *common.State, action string, f lib.Fault) {
+	tags := state.Options.RunTags.CloneTags()
+	for k, v := range f.Tags {
+		tags[k] = v
+	}
+	tags["fault"] = f.Kind
+	tags["action"] = action
+	tags["rate"] = strconv.FormatFloat(f.Rate, 'g', -1, 64)
+	switch f.Kind {
+	case lib.FaultLatency:
+		tags["delay"] = strconv.FormatFloat(stats.D(f.Delay), 'g', -1, 64)
+	case lib.FaultError:
+		tags["status"] = strconv.Itoa(f.Status)
+	}
+	if state.Options.SystemTags["group"] {
+		tags["group"] = state.Group.Path
+	}
+	if state.Options.SystemTags["vu"] {
+		tags["vu"] = strconv.FormatInt(state.Vu, 10)
+	}
+
+	stats.PushIfNotCancelled(ctx, state.Samples, stats.Sample{
+		Time:   time.Now(),
+		Metric: metrics.FaultInjections,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  1,
+	})
+}
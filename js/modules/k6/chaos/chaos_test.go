@@ -0,0 +1,118 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaos(t *testing.T) {
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &common.State{
+		Group:   root,
+		Options: lib.Options{SystemTags: lib.GetTagSet("group")},
+		Faults:  lib.NewFaultInjector(),
+		Samples: samples,
+	}
+
+	ctx := context.Background()
+	ctx = common.WithRuntime(ctx, rt)
+	ctx = common.WithState(ctx, state)
+	rt.Set("chaos", common.Bind(rt, New(), &ctx))
+
+	events := func() []map[string]string {
+		var tags []map[string]string
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				assert.Equal(t, metrics.FaultInjections, sample.Metric)
+				tags = append(tags, sample.Tags.CloneTags())
+			}
+		}
+		return tags
+	}
+
+	t.Run("Start and stop", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let abort = chaos.abortRequests(0.1, { duration: 60000 });
+		chaos.injectLatency(250, { rate: 0.5 });
+		chaos.errorMode({ status: 500, tags: { scenario: "checkout" } });
+		if (!chaos.stop(abort)) { throw new Error("not stopped"); }
+		if (chaos.stop(abort)) { throw new Error("stopped twice"); }
+		chaos.clear();
+		`)
+		require.NoError(t, err)
+
+		assert.Equal(t, []map[string]string{
+			{"fault": "abort", "action": "start", "rate": "0.1", "group": ""},
+			{"fault": "latency", "action": "start", "rate": "0.5", "delay": "250", "group": ""},
+			{"fault": "error", "action": "start", "rate": "1", "status": "500", "scenario": "checkout", "group": ""},
+			{"fault": "abort", "action": "stop", "rate": "0.1", "group": ""},
+			{"fault": "latency", "action": "stop", "rate": "0.5", "delay": "250", "group": ""},
+			{"fault": "error", "action": "stop", "rate": "1", "status": "500", "scenario": "checkout", "group": ""},
+		}, events())
+		assert.Empty(t, state.Faults.Inject(map[string]string{"scenario": "checkout"}, time.Now()))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		testdata := map[string]string{
+			"rate":      `chaos.abortRequests(2)`,
+			"delay":     `chaos.injectLatency(0)`,
+			"status":    `chaos.errorMode({ status: 42 })`,
+			"duration":  `chaos.errorMode({ duration: -1 })`,
+			"parameter": `chaos.errorMode({ code: 500 })`,
+		}
+		for name, script := range testdata {
+			t.Run(name, func(t *testing.T) {
+				_, err := common.RunString(rt, script)
+				assert.Error(t, err)
+			})
+		}
+		assert.Empty(t, events())
+	})
+}
+
+func TestChaosInitContext(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := common.WithRuntime(context.Background(), rt)
+	rt.Set("chaos", common.Bind(rt, New(), &ctx))
+
+	for _, script := range []string{`chaos.abortRequests(0.5)`, `chaos.clear()`} {
+		_, err := common.RunString(rt, script)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), ErrChaosInInitContext.Error())
+		}
+	}
+}
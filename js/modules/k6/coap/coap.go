@@ -0,0 +1,495 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package coap
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	mathrand "math/rand"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// ErrCoAPInInitContext is returned when connecting to a CoAP server from the init context
+var ErrCoAPInInitContext = common.NewInitContextError("using CoAP in the init context is not supported")
+
+// Transmission parameters, from RFC 7252, section 4.8. ackTimeout is a variable so that the tests
+// don't have to wait for seconds for a retransmission.
+var ackTimeout = 2 * time.Second
+
+const (
+	ackRandomFactor = 1.5
+	maxRetransmit   = 4
+
+	// The longest a confirmable request can wait for its acknowledgement, and the timeout for
+	// requests if the script doesn't specify one.
+	maxTransmitWait = 93 * time.Second
+
+	// Large enough for any datagram, though servers keep their messages under 1152 bytes.
+	maxMessageSize = 64 * 1024
+)
+
+type CoAP struct{}
+
+// A Response is the response to a request: its code, eg. "2.05", its payload, and its content
+// format, if it has one.
+type Response struct {
+	Code          string      `js:"code"`
+	Payload       string      `js:"payload"`
+	ContentFormat interface{} `js:"contentFormat"`
+}
+
+type connectParams struct {
+	timeout time.Duration
+	tags    map[string]string
+}
+
+// Client is a client of a CoAP server created by coap.connect(); it's only valid inside the
+// callback.
+type Client struct {
+	ctx     context.Context
+	rt      *goja.Runtime
+	state   *common.State
+	conn    net.Conn
+	host    string
+	timeout time.Duration
+	tags    map[string]string
+	nextID  uint16
+
+	// The IDs of the confirmable responses that were acknowledged, to acknowledge them again
+	// if the server retransmits them.
+	acked map[uint16]bool
+}
+
+func New() *CoAP {
+	return &CoAP{}
+}
+
+// Connect creates a client of the CoAP server at the given coap:// URL, passes it to the given
+// function, and closes it when the function returns. It returns whatever the function returns.
+func (*CoAP) Connect(ctx context.Context, rawurl string, args ...goja.Value) (goja.Value, error) {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, ErrCoAPInInitContext
+	}
+
+	// The params argument is optional
+	var callableV, paramsV goja.Value
+	switch len(args) {
+	case 2:
+		paramsV = args[0]
+		callableV = args[1]
+	case 1:
+		paramsV = goja.Undefined()
+		callableV = args[0]
+	default:
+		return nil, errors.New("invalid number of arguments to coap.connect")
+	}
+
+	fn, isFunc := goja.AssertFunction(callableV)
+	if !isFunc {
+		return nil, errors.New("last argument to coap.connect must be a function")
+	}
+
+	params := connectParams{tags: state.Options.RunTags.CloneTags()}
+	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		paramsObj := paramsV.ToObject(rt)
+		for _, k := range paramsObj.Keys() {
+			switch k {
+			case "timeout":
+				params.timeout = time.Duration(paramsObj.Get(k).ToFloat() * float64(time.Millisecond))
+			case "tags":
+				tagsV := paramsObj.Get(k)
+				if goja.IsUndefined(tagsV) || goja.IsNull(tagsV) {
+					continue
+				}
+				tagObj := tagsV.ToObject(rt)
+				if tagObj == nil {
+					continue
+				}
+				for _, key := range tagObj.Keys() {
+					params.tags[key] = tagObj.Get(key).String()
+				}
+			}
+		}
+	}
+	if state.Options.SystemTags["group"] {
+		params.tags["group"] = state.Group.Path
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "coap":
+	case "coaps":
+		// The standard library has no DTLS, and the only Go implementation, pion/dtls, needs
+		// newer golang.org/x/crypto, x/net and x/sys than the ones vendored.
+		return nil, errors.New("coaps:// URLs need DTLS, which isn't supported yet")
+	default:
+		return nil, errors.Errorf("invalid CoAP URL '%s', use: 'coap://host[:port]'", rawurl)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, errors.Errorf("invalid CoAP URL '%s', the paths go in the requests", rawurl)
+	}
+	port := "5683"
+	if u.Port() != "" {
+		port = u.Port()
+	}
+
+	dialCtx := ctx
+	if params.timeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, params.timeout)
+		defer cancel()
+	}
+	conn, err := state.Dialer.DialContext(dialCtx, "udp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	// Unblock any pending requests if the VU is interrupted.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	c := &Client{
+		ctx:     ctx,
+		rt:      rt,
+		state:   state,
+		conn:    conn,
+		timeout: params.timeout,
+		tags:    params.tags,
+		nextID:  binary.BigEndian.Uint16(id[:]),
+		acked:   make(map[uint16]bool),
+	}
+	// The server can tell which host a request is for from the address it was sent to, unless
+	// the URL has a name.
+	if net.ParseIP(u.Hostname()) == nil {
+		c.host = u.Hostname()
+	}
+	return fn(goja.Undefined(), rt.ToValue(c))
+}
+
+// Get sends a GET request for the given path, which can have a query, and returns the response.
+func (c *Client) Get(path string, paramsV goja.Value) (*Response, error) {
+	return c.request("GET", codeGET, path, nil, paramsV)
+}
+
+// Post sends a POST request with the given payload, and returns the response.
+func (c *Client) Post(path string, payload goja.Value, paramsV goja.Value) (*Response, error) {
+	return c.request("POST", codePOST, path, payload, paramsV)
+}
+
+// Put sends a PUT request with the given payload, and returns the response.
+func (c *Client) Put(path string, payload goja.Value, paramsV goja.Value) (*Response, error) {
+	return c.request("PUT", codePUT, path, payload, paramsV)
+}
+
+// Delete sends a DELETE request, and returns the response.
+func (c *Client) Delete(path string, paramsV goja.Value) (*Response, error) {
+	return c.request("DELETE", codeDELETE, path, nil, paramsV)
+}
+
+// request sends a request, waits for its response, and emits its metrics. Requests are
+// confirmable unless the params say otherwise.
+func (c *Client) request(method string, code uint8, path string, payloadV, paramsV goja.Value) (*Response, error) {
+	req := &message{typ: typeCON, code: code, token: make([]byte, 4)}
+	if _, err := rand.Read(req.token); err != nil {
+		return nil, err
+	}
+	if c.host != "" {
+		req.options = append(req.options, option{number: optionURIHost, value: []byte(c.host)})
+	}
+	if err := req.setPath(path); err != nil {
+		return nil, err
+	}
+	if payloadV != nil && !goja.IsUndefined(payloadV) && !goja.IsNull(payloadV) {
+		payload, err := toBytes(payloadV)
+		if err != nil {
+			return nil, err
+		}
+		req.payload = payload
+	}
+
+	if paramsV != nil && !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		paramsObj := paramsV.ToObject(c.rt)
+		for _, k := range paramsObj.Keys() {
+			v := paramsObj.Get(k)
+			switch k {
+			case "confirmable":
+				if !v.ToBoolean() {
+					req.typ = typeNON
+				}
+			case "contentFormat", "accept":
+				format, err := toContentFormat(v)
+				if err != nil {
+					return nil, err
+				}
+				number := uint16(optionContentFormat)
+				if k == "accept" {
+					number = optionAccept
+				}
+				req.options = append(req.options, uintOption(number, uint32(format)))
+			}
+		}
+	}
+
+	req.id = c.nextID
+	c.nextID++
+
+	start := time.Now()
+	res, retransmissions, err := c.exchange(req)
+	end := time.Now()
+
+	tags := make(map[string]string, len(c.tags)+3)
+	for k, v := range c.tags {
+		tags[k] = v
+	}
+	tags["method"] = method
+	if res != nil {
+		tags["code"] = codeString(res.code)
+	}
+	if c.state.Options.SystemTags["error"] && err != nil {
+		tags["error"] = err.Error()
+	}
+	sampleTags := stats.IntoSampleTags(&tags)
+	samples := []stats.Sample{
+		{Metric: metrics.CoAPRequests, Time: start, Tags: sampleTags, Value: 1},
+		{Metric: metrics.CoAPRequestDuration, Time: start, Tags: sampleTags, Value: stats.D(end.Sub(start))},
+	}
+	if retransmissions > 0 {
+		samples = append(samples, stats.Sample{
+			Metric: metrics.CoAPRetransmissions, Time: start, Tags: sampleTags, Value: float64(retransmissions),
+		})
+	}
+	stats.PushIfNotCancelled(c.ctx, c.state.Samples, stats.ConnectedSamples{
+		Samples: samples,
+		Tags:    sampleTags,
+		Time:    start,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &Response{Code: codeString(res.code), Payload: string(res.payload)}
+	if v, ok := res.option(optionContentFormat); ok {
+		var format uint32
+		for _, b := range v {
+			format = format<<8 | uint32(b)
+		}
+		response.ContentFormat = format
+	}
+	return response, nil
+}
+
+// exchange sends a request and returns its response, and the number of times the request was
+// retransmitted. A confirmable request is retransmitted, with an exponential back-off, until the
+// server acknowledges it, and its response can be piggybacked on the acknowledgement or sent
+// separately later, as in RFC 7252, section 5.2.
+func (c *Client) exchange(req *message) (*message, int, error) {
+	data, err := req.marshal()
+	if err != nil {
+		return nil, 0, err
+	}
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = maxTransmitWait
+	}
+	deadline := time.Now().Add(timeout)
+
+	if err := c.write(data); err != nil {
+		return nil, 0, err
+	}
+	acked := req.typ != typeCON
+	retransmissions := 0
+	wait := time.Duration(float64(ackTimeout) * (1 + mathrand.Float64()*(ackRandomFactor-1)))
+	retransmitAt := time.Now().Add(wait)
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		readDeadline := deadline
+		if !acked && retransmitAt.Before(deadline) {
+			readDeadline = retransmitAt
+		}
+		_ = c.conn.SetReadDeadline(readDeadline)
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return nil, retransmissions, c.ctx.Err()
+			}
+			netErr, ok := err.(net.Error)
+			if !ok || !netErr.Timeout() {
+				return nil, retransmissions, err
+			}
+			if !time.Now().Before(deadline) {
+				return nil, retransmissions, errors.Errorf("no response to the CoAP request in %s", timeout)
+			}
+			if retransmissions == maxRetransmit {
+				return nil, retransmissions, errors.Errorf(
+					"the CoAP request wasn't acknowledged after %d retransmissions", retransmissions)
+			}
+			if err := c.write(data); err != nil {
+				return nil, retransmissions, err
+			}
+			retransmissions++
+			wait *= 2
+			retransmitAt = time.Now().Add(wait)
+			continue
+		}
+
+		// Anything that isn't a valid message is ignored, as it could be from anyone.
+		res, err := unmarshalMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		matchesToken := string(res.token) == string(req.token)
+		switch {
+		case res.typ == typeRST && res.id == req.id:
+			return nil, retransmissions, errors.New("the CoAP server reset the request")
+		case res.typ == typeACK && res.id == req.id:
+			acked = true
+			// An empty acknowledgement means the response will be sent separately.
+			if res.code != codeEmpty && matchesToken {
+				return res, retransmissions, nil
+			}
+		case (res.typ == typeCON || res.typ == typeNON) && matchesToken:
+			if res.typ == typeCON {
+				if err := c.acknowledge(res.id); err != nil {
+					return nil, retransmissions, err
+				}
+			}
+			return res, retransmissions, nil
+		case res.typ == typeCON && c.acked[res.id]:
+			if err := c.acknowledge(res.id); err != nil {
+				return nil, retransmissions, err
+			}
+		case res.typ == typeCON:
+			if err := c.reset(res.id); err != nil {
+				return nil, retransmissions, err
+			}
+		}
+	}
+}
+
+// acknowledge sends an empty acknowledgement of the confirmable message with the given ID.
+func (c *Client) acknowledge(id uint16) error {
+	c.acked[id] = true
+	data, _ := (&message{typ: typeACK, code: codeEmpty, id: id}).marshal()
+	return c.write(data)
+}
+
+// reset rejects the confirmable message with the given ID, which isn't for any of the requests.
+func (c *Client) reset(id uint16) error {
+	data, _ := (&message{typ: typeRST, code: codeEmpty, id: id}).marshal()
+	return c.write(data)
+}
+
+func (c *Client) write(data []byte) error {
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// setPath sets the Uri-Path and Uri-Query options of a request from a path like "/a/b?c=d&e".
+func (m *message) setPath(path string) error {
+	var query string
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path, query = path[:i], path[i+1:]
+	}
+	if path = strings.TrimPrefix(path, "/"); path != "" {
+		for _, segment := range strings.Split(path, "/") {
+			v, err := url.PathUnescape(segment)
+			if err != nil {
+				return errors.Wrapf(err, "invalid path '%s'", path)
+			}
+			m.options = append(m.options, option{number: optionURIPath, value: []byte(v)})
+		}
+	}
+	if query != "" {
+		for _, arg := range strings.Split(query, "&") {
+			v, err := url.PathUnescape(arg)
+			if err != nil {
+				return errors.Wrapf(err, "invalid query '%s'", query)
+			}
+			m.options = append(m.options, option{number: optionURIQuery, value: []byte(v)})
+		}
+	}
+	return nil
+}
+
+// toContentFormat returns a content format given as its number or its media type.
+func toContentFormat(v goja.Value) (uint16, error) {
+	switch format := v.Export().(type) {
+	case int64:
+		if format >= 0 && format <= 65535 {
+			return uint16(format), nil
+		}
+	case string:
+		if number, ok := contentFormats[format]; ok {
+			return number, nil
+		}
+	}
+	return 0, errors.Errorf("invalid content format '%s', use a number or a registered media type", v.String())
+}
+
+// toBytes returns a payload given as a string or an array of bytes.
+func toBytes(v goja.Value) ([]byte, error) {
+	switch data := v.Export().(type) {
+	case string:
+		return []byte(data), nil
+	case []byte:
+		return data, nil
+	case []interface{}:
+		b := make([]byte, len(data))
+		for i, e := range data {
+			n, ok := e.(int64)
+			if !ok || n < 0 || n > 255 {
+				return nil, errors.Errorf("invalid byte at index %d: %v", i, e)
+			}
+			b[i] = byte(n)
+		}
+		return b, nil
+	default:
+		return nil, errors.Errorf("payloads can only be strings and arrays of bytes, not %T", data)
+	}
+}
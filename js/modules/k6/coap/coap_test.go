@@ -0,0 +1,335 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package coap
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A fakeServer answers requests depending on the first segment of their path. "echo" responds
+// with the method, path, queries and payload of the request, piggybacked on the acknowledgement,
+// or in a non-confirmable response to a non-confirmable request. "separate" acknowledges the
+// request, then sends a confirmable response separately. "lossy" drops the first transmission of
+// every request, "reset" resets it, and anything else is never responded to.
+type fakeServer struct {
+	conn net.PacketConn
+
+	mu      sync.Mutex
+	seen    map[uint16]bool
+	acks    int
+	pending map[uint16]bool
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeServer{conn: conn, seen: make(map[uint16]bool), pending: make(map[uint16]bool)}
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) serve() {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req, err := unmarshalMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		s.handle(req, addr)
+	}
+}
+
+func (s *fakeServer) handle(req *message, addr net.Addr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.typ == typeACK {
+		if s.pending[req.id] {
+			delete(s.pending, req.id)
+			s.acks++
+		}
+		return
+	}
+
+	var path, queries []string
+	for _, o := range req.options {
+		switch o.number {
+		case optionURIPath:
+			path = append(path, string(o.value))
+		case optionURIQuery:
+			queries = append(queries, string(o.value))
+		}
+	}
+	send := func(m *message) {
+		data, _ := m.marshal()
+		_, _ = s.conn.WriteTo(data, addr)
+	}
+	res := &message{typ: typeACK, code: 0x45, id: req.id, token: req.token}
+	if req.typ == typeNON {
+		res.typ, res.id = typeNON, req.id+1000
+	}
+
+	var resource string
+	if len(path) > 0 {
+		resource = path[0]
+	}
+	switch resource {
+	case "lossy":
+		if !s.seen[req.id] {
+			s.seen[req.id] = true
+			return
+		}
+		send(res)
+	case "echo":
+		res.options = []option{uintOption(optionContentFormat, 0)}
+		res.payload = []byte(codeString(req.code) + " " + strings.Join(path, "/") + " " +
+			strings.Join(queries, "&") + " " + string(req.payload))
+		send(res)
+	case "separate":
+		send(&message{typ: typeACK, code: codeEmpty, id: req.id})
+		s.pending[req.id+1000] = true
+		send(&message{typ: typeCON, code: 0x44, id: req.id + 1000, token: req.token})
+	case "reset":
+		send(&message{typ: typeRST, code: codeEmpty, id: req.id})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	m := &message{
+		typ:   typeCON,
+		code:  codePOST,
+		id:    0x1234,
+		token: []byte{1, 2, 3, 4},
+		options: []option{
+			{number: optionURIQuery, value: []byte("a=b")},
+			{number: optionURIPath, value: []byte("sensors")},
+			{number: optionURIPath, value: []byte(strings.Repeat("t", 300))},
+			uintOption(optionContentFormat, 50),
+			uintOption(2048, 0),
+		},
+		payload: []byte(`{"temp":21}`),
+	}
+	b, err := m.marshal()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x44, codePOST, 0x12, 0x34, 1, 2, 3, 4, 0xb7}, b[:9])
+
+	got, err := unmarshalMessage(b)
+	require.NoError(t, err)
+	assert.Equal(t, m.typ, got.typ)
+	assert.Equal(t, m.code, got.code)
+	assert.Equal(t, m.id, got.id)
+	assert.Equal(t, m.token, got.token)
+	assert.Equal(t, m.payload, got.payload)
+	assert.Equal(t, []option{
+		{number: optionURIPath, value: []byte("sensors")},
+		{number: optionURIPath, value: []byte(strings.Repeat("t", 300))},
+		{number: optionContentFormat, value: []byte{50}},
+		{number: optionURIQuery, value: []byte("a=b")},
+		{number: 2048},
+	}, got.options)
+
+	for name, b := range map[string][]byte{
+		"Short":          {0x40, 0x01},
+		"Version":        {0x80, 0x01, 0, 0},
+		"Token":          {0x49, 0x01, 0, 0},
+		"EmptyPayload":   {0x40, 0x01, 0, 0, 0xff},
+		"OptionTooShort": {0x40, 0x01, 0, 0, 0xb3, 'a'},
+		"Reserved":       {0x40, 0x01, 0, 0, 0xf0},
+	} {
+		_, err := unmarshalMessage(b)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestClient(t *testing.T) {
+	defer func(timeout time.Duration) { ackTimeout = timeout }(ackTimeout)
+	ackTimeout = 50 * time.Millisecond
+
+	server := newFakeServer(t)
+	defer func() { _ = server.conn.Close() }()
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &common.State{
+		Group:  root,
+		Dialer: netext.NewDialer(net.Dialer{Timeout: 10 * time.Second}),
+		Options: lib.Options{
+			SystemTags: lib.GetTagSet("group"),
+		},
+		Samples: samples,
+	}
+
+	ctx := context.Background()
+	ctx = common.WithState(ctx, state)
+	ctx = common.WithRuntime(ctx, rt)
+
+	rt.Set("coap", common.Bind(rt, New(), &ctx))
+	rt.Set("url", "coap://"+server.conn.LocalAddr().String())
+
+	t.Run("Requests", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		coap.connect(url, function(client) {
+			let res = client.get("/echo?unit=c&at=now");
+			if (res.code !== "2.05" || res.payload !== "0.01 echo unit=c&at=now " || res.contentFormat !== 0) {
+				throw new Error("Wrong response: " + JSON.stringify(res));
+			}
+			res = client.post("/echo", '{"temp":21}', { contentFormat: "application/json" });
+			if (res.payload !== '0.02 echo  {"temp":21}') {
+				throw new Error("Wrong POST response: " + JSON.stringify(res));
+			}
+			res = client.put("/echo/a%20b", [104, 105], { contentFormat: 42 });
+			if (res.payload !== "0.03 echo/a b  hi") {
+				throw new Error("Wrong PUT response: " + JSON.stringify(res));
+			}
+			res = client.delete("/echo", { confirmable: false });
+			if (res.payload !== "0.04 echo  ") {
+				throw new Error("Wrong DELETE response: " + JSON.stringify(res));
+			}
+		});`)
+		assert.NoError(t, err)
+
+		var methods []string
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				if sample.Metric == metrics.CoAPRequests {
+					method, _ := sample.Tags.Get("method")
+					methods = append(methods, method)
+					code, _ := sample.Tags.Get("code")
+					assert.Equal(t, "2.05", code)
+				}
+				assert.NotEqual(t, metrics.CoAPRetransmissions, sample.Metric)
+			}
+		}
+		assert.Equal(t, []string{"GET", "POST", "PUT", "DELETE"}, methods)
+	})
+
+	t.Run("Separate", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		coap.connect(url, function(client) {
+			return client.post("/separate", "reading").code;
+		});`)
+		require.NoError(t, err)
+		assert.Equal(t, "2.04", v.Export())
+
+		// The client acknowledges the confirmable response.
+		var acks int
+		for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+			server.mu.Lock()
+			acks = server.acks
+			server.mu.Unlock()
+			if acks > 0 {
+				break
+			}
+		}
+		assert.Equal(t, 1, acks)
+	})
+
+	t.Run("Retransmission", func(t *testing.T) {
+		stats.GetBufferedSamples(samples)
+		_, err := common.RunString(rt, `
+		coap.connect(url, function(client) {
+			if (client.get("/lossy").code !== "2.05") {
+				throw new Error("Wrong response");
+			}
+		});`)
+		require.NoError(t, err)
+
+		var retransmissions float64
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				if sample.Metric == metrics.CoAPRetransmissions {
+					retransmissions += sample.Value
+				}
+			}
+		}
+		assert.Equal(t, 1.0, retransmissions)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := common.RunString(rt, `coap.connect(url, function(client) { client.get("/reset"); });`)
+		assert.EqualError(t, err, "GoError: the CoAP server reset the request")
+
+		_, err = common.RunString(rt, `coap.connect(url, function(client) { client.get("/silent"); });`)
+		assert.EqualError(t, err, "GoError: the CoAP request wasn't acknowledged after 4 retransmissions")
+
+		_, err = common.RunString(rt, `
+		coap.connect(url, { timeout: 100 }, function(client) {
+			client.get("/silent", { confirmable: false });
+		});`)
+		assert.EqualError(t, err, "GoError: no response to the CoAP request in 100ms")
+
+		_, err = common.RunString(rt, `
+		coap.connect(url, function(client) {
+			client.post("/echo", "", { contentFormat: "text/html" });
+		});`)
+		assert.EqualError(t, err,
+			"GoError: invalid content format 'text/html', use a number or a registered media type")
+
+		_, err = common.RunString(rt, `coap.connect("coaps://127.0.0.1", function(client) {});`)
+		assert.EqualError(t, err, "GoError: coaps:// URLs need DTLS, which isn't supported yet")
+
+		_, err = common.RunString(rt, `coap.connect("http://127.0.0.1", function(client) {});`)
+		assert.EqualError(t, err, "GoError: invalid CoAP URL 'http://127.0.0.1', use: 'coap://host[:port]'")
+
+		_, err = common.RunString(rt, `coap.connect(url, {});`)
+		assert.EqualError(t, err, "GoError: last argument to coap.connect must be a function")
+	})
+
+	t.Run("Dialer", func(t *testing.T) {
+		_, ipNet, err := net.ParseCIDR("127.0.0.0/8")
+		require.NoError(t, err)
+		state.Dialer.Blacklist = []*net.IPNet{ipNet}
+		defer func() { state.Dialer.Blacklist = nil }()
+
+		_, err = common.RunString(rt, `coap.connect(url, function(client) {});`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "IP (127.0.0.1) is in a blacklisted range (127.0.0.0/8)")
+		}
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		initCtx := common.WithRuntime(context.Background(), rt)
+		rt.Set("initCoAP", common.Bind(rt, New(), &initCtx))
+		_, err := common.RunString(rt, `initCoAP.connect(url, function(client) {});`)
+		assert.Contains(t, err.Error(), "using CoAP in the init context is not supported")
+	})
+}
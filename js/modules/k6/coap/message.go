@@ -0,0 +1,231 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package coap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Message types, from RFC 7252, section 3.
+const (
+	typeCON = 0
+	typeNON = 1
+	typeACK = 2
+	typeRST = 3
+)
+
+// Method and response codes; a code is a class in the top 3 bits and a detail in the bottom 5.
+const (
+	codeEmpty  = 0x00
+	codeGET    = 0x01
+	codePOST   = 0x02
+	codePUT    = 0x03
+	codeDELETE = 0x04
+)
+
+// Option numbers, from RFC 7252, section 5.10.
+const (
+	optionURIHost       = 3
+	optionURIPath       = 11
+	optionContentFormat = 12
+	optionURIQuery      = 15
+	optionAccept        = 17
+)
+
+// payloadMarker separates the options of a message from its payload.
+const payloadMarker = 0xff
+
+// contentFormats are the registered content formats by media type, from RFC 7252, section 12.3.
+var contentFormats = map[string]uint16{
+	"text/plain; charset=utf-8": 0,
+	"text/plain":                0,
+	"application/link-format":   40,
+	"application/xml":           41,
+	"application/octet-stream":  42,
+	"application/exi":           47,
+	"application/json":          50,
+	"application/cbor":          60,
+}
+
+type option struct {
+	number uint16
+	value  []byte
+}
+
+type message struct {
+	typ     uint8
+	code    uint8
+	id      uint16
+	token   []byte
+	options []option
+	payload []byte
+}
+
+// codeString returns a code in its "class.detail" form, eg. "2.05" for Content.
+func codeString(code uint8) string {
+	return fmt.Sprintf("%d.%02d", code>>5, code&0x1f)
+}
+
+// uintOption returns an option with an unsigned integer value, in as few bytes as it takes.
+func uintOption(number uint16, v uint32) option {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	i := 0
+	for i < len(b) && b[i] == 0 {
+		i++
+	}
+	return option{number: number, value: b[i:]}
+}
+
+// option returns the value of the first option with the given number.
+func (m *message) option(number uint16) ([]byte, bool) {
+	for _, o := range m.options {
+		if o.number == number {
+			return o.value, true
+		}
+	}
+	return nil, false
+}
+
+// marshal encodes the message in the format of RFC 7252, section 3.
+func (m *message) marshal() ([]byte, error) {
+	if len(m.token) > 8 {
+		return nil, errors.Errorf("the token is %d bytes long, the maximum is 8", len(m.token))
+	}
+	b := make([]byte, 4, 4+len(m.token)+len(m.payload)+16)
+	b[0] = 1<<6 | m.typ<<4 | uint8(len(m.token))
+	b[1] = m.code
+	binary.BigEndian.PutUint16(b[2:], m.id)
+	b = append(b, m.token...)
+
+	// Options are encoded in order of their numbers, each as the delta from the previous one.
+	options := make([]option, len(m.options))
+	copy(options, m.options)
+	sort.SliceStable(options, func(i, j int) bool { return options[i].number < options[j].number })
+	var prev uint16
+	for _, o := range options {
+		if len(o.value) > 65535+269 {
+			return nil, errors.Errorf("the value of option %d is too long", o.number)
+		}
+		delta, deltaExt := optionNibble(int(o.number - prev))
+		length, lengthExt := optionNibble(len(o.value))
+		b = append(b, delta<<4|length)
+		b = append(b, deltaExt...)
+		b = append(b, lengthExt...)
+		b = append(b, o.value...)
+		prev = o.number
+	}
+
+	if len(m.payload) > 0 {
+		b = append(b, payloadMarker)
+		b = append(b, m.payload...)
+	}
+	return b, nil
+}
+
+// optionNibble returns the 4 bit form of an option delta or length, and the extended bytes that
+// follow the option's first byte for values that don't fit in it.
+func optionNibble(v int) (uint8, []byte) {
+	switch {
+	case v < 13:
+		return uint8(v), nil
+	case v < 269:
+		return 13, []byte{uint8(v - 13)}
+	default:
+		ext := make([]byte, 2)
+		binary.BigEndian.PutUint16(ext, uint16(v-269))
+		return 14, ext
+	}
+}
+
+// unmarshalMessage decodes a message in the format of RFC 7252, section 3.
+func unmarshalMessage(b []byte) (*message, error) {
+	if len(b) < 4 {
+		return nil, errors.New("message too short")
+	}
+	if b[0]>>6 != 1 {
+		return nil, errors.Errorf("unsupported version %d", b[0]>>6)
+	}
+	tokenLength := int(b[0] & 0x0f)
+	if tokenLength > 8 || len(b) < 4+tokenLength {
+		return nil, errors.New("invalid token length")
+	}
+	m := &message{
+		typ:   (b[0] >> 4) & 0x03,
+		code:  b[1],
+		id:    binary.BigEndian.Uint16(b[2:]),
+		token: append([]byte(nil), b[4:4+tokenLength]...),
+	}
+
+	b = b[4+tokenLength:]
+	var number int
+	for len(b) > 0 {
+		if b[0] == payloadMarker {
+			if len(b) == 1 {
+				return nil, errors.New("payload marker without a payload")
+			}
+			m.payload = append([]byte(nil), b[1:]...)
+			break
+		}
+		delta, length := int(b[0]>>4), int(b[0]&0x0f)
+		b = b[1:]
+		var err error
+		if delta, b, err = readOptionNibble(delta, b); err != nil {
+			return nil, err
+		}
+		if length, b, err = readOptionNibble(length, b); err != nil {
+			return nil, err
+		}
+		if len(b) < length {
+			return nil, errors.New("option value too short")
+		}
+		number += delta
+		if number > 65535 {
+			return nil, errors.New("invalid option number")
+		}
+		m.options = append(m.options, option{number: uint16(number), value: append([]byte(nil), b[:length]...)})
+		b = b[length:]
+	}
+	return m, nil
+}
+
+// readOptionNibble reads the extended bytes of an option delta or length, if it has any.
+func readOptionNibble(v int, b []byte) (int, []byte, error) {
+	switch v {
+	case 13:
+		if len(b) < 1 {
+			return 0, nil, errors.New("option too short")
+		}
+		return int(b[0]) + 13, b[1:], nil
+	case 14:
+		if len(b) < 2 {
+			return 0, nil, errors.New("option too short")
+		}
+		return int(binary.BigEndian.Uint16(b)) + 269, b[2:], nil
+	case 15:
+		return 0, nil, errors.New("invalid option: reserved value 15")
+	}
+	return v, b, nil
+}
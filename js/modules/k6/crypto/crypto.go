@@ -36,8 +36,23 @@ import (
 	"golang.org/x/crypto/ripemd160"
 
 	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/js/modules/k6/crypto/sm3"
 )
 
+// HashFuncs maps the algorithm names accepted by createHash() and createHMAC() to their hashes.
+var HashFuncs = map[string]func() hash.Hash{
+	"md4":        md4.New,
+	"md5":        md5.New,
+	"sha1":       sha1.New,
+	"sha256":     sha256.New,
+	"sha384":     sha512.New384,
+	"sha512_224": sha512.New512_224,
+	"sha512_256": sha512.New512_256,
+	"sha512":     sha512.New,
+	"ripemd160":  ripemd160.New,
+	"sm3":        sm3.New,
+}
+
 type Crypto struct{}
 
 type Hasher struct {
@@ -104,29 +119,18 @@ func (c *Crypto) Ripemd160(ctx context.Context, input []byte, outputEncoding str
 	return hasher.Digest(outputEncoding)
 }
 
+func (c *Crypto) Sm3(ctx context.Context, input []byte, outputEncoding string) string {
+	hasher := c.CreateHash(ctx, "sm3")
+	hasher.Update(input)
+	return hasher.Digest(outputEncoding)
+}
+
 func (*Crypto) CreateHash(ctx context.Context, algorithm string) *Hasher {
 	hasher := Hasher{}
 	hasher.ctx = ctx
 
-	switch algorithm {
-	case "md4":
-		hasher.hash = md4.New()
-	case "md5":
-		hasher.hash = md5.New()
-	case "sha1":
-		hasher.hash = sha1.New()
-	case "sha256":
-		hasher.hash = sha256.New()
-	case "sha384":
-		hasher.hash = sha512.New384()
-	case "sha512_224":
-		hasher.hash = sha512.New512_224()
-	case "sha512_256":
-		hasher.hash = sha512.New512_256()
-	case "sha512":
-		hasher.hash = sha512.New()
-	case "ripemd160":
-		hasher.hash = ripemd160.New()
+	if fn, ok := HashFuncs[algorithm]; ok {
+		hasher.hash = fn()
 	}
 
 	return &hasher
@@ -168,26 +172,9 @@ func (c Crypto) CreateHMAC(ctx context.Context, algorithm string, key string) *H
 	hasher.ctx = ctx
 	keyBuffer := []byte(key)
 
-	switch algorithm {
-	case "md4":
-		hasher.hash = hmac.New(md4.New, keyBuffer)
-	case "md5":
-		hasher.hash = hmac.New(md5.New, keyBuffer)
-	case "sha1":
-		hasher.hash = hmac.New(sha1.New, keyBuffer)
-	case "sha256":
-		hasher.hash = hmac.New(sha256.New, keyBuffer)
-	case "sha384":
-		hasher.hash = hmac.New(sha512.New384, keyBuffer)
-	case "sha512_224":
-		hasher.hash = hmac.New(sha512.New512_224, keyBuffer)
-	case "sha512_256":
-		hasher.hash = hmac.New(sha512.New512_256, keyBuffer)
-	case "sha512":
-		hasher.hash = hmac.New(sha512.New, keyBuffer)
-	case "ripemd160":
-		hasher.hash = hmac.New(ripemd160.New, keyBuffer)
-	default:
+	if fn, ok := HashFuncs[algorithm]; ok {
+		hasher.hash = hmac.New(fn, keyBuffer)
+	} else {
 		err := errors.New("Invalid algorithm: " + algorithm)
 		common.Throw(common.GetRuntime(hasher.ctx), err)
 	}
@@ -138,6 +138,17 @@ func TestCryptoAlgorithms(t *testing.T) {
 
 		assert.NoError(t, err)
 	})
+
+	t.Run("SM3", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let hash = crypto.sm3("hello world", "hex");
+		const correct = "44f0061e69fa6fdfc290c494654a05dc0c053da7e5c52b84ef93a9d67d3fff88";
+		if (hash !== correct) {
+			throw new Error("Hash mismatch: " + hash);
+		}`)
+
+		assert.NoError(t, err)
+	})
 }
 
 func TestStreamingApi(t *testing.T) {
@@ -293,6 +304,7 @@ func TestHMac(t *testing.T) {
 		"sha512_256": "e3d0763ba92a4f40676c3d5b234d9842b71951e6e0767082cfb3f5e14c124b22",
 		"sha512":     "cd3146f96a3005024108ff56b025517552435589a4c218411f165da0a368b6f47228b20a1a4bf081e4aae6f07e2790f27194fc77f0addc890e98ce1951cacc9f",
 		"ripemd160":  "00bb4ce0d6afd4c7424c9d01b8a6caa3e749b08b",
+		"sm3":        "3c9b776d294a4ca73249c669c1ba7c933b34de1e4cd53832d98a8023407c51c5",
 	}
 	for algorithm, value := range testData {
 		rt.Set("correctHex", rt.ToValue(value))
@@ -0,0 +1,236 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package openpgp implements the k6/crypto/openpgp module, which signs, verifies, encrypts and
+// decrypts OpenPGP messages.
+//
+// It's limited by what golang.org/x/crypto/openpgp supports: RSA keys can do everything, but ECC
+// keys can only sign and verify, with ECDSA. The package can't encrypt to ECDH keys, and it can't
+// read secret ECDH keys at all, so ECC keys have to be exported without their encryption
+// subkeys. EdDSA keys aren't supported.
+package openpgp
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	pgperrors "golang.org/x/crypto/openpgp/errors"
+)
+
+// OpenPGP is the k6/crypto/openpgp module.
+type OpenPGP struct{}
+
+// New returns a new k6/crypto/openpgp module.
+func New() *OpenPGP {
+	return &OpenPGP{}
+}
+
+// A Key is a parsed OpenPGP key, public or private, with its subkeys.
+type Key struct {
+	// The fingerprint of the primary key, in upper case hex.
+	Fingerprint string `js:"fingerprint"`
+	// The long ID of the primary key, in upper case hex.
+	KeyID string `js:"keyId"`
+	// The user IDs, eg. "Name <name@example.com>".
+	UserIDs []string `js:"userIds"`
+	// Whether the private key is there, to sign and decrypt with.
+	IsPrivate bool `js:"isPrivate"`
+
+	entity *openpgp.Entity
+}
+
+// ReadKey reads an armored key, as exported by `gpg --armor --export` or
+// `gpg --armor --export-secret-keys`. Encrypted private keys are decrypted with the passphrase.
+func (*OpenPGP) ReadKey(armored string, passphrase string) (*Key, error) {
+	entities, err := readKeyRing(armored)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't read the key")
+	}
+	if len(entities) != 1 {
+		return nil, errors.Errorf("expected one key, got %d", len(entities))
+	}
+	entity := entities[0]
+
+	if entity.PrivateKey != nil && entity.PrivateKey.Encrypted {
+		if passphrase == "" {
+			return nil, errors.New("the private key is encrypted, but no passphrase was given")
+		}
+		if err := entity.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+			return nil, errors.Wrap(err, "couldn't decrypt the private key")
+		}
+	}
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			if err := subkey.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return nil, errors.Wrap(err, "couldn't decrypt the private key")
+			}
+		}
+	}
+
+	key := &Key{
+		Fingerprint: fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint),
+		KeyID:       fmt.Sprintf("%016X", entity.PrimaryKey.KeyId),
+		UserIDs:     []string{},
+		IsPrivate:   entity.PrivateKey != nil,
+		entity:      entity,
+	}
+	for name := range entity.Identities {
+		key.UserIDs = append(key.UserIDs, name)
+	}
+	sort.Strings(key.UserIDs)
+	return key, nil
+}
+
+// readKeyRing reads armored keys. The packet parser panics on the secret keys it doesn't
+// support, which gpg makes for the encryption subkeys of ECC keys.
+func readKeyRing(armored string) (entities openpgp.EntityList, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("unsupported secret key type, eg. ECDH")
+		}
+	}()
+	return openpgp.ReadArmoredKeyRing(strings.NewReader(armored))
+}
+
+// Sign returns an armored detached signature of data, like `gpg --armor --detach-sign`.
+func (*OpenPGP) Sign(key *Key, data []byte) (string, error) {
+	if err := checkPrivate(key); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&buf, key.entity, bytes.NewReader(data), nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Verify returns whether an armored detached signature was made by the key over data.
+func (*OpenPGP) Verify(key *Key, data []byte, signature string) (bool, error) {
+	if key == nil {
+		return false, errors.New("a key is required")
+	}
+	_, err := openpgp.CheckArmoredDetachedSignature(
+		openpgp.EntityList{key.entity}, bytes.NewReader(data), strings.NewReader(signature),
+	)
+	switch err.(type) {
+	case nil:
+		return true, nil
+	case pgperrors.SignatureError:
+		return false, nil
+	}
+	if err == pgperrors.ErrUnknownIssuer {
+		return false, nil
+	}
+	return false, errors.Wrap(err, "invalid OpenPGP signature")
+}
+
+// Encrypt encrypts data for one or more recipients, like `gpg --armor --encrypt`, and returns
+// the armored message. If a signer is given, the message is signed by it as well.
+func (*OpenPGP) Encrypt(recipients []*Key, data []byte, signer *Key) (string, error) {
+	if len(recipients) == 0 {
+		return "", errors.New("at least one recipient is required")
+	}
+	to := make(openpgp.EntityList, len(recipients))
+	for i, key := range recipients {
+		if key == nil {
+			return "", errors.New("a recipient is required")
+		}
+		to[i] = key.entity
+	}
+	var from *openpgp.Entity
+	if signer != nil {
+		if err := checkPrivate(signer); err != nil {
+			return "", err
+		}
+		from = signer.entity
+	}
+
+	var buf bytes.Buffer
+	armored, err := armor.Encode(&buf, "PGP MESSAGE", nil)
+	if err != nil {
+		return "", err
+	}
+	w, err := openpgp.Encrypt(armored, to, from, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if err := armored.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Decrypt decrypts an armored message with the key and returns its contents. If a signer is
+// given, the message has to be signed by it.
+func (*OpenPGP) Decrypt(key *Key, message string, signer *Key) (string, error) {
+	if err := checkPrivate(key); err != nil {
+		return "", err
+	}
+	block, err := armor.Decode(strings.NewReader(message))
+	if err != nil {
+		return "", errors.Wrap(err, "invalid OpenPGP message")
+	}
+	keyring := openpgp.EntityList{key.entity}
+	if signer != nil {
+		keyring = append(keyring, signer.entity)
+	}
+	md, err := openpgp.ReadMessage(block.Body, keyring, nil, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't decrypt the message")
+	}
+	// The signature is only checked once the whole body has been read.
+	data, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't decrypt the message")
+	}
+	if signer != nil {
+		switch {
+		case !md.IsSigned:
+			return "", errors.New("the message isn't signed")
+		case md.SignedBy == nil || md.SignedBy.Entity != signer.entity:
+			return "", errors.Errorf("the message isn't signed by %s, but by %016X", signer.KeyID, md.SignedByKeyId)
+		case md.SignatureError != nil:
+			return "", errors.Wrap(md.SignatureError, "invalid signature")
+		}
+	}
+	return string(data), nil
+}
+
+func checkPrivate(key *Key) error {
+	if key == nil {
+		return errors.New("a private key is required")
+	}
+	if !key.IsPrivate {
+		return errors.Errorf("%s is a public key, a private key is required", key.KeyID)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package sm3 implements the SM3 hash algorithm, as defined in GB/T 32905-2016 and
+// draft-sca-cfrg-sm3.
+package sm3
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// The size of an SM3 checksum in bytes.
+const Size = 32
+
+// The block size of SM3 in bytes.
+const BlockSize = 64
+
+var iv = [8]uint32{
+	0x7380166f, 0x4914b2b9, 0x172442d7, 0xda8a0600,
+	0xa96f30bc, 0x163138aa, 0xe38dee4d, 0xb0fb0e4e,
+}
+
+// digest represents the partial evaluation of a checksum.
+type digest struct {
+	s   [8]uint32       // running context
+	x   [BlockSize]byte // temporary buffer
+	nx  int             // index into x
+	len uint64          // total count of bytes processed
+}
+
+// New returns a new hash.Hash computing the SM3 checksum.
+func New() hash.Hash {
+	d := new(digest)
+	d.Reset()
+	return d
+}
+
+// Sum returns the SM3 checksum of the data.
+func Sum(data []byte) [Size]byte {
+	var d digest
+	d.Reset()
+	_, _ = d.Write(data)
+	var sum [Size]byte
+	d.checkSum(sum[:0])
+	return sum
+}
+
+func (d *digest) Reset() {
+	d.s = iv
+	d.nx = 0
+	d.len = 0
+}
+
+func (d *digest) Size() int { return Size }
+
+func (d *digest) BlockSize() int { return BlockSize }
+
+func (d *digest) Write(p []byte) (int, error) {
+	nn := len(p)
+	d.len += uint64(nn)
+	if d.nx > 0 {
+		n := copy(d.x[d.nx:], p)
+		d.nx += n
+		if d.nx == BlockSize {
+			block(d, d.x[:])
+			d.nx = 0
+		}
+		p = p[n:]
+	}
+	if len(p) >= BlockSize {
+		n := len(p) &^ (BlockSize - 1)
+		block(d, p[:n])
+		p = p[n:]
+	}
+	if len(p) > 0 {
+		d.nx = copy(d.x[:], p)
+	}
+	return nn, nil
+}
+
+func (d *digest) Sum(in []byte) []byte {
+	// Make a copy of d so that the caller can keep writing and summing.
+	d0 := *d
+	return d0.checkSum(in)
+}
+
+func (d *digest) checkSum(in []byte) []byte {
+	// Padding: a 1 bit, zeroes up to 56 mod 64 bytes, then the message length in bits.
+	length := d.len
+	var tmp [BlockSize]byte
+	tmp[0] = 0x80
+	if length%BlockSize < 56 {
+		_, _ = d.Write(tmp[0 : 56-length%BlockSize])
+	} else {
+		_, _ = d.Write(tmp[0 : BlockSize+56-length%BlockSize])
+	}
+	binary.BigEndian.PutUint64(tmp[:8], length<<3)
+	_, _ = d.Write(tmp[:8])
+
+	var sum [Size]byte
+	for i, s := range d.s {
+		binary.BigEndian.PutUint32(sum[i*4:], s)
+	}
+	return append(in, sum[:]...)
+}
+
+func p0(x uint32) uint32 { return x ^ bits.RotateLeft32(x, 9) ^ bits.RotateLeft32(x, 17) }
+
+func p1(x uint32) uint32 { return x ^ bits.RotateLeft32(x, 15) ^ bits.RotateLeft32(x, 23) }
+
+// block runs the compression function over every full block in p.
+func block(d *digest, p []byte) {
+	var w [68]uint32
+	for ; len(p) >= BlockSize; p = p[BlockSize:] {
+		for i := 0; i < 16; i++ {
+			w[i] = binary.BigEndian.Uint32(p[i*4:])
+		}
+		for i := 16; i < 68; i++ {
+			w[i] = p1(w[i-16]^w[i-9]^bits.RotateLeft32(w[i-3], 15)) ^ bits.RotateLeft32(w[i-13], 7) ^ w[i-6]
+		}
+
+		a, b, c, dd, e, f, g, h := d.s[0], d.s[1], d.s[2], d.s[3], d.s[4], d.s[5], d.s[6], d.s[7]
+		for j := 0; j < 64; j++ {
+			var t, ff, gg uint32
+			if j < 16 {
+				t = 0x79cc4519
+				ff = a ^ b ^ c
+				gg = e ^ f ^ g
+			} else {
+				t = 0x7a879d8a
+				ff = (a & b) | (a & c) | (b & c)
+				gg = (e & f) | (^e & g)
+			}
+			a12 := bits.RotateLeft32(a, 12)
+			ss1 := bits.RotateLeft32(a12+e+bits.RotateLeft32(t, j%32), 7)
+			ss2 := ss1 ^ a12
+			tt1 := ff + dd + ss2 + (w[j] ^ w[j+4])
+			tt2 := gg + h + ss1 + w[j]
+			dd, c, b, a = c, bits.RotateLeft32(b, 9), a, tt1
+			h, g, f, e = g, bits.RotateLeft32(f, 19), e, p0(tt2)
+		}
+
+		d.s[0] ^= a
+		d.s[1] ^= b
+		d.s[2] ^= c
+		d.s[3] ^= dd
+		d.s[4] ^= e
+		d.s[5] ^= f
+		d.s[6] ^= g
+		d.s[7] ^= h
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sm3
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSM3(t *testing.T) {
+	testdata := map[string]string{
+		"":                           "1ab21d8355cfa17f8e61194831e81a8f22bec8c728fefb747ed035eb5082aa2b",
+		"abc":                        "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0",
+		"hello world":                "44f0061e69fa6fdfc290c494654a05dc0c053da7e5c52b84ef93a9d67d3fff88",
+		strings.Repeat("abcd", 16):   "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732",
+		strings.Repeat("a", 1000000): "c8aaf89429554029e231941a2acc0ad61ff2a5acd8fadd25847a3a732b3b02c3",
+	}
+	for input, output := range testdata {
+		name := input
+		if len(name) > 16 {
+			name = name[:16] + "..."
+		}
+		t.Run(name, func(t *testing.T) {
+			sum := Sum([]byte(input))
+			assert.Equal(t, output, hex.EncodeToString(sum[:]))
+
+			// Feed the same input in uneven chunks, to exercise the block buffering.
+			h := New()
+			for i, step := 0, 1; i < len(input); i, step = i+step, step*3+1 {
+				end := i + step
+				if end > len(input) {
+					end = len(input)
+				}
+				_, _ = h.Write([]byte(input[i:end]))
+			}
+			assert.Equal(t, output, hex.EncodeToString(h.Sum(nil)))
+
+			// Summing must not disturb the running state.
+			assert.Equal(t, output, hex.EncodeToString(h.Sum(nil)))
+
+			h.Reset()
+			_, _ = h.Write([]byte(input))
+			assert.Equal(t, output, hex.EncodeToString(h.Sum(nil)))
+		})
+	}
+}
@@ -0,0 +1,335 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package ssh implements the k6/crypto/ssh module, which parses SSH keys and signs data with
+// them in the format of `ssh-keygen -Y sign`, described in OpenSSH's PROTOCOL.sshsig.
+package ssh
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/pem"
+	"hash"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// The framing of signatures, from PROTOCOL.sshsig.
+const (
+	sigMagic   = "SSHSIG"
+	sigVersion = 1
+	sigArmor   = "SSH SIGNATURE"
+
+	// The hash the message is signed through; ssh-keygen uses SHA-512 as well.
+	sigHash = "sha512"
+)
+
+// SSH is the k6/crypto/ssh module.
+type SSH struct{}
+
+// New returns a new k6/crypto/ssh module.
+func New() *SSH {
+	return &SSH{}
+}
+
+// A PublicKey is a parsed SSH public key.
+type PublicKey struct {
+	// The key's algorithm, eg. "ssh-ed25519" or "ssh-rsa".
+	Type string `js:"type"`
+	// The SHA-256 fingerprint, as shown by `ssh-keygen -l`.
+	Fingerprint string `js:"fingerprint"`
+	// The comment after the key in an authorized_keys line, if any.
+	Comment string `js:"comment"`
+	// The key as an authorized_keys line, without a comment.
+	AuthorizedKey string `js:"authorizedKey"`
+
+	key ssh.PublicKey
+}
+
+func newPublicKey(key ssh.PublicKey, comment string) *PublicKey {
+	return &PublicKey{
+		Type:          key.Type(),
+		Fingerprint:   ssh.FingerprintSHA256(key),
+		Comment:       comment,
+		AuthorizedKey: strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(key)), "\n"),
+		key:           key,
+	}
+}
+
+// A PrivateKey is a parsed SSH private key.
+type PrivateKey struct {
+	PublicKey *PublicKey `js:"publicKey"`
+
+	signer ssh.Signer
+	rsa    *rsa.PrivateKey
+}
+
+// ParsePublicKey parses a public key in the authorized_keys format, as in a .pub file.
+func (*SSH) ParsePublicKey(data []byte) (*PublicKey, error) {
+	key, comment, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't parse the public key")
+	}
+	return newPublicKey(key, comment), nil
+}
+
+// ParsePrivateKey parses an unencrypted private key in the OpenSSH format, or an RSA, ECDSA or
+// DSA key in the PEM format, which may be encrypted with the passphrase.
+func (*SSH) ParsePrivateKey(data []byte, passphrase string) (*PrivateKey, error) {
+	var raw interface{}
+	var err error
+	if passphrase != "" {
+		raw, err = ssh.ParseRawPrivateKeyWithPassphrase(data, []byte(passphrase))
+	} else {
+		raw, err = ssh.ParseRawPrivateKey(data)
+	}
+	if err != nil && err.Error() == "ssh: unhandled key type" {
+		// The OpenSSH format is only supported for Ed25519 and RSA keys.
+		raw, err = parseOpenSSHECDSAKey(data)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't parse the private key")
+	}
+
+	signer, err := ssh.NewSignerFromKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	key := &PrivateKey{PublicKey: newPublicKey(signer.PublicKey(), ""), signer: signer}
+	key.rsa, _ = raw.(*rsa.PrivateKey)
+	return key, nil
+}
+
+// parseOpenSSHECDSAKey parses an unencrypted ECDSA key in the OpenSSH format, from
+// PROTOCOL.key.
+func parseOpenSSHECDSAKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	magic := "openssh-key-v1\x00"
+	if block == nil || !bytes.HasPrefix(block.Bytes, []byte(magic)) {
+		return nil, errors.New("ssh: invalid openssh private key format")
+	}
+	var container struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}
+	if err := ssh.Unmarshal(block.Bytes[len(magic):], &container); err != nil {
+		return nil, err
+	}
+	if container.CipherName != "none" || container.KdfName != "none" {
+		return nil, errors.New("ssh: cannot decode encrypted private keys")
+	}
+	var keys struct {
+		Check1  uint32
+		Check2  uint32
+		KeyType string
+		Rest    []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(container.PrivKeyBlock, &keys); err != nil {
+		return nil, err
+	}
+	if keys.Check1 != keys.Check2 {
+		return nil, errors.New("ssh: checkint mismatch")
+	}
+	var key struct {
+		Curve   string
+		Pub     []byte
+		D       *big.Int
+		Comment string
+		Pad     []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(keys.Rest, &key); err != nil {
+		return nil, err
+	}
+
+	var curve elliptic.Curve
+	switch keys.KeyType + "/" + key.Curve {
+	case ssh.KeyAlgoECDSA256 + "/nistp256":
+		curve = elliptic.P256()
+	case ssh.KeyAlgoECDSA384 + "/nistp384":
+		curve = elliptic.P384()
+	case ssh.KeyAlgoECDSA521 + "/nistp521":
+		curve = elliptic.P521()
+	default:
+		return nil, errors.Errorf("ssh: unsupported key type %q", keys.KeyType)
+	}
+	x, y := elliptic.Unmarshal(curve, key.Pub)
+	if x == nil {
+		return nil, errors.New("ssh: invalid ECDSA public key")
+	}
+	return &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y}, D: key.D}, nil
+}
+
+// Sign signs data for the given namespace, which tells what the signature is for, eg. "git"
+// or "file", so that a signature meant for one use can't be passed off for another. It returns
+// the signature armored like `ssh-keygen -Y sign` does.
+func (*SSH) Sign(key *PrivateKey, data []byte, namespace string) (string, error) {
+	if key == nil {
+		return "", errors.New("a private key is required")
+	}
+	if namespace == "" {
+		return "", errors.New("a namespace is required")
+	}
+
+	digest := sha512.Sum512(data)
+	signed := signedData(namespace, sigHash, digest[:])
+	var sig *ssh.Signature
+	if key.rsa != nil {
+		// SHA-1 signatures, the only ones the ssh package makes with RSA keys, aren't accepted.
+		digest := sha512.Sum512(signed)
+		blob, err := rsa.SignPKCS1v15(rand.Reader, key.rsa, crypto.SHA512, digest[:])
+		if err != nil {
+			return "", err
+		}
+		sig = &ssh.Signature{Format: "rsa-sha2-512", Blob: blob}
+	} else {
+		var err error
+		if sig, err = key.signer.Sign(rand.Reader, signed); err != nil {
+			return "", err
+		}
+	}
+
+	blob := append([]byte(sigMagic), ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{sigVersion, key.PublicKey.key.Marshal(), namespace, "", sigHash, ssh.Marshal(sig)})...)
+	return armor(blob), nil
+}
+
+// Verify returns whether signature, as returned by sign() or `ssh-keygen -Y sign`, was made by
+// the key over data, for the given namespace.
+func (*SSH) Verify(key *PublicKey, data []byte, namespace string, signature string) (bool, error) {
+	if key == nil {
+		return false, errors.New("a public key is required")
+	}
+	blob, err := unarmor(signature)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.HasPrefix(blob, []byte(sigMagic)) {
+		return false, errors.New("invalid SSH signature: bad preamble")
+	}
+	var s struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if err := ssh.Unmarshal(blob[len(sigMagic):], &s); err != nil {
+		return false, errors.Wrap(err, "invalid SSH signature")
+	}
+	if s.Version != sigVersion {
+		return false, errors.Errorf("unsupported SSH signature version %d", s.Version)
+	}
+	var sig ssh.Signature
+	if err := ssh.Unmarshal(s.Signature, &sig); err != nil {
+		return false, errors.Wrap(err, "invalid SSH signature")
+	}
+	if !bytes.Equal(s.PublicKey, key.key.Marshal()) || s.Namespace != namespace {
+		return false, nil
+	}
+
+	var h hash.Hash
+	switch s.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return false, errors.Errorf("unsupported SSH signature hash '%s'", s.HashAlgorithm)
+	}
+	_, _ = h.Write(data)
+	signed := signedData(namespace, s.HashAlgorithm, h.Sum(nil))
+
+	// The ssh package only verifies SHA-1 signatures for RSA keys.
+	if pub, ok := key.key.(ssh.CryptoPublicKey).CryptoPublicKey().(*rsa.PublicKey); ok {
+		var hashFunc crypto.Hash
+		switch sig.Format {
+		case "rsa-sha2-256":
+			hashFunc = crypto.SHA256
+		case "rsa-sha2-512":
+			hashFunc = crypto.SHA512
+		default:
+			return false, nil
+		}
+		h := hashFunc.New()
+		_, _ = h.Write(signed)
+		return rsa.VerifyPKCS1v15(pub, hashFunc, h.Sum(nil), sig.Blob) == nil, nil
+	}
+	return key.key.Verify(signed, &sig) == nil, nil
+}
+
+// signedData returns what's actually signed for a message with the given digest.
+func signedData(namespace, hashAlgorithm string, digest []byte) []byte {
+	return append([]byte(sigMagic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Digest        []byte
+	}{namespace, "", hashAlgorithm, digest})...)
+}
+
+// armor encodes a signature blob like ssh-keygen does, with lines of 70 characters.
+func armor(blob []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var buf strings.Builder
+	buf.WriteString("-----BEGIN " + sigArmor + "-----\n")
+	for len(encoded) > 70 {
+		buf.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	buf.WriteString(encoded + "\n")
+	buf.WriteString("-----END " + sigArmor + "-----\n")
+	return buf.String()
+}
+
+// unarmor decodes an armored signature.
+func unarmor(signature string) ([]byte, error) {
+	begin, end := "-----BEGIN "+sigArmor+"-----", "-----END "+sigArmor+"-----"
+	signature = strings.TrimSpace(signature)
+	if !strings.HasPrefix(signature, begin) || !strings.HasSuffix(signature, end) {
+		return nil, errors.New("invalid SSH signature: it isn't armored")
+	}
+	encoded := strings.Join(strings.Fields(signature[len(begin):len(signature)-len(end)]), "")
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid SSH signature")
+	}
+	return blob, nil
+}
@@ -0,0 +1,395 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+)
+
+// Ways in which a Feeder hands out records.
+const (
+	// Every VU goes through all the records in order.
+	ModeSequential = "sequential"
+	// All VUs share one cursor, so every record is only handed out once.
+	ModeUnique = "unique"
+	// Every VU always gets the record matching its ID, so no two VUs share a record.
+	ModeVU = "vu"
+	// Every call returns a random record.
+	ModeRandom = "random"
+)
+
+// What a Feeder does when it runs out of records.
+const (
+	// Start over from the first record.
+	ExhaustedRecycle = "recycle"
+	// Return null.
+	ExhaustedNull = "null"
+	// Throw an error.
+	ExhaustedThrow = "throw"
+)
+
+// A feed is the data behind all the Feeders with the same name, which is parsed only once and
+// shared between all VUs.
+type feed struct {
+	name string
+
+	// Records are kept serialized, so that every VU gets its own copy to modify.
+	header []string
+	rows   [][]string
+	json   []json.RawMessage
+
+	// Shared cursor for ModeUnique.
+	next int64
+
+	// How the feed was first declared, which every other declaration has to match.
+	declaration feedDeclaration
+}
+
+// A feedDeclaration is what a Feeder was declared with: a digest of its data, and its params.
+type feedDeclaration struct {
+	digest      [sha256.Size]byte
+	format      string
+	header      bool
+	delimiter   rune
+	mode        string
+	onExhausted string
+}
+
+func (f *feed) len() int {
+	if f.json != nil {
+		return len(f.json)
+	}
+	return len(f.rows)
+}
+
+// record returns a fresh JS copy of the ith record.
+func (f *feed) record(rt *goja.Runtime, i int) (goja.Value, error) {
+	if f.header != nil {
+		record := rt.NewObject()
+		for j, name := range f.header {
+			if j < len(f.rows[i]) {
+				if err := record.Set(name, f.rows[i][j]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return record, nil
+	}
+
+	var data []byte
+	if f.json != nil {
+		data = f.json[i]
+	} else {
+		var err error
+		if data, err = json.Marshal(f.rows[i]); err != nil {
+			return nil, err
+		}
+	}
+	// Go values can't be freely modified from JS, so go through JSON.parse() instead of ToValue()
+	parse, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("parse"))
+	return parse(goja.Undefined(), rt.ToValue(string(data)))
+}
+
+func parseFeed(name, data string, format string, header bool, delimiter rune) (*feed, error) {
+	f := &feed{name: name}
+
+	if format == "" {
+		format = "csv"
+		if strings.HasPrefix(strings.TrimSpace(data), "[") {
+			format = "json"
+		}
+	}
+
+	switch format {
+	case "json":
+		var records []json.RawMessage
+		if err := json.Unmarshal([]byte(data), &records); err != nil {
+			return nil, errors.Wrapf(err, "couldn't parse the data for feeder '%s'", name)
+		}
+		f.json = records
+		if f.json == nil {
+			f.json = []json.RawMessage{}
+		}
+	case "csv":
+		r := csv.NewReader(bytes.NewBufferString(data))
+		r.Comma = delimiter
+		r.FieldsPerRecord = -1
+		rows, err := r.ReadAll()
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't parse the data for feeder '%s'", name)
+		}
+		if header && len(rows) > 0 {
+			f.header = rows[0]
+			rows = rows[1:]
+		}
+		f.rows = rows
+	default:
+		return nil, errors.Errorf("unknown feeder format '%s'", format)
+	}
+	return f, nil
+}
+
+// A Feeder hands out the records of a feed to a VU.
+type Feeder struct {
+	feed        *feed
+	mode        string
+	onExhausted string
+
+	// Per-VU cursor for ModeSequential.
+	cursor int
+}
+
+// Next returns the next record for the VU, according to the feeder's mode. With an execution
+// segment, the feeder only hands out the segment's part of the records, so that instances of a
+// distributed test don't share any.
+func (f *Feeder) Next(ctx context.Context) (goja.Value, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, errors.New("feeder records can't be taken in the init context")
+	}
+	rt := common.GetRuntime(ctx)
+
+	start, end := state.Options.ExecutionSegment.Range(f.feed.len())
+	n := end - start
+	if n == 0 {
+		return f.exhausted(rt, start, n, 0)
+	}
+
+	var i int
+	switch f.mode {
+	case ModeSequential:
+		i = f.cursor
+		f.cursor++
+	case ModeUnique:
+		i = int(atomic.AddInt64(&f.feed.next, 1) - 1)
+	case ModeVU:
+		// setup() and teardown() run in VU 0, which gets the first record
+		if state.Vu > 0 {
+			i = int(state.Vu - 1)
+		}
+	case ModeRandom:
+		i = rand.Intn(n)
+	}
+
+	if i >= n {
+		return f.exhausted(rt, start, n, i)
+	}
+	return f.feed.record(rt, start+i)
+}
+
+// exhausted handles the ith record being past the n records starting at start.
+func (f *Feeder) exhausted(rt *goja.Runtime, start, n, i int) (goja.Value, error) {
+	switch {
+	case f.onExhausted == ExhaustedRecycle && n > 0:
+		return f.feed.record(rt, start+i%n)
+	case f.onExhausted == ExhaustedNull:
+		return goja.Null(), nil
+	default:
+		return nil, errors.Errorf("feeder '%s' ran out of records", f.feed.name)
+	}
+}
+
+// Length returns the number of records the feeder hands out, which is only the execution
+// segment's part of them outside of the init context.
+func (f *Feeder) Length(ctx context.Context) int {
+	if state := common.GetState(ctx); state != nil {
+		start, end := state.Options.ExecutionSegment.Range(f.feed.len())
+		return end - start
+	}
+	return f.feed.len()
+}
+
+// An idGenerator generates time-ordered UUIDv7s (RFC 9562). The 12 bits after the version are
+// a counter for IDs generated in the same millisecond, and the last 62 bits are a random node
+// ID picked once per process, so IDs don't collide between VUs nor between k6 instances. With an
+// execution segment, the 14 bits after the variant are the segment's index instead, so that
+// instances of a distributed test can't ever generate the same IDs.
+type idGenerator struct {
+	mu     sync.Mutex
+	node   uint64
+	lastMs int64
+	seq    uint16
+}
+
+// Where the execution segment's index goes in the node ID, right after the variant bits.
+const (
+	idSegmentShift = 48
+	idSegmentMask  = 0x3fff
+)
+
+func newIDGenerator() *idGenerator {
+	var node [8]byte
+	if _, err := cryptorand.Read(node[:]); err != nil {
+		panic(err)
+	}
+	return &idGenerator{node: binary.BigEndian.Uint64(node[:])}
+}
+
+func (g *idGenerator) next(now time.Time, segment *lib.ExecutionSegment) string {
+	ms := now.UnixNano() / int64(time.Millisecond)
+	node := g.node
+	if segment != nil && segment.Count > 1 {
+		node = uint64(segment.Index&idSegmentMask)<<idSegmentShift | node&(1<<idSegmentShift-1)
+	}
+
+	g.mu.Lock()
+	if ms <= g.lastMs {
+		// Same millisecond, or the clock went backwards; when the counter overflows, borrow
+		// the next millisecond rather than waiting for it.
+		g.seq++
+		if g.seq > 0xfff {
+			g.seq = 0
+			g.lastMs++
+		}
+		ms = g.lastMs
+	} else {
+		g.lastMs = ms
+		g.seq = 0
+	}
+	seq := g.seq
+	g.mu.Unlock()
+
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[8:], node)
+	binary.BigEndian.PutUint64(id[:8], uint64(ms)<<16)
+	id[6] = 0x70 | byte(seq>>8)
+	id[7] = byte(seq)
+	id[8] = 0x80 | id[8]&0x3f
+
+	h := hex.EncodeToString(id[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// Data is the k6/data module.
+type Data struct {
+	feedsLock sync.Mutex
+	feeds     map[string]*feed
+
+	ids *idGenerator
+}
+
+// New returns a new k6/data module.
+func New() *Data {
+	return &Data{feeds: make(map[string]*feed), ids: newIDGenerator()}
+}
+
+// UniqueID returns a new ID that's unique across all VUs and k6 instances, formatted as a
+// time-ordered UUID, eg. for idempotency keys.
+func (d *Data) UniqueID(ctx context.Context) string {
+	var segment *lib.ExecutionSegment
+	if state := common.GetState(ctx); state != nil {
+		segment = state.Options.ExecutionSegment
+	}
+	return d.ids.next(time.Now(), segment)
+}
+
+// XFeeder creates a Feeder for the feed with the given name. The data is only parsed the first
+// time a feed is declared; every other VU declaring a feeder with the same name shares it, and
+// has to give the same data and params.
+func (d *Data) XFeeder(ctxPtr *context.Context, name string, data string, paramsV goja.Value) (interface{}, error) {
+	if common.GetState(*ctxPtr) != nil {
+		return nil, errors.New("feeders must be declared in the init context")
+	}
+	if name == "" {
+		return nil, errors.New("feeders need a name")
+	}
+	rt := common.GetRuntime(*ctxPtr)
+
+	feeder := &Feeder{mode: ModeSequential, onExhausted: ExhaustedRecycle}
+	var format string
+	header := true
+	delimiter := ','
+	if paramsV != nil && !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		params := paramsV.ToObject(rt)
+		for _, k := range params.Keys() {
+			v := params.Get(k)
+			switch k {
+			case "mode":
+				feeder.mode = v.String()
+				switch feeder.mode {
+				case ModeSequential, ModeUnique, ModeVU, ModeRandom:
+				default:
+					return nil, errors.Errorf("unknown feeder mode '%s'", feeder.mode)
+				}
+			case "onExhausted":
+				feeder.onExhausted = v.String()
+				switch feeder.onExhausted {
+				case ExhaustedRecycle, ExhaustedNull, ExhaustedThrow:
+				default:
+					return nil, errors.Errorf("unknown feeder exhaustion policy '%s'", feeder.onExhausted)
+				}
+			case "format":
+				format = v.String()
+			case "header":
+				header = v.ToBoolean()
+			case "delimiter":
+				s := []rune(v.String())
+				if len(s) != 1 {
+					return nil, errors.New("the feeder delimiter must be a single character")
+				}
+				delimiter = s[0]
+			}
+		}
+	}
+
+	declaration := feedDeclaration{
+		digest:      sha256.Sum256([]byte(data)),
+		format:      format,
+		header:      header,
+		delimiter:   delimiter,
+		mode:        feeder.mode,
+		onExhausted: feeder.onExhausted,
+	}
+
+	d.feedsLock.Lock()
+	defer d.feedsLock.Unlock()
+	f, ok := d.feeds[name]
+	if !ok {
+		var err error
+		if f, err = parseFeed(name, data, format, header, delimiter); err != nil {
+			return nil, err
+		}
+		f.declaration = declaration
+		d.feeds[name] = f
+	} else if f.declaration != declaration {
+		return nil, errors.Errorf("feeder '%s' was already declared with different data or params", name)
+	}
+	feeder.feed = f
+
+	return common.Bind(rt, feeder, ctxPtr), nil
+}
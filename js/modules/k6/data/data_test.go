@@ -0,0 +1,317 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const usersCSV = `name,password
+alice,a
+bob,b
+carol,c
+`
+
+// newVU returns a runtime with the module bound, in the init context, and a function to leave it.
+func newVU(t *testing.T, module *Data, id int64) (*goja.Runtime, func()) {
+	return newSegmentVU(t, module, id, nil)
+}
+
+// newSegmentVU is newVU for an instance running the given execution segment.
+func newSegmentVU(t *testing.T, module *Data, id int64, segment *lib.ExecutionSegment) (*goja.Runtime, func()) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctxPtr := new(context.Context)
+	*ctxPtr = common.WithRuntime(context.Background(), rt)
+	rt.Set("data", common.Bind(rt, module, ctxPtr))
+	rt.Set("usersCSV", usersCSV)
+	return rt, func() {
+		*ctxPtr = common.WithState(*ctxPtr, &common.State{Vu: id, Options: lib.Options{ExecutionSegment: segment}})
+	}
+}
+
+func names(t *testing.T, rt *goja.Runtime, feeder string, n int) []string {
+	var result []string
+	for i := 0; i < n; i++ {
+		v, err := common.RunString(rt, feeder+`.next().name`)
+		require.NoError(t, err)
+		result = append(result, v.String())
+	}
+	return result
+}
+
+func TestFeeder(t *testing.T) {
+	t.Run("Sequential", func(t *testing.T) {
+		module := New()
+		rt1, start1 := newVU(t, module, 1)
+		rt2, start2 := newVU(t, module, 2)
+		for _, rt := range []*goja.Runtime{rt1, rt2} {
+			_, err := common.RunString(rt, `let users = new data.Feeder("users", usersCSV);`)
+			require.NoError(t, err)
+		}
+		start1()
+		start2()
+
+		assert.Equal(t, []string{"alice", "bob", "carol", "alice"}, names(t, rt1, "users", 4))
+		assert.Equal(t, []string{"alice", "bob"}, names(t, rt2, "users", 2))
+
+		v, err := common.RunString(rt1, `users.length()`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), v.ToInteger())
+	})
+
+	t.Run("Unique", func(t *testing.T) {
+		module := New()
+		rt1, start1 := newVU(t, module, 1)
+		rt2, start2 := newVU(t, module, 2)
+		for _, rt := range []*goja.Runtime{rt1, rt2} {
+			_, err := common.RunString(rt,
+				`let users = new data.Feeder("users", usersCSV, { mode: "unique", onExhausted: "throw" });`)
+			require.NoError(t, err)
+		}
+		start1()
+		start2()
+
+		assert.Equal(t, []string{"alice", "bob"}, names(t, rt1, "users", 2))
+		assert.Equal(t, []string{"carol"}, names(t, rt2, "users", 1))
+		_, err := common.RunString(rt1, `users.next()`)
+		assert.EqualError(t, err, "GoError: feeder 'users' ran out of records")
+	})
+
+	t.Run("VU", func(t *testing.T) {
+		module := New()
+		var rts []*goja.Runtime
+		for id := int64(1); id <= 4; id++ {
+			rt, start := newVU(t, module, id)
+			_, err := common.RunString(rt,
+				`let users = new data.Feeder("users", usersCSV, { mode: "vu", onExhausted: "null" });`)
+			require.NoError(t, err)
+			start()
+			rts = append(rts, rt)
+		}
+
+		for i, name := range []string{"alice", "bob", "carol"} {
+			assert.Equal(t, []string{name, name}, names(t, rts[i], "users", 2))
+		}
+		v, err := common.RunString(rts[3], `users.next()`)
+		require.NoError(t, err)
+		assert.True(t, goja.IsNull(v))
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		rt, start := newVU(t, New(), 1)
+		_, err := common.RunString(rt, `let users = new data.Feeder("users", usersCSV, { mode: "random" });`)
+		require.NoError(t, err)
+		start()
+		for _, name := range names(t, rt, "users", 10) {
+			assert.Contains(t, []string{"alice", "bob", "carol"}, name)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		rt, start := newVU(t, New(), 1)
+		_, err := common.RunString(rt,
+			`let users = new data.Feeder("users", '[{"name": "alice", "roles": ["admin"]}, {"name": "bob", "roles": []}]');`)
+		require.NoError(t, err)
+		start()
+
+		// Every record is a copy, so modifying it doesn't affect other VUs
+		v, err := common.RunString(rt, `
+		let a = users.next(); a.roles.push("user");
+		users.next(); users.next().roles.length`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), v.ToInteger())
+	})
+
+	t.Run("CSV options", func(t *testing.T) {
+		rt, start := newVU(t, New(), 1)
+		_, err := common.RunString(rt,
+			`let rows = new data.Feeder("rows", "alice;a\nbob;b", { header: false, delimiter: ";" });`)
+		require.NoError(t, err)
+		start()
+
+		v, err := common.RunString(rt, `rows.next().join("|")`)
+		require.NoError(t, err)
+		assert.Equal(t, "alice|a", v.String())
+	})
+
+	t.Run("Segments", func(t *testing.T) {
+		// Every instance has its own module, and hands out its part of the records: the first
+		// one gets alice, the second one bob and carol
+		for mode, expected := range map[string][][]string{
+			"sequential": {{"alice", "alice"}, {"bob", "carol"}},
+			"unique":     {{"alice", "alice"}, {"bob", "carol"}},
+			"vu":         {{"alice", "alice"}, {"bob", "bob"}},
+		} {
+			for i, segment := range []*lib.ExecutionSegment{{Index: 0, Count: 2}, {Index: 1, Count: 2}} {
+				rt, start := newSegmentVU(t, New(), 1, segment)
+				_, err := common.RunString(rt,
+					`let users = new data.Feeder("users", usersCSV, { mode: "`+mode+`" });`)
+				require.NoError(t, err)
+
+				v, err := common.RunString(rt, `users.length()`)
+				require.NoError(t, err)
+				assert.Equal(t, int64(3), v.ToInteger(), "init context")
+
+				start()
+				assert.Equal(t, expected[i], names(t, rt, "users", 2), mode)
+				v, err = common.RunString(rt, `users.length()`)
+				require.NoError(t, err)
+				assert.Equal(t, int64(i+1), v.ToInteger())
+			}
+		}
+	})
+
+	t.Run("Redeclared", func(t *testing.T) {
+		module := New()
+		rt, _ := newVU(t, module, 1)
+		_, err := common.RunString(rt, `new data.Feeder("users", usersCSV, { mode: "unique" })`)
+		require.NoError(t, err)
+
+		rt2, _ := newVU(t, module, 2)
+		_, err = common.RunString(rt2, `new data.Feeder("users", usersCSV, { mode: "unique" })`)
+		assert.NoError(t, err)
+		for _, script := range []string{
+			`new data.Feeder("users", "name,id\nmallory,m\n", { mode: "unique" })`,
+			`new data.Feeder("users", usersCSV)`,
+			`new data.Feeder("users", usersCSV, { mode: "unique", header: false })`,
+		} {
+			_, err := common.RunString(rt2, script)
+			if assert.Error(t, err, script) {
+				assert.Contains(t, err.Error(), "feeder 'users' was already declared with different data or params")
+			}
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		rt, start := newVU(t, New(), 1)
+		for script, msg := range map[string]string{
+			`new data.Feeder("", "")`:                           "feeders need a name",
+			`new data.Feeder("a", "", { mode: "shuffled" })`:    "unknown feeder mode 'shuffled'",
+			`new data.Feeder("a", "", { onExhausted: "stop" })`: "unknown feeder exhaustion policy 'stop'",
+			`new data.Feeder("a", "", { format: "xml" })`:       "unknown feeder format 'xml'",
+			`new data.Feeder("a", "", { delimiter: ";;" })`:     "the feeder delimiter must be a single character",
+			`new data.Feeder("a", "[", { format: "json" })`:     "couldn't parse the data for feeder 'a': unexpected end of JSON input",
+			`let f = new data.Feeder("b", usersCSV); f.next()`:  "feeder records can't be taken in the init context",
+		} {
+			_, err := common.RunString(rt, script)
+			if assert.Error(t, err, script) {
+				assert.Contains(t, err.Error(), msg)
+			}
+		}
+
+		start()
+		_, err := common.RunString(rt, `new data.Feeder("c", usersCSV)`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "feeders must be declared in the init context")
+		}
+	})
+}
+
+func TestUniqueID(t *testing.T) {
+	uuidRE := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	t.Run("Concurrent", func(t *testing.T) {
+		module := New()
+		ids := make(chan string, 8*1000)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					ids <- module.UniqueID(context.Background())
+				}
+			}()
+		}
+		wg.Wait()
+		close(ids)
+
+		seen := make(map[string]bool)
+		for id := range ids {
+			assert.Regexp(t, uuidRE, id)
+			assert.False(t, seen[id], id)
+			seen[id] = true
+		}
+	})
+
+	t.Run("Ordered", func(t *testing.T) {
+		g := newIDGenerator()
+		now := time.Now()
+		var ids []string
+		// Overflow the counter, and go back in time
+		for i := 0; i < 5000; i++ {
+			ids = append(ids, g.next(now, nil))
+		}
+		ids = append(ids, g.next(now.Add(-time.Second), nil), g.next(now.Add(time.Second), nil))
+
+		assert.True(t, sort.StringsAreSorted(ids))
+		for i := 1; i < len(ids); i++ {
+			assert.NotEqual(t, ids[i-1], ids[i])
+		}
+		assert.Equal(t, ids[0][:13], ids[4095][:13], "same millisecond")
+		assert.NotEqual(t, ids[0][:13], ids[4096][:13], "borrowed the next millisecond")
+	})
+
+	t.Run("Nodes", func(t *testing.T) {
+		// IDs from different processes differ in their node ID, even in the same millisecond
+		now := time.Now()
+		assert.NotEqual(t, newIDGenerator().next(now, nil), newIDGenerator().next(now, nil))
+	})
+
+	t.Run("Segments", func(t *testing.T) {
+		// Even generators that picked the same random node ID differ in the segment's index
+		now := time.Now()
+		g1, g2 := newIDGenerator(), newIDGenerator()
+		g2.node = g1.node
+		id1 := g1.next(now, &lib.ExecutionSegment{Index: 0, Count: 2})
+		id2 := g2.next(now, &lib.ExecutionSegment{Index: 1, Count: 2})
+		assert.Regexp(t, uuidRE, id1)
+		assert.Regexp(t, uuidRE, id2)
+		assert.NotEqual(t, id1, id2)
+		assert.Equal(t, "8000", id1[19:23])
+		assert.Equal(t, "8001", id2[19:23])
+
+		rt, start := newSegmentVU(t, New(), 1, &lib.ExecutionSegment{Index: 3, Count: 4})
+		start()
+		v, err := common.RunString(rt, `data.uniqueID()`)
+		require.NoError(t, err)
+		assert.Equal(t, "8003", v.String()[19:23])
+	})
+
+	t.Run("JS", func(t *testing.T) {
+		rt, _ := newVU(t, New(), 1)
+		v, err := common.RunString(rt, `data.uniqueID()`)
+		require.NoError(t, err)
+		assert.Regexp(t, uuidRE, v.String())
+	})
+}
@@ -0,0 +1,150 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package execution
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+)
+
+// ErrNotRunning is returned when the test is controlled from outside of a running test, eg. from
+// the init context or from setup() and teardown().
+var ErrNotRunning = errors.New("the test can only be controlled while it's running")
+
+// Execution is the k6/execution module, which lets a script pause, resume and rescale the test
+// it's part of, the same way the REST API does. There are no scenarios to control separately,
+// so it always controls the whole test.
+type Execution struct {
+	mu     sync.Mutex
+	resume *time.Timer
+}
+
+// New returns a new k6/execution module.
+func New() *Execution {
+	return &Execution{}
+}
+
+func getExecutor(ctx context.Context) (lib.Executor, error) {
+	ex := lib.GetExecutor(ctx)
+	if ex == nil {
+		return nil, ErrNotRunning
+	}
+	return ex, nil
+}
+
+// Pause stops the test from starting new iterations; ones in progress, including the caller's,
+// are allowed to finish. If a duration in seconds is given, the test resumes after it elapses.
+func (e *Execution) Pause(ctx context.Context, duration goja.Value) {
+	ex, err := getExecutor(ctx)
+	if err != nil {
+		common.Throw(common.GetRuntime(ctx), err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.resume != nil {
+		e.resume.Stop()
+		e.resume = nil
+	}
+	ex.SetPaused(true)
+	if duration != nil && !goja.IsUndefined(duration) && !goja.IsNull(duration) && duration.ToFloat() > 0 {
+		e.resume = time.AfterFunc(time.Duration(duration.ToFloat()*float64(time.Second)), func() {
+			ex.SetPaused(false)
+		})
+	}
+}
+
+// Resume resumes a paused test.
+func (e *Execution) Resume(ctx context.Context) {
+	ex, err := getExecutor(ctx)
+	if err != nil {
+		common.Throw(common.GetRuntime(ctx), err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.resume != nil {
+		e.resume.Stop()
+		e.resume = nil
+	}
+	ex.SetPaused(false)
+}
+
+// IsPaused returns whether the test is paused.
+func (e *Execution) IsPaused(ctx context.Context) (bool, error) {
+	ex, err := getExecutor(ctx)
+	if err != nil {
+		return false, err
+	}
+	return ex.IsPaused(), nil
+}
+
+// GetVUs returns the number of currently active VUs.
+func (e *Execution) GetVUs(ctx context.Context) (int64, error) {
+	ex, err := getExecutor(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return ex.GetVUs(), nil
+}
+
+// GetVUsMax returns the number of allocated VUs, which is the most SetVUs() can scale up to.
+func (e *Execution) GetVUsMax(ctx context.Context) (int64, error) {
+	ex, err := getExecutor(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return ex.GetVUsMax(), nil
+}
+
+// Abort stops the test right away, interrupting the calling VU. Other VUs' iterations are
+// cancelled, but teardown() still runs and the end-of-test summary is still printed. k6 exits
+// with the given exit code, or with its default one for aborted tests.
+func (e *Execution) Abort(ctx context.Context, reason string, exitCode goja.Value) {
+	rt := common.GetRuntime(ctx)
+	var code int64
+	if exitCode != nil && !goja.IsUndefined(exitCode) && !goja.IsNull(exitCode) {
+		code = exitCode.ToInteger()
+		if code < 1 || code > 255 {
+			common.Throw(rt, errors.Errorf("exit code %d is out of range", code))
+		}
+	}
+	rt.Interrupt(lib.NewAbortError(reason, int(code)))
+}
+
+// SetVUs scales the test to the given number of active VUs. If the calling VU is one of the ones
+// being stopped, its current iteration is interrupted. Note that stages, if any, will override
+// this at their next step.
+func (e *Execution) SetVUs(ctx context.Context, vus int64) {
+	ex, err := getExecutor(ctx)
+	if err == nil {
+		err = ex.SetVUs(vus)
+	}
+	if err != nil {
+		common.Throw(common.GetRuntime(ctx), err)
+	}
+}
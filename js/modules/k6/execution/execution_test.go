@@ -0,0 +1,129 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package execution
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/core/local"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecution(t *testing.T) {
+	ex := local.New(nil)
+	require.NoError(t, ex.SetVUsMax(5))
+	require.NoError(t, ex.SetVUs(1))
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := common.WithRuntime(context.Background(), rt)
+	rt.Set("execution", common.Bind(rt, New(), &ctx))
+
+	t.Run("not running", func(t *testing.T) {
+		for _, fn := range []string{"pause()", "resume()", "isPaused()", "getVUs()", "setVUs(1)"} {
+			_, err := common.RunString(rt, "execution."+fn)
+			if assert.Error(t, err, fn) {
+				assert.Contains(t, err.Error(), ErrNotRunning.Error(), fn)
+			}
+		}
+	})
+
+	ctx = lib.WithExecutor(ctx, ex)
+
+	t.Run("pause", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		execution.pause();
+		if (!execution.isPaused()) { throw new Error("not paused"); }
+		execution.resume();
+		if (execution.isPaused()) { throw new Error("still paused"); }
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("pause for", func(t *testing.T) {
+		_, err := common.RunString(rt, `execution.pause(0.05)`)
+		require.NoError(t, err)
+		assert.True(t, ex.IsPaused())
+		time.Sleep(200 * time.Millisecond)
+		assert.False(t, ex.IsPaused())
+	})
+
+	t.Run("resume cancels timer", func(t *testing.T) {
+		_, err := common.RunString(rt, `execution.pause(0.05); execution.resume(); execution.pause();`)
+		require.NoError(t, err)
+		time.Sleep(200 * time.Millisecond)
+		assert.True(t, ex.IsPaused())
+		ex.SetPaused(false)
+	})
+
+	t.Run("vus", func(t *testing.T) {
+		v, err := common.RunString(rt, `execution.setVUs(3); [execution.getVUs(), execution.getVUsMax()]`)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{int64(3), int64(5)}, v.Export())
+		assert.Equal(t, int64(3), ex.GetVUs())
+
+		_, err = common.RunString(rt, `execution.setVUs(10)`)
+		assert.Error(t, err)
+	})
+}
+
+func TestAbort(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := common.WithRuntime(context.Background(), rt)
+	rt.Set("execution", common.Bind(rt, New(), &ctx))
+
+	testdata := map[string]struct {
+		script string
+		err    lib.AbortError
+	}{
+		"no reason": {`execution.abort()`, lib.NewAbortError("", 0)},
+		"reason":    {`execution.abort("broken")`, lib.NewAbortError("broken", 0)},
+		"exit code": {`execution.abort("broken", 42)`, lib.NewAbortError("broken", 42)},
+		"uncatchable": {
+			`try { execution.abort("broken", 42); } catch (e) {}; throw new Error("not aborted")`,
+			lib.NewAbortError("broken", 42),
+		},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			_, err := common.RunString(rt, data.script)
+			ierr, ok := err.(*goja.InterruptedError)
+			require.True(t, ok, "%v", err)
+			assert.Equal(t, data.err, ierr.Value())
+		})
+	}
+
+	t.Run("invalid exit code", func(t *testing.T) {
+		for _, code := range []string{"0", "-1", "256"} {
+			_, err := common.RunString(rt, `execution.abort("broken", `+code+`)`)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "out of range")
+			}
+		}
+	})
+}
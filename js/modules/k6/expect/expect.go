@@ -0,0 +1,409 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package expect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/js/modules/k6"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/jsonschema"
+	"github.com/pkg/errors"
+)
+
+// ErrExpectInInitContext is returned when expect() is used in the init context.
+var ErrExpectInInitContext = common.NewInitContextError("Using expect() in the init context is not supported")
+
+// Words that can be chained for readability, eg. expect(x).to.be.above(1); they don't change
+// the assertion.
+var chainWords = []string{
+	"to", "be", "been", "is", "that", "which", "and", "has", "have", "with", "at", "of", "same", "does",
+}
+
+// An AssertionError is thrown by a failed assertion, which is also recorded as a failed check.
+type AssertionError struct {
+	Check    string
+	Expected interface{}
+	Actual   interface{}
+}
+
+func (e AssertionError) Error() string {
+	return fmt.Sprintf("%s, got %s", e.Check, formatValue(e.Actual))
+}
+
+// Expect is the k6/expect module.
+type Expect struct{}
+
+// New returns a new k6/expect module.
+func New() *Expect {
+	return &Expect{}
+}
+
+// Expect starts an assertion about a value. Every assertion is recorded as a check in the
+// current group, named after the message, if any, and what was expected, eg.
+// expect(res.status, "status").to.equal(200) is the check "status to equal 200". A failed
+// assertion throws, which ends the iteration, unless it was made within describe().
+func (*Expect) Expect(ctx context.Context, value goja.Value, message string) (*goja.Object, error) {
+	if common.GetState(ctx) == nil {
+		return nil, ErrExpectInInitContext
+	}
+	if message == "" {
+		message = "value"
+	}
+	a := &assertion{ctx: ctx, rt: common.GetRuntime(ctx), value: value, message: message}
+	return a.object(), nil
+}
+
+// Describe runs a function in a group, like group(), and returns whether all of the assertions
+// in it passed. A failed assertion ends the function, but not the iteration; other errors are
+// thrown as usual.
+func (*Expect) Describe(ctx context.Context, name string, fn goja.Callable) (bool, error) {
+	_, err := (&k6.K6{}).Group(ctx, name, fn)
+	if err == nil {
+		return true, nil
+	}
+	if isAssertionError(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// isAssertionError returns whether a JS exception was thrown by a failed assertion.
+func isAssertionError(err error) bool {
+	ex, ok := err.(*goja.Exception)
+	if !ok {
+		return false
+	}
+	obj, ok := ex.Value().(*goja.Object)
+	if !ok {
+		return false
+	}
+	v := obj.Get("value")
+	if v == nil {
+		return false
+	}
+	_, ok = v.Export().(AssertionError)
+	return ok
+}
+
+// An assertion about a value, possibly negated with "not".
+type assertion struct {
+	ctx     context.Context
+	rt      *goja.Runtime
+	value   goja.Value
+	message string
+	negate  bool
+}
+
+// object returns the JS object of the assertion, with the chain words, "not", the property
+// assertions, eg. expect(x).to.be.ok, and the method assertions, eg. expect(x).to.equal(1).
+func (a *assertion) object() *goja.Object {
+	rt := a.rt
+	obj := rt.NewObject()
+	getter := func(fn func() goja.Value) goja.Value {
+		return rt.ToValue(func(goja.FunctionCall) goja.Value { return fn() })
+	}
+	self := func() goja.Value { return obj }
+
+	for _, word := range chainWords {
+		_ = obj.DefineAccessorProperty(word, getter(self), nil, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	}
+	_ = obj.DefineAccessorProperty("not", getter(func() goja.Value {
+		negated := *a
+		negated.negate = !a.negate
+		return negated.object()
+	}), nil, goja.FLAG_FALSE, goja.FLAG_FALSE)
+
+	properties := map[string]func() (bool, string, interface{}){
+		"ok":        func() (bool, string, interface{}) { return a.value.ToBoolean(), "be ok", nil },
+		"true":      func() (bool, string, interface{}) { return a.value.StrictEquals(rt.ToValue(true)), "be true", true },
+		"false":     func() (bool, string, interface{}) { return a.value.StrictEquals(rt.ToValue(false)), "be false", false },
+		"null":      func() (bool, string, interface{}) { return goja.IsNull(a.value), "be null", nil },
+		"undefined": func() (bool, string, interface{}) { return goja.IsUndefined(a.value), "be undefined", nil },
+		"exist": func() (bool, string, interface{}) {
+			return !goja.IsNull(a.value) && !goja.IsUndefined(a.value), "exist", nil
+		},
+		"empty": func() (bool, string, interface{}) { return a.length() == 0, "be empty", nil },
+	}
+	for name, fn := range properties {
+		fn := fn
+		_ = obj.DefineAccessorProperty(name, getter(func() goja.Value {
+			a.assert(fn())
+			return obj
+		}), nil, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	}
+
+	methods := map[string]func(goja.FunctionCall) (bool, string, interface{}){
+		"equal":       a.equal,
+		"eql":         a.eql,
+		"above":       a.compare("be above", func(v, n float64) bool { return v > n }),
+		"below":       a.compare("be below", func(v, n float64) bool { return v < n }),
+		"least":       a.compare("be at least", func(v, n float64) bool { return v >= n }),
+		"most":        a.compare("be at most", func(v, n float64) bool { return v <= n }),
+		"within":      a.within,
+		"a":           a.typeOf,
+		"an":          a.typeOf,
+		"include":     a.include,
+		"contain":     a.include,
+		"property":    a.property,
+		"lengthOf":    a.lengthOf,
+		"match":       a.match,
+		"oneOf":       a.oneOf,
+		"matchSchema": a.matchSchema,
+	}
+	for name, fn := range methods {
+		fn := fn
+		obj.Set(name, func(call goja.FunctionCall) goja.Value {
+			a.assert(fn(call))
+			return obj
+		})
+	}
+	return obj
+}
+
+// assert records the outcome of the assertion as a check, and throws if it failed.
+func (a *assertion) assert(pass bool, desc string, expected interface{}) {
+	name := a.message + " to " + desc
+	if a.negate {
+		name = a.message + " not to " + desc
+		pass = !pass
+	}
+
+	actual := a.value.Export()
+	failure := lib.CheckFailure{Expected: expected, Actual: actual}
+	if err := k6.RecordCheck(a.ctx, name, pass, failure); err != nil {
+		common.Throw(a.rt, err)
+	}
+	if !pass {
+		common.Throw(a.rt, AssertionError{Check: "expected " + name, Expected: expected, Actual: actual})
+	}
+}
+
+func (a *assertion) equal(call goja.FunctionCall) (bool, string, interface{}) {
+	expected := call.Argument(0)
+	return a.value.StrictEquals(expected), "equal " + formatValue(expected.Export()), expected.Export()
+}
+
+// eql compares values deeply, by their JSON representation.
+func (a *assertion) eql(call goja.FunctionCall) (bool, string, interface{}) {
+	expected := call.Argument(0).Export()
+	want, err1 := json.Marshal(expected)
+	got, err2 := json.Marshal(a.value.Export())
+	pass := err1 == nil && err2 == nil && string(want) == string(got)
+	return pass, "deeply equal " + formatValue(expected), expected
+}
+
+func (a *assertion) compare(desc string, fn func(v, n float64) bool) func(goja.FunctionCall) (bool, string, interface{}) {
+	return func(call goja.FunctionCall) (bool, string, interface{}) {
+		n := call.Argument(0).ToFloat()
+		return a.isNumber() && fn(a.value.ToFloat(), n), desc + " " + formatValue(n), n
+	}
+}
+
+func (a *assertion) within(call goja.FunctionCall) (bool, string, interface{}) {
+	low, high := call.Argument(0).ToFloat(), call.Argument(1).ToFloat()
+	v := a.value.ToFloat()
+	return a.isNumber() && v >= low && v <= high,
+		fmt.Sprintf("be within %s..%s", formatValue(low), formatValue(high)), []float64{low, high}
+}
+
+func (a *assertion) typeOf(call goja.FunctionCall) (bool, string, interface{}) {
+	expected := strings.ToLower(call.Argument(0).String())
+	article := "a "
+	if expected != "" && strings.ContainsAny(expected[:1], "aeiou") {
+		article = "an "
+	}
+	return typeOf(a.value) == expected, "be " + article + expected, expected
+}
+
+// include checks whether a string contains a substring, or an array an element.
+func (a *assertion) include(call goja.FunctionCall) (bool, string, interface{}) {
+	needle := call.Argument(0)
+	desc := "include " + formatValue(needle.Export())
+	switch typeOf(a.value) {
+	case "string":
+		return strings.Contains(a.value.String(), needle.String()), desc, needle.Export()
+	case "array":
+		obj := a.value.ToObject(a.rt)
+		for i := int64(0); i < obj.Get("length").ToInteger(); i++ {
+			if obj.Get(fmt.Sprint(i)).StrictEquals(needle) {
+				return true, desc, needle.Export()
+			}
+		}
+	}
+	return false, desc, needle.Export()
+}
+
+// property checks whether an object has a property, and optionally, its value.
+func (a *assertion) property(call goja.FunctionCall) (bool, string, interface{}) {
+	name := call.Argument(0).String()
+	var prop goja.Value
+	if t := typeOf(a.value); t == "object" || t == "array" || t == "function" {
+		prop = a.value.ToObject(a.rt).Get(name)
+	}
+	if len(call.Arguments) < 2 {
+		return prop != nil, "have property " + formatValue(name), name
+	}
+	expected := call.Argument(1)
+	return prop != nil && prop.StrictEquals(expected),
+		fmt.Sprintf("have property %s of %s", formatValue(name), formatValue(expected.Export())),
+		expected.Export()
+}
+
+func (a *assertion) lengthOf(call goja.FunctionCall) (bool, string, interface{}) {
+	n := call.Argument(0).ToInteger()
+	return a.length() == n, fmt.Sprintf("have a length of %d", n), n
+}
+
+// match checks a string against a RegExp, or a pattern in a string.
+func (a *assertion) match(call goja.FunctionCall) (bool, string, interface{}) {
+	re := call.Argument(0)
+	if obj, ok := re.(*goja.Object); ok {
+		if test, ok := goja.AssertFunction(obj.Get("test")); ok {
+			res, err := test(obj, a.value)
+			if err != nil {
+				common.Throw(a.rt, err)
+			}
+			return typeOf(a.value) == "string" && res.ToBoolean(), "match " + re.String(), re.String()
+		}
+	}
+	pattern, err := regexp.Compile(re.String())
+	if err != nil {
+		common.Throw(a.rt, errors.Wrap(err, "invalid pattern"))
+	}
+	return typeOf(a.value) == "string" && pattern.MatchString(a.value.String()),
+		"match " + formatValue(re.String()), re.String()
+}
+
+func (a *assertion) oneOf(call goja.FunctionCall) (bool, string, interface{}) {
+	list := call.Argument(0)
+	desc := "be one of " + formatValue(list.Export())
+	if typeOf(list) != "array" {
+		return false, desc, list.Export()
+	}
+	obj := list.ToObject(a.rt)
+	for i := int64(0); i < obj.Get("length").ToInteger(); i++ {
+		if obj.Get(fmt.Sprint(i)).StrictEquals(a.value) {
+			return true, desc, list.Export()
+		}
+	}
+	return false, desc, list.Export()
+}
+
+// matchSchema validates the value against a JSON schema, given as an object or a JSON string.
+// A string value, such as a response body, is decoded as JSON first.
+func (a *assertion) matchSchema(call goja.FunctionCall) (bool, string, interface{}) {
+	var schema *jsonschema.Schema
+	var err error
+	if arg := call.Argument(0); typeOf(arg) == "string" {
+		schema, err = jsonschema.Parse([]byte(arg.String()))
+	} else {
+		schema, err = jsonschema.New(arg.Export())
+	}
+	if err != nil {
+		common.Throw(a.rt, err)
+	}
+
+	const desc = "match the schema"
+	var errs []jsonschema.ValidationError
+	if typeOf(a.value) == "string" {
+		if errs, err = schema.ValidateJSON([]byte(a.value.String())); err != nil {
+			return false, desc, "valid JSON"
+		}
+	} else {
+		errs = schema.Validate(a.value.Export())
+	}
+	if len(errs) == 0 {
+		return true, desc, nil
+	}
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return false, desc, msgs
+}
+
+// isNumber returns whether the value is a number.
+func (a *assertion) isNumber() bool {
+	return typeOf(a.value) == "number"
+}
+
+// length returns the length of a string or an array, or the number of keys of an object; -1
+// for anything else.
+func (a *assertion) length() int64 {
+	switch typeOf(a.value) {
+	case "string", "array":
+		return a.value.ToObject(a.rt).Get("length").ToInteger()
+	case "object":
+		return int64(len(a.value.ToObject(a.rt).Keys()))
+	default:
+		return -1
+	}
+}
+
+// typeOf returns the JS type of a value, except that arrays and null have their own.
+func typeOf(v goja.Value) string {
+	switch {
+	case v == nil || goja.IsUndefined(v):
+		return "undefined"
+	case goja.IsNull(v):
+		return "null"
+	}
+	switch v.ExportType().Kind().String() {
+	case "string":
+		return "string"
+	case "bool":
+		return "boolean"
+	case "int", "int64", "float64":
+		return "number"
+	case "slice":
+		return "array"
+	case "func":
+		return "function"
+	default:
+		if _, ok := goja.AssertFunction(v); ok {
+			return "function"
+		}
+		return "object"
+	}
+}
+
+// formatValue formats a value for a check name or an error.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", v)
+	case float64, int64:
+		return fmt.Sprint(v)
+	}
+	if data, err := json.Marshal(v); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
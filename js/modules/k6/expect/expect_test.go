@@ -0,0 +1,188 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package expect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRuntime(t *testing.T) (*goja.Runtime, *lib.Group, chan stats.SampleContainer) {
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	samples := make(chan stats.SampleContainer, 1000)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := common.WithRuntime(context.Background(), rt)
+	ctx = common.WithState(ctx, &common.State{
+		Group:   root,
+		Options: lib.Options{SystemTags: lib.GetTagSet(lib.DefaultSystemTagList...)},
+		Samples: samples,
+	})
+	module := common.Bind(rt, New(), &ctx)
+	rt.Set("expect", module["expect"])
+	rt.Set("describe", module["describe"])
+	return rt, root, samples
+}
+
+func TestExpectInitContext(t *testing.T) {
+	rt := goja.New()
+	ctx := common.WithRuntime(context.Background(), rt)
+	rt.Set("expect", common.Bind(rt, New(), &ctx)["expect"])
+	_, err := common.RunString(rt, `expect(1).to.equal(1)`)
+	assert.Contains(t, err.Error(), ErrExpectInInitContext.Error())
+}
+
+func TestExpectPass(t *testing.T) {
+	assertions := []string{
+		`expect(200, "status").to.equal(200)`,
+		`expect(1).not.to.equal(2)`,
+		`expect({a: [1, {b: "c"}]}).to.eql({a: [1, {b: "c"}]})`,
+		`expect(1).to.be.ok`,
+		`expect(0).not.to.be.ok`,
+		`expect(true).to.be.true`,
+		`expect(false).to.be.false`,
+		`expect(null).to.be.null`,
+		`expect(undefined).to.be.undefined`,
+		`expect("").to.exist`,
+		`expect(null).not.to.exist`,
+		`expect([]).to.be.empty`,
+		`expect({}).to.be.empty`,
+		`expect("a").not.to.be.empty`,
+		`expect(5).to.be.above(4).and.below(6)`,
+		`expect(5).to.be.at.least(5).and.at.most(5)`,
+		`expect(5).to.be.within(1, 10)`,
+		`expect("5").not.to.be.above(4)`,
+		`expect("x").to.be.a("string")`,
+		`expect(1.5).to.be.a("number")`,
+		`expect([]).to.be.an("array")`,
+		`expect({}).to.be.an("object")`,
+		`expect(null).to.be.a("null")`,
+		`expect(function() {}).to.be.a("function")`,
+		`expect("hello world").to.include("world")`,
+		`expect([1, 2, 3]).to.contain(2)`,
+		`expect([1, 2, 3]).not.to.contain("2")`,
+		`expect({id: 1}).to.have.property("id")`,
+		`expect({id: 1}).to.have.property("id", 1)`,
+		`expect({id: 1}).not.to.have.property("name")`,
+		`expect("abc").to.have.lengthOf(3)`,
+		`expect([1, 2]).to.have.lengthOf(2)`,
+		`expect("abc123").to.match(/^[a-z]+\d+$/)`,
+		`expect("abc123").to.match("c1")`,
+		`expect(201).to.be.oneOf([200, 201])`,
+		`expect({id: 1}).to.matchSchema({type: "object", required: ["id"], properties: {id: {type: "integer"}}})`,
+		`expect('{"id": 1}').to.matchSchema('{"properties": {"id": {"type": "integer"}}}')`,
+	}
+	for _, src := range assertions {
+		t.Run(src, func(t *testing.T) {
+			rt, root, _ := newRuntime(t)
+			_, err := common.RunString(rt, src)
+			require.NoError(t, err)
+			for _, check := range root.Checks {
+				assert.Equal(t, int64(1), check.Passes, check.Name)
+				assert.Equal(t, int64(0), check.Fails, check.Name)
+			}
+		})
+	}
+}
+
+func TestExpectFail(t *testing.T) {
+	testdata := map[string]struct {
+		check, err string
+	}{
+		`expect(503, "status").to.equal(200)`: {"status to equal 200", "expected status to equal 200, got 503"},
+		`expect(1).not.to.equal(1)`:           {"value not to equal 1", "expected value not to equal 1, got 1"},
+		`expect({a: 1}).to.eql({a: 2})`:       {`value to deeply equal {"a":2}`, `expected value to deeply equal {"a":2}, got {"a":1}`},
+		`expect(undefined, "token").to.exist`: {"token to exist", "expected token to exist, got null"},
+		`expect(3).to.be.within(4, 5)`:        {"value to be within 4..5", "expected value to be within 4..5, got 3"},
+		`expect(1).to.be.a("string")`:         {"value to be a string", "expected value to be a string, got 1"},
+		`expect("abc").to.include("d")`:       {`value to include "d"`, `expected value to include "d", got "abc"`},
+		`expect({}).to.have.property("id")`:   {`value to have property "id"`, `expected value to have property "id", got {}`},
+		`expect("abc").to.match(/^\d+$/)`:     {`value to match /^\d+$/`, `expected value to match /^\d+$/, got "abc"`},
+		`expect("{").to.matchSchema({})`:      {"value to match the schema", `expected value to match the schema, got "{"`},
+		`expect({id: "1"}).to.matchSchema({properties: {id: {type: "integer"}}})`: {
+			"value to match the schema", `expected value to match the schema, got {"id":"1"}`,
+		},
+	}
+	for src, data := range testdata {
+		t.Run(src, func(t *testing.T) {
+			rt, root, samples := newRuntime(t)
+			_, err := common.RunString(rt, src)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), data.err)
+
+			check, ok := root.Checks[data.check]
+			require.True(t, ok, "no check %q", data.check)
+			assert.Equal(t, int64(0), check.Passes)
+			assert.Equal(t, int64(1), check.Fails)
+			assert.Len(t, check.GetFailureSamples(), 1)
+
+			bufSamples := stats.GetBufferedSamples(samples)
+			require.Len(t, bufSamples, 1)
+			sample := bufSamples[0].(stats.Sample)
+			assert.Equal(t, float64(0), sample.Value)
+			assert.Equal(t, data.check, sample.Tags.CloneTags()["check"])
+		})
+	}
+
+	t.Run("Details", func(t *testing.T) {
+		rt, root, _ := newRuntime(t)
+		_, err := common.RunString(rt, `expect({id: "1"}).to.matchSchema({properties: {id: {type: "integer"}}})`)
+		require.Error(t, err)
+		failures := root.Checks["value to match the schema"].GetFailureSamples()
+		require.Len(t, failures, 1)
+		assert.Equal(t, map[string]interface{}{"id": "1"}, failures[0].Actual)
+		assert.Equal(t, []string{"/id: expected integer, got string"}, failures[0].Expected)
+	})
+}
+
+func TestDescribe(t *testing.T) {
+	rt, root, _ := newRuntime(t)
+
+	v, err := common.RunString(rt, `describe("users", function() {
+		expect(1, "a").to.equal(1);
+		expect(2, "b").to.equal(3);
+		expect(3, "c").to.equal(3);
+	})`)
+	require.NoError(t, err)
+	assert.Equal(t, false, v.Export())
+
+	group := root.Groups["users"]
+	require.NotNil(t, group)
+	assert.Equal(t, int64(1), group.Checks["a to equal 1"].Passes)
+	assert.Equal(t, int64(1), group.Checks["b to equal 3"].Fails)
+	assert.NotContains(t, group.Checks, "c to equal 3")
+
+	v, err = common.RunString(rt, `describe("users", function() { expect(1).to.be.ok; })`)
+	require.NoError(t, err)
+	assert.Equal(t, true, v.Export())
+
+	_, err = common.RunString(rt, `describe("users", function() { throw new Error("oops"); })`)
+	assert.Contains(t, err.Error(), "oops")
+}
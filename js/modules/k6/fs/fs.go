@@ -0,0 +1,350 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package fs
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
+)
+
+// ErrFSInInitContext is returned when files are accessed in the init context, which runs for
+// every VU and before the options are known.
+var ErrFSInInitContext = common.NewInitContextError("Files can't be accessed with k6/fs in the init context")
+
+// FS is the k6/fs module. Scripts can only read and write files in the directory given by the
+// artifactsDir option.
+type FS struct{}
+
+// New returns a new k6/fs module.
+func New() *FS {
+	return &FS{}
+}
+
+// A File is a file opened with open().
+type File struct {
+	name   string
+	f      *os.File
+	reader *bufio.Reader
+
+	// The VU's handle count, which the file is released from when it's closed, if not nil.
+	handles *lib.HandleCounter
+	untrack func()
+
+	mu     sync.Mutex
+	closed chan struct{}
+}
+
+// XOpen opens a file in the artifacts directory: for reading with mode "r", the default, for
+// writing with "w", which truncates it, or for appending with "a". Missing directories are
+// created for writing. Files the script doesn't close are closed when the VU stops, at the end
+// of the test at the latest.
+func (m *FS) XOpen(ctxPtr *context.Context, name, mode string) (interface{}, error) {
+	filename, err := resolve(*ctxPtr, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var flag int
+	switch mode {
+	case "", "r":
+		flag = os.O_RDONLY
+	case "w":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	case "a":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	default:
+		return nil, errors.Errorf("invalid mode '%s', use: 'r', 'w' or 'a'", mode)
+	}
+	if flag != os.O_RDONLY {
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	handles := common.GetState(*ctxPtr).Handles
+	if handles != nil {
+		if err := handles.Acquire(); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(filename, flag, 0644)
+	if err != nil {
+		if handles != nil {
+			handles.Release()
+		}
+		return nil, errors.Errorf("couldn't open '%s': %s", name, causeOf(err))
+	}
+	file := &File{name: name, f: f, handles: handles, closed: make(chan struct{})}
+	if flag == os.O_RDONLY {
+		file.reader = bufio.NewReader(f)
+	}
+	if handles != nil {
+		file.untrack = handles.Track(func() { _ = file.close() })
+	}
+	ctx := *ctxPtr
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = file.close()
+		case <-file.closed:
+		}
+	}()
+	return common.Bind(common.GetRuntime(*ctxPtr), file, ctxPtr), nil
+}
+
+// Read reads up to n bytes, or the rest of the file if n isn't positive, as a string. It
+// returns null at the end of the file.
+func (f *File) Read(n int64) (interface{}, error) {
+	if f.reader == nil {
+		return nil, errors.Errorf("'%s' isn't open for reading", f.name)
+	}
+	var data []byte
+	var err error
+	if n > 0 {
+		data = make([]byte, n)
+		var read int
+		read, err = io.ReadFull(f.reader, data)
+		data = data[:read]
+		if err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+	} else if data, err = ioutil.ReadAll(f.reader); err == nil && len(data) == 0 {
+		err = io.EOF
+	}
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// ReadLine reads the next line, without its line ending. It returns null at the end of the file.
+func (f *File) ReadLine() (interface{}, error) {
+	if f.reader == nil {
+		return nil, errors.Errorf("'%s' isn't open for reading", f.name)
+	}
+	line, err := f.reader.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return nil, nil
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// Write writes a string, or bytes as returned by open(name, "b"), and returns the number of
+// bytes written.
+func (f *File) Write(data goja.Value) (int, error) {
+	if f.reader != nil {
+		return 0, errors.Errorf("'%s' isn't open for writing", f.name)
+	}
+	b, err := toBytes(data)
+	if err != nil {
+		return 0, err
+	}
+	return f.f.Write(b)
+}
+
+// Close closes the file.
+func (f *File) Close(ctx context.Context) {
+	if err := f.close(); err != nil {
+		common.Throw(common.GetRuntime(ctx), err)
+	}
+}
+
+// close closes the file, and releases it from the VU's handle count the first time.
+func (f *File) close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.closed:
+		return f.f.Close()
+	default:
+	}
+	close(f.closed)
+	if f.handles != nil {
+		f.handles.Release()
+		f.untrack()
+	}
+	return f.f.Close()
+}
+
+// ReadFile returns the contents of a file in the artifacts directory, as a string, or as bytes
+// if the mode is "b".
+func (m *FS) ReadFile(ctx context.Context, name, mode string) (goja.Value, error) {
+	filename, err := resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Errorf("couldn't read '%s': %s", name, causeOf(err))
+	}
+	if mode == "b" {
+		return common.GetRuntime(ctx).ToValue(data), nil
+	}
+	return common.GetRuntime(ctx).ToValue(string(data)), nil
+}
+
+// WriteFile writes data to a file in the artifacts directory, replacing it if it exists.
+func (m *FS) WriteFile(ctx context.Context, name string, data goja.Value) {
+	if err := writeFile(ctx, name, data, os.O_TRUNC); err != nil {
+		common.Throw(common.GetRuntime(ctx), err)
+	}
+}
+
+// AppendFile appends data to a file in the artifacts directory, creating it if it doesn't exist.
+func (m *FS) AppendFile(ctx context.Context, name string, data goja.Value) {
+	if err := writeFile(ctx, name, data, os.O_APPEND); err != nil {
+		common.Throw(common.GetRuntime(ctx), err)
+	}
+}
+
+func writeFile(ctx context.Context, name string, data goja.Value, flag int) error {
+	filename, err := resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	b, err := toBytes(data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return errors.Errorf("couldn't write '%s': %s", name, causeOf(err))
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Exists returns whether a file exists in the artifacts directory.
+func (m *FS) Exists(ctx context.Context, name string) (bool, error) {
+	filename, err := resolve(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(filename)
+	return err == nil, nil
+}
+
+// resolve returns the path of a file in the artifacts directory. Paths are always relative to
+// it, and can't lead out of it, including through symlinks.
+func resolve(ctx context.Context, name string) (string, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return "", ErrFSInInitContext
+	}
+	dir := state.Options.ArtifactsDir.String
+	if dir == "" {
+		return "", errors.New("the artifactsDir option must be set to access files with k6/fs")
+	}
+	if name == "" {
+		return "", errors.New("a file name is required")
+	}
+
+	rel := path.Clean("/" + filepath.ToSlash(name))
+	if rel == "/" {
+		return "", errors.Errorf("'%s' isn't a file in the artifacts directory", name)
+	}
+	filename := filepath.Join(dir, filepath.FromSlash(rel))
+
+	// Symlinks inside the directory may still point outside of it.
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return filename, nil
+		}
+		return "", err
+	}
+	for parent := filepath.Dir(filename); ; parent = filepath.Dir(parent) {
+		realParent, err := filepath.EvalSymlinks(parent)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if r, err := filepath.Rel(realDir, realParent); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			return "", errors.Errorf("'%s' isn't a file in the artifacts directory", name)
+		}
+		break
+	}
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		if r, err := filepath.Rel(realDir, target); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			return "", errors.Errorf("'%s' isn't a file in the artifacts directory", name)
+		}
+	}
+	return filename, nil
+}
+
+// toBytes converts data to write, which is a string or bytes, to bytes.
+func toBytes(v goja.Value) ([]byte, error) {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil, errors.New("no data to write")
+	}
+	switch data := v.Export().(type) {
+	case string:
+		return []byte(data), nil
+	case []byte:
+		return data, nil
+	case []interface{}:
+		b := make([]byte, len(data))
+		for i, e := range data {
+			n, ok := e.(int64)
+			if !ok || n < 0 || n > 255 {
+				return nil, errors.Errorf("invalid byte %v at index %d", e, i)
+			}
+			b[i] = byte(n)
+		}
+		return b, nil
+	default:
+		return []byte(v.String()), nil
+	}
+}
+
+// causeOf returns the reason an *os.PathError happened, without the absolute path in it.
+func causeOf(err error) error {
+	if pe, ok := err.(*os.PathError); ok {
+		return pe.Err
+	}
+	return err
+}
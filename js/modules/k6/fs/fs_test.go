@@ -0,0 +1,205 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package fs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func newRuntime(t *testing.T, dir string) (*goja.Runtime, *context.Context) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctxPtr := new(context.Context)
+	*ctxPtr = common.WithRuntime(context.Background(), rt)
+	*ctxPtr = common.WithState(*ctxPtr, &common.State{
+		Options: lib.Options{ArtifactsDir: null.NewString(dir, dir != "")},
+	})
+	rt.Set("fs", common.Bind(rt, New(), ctxPtr))
+	return rt, ctxPtr
+}
+
+func TestFS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k6-fs")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	rt, _ := newRuntime(t, dir)
+
+	t.Run("WriteFile", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		fs.writeFile("certs/cert.pem", "-----BEGIN CERTIFICATE-----\n");
+		fs.appendFile("certs/cert.pem", "-----END CERTIFICATE-----\n");
+		fs.writeFile("bytes.bin", [1, 2, 255]);
+		`)
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, "certs", "cert.pem"))
+		require.NoError(t, err)
+		assert.Equal(t, "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n", string(data))
+		data, err = ioutil.ReadFile(filepath.Join(dir, "bytes.bin"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte{1, 2, 255}, data)
+	})
+
+	t.Run("ReadFile", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		if (!fs.exists("certs/cert.pem")) { throw new Error("cert.pem doesn't exist"); }
+		if (fs.exists("certs/key.pem")) { throw new Error("key.pem exists"); }
+		let pem = fs.readFile("certs/cert.pem");
+		if (pem.indexOf("-----END CERTIFICATE-----") == -1) { throw new Error("unexpected cert.pem: " + pem); }
+		let bytes = fs.readFile("bytes.bin", "b");
+		if (bytes.length != 3 || bytes[2] != 255) { throw new Error("unexpected bytes.bin: " + bytes); }
+		`)
+		assert.NoError(t, err)
+
+		_, err = common.RunString(rt, `fs.readFile("missing.txt")`)
+		assert.Contains(t, err.Error(), "couldn't read 'missing.txt': no such file or directory")
+	})
+
+	t.Run("Open", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let w = new fs.Open("dumps/failures.log", "w");
+		w.write("first\n");
+		w.write("second\r\n");
+		w.close();
+		let a = new fs.Open("dumps/failures.log", "a");
+		a.write("third");
+		a.close();
+
+		let r = new fs.Open("dumps/failures.log");
+		let lines = [];
+		for (let line = r.readLine(); line !== null; line = r.readLine()) { lines.push(line); }
+		r.close();
+		if (lines.join("|") != "first|second|third") { throw new Error("unexpected lines: " + lines); }
+
+		r = new fs.Open("dumps/failures.log", "r");
+		let chunks = [];
+		for (let chunk = r.read(8); chunk !== null; chunk = r.read(8)) { chunks.push(chunk); }
+		r.close();
+		if (chunks.length != 3 || chunks.join("") != "first\nsecond\r\nthird") { throw new Error("unexpected chunks: " + chunks); }
+		`)
+		assert.NoError(t, err)
+
+		_, err = common.RunString(rt, `new fs.Open("dumps/failures.log", "x")`)
+		assert.Contains(t, err.Error(), "invalid mode 'x'")
+		_, err = common.RunString(rt, `new fs.Open("dumps/failures.log").write("a")`)
+		assert.Contains(t, err.Error(), "'dumps/failures.log' isn't open for writing")
+		_, err = common.RunString(rt, `new fs.Open("dumps/other.log", "w").read(1)`)
+		assert.Contains(t, err.Error(), "'dumps/other.log' isn't open for reading")
+	})
+
+	t.Run("Sandbox", func(t *testing.T) {
+		outside, err := ioutil.TempDir("", "k6-fs-outside")
+		require.NoError(t, err)
+		defer func() { _ = os.RemoveAll(outside) }()
+		require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+
+		// Paths that lead out of the directory stay in it.
+		_, err = common.RunString(rt, `fs.writeFile("../../escaped.txt", "x"); fs.writeFile("/abs.txt", "x")`)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "escaped.txt"))
+		assert.FileExists(t, filepath.Join(dir, "abs.txt"))
+
+		_, err = common.RunString(rt, `fs.writeFile("link/escaped.txt", "x")`)
+		assert.Contains(t, err.Error(), "'link/escaped.txt' isn't a file in the artifacts directory")
+		_, err = common.RunString(rt, `fs.writeFile("link/sub/escaped.txt", "x")`)
+		assert.Contains(t, err.Error(), "'link/sub/escaped.txt' isn't a file in the artifacts directory")
+		_, err = common.RunString(rt, `fs.readFile("..")`)
+		assert.Contains(t, err.Error(), "'..' isn't a file in the artifacts directory")
+		_, err = os.Stat(filepath.Join(outside, "escaped.txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestFSUnclosed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k6-fs")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	open := func(t *testing.T) (*goja.Runtime, *lib.HandleCounter, context.CancelFunc) {
+		rt, ctxPtr := newRuntime(t, dir)
+		handles := &lib.HandleCounter{}
+		ctx, cancel := context.WithCancel(*ctxPtr)
+		*ctxPtr = common.WithState(ctx, &common.State{
+			Options: lib.Options{ArtifactsDir: null.StringFrom(dir)},
+			Handles: handles,
+		})
+		_, err := common.RunString(rt, `var f = new fs.Open("unclosed.log", "w");`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), handles.Open())
+		return rt, handles, cancel
+	}
+
+	t.Run("VU stopped", func(t *testing.T) {
+		rt, handles, cancel := open(t)
+		cancel()
+		for deadline := time.Now().Add(5 * time.Second); handles.Open() != 0 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal(t, int64(0), handles.Open())
+		_, err := common.RunString(rt, `f.write("a")`)
+		assert.Error(t, err)
+	})
+
+	t.Run("CloseAll", func(t *testing.T) {
+		rt, handles, cancel := open(t)
+		defer cancel()
+		handles.CloseAll()
+		assert.Equal(t, int64(0), handles.Open())
+		_, err := common.RunString(rt, `f.write("a")`)
+		assert.Error(t, err)
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		rt, handles, cancel := open(t)
+		defer cancel()
+		_, err := common.RunString(rt, `f.close()`)
+		require.NoError(t, err)
+		handles.CloseAll()
+		assert.Equal(t, int64(0), handles.Open())
+	})
+}
+
+func TestFSErrors(t *testing.T) {
+	t.Run("no artifacts dir", func(t *testing.T) {
+		rt, _ := newRuntime(t, "")
+		_, err := common.RunString(rt, `fs.writeFile("a.txt", "a")`)
+		assert.Contains(t, err.Error(), "the artifactsDir option must be set to access files with k6/fs")
+	})
+
+	t.Run("init context", func(t *testing.T) {
+		rt, ctxPtr := newRuntime(t, "/tmp")
+		*ctxPtr = common.WithRuntime(context.Background(), rt)
+		_, err := common.RunString(rt, `fs.writeFile("a.txt", "a")`)
+		assert.Contains(t, err.Error(), ErrFSInInitContext.Error())
+	})
+}
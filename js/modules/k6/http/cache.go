@@ -0,0 +1,69 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/stats"
+)
+
+// setCached sets the status, headers and body of a response to those of a cached one.
+func (res *Response) setCached(cached *netext.CachedResponse, responseType ResponseType, hasher bodyHasher) {
+	res.URL = cached.URL
+	res.Status = cached.Status
+	res.Proto = cached.Proto
+	res.Headers = make(map[string]string, len(cached.Header))
+	for k, vs := range cached.Header {
+		res.Headers[k] = strings.Join(vs, ", ")
+	}
+
+	res.BodySize = int64(len(cached.Body))
+	if hasher != nil {
+		hasher.Update(cached.Body)
+	}
+	switch responseType {
+	case ResponseTypeText:
+		res.Body = string(cached.Body)
+	case ResponseTypeBinary:
+		res.Body = append([]byte{}, cached.Body...)
+	default:
+		res.Body = nil
+	}
+}
+
+// pushSample emits a sample of one of the HTTP cache or retry metrics, with the tags of a request.
+func pushSample(ctx context.Context, state *common.State, metric *stats.Metric, tags map[string]string, value float64) {
+	sampleTags := make(map[string]string, len(tags))
+	for k, v := range tags {
+		sampleTags[k] = v
+	}
+	stats.PushIfNotCancelled(ctx, state.Samples, stats.Sample{
+		Time:   time.Now(),
+		Metric: metric,
+		Tags:   stats.IntoSampleTags(&sampleTags),
+		Value:  value,
+	})
+}
@@ -23,23 +23,23 @@ package http
 import (
 	"context"
 	"net/http"
-	"net/http/cookiejar"
 	neturl "net/url"
 	"strings"
 	"time"
 
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/netext"
 	"github.com/pkg/errors"
 )
 
 type HTTPCookieJar struct {
-	jar *cookiejar.Jar
+	jar *netext.CookieJar
 	ctx *context.Context
 }
 
 func newCookieJar(ctxPtr *context.Context) *HTTPCookieJar {
-	jar, err := cookiejar.New(nil)
+	jar, err := netext.NewCookieJar()
 	if err != nil {
 		common.Throw(common.GetRuntime(*ctxPtr), err)
 	}
@@ -104,3 +104,147 @@ func (j HTTPCookieJar) Set(url, name, value string, opts goja.Value) (bool, erro
 	j.jar.SetCookies(u, []*http.Cookie{&c})
 	return true, nil
 }
+
+// Get returns the cookie with a name that would be sent to a URL, with its attributes, or null.
+func (j HTTPCookieJar) Get(url, name string) (interface{}, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	// The most specific cookie is sent first: the one with the longest path.
+	var found *netext.Cookie
+	for _, c := range j.jar.All() {
+		c := c
+		if c.Name != name || !cookieMatchesURL(c, u) || (c.Secure && u.Scheme != "https") {
+			continue
+		}
+		if found == nil || len(c.Path) > len(found.Path) {
+			found = &c
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	return exportCookie(*found), nil
+}
+
+// Delete removes the cookie with a name that would be sent to a URL.
+func (j HTTPCookieJar) Delete(url, name string) error {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return err
+	}
+	for _, c := range j.jar.All() {
+		if c.Name != name || !cookieMatchesURL(c, u) {
+			continue
+		}
+		hc := &http.Cookie{Name: c.Name, Path: c.Path, MaxAge: -1}
+		if !c.HostOnly {
+			hc.Domain = c.Domain
+		}
+		j.jar.SetCookies(c.URL(), []*http.Cookie{hc})
+	}
+	return nil
+}
+
+// Clear removes all cookies from the jar.
+func (j HTTPCookieJar) Clear() {
+	j.jar.Clear()
+}
+
+// Export returns all cookies in the jar as plain objects, which can be returned from setup() and
+// imported into the jars of the VUs with import().
+func (j HTTPCookieJar) Export() []map[string]interface{} {
+	cookies := j.jar.All()
+	objs := make([]map[string]interface{}, len(cookies))
+	for i, c := range cookies {
+		objs[i] = exportCookie(c)
+	}
+	return objs
+}
+
+// Import adds cookies exported with export() to the jar.
+func (j HTTPCookieJar) Import(cookiesV goja.Value) error {
+	if cookiesV == nil || goja.IsUndefined(cookiesV) || goja.IsNull(cookiesV) {
+		return nil
+	}
+	objs, ok := cookiesV.Export().([]interface{})
+	if !ok {
+		return errors.New("import() requires an array of cookies")
+	}
+
+	cookies := make([]netext.Cookie, 0, len(objs))
+	for i, obj := range objs {
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("cookie %d isn't an object", i)
+		}
+		c := netext.Cookie{Path: "/"}
+		for k, v := range m {
+			switch strings.ToLower(k) {
+			case "name":
+				c.Name, _ = v.(string)
+			case "value":
+				c.Value, _ = v.(string)
+			case "domain":
+				c.Domain, _ = v.(string)
+			case "path":
+				c.Path, _ = v.(string)
+			case "host_only":
+				c.HostOnly, _ = v.(bool)
+			case "secure":
+				c.Secure, _ = v.(bool)
+			case "http_only":
+				c.HTTPOnly, _ = v.(bool)
+			case "expires":
+				expires, _ := v.(string)
+				if expires == "" {
+					continue
+				}
+				t, err := time.Parse(time.RFC1123, expires)
+				if err != nil {
+					return errors.Errorf("unable to parse \"expires\" date string \"%s\" with: %s", expires, err.Error())
+				}
+				c.Expires = t
+			}
+		}
+		if c.Name == "" || c.Domain == "" {
+			return errors.Errorf("cookie %d needs a name and a domain", i)
+		}
+		cookies = append(cookies, c)
+	}
+	j.jar.Add(cookies)
+	return nil
+}
+
+func exportCookie(c netext.Cookie) map[string]interface{} {
+	expires := ""
+	if !c.Expires.IsZero() {
+		expires = c.Expires.UTC().Format(time.RFC1123)
+	}
+	return map[string]interface{}{
+		"name":      c.Name,
+		"value":     c.Value,
+		"domain":    c.Domain,
+		"path":      c.Path,
+		"host_only": c.HostOnly,
+		"expires":   expires,
+		"secure":    c.Secure,
+		"http_only": c.HTTPOnly,
+	}
+}
+
+// cookieMatchesURL returns whether a cookie's domain and path match a URL's.
+func cookieMatchesURL(c netext.Cookie, u *neturl.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	if host != c.Domain && (c.HostOnly || !strings.HasSuffix(host, "."+c.Domain)) {
+		return false
+	}
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+	return p == c.Path || (strings.HasPrefix(p, c.Path) &&
+		(strings.HasSuffix(c.Path, "/") || p[len(c.Path)] == '/'))
+}
@@ -0,0 +1,161 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/extract"
+	"github.com/pkg/errors"
+)
+
+// The extraction helpers below parse the body once per response, and return the first match,
+// or undefined, or with the All variants, all of them.
+
+// JSONPath returns the first value a JSONPath selects in the JSON body.
+func (res *Response) JSONPath(expr string) (goja.Value, error) {
+	values, err := res.jsonPath(expr)
+	if err != nil || len(values) == 0 {
+		return goja.Undefined(), err
+	}
+	return common.GetRuntime(res.ctx).ToValue(values[0]), nil
+}
+
+// JSONPathAll returns all the values a JSONPath selects in the JSON body.
+func (res *Response) JSONPathAll(expr string) ([]interface{}, error) {
+	values, err := res.jsonPath(expr)
+	if values == nil {
+		values = []interface{}{}
+	}
+	return values, err
+}
+
+func (res *Response) jsonPath(expr string) ([]interface{}, error) {
+	if res.extractedJSON == nil {
+		body, err := res.bodyBytes()
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(body, &res.extractedJSON); err != nil {
+			return nil, errors.Wrap(err, "the body isn't valid JSON")
+		}
+	}
+	return extract.JSON(res.extractedJSON, expr)
+}
+
+// XPath returns the text of the first node an XPath selects in the XML body.
+func (res *Response) XPath(expr string) (goja.Value, error) {
+	return res.first(res.xpath(expr))
+}
+
+// XPathAll returns the text of all the nodes an XPath selects in the XML body.
+func (res *Response) XPathAll(expr string) ([]string, error) {
+	return res.all(res.xpath(expr))
+}
+
+func (res *Response) xpath(expr string) ([]string, error) {
+	if res.extractedXML == nil {
+		body, err := res.bodyBytes()
+		if err != nil {
+			return nil, err
+		}
+		if res.extractedXML, err = extract.ParseXML(body); err != nil {
+			return nil, err
+		}
+	}
+	return extract.XML(res.extractedXML, expr)
+}
+
+// Regex returns the first capture group of the first match of a regular expression in the
+// body, or the whole match if it has no groups.
+func (res *Response) Regex(expr string) (goja.Value, error) {
+	return res.first(res.regex(expr))
+}
+
+// RegexAll is like Regex, for all the matches.
+func (res *Response) RegexAll(expr string) ([]string, error) {
+	return res.all(res.regex(expr))
+}
+
+func (res *Response) regex(expr string) ([]string, error) {
+	body, err := res.bodyBytes()
+	if err != nil {
+		return nil, err
+	}
+	return extract.Regex(string(body), expr)
+}
+
+// CSS returns the text of the first element a CSS selector selects in the HTML body, or the
+// value of an attribute of it, if one is given.
+func (res *Response) CSS(selector string, attr ...string) (goja.Value, error) {
+	return res.first(res.css(selector, attr))
+}
+
+// CSSAll is like CSS, for all the selected elements.
+func (res *Response) CSSAll(selector string, attr ...string) ([]string, error) {
+	return res.all(res.css(selector, attr))
+}
+
+func (res *Response) css(selector string, attr []string) ([]string, error) {
+	if res.extractedHTML == nil {
+		body, err := res.bodyBytes()
+		if err != nil {
+			return nil, err
+		}
+		if res.extractedHTML, err = goquery.NewDocumentFromReader(bytes.NewReader(body)); err != nil {
+			return nil, err
+		}
+	}
+	name := ""
+	if len(attr) > 0 {
+		name = attr[0]
+	}
+	return extract.CSS(res.extractedHTML, selector, name)
+}
+
+func (res *Response) bodyBytes() ([]byte, error) {
+	switch b := res.Body.(type) {
+	case []byte:
+		return b, nil
+	case string:
+		return []byte(b), nil
+	default:
+		return nil, errors.New("the response has no body; was it discarded with responseType: \"none\"?")
+	}
+}
+
+func (res *Response) first(values []string, err error) (goja.Value, error) {
+	if err != nil || len(values) == 0 {
+		return goja.Undefined(), err
+	}
+	return common.GetRuntime(res.ctx).ToValue(values[0]), nil
+}
+
+func (res *Response) all(values []string, err error) ([]string, error) {
+	if values == nil {
+		values = []string{}
+	}
+	return values, err
+}
@@ -23,7 +23,6 @@ package http
 import (
 	"context"
 	"net/http"
-	"net/http/cookiejar"
 
 	"fmt"
 	"net/http/httputil"
@@ -115,7 +114,7 @@ func (*HTTP) CookieJar(ctx context.Context) (*HTTPCookieJar, error) {
 	return &HTTPCookieJar{state.CookieJar, &ctx}, nil
 }
 
-func (*HTTP) mergeCookies(req *http.Request, jar *cookiejar.Jar, reqCookies map[string]*HTTPRequestCookie) map[string][]*HTTPRequestCookie {
+func (*HTTP) mergeCookies(req *http.Request, jar *netext.CookieJar, reqCookies map[string]*HTTPRequestCookie) map[string][]*HTTPRequestCookie {
 	allCookies := make(map[string][]*HTTPRequestCookie)
 	for _, c := range jar.Cookies(req.URL) {
 		allCookies[c.Name] = append(allCookies[c.Name], &HTTPRequestCookie{Name: c.Name, Value: c.Value})
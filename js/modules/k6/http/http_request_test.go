@@ -23,22 +23,31 @@ package http
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/js/modules/k6/crypto"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
 	"github.com/loadimpact/k6/lib/testutils"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats"
 	"github.com/oxtoacart/bpool"
 	"github.com/sirupsen/logrus"
@@ -464,7 +473,7 @@ func TestRequestAndBatch(t *testing.T) {
 
 		t.Run("cookies", func(t *testing.T) {
 			t.Run("access", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = common.RunString(rt, sr(`
@@ -476,7 +485,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("vuJar", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = common.RunString(rt, sr(`
@@ -494,7 +503,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("requestScope", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = common.RunString(rt, sr(`
@@ -509,7 +518,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("requestScopeReplace", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = common.RunString(rt, sr(`
@@ -526,7 +535,7 @@ func TestRequestAndBatch(t *testing.T) {
 
 			t.Run("redirect", func(t *testing.T) {
 				t.Run("set cookie before redirect", func(t *testing.T) {
-					cookieJar, err := cookiejar.New(nil)
+					cookieJar, err := netext.NewCookieJar()
 					assert.NoError(t, err)
 					state.CookieJar = cookieJar
 					_, err = common.RunString(rt, sr(`
@@ -552,7 +561,7 @@ func TestRequestAndBatch(t *testing.T) {
 					)
 				})
 				t.Run("set cookie after redirect", func(t *testing.T) {
-					cookieJar, err := cookiejar.New(nil)
+					cookieJar, err := netext.NewCookieJar()
 					assert.NoError(t, err)
 					state.CookieJar = cookieJar
 					_, err = common.RunString(rt, sr(`
@@ -582,7 +591,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("domain", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = common.RunString(rt, sr(`
@@ -606,7 +615,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("path", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = common.RunString(rt, sr(`
@@ -630,7 +639,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("expires", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = common.RunString(rt, sr(`
@@ -651,7 +660,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("secure", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = common.RunString(rt, sr(`
@@ -667,7 +676,7 @@ func TestRequestAndBatch(t *testing.T) {
 			})
 
 			t.Run("localJar", func(t *testing.T) {
-				cookieJar, err := cookiejar.New(nil)
+				cookieJar, err := netext.NewCookieJar()
 				assert.NoError(t, err)
 				state.CookieJar = cookieJar
 				_, err = common.RunString(rt, sr(`
@@ -683,6 +692,51 @@ func TestRequestAndBatch(t *testing.T) {
 				assert.NoError(t, err)
 				assertRequestMetricsEmitted(t, stats.GetBufferedSamples(samples), "GET", sr("HTTPBIN_URL/cookies"), "", 200, "")
 			})
+
+			t.Run("getDelete", func(t *testing.T) {
+				cookieJar, err := netext.NewCookieJar()
+				assert.NoError(t, err)
+				state.CookieJar = cookieJar
+				_, err = common.RunString(rt, sr(`
+				let jar = http.cookieJar();
+				jar.set("HTTPBIN_URL/cookies", "key", "value", { path: "/cookies", http_only: true });
+				let c = jar.get("HTTPBIN_URL/cookies/foo", "key");
+				if (c === null) { throw new Error("missing cookie"); }
+				if (c.value != "value") { throw new Error("wrong cookie value: " + c.value); }
+				if (c.path != "/cookies") { throw new Error("wrong cookie path: " + c.path); }
+				if (!c.http_only) { throw new Error("cookie should be http only"); }
+				if (jar.get("HTTPBIN_URL/other", "key") !== null) { throw new Error("unexpected cookie for another path"); }
+				jar.delete("HTTPBIN_URL/cookies", "key");
+				if (jar.get("HTTPBIN_URL/cookies", "key") !== null) { throw new Error("cookie wasn't deleted"); }
+				let res = http.request("GET", "HTTPBIN_URL/cookies");
+				if (res.json().key != undefined) { throw new Error("deleted cookie was sent"); }
+				jar.set("HTTPBIN_URL/cookies", "key2", "value2");
+				jar.clear();
+				if (Object.keys(jar.cookiesForURL("HTTPBIN_URL/cookies")).length != 0) { throw new Error("jar wasn't cleared"); }
+				`))
+				assert.NoError(t, err)
+				assertRequestMetricsEmitted(t, stats.GetBufferedSamples(samples), "GET", sr("HTTPBIN_URL/cookies"), "", 200, "")
+			})
+
+			t.Run("exportImport", func(t *testing.T) {
+				cookieJar, err := netext.NewCookieJar()
+				assert.NoError(t, err)
+				state.CookieJar = cookieJar
+				_, err = common.RunString(rt, sr(`
+				let jar = new http.CookieJar();
+				jar.set("HTTPBIN_URL/cookies", "key", "value");
+				jar.set("HTTPBIN_URL/cookies", "key2", "value2", { expires: "Sat, 24 Jul 2083 17:01:02 GMT" });
+				let exported = JSON.parse(JSON.stringify(jar.export()));
+				if (exported.length != 2) { throw new Error("wrong number of exported cookies: " + exported.length); }
+				if (exported[1].expires != "Sat, 24 Jul 2083 17:01:02 UTC") { throw new Error("wrong expiry: " + exported[1].expires); }
+				http.cookieJar().import(exported);
+				let res = http.request("GET", "HTTPBIN_URL/cookies");
+				if (res.json().key != "value") { throw new Error("wrong cookie value: " + res.json().key); }
+				if (res.json().key2 != "value2") { throw new Error("wrong cookie value: " + res.json().key2); }
+				`))
+				assert.NoError(t, err)
+				assertRequestMetricsEmitted(t, stats.GetBufferedSamples(samples), "GET", sr("HTTPBIN_URL/cookies"), "", 200, "")
+			})
 		})
 
 		t.Run("auth", func(t *testing.T) {
@@ -1162,6 +1216,7 @@ func TestSystemTags(t *testing.T) {
 		{"iter", httpGet, "0"},
 		{"tls_version", httpsGet, "tls1.2"},
 		{"ocsp_status", httpsGet, "unknown"},
+		{"tls_resumed", httpsGet, "false"},
 		{
 			"error",
 			tb.Replacer.Replace(`http.get("http://127.0.0.1:56789");`),
@@ -1194,6 +1249,162 @@ func TestSystemTags(t *testing.T) {
 	}
 }
 
+func TestURLGrouping(t *testing.T) {
+	t.Parallel()
+	tb, state, samples, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+
+	grouping := &lib.URLGrouping{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"collapse": true,
+		"rules": [{"match": "/anything/orders/", "name": "orders"}],
+		"maxNames": 3
+	}`), grouping))
+	state.Options.URLGrouping = grouping
+
+	_, err := common.RunString(rt, tb.Replacer.Replace(`
+		http.get("HTTPBIN_URL/anything/123?a=1");
+		http.get("HTTPBIN_URL/anything/456?a=2");
+		http.get("HTTPBIN_URL/anything/orders/1");
+		http.get("HTTPBIN_URL/anything/xyz", { tags: { name: "custom" } });
+		http.get(http.url`+"`HTTPBIN_URL/anything/${789}/a`"+`);
+		http.get("HTTPBIN_URL/anything/a");
+		http.get("HTTPBIN_URL/anything/b");
+	`))
+	require.NoError(t, err)
+
+	var names []string
+	for _, sampleC := range stats.GetBufferedSamples(samples) {
+		for _, sample := range sampleC.GetSamples() {
+			if sample.Metric == metrics.HTTPReqs {
+				name, _ := sample.Tags.Get("name")
+				names = append(names, name)
+			}
+		}
+	}
+	assert.Equal(t, []string{
+		tb.Replacer.Replace("HTTPBIN_URL/anything/${}?a=${}"),
+		tb.Replacer.Replace("HTTPBIN_URL/anything/${}?a=${}"),
+		"orders",
+		"custom",
+		tb.Replacer.Replace("HTTPBIN_URL/anything/${}/a"),
+		tb.Replacer.Replace("HTTPBIN_URL/anything/a"),
+		lib.URLGroupingOtherName,
+	}, names)
+}
+
+func TestTraceContext(t *testing.T) {
+	t.Parallel()
+	tb, state, samples, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+
+	state.Options.TraceContext = null.BoolFrom(true)
+	_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let res = http.get("HTTPBIN_URL/headers");
+		let traceparent = res.json().headers["Traceparent"];
+		if (!/^00-[0-9a-f]{32}-[0-9a-f]{16}-01$/.test(traceparent)) {
+			throw new Error("unexpected traceparent: " + traceparent);
+		}
+		res = http.get("HTTPBIN_URL/headers", { headers: { traceparent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01" } });
+		if (res.json().headers["Traceparent"] != "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01") {
+			throw new Error("overwrote traceparent: " + res.json().headers["Traceparent"]);
+		}
+	`))
+	require.NoError(t, err)
+
+	var trails []*netext.Trail
+	for _, sampleC := range stats.GetBufferedSamples(samples) {
+		if trail, ok := sampleC.(*netext.Trail); ok {
+			trails = append(trails, trail)
+		}
+	}
+	require.Len(t, trails, 2)
+	assert.Len(t, trails[0].TraceID, 32)
+	assert.Len(t, trails[0].SpanID, 16)
+	assert.Empty(t, trails[1].TraceID)
+}
+
+func TestFaultInjection(t *testing.T) {
+	t.Parallel()
+	tb, state, samples, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+
+	state.Options.Throw = null.BoolFrom(false)
+	state.Faults = lib.NewFaultInjector()
+	for _, f := range []lib.Fault{
+		{Kind: lib.FaultAbort, Rate: 0.5, Tags: map[string]string{"name": "abort"}},
+		{Kind: lib.FaultError, Rate: 1, Status: 503, Tags: map[string]string{"name": "error"}},
+		{Kind: lib.FaultLatency, Rate: 1, Delay: 200 * time.Millisecond, Tags: map[string]string{"name": "slow"}},
+	} {
+		_, err := state.Faults.Add(f)
+		require.NoError(t, err)
+	}
+
+	_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let statuses = [];
+		for (let i = 0; i < 4; i++) {
+			statuses.push(http.get("HTTPBIN_URL/get", { tags: { name: "abort" } }).status);
+		}
+		if (statuses.join(",") !== "200,0,200,0") {
+			throw new Error("unexpected statuses: " + statuses);
+		}
+		let res = http.get("HTTPBIN_URL/get", { tags: { name: "error" } });
+		if (res.status !== 503) {
+			throw new Error("unexpected status: " + res.status);
+		}
+		res = http.get("HTTPBIN_URL/get", { tags: { name: "slow" } });
+		if (res.status !== 200 || res.timings.waiting < 200) {
+			throw new Error("unexpected response: " + res.status + ", waited " + res.timings.waiting);
+		}
+	`))
+	require.NoError(t, err)
+
+	faults := map[string]int{}
+	for _, sampleC := range stats.GetBufferedSamples(samples) {
+		if trail, ok := sampleC.(*netext.Trail); ok {
+			if fault, ok := trail.Tags.Get("fault"); ok {
+				faults[fault]++
+			}
+		}
+	}
+	assert.Equal(t, map[string]int{"abort": 2, "error": 1, "latency": 1}, faults)
+}
+
+func TestRequestMetadata(t *testing.T) {
+	t.Parallel()
+	tb, _, samples, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+
+	_, err := common.RunString(rt, tb.Replacer.Replace(`
+		http.get("HTTPBIN_URL/get?row=5", { metadata: { testCase: "TC-12", row: 5 }, tags: { name: "rows" } });
+		http.get("HTTPBIN_URL/get", { metadata: null });
+	`))
+	require.NoError(t, err)
+
+	var trails []*netext.Trail
+	for _, sampleC := range stats.GetBufferedSamples(samples) {
+		if trail, ok := sampleC.(*netext.Trail); ok {
+			trails = append(trails, trail)
+		}
+	}
+	require.Len(t, trails, 2)
+
+	metadata := map[string]string{"testCase": "TC-12", "row": "5"}
+	assert.Equal(t, metadata, trails[0].Metadata)
+	for _, sample := range trails[0].GetSamples() {
+		assert.Equal(t, metadata, sample.Metadata, sample.Metric.Name)
+	}
+	_, ok := trails[0].Tags.Get("testCase")
+	assert.False(t, ok, "metadata isn't a tag")
+	name, _ := trails[0].Tags.Get("name")
+	assert.Equal(t, "rows", name)
+
+	assert.Nil(t, trails[1].Metadata)
+	for _, sample := range trails[1].GetSamples() {
+		assert.Nil(t, sample.Metadata)
+	}
+}
+
 func TestResponseTypes(t *testing.T) {
 	t.Parallel()
 	tb, state, _, rt, _ := newRuntime(t)
@@ -1305,3 +1516,423 @@ func TestResponseTypes(t *testing.T) {
 	`))
 	assert.NoError(t, err)
 }
+
+func TestResponseBodySinks(t *testing.T) {
+	t.Parallel()
+	tb, _, _, rt, ctx := newRuntime(t)
+	defer tb.Cleanup()
+	rt.Set("crypto", common.Bind(rt, crypto.New(), ctx))
+
+	dir, err := ioutil.TempDir("", "k6-response-")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "bytes")
+
+	body := make([]byte, 100)
+	for i := range body {
+		body[i] = byte(i * 7)
+	}
+	sum := sha256.Sum256(body)
+	tb.Mux.HandleFunc("/sink-bytes", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write(body)
+		assert.NoError(t, err)
+	}))
+	replace := func(s string) string {
+		return strings.NewReplacer(
+			"EXP_SUM", hex.EncodeToString(sum[:]),
+			"FILE_PATH", strconv.Quote(path),
+		).Replace(tb.Replacer.Replace(s))
+	}
+
+	t.Run("discardResponseBody", func(t *testing.T) {
+		_, err := common.RunString(rt, replace(`
+		let hasher = crypto.createHash("sha256");
+		let res = http.get("HTTPBIN_URL/sink-bytes", { discardResponseBody: true, hash: hasher });
+		if (res.body !== null) { throw new Error("unexpected body: " + res.body); }
+		if (res.body_size !== 100) { throw new Error("unexpected body size: " + res.body_size); }
+		if (hasher.digest("hex") !== "EXP_SUM") { throw new Error("unexpected checksum"); }
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("responseToFile", func(t *testing.T) {
+		_, err := common.RunString(rt, replace(`
+		let hasher = crypto.createHash("sha256");
+		let res = http.get("HTTPBIN_URL/sink-bytes", { responseToFile: FILE_PATH, hash: hasher });
+		if (res.body !== null) { throw new Error("unexpected body: " + res.body); }
+		if (res.body_size !== 100) { throw new Error("unexpected body size: " + res.body_size); }
+		if (hasher.digest("hex") !== "EXP_SUM") { throw new Error("unexpected checksum"); }
+		`))
+		assert.NoError(t, err)
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, body, data)
+	})
+
+	t.Run("buffered", func(t *testing.T) {
+		_, err := common.RunString(rt, replace(`
+		let hasher = crypto.createHash("sha256");
+		let res = http.get("HTTPBIN_URL/sink-bytes", { responseType: "binary", hash: hasher });
+		if (res.body.length !== 100) { throw new Error("unexpected body length: " + res.body.length); }
+		if (hasher.digest("hex") !== "EXP_SUM") { throw new Error("unexpected checksum"); }
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid hash", func(t *testing.T) {
+		_, err := common.RunString(rt, replace(`
+		http.get("HTTPBIN_URL/sink-bytes", { hash: "sha256" });
+		`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "hash must be created with crypto.createHash()")
+	})
+}
+
+func TestResponseChunks(t *testing.T) {
+	t.Parallel()
+	tb, state, samples, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	state.Options.Throw = null.BoolFrom(true)
+
+	tb.Mux.HandleFunc("/stream", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		require.True(t, ok)
+		for i := 0; i < 3; i++ {
+			_, err := fmt.Fprintf(w, "{\"n\":%d}\n", i)
+			assert.NoError(t, err)
+			flusher.Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+
+	t.Run("text", func(t *testing.T) {
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let chunks = [];
+		let res = http.get("HTTPBIN_URL/stream", { onChunk: function(chunk) { chunks.push(chunk); } });
+		if (res.body !== null) { throw new Error("unexpected body: " + res.body); }
+		if (chunks.length !== 3) { throw new Error("unexpected chunks: " + JSON.stringify(chunks)); }
+		if (JSON.parse(chunks[2]).n !== 2) { throw new Error("unexpected last chunk: " + chunks[2]); }
+		`))
+		assert.NoError(t, err)
+
+		var chunks, intervals int
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				switch sample.Metric {
+				case metrics.HTTPReqChunks:
+					chunks++
+				case metrics.HTTPReqChunkInterval:
+					intervals++
+					if chunks > 1 {
+						assert.True(t, sample.Value >= 40, "interval too short: %f", sample.Value)
+					}
+				}
+			}
+		}
+		assert.Equal(t, 3, chunks)
+		assert.Equal(t, 3, intervals)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let length = 0;
+		http.get("HTTPBIN_URL/stream", {
+			responseType: "binary",
+			onChunk: function(chunk) { length += chunk.length; },
+		});
+		if (length !== 24) { throw new Error("unexpected length: " + length); }
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let count = 0;
+		http.get("HTTPBIN_URL/stream", { onChunk: function(chunk) { count++; return false; } });
+		if (count !== 1) { throw new Error("unexpected count: " + count); }
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("throw", func(t *testing.T) {
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		http.get("HTTPBIN_URL/stream", { onChunk: function(chunk) { throw new Error("oops"); } });
+		`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "oops")
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		http.batch([["GET", "HTTPBIN_URL/stream", null, { onChunk: function(chunk) {} }]]);
+		`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't use onChunk")
+	})
+}
+
+func TestResponseCache(t *testing.T) {
+	t.Parallel()
+	tb, state, samples, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	state.HTTPCache = netext.NewHTTPCache()
+
+	var requests, fullResponses int
+	tb.Mux.HandleFunc("/cached/fresh", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("fresh"))
+	})
+	tb.Mux.HandleFunc("/cached/etag", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullResponses++
+		_, _ = w.Write([]byte("etag"))
+	})
+
+	countSamples := func() (hits, misses, revalidations int) {
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				switch {
+				case sample.Metric == metrics.HTTPCacheHits && sample.Value == 1:
+					hits++
+				case sample.Metric == metrics.HTTPCacheHits:
+					misses++
+				case sample.Metric == metrics.HTTPCacheRevalidations:
+					revalidations++
+				}
+			}
+		}
+		return hits, misses, revalidations
+	}
+
+	t.Run("fresh", func(t *testing.T) {
+		requests = 0
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		for (let i = 0; i < 3; i++) {
+			let res = http.get("HTTPBIN_URL/cached/fresh");
+			if (res.status !== 200 || res.body !== "fresh") { throw new Error("wrong response: " + res.status + " " + res.body); }
+		}
+		let res = http.get("HTTPBIN_URL/cached/fresh", { headers: { "Cache-Control": "no-cache" } });
+		if (res.body !== "fresh") { throw new Error("wrong body: " + res.body); }
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, requests)
+
+		hits, misses, revalidations := countSamples()
+		assert.Equal(t, 2, hits)
+		assert.Equal(t, 2, misses)
+		assert.Equal(t, 0, revalidations)
+	})
+
+	t.Run("revalidate", func(t *testing.T) {
+		requests, fullResponses = 0, 0
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		for (let i = 0; i < 3; i++) {
+			let res = http.get("HTTPBIN_URL/cached/etag");
+			if (res.status !== 200 || res.body !== "etag") { throw new Error("wrong response: " + res.status + " " + res.body); }
+		}
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, requests)
+		assert.Equal(t, 1, fullResponses)
+
+		hits, misses, revalidations := countSamples()
+		assert.Equal(t, 0, hits)
+		assert.Equal(t, 3, misses)
+		assert.Equal(t, 2, revalidations)
+	})
+
+	t.Run("uncacheable", func(t *testing.T) {
+		requests = 0
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		http.post("HTTPBIN_URL/cached/fresh");
+		http.get("HTTPBIN_URL/cached/fresh", { headers: { "Cache-Control": "no-store" } });
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, requests)
+
+		hits, misses, _ := countSamples()
+		assert.Equal(t, 0, hits+misses)
+	})
+}
+
+func TestRequestRetry(t *testing.T) {
+	t.Parallel()
+	tb, state, samples, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	state.HTTPRetryBudget = &lib.HTTPRetryBudget{}
+	state.Options.HTTPRetry = &lib.HTTPRetry{HTTPRetryFields: lib.HTTPRetryFields{
+		MaxAttempts: null.IntFrom(3),
+		Backoff:     types.NullDurationFrom(time.Millisecond),
+	}}
+
+	var attempts int64
+	var bodies []string
+	tb.Mux.HandleFunc("/retry/flaky", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if atomic.AddInt64(&attempts, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	reset := func() {
+		atomic.StoreInt64(&attempts, 0)
+		bodies = nil
+	}
+
+	countRetries := func() (retries, exhausted int) {
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				switch sample.Metric {
+				case metrics.HTTPReqRetries:
+					retries++
+				case metrics.HTTPReqRetryBudgetExhausted:
+					exhausted++
+				}
+			}
+		}
+		return retries, exhausted
+	}
+
+	t.Run("global", func(t *testing.T) {
+		reset()
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let res = http.get("HTTPBIN_URL/retry/flaky");
+		if (res.status !== 200 || res.body !== "ok") { throw new Error("wrong response: " + res.status); }
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), atomic.LoadInt64(&attempts))
+		retries, exhausted := countRetries()
+		assert.Equal(t, 2, retries)
+		assert.Equal(t, 0, exhausted)
+	})
+
+	t.Run("override", func(t *testing.T) {
+		reset()
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let res = http.get("HTTPBIN_URL/retry/flaky", { retry: { maxAttempts: 2 } });
+		if (res.status !== 503) { throw new Error("wrong status: " + res.status); }
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), atomic.LoadInt64(&attempts))
+
+		reset()
+		_, err = common.RunString(rt, tb.Replacer.Replace(`
+		let res = http.get("HTTPBIN_URL/retry/flaky", { retry: false });
+		if (res.status !== 503) { throw new Error("wrong status: " + res.status); }
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), atomic.LoadInt64(&attempts))
+
+		_, err = common.RunString(rt, tb.Replacer.Replace(`
+		http.get("HTTPBIN_URL/retry/flaky", { retry: { statuses: ["600"] } });
+		`))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "invalid HTTP retry status '600'")
+		}
+	})
+
+	t.Run("idempotency", func(t *testing.T) {
+		reset()
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let res = http.post("HTTPBIN_URL/retry/flaky", "data");
+		if (res.status !== 503) { throw new Error("wrong status: " + res.status); }
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), atomic.LoadInt64(&attempts))
+
+		reset()
+		_, err = common.RunString(rt, tb.Replacer.Replace(`
+		let res = http.post("HTTPBIN_URL/retry/flaky", "data", { headers: { "Idempotency-Key": "abc" } });
+		if (res.status !== 200) { throw new Error("wrong status: " + res.status); }
+		if (res.request.body !== "data") { throw new Error("wrong request body: " + res.request.body); }
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"data", "data", "data"}, bodies)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := ln.Addr().String()
+		require.NoError(t, ln.Close())
+		countRetries()
+
+		_, err = common.RunString(rt, fmt.Sprintf(`
+		let res = http.get("http://%s/", { throw: false, retry: { maxAttempts: 2 } });
+		if (!res.error) { throw new Error("expected an error"); }
+		`, addr))
+		assert.NoError(t, err)
+		retries, _ := countRetries()
+		assert.Equal(t, 1, retries)
+
+		_, err = common.RunString(rt, fmt.Sprintf(`
+		http.get("http://%s/", { throw: false, retry: { maxAttempts: 2, errors: ["dns"] } });
+		`, addr))
+		assert.NoError(t, err)
+		retries, _ = countRetries()
+		assert.Equal(t, 0, retries)
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		reset()
+		countRetries()
+		state.HTTPRetryBudget = &lib.HTTPRetryBudget{}
+		for i := 0; i < lib.MinHTTPRetryBudget; i++ {
+			require.True(t, state.HTTPRetryBudget.Allow(0.1))
+		}
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let res = http.get("HTTPBIN_URL/retry/flaky", { retry: { budget: 0.1 } });
+		if (res.status !== 503) { throw new Error("wrong status: " + res.status); }
+		`))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), atomic.LoadInt64(&attempts))
+		retries, exhausted := countRetries()
+		assert.Equal(t, 0, retries)
+		assert.Equal(t, 1, exhausted)
+	})
+}
+
+func TestRequestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+	tb, state, _, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	state.Options.CircuitBreaker = &lib.CircuitBreaker{}
+	require.NoError(t, state.Options.CircuitBreaker.UnmarshalText([]byte("errorRate=0.5,minRequests=2,cooldown=1h")))
+
+	var requests int64
+	tb.Mux.HandleFunc("/circuit/broken", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	_, err := common.RunString(rt, tb.Replacer.Replace(`
+	for (let i = 0; i < 2; i++) {
+		let res = http.get("HTTPBIN_URL/circuit/broken", { tags: { name: "broken" } });
+		if (res.status !== 500) { throw new Error("wrong status: " + res.status); }
+	}
+	let res = http.get("HTTPBIN_URL/status/200", { tags: { name: "other" } });
+	if (res.status !== 200) { throw new Error("wrong status: " + res.status); }
+	`))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&requests))
+
+	for _, throw := range []bool{true, false} {
+		_, err = common.RunString(rt, tb.Replacer.Replace(fmt.Sprintf(`
+		http.get("HTTPBIN_URL/circuit/broken", { tags: { name: "broken" }, throw: %t });
+		`, throw)))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "the circuit breaker of 'broken' is open")
+		}
+	}
+	assert.Equal(t, int64(2), atomic.LoadInt64(&requests))
+}
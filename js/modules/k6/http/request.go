@@ -25,15 +25,16 @@ import (
 	"compress/gzip"
 	"compress/zlib"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"net/textproto"
 	"net/url"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
@@ -44,6 +45,8 @@ import (
 	digest "github.com/Soontao/goHttpDigestClient"
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/lib/netext"
 	"github.com/loadimpact/k6/stats"
 	log "github.com/sirupsen/logrus"
@@ -163,10 +166,29 @@ type parsedHTTPRequest struct {
 	throw         bool
 	responseType  ResponseType
 	redirects     null.Int
-	activeJar     *cookiejar.Jar
+	activeJar     *netext.CookieJar
 	cookies       map[string]*HTTPRequestCookie
 	mergedCookies map[string][]*HTTPRequestCookie
 	tags          map[string]string
+	metadata      map[string]string
+	onChunk       func(data []byte) (bool, error)
+	responseFile  string
+	hasher        bodyHasher
+	cache         *netext.HTTPCache
+	retry         *lib.HTTPRetry
+}
+
+// bodyHasher is implemented by the hashers of the k6/crypto streaming API,
+// which can be passed in the hash param to checksum response bodies.
+type bodyHasher interface {
+	Update(input []byte)
+}
+
+type hasherWriter struct{ bodyHasher }
+
+func (w hasherWriter) Write(p []byte) (int, error) {
+	w.Update(p)
+	return len(p), nil
 }
 
 func (h *HTTP) parseRequest(ctx context.Context, method string, reqURL URL, body interface{}, params goja.Value) (*parsedHTTPRequest, error) {
@@ -289,6 +311,8 @@ func (h *HTTP) parseRequest(ctx context.Context, method string, reqURL URL, body
 		result.req.ContentLength = int64(result.body.Len())
 	}
 
+	retry := state.Options.HTTPRetry
+
 	if userAgent := state.Options.UserAgent; userAgent.String != "" {
 		result.req.Header.Set("User-Agent", userAgent.String)
 	}
@@ -373,6 +397,19 @@ func (h *HTTP) parseRequest(ctx context.Context, method string, reqURL URL, body
 				for _, key := range tagObj.Keys() {
 					result.tags[key] = tagObj.Get(key).String()
 				}
+			case "metadata":
+				metadataV := params.Get(k)
+				if goja.IsUndefined(metadataV) || goja.IsNull(metadataV) {
+					continue
+				}
+				metadataObj := metadataV.ToObject(rt)
+				if metadataObj == nil {
+					continue
+				}
+				result.metadata = make(map[string]string, len(metadataObj.Keys()))
+				for _, key := range metadataObj.Keys() {
+					result.metadata[key] = metadataObj.Get(key).String()
+				}
 			case "auth":
 				result.auth = params.Get(k).String()
 			case "timeout":
@@ -385,6 +422,65 @@ func (h *HTTP) parseRequest(ctx context.Context, method string, reqURL URL, body
 					return nil, err
 				}
 				result.responseType = responseType
+			case "discardResponseBody":
+				if params.Get(k).ToBoolean() {
+					result.responseType = ResponseTypeNone
+				}
+			case "responseToFile":
+				result.responseFile = params.Get(k).String()
+			case "retry":
+				retryV := params.Get(k)
+				if goja.IsUndefined(retryV) || goja.IsNull(retryV) {
+					continue
+				}
+				if enabled, ok := retryV.Export().(bool); ok {
+					if !enabled {
+						retry = &lib.HTTPRetry{}
+					}
+					continue
+				}
+				data, err := json.Marshal(retryV.Export())
+				if err != nil {
+					return nil, err
+				}
+				var override lib.HTTPRetry
+				if err := json.Unmarshal(data, &override); err != nil {
+					return nil, fmt.Errorf("invalid retry param: %s", err)
+				}
+				if retry == nil {
+					retry = &lib.HTTPRetry{}
+				}
+				merged := retry.Apply(override)
+				retry = &merged
+			case "hash":
+				hasher, ok := params.Get(k).Export().(bodyHasher)
+				if !ok {
+					return nil, fmt.Errorf("hash must be created with crypto.createHash() or crypto.createHMAC()")
+				}
+				result.hasher = hasher
+			case "onChunk":
+				onChunkV := params.Get(k)
+				if goja.IsUndefined(onChunkV) || goja.IsNull(onChunkV) {
+					continue
+				}
+				fn, ok := goja.AssertFunction(onChunkV)
+				if !ok {
+					return nil, fmt.Errorf("onChunk must be a function")
+				}
+				result.onChunk = func(data []byte) (bool, error) {
+					var chunk goja.Value
+					if result.responseType == ResponseTypeBinary {
+						chunk = rt.ToValue(append([]byte(nil), data...))
+					} else {
+						chunk = rt.ToValue(string(data))
+					}
+					ret, err := fn(goja.Undefined(), chunk)
+					if err != nil {
+						return false, err
+					}
+					// Only an explicit false stops the reading of the body
+					return ret.Export() != false, nil
+				}
 			}
 		}
 	}
@@ -394,12 +490,40 @@ func (h *HTTP) parseRequest(ctx context.Context, method string, reqURL URL, body
 		h.setRequestCookies(result.req, result.mergedCookies)
 	}
 
+	// Retrying a request would deliver the chunks of the failed responses as well.
+	if retry != nil && retry.GetMaxAttempts() > 1 && result.onChunk == nil &&
+		retry.RetriesMethod(result.req.Method, result.req.Header) {
+		result.retry = retry
+	}
+
+	// Streamed bodies and the extra round trips of digest and NTLM auth aren't cached.
+	if state.HTTPCache != nil && netext.Cacheable(result.req) && result.onChunk == nil &&
+		result.responseFile == "" && result.auth != "digest" && result.auth != "ntlm" {
+		result.cache = state.HTTPCache
+	}
+
 	return result, nil
 }
 
-// request() shouldn't mess with the goja runtime or other thread-unsafe
-// things because it's called concurrently by Batch()
-func (h *HTTP) request(ctx context.Context, preq *parsedHTTPRequest) (*Response, error) {
+// requestName returns the name of a request: its name tag, or else its URL, grouped if the
+// urlGrouping option is set.
+func requestName(state *common.State, preq *parsedHTTPRequest) string {
+	if name, ok := preq.tags["name"]; ok {
+		return name
+	}
+	if name, ok := state.Options.RunTags.Get("name"); ok {
+		return name
+	}
+	name := preq.url.Name
+	// URLs built with http.url`...` are already named after their template
+	if g := state.Options.URLGrouping; g != nil && name == preq.url.URLString {
+		name = g.Name(name)
+	}
+	return name
+}
+
+// requestOnce() makes a single attempt at a request; see request().
+func (h *HTTP) requestOnce(ctx context.Context, preq *parsedHTTPRequest) (*Response, error) {
 	state := common.GetState(ctx)
 
 	respReq := &Request{
@@ -426,7 +550,7 @@ func (h *HTTP) request(ctx context.Context, preq *parsedHTTPRequest) (*Response,
 
 	// Only set the name system tag if the user didn't explicitly set it beforehand
 	if _, ok := tags["name"]; !ok && state.Options.SystemTags["name"] {
-		tags["name"] = preq.url.Name
+		tags["name"] = requestName(state, preq)
 	}
 	if state.Options.SystemTags["group"] {
 		tags["group"] = state.Group.Path
@@ -438,6 +562,25 @@ func (h *HTTP) request(ctx context.Context, preq *parsedHTTPRequest) (*Response,
 		tags["iter"] = strconv.FormatInt(state.Iteration, 10)
 	}
 
+	// A fresh cached response is used without making a request at all, like a browser would. A
+	// stale one is revalidated, if it can be.
+	var cached *netext.CachedResponse
+	revalidating := false
+	if preq.cache != nil {
+		// A response whose body was discarded can only be used if this one's would be, too.
+		if cached = preq.cache.Lookup(preq.req); cached != nil && cached.Body == nil && preq.responseType != ResponseTypeNone {
+			cached = nil
+		}
+		if cached != nil && cached.Fresh(preq.req) {
+			pushSample(ctx, state, metrics.HTTPCacheHits, tags, 1)
+			resp := &Response{ctx: ctx, Request: *respReq, tags: tags, Cookies: map[string][]*HTTPCookie{}}
+			resp.setCached(cached, preq.responseType, preq.hasher)
+			return resp, nil
+		}
+		pushSample(ctx, state, metrics.HTTPCacheHits, tags, 0)
+		revalidating = cached != nil && cached.AddConditions(preq.req)
+	}
+
 	// Check rate limit *after* we've prepared a request; no need to wait with that part.
 	if rpsLimit := state.RPSLimit; rpsLimit != nil {
 		if err := rpsLimit.Wait(ctx); err != nil {
@@ -446,6 +589,8 @@ func (h *HTTP) request(ctx context.Context, preq *parsedHTTPRequest) (*Response,
 	}
 
 	tracerTransport := netext.NewTransport(state.Transport, state.Samples, &state.Options, tags)
+	tracerTransport.SetFaultInjector(state.Faults)
+	tracerTransport.SetMetadata(preq.metadata)
 	var transport http.RoundTripper = tracerTransport
 	if preq.auth == "ntlm" {
 		transport = ntlmssp.Negotiator{
@@ -453,7 +598,7 @@ func (h *HTTP) request(ctx context.Context, preq *parsedHTTPRequest) (*Response,
 		}
 	}
 
-	resp := &Response{ctx: ctx, URL: preq.url.URLString, Request: *respReq}
+	resp := &Response{ctx: ctx, URL: preq.url.URLString, Request: *respReq, tags: tags}
 	client := http.Client{
 		Transport: transport,
 		Timeout:   preq.timeout,
@@ -537,22 +682,60 @@ func (h *HTTP) request(ctx context.Context, preq *parsedHTTPRequest) (*Response,
 			res.Body, resErr = gzip.NewReader(res.Body)
 		}
 	}
+	// The body to store in the cache; nil if it was discarded.
+	var cacheBody []byte
 	if resErr == nil && res != nil {
-		if preq.responseType == ResponseTypeNone {
-			_, err := io.Copy(ioutil.Discard, res.Body)
-			if err != nil && err != io.EOF {
+		if preq.onChunk != nil {
+			callbackErr, err := readChunks(ctx, state, res.Body, tracerTransport.GetTrail(), preq.onChunk)
+			if callbackErr != nil {
+				_ = res.Body.Close()
+				return nil, callbackErr
+			}
+			if err != nil {
 				resErr = err
 			}
 			resp.Body = nil
+		} else if preq.responseType == ResponseTypeNone || preq.responseFile != "" {
+			// The body is only read for the byte count and the checksum, or
+			// it's written to a file, so there's no need to buffer it
+			var dst io.Writer = ioutil.Discard
+			if preq.responseFile != "" {
+				f, err := os.Create(preq.responseFile)
+				if err != nil {
+					resErr = err
+				} else {
+					defer func() { _ = f.Close() }()
+					dst = f
+				}
+			}
+			if resErr == nil {
+				if preq.hasher != nil {
+					dst = io.MultiWriter(dst, hasherWriter{preq.hasher})
+				}
+				n, err := io.Copy(dst, res.Body)
+				if err != nil && err != io.EOF {
+					resErr = err
+				}
+				resp.BodySize = n
+			}
+			resp.Body = nil
 		} else {
 			// Binary or string
 			buf := state.BPool.Get()
 			buf.Reset()
 			defer state.BPool.Put(buf)
-			_, err := io.Copy(buf, res.Body)
+			var dst io.Writer = buf
+			if preq.hasher != nil {
+				dst = io.MultiWriter(buf, hasherWriter{preq.hasher})
+			}
+			n, err := io.Copy(dst, res.Body)
 			if err != nil && err != io.EOF {
 				resErr = err
 			}
+			resp.BodySize = n
+			if preq.cache != nil {
+				cacheBody = append([]byte{}, buf.Bytes()...)
+			}
 
 			switch preq.responseType {
 			case ResponseTypeText:
@@ -586,6 +769,7 @@ func (h *HTTP) request(ctx context.Context, preq *parsedHTTPRequest) (*Response,
 
 	if resErr != nil {
 		resp.Error = resErr.Error()
+		resp.err = resErr
 	} else {
 		if preq.activeJar != nil {
 			if rc := res.Cookies(); len(rc) > 0 {
@@ -620,6 +804,20 @@ func (h *HTTP) request(ctx context.Context, preq *parsedHTTPRequest) (*Response,
 				Expires:  c.Expires.UnixNano() / 1000000,
 			})
 		}
+
+		if preq.cache != nil {
+			if revalidating && res.StatusCode == http.StatusNotModified {
+				preq.cache.Revalidated(cached, res)
+				pushSample(ctx, state, metrics.HTTPCacheRevalidations, tags, 1)
+				resp.setCached(cached, preq.responseType, preq.hasher)
+			} else {
+				preq.cache.Store(res.Request, res, cacheBody)
+			}
+		}
+	}
+
+	if state.HTTPRecorder != nil {
+		recordExchange(state.HTTPRecorder, preq, respReq, res, resp, trail)
 	}
 
 	if resErr != nil {
@@ -766,7 +964,89 @@ func (h *HTTP) parseBatchRequest(ctx context.Context, key string, val goja.Value
 		}
 	}
 
-	return h.parseRequest(ctx, method, reqURL, body, params)
+	preq, err := h.parseRequest(ctx, method, reqURL, body, params)
+	if err != nil {
+		return nil, err
+	}
+	if preq.onChunk != nil {
+		return nil, fmt.Errorf("batch request %s can't use onChunk", key)
+	}
+	return preq, nil
+}
+
+// recordExchange adds a finished request to the recording made for the
+// harOutput option. The response body is only included if it was kept.
+func recordExchange(
+	recorder *netext.Recorder, preq *parsedHTTPRequest, req *Request, res *http.Response, resp *Response, trail *netext.Trail,
+) {
+	exchange := &netext.HTTPExchange{
+		Method:           req.Method,
+		URL:              req.URL,
+		RequestHeaders:   preq.req.Header,
+		RequestBody:      req.Body,
+		Status:           resp.Status,
+		Proto:            resp.Proto,
+		ResponseBodySize: resp.BodySize,
+		Error:            resp.Error,
+		Trail:            trail,
+	}
+	if res != nil {
+		// Redirects are followed, so this is the last request that was made
+		exchange.URL = res.Request.URL.String()
+		exchange.RequestHeaders = res.Request.Header
+		exchange.ResponseHeaders = res.Header
+	}
+	switch body := resp.Body.(type) {
+	case string:
+		exchange.ResponseBody = []byte(body)
+	case []byte:
+		exchange.ResponseBody = append([]byte{}, body...)
+	}
+	recorder.Record(exchange)
+}
+
+// readChunks passes the response body to onChunk piece by piece, as it's
+// received, instead of buffering it. It emits the number of chunks and the
+// intervals between them; the first interval is measured from the moment the
+// response headers were received. Errors thrown by the callback are returned
+// separately from the ones encountered while reading the body.
+func readChunks(
+	ctx context.Context, state *common.State, body io.Reader, trail *netext.Trail, onChunk func([]byte) (bool, error),
+) (callbackErr, readErr error) {
+	buf := make([]byte, 32*1024)
+	last := trail.EndTime
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			now := time.Now()
+			stats.PushIfNotCancelled(ctx, state.Samples, stats.ConnectedSamples{
+				Samples: []stats.Sample{
+					{Metric: metrics.HTTPReqChunks, Time: now, Tags: trail.Tags, Value: 1, Metadata: trail.Metadata},
+					{
+						Metric: metrics.HTTPReqChunkInterval, Time: now, Tags: trail.Tags,
+						Value: stats.D(now.Sub(last)), Metadata: trail.Metadata,
+					},
+				},
+				Tags: trail.Tags,
+				Time: now,
+			})
+			last = now
+
+			more, cbErr := onChunk(buf[:n])
+			if cbErr != nil {
+				return cbErr, nil
+			}
+			if !more {
+				return nil, nil
+			}
+		}
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 }
 
 func requestContainsFile(data map[string]interface{}) bool {
@@ -31,9 +31,11 @@ import (
 
 	"github.com/tidwall/gjson"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
 	"github.com/loadimpact/k6/js/modules/k6/html"
+	"github.com/loadimpact/k6/lib/extract"
 	"github.com/loadimpact/k6/lib/netext"
 )
 
@@ -61,21 +63,37 @@ type Response struct {
 	Headers        map[string]string        `json:"headers"`
 	Cookies        map[string][]*HTTPCookie `json:"cookies"`
 	Body           interface{}              `json:"body"`
+	BodySize       int64                    `json:"body_size"`
 	Timings        ResponseTimings          `json:"timings"`
 	TLSVersion     string                   `json:"tls_version"`
 	TLSCipherSuite string                   `json:"tls_cipher_suite"`
+	TLSResumed     bool                     `json:"tls_resumed"`
+	TLSGroup       string                   `json:"tls_group"`
 	OCSP           netext.OCSP              `js:"ocsp" json:"ocsp"`
 	Error          string                   `json:"error"`
 	Request        Request                  `json:"request"`
 
 	cachedJSON    goja.Value
 	validatedJSON bool
+
+	// The tags of the request's metrics, other than the status.
+	tags map[string]string
+
+	// The error the request failed with, if it did, for deciding whether to retry it.
+	err error
+
+	// The body parsed for the extraction helpers, the first time each is used.
+	extractedJSON interface{}
+	extractedXML  *extract.XMLNode
+	extractedHTML *goquery.Document
 }
 
 func (res *Response) setTLSInfo(tlsState *tls.ConnectionState) {
 	tlsInfo, oscp := netext.ParseTLSConnState(tlsState)
 	res.TLSVersion = tlsInfo.Version
 	res.TLSCipherSuite = tlsInfo.CipherSuite
+	res.TLSResumed = tlsInfo.Resumed
+	res.TLSGroup = tlsInfo.Group
 	res.OCSP = oscp
 }
 
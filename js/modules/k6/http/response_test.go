@@ -403,3 +403,53 @@ func BenchmarkResponseJson(b *testing.B) {
 		}
 	})
 }
+
+func TestResponseExtraction(t *testing.T) {
+	tb, _, _, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	tb.Mux.HandleFunc("/extract/json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"items": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}], "token": "t0k3n"}`))
+	})
+	tb.Mux.HandleFunc("/extract/xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<order id="7"><item sku="x1">Pen</item><item sku="x2">Ink</item></order>`))
+	})
+	tb.Mux.HandleFunc("/extract/html", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<form><input name="csrf" value="s3cr3t"></form><a href="/1">One</a><a href="/2">Two</a>`))
+	})
+
+	_, err := common.RunString(rt, sr(`
+		let res = http.get("HTTPBIN_URL/extract/json");
+		if (res.jsonPath("$.token") !== "t0k3n") { throw new Error("wrong token: " + res.jsonPath("$.token")); }
+		if (res.jsonPath("items[?(@.name == 'b')].id") !== 2) { throw new Error("wrong id"); }
+		if (res.jsonPath("$.nope") !== undefined) { throw new Error("expected undefined"); }
+		if (res.jsonPathAll("$.items[*].name").join() !== "a,b") { throw new Error("wrong names"); }
+		if (res.jsonPathAll("$.nope").length !== 0) { throw new Error("expected no values"); }
+		if (res.regex('"token": "(\\w+)"') !== "t0k3n") { throw new Error("wrong regex match"); }
+		if (res.regexAll('"id": \\d').length !== 2) { throw new Error("wrong regex matches"); }
+
+		res = http.get("HTTPBIN_URL/extract/xml");
+		if (res.xpath("/order/@id") !== "7") { throw new Error("wrong order id"); }
+		if (res.xpath("//item[@sku='x2']") !== "Ink") { throw new Error("wrong item"); }
+		if (res.xpathAll("//item/@sku").join() !== "x1,x2") { throw new Error("wrong skus"); }
+
+		res = http.get("HTTPBIN_URL/extract/html");
+		if (res.css("input[name=csrf]", "value") !== "s3cr3t") { throw new Error("wrong csrf token"); }
+		if (res.css("a") !== "One") { throw new Error("wrong link"); }
+		if (res.cssAll("a", "href").join() !== "/1,/2") { throw new Error("wrong links"); }
+		if (res.css("table") !== undefined) { throw new Error("expected undefined"); }
+	`))
+	assert.NoError(t, err)
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, sr(`http.get("HTTPBIN_URL/extract/xml").jsonPath("$.a")`))
+		assert.Contains(t, err.Error(), "the body isn't valid JSON")
+
+		_, err = common.RunString(rt, sr(`http.get("HTTPBIN_URL/extract/json").jsonPath("$[")`))
+		assert.Contains(t, err.Error(), "invalid JSONPath '$['")
+
+		_, err = common.RunString(rt, sr(`http.get("HTTPBIN_URL/extract/json", { responseType: "none" }).regex("a")`))
+		assert.Contains(t, err.Error(), "the response has no body")
+	})
+}
@@ -0,0 +1,128 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+)
+
+// request() shouldn't mess with the goja runtime or other thread-unsafe
+// things because it's called concurrently by Batch()
+func (h *HTTP) request(ctx context.Context, preq *parsedHTTPRequest) (*Response, error) {
+	state := common.GetState(ctx)
+	if preq.retry == nil {
+		return h.attempt(ctx, state, preq)
+	}
+
+	state.HTTPRetryBudget.AddRequest()
+	var body []byte
+	if preq.body != nil {
+		body = append([]byte{}, preq.body.Bytes()...)
+	}
+	tags := state.Options.RunTags.CloneTags()
+	for k, v := range preq.tags {
+		tags[k] = v
+	}
+	for attempt := int64(1); ; attempt++ {
+		if attempt > 1 && preq.body != nil {
+			preq.body = bytes.NewBuffer(body)
+			preq.req.Body = ioutil.NopCloser(preq.body)
+		}
+		resp, err := h.attempt(ctx, state, preq)
+		if _, ok := err.(lib.CircuitOpenError); ok {
+			return resp, err
+		}
+		if attempt >= preq.retry.GetMaxAttempts() || !shouldRetry(preq, resp, err) {
+			return resp, err
+		}
+
+		if resp != nil && resp.tags != nil {
+			tags = resp.tags
+		}
+		if !state.HTTPRetryBudget.Allow(preq.retry.Budget.Float64) {
+			pushSample(ctx, state, metrics.HTTPReqRetryBudgetExhausted, tags, 1)
+			return resp, err
+		}
+		pushSample(ctx, state, metrics.HTTPReqRetries, tags, 1)
+
+		timer := time.NewTimer(preq.retry.Delay(attempt, retryAfter(resp)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		}
+	}
+}
+
+// attempt makes a single attempt at a request, unless the circuit of its endpoint is open, in
+// which case it fails right away, whether or not the request would throw; the error ends the
+// iteration, unless the script catches it.
+func (h *HTTP) attempt(ctx context.Context, state *common.State, preq *parsedHTTPRequest) (*Response, error) {
+	breaker := state.Options.CircuitBreaker
+	if breaker == nil {
+		return h.requestOnce(ctx, preq)
+	}
+
+	name := requestName(state, preq)
+	if !breaker.Allow(name, time.Now()) {
+		return nil, lib.CircuitOpenError{Name: name}
+	}
+	resp, err := h.requestOnce(ctx, preq)
+	breaker.Record(name, err != nil || resp == nil || resp.err != nil || resp.Status >= 500, time.Now())
+	return resp, err
+}
+
+// shouldRetry returns whether the outcome of an attempt at a request should be retried.
+func shouldRetry(preq *parsedHTTPRequest, resp *Response, err error) bool {
+	if err == nil && resp != nil {
+		err = resp.err
+	}
+	if err != nil {
+		return preq.retry.RetriesError(netext.ErrorClass(err))
+	}
+	return resp != nil && preq.retry.RetriesStatus(resp.Status)
+}
+
+// retryAfter returns the delay a response asked for with a Retry-After header, if any.
+func retryAfter(resp *Response) time.Duration {
+	if resp == nil || resp.Headers["Retry-After"] == "" {
+		return 0
+	}
+	value := resp.Headers["Retry-After"]
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,257 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+)
+
+const (
+	tusVersion          = "1.0.0"
+	tusDefaultChunkSize = 4 * 1024 * 1024
+)
+
+// TusUpload is the result of a resumable upload made with tusUpload()
+type TusUpload struct {
+	// URL of the upload resource, which can be used to resume the upload
+	URL string `json:"url"`
+	// Number of bytes the server has confirmed receiving
+	Offset int64 `json:"offset"`
+	// Whether the whole file was uploaded
+	Complete bool `json:"complete"`
+	// The last response received; in case of failure, the one with the error
+	Response *Response `json:"response"`
+}
+
+// TusUpload uploads data using the tus resumable upload protocol
+// (https://tus.io/protocols/resumable-upload.html). A new upload is created on
+// the endpoint, unless the uploadURL of a previous one is passed in params, in
+// which case it's resumed from the offset reported by the server. The data is
+// then sent in PATCH requests of chunkSize bytes. All other params are passed
+// through to the individual requests.
+func (h *HTTP) TusUpload(ctx context.Context, endpoint goja.Value, data goja.Value, paramsV goja.Value) (*TusUpload, error) {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, ErrHTTPForbiddenInInitContext
+	}
+
+	var payload []byte
+	metadata := map[string]string{}
+	switch d := data.Export().(type) {
+	case FileData:
+		payload = d.Data
+		metadata["filename"] = d.Filename
+		metadata["filetype"] = d.ContentType
+	case []byte:
+		payload = d
+	case string:
+		payload = []byte(d)
+	default:
+		return nil, fmt.Errorf("unsupported tus upload data type %T", d)
+	}
+
+	chunkSize := int64(tusDefaultChunkSize)
+	uploadURL := ""
+	userHeaders := map[string]string{}
+	passthrough := map[string]goja.Value{}
+	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		params := paramsV.ToObject(rt)
+		for _, k := range params.Keys() {
+			v := params.Get(k)
+			switch k {
+			case "chunkSize":
+				if chunkSize = v.ToInteger(); chunkSize <= 0 {
+					return nil, fmt.Errorf("invalid tus chunk size %d", chunkSize)
+				}
+			case "uploadURL":
+				uploadURL = v.String()
+			case "metadata":
+				if goja.IsUndefined(v) || goja.IsNull(v) {
+					continue
+				}
+				metadataObj := v.ToObject(rt)
+				for _, key := range metadataObj.Keys() {
+					metadata[key] = metadataObj.Get(key).String()
+				}
+			case "headers":
+				if goja.IsUndefined(v) || goja.IsNull(v) {
+					continue
+				}
+				headersObj := v.ToObject(rt)
+				for _, key := range headersObj.Keys() {
+					userHeaders[key] = headersObj.Get(key).String()
+				}
+			default:
+				passthrough[k] = v
+			}
+		}
+	}
+
+	do := func(method, rawURL string, headers map[string]string, body interface{}) (*Response, error) {
+		reqURL, err := ToURL(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		params := rt.NewObject()
+		for k, v := range passthrough {
+			_ = params.Set(k, v)
+		}
+		headersObj := rt.NewObject()
+		for k, v := range userHeaders {
+			_ = headersObj.Set(k, v)
+		}
+		_ = headersObj.Set("Tus-Resumable", tusVersion)
+		for k, v := range headers {
+			_ = headersObj.Set(k, v)
+		}
+		_ = params.Set("headers", headersObj)
+
+		preq, err := h.parseRequest(ctx, method, reqURL, body, params)
+		if err != nil {
+			return nil, err
+		}
+		return h.request(ctx, preq)
+	}
+
+	result := &TusUpload{}
+	var err error
+	if uploadURL == "" {
+		result.Response, err = do(HTTP_METHOD_POST, endpoint.String(), map[string]string{
+			"Upload-Length":   strconv.Itoa(len(payload)),
+			"Upload-Metadata": encodeTusMetadata(metadata),
+		}, nil)
+		if err != nil || result.Response.Error != "" || result.Response.Status != 201 {
+			return result, err
+		}
+		if uploadURL, err = resolveTusLocation(endpoint.String(), result.Response.Headers["Location"]); err != nil {
+			return result, err
+		}
+	} else {
+		result.Response, err = do(HTTP_METHOD_HEAD, uploadURL, nil, nil)
+		if err != nil || result.Response.Error != "" || result.Response.Status/100 != 2 {
+			return result, err
+		}
+		if result.Offset, err = parseTusOffset(result.Response); err != nil {
+			return result, err
+		}
+	}
+	result.URL = uploadURL
+
+	start := time.Now()
+	startOffset := result.Offset
+	for result.Offset < int64(len(payload)) {
+		end := result.Offset + chunkSize
+		if end > int64(len(payload)) {
+			end = int64(len(payload))
+		}
+		result.Response, err = do(HTTP_METHOD_PATCH, uploadURL, map[string]string{
+			"Content-Type":  "application/offset+octet-stream",
+			"Upload-Offset": strconv.FormatInt(result.Offset, 10),
+		}, payload[result.Offset:end])
+		if err != nil || result.Response.Error != "" || result.Response.Status != 204 {
+			return result, err
+		}
+		if result.Offset, err = parseTusOffset(result.Response); err != nil {
+			return result, err
+		}
+	}
+	result.Complete = true
+
+	if uploaded := result.Offset - startOffset; uploaded > 0 {
+		tags := state.Options.RunTags.CloneTags()
+		for k, v := range passthroughTags(rt, passthrough["tags"]) {
+			tags[k] = v
+		}
+		if state.Options.SystemTags["url"] {
+			tags["url"] = uploadURL
+		}
+		if state.Options.SystemTags["group"] {
+			tags["group"] = state.Group.Path
+		}
+		stats.PushIfNotCancelled(ctx, state.Samples, stats.Sample{
+			Metric: metrics.HTTPUploadThroughput,
+			Time:   start,
+			Tags:   stats.IntoSampleTags(&tags),
+			Value:  float64(uploaded) / time.Since(start).Seconds(),
+		})
+	}
+
+	return result, nil
+}
+
+// The Upload-Metadata header is a list of comma-separated key and base64
+// encoded value pairs; they're sorted to keep it stable between requests.
+func encodeTusMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for k, v := range metadata {
+		pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(v)))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func resolveTusLocation(endpoint, location string) (string, error) {
+	if location == "" {
+		return "", fmt.Errorf("tus server didn't return the upload location")
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	u, err := base.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func parseTusOffset(res *Response) (int64, error) {
+	offset, err := strconv.ParseInt(res.Headers["Upload-Offset"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tus upload offset '%s'", res.Headers["Upload-Offset"])
+	}
+	return offset, nil
+}
+
+func passthroughTags(rt *goja.Runtime, tagsV goja.Value) map[string]string {
+	tags := map[string]string{}
+	if tagsV == nil || goja.IsUndefined(tagsV) || goja.IsNull(tagsV) {
+		return tags
+	}
+	tagsObj := tagsV.ToObject(rt)
+	for _, k := range tagsObj.Keys() {
+		tags[k] = tagsObj.Get(k).String()
+	}
+	return tags
+}
@@ -0,0 +1,175 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A minimal tus server that keeps the uploads in memory
+type tusServer struct {
+	sync.Mutex
+	t        *testing.T
+	uploads  map[string][]byte
+	lengths  map[string]int
+	metadata map[string]string
+	patches  int
+}
+
+func (s *tusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	assert.Equal(s.t, "1.0.0", r.Header.Get("Tus-Resumable"))
+	w.Header().Set("Tus-Resumable", "1.0.0")
+
+	switch r.Method {
+	case "POST":
+		length, err := strconv.Atoi(r.Header.Get("Upload-Length"))
+		require.NoError(s.t, err)
+		id := fmt.Sprintf("/tus/%d", len(s.uploads))
+		s.uploads[id] = nil
+		s.lengths[id] = length
+		s.metadata[id] = r.Header.Get("Upload-Metadata")
+		w.Header().Set("Location", id)
+		w.WriteHeader(http.StatusCreated)
+	case "HEAD":
+		data, ok := s.uploads[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(data)))
+		w.Header().Set("Upload-Length", strconv.Itoa(s.lengths[r.URL.Path]))
+	case "PATCH":
+		data, ok := s.uploads[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.Equal(s.t, "application/offset+octet-stream", r.Header.Get("Content-Type"))
+		if r.Header.Get("Upload-Offset") != strconv.Itoa(len(data)) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(s.t, err)
+		s.uploads[r.URL.Path] = append(data, body...)
+		s.patches++
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.uploads[r.URL.Path])))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestTusUpload(t *testing.T) {
+	t.Parallel()
+	tb, _, samples, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+
+	srv := &tusServer{
+		t:        t,
+		uploads:  map[string][]byte{},
+		lengths:  map[string]int{},
+		metadata: map[string]string{},
+	}
+	tb.Mux.Handle("/tus/", srv)
+
+	t.Run("new", func(t *testing.T) {
+		res, err := common.RunString(rt, tb.Replacer.Replace(`
+		let file = http.file("0123456789abcdefghijklmno", "test.txt", "text/plain");
+		let upload = http.tusUpload("HTTPBIN_URL/tus/", file, { chunkSize: 10, tags: { tag: "value" } });
+		if (!upload.complete) { throw new Error("upload wasn't completed: " + upload.response.status); }
+		if (upload.offset !== 25) { throw new Error("unexpected offset: " + upload.offset); }
+		upload.url;
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, tb.Replacer.Replace("HTTPBIN_URL/tus/0"), res.String())
+
+		srv.Lock()
+		assert.Equal(t, []byte("0123456789abcdefghijklmno"), srv.uploads["/tus/0"])
+		assert.Equal(t, "filename dGVzdC50eHQ=,filetype dGV4dC9wbGFpbg==", srv.metadata["/tus/0"])
+		assert.Equal(t, 3, srv.patches)
+		srv.Unlock()
+
+		var throughput int
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				if sample.Metric == metrics.HTTPUploadThroughput {
+					throughput++
+					tag, _ := sample.Tags.Get("tag")
+					assert.Equal(t, "value", tag)
+					assert.True(t, sample.Value > 0)
+				}
+			}
+		}
+		assert.Equal(t, 1, throughput)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		srv.Lock()
+		srv.uploads["/tus/resumed"] = []byte("hello ")
+		srv.lengths["/tus/resumed"] = 11
+		srv.patches = 0
+		srv.Unlock()
+
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let upload = http.tusUpload("HTTPBIN_URL/tus/", "hello world", { uploadURL: "HTTPBIN_URL/tus/resumed" });
+		if (!upload.complete) { throw new Error("upload wasn't completed: " + upload.response.status); }
+		`))
+		require.NoError(t, err)
+
+		srv.Lock()
+		assert.True(t, bytes.Equal([]byte("hello world"), srv.uploads["/tus/resumed"]))
+		assert.Equal(t, 1, srv.patches)
+		srv.Unlock()
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let upload = http.tusUpload("HTTPBIN_URL/tus/", "data", { uploadURL: "HTTPBIN_URL/tus/missing" });
+		if (upload.complete) { throw new Error("upload shouldn't have completed"); }
+		if (upload.response.status !== 404) { throw new Error("unexpected status: " + upload.response.status); }
+		`))
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid chunk size", func(t *testing.T) {
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		http.tusUpload("HTTPBIN_URL/tus/", "data", { chunkSize: 0 });
+		`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid tus chunk size 0")
+	})
+}
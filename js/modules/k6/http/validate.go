@@ -0,0 +1,345 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/ghodss/yaml"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/jsonschema"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+var openAPIPathParamRE = regexp.MustCompile(`\{[^}/]+\}`)
+
+// ValidateSchema validates the JSON body of a response against a JSON schema, given as an object
+// or a JSON string, and returns the ways in which it doesn't match, if any. Their number is
+// recorded in the schema_violations metric, with the tags of the request and any given tags.
+func (*HTTP) ValidateSchema(ctx context.Context, res *Response, schemaV goja.Value, tags goja.Value) ([]string, error) {
+	if common.GetState(ctx) == nil {
+		return nil, ErrHTTPForbiddenInInitContext
+	}
+	if res == nil {
+		return nil, errors.New("validateSchema() requires a response")
+	}
+
+	var schema *jsonschema.Schema
+	var err error
+	if s, ok := schemaV.Export().(string); ok {
+		schema, err = jsonschema.Parse([]byte(s))
+	} else {
+		schema, err = jsonschema.New(schemaV.Export())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	violations := validateBody(res, schema)
+	recordViolations(ctx, res, violations, nil, tags)
+	return violations, nil
+}
+
+// OpenAPI validates responses against the operations of an OpenAPI 3 spec.
+type OpenAPI struct {
+	spec       *jsonschema.Schema
+	root       map[string]interface{}
+	basePaths  []string
+	operations []openAPIPath
+}
+
+// A path of an OpenAPI spec, and the pattern of the URL paths that match it.
+type openAPIPath struct {
+	template string
+	pattern  *regexp.Regexp
+	params   int
+}
+
+// XOpenAPI parses an OpenAPI 3 spec, in either YAML or JSON format, to validate responses with.
+func (*HTTP) XOpenAPI(ctxPtr *context.Context, data string) (interface{}, error) {
+	jsonData := []byte(data)
+	if trimmed := bytes.TrimSpace(jsonData); len(trimmed) == 0 || trimmed[0] != '{' {
+		var err error
+		if jsonData, err = yaml.YAMLToJSON(jsonData); err != nil {
+			return nil, errors.Wrap(err, "invalid OpenAPI spec")
+		}
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(jsonData, &root); err != nil {
+		return nil, errors.Wrap(err, "invalid OpenAPI spec")
+	}
+	if version, _ := root["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, errors.Errorf("unsupported OpenAPI version '%v', only 3.x specs are supported", root["openapi"])
+	}
+	spec, err := jsonschema.New(root)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &OpenAPI{spec: spec, root: root}
+	servers, _ := root["servers"].([]interface{})
+	for _, server := range servers {
+		rawURL, _ := server.(map[string]interface{})["url"].(string)
+		if u, err := url.Parse(rawURL); err == nil && !openAPIPathParamRE.MatchString(u.Path) {
+			o.basePaths = append(o.basePaths, strings.TrimSuffix(u.Path, "/"))
+		}
+	}
+	// Longer base paths first, so that the most specific one is stripped.
+	sort.Slice(o.basePaths, func(i, j int) bool { return len(o.basePaths[i]) > len(o.basePaths[j]) })
+
+	paths, _ := root["paths"].(map[string]interface{})
+	for template := range paths {
+		pattern := "^"
+		last := 0
+		locs := openAPIPathParamRE.FindAllStringIndex(template, -1)
+		for _, loc := range locs {
+			pattern += regexp.QuoteMeta(template[last:loc[0]]) + "[^/]+"
+			last = loc[1]
+		}
+		pattern += regexp.QuoteMeta(template[last:]) + "/?$"
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid path '%s'", template)
+		}
+		o.operations = append(o.operations, openAPIPath{template: template, pattern: re, params: len(locs)})
+	}
+	// A path without parameters takes precedence over a templated one that also matches.
+	sort.Slice(o.operations, func(i, j int) bool {
+		if o.operations[i].params != o.operations[j].params {
+			return o.operations[i].params < o.operations[j].params
+		}
+		return o.operations[i].template < o.operations[j].template
+	})
+	return common.Bind(common.GetRuntime(*ctxPtr), o, ctxPtr), nil
+}
+
+// Validate validates a response against the spec: the request must match an operation, the
+// status must be documented for it, and the JSON body must match the documented schema. It
+// returns the ways in which the response doesn't match, if any, which are recorded like
+// validateSchema() does, with the operation as the "operation" tag.
+func (o *OpenAPI) Validate(ctx context.Context, res *Response, tags goja.Value) ([]string, error) {
+	if common.GetState(ctx) == nil {
+		return nil, ErrHTTPForbiddenInInitContext
+	}
+	if res == nil {
+		return nil, errors.New("validate() requires a response")
+	}
+
+	method := strings.ToLower(res.Request.Method)
+	path := ""
+	if u, err := url.Parse(res.Request.URL); err == nil {
+		path = u.Path
+	}
+	template, ok := o.match(method, path)
+	if !ok {
+		violations := []string{fmt.Sprintf("no operation for %s %s in the spec", res.Request.Method, path)}
+		recordViolations(ctx, res, violations, nil, tags)
+		return violations, nil
+	}
+
+	pointer := "#/paths/" + escapePointer(template) + "/" + method
+	opTags := map[string]string{"operation": strings.ToUpper(method) + " " + template}
+	if op, _ := o.lookup(pointer).(map[string]interface{}); op != nil {
+		if id, _ := op["operationId"].(string); id != "" {
+			opTags["operation"] = id
+		}
+	}
+
+	violations := []string{}
+	if pointer, ok = o.responsePointer(pointer, res.Status); !ok {
+		violations = []string{fmt.Sprintf("status %d isn't documented for %s", res.Status, opTags["operation"])}
+	} else if pointer, ok = o.contentPointer(pointer, res.Headers["Content-Type"]); ok {
+		schema, err := o.spec.At(pointer + "/schema")
+		if err != nil {
+			return nil, err
+		}
+		violations = validateBody(res, schema)
+	}
+	recordViolations(ctx, res, violations, opTags, tags)
+	return violations, nil
+}
+
+// match finds the path of the spec that a request is for, if it has an operation for the method.
+func (o *OpenAPI) match(method, path string) (string, bool) {
+	candidates := []string{path}
+	for _, base := range o.basePaths {
+		if base != "" && strings.HasPrefix(path, base) {
+			candidates = append(candidates, strings.TrimPrefix(path, base))
+		}
+	}
+	for _, p := range o.operations {
+		for _, candidate := range candidates {
+			if p.pattern.MatchString(candidate) && o.lookup("#/paths/"+escapePointer(p.template)+"/"+method) != nil {
+				return p.template, true
+			}
+		}
+	}
+	return "", false
+}
+
+// responsePointer returns where the response of an operation for a status is documented: its
+// own status, its range, eg. "2XX", or the default.
+func (o *OpenAPI) responsePointer(op string, status int) (string, bool) {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		pointer := op + "/responses/" + key
+		if o.lookup(pointer) != nil {
+			return o.deref(pointer), true
+		}
+	}
+	return "", false
+}
+
+// contentPointer returns where the JSON body of a response is documented, if it is: under its
+// content type, or else under any JSON content type.
+func (o *OpenAPI) contentPointer(response, contentType string) (string, bool) {
+	content, _ := o.lookup(response + "/content").(map[string]interface{})
+	if len(content) == 0 {
+		return "", false
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if _, ok := content[mediaType]; ok && isJSONMediaType(mediaType) {
+		return o.schemaPointer(response + "/content/" + escapePointer(mediaType))
+	}
+	keys := make([]string, 0, len(content))
+	for key := range content {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if isJSONMediaType(key) {
+			return o.schemaPointer(response + "/content/" + escapePointer(key))
+		}
+	}
+	return "", false
+}
+
+// schemaPointer returns the media type, if it has a schema.
+func (o *OpenAPI) schemaPointer(mediaType string) (string, bool) {
+	if o.lookup(mediaType+"/schema") == nil {
+		return "", false
+	}
+	return mediaType, true
+}
+
+// deref follows the $ref of the object at a pointer, if it has one.
+func (o *OpenAPI) deref(pointer string) string {
+	for i := 0; i < 32; i++ {
+		obj, _ := o.lookup(pointer).(map[string]interface{})
+		ref, _ := obj["$ref"].(string)
+		if !strings.HasPrefix(ref, "#") {
+			break
+		}
+		pointer = ref
+	}
+	return pointer
+}
+
+// lookup returns the value at a JSON pointer into the spec, or nil.
+func (o *OpenAPI) lookup(pointer string) interface{} {
+	var v interface{} = o.root
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "#/"), "/") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[strings.NewReplacer("~1", "/", "~0", "~").Replace(token)]
+	}
+	return v
+}
+
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// validateBody validates the JSON body of a response against a schema.
+func validateBody(res *Response, schema *jsonschema.Schema) []string {
+	var body []byte
+	switch b := res.Body.(type) {
+	case []byte:
+		body = b
+	case string:
+		body = []byte(b)
+	default:
+		return []string{"the response has no body"}
+	}
+	errs, err := schema.ValidateJSON(body)
+	if err != nil {
+		return []string{"the body isn't valid JSON: " + err.Error()}
+	}
+	violations := make([]string, len(errs))
+	for i, e := range errs {
+		violations[i] = e.Error()
+	}
+	return violations
+}
+
+// recordViolations emits the number of violations of a response, with the tags of its request,
+// its status, and the given tags.
+func recordViolations(ctx context.Context, res *Response, violations []string, extra map[string]string, tags goja.Value) {
+	state := common.GetState(ctx)
+	sampleTags := make(map[string]string, len(res.tags)+len(extra)+1)
+	if res.tags != nil {
+		for k, v := range res.tags {
+			sampleTags[k] = v
+		}
+	} else {
+		for k, v := range state.Options.RunTags.CloneTags() {
+			sampleTags[k] = v
+		}
+	}
+	if state.Options.SystemTags["status"] {
+		sampleTags["status"] = strconv.Itoa(res.Status)
+	}
+	for k, v := range extra {
+		sampleTags[k] = v
+	}
+	if tags != nil && !goja.IsUndefined(tags) && !goja.IsNull(tags) {
+		obj := tags.ToObject(common.GetRuntime(ctx))
+		for _, k := range obj.Keys() {
+			sampleTags[k] = obj.Get(k).String()
+		}
+	}
+
+	stats.PushIfNotCancelled(ctx, state.Samples, stats.Sample{
+		Time:   time.Now(),
+		Metric: metrics.SchemaViolations,
+		Tags:   stats.IntoSampleTags(&sampleTags),
+		Value:  float64(len(violations)),
+	})
+}
@@ -0,0 +1,174 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOpenAPISpec = `
+openapi: 3.0.0
+servers:
+  - url: HTTPBIN_URL/api
+paths:
+  /users/{id}:
+    get:
+      operationId: getUser
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/User"
+        "404":
+          $ref: "#/components/responses/NotFound"
+  /users/me:
+    get:
+      responses:
+        "2XX":
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/User"
+components:
+  schemas:
+    User:
+      type: object
+      required: [id, name]
+      properties:
+        id: {type: integer}
+        name: {type: string}
+  responses:
+    NotFound:
+      content:
+        application/problem+json:
+          schema:
+            type: object
+            required: [title]
+`
+
+// violationSamples returns the schema_violations samples that were emitted.
+func violationSamples(samples chan stats.SampleContainer) []stats.Sample {
+	var result []stats.Sample
+	for _, container := range stats.GetBufferedSamples(samples) {
+		for _, sample := range container.GetSamples() {
+			if sample.Metric == metrics.SchemaViolations {
+				result = append(result, sample)
+			}
+		}
+	}
+	return result
+}
+
+func TestResponseValidation(t *testing.T) {
+	tb, _, samples, rt, _ := newRuntime(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+
+	respond := func(status int, contentType, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}
+	}
+	tb.Mux.HandleFunc("/api/users/1", respond(200, "application/json", `{"id": 1, "name": "Jane"}`))
+	tb.Mux.HandleFunc("/api/users/2", respond(200, "application/json", `{"id": "2"}`))
+	tb.Mux.HandleFunc("/api/users/3", respond(404, "application/problem+json", `{"detail": "no such user"}`))
+	tb.Mux.HandleFunc("/api/users/4", respond(500, "text/plain", `oops`))
+	tb.Mux.HandleFunc("/api/users/me", respond(200, "application/json", `{"id": 1, "name": "Me"}`))
+	tb.Mux.HandleFunc("/api/groups", respond(200, "application/json", `[]`))
+
+	rt.Set("specData", sr(testOpenAPISpec))
+	_, err := common.RunString(rt, `let spec = new http.OpenAPI(specData);`)
+	require.NoError(t, err)
+
+	testdata := map[string]struct {
+		path       string
+		violations []string
+		operation  string
+	}{
+		"Valid":        {"/users/1", []string{}, "getUser"},
+		"InvalidBody":  {"/users/2", []string{"missing required property 'name'", "/id: expected integer, got string"}, "getUser"},
+		"RefResponse":  {"/users/3", []string{"missing required property 'title'"}, "getUser"},
+		"Undocumented": {"/users/4", []string{"status 500 isn't documented for getUser"}, "getUser"},
+		"StatusRange":  {"/users/me", []string{}, "GET /users/me"},
+		"NoOperation":  {"/groups", []string{"no operation for GET /api/groups in the spec"}, ""},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			stats.GetBufferedSamples(samples)
+			v, err := common.RunString(rt, sr(`spec.validate(http.get("HTTPBIN_URL/api`+data.path+`"), { team: "a" })`))
+			require.NoError(t, err)
+			var violations []string
+			require.NoError(t, rt.ExportTo(v, &violations))
+			assert.Equal(t, data.violations, violations)
+
+			vs := violationSamples(samples)
+			require.Len(t, vs, 1)
+			assert.Equal(t, float64(len(violations)), vs[0].Value)
+			tags := vs[0].Tags.CloneTags()
+			assert.Equal(t, "a", tags["team"])
+			assert.Equal(t, "GET", tags["method"])
+			assert.Equal(t, sr("HTTPBIN_URL/api"+data.path), tags["name"])
+			if data.operation != "" {
+				assert.Equal(t, data.operation, tags["operation"])
+			}
+		})
+	}
+
+	t.Run("Schema", func(t *testing.T) {
+		stats.GetBufferedSamples(samples)
+		v, err := common.RunString(rt, sr(`
+		let res = http.get("HTTPBIN_URL/api/users/1");
+		http.validateSchema(res, { type: "object", required: ["email"] }).concat(
+			http.validateSchema(res, '{"type": "object", "required": ["id"]}'))
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{"missing required property 'email'"}, v.Export())
+
+		vs := violationSamples(samples)
+		require.Len(t, vs, 2)
+		assert.Equal(t, float64(1), vs[0].Value)
+		assert.Equal(t, float64(0), vs[1].Value)
+		assert.Equal(t, "200", vs[0].Tags.CloneTags()["status"])
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		v, err := common.RunString(rt, sr(`http.validateSchema(http.get("HTTPBIN_URL/api/users/4"), {})`))
+		require.NoError(t, err)
+		violations := v.Export().([]string)
+		require.Len(t, violations, 1)
+		assert.Contains(t, violations[0], "the body isn't valid JSON")
+	})
+
+	t.Run("InvalidSpec", func(t *testing.T) {
+		_, err := common.RunString(rt, `new http.OpenAPI('{"swagger": "2.0"}')`)
+		assert.Contains(t, err.Error(), "only 3.x specs are supported")
+	})
+}
@@ -29,6 +29,7 @@ import (
 
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/stats"
 	"github.com/pkg/errors"
@@ -51,12 +52,7 @@ func (*K6) Fail(msg string) (goja.Value, error) {
 }
 
 func (*K6) Sleep(ctx context.Context, secs float64) {
-	timer := time.NewTimer(time.Duration(secs * float64(time.Second)))
-	select {
-	case <-timer.C:
-	case <-ctx.Done():
-		timer.Stop()
-	}
+	sleepFor(ctx, time.Duration(secs*float64(time.Second)))
 }
 
 func (*K6) RandomSeed(ctx context.Context, seed int64) {
@@ -119,22 +115,14 @@ func (*K6) Check(ctx context.Context, arg0, checks goja.Value, extras ...goja.Va
 	t := time.Now()
 
 	// Prepare tags, make sure the `group` tag can't be overwritten.
-	commonTags := state.Options.RunTags.CloneTags()
-	if state.Options.SystemTags["group"] {
-		commonTags["group"] = state.Group.Path
-	}
+	userTags := make(map[string]string)
 	if len(extras) > 0 {
 		obj := extras[0].ToObject(rt)
 		for _, k := range obj.Keys() {
-			commonTags[k] = obj.Get(k).String()
+			userTags[k] = obj.Get(k).String()
 		}
 	}
-	if state.Options.SystemTags["vu"] {
-		commonTags["vu"] = strconv.FormatInt(state.Vu, 10)
-	}
-	if state.Options.SystemTags["iter"] {
-		commonTags["iter"] = strconv.FormatInt(state.Iteration, 10)
-	}
+	commonTags := checkTags(state, userTags)
 
 	succ := true
 	obj := checks.ToObject(rt)
@@ -155,6 +143,21 @@ func (*K6) Check(ctx context.Context, arg0, checks goja.Value, extras ...goja.Va
 			tags["check"] = check.Name
 		}
 
+		// A check can be given as an object with a severity; the check itself is its "check".
+		severity := ""
+		if def, ok := checkDefinition(val); ok {
+			val = def.Get("check")
+			if sev := def.Get("severity"); sev != nil && !goja.IsUndefined(sev) {
+				severity = sev.String()
+				if severity != lib.CheckSeverityError && severity != lib.CheckSeverityWarn {
+					return false, errors.Errorf("invalid severity '%s' for check '%s', use: '%s' or '%s'",
+						severity, name, lib.CheckSeverityError, lib.CheckSeverityWarn)
+				}
+				check.SetSeverity(severity)
+				tags["severity"] = severity
+			}
+		}
+
 		// Resolve callables into values.
 		fn, ok := goja.AssertFunction(val)
 		if ok {
@@ -165,23 +168,116 @@ func (*K6) Check(ctx context.Context, arg0, checks goja.Value, extras ...goja.Va
 			val = tmpVal
 		}
 
-		sampleTags := stats.IntoSampleTags(&tags)
-
-		// Emit! (But only if we have a valid context.)
-		select {
-		case <-ctx.Done():
-		default:
-			if val.ToBoolean() {
-				atomic.AddInt64(&check.Passes, 1)
-				stats.PushIfNotCancelled(ctx, state.Samples, stats.Sample{Time: t, Metric: metrics.Checks, Tags: sampleTags, Value: 1})
-			} else {
-				atomic.AddInt64(&check.Fails, 1)
-				stats.PushIfNotCancelled(ctx, state.Samples, stats.Sample{Time: t, Metric: metrics.Checks, Tags: sampleTags, Value: 0})
-				// A single failure makes the return value false.
-				succ = false
-			}
+		// A check may return the details of a failure along with its outcome.
+		pass := val.ToBoolean()
+		var result *goja.Object
+		if obj, ok := val.(*goja.Object); ok && obj.Get("pass") != nil {
+			result = obj
+			pass = obj.Get("pass").ToBoolean()
+		}
+
+		// A single failure makes the return value false, unless it's only a warning.
+		if recordCheck(ctx, state, check, t, tags, pass, checkFailure(result)) && !pass && severity != lib.CheckSeverityWarn {
+			succ = false
 		}
 	}
 
 	return succ, nil
 }
+
+// RecordCheck records an outcome of the check with the given name in the current group, the same
+// way check() does, for modules that build on checks. If it failed, the details of the failure
+// are kept along with the time, VU and iteration.
+func RecordCheck(ctx context.Context, name string, pass bool, failure lib.CheckFailure) error {
+	state := common.GetState(ctx)
+	if state == nil {
+		return ErrCheckInInitContext
+	}
+	check, err := state.Group.Check(name)
+	if err != nil {
+		return err
+	}
+	tags := checkTags(state, nil)
+	if state.Options.SystemTags["check"] {
+		tags["check"] = check.Name
+	}
+	recordCheck(ctx, state, check, time.Now(), tags, pass, failure)
+	return nil
+}
+
+// checkTags returns the tags of a check's samples, other than the check's own. User tags can't
+// overwrite the group tag.
+func checkTags(state *common.State, userTags map[string]string) map[string]string {
+	tags := state.Options.RunTags.CloneTags()
+	if state.Options.SystemTags["group"] {
+		tags["group"] = state.Group.Path
+	}
+	for k, v := range userTags {
+		tags[k] = v
+	}
+	if state.Options.SystemTags["vu"] {
+		tags["vu"] = strconv.FormatInt(state.Vu, 10)
+	}
+	if state.Options.SystemTags["iter"] {
+		tags["iter"] = strconv.FormatInt(state.Iteration, 10)
+	}
+	return tags
+}
+
+// recordCheck counts and emits an outcome of a check, and returns whether it did; nothing is
+// recorded once the context is done.
+func recordCheck(
+	ctx context.Context, state *common.State, check *lib.Check, t time.Time, tags map[string]string,
+	pass bool, failure lib.CheckFailure,
+) bool {
+	sampleTags := stats.IntoSampleTags(&tags)
+
+	// Emit! (But only if we have a valid context.)
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+	}
+	if pass {
+		atomic.AddInt64(&check.Passes, 1)
+		stats.PushIfNotCancelled(ctx, state.Samples, stats.Sample{Time: t, Metric: metrics.Checks, Tags: sampleTags, Value: 1})
+	} else {
+		atomic.AddInt64(&check.Fails, 1)
+		stats.PushIfNotCancelled(ctx, state.Samples, stats.Sample{Time: t, Metric: metrics.Checks, Tags: sampleTags, Value: 0})
+		failure.Time, failure.VU, failure.Iteration = t, state.Vu, state.Iteration
+		check.AddFailure(failure)
+	}
+	return true
+}
+
+// checkDefinition returns the object a check is defined by, if it's given as one, eg.
+// { check: (r) => r.status === 200, severity: "warn" }.
+func checkDefinition(val goja.Value) (*goja.Object, bool) {
+	obj, ok := val.(*goja.Object)
+	if !ok {
+		return nil, false
+	}
+	if _, isFn := goja.AssertFunction(val); isFn || obj.Get("check") == nil {
+		return nil, false
+	}
+	return obj, true
+}
+
+// checkFailure returns the details a check returned along with its outcome, if any, eg.
+// { pass: false, expected: 200, actual: 503, message: "bad status" }.
+func checkFailure(result *goja.Object) lib.CheckFailure {
+	var f lib.CheckFailure
+	if result == nil {
+		return f
+	}
+	if v := result.Get("expected"); v != nil && !goja.IsUndefined(v) {
+		f.Expected = v.Export()
+	}
+	if v := result.Get("actual"); v != nil && !goja.IsUndefined(v) {
+		f.Actual = v.Export()
+	}
+	if v := result.Get("message"); v != nil && !goja.IsUndefined(v) {
+		f.Message = v.String()
+	}
+	return f
+}
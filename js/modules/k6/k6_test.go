@@ -364,4 +364,74 @@ func TestCheck(t *testing.T) {
 			}, sample.Tags.CloneTags())
 		}
 	})
+
+	t.Run("Severity", func(t *testing.T) {
+		state, samples := getState()
+		*ctx = common.WithState(baseCtx, state)
+
+		v, err := common.RunString(rt, `k6.check(null, {
+			"warning": { check: false, severity: "warn" },
+			"error": { check: () => true, severity: "error" },
+		})`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, true, v.Export(), "a failed warning doesn't fail the check")
+		}
+		assert.Equal(t, lib.CheckSeverityWarn, root.Checks["warning"].GetSeverity())
+		assert.Equal(t, int64(1), root.Checks["warning"].Fails)
+		assert.Equal(t, int64(1), root.Checks["error"].Passes)
+
+		bufSamples := stats.GetBufferedSamples(samples)
+		if assert.Len(t, bufSamples, 2) {
+			for _, sc := range bufSamples {
+				sample, ok := sc.(stats.Sample)
+				require.True(t, ok)
+				check, _ := sample.Tags.Get("check")
+				severity, _ := sample.Tags.Get("severity")
+				assert.Equal(t, map[string]string{"warning": "warn", "error": "error"}[check], severity)
+			}
+		}
+
+		v, err = common.RunString(rt, `k6.check(null, { "failing error": { check: false, severity: "error" } })`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, false, v.Export())
+		}
+
+		_, err = common.RunString(rt, `k6.check(null, { "invalid": { check: true, severity: "fatal" } })`)
+		assert.EqualError(t, err, "GoError: invalid severity 'fatal' for check 'invalid', use: 'error' or 'warn'")
+	})
+
+	t.Run("FailureDetails", func(t *testing.T) {
+		state, _ := getState()
+		state.Vu, state.Iteration = 2, 3
+		*ctx = common.WithState(baseCtx, state)
+
+		v, err := common.RunString(rt, `k6.check({ status: 503 }, {
+			"status": (r) => ({ pass: r.status === 200, expected: 200, actual: r.status, message: "bad status" }),
+			"passing details": (r) => ({ pass: true, expected: 503, actual: r.status }),
+			"no details": false,
+		})`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, false, v.Export())
+		}
+
+		failures := root.Checks["status"].GetFailureSamples()
+		if assert.Len(t, failures, 1) {
+			assert.NotZero(t, failures[0].Time)
+			assert.Equal(t, int64(2), failures[0].VU)
+			assert.Equal(t, int64(3), failures[0].Iteration)
+			assert.Equal(t, int64(200), failures[0].Expected)
+			assert.Equal(t, int64(503), failures[0].Actual)
+			assert.Equal(t, "bad status", failures[0].Message)
+		}
+		assert.Equal(t, int64(1), root.Checks["passing details"].Passes)
+		assert.Len(t, root.Checks["passing details"].GetFailureSamples(), 0)
+		assert.Len(t, root.Checks["no details"].GetFailureSamples(), 1)
+
+		for i := 0; i < lib.MaxCheckFailureSamples+5; i++ {
+			_, err := common.RunString(rt, `k6.check(null, { "many failures": false })`)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int64(lib.MaxCheckFailureSamples+5), root.Checks["many failures"].Fails)
+		assert.Len(t, root.Checks["many failures"].GetFailureSamples(), lib.MaxCheckFailureSamples)
+	})
 }
@@ -0,0 +1,322 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kv
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// ErrKVInInitContext is returned when using a store in the init context, which runs more than
+// once for every VU.
+var ErrKVInInitContext = common.NewInitContextError("Key-value stores can't be used in the init context")
+
+// A backend holds the data of a store. Values are JSON-encoded. The context is the one of the
+// VU making the call.
+type backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) (bool, error)
+	Incr(ctx context.Context, key string, by int64) (int64, error)
+	Push(ctx context.Context, key string, value []byte) error
+	Pop(ctx context.Context, key string) ([]byte, bool, error)
+	Keys(ctx context.Context, prefix string) ([]string, error)
+}
+
+// memoryBackend keeps the data in memory, shared between the VUs of the test.
+type memoryBackend struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	lists  map[string][][]byte
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{values: make(map[string][]byte), lists: make(map[string][][]byte)}
+}
+
+func (m *memoryBackend) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.values[key]
+	return v, ok, nil
+}
+
+func (m *memoryBackend) Set(_ context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.lists, key)
+	m.values[key] = value
+	return nil
+}
+
+func (m *memoryBackend) Delete(_ context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, isValue := m.values[key]
+	_, isList := m.lists[key]
+	delete(m.values, key)
+	delete(m.lists, key)
+	return isValue || isList, nil
+}
+
+func (m *memoryBackend) Incr(_ context.Context, key string, by int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	if v, ok := m.values[key]; ok {
+		var err error
+		if n, err = strconv.ParseInt(string(v), 10, 64); err != nil {
+			return 0, errors.Errorf("the value of '%s' is not an integer", key)
+		}
+	}
+	n += by
+	m.values[key] = []byte(strconv.FormatInt(n, 10))
+	return n, nil
+}
+
+func (m *memoryBackend) Push(_ context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[key]; ok {
+		return errors.Errorf("the value of '%s' is not a list", key)
+	}
+	m.lists[key] = append(m.lists[key], value)
+	return nil
+}
+
+func (m *memoryBackend) Pop(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[key]; ok {
+		return nil, false, errors.Errorf("the value of '%s' is not a list", key)
+	}
+	list := m.lists[key]
+	if len(list) == 0 {
+		return nil, false, nil
+	}
+	v := list[0]
+	if len(list) == 1 {
+		delete(m.lists, key)
+	} else {
+		m.lists[key] = list[1:]
+	}
+	return v, true, nil
+}
+
+func (m *memoryBackend) Keys(_ context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []string{}
+	for k := range m.values {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range m.lists {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Store is the JS handle to a key-value store.
+type Store struct {
+	backend backend
+}
+
+func (s Store) runtime(ctx context.Context) (*goja.Runtime, error) {
+	if common.GetState(ctx) == nil {
+		return nil, ErrKVInInitContext
+	}
+	return common.GetRuntime(ctx), nil
+}
+
+func encode(rt *goja.Runtime, value goja.Value) ([]byte, error) {
+	if value == nil || goja.IsUndefined(value) {
+		return nil, errors.New("can't store undefined")
+	}
+	stringify, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("stringify"))
+	data, err := stringify(goja.Undefined(), value)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(data.String()), nil
+}
+
+func decode(rt *goja.Runtime, data []byte) (goja.Value, error) {
+	parse, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("parse"))
+	return parse(goja.Undefined(), rt.ToValue(string(data)))
+}
+
+// Get returns the value of a key, or null if it isn't set.
+func (s Store) Get(ctx context.Context, key string) (goja.Value, error) {
+	rt, err := s.runtime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, ok, err := s.backend.Get(ctx, key)
+	if err != nil || !ok {
+		return goja.Null(), err
+	}
+	return decode(rt, data)
+}
+
+// Set sets the value of a key; it can be anything that can be serialized to JSON.
+func (s Store) Set(ctx context.Context, key string, value goja.Value) {
+	rt, err := s.runtime(ctx)
+	if err == nil {
+		var data []byte
+		if data, err = encode(rt, value); err == nil {
+			err = s.backend.Set(ctx, key, data)
+		}
+	}
+	if err != nil {
+		common.Throw(common.GetRuntime(ctx), err)
+	}
+}
+
+// Delete removes a key, returning whether it was set.
+func (s Store) Delete(ctx context.Context, key string) (bool, error) {
+	if _, err := s.runtime(ctx); err != nil {
+		return false, err
+	}
+	return s.backend.Delete(ctx, key)
+}
+
+// Incr atomically adds to an integer value, 1 by default, and returns the result.
+func (s Store) Incr(ctx context.Context, key string, by goja.Value) (int64, error) {
+	if _, err := s.runtime(ctx); err != nil {
+		return 0, err
+	}
+	n := int64(1)
+	if by != nil && !goja.IsUndefined(by) {
+		n = by.ToInteger()
+	}
+	return s.backend.Incr(ctx, key, n)
+}
+
+// Push appends a value to the list at a key, so that a consumer can pop() it.
+func (s Store) Push(ctx context.Context, key string, value goja.Value) {
+	rt, err := s.runtime(ctx)
+	if err == nil {
+		var data []byte
+		if data, err = encode(rt, value); err == nil {
+			err = s.backend.Push(ctx, key, data)
+		}
+	}
+	if err != nil {
+		common.Throw(common.GetRuntime(ctx), err)
+	}
+}
+
+// Pop removes and returns the oldest value in the list at a key, or null if it's empty.
+func (s Store) Pop(ctx context.Context, key string) (goja.Value, error) {
+	rt, err := s.runtime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, ok, err := s.backend.Pop(ctx, key)
+	if err != nil || !ok {
+		return goja.Null(), err
+	}
+	return decode(rt, data)
+}
+
+// Keys returns the keys starting with the given prefix, or all keys, in order.
+func (s Store) Keys(ctx context.Context, prefix string) ([]string, error) {
+	if _, err := s.runtime(ctx); err != nil {
+		return nil, err
+	}
+	return s.backend.Keys(ctx, prefix)
+}
+
+// KV is the k6/kv module. Stores are identified by name, and shared between all VUs of the test
+// that declare one with the same name.
+type KV struct {
+	mu     sync.Mutex
+	stores map[string]backend
+}
+
+// New returns a new k6/kv module.
+func New() *KV {
+	return &KV{stores: make(map[string]backend)}
+}
+
+// Close closes the connections of the module's stores. It's a function rather than a method, so
+// that it isn't exposed to scripts.
+func Close(kv *KV) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	for _, b := range kv.stores {
+		if rb, ok := b.(*redisBackend); ok {
+			rb.Close()
+		}
+	}
+}
+
+// XStore declares a key-value store. By default it's kept in memory; with the redis param set
+// to a redis:// URL, it's kept in Redis instead, under keys prefixed with the store name, so
+// it can also be shared between k6 instances.
+func (kv *KV) XStore(ctxPtr *context.Context, name string, paramsV goja.Value) (interface{}, error) {
+	if common.GetState(*ctxPtr) != nil {
+		return nil, errors.New("key-value stores must be declared in the init context")
+	}
+	if name == "" {
+		return nil, errors.New("key-value stores need a name")
+	}
+	rt := common.GetRuntime(*ctxPtr)
+
+	var redisURL string
+	if paramsV != nil && !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		params := paramsV.ToObject(rt)
+		for _, k := range params.Keys() {
+			switch k {
+			case "redis":
+				redisURL = params.Get(k).String()
+			}
+		}
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	b, ok := kv.stores[name]
+	if !ok {
+		if redisURL != "" {
+			var err error
+			if b, err = newRedisBackend(redisURL, name+":"); err != nil {
+				return nil, err
+			}
+		} else {
+			b = newMemoryBackend()
+		}
+		kv.stores[name] = b
+	}
+	return common.Bind(rt, Store{b}, ctxPtr), nil
+}
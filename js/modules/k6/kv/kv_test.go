@@ -0,0 +1,139 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newVUs returns n runtimes with the module bound that have run the init code, and a function
+// to leave the init context, with the given state.
+func newVUs(t *testing.T, module *KV, n int, init string) ([]*goja.Runtime, func(*common.State)) {
+	var rts []*goja.Runtime
+	var ctxPtrs []*context.Context
+	for i := 0; i < n; i++ {
+		rt := goja.New()
+		rt.SetFieldNameMapper(common.FieldNameMapper{})
+		ctxPtr := new(context.Context)
+		*ctxPtr = common.WithRuntime(context.Background(), rt)
+		rt.Set("kv", common.Bind(rt, module, ctxPtr))
+		_, err := common.RunString(rt, init)
+		require.NoError(t, err)
+		rts = append(rts, rt)
+		ctxPtrs = append(ctxPtrs, ctxPtr)
+	}
+	return rts, func(state *common.State) {
+		for _, ctxPtr := range ctxPtrs {
+			*ctxPtr = common.WithState(*ctxPtr, state)
+		}
+	}
+}
+
+func testStore(t *testing.T, module *KV, init string) {
+	rts, start := newVUs(t, module, 2, init)
+	producer, consumer := rts[0], rts[1]
+
+	_, err := common.RunString(producer, `store.set("a", 1)`)
+	assert.Contains(t, err.Error(), "Key-value stores can't be used in the init context")
+	start(&common.State{})
+
+	t.Run("Values", func(t *testing.T) {
+		_, err := common.RunString(producer, `
+		store.set("token", { id: "abc", scopes: ["read"] });
+		store.set("count", 1);`)
+		require.NoError(t, err)
+
+		v, err := common.RunString(consumer, `
+		let token = store.get("token");
+		token.scopes.push("write"); // doesn't affect the stored value
+		JSON.stringify([token.id, store.get("token").scopes, store.get("count"), store.get("missing")])`)
+		require.NoError(t, err)
+		assert.Equal(t, `["abc",["read"],1,null]`, v.String())
+
+		v, err = common.RunString(consumer, `[store.delete("count"), store.delete("count"), store.get("count")]`)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{true, false, nil}, v.Export())
+
+		_, err = common.RunString(consumer, `store.set("u", undefined)`)
+		assert.Contains(t, err.Error(), "can't store undefined")
+	})
+
+	t.Run("Incr", func(t *testing.T) {
+		v, err := common.RunString(producer, `[store.incr("hits"), store.incr("hits", 5), store.incr("hits", -2)]`)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{int64(1), int64(6), int64(4)}, v.Export())
+
+		v, err = common.RunString(consumer, `store.get("hits")`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), v.Export())
+	})
+
+	t.Run("Queue", func(t *testing.T) {
+		_, err := common.RunString(producer, `store.push("orders", { id: 1 }); store.push("orders", { id: 2 });`)
+		require.NoError(t, err)
+
+		v, err := common.RunString(consumer, `JSON.stringify([store.pop("orders"), store.pop("orders"), store.pop("orders")])`)
+		require.NoError(t, err)
+		assert.Equal(t, `[{"id":1},{"id":2},null]`, v.String())
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		_, err := common.RunString(producer, `store.set("user:2", 2); store.set("user:1", 1); store.push("user:q", 1);`)
+		require.NoError(t, err)
+
+		v, err := common.RunString(consumer, `store.keys("user:")`)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user:1", "user:2", "user:q"}, v.Export())
+
+		v, err = common.RunString(consumer, `store.keys().length`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), v.Export())
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, New(), `let store = new kv.Store("tokens");`)
+
+	t.Run("Declaration", func(t *testing.T) {
+		rts, _ := newVUs(t, New(), 1, ``)
+		_, err := common.RunString(rts[0], `new kv.Store("")`)
+		assert.Contains(t, err.Error(), "key-value stores need a name")
+		_, err = common.RunString(rts[0], `new kv.Store("a", { redis: "http://localhost" })`)
+		assert.Contains(t, err.Error(), "unsupported Redis URL scheme 'http'")
+	})
+
+	t.Run("Types", func(t *testing.T) {
+		ctx := context.Background()
+		b := newMemoryBackend()
+		assert.NoError(t, b.Set(ctx, "s", []byte(`"x"`)))
+		_, err := b.Incr(ctx, "s", 1)
+		assert.EqualError(t, err, "the value of 's' is not an integer")
+		assert.EqualError(t, b.Push(ctx, "s", []byte(`1`)), "the value of 's' is not a list")
+		_, _, err = b.Pop(ctx, "s")
+		assert.EqualError(t, err, "the value of 's' is not a list")
+	})
+}
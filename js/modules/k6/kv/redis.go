@@ -0,0 +1,363 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/pkg/errors"
+)
+
+// Defaults for the connections to Redis: how many of them the VUs share, and how long a command
+// may take, including connecting, before it's given up on.
+const (
+	redisMaxConns = 8
+	redisTimeout  = 10 * time.Second
+)
+
+// redisBackend keeps the data in Redis, speaking just enough of its protocol (RESP) for the
+// handful of commands a store needs. The VUs share a small pool of connections, so that one
+// stalled command doesn't hold up all of them.
+type redisBackend struct {
+	addr     string
+	password string
+	db       int
+	prefix   string
+	timeout  time.Duration
+
+	// A slot in conns is taken for every connection in use or idle; idle ones wait in idle.
+	conns chan struct{}
+	idle  chan *redisConn
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// A redisConn is a connection to Redis.
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// A redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func newRedisBackend(rawurl, prefix string) (*redisBackend, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, errors.Errorf("unsupported Redis URL scheme '%s'", u.Scheme)
+	}
+
+	b := &redisBackend{
+		addr:    u.Host,
+		prefix:  prefix,
+		timeout: redisTimeout,
+		conns:   make(chan struct{}, redisMaxConns),
+		idle:    make(chan *redisConn, redisMaxConns),
+	}
+	if u.Port() == "" {
+		b.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		b.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if b.db, err = strconv.Atoi(db); err != nil {
+			return nil, errors.Errorf("invalid Redis database '%s'", db)
+		}
+	}
+	return b, nil
+}
+
+// dialer returns a dialer for the connections to the server, which applies the hosts,
+// blacklistIPs, allowedHosts and blockedHosts options of the test, like the VUs' own dialers.
+// The connections are shared by all VUs, so they aren't made with the dialer of the VU that
+// happens to need one first.
+func dialer(state *common.State) *netext.Dialer {
+	d := netext.NewDialer(net.Dialer{})
+	d.Blacklist = state.Options.BlacklistIPs
+	d.Hosts = state.Options.Hosts
+	d.AllowedHosts = state.Options.AllowedHosts
+	d.BlockedHosts = state.Options.BlockedHosts
+	return d
+}
+
+func (b *redisBackend) connect(ctx context.Context) (*redisConn, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, ErrKVInInitContext
+	}
+	conn, err := dialer(state).DialContext(ctx, "tcp", b.addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+
+	if b.password != "" {
+		if _, err := c.roundTrip(ctx, "AUTH", b.password); err != nil {
+			_ = c.conn.Close()
+			return nil, err
+		}
+	}
+	if b.db != 0 {
+		if _, err := c.roundTrip(ctx, "SELECT", strconv.Itoa(b.db)); err != nil {
+			_ = c.conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Close closes the idle connections, and the ones in use once they're done with.
+func (b *redisBackend) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for {
+		select {
+		case c := <-b.idle:
+			_ = c.conn.Close()
+			<-b.conns
+		default:
+			return
+		}
+	}
+}
+
+// get returns an idle connection, or a new one if there's none and the pool isn't full, waiting
+// for one to be put back otherwise.
+func (b *redisBackend) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-b.idle:
+		return c, nil
+	default:
+	}
+	select {
+	case c := <-b.idle:
+		return c, nil
+	case b.conns <- struct{}{}:
+		c, err := b.connect(ctx)
+		if err != nil {
+			<-b.conns
+		}
+		return c, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// put puts a connection back in the pool, or closes it if it's broken or the pool is closed.
+func (b *redisBackend) put(c *redisConn, broken bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if broken || b.closed {
+		_ = c.conn.Close()
+		<-b.conns
+		return
+	}
+	b.idle <- c
+}
+
+// do runs a command, connecting first if needed. Error replies are returned as errors. It gives up
+// when the timeout expires or the context is done, eg. because the test is over.
+func (b *redisBackend) do(ctx context.Context, args ...string) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+
+	c, err := b.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.roundTrip(ctx, args...)
+	// After any other error, the connection is in an unknown state.
+	_, ok := err.(redisError)
+	b.put(c, err != nil && !ok)
+	return reply, err
+}
+
+// roundTrip sends a command and reads its reply, interrupting both when the context is done.
+func (c *redisConn) roundTrip(ctx context.Context, args ...string) (interface{}, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := c.conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	var cmd bytes.Buffer
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := cmd.WriteTo(c.conn); err != nil {
+		return nil, c.interrupted(ctx, err)
+	}
+	reply, err := readReply(c.r)
+	if err != nil {
+		return nil, c.interrupted(ctx, err)
+	}
+	return reply, nil
+}
+
+// interrupted returns the context's error if the command failed because it's done.
+func (c *redisConn) interrupted(ctx context.Context, err error) error {
+	if _, ok := err.(redisError); ok {
+		return err
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return errors.Wrap(ctxErr, "redis")
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		// The connection's deadline is the context's, which can pass before it's marked done.
+		return errors.Wrap(context.DeadlineExceeded, "redis")
+	}
+	return err
+}
+
+// readReply reads a RESP reply: a string, an int64, a []byte (nil for a null bulk string), a
+// []interface{} or a redisError.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return []byte(nil), err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return []interface{}(nil), err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, errors.Errorf("redis: invalid reply '%s'", line)
+	}
+}
+
+func (b *redisBackend) bulk(ctx context.Context, args ...string) ([]byte, bool, error) {
+	reply, err := b.do(ctx, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	data, _ := reply.([]byte)
+	return data, data != nil, nil
+}
+
+func (b *redisBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return b.bulk(ctx, "GET", b.prefix+key)
+}
+
+func (b *redisBackend) Set(ctx context.Context, key string, value []byte) error {
+	_, err := b.do(ctx, "SET", b.prefix+key, string(value))
+	return err
+}
+
+func (b *redisBackend) Delete(ctx context.Context, key string) (bool, error) {
+	reply, err := b.do(ctx, "DEL", b.prefix+key)
+	n, _ := reply.(int64)
+	return n > 0, err
+}
+
+func (b *redisBackend) Incr(ctx context.Context, key string, by int64) (int64, error) {
+	reply, err := b.do(ctx, "INCRBY", b.prefix+key, strconv.FormatInt(by, 10))
+	n, _ := reply.(int64)
+	return n, err
+}
+
+func (b *redisBackend) Push(ctx context.Context, key string, value []byte) error {
+	_, err := b.do(ctx, "RPUSH", b.prefix+key, string(value))
+	return err
+}
+
+func (b *redisBackend) Pop(ctx context.Context, key string) ([]byte, bool, error) {
+	return b.bulk(ctx, "LPOP", b.prefix+key)
+}
+
+// globEscaper escapes the characters that are special in a KEYS pattern.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+func (b *redisBackend) Keys(ctx context.Context, prefix string) ([]string, error) {
+	reply, err := b.do(ctx, "KEYS", globEscaper.Replace(b.prefix+prefix)+"*")
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]interface{})
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		if key, ok := item.([]byte); ok {
+			keys = append(keys, strings.TrimPrefix(string(key), b.prefix))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
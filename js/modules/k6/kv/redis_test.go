@@ -0,0 +1,271 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package kv
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis serves the commands a redisBackend uses from a memoryBackend. It never replies to
+// a GET of the stall key.
+type fakeRedis struct {
+	listener net.Listener
+	data     *memoryBackend
+	password string
+	stall    string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeRedis{listener: l, data: newMemoryBackend(), password: password}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		req, err := readReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range req.([]interface{}) {
+			args = append(args, string(arg.([]byte)))
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args[0])
+		s.mu.Unlock()
+		if args[0] == "GET" && args[1] == s.stall {
+			continue
+		}
+
+		if !authed && args[0] != "AUTH" {
+			_, _ = io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		_, _ = io.WriteString(conn, s.reply(args, &authed))
+	}
+}
+
+func bulk(data []byte, ok bool, _ error) string {
+	if !ok {
+		return "$-1\r\n"
+	}
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(data), data)
+}
+
+func (s *fakeRedis) reply(args []string, authed *bool) string {
+	ctx := context.Background()
+	switch args[0] {
+	case "AUTH":
+		if args[1] != s.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		*authed = true
+		return "+OK\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		return bulk(s.data.Get(ctx, args[1]))
+	case "SET":
+		_ = s.data.Set(ctx, args[1], []byte(args[2]))
+		return "+OK\r\n"
+	case "DEL":
+		ok, _ := s.data.Delete(ctx, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "INCRBY":
+		by, _ := strconv.ParseInt(args[2], 10, 64)
+		n, err := s.data.Incr(ctx, args[1], by)
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "RPUSH":
+		_ = s.data.Push(ctx, args[1], []byte(args[2]))
+		return ":1\r\n"
+	case "LPOP":
+		return bulk(s.data.Pop(ctx, args[1]))
+	case "KEYS":
+		prefix := strings.Replace(strings.TrimSuffix(args[1], "*"), `\`, "", -1)
+		keys, _ := s.data.Keys(ctx, prefix)
+		reply := fmt.Sprintf("*%d\r\n", len(keys))
+		for _, k := range keys {
+			reply += bulk([]byte(k), true, nil)
+		}
+		return reply
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	defer func() { _ = server.listener.Close() }()
+
+	testStore(t, New(), fmt.Sprintf(
+		`let store = new kv.Store("tokens", { redis: "redis://:secret@%s/2" });`, server.listener.Addr()))
+
+	keys, _ := server.data.Keys(context.Background(), "")
+	for _, k := range keys {
+		assert.True(t, strings.HasPrefix(k, "tokens:"), k)
+	}
+	assert.Equal(t, []string{"AUTH", "SELECT"}, server.commands[:2])
+
+	t.Run("Errors", func(t *testing.T) {
+		ctx := common.WithState(context.Background(), &common.State{})
+		b, err := newRedisBackend("redis://"+server.listener.Addr().String(), "")
+		require.NoError(t, err)
+		_, _, err = b.Get(ctx, "a")
+		assert.EqualError(t, err, "redis: NOAUTH Authentication required.")
+
+		b, err = newRedisBackend("redis://:wrong@"+server.listener.Addr().String(), "")
+		require.NoError(t, err)
+		_, _, err = b.Get(ctx, "a")
+		assert.EqualError(t, err, "redis: WRONGPASS invalid password")
+
+		_, err = newRedisBackend("redis://localhost/db", "")
+		assert.EqualError(t, err, "invalid Redis database 'db'")
+		b, err = newRedisBackend("redis://localhost", "")
+		require.NoError(t, err)
+		assert.Equal(t, "localhost:6379", b.addr)
+	})
+}
+
+func TestRedisStoreHosts(t *testing.T) {
+	server := newFakeRedis(t, "")
+	defer func() { _ = server.listener.Close() }()
+
+	_, port, err := net.SplitHostPort(server.listener.Addr().String())
+	require.NoError(t, err)
+	testdata := map[string]struct {
+		options lib.Options
+		err     string
+	}{
+		"blocked": {
+			lib.Options{BlockedHosts: []string{"127.0.0.0/8"}},
+			"the host '127.0.0.1' is in the blocked hosts",
+		},
+		"not allowed": {
+			lib.Options{AllowedHosts: []string{"*.example.com"}},
+			"the host 'localhost' isn't in the allowed hosts",
+		},
+		"allowed": {lib.Options{AllowedHosts: []string{"localhost"}}, ""},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			host := "127.0.0.1"
+			if name != "blocked" {
+				host = "localhost"
+			}
+			rts, start := newVUs(t, New(), 1, fmt.Sprintf(
+				`let store = new kv.Store("hosts", { redis: "redis://%s" });`, net.JoinHostPort(host, port)))
+			start(&common.State{Options: data.options})
+
+			_, err := common.RunString(rts[0], `store.set("a", 1)`)
+			if data.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), data.err)
+			}
+		})
+	}
+}
+
+func TestRedisStoreStalled(t *testing.T) {
+	server := newFakeRedis(t, "")
+	server.stall = "stalled:slow"
+	defer func() { _ = server.listener.Close() }()
+
+	b, err := newRedisBackend("redis://"+server.listener.Addr().String(), "stalled:")
+	require.NoError(t, err)
+	ctx := common.WithState(context.Background(), &common.State{})
+
+	t.Run("Timeout", func(t *testing.T) {
+		b.timeout = 100 * time.Millisecond
+		defer func() { b.timeout = redisTimeout }()
+		_, _, err := b.Get(ctx, "slow")
+		assert.EqualError(t, err, "redis: context deadline exceeded")
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(100*time.Millisecond, cancel)
+		_, _, err := b.Get(ctx, "slow")
+		assert.EqualError(t, err, "redis: context canceled")
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		stalled := make(chan error)
+		go func() {
+			_, _, err := b.Get(ctx, "slow")
+			stalled <- err
+		}()
+
+		// The other commands get their own connections in the meantime.
+		for i := 0; i < 2*redisMaxConns; i++ {
+			require.NoError(t, b.Set(ctx, "fast", []byte(`1`)))
+		}
+		select {
+		case err := <-stalled:
+			t.Fatalf("the stalled command returned: %v", err)
+		default:
+		}
+		cancel()
+		assert.EqualError(t, <-stalled, "redis: context canceled")
+	})
+
+	b.Close()
+	assert.Len(t, b.idle, 0)
+	assert.Len(t, b.conns, 0)
+}
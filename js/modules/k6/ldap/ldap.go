@@ -0,0 +1,408 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	goldap "gopkg.in/ldap.v2"
+)
+
+// ErrLDAPInInitContext is returned when connecting to a directory from the init context
+var ErrLDAPInInitContext = common.NewInitContextError("using LDAP in the init context is not supported")
+
+// Values for the scope param of search().
+const (
+	ScopeBase = "base"
+	ScopeOne  = "one"
+	ScopeSub  = "sub"
+)
+
+type LDAP struct{}
+
+// An Entry is an entry returned by search(): its DN, and the values of its attributes by name.
+type Entry struct {
+	DN         string                 `js:"dn"`
+	Attributes map[string]interface{} `js:"attributes"`
+}
+
+type connectParams struct {
+	startTLS bool
+	timeout  time.Duration
+	tags     map[string]string
+}
+
+// Conn is a connection to a directory server opened by ldap.connect(); it's only valid inside
+// the callback.
+type Conn struct {
+	ctx   context.Context
+	rt    *goja.Runtime
+	state *common.State
+	tags  map[string]string
+	conn  *goldap.Conn
+}
+
+func New() *LDAP {
+	return &LDAP{}
+}
+
+// Connect connects to the directory server at the given ldap:// or ldaps:// URL, passes the
+// connection to the given function, and closes it when the function returns. It returns whatever
+// the function returns.
+func (*LDAP) Connect(ctx context.Context, rawurl string, args ...goja.Value) (goja.Value, error) {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, ErrLDAPInInitContext
+	}
+
+	// The params argument is optional
+	var callableV, paramsV goja.Value
+	switch len(args) {
+	case 2:
+		paramsV = args[0]
+		callableV = args[1]
+	case 1:
+		paramsV = goja.Undefined()
+		callableV = args[0]
+	default:
+		return nil, errors.New("invalid number of arguments to ldap.connect")
+	}
+
+	fn, isFunc := goja.AssertFunction(callableV)
+	if !isFunc {
+		return nil, errors.New("last argument to ldap.connect must be a function")
+	}
+
+	params := connectParams{tags: state.Options.RunTags.CloneTags()}
+	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		paramsObj := paramsV.ToObject(rt)
+		for _, k := range paramsObj.Keys() {
+			switch k {
+			case "startTLS":
+				params.startTLS = paramsObj.Get(k).ToBoolean()
+			case "timeout":
+				params.timeout = time.Duration(paramsObj.Get(k).ToFloat() * float64(time.Millisecond))
+			case "tags":
+				tagsV := paramsObj.Get(k)
+				if goja.IsUndefined(tagsV) || goja.IsNull(tagsV) {
+					continue
+				}
+				tagObj := tagsV.ToObject(rt)
+				if tagObj == nil {
+					continue
+				}
+				for _, key := range tagObj.Keys() {
+					params.tags[key] = tagObj.Get(key).String()
+				}
+			}
+		}
+	}
+	if state.Options.SystemTags["group"] {
+		params.tags["group"] = state.Group.Path
+	}
+
+	start := time.Now()
+	c, netConn, err := dial(ctx, rawurl, &params)
+	if err != nil {
+		return nil, err
+	}
+	connectionEnd := time.Now()
+
+	// Unblock any pending requests if the VU is interrupted.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = netConn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	ret, fnErr := fn(goja.Undefined(), rt.ToValue(c))
+	c.conn.Close()
+
+	end := time.Now()
+	sampleTags := stats.IntoSampleTags(&params.tags)
+	stats.PushIfNotCancelled(ctx, state.Samples, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Metric: metrics.LDAPSessions, Time: start, Tags: sampleTags, Value: 1},
+			{Metric: metrics.LDAPConnecting, Time: start, Tags: sampleTags, Value: stats.D(connectionEnd.Sub(start))},
+			{Metric: metrics.LDAPSessionDuration, Time: start, Tags: sampleTags, Value: stats.D(end.Sub(start))},
+		},
+		Tags: sampleTags,
+		Time: start,
+	})
+
+	if fnErr != nil {
+		return nil, fnErr
+	}
+	return ret, nil
+}
+
+// dial connects to the server, and returns the LDAP connection and the connection it's made over.
+func dial(ctx context.Context, rawurl string, params *connectParams) (*Conn, net.Conn, error) {
+	state := common.GetState(ctx)
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, nil, err
+	}
+	var port string
+	switch u.Scheme {
+	case "ldap":
+		port = "389"
+	case "ldaps":
+		port = "636"
+		if params.startTLS {
+			return nil, nil, errors.New("StartTLS can't be used with ldaps:// URLs, which already use TLS")
+		}
+	default:
+		return nil, nil, errors.Errorf("invalid LDAP URL '%s', use: 'ldap://host[:port]' or 'ldaps://host[:port]'", rawurl)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	host := u.Hostname()
+
+	var tlsConfig *tls.Config
+	if state.TLSConfig != nil {
+		tlsConfig = state.TLSConfig.Clone()
+		tlsConfig.NextProtos = nil
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+
+	dialCtx := ctx
+	if params.timeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, params.timeout)
+		defer cancel()
+	}
+	netConn, err := state.Dialer.DialContext(dialCtx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn := netConn
+	if u.Scheme == "ldaps" {
+		tlsConn := tls.Client(netConn, tlsConfig)
+		if params.timeout > 0 {
+			_ = netConn.SetDeadline(time.Now().Add(params.timeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			_ = netConn.Close()
+			return nil, nil, err
+		}
+		_ = netConn.SetDeadline(time.Time{})
+		conn = tlsConn
+	}
+
+	c := &Conn{
+		ctx:   ctx,
+		rt:    common.GetRuntime(ctx),
+		state: state,
+		tags:  params.tags,
+		conn:  goldap.NewConn(conn, u.Scheme == "ldaps"),
+	}
+	c.conn.Start()
+	c.conn.SetTimeout(params.timeout)
+
+	if params.startTLS {
+		if err := c.conn.StartTLS(tlsConfig); err != nil {
+			c.conn.Close()
+			return nil, nil, err
+		}
+	}
+	return c, netConn, nil
+}
+
+// Bind authenticates as the given DN with the password. It throws if the server refuses them.
+func (c *Conn) Bind(dn, password string) error {
+	return c.request("bind", func() error {
+		return c.conn.Bind(dn, password)
+	})
+}
+
+// Search returns the entries under the base DN that match the filter. The scope is "sub", the
+// whole subtree, unless the params say otherwise.
+func (c *Conn) Search(baseDN, filter string, paramsV goja.Value) ([]interface{}, error) {
+	scope := goldap.ScopeWholeSubtree
+	var attributes []string
+	var sizeLimit, pageSize int
+	if paramsV != nil && !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		paramsObj := paramsV.ToObject(c.rt)
+		for _, k := range paramsObj.Keys() {
+			v := paramsObj.Get(k)
+			switch k {
+			case "scope":
+				switch v.String() {
+				case ScopeBase:
+					scope = goldap.ScopeBaseObject
+				case ScopeOne:
+					scope = goldap.ScopeSingleLevel
+				case ScopeSub:
+					scope = goldap.ScopeWholeSubtree
+				default:
+					return nil, errors.Errorf("invalid scope '%s', use: '%s', '%s' or '%s'",
+						v.String(), ScopeBase, ScopeOne, ScopeSub)
+				}
+			case "attributes":
+				if err := c.rt.ExportTo(v, &attributes); err != nil {
+					return nil, errors.Wrap(err, "invalid attributes")
+				}
+			case "sizeLimit":
+				sizeLimit = int(v.ToInteger())
+			case "pageSize":
+				pageSize = int(v.ToInteger())
+			}
+		}
+	}
+
+	req := goldap.NewSearchRequest(baseDN, scope, goldap.NeverDerefAliases, sizeLimit, 0, false, filter, attributes, nil)
+	var result *goldap.SearchResult
+	err := c.request("search", func() (err error) {
+		if pageSize > 0 {
+			result, err = c.conn.SearchWithPaging(req, uint32(pageSize))
+		} else {
+			result, err = c.conn.Search(req)
+		}
+		// Hitting the size limit the script asked for isn't an error; the entries up to it are
+		// returned.
+		if result != nil && goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]interface{}, len(result.Entries))
+	for i, e := range result.Entries {
+		entry := &Entry{DN: e.DN, Attributes: make(map[string]interface{}, len(e.Attributes))}
+		for _, attr := range e.Attributes {
+			values := make([]interface{}, len(attr.Values))
+			for j, v := range attr.Values {
+				values[j] = v
+			}
+			entry.Attributes[attr.Name] = values
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// Modify changes the attributes of the entry with the given DN. The changes are an object with
+// add, delete and replace objects, which map attribute names to a value or an array of values;
+// deleting an empty array of values deletes the whole attribute.
+func (c *Conn) Modify(dn string, changesV goja.Value) error {
+	if changesV == nil || goja.IsUndefined(changesV) || goja.IsNull(changesV) {
+		return errors.New("ldap modify needs changes")
+	}
+	req := goldap.NewModifyRequest(dn)
+	changesObj := changesV.ToObject(c.rt)
+	for _, k := range changesObj.Keys() {
+		var change func(string, []string)
+		switch k {
+		case "add":
+			change = req.Add
+		case "delete":
+			change = req.Delete
+		case "replace":
+			change = req.Replace
+		default:
+			return errors.Errorf("invalid change '%s', use: 'add', 'delete' or 'replace'", k)
+		}
+		attrsV := changesObj.Get(k)
+		if goja.IsUndefined(attrsV) || goja.IsNull(attrsV) {
+			continue
+		}
+		attrsObj := attrsV.ToObject(c.rt)
+		names := attrsObj.Keys()
+		sort.Strings(names)
+		for _, name := range names {
+			values, err := c.toStrings(attrsObj.Get(name))
+			if err != nil {
+				return errors.Wrapf(err, "invalid values for '%s'", name)
+			}
+			change(name, values)
+		}
+	}
+	return c.request("modify", func() error {
+		return c.conn.Modify(req)
+	})
+}
+
+// request times an operation, and emits its metrics.
+func (c *Conn) request(operation string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	end := time.Now()
+
+	tags := make(map[string]string, len(c.tags)+2)
+	for k, v := range c.tags {
+		tags[k] = v
+	}
+	tags["operation"] = operation
+	if c.state.Options.SystemTags["error"] && err != nil {
+		tags["error"] = err.Error()
+	}
+	sampleTags := stats.IntoSampleTags(&tags)
+	stats.PushIfNotCancelled(c.ctx, c.state.Samples, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Metric: metrics.LDAPRequests, Time: start, Tags: sampleTags, Value: 1},
+			{Metric: metrics.LDAPRequestDuration, Time: start, Tags: sampleTags, Value: stats.D(end.Sub(start))},
+		},
+		Tags: sampleTags,
+		Time: start,
+	})
+	return err
+}
+
+// toStrings returns a value, or an array of values, as strings.
+func (c *Conn) toStrings(v goja.Value) ([]string, error) {
+	if goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil, nil
+	}
+	// Allow a single value as a shorthand for a list of one.
+	if s, ok := v.Export().(string); ok {
+		return []string{s}, nil
+	}
+	var values []string
+	if err := c.rt.ExportTo(v, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,533 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/testutils"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ber "gopkg.in/asn1-ber.v1"
+	goldap "gopkg.in/ldap.v2"
+)
+
+// Protocol operations, as application tags.
+const (
+	opBindRequest      = 0
+	opBindResponse     = 1
+	opUnbindRequest    = 2
+	opSearchRequest    = 3
+	opSearchEntry      = 4
+	opSearchDone       = 5
+	opModifyRequest    = 6
+	opModifyResponse   = 7
+	opExtendedRequest  = 23
+	opExtendedResponse = 24
+)
+
+// A server is just enough of an LDAP server to test against. It only accepts the password
+// "secret", and its filters only support &, equality and presence.
+type server struct {
+	tlsConfig *tls.Config
+
+	mu      sync.Mutex
+	entries map[string]map[string][]string
+}
+
+func newServer(tlsConfig *tls.Config) *server {
+	return &server{
+		tlsConfig: tlsConfig,
+		entries: map[string]map[string][]string{
+			"dc=example,dc=com": {
+				"objectClass": {"domain"},
+				"dc":          {"example"},
+			},
+			"ou=people,dc=example,dc=com": {
+				"objectClass": {"organizationalUnit"},
+				"ou":          {"people"},
+			},
+			"uid=alice,ou=people,dc=example,dc=com": {
+				"objectClass": {"person"},
+				"uid":         {"alice"},
+				"cn":          {"Alice"},
+				"mail":        {"alice@example.com"},
+			},
+			"uid=bob,ou=people,dc=example,dc=com": {
+				"objectClass": {"person"},
+				"uid":         {"bob"},
+				"cn":          {"Bob"},
+				"mail":        {"bob@example.com"},
+			},
+		},
+	}
+}
+
+func (s *server) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id, _ := packet.Children[0].Value.(int64)
+		req := packet.Children[1]
+		var responses [][]byte
+		switch req.Tag {
+		case opBindRequest:
+			code := goldap.LDAPResultSuccess
+			if req.Children[2].Data.String() != "secret" {
+				code = goldap.LDAPResultInvalidCredentials
+			}
+			responses = append(responses, message(id, result(opBindResponse, code, ""), nil))
+		case opUnbindRequest:
+			return
+		case opSearchRequest:
+			responses = s.search(id, req, packet)
+		case opModifyRequest:
+			responses = append(responses, message(id, s.modify(req), nil))
+		case opExtendedRequest:
+			if _, err := conn.Write(message(id, result(opExtendedResponse, goldap.LDAPResultSuccess, ""), nil)); err != nil {
+				return
+			}
+			// StartTLS is the only extended operation used.
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			continue
+		default:
+			return
+		}
+		for _, response := range responses {
+			if _, err := conn.Write(response); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// search returns the messages that answer a search request: an entry per match, and the result.
+func (s *server) search(id int64, req, packet *ber.Packet) [][]byte {
+	base := req.Children[0].Value.(string)
+	scope := req.Children[1].Value.(int64)
+	sizeLimit := int(req.Children[3].Value.(int64))
+	filter := req.Children[6]
+	var attributes []string
+	for _, attr := range req.Children[7].Children {
+		attributes = append(attributes, attr.Value.(string))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[base]; !ok {
+		return [][]byte{message(id, result(opSearchDone, goldap.LDAPResultNoSuchObject, ""), nil)}
+	}
+	var dns []string
+	for dn, attrs := range s.entries {
+		var inScope bool
+		switch scope {
+		case goldap.ScopeBaseObject:
+			inScope = dn == base
+		case goldap.ScopeSingleLevel:
+			inScope = strings.HasSuffix(dn, ","+base) && !strings.Contains(strings.TrimSuffix(dn, ","+base), ",")
+		default:
+			inScope = dn == base || strings.HasSuffix(dn, ","+base)
+		}
+		if inScope && matches(filter, attrs) {
+			dns = append(dns, dn)
+		}
+	}
+	sort.Strings(dns)
+
+	// Paged results are returned from the offset in the cookie.
+	var paging *goldap.ControlPaging
+	if len(packet.Children) > 2 {
+		for _, c := range packet.Children[2].Children {
+			if control, ok := goldap.DecodeControl(c).(*goldap.ControlPaging); ok {
+				paging = control
+			}
+		}
+	}
+	offset := 0
+	if paging != nil && len(paging.Cookie) > 0 {
+		offset, _ = strconv.Atoi(string(paging.Cookie))
+	}
+	dns = dns[offset:]
+
+	code := goldap.LDAPResultSuccess
+	if sizeLimit > 0 && len(dns) > sizeLimit {
+		dns = dns[:sizeLimit]
+		code = goldap.LDAPResultSizeLimitExceeded
+	}
+	var cookie string
+	if paging != nil && paging.PagingSize > 0 && len(dns) > int(paging.PagingSize) {
+		dns = dns[:paging.PagingSize]
+		cookie = strconv.Itoa(offset + len(dns))
+	}
+
+	var responses [][]byte
+	for _, dn := range dns {
+		entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, opSearchEntry, nil, "Search Result Entry")
+		entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "DN"))
+		attrs := ber.NewSequence("Attributes")
+		names := make([]string, 0, len(s.entries[dn]))
+		for name := range s.entries[dn] {
+			if len(attributes) == 0 || contains(attributes, name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			attr := ber.NewSequence("Attribute")
+			attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+			values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+			for _, v := range s.entries[dn][name] {
+				values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+			}
+			attr.AppendChild(values)
+			attrs.AppendChild(attr)
+		}
+		entry.AppendChild(attrs)
+		responses = append(responses, message(id, entry, nil))
+	}
+
+	var controls []goldap.Control
+	if paging != nil {
+		control := goldap.NewControlPaging(paging.PagingSize)
+		control.SetCookie([]byte(cookie))
+		controls = append(controls, control)
+	}
+	return append(responses, message(id, result(opSearchDone, code, ""), controls))
+}
+
+func (s *server) modify(req *ber.Packet) *ber.Packet {
+	dn := req.Children[0].Value.(string)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attrs, ok := s.entries[dn]
+	if !ok {
+		return result(opModifyResponse, goldap.LDAPResultNoSuchObject, "no such entry: "+dn)
+	}
+	for _, change := range req.Children[1].Children {
+		name := change.Children[1].Children[0].Value.(string)
+		var values []string
+		for _, v := range change.Children[1].Children[1].Children {
+			values = append(values, v.Value.(string))
+		}
+		switch change.Children[0].Value.(int64) {
+		case goldap.AddAttribute:
+			attrs[name] = append(attrs[name], values...)
+		case goldap.DeleteAttribute:
+			if len(values) == 0 {
+				delete(attrs, name)
+				continue
+			}
+			var kept []string
+			for _, v := range attrs[name] {
+				if !contains(values, v) {
+					kept = append(kept, v)
+				}
+			}
+			attrs[name] = kept
+		case goldap.ReplaceAttribute:
+			attrs[name] = values
+		}
+	}
+	return result(opModifyResponse, goldap.LDAPResultSuccess, "")
+}
+
+// matches returns whether the entry's attributes match the filter.
+func matches(filter *ber.Packet, attrs map[string][]string) bool {
+	switch filter.Tag {
+	case goldap.FilterAnd:
+		for _, f := range filter.Children {
+			if !matches(f, attrs) {
+				return false
+			}
+		}
+		return true
+	case goldap.FilterEqualityMatch:
+		return contains(attrs[filter.Children[0].Value.(string)], filter.Children[1].Value.(string))
+	case goldap.FilterPresent:
+		return len(attrs[filter.Data.String()]) > 0
+	default:
+		return false
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// result returns an LDAPResult response for the operation.
+func result(op ber.Tag, code int, diagnostic string) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, op, nil, "Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, diagnostic, "Diagnostic Message"))
+	return p
+}
+
+// message encodes a response, and its controls, as an LDAPMessage.
+func message(id int64, response *ber.Packet, controls []goldap.Control) []byte {
+	p := ber.NewSequence("LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	p.AppendChild(response)
+	if len(controls) > 0 {
+		c := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		for _, control := range controls {
+			c.AppendChild(control.Encode())
+		}
+		p.AppendChild(c)
+	}
+	return p.Bytes()
+}
+
+func TestConnect(t *testing.T) {
+	certs := testutils.NewTestCerts(t)
+	serverTLS := &tls.Config{Certificates: []tls.Certificate{certs.Server}}
+	s := newServer(serverTLS)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+	go s.serve(l)
+
+	tlsL, err := tls.Listen("tcp", "127.0.0.1:0", serverTLS)
+	require.NoError(t, err)
+	defer func() { _ = tlsL.Close() }()
+	go s.serve(tlsL)
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &common.State{
+		Group:     root,
+		Dialer:    netext.NewDialer(net.Dialer{Timeout: 10 * time.Second}),
+		TLSConfig: &tls.Config{RootCAs: certs.CAPool()},
+		Options: lib.Options{
+			SystemTags: lib.GetTagSet("group"),
+		},
+		Samples: samples,
+	}
+
+	ctx := context.Background()
+	ctx = common.WithState(ctx, state)
+	ctx = common.WithRuntime(ctx, rt)
+
+	rt.Set("ldap", common.Bind(rt, New(), &ctx))
+	rt.Set("url", "ldap://"+l.Addr().String())
+	rt.Set("tlsURL", "ldaps://"+tlsL.Addr().String())
+
+	t.Run("Bind", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		ldap.connect(url, function(conn) {
+			conn.bind("cn=admin,dc=example,dc=com", "secret");
+		});`)
+		assert.NoError(t, err)
+
+		_, err = common.RunString(rt, `
+		ldap.connect(url, function(conn) {
+			conn.bind("cn=admin,dc=example,dc=com", "wrong");
+		});`)
+		assert.EqualError(t, err, `GoError: LDAP Result Code 49 "Invalid Credentials": `)
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		ldap.connect(url, { timeout: 2000 }, function(conn) {
+			let entries = conn.search("dc=example,dc=com", "(objectClass=person)");
+			if (entries.length !== 2 || entries[0].dn !== "uid=alice,ou=people,dc=example,dc=com") {
+				throw new Error("Unexpected entries: " + JSON.stringify(entries));
+			}
+			if (entries[1].attributes.mail[0] !== "bob@example.com" || entries[1].attributes.cn.length !== 1) {
+				throw new Error("Unexpected entry: " + JSON.stringify(entries[1]));
+			}
+
+			entries = conn.search("dc=example,dc=com", "(&(objectClass=person)(uid=alice))", { attributes: ["cn"] });
+			if (entries.length !== 1 || entries[0].attributes.cn[0] !== "Alice" || entries[0].attributes.mail) {
+				throw new Error("Unexpected entries: " + JSON.stringify(entries));
+			}
+
+			entries = conn.search("dc=example,dc=com", "(objectClass=*)", { scope: "one" });
+			if (entries.length !== 1 || entries[0].dn !== "ou=people,dc=example,dc=com") {
+				throw new Error("Unexpected entries: " + JSON.stringify(entries));
+			}
+			entries = conn.search("dc=example,dc=com", "(objectClass=*)", { scope: "base" });
+			if (entries.length !== 1 || entries[0].dn !== "dc=example,dc=com") {
+				throw new Error("Unexpected entries: " + JSON.stringify(entries));
+			}
+
+			entries = conn.search("dc=example,dc=com", "(objectClass=*)", { sizeLimit: 3 });
+			if (entries.length !== 3) {
+				throw new Error("Unexpected entries: " + JSON.stringify(entries));
+			}
+			entries = conn.search("dc=example,dc=com", "(objectClass=*)", { pageSize: 1 });
+			if (entries.length !== 4) {
+				throw new Error("Unexpected entries: " + JSON.stringify(entries));
+			}
+		});`)
+		assert.NoError(t, err)
+
+		_, err = common.RunString(rt, `
+		ldap.connect(url, function(conn) {
+			conn.search("dc=example,dc=org", "(objectClass=*)");
+		});`)
+		assert.EqualError(t, err, `GoError: LDAP Result Code 32 "No Such Object": `)
+	})
+
+	t.Run("Modify", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		ldap.connect(url, function(conn) {
+			conn.bind("cn=admin,dc=example,dc=com", "secret");
+			conn.modify("uid=bob,ou=people,dc=example,dc=com", {
+				add: { telephoneNumber: ["555-0100", "555-0101"] },
+				replace: { cn: "Robert" },
+				delete: { mail: [] },
+			});
+			conn.modify("uid=bob,ou=people,dc=example,dc=com", { delete: { telephoneNumber: "555-0100" } });
+
+			let attrs = conn.search("uid=bob,ou=people,dc=example,dc=com", "(objectClass=*)", { scope: "base" })[0].attributes;
+			if (attrs.cn[0] !== "Robert" || attrs.mail || attrs.telephoneNumber.length !== 1 || attrs.telephoneNumber[0] !== "555-0101") {
+				throw new Error("Unexpected attributes: " + JSON.stringify(attrs));
+			}
+		});`)
+		assert.NoError(t, err)
+
+		_, err = common.RunString(rt, `
+		ldap.connect(url, function(conn) {
+			conn.modify("uid=carol,ou=people,dc=example,dc=com", { replace: { cn: "Carol" } });
+		});`)
+		assert.EqualError(t, err, `GoError: LDAP Result Code 32 "No Such Object": no such entry: uid=carol,ou=people,dc=example,dc=com`)
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		ldap.connect(tlsURL, { timeout: 2000 }, function(conn) {
+			conn.bind("cn=admin,dc=example,dc=com", "secret");
+		});
+		ldap.connect(url, { startTLS: true, timeout: 2000 }, function(conn) {
+			conn.bind("cn=admin,dc=example,dc=com", "secret");
+			if (conn.search("dc=example,dc=com", "(uid=alice)").length !== 1) {
+				throw new Error("Alice is missing");
+			}
+		});`)
+		assert.NoError(t, err)
+
+		_, err = common.RunString(rt, `ldap.connect(tlsURL, { startTLS: true }, function() {});`)
+		assert.EqualError(t, err, "GoError: StartTLS can't be used with ldaps:// URLs, which already use TLS")
+
+		// Without the CA, the server's certificate isn't trusted.
+		state.TLSConfig = nil
+		defer func() { state.TLSConfig = &tls.Config{RootCAs: certs.CAPool()} }()
+		_, err = common.RunString(rt, `ldap.connect(tlsURL, { timeout: 2000 }, function() {});`)
+		assert.Contains(t, err.Error(), "certificate")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, `ldap.connect("http://localhost", function() {});`)
+		assert.EqualError(t, err, "GoError: invalid LDAP URL 'http://localhost', use: 'ldap://host[:port]' or 'ldaps://host[:port]'")
+
+		_, err = common.RunString(rt, `
+		ldap.connect(url, function(conn) {
+			conn.search("dc=example,dc=com", "(objectClass=*)", { scope: "children" });
+		});`)
+		assert.EqualError(t, err, "GoError: invalid scope 'children', use: 'base', 'one' or 'sub'")
+
+		_, err = common.RunString(rt, `
+		ldap.connect(url, function(conn) {
+			conn.modify("dc=example,dc=com", { increment: { n: "1" } });
+		});`)
+		assert.EqualError(t, err, "GoError: invalid change 'increment', use: 'add', 'delete' or 'replace'")
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		for len(samples) > 0 {
+			<-samples
+		}
+		_, err := common.RunString(rt, `
+		ldap.connect(url, { tags: { directory: "test" } }, function(conn) {
+			conn.bind("cn=admin,dc=example,dc=com", "secret");
+			conn.search("dc=example,dc=com", "(uid=alice)");
+			conn.search("dc=example,dc=com", "(uid=bob)");
+		});`)
+		assert.NoError(t, err)
+
+		counts := map[string]float64{}
+		seen := map[string]bool{}
+		for len(samples) > 0 {
+			for _, sample := range (<-samples).GetSamples() {
+				seen[sample.Metric.Name] = true
+				name := sample.Metric.Name
+				if op, ok := sample.Tags.Get("operation"); ok {
+					name += ":" + op
+				}
+				if sample.Metric.Type == stats.Counter {
+					counts[name] += sample.Value
+				}
+				tag, _ := sample.Tags.Get("directory")
+				assert.Equal(t, "test", tag)
+			}
+		}
+		assert.Equal(t, map[string]float64{
+			metrics.LDAPSessions.Name:             1,
+			metrics.LDAPRequests.Name + ":bind":   1,
+			metrics.LDAPRequests.Name + ":search": 2,
+		}, counts)
+		for _, m := range []*stats.Metric{metrics.LDAPConnecting, metrics.LDAPSessionDuration, metrics.LDAPRequestDuration} {
+			assert.True(t, seen[m.Name], m.Name)
+		}
+	})
+}
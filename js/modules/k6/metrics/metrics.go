@@ -110,6 +110,10 @@ func (*Metrics) XTrend(ctx *context.Context, name string, isTime ...bool) (inter
 	return newMetric(ctx, name, stats.Trend, isTime)
 }
 
+func (*Metrics) XHistogram(ctx *context.Context, name string, isTime ...bool) (interface{}, error) {
+	return newMetric(ctx, name, stats.Histogram, isTime)
+}
+
 func (*Metrics) XRate(ctx *context.Context, name string, isTime ...bool) (interface{}, error) {
 	return newMetric(ctx, name, stats.Rate, isTime)
 }
@@ -36,10 +36,11 @@ import (
 func TestMetrics(t *testing.T) {
 	t.Parallel()
 	types := map[string]stats.MetricType{
-		"Counter": stats.Counter,
-		"Gauge":   stats.Gauge,
-		"Trend":   stats.Trend,
-		"Rate":    stats.Rate,
+		"Counter":   stats.Counter,
+		"Gauge":     stats.Gauge,
+		"Trend":     stats.Trend,
+		"Rate":      stats.Rate,
+		"Histogram": stats.Histogram,
 	}
 	values := map[string]struct {
 		JS    string
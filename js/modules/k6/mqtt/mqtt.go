@@ -0,0 +1,825 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// ErrMQTTInInitContext is returned when connecting to a broker from the init context
+var ErrMQTTInInitContext = common.NewInitContextError("using MQTT in the init context is not supported")
+
+// How often the broker expects to hear from the client if the script doesn't say.
+const defaultKeepAlive = 60 * time.Second
+
+type MQTT struct{}
+
+// A Message is a message received from the broker.
+type Message struct {
+	Topic   string `js:"topic"`
+	Payload string `js:"payload"`
+	QoS     int    `js:"qos"`
+	Retain  bool   `js:"retain"`
+}
+
+type connectParams struct {
+	clientID     string
+	username     string
+	password     string
+	keepAlive    time.Duration
+	cleanSession bool
+	version      byte
+	useTLS       bool
+	cert         string
+	key          string
+	ca           string
+	timeout      time.Duration
+	tags         map[string]string
+}
+
+// Client is a connection to a broker opened by mqtt.connect(); it's only valid inside the
+// callback.
+type Client struct {
+	ctx     context.Context
+	rt      *goja.Runtime
+	state   *common.State
+	tags    *stats.SampleTags
+	conn    net.Conn
+	version byte
+	timeout time.Duration
+
+	writeMu sync.Mutex
+
+	mu sync.Mutex
+	// Packets waiting for an acknowledgement, by their identifier.
+	lastID uint16
+	acks   map[uint16]chan packet
+	// Messages received, but not yet returned by receive().
+	messages []*Message
+	arrived  chan struct{}
+	// Why the connection was lost, if it wasn't closed by the script.
+	err     error
+	closing bool
+	// Closed when the connection is lost or closed.
+	done chan struct{}
+}
+
+func New() *MQTT {
+	return &MQTT{}
+}
+
+// Connect connects to the broker at addr ("host:port"), passes the client to the given
+// function, and disconnects when the function returns. It returns whatever the function returns.
+func (*MQTT) Connect(ctx context.Context, addr string, args ...goja.Value) (goja.Value, error) {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, ErrMQTTInInitContext
+	}
+
+	// The params argument is optional
+	var callableV, paramsV goja.Value
+	switch len(args) {
+	case 2:
+		paramsV = args[0]
+		callableV = args[1]
+	case 1:
+		paramsV = goja.Undefined()
+		callableV = args[0]
+	default:
+		return nil, errors.New("invalid number of arguments to mqtt.connect")
+	}
+
+	fn, isFunc := goja.AssertFunction(callableV)
+	if !isFunc {
+		return nil, errors.New("last argument to mqtt.connect must be a function")
+	}
+
+	params, err := parseConnectParams(rt, paramsV, state)
+	if err != nil {
+		return nil, err
+	}
+	if state.Options.SystemTags["group"] {
+		params.tags["group"] = state.Group.Path
+	}
+
+	start := time.Now()
+	c, err := dial(ctx, addr, params)
+	if err != nil {
+		return nil, err
+	}
+	connectionEnd := time.Now()
+
+	if state.Options.SystemTags["ip"] && c.conn.RemoteAddr() != nil {
+		if ip, _, err := net.SplitHostPort(c.conn.RemoteAddr().String()); err == nil {
+			params.tags["ip"] = ip
+		}
+	}
+	c.tags = stats.IntoSampleTags(&params.tags)
+
+	go c.readLoop()
+	// Unblock any pending operations if the VU is interrupted.
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.conn.Close()
+		case <-c.done:
+		}
+	}()
+	if params.keepAlive > 0 {
+		go c.keepAlive(params.keepAlive)
+	}
+
+	ret, fnErr := fn(goja.Undefined(), rt.ToValue(c))
+	c.Close()
+
+	end := time.Now()
+	stats.PushIfNotCancelled(ctx, state.Samples, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Metric: metrics.MQTTSessions, Time: start, Tags: c.tags, Value: 1},
+			{Metric: metrics.MQTTConnecting, Time: start, Tags: c.tags, Value: stats.D(connectionEnd.Sub(start))},
+			{Metric: metrics.MQTTSessionDuration, Time: start, Tags: c.tags, Value: stats.D(end.Sub(start))},
+		},
+		Tags: c.tags,
+		Time: start,
+	})
+
+	if fnErr != nil {
+		return nil, fnErr
+	}
+	return ret, nil
+}
+
+func parseConnectParams(rt *goja.Runtime, paramsV goja.Value, state *common.State) (*connectParams, error) {
+	params := &connectParams{
+		keepAlive:    defaultKeepAlive,
+		cleanSession: true,
+		version:      version311,
+		tags:         state.Options.RunTags.CloneTags(),
+	}
+	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		paramsObj := paramsV.ToObject(rt)
+		for _, k := range paramsObj.Keys() {
+			v := paramsObj.Get(k)
+			if goja.IsUndefined(v) || goja.IsNull(v) {
+				continue
+			}
+			switch k {
+			case "clientId":
+				params.clientID = v.String()
+			case "username":
+				params.username = v.String()
+			case "password":
+				params.password = v.String()
+			case "keepAlive":
+				params.keepAlive = time.Duration(v.ToFloat() * float64(time.Millisecond))
+			case "cleanSession":
+				params.cleanSession = v.ToBoolean()
+			case "version":
+				switch v.String() {
+				case "3.1.1", "4":
+					params.version = version311
+				case "5", "5.0":
+					params.version = version5
+				default:
+					return nil, errors.Errorf("unsupported MQTT version '%s', use: '3.1.1' or '5'", v.String())
+				}
+			case "tls":
+				// Either true, or the x509 material to use instead of the test's.
+				if _, ok := v.Export().(bool); ok {
+					params.useTLS = v.ToBoolean()
+					continue
+				}
+				params.useTLS = true
+				tlsObj := v.ToObject(rt)
+				for _, key := range tlsObj.Keys() {
+					switch key {
+					case "cert":
+						params.cert = tlsObj.Get(key).String()
+					case "key":
+						params.key = tlsObj.Get(key).String()
+					case "ca":
+						params.ca = tlsObj.Get(key).String()
+					}
+				}
+			case "timeout":
+				params.timeout = time.Duration(v.ToFloat() * float64(time.Millisecond))
+			case "tags":
+				tagObj := v.ToObject(rt)
+				if tagObj == nil {
+					continue
+				}
+				for _, key := range tagObj.Keys() {
+					params.tags[key] = tagObj.Get(key).String()
+				}
+			}
+		}
+	}
+	if (params.cert == "") != (params.key == "") {
+		return nil, errors.New("a client certificate needs both a cert and a key")
+	}
+	if params.clientID == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		params.clientID = "k6-" + hex.EncodeToString(id)
+	}
+	return params, nil
+}
+
+// dial connects to the broker and sends CONNECT, returning once the broker has accepted it.
+func dial(ctx context.Context, addr string, params *connectParams) (*Client, error) {
+	state := common.GetState(ctx)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+	if params.useTLS {
+		if state.TLSConfig != nil {
+			tlsConfig = state.TLSConfig.Clone()
+			tlsConfig.NextProtos = nil
+		} else {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = host
+		}
+		if params.cert != "" {
+			cert, err := tls.X509KeyPair([]byte(params.cert), []byte(params.key))
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid client certificate")
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+			tlsConfig.GetClientCertificate = nil
+		}
+		if params.ca != "" {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(params.ca)) {
+				return nil, errors.New("the ca doesn't contain any valid PEM-encoded certificates")
+			}
+			tlsConfig.RootCAs = pool
+		}
+	}
+
+	dialCtx := ctx
+	if params.timeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, params.timeout)
+		defer cancel()
+	}
+	conn, err := state.Dialer.DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if params.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(params.timeout))
+	}
+	if tlsConfig != nil {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c := &Client{
+		ctx:     ctx,
+		rt:      common.GetRuntime(ctx),
+		state:   state,
+		conn:    conn,
+		version: params.version,
+		timeout: params.timeout,
+		acks:    make(map[uint16]chan packet),
+		arrived: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if err := c.handshake(params); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func (c *Client) handshake(params *connectParams) error {
+	var flags byte
+	if params.cleanSession {
+		flags |= 0x02
+	}
+	if params.username != "" {
+		flags |= 0x80
+	}
+	if params.password != "" {
+		flags |= 0x40
+	}
+	keepAlive := (params.keepAlive + time.Second - 1) / time.Second
+	if keepAlive > 0xffff {
+		keepAlive = 0xffff
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, c.version, flags)
+	body = appendUint16(body, uint16(keepAlive))
+	body = c.appendProperties(body)
+	body = appendString(body, params.clientID)
+	if params.username != "" {
+		body = appendString(body, params.username)
+	}
+	if params.password != "" {
+		body = appendString(body, params.password)
+	}
+	if err := writePacket(c.conn, packetConnect, 0, body); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(c.conn)
+	p, err := readPacket(r)
+	if err != nil {
+		return c.readError(err)
+	}
+	if p.kind != packetConnack {
+		return errors.Errorf("mqtt: expected CONNACK, got packet type %d", p.kind)
+	}
+	d := decoder{b: p.body}
+	d.byte()
+	code := d.byte()
+	if d.err != nil {
+		return d.err
+	}
+	if err := connackError(c.version, code); err != nil {
+		return err
+	}
+	c.conn = &bufferedConn{Conn: c.conn, r: r}
+	return nil
+}
+
+// A bufferedConn reads through the reader that was used for CONNACK, in case the broker sent
+// more right after it.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+
+// appendProperties appends the (empty) properties of an MQTT 5 packet.
+func (c *Client) appendProperties(b []byte) []byte {
+	if c.version == version5 {
+		return appendVarint(b, 0)
+	}
+	return b
+}
+
+func (c *Client) readError(err error) error {
+	if err == io.EOF {
+		return errors.New("mqtt: the broker closed the connection")
+	}
+	return err
+}
+
+func (c *Client) readLoop() {
+	defer close(c.done)
+	r := bufio.NewReader(c.conn)
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			err = c.readError(err)
+		} else {
+			err = c.handle(p)
+		}
+		if err != nil {
+			c.mu.Lock()
+			if !c.closing && c.err == nil {
+				c.err = err
+			}
+			c.mu.Unlock()
+			_ = c.conn.Close()
+			return
+		}
+	}
+}
+
+// handle handles a packet from the broker.
+func (c *Client) handle(p packet) error {
+	d := decoder{b: p.body}
+	switch p.kind {
+	case packetPublish:
+		qos := int(p.flags>>1) & 3
+		msg := &Message{Topic: d.string(), QoS: qos, Retain: p.flags&1 != 0}
+		var id uint16
+		if qos > 0 {
+			id = d.uint16()
+		}
+		d.properties(c.version)
+		if d.err != nil {
+			return d.err
+		}
+		if qos > 2 {
+			return errors.New("mqtt: malformed packet")
+		}
+		msg.Payload = string(d.b)
+
+		c.mu.Lock()
+		c.messages = append(c.messages, msg)
+		c.mu.Unlock()
+		select {
+		case c.arrived <- struct{}{}:
+		default:
+		}
+		stats.PushIfNotCancelled(c.ctx, c.state.Samples, stats.Sample{
+			Metric: metrics.MQTTMessagesReceived, Time: time.Now(), Tags: c.tags, Value: 1,
+		})
+
+		switch qos {
+		case 1:
+			return c.write(packetPuback, 0, appendUint16(nil, id))
+		case 2:
+			return c.write(packetPubrec, 0, appendUint16(nil, id))
+		}
+	case packetPubrel:
+		id := d.uint16()
+		if d.err != nil {
+			return d.err
+		}
+		return c.write(packetPubcomp, 0, appendUint16(nil, id))
+	case packetPuback, packetPubrec, packetPubcomp, packetSuback, packetUnsuback:
+		id := d.uint16()
+		if d.err != nil {
+			return d.err
+		}
+		c.mu.Lock()
+		ch := c.acks[id]
+		c.mu.Unlock()
+		if ch != nil {
+			select {
+			case ch <- p:
+			default:
+			}
+		}
+	case packetPingresp:
+	case packetDisconnect:
+		if code := d.byte(); code != 0 {
+			return errors.Errorf("mqtt: the broker disconnected: %s", reasonString(code))
+		}
+		return errors.New("mqtt: the broker disconnected")
+	default:
+		return errors.Errorf("mqtt: unexpected packet type %d", p.kind)
+	}
+	return nil
+}
+
+func (c *Client) write(kind, flags byte, body []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.timeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	return writePacket(c.conn, kind, flags, body)
+}
+
+func (c *Client) keepAlive(interval time.Duration) {
+	t := time.NewTicker(interval / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			_ = c.write(packetPingreq, 0, nil)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// newID returns an unused packet identifier, and the channel its acknowledgements arrive on.
+func (c *Client) newID() (uint16, chan packet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		c.lastID++
+		if _, ok := c.acks[c.lastID]; c.lastID != 0 && !ok {
+			break
+		}
+	}
+	ch := make(chan packet, 1)
+	c.acks[c.lastID] = ch
+	return c.lastID, ch
+}
+
+func (c *Client) releaseID(id uint16) {
+	c.mu.Lock()
+	delete(c.acks, id)
+	c.mu.Unlock()
+}
+
+// await waits for the acknowledgement of the given type to arrive on ch.
+func (c *Client) await(ch chan packet, kind byte) (*decoder, error) {
+	var expired <-chan time.Time
+	if c.timeout > 0 {
+		t := time.NewTimer(c.timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case p := <-ch:
+		if p.kind != kind {
+			return nil, errors.Errorf("mqtt: expected packet type %d, got %d", kind, p.kind)
+		}
+		d := &decoder{b: p.body}
+		d.uint16()
+		return d, nil
+	case <-c.done:
+		return nil, c.lostError()
+	case <-expired:
+		return nil, errors.New("mqtt: timed out waiting for the broker")
+	}
+}
+
+// lostError returns why the connection was lost.
+func (c *Client) lostError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return errors.New("mqtt: the connection is closed")
+}
+
+// ackError returns the error for the reason code of a PUBACK or PUBREC from an MQTT 5 broker,
+// which leaves it out on success.
+func (c *Client) ackError(d *decoder) error {
+	if c.version != version5 || len(d.b) == 0 {
+		return nil
+	}
+	if code := d.byte(); code >= 0x80 {
+		return errors.Errorf("mqtt: the broker rejected the message: %s", reasonString(code))
+	}
+	return nil
+}
+
+// Publish sends a message, a string or an array of bytes, to the topic. With a QoS of 1 or 2, it
+// waits until the broker has acknowledged it.
+func (c *Client) Publish(topic string, payload goja.Value, paramsV goja.Value) error {
+	data, err := toBytes(payload)
+	if err != nil {
+		return err
+	}
+	if topic == "" || strings.ContainsAny(topic, "+#") {
+		return errors.Errorf("invalid topic '%s'", topic)
+	}
+	var qos int64
+	var retain bool
+	if paramsV != nil && !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		params := paramsV.ToObject(c.rt)
+		if v := params.Get("qos"); v != nil && !goja.IsUndefined(v) {
+			qos = v.ToInteger()
+		}
+		if v := params.Get("retain"); v != nil && !goja.IsUndefined(v) {
+			retain = v.ToBoolean()
+		}
+	}
+	if qos < 0 || qos > 2 {
+		return errors.Errorf("invalid QoS %d, use: 0, 1 or 2", qos)
+	}
+
+	flags := byte(qos) << 1
+	if retain {
+		flags |= 0x01
+	}
+	body := appendString(nil, topic)
+	var id uint16
+	var acks chan packet
+	if qos > 0 {
+		id, acks = c.newID()
+		defer c.releaseID(id)
+		body = appendUint16(body, id)
+	}
+	body = c.appendProperties(body)
+	body = append(body, data...)
+
+	start := time.Now()
+	if err := c.write(packetPublish, flags, body); err != nil {
+		return err
+	}
+	switch qos {
+	case 1:
+		d, err := c.await(acks, packetPuback)
+		if err != nil {
+			return err
+		}
+		if err := c.ackError(d); err != nil {
+			return err
+		}
+	case 2:
+		d, err := c.await(acks, packetPubrec)
+		if err != nil {
+			return err
+		}
+		if err := c.ackError(d); err != nil {
+			return err
+		}
+		if err := c.write(packetPubrel, 0x02, appendUint16(nil, id)); err != nil {
+			return err
+		}
+		if _, err := c.await(acks, packetPubcomp); err != nil {
+			return err
+		}
+	}
+	end := time.Now()
+
+	stats.PushIfNotCancelled(c.ctx, c.state.Samples, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Metric: metrics.MQTTMessagesSent, Time: start, Tags: c.tags, Value: 1},
+			{Metric: metrics.MQTTPublishDuration, Time: start, Tags: c.tags, Value: stats.D(end.Sub(start))},
+		},
+		Tags: c.tags,
+		Time: start,
+	})
+	return nil
+}
+
+// Subscribe subscribes to a topic filter, and returns the QoS the broker granted.
+func (c *Client) Subscribe(filter string, paramsV goja.Value) (int, error) {
+	if filter == "" {
+		return 0, errors.New("invalid topic filter ''")
+	}
+	var qos int64
+	if paramsV != nil && !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		if v := paramsV.ToObject(c.rt).Get("qos"); v != nil && !goja.IsUndefined(v) {
+			qos = v.ToInteger()
+		}
+	}
+	if qos < 0 || qos > 2 {
+		return 0, errors.Errorf("invalid QoS %d, use: 0, 1 or 2", qos)
+	}
+
+	id, acks := c.newID()
+	defer c.releaseID(id)
+	body := c.appendProperties(appendUint16(nil, id))
+	body = append(appendString(body, filter), byte(qos))
+	if err := c.write(packetSubscribe, 0x02, body); err != nil {
+		return 0, err
+	}
+	d, err := c.await(acks, packetSuback)
+	if err != nil {
+		return 0, err
+	}
+	d.properties(c.version)
+	code := d.byte()
+	if d.err != nil {
+		return 0, d.err
+	}
+	if code >= 0x80 {
+		if c.version == version311 {
+			return 0, errors.Errorf("mqtt: the broker rejected the subscription to '%s'", filter)
+		}
+		return 0, errors.Errorf("mqtt: the broker rejected the subscription to '%s': %s", filter, reasonString(code))
+	}
+	return int(code), nil
+}
+
+// Unsubscribe unsubscribes from a topic filter.
+func (c *Client) Unsubscribe(filter string) error {
+	id, acks := c.newID()
+	defer c.releaseID(id)
+	body := c.appendProperties(appendUint16(nil, id))
+	body = appendString(body, filter)
+	if err := c.write(packetUnsubscribe, 0x02, body); err != nil {
+		return err
+	}
+	d, err := c.await(acks, packetUnsuback)
+	if err != nil {
+		return err
+	}
+	if c.version == version5 {
+		d.properties(c.version)
+		// 0x11 means there was no such subscription, which is fine.
+		if code := d.byte(); d.err == nil && code >= 0x80 {
+			return errors.Errorf("mqtt: the broker rejected unsubscribing from '%s': %s", filter, reasonString(code))
+		}
+	}
+	return nil
+}
+
+// Receive returns the next message from the subscribed topics, waiting for up to timeout
+// milliseconds for one to arrive, or as long as the timeout of the connection if none is given.
+// It returns null if none arrived in time.
+func (c *Client) Receive(timeout float64) (goja.Value, error) {
+	wait := time.Duration(timeout * float64(time.Millisecond))
+	if wait <= 0 {
+		wait = c.timeout
+	}
+	var expired <-chan time.Time
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		expired = t.C
+	}
+	for {
+		c.mu.Lock()
+		if len(c.messages) > 0 {
+			msg := c.messages[0]
+			c.messages[0] = nil
+			c.messages = c.messages[1:]
+			c.mu.Unlock()
+			return c.rt.ToValue(msg), nil
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.arrived:
+		case <-c.done:
+			// Messages that arrived before the connection was lost are still returned.
+			c.mu.Lock()
+			pending := len(c.messages)
+			c.mu.Unlock()
+			if pending == 0 {
+				return nil, c.lostError()
+			}
+		case <-expired:
+			return goja.Null(), nil
+		}
+	}
+}
+
+// Close disconnects from the broker. It's done automatically when the mqtt.connect() callback
+// returns.
+func (c *Client) Close() {
+	c.mu.Lock()
+	if c.closing {
+		c.mu.Unlock()
+		return
+	}
+	c.closing = true
+	lost := c.err != nil
+	c.mu.Unlock()
+
+	if !lost {
+		var body []byte
+		if c.version == version5 {
+			body = []byte{0}
+		}
+		_ = c.write(packetDisconnect, 0, body)
+	}
+	_ = c.conn.Close()
+	<-c.done
+}
+
+// toBytes returns the bytes of a string or an array of bytes.
+func toBytes(data goja.Value) ([]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+	switch v := data.Export().(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case []interface{}:
+		b := make([]byte, len(v))
+		for i, e := range v {
+			n, ok := e.(int64)
+			if !ok || n < 0 || n > 255 {
+				return nil, errors.Errorf("invalid byte at index %d: %v", i, e)
+			}
+			b[i] = byte(n)
+		}
+		return b, nil
+	default:
+		return nil, errors.Errorf("can only publish strings and arrays of bytes, not %T", v)
+	}
+}
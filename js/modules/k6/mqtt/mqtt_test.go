@@ -0,0 +1,360 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/testutils"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A broker is just enough of an MQTT broker to test against: it delivers the messages a client
+// publishes back to that client, if it's subscribed to them. It refuses the password "wrong",
+// rejects subscriptions to "forbidden", and drops the connection on messages to "k6/kick".
+type broker struct {
+	mu       sync.Mutex
+	connects []string
+}
+
+func (b *broker) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *broker) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+
+	p, err := readPacket(r)
+	if err != nil || p.kind != packetConnect {
+		return
+	}
+	d := decoder{b: p.body}
+	d.string()
+	version, flags := d.byte(), d.byte()
+	d.uint16()
+	d.properties(version)
+	clientID := d.string()
+	var username, password string
+	if flags&0x80 != 0 {
+		username = d.string()
+	}
+	if flags&0x40 != 0 {
+		password = d.string()
+	}
+	b.mu.Lock()
+	b.connects = append(b.connects, clientID+":"+username)
+	b.mu.Unlock()
+
+	withProperties := func(body []byte) []byte {
+		if version == version5 {
+			return appendVarint(body, 0)
+		}
+		return body
+	}
+	code := byte(0)
+	if password == "wrong" {
+		code = map[byte]byte{version311: 4, version5: 0x86}[version]
+	}
+	if writePacket(conn, packetConnack, 0, withProperties([]byte{0, code})) != nil || code != 0 {
+		return
+	}
+
+	subscriptions := map[string]byte{}
+	var lastID uint16
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		d := decoder{b: p.body}
+		switch p.kind {
+		case packetPublish:
+			qos := p.flags >> 1 & 3
+			topic := d.string()
+			var id uint16
+			if qos > 0 {
+				id = d.uint16()
+			}
+			d.properties(version)
+			if topic == "k6/kick" {
+				return
+			}
+			switch qos {
+			case 1:
+				_ = writePacket(conn, packetPuback, 0, appendUint16(nil, id))
+			case 2:
+				_ = writePacket(conn, packetPubrec, 0, appendUint16(nil, id))
+			}
+			for filter, maxQoS := range subscriptions {
+				if filter != topic && !(strings.HasSuffix(filter, "/#") && strings.HasPrefix(topic, filter[:len(filter)-1])) {
+					continue
+				}
+				if qos > maxQoS {
+					qos = maxQoS
+				}
+				body := appendString(nil, topic)
+				if qos > 0 {
+					lastID++
+					body = appendUint16(body, lastID)
+				}
+				body = append(withProperties(body), d.b...)
+				_ = writePacket(conn, packetPublish, qos<<1|p.flags&1, body)
+			}
+		case packetPubrel:
+			_ = writePacket(conn, packetPubcomp, 0, p.body[:2])
+		case packetPuback, packetPubrec, packetPubcomp:
+			if p.kind == packetPubrec {
+				_ = writePacket(conn, packetPubrel, 0x02, p.body[:2])
+			}
+		case packetSubscribe:
+			id := d.uint16()
+			d.properties(version)
+			filter, qos := d.string(), d.byte()
+			if filter == "forbidden" {
+				qos = 0x80
+			} else {
+				subscriptions[filter] = qos
+			}
+			_ = writePacket(conn, packetSuback, 0, append(withProperties(appendUint16(nil, id)), qos))
+		case packetUnsubscribe:
+			id := d.uint16()
+			d.properties(version)
+			delete(subscriptions, d.string())
+			body := withProperties(appendUint16(nil, id))
+			if version == version5 {
+				body = append(body, 0)
+			}
+			_ = writePacket(conn, packetUnsuback, 0, body)
+		case packetPingreq:
+			_ = writePacket(conn, packetPingresp, 0, nil)
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+func TestConnect(t *testing.T) {
+	b := &broker{}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+	go b.serve(l)
+
+	certs := testutils.NewTestCerts(t)
+	tlsL, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{certs.Server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    certs.CAPool(),
+	})
+	require.NoError(t, err)
+	defer func() { _ = tlsL.Close() }()
+	go b.serve(tlsL)
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &common.State{
+		Group:  root,
+		Dialer: netext.NewDialer(net.Dialer{Timeout: 10 * time.Second}),
+		Options: lib.Options{
+			SystemTags: lib.GetTagSet("group"),
+		},
+		Samples: samples,
+	}
+
+	ctx := context.Background()
+	ctx = common.WithState(ctx, state)
+	ctx = common.WithRuntime(ctx, rt)
+
+	rt.Set("mqtt", common.Bind(rt, New(), &ctx))
+	rt.Set("addr", l.Addr().String())
+	rt.Set("tlsAddr", tlsL.Addr().String())
+
+	for _, version := range []string{"3.1.1", "5"} {
+		t.Run("Version "+version, func(t *testing.T) {
+			_, err := common.RunString(rt, `
+			mqtt.connect(addr, { version: "`+version+`", timeout: 2000 }, function(client) {
+				if (client.subscribe("k6/#", { qos: 2 }) !== 2) {
+					throw new Error("QoS 2 wasn't granted");
+				}
+				for (let qos = 0; qos <= 2; qos++) {
+					client.publish("k6/test", "hello " + qos, { qos: qos, retain: qos === 1 });
+				}
+				for (let qos = 0; qos <= 2; qos++) {
+					let msg = client.receive();
+					if (msg.topic !== "k6/test" || msg.payload !== "hello " + qos || msg.qos !== qos) {
+						throw new Error("Unexpected message: " + JSON.stringify(msg));
+					}
+					if (msg.retain !== (qos === 1)) {
+						throw new Error("Retain flag mismatch: " + JSON.stringify(msg));
+					}
+				}
+				if (client.receive(50) !== null) {
+					throw new Error("Received a message that wasn't published");
+				}
+
+				client.publish("other/test", [104, 105]);
+				client.unsubscribe("k6/#");
+				client.publish("k6/test", "hello");
+				if (client.receive(50) !== null) {
+					throw new Error("Received a message after unsubscribing");
+				}
+			});`)
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("ClientID", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		mqtt.connect(addr, { clientId: "k6-1", username: "k6", password: "k6" }, function() {});
+		mqtt.connect(addr, function() {});`)
+		assert.NoError(t, err)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		assert.Equal(t, "k6-1:k6", b.connects[len(b.connects)-2])
+		assert.Regexp(t, "^k6-[0-9a-f]{16}:$", b.connects[len(b.connects)-1])
+	})
+
+	t.Run("Refused", func(t *testing.T) {
+		_, err := common.RunString(rt, `mqtt.connect(addr, { username: "k6", password: "wrong" }, function() {});`)
+		assert.EqualError(t, err, "GoError: mqtt: connection refused: bad user name or password")
+
+		_, err = common.RunString(rt, `
+		mqtt.connect(addr, { username: "k6", password: "wrong", version: 5 }, function() {});`)
+		assert.EqualError(t, err, "GoError: mqtt: connection refused: bad user name or password")
+	})
+
+	t.Run("Rejected subscription", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		mqtt.connect(addr, function(client) {
+			client.subscribe("forbidden");
+		});`)
+		assert.EqualError(t, err, "GoError: mqtt: the broker rejected the subscription to 'forbidden'")
+	})
+
+	t.Run("Connection lost", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		mqtt.connect(addr, { timeout: 2000 }, function(client) {
+			client.publish("k6/kick", "bye");
+			client.receive();
+		});`)
+		assert.EqualError(t, err, "GoError: mqtt: the broker closed the connection")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		mqtt.connect(addr, function(client) {
+			client.publish("k6/test", "hello", { qos: 3 });
+		});`)
+		assert.EqualError(t, err, "GoError: invalid QoS 3, use: 0, 1 or 2")
+
+		_, err = common.RunString(rt, `
+		mqtt.connect(addr, function(client) {
+			client.publish("k6/#", "hello");
+		});`)
+		assert.EqualError(t, err, "GoError: invalid topic 'k6/#'")
+
+		_, err = common.RunString(rt, `mqtt.connect(addr, { version: "3.1" }, function() {});`)
+		assert.EqualError(t, err, "GoError: unsupported MQTT version '3.1', use: '3.1.1' or '5'")
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		certPEM, keyPEM := testutils.CertificatePEM(t, certs.Client)
+		rt.Set("tlsParams", map[string]string{"ca": certs.CAPEM(), "cert": certPEM, "key": keyPEM})
+
+		_, err := common.RunString(rt, `
+		mqtt.connect(tlsAddr, { tls: tlsParams, timeout: 2000 }, function(client) {
+			client.subscribe("k6/tls", { qos: 1 });
+			client.publish("k6/tls", "hello", { qos: 1 });
+			if (client.receive().payload !== "hello") {
+				throw new Error("Message mismatch");
+			}
+		});`)
+		assert.NoError(t, err)
+
+		// Without the client certificate, the broker hangs up during the handshake.
+		_, err = common.RunString(rt, `
+		mqtt.connect(tlsAddr, { tls: { ca: tlsParams.ca }, timeout: 2000 }, function() {});`)
+		assert.Error(t, err)
+
+		// Without the CA, the broker's certificate isn't trusted.
+		_, err = common.RunString(rt, `mqtt.connect(tlsAddr, { tls: true, timeout: 2000 }, function() {});`)
+		assert.Contains(t, err.Error(), "certificate")
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		for len(samples) > 0 {
+			<-samples
+		}
+		_, err := common.RunString(rt, `
+		mqtt.connect(addr, { tags: { broker: "test" } }, function(client) {
+			client.subscribe("k6/metrics", { qos: 1 });
+			client.publish("k6/metrics", "hello", { qos: 1 });
+			client.receive(2000);
+		});`)
+		assert.NoError(t, err)
+
+		counts := map[string]float64{}
+		seen := map[string]bool{}
+		for len(samples) > 0 {
+			for _, sample := range (<-samples).GetSamples() {
+				seen[sample.Metric.Name] = true
+				if sample.Metric.Type == stats.Counter {
+					counts[sample.Metric.Name] += sample.Value
+				}
+				tag, _ := sample.Tags.Get("broker")
+				assert.Equal(t, "test", tag)
+			}
+		}
+		assert.Equal(t, map[string]float64{
+			metrics.MQTTSessions.Name:         1,
+			metrics.MQTTMessagesSent.Name:     1,
+			metrics.MQTTMessagesReceived.Name: 1,
+		}, counts)
+		for _, m := range []*stats.Metric{metrics.MQTTConnecting, metrics.MQTTSessionDuration, metrics.MQTTPublishDuration} {
+			assert.True(t, seen[m.Name], m.Name)
+		}
+	})
+}
@@ -0,0 +1,235 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Protocol versions, as sent in the CONNECT packet.
+const (
+	version311 = 4
+	version5   = 5
+)
+
+// Control packet types.
+const (
+	packetConnect     = 1
+	packetConnack     = 2
+	packetPublish     = 3
+	packetPuback      = 4
+	packetPubrec      = 5
+	packetPubrel      = 6
+	packetPubcomp     = 7
+	packetSubscribe   = 8
+	packetSuback      = 9
+	packetUnsubscribe = 10
+	packetUnsuback    = 11
+	packetPingreq     = 12
+	packetPingresp    = 13
+	packetDisconnect  = 14
+)
+
+// The largest packet accepted from the broker. The protocol allows up to 256MB.
+const maxPacketSize = 16 << 20
+
+// A packet is an MQTT control packet: the type and flags of its fixed header, and whatever
+// follows the remaining length.
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+func writePacket(w io.Writer, kind, flags byte, body []byte) error {
+	buf := appendVarint([]byte{kind<<4 | flags}, len(body))
+	_, err := w.Write(append(buf, body...))
+	return err
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	var size int
+	for i := uint(0); ; i += 7 {
+		if i > 21 {
+			return packet{}, errors.New("mqtt: malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		size |= int(b&0x7f) << i
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if size > maxPacketSize {
+		return packet{}, errors.Errorf("mqtt: packet of %d bytes is too large", size)
+	}
+	p := packet{kind: header >> 4, flags: header & 0x0f, body: make([]byte, size)}
+	if _, err := io.ReadFull(r, p.body); err != nil {
+		return packet{}, err
+	}
+	return p, nil
+}
+
+// appendVarint appends a variable byte integer, as used for lengths.
+func appendVarint(b []byte, n int) []byte {
+	for {
+		d := byte(n % 128)
+		if n /= 128; n > 0 {
+			d |= 0x80
+		}
+		b = append(b, d)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+func appendUint16(b []byte, n uint16) []byte {
+	return append(b, byte(n>>8), byte(n))
+}
+
+// appendString appends a length-prefixed string, or binary data.
+func appendString(b []byte, s string) []byte {
+	return append(appendUint16(b, uint16(len(s))), s...)
+}
+
+// A decoder reads the fields of a packet body. After the first error, it returns zero values,
+// and err tells what went wrong.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.b) {
+		d.err = errors.New("mqtt: malformed packet")
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	return string(d.next(int(d.uint16())))
+}
+
+func (d *decoder) varint() int {
+	var n int
+	for i := uint(0); i <= 21; i += 7 {
+		b := d.byte()
+		n |= int(b&0x7f) << i
+		if b&0x80 == 0 {
+			return n
+		}
+	}
+	d.err = errors.New("mqtt: malformed variable byte integer")
+	return 0
+}
+
+// properties skips the properties of an MQTT 5 packet, which the client doesn't use.
+func (d *decoder) properties(version byte) {
+	if version == version5 {
+		d.next(d.varint())
+	}
+}
+
+// connackError returns the error for a CONNACK return code (3.1.1) or reason code (5), or nil if
+// the connection was accepted.
+func connackError(version, code byte) error {
+	if code == 0 {
+		return nil
+	}
+	if version == version311 {
+		reasons := map[byte]string{
+			1: "unacceptable protocol version",
+			2: "identifier rejected",
+			3: "server unavailable",
+			4: "bad user name or password",
+			5: "not authorized",
+		}
+		if reason, ok := reasons[code]; ok {
+			return errors.Errorf("mqtt: connection refused: %s", reason)
+		}
+	}
+	return errors.Errorf("mqtt: connection refused: %s", reasonString(code))
+}
+
+// reasonString describes the MQTT 5 reason codes that brokers commonly return.
+func reasonString(code byte) string {
+	reasons := map[byte]string{
+		0x80: "unspecified error",
+		0x81: "malformed packet",
+		0x82: "protocol error",
+		0x83: "implementation specific error",
+		0x84: "unsupported protocol version",
+		0x85: "client identifier not valid",
+		0x86: "bad user name or password",
+		0x87: "not authorized",
+		0x88: "server unavailable",
+		0x89: "server busy",
+		0x8A: "banned",
+		0x8B: "server shutting down",
+		0x8D: "keep alive timeout",
+		0x8E: "session taken over",
+		0x8F: "topic filter invalid",
+		0x90: "topic name invalid",
+		0x91: "packet identifier in use",
+		0x97: "quota exceeded",
+		0x99: "payload format invalid",
+		0x9A: "retain not supported",
+		0x9B: "QoS not supported",
+		0x9E: "shared subscriptions not supported",
+		0xA2: "wildcard subscriptions not supported",
+	}
+	if reason, ok := reasons[code]; ok {
+		return reason
+	}
+	return fmt.Sprintf("reason code 0x%02x", code)
+}
@@ -0,0 +1,317 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	gonet "net"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// ErrNetInInitContext is returned when sockets are used in the init context
+var ErrNetInInitContext = common.NewInitContextError("using sockets in the init context is not supported")
+
+const (
+	// Read size used if the script doesn't specify one.
+	defaultReadSize = 4096
+
+	// Maximum amount of data readUntil() buffers while looking for the delimiter.
+	maxReadUntilSize = 1 << 20
+)
+
+type Net struct{}
+
+// Conn is a connection opened by net.connect(); it's only valid inside the callback.
+type Conn struct {
+	conn    gonet.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+func New() *Net {
+	return &Net{}
+}
+
+// Connect opens a TCP, UDP or TLS connection, passes it to the given function, and closes it
+// when the function returns. It returns whatever the function returns.
+func (*Net) Connect(ctx context.Context, network, addr string, args ...goja.Value) (goja.Value, error) {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, ErrNetInInitContext
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+	default:
+		return nil, errors.Errorf("unsupported network '%s', use: 'tcp' or 'udp'", network)
+	}
+
+	// The params argument is optional
+	var callableV, paramsV goja.Value
+	switch len(args) {
+	case 2:
+		paramsV = args[0]
+		callableV = args[1]
+	case 1:
+		paramsV = goja.Undefined()
+		callableV = args[0]
+	default:
+		return nil, errors.New("invalid number of arguments to net.connect")
+	}
+
+	fn, isFunc := goja.AssertFunction(callableV)
+	if !isFunc {
+		return nil, errors.New("last argument to net.connect must be a function")
+	}
+
+	tags := state.Options.RunTags.CloneTags()
+	var timeout time.Duration
+	var useTLS bool
+
+	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		params := paramsV.ToObject(rt)
+		for _, k := range params.Keys() {
+			switch k {
+			case "timeout":
+				timeout = time.Duration(params.Get(k).ToFloat() * float64(time.Millisecond))
+			case "tls":
+				useTLS = params.Get(k).ToBoolean()
+			case "tags":
+				tagsV := params.Get(k)
+				if goja.IsUndefined(tagsV) || goja.IsNull(tagsV) {
+					continue
+				}
+				tagObj := tagsV.ToObject(rt)
+				if tagObj == nil {
+					continue
+				}
+				for _, key := range tagObj.Keys() {
+					tags[key] = tagObj.Get(key).String()
+				}
+			}
+		}
+	}
+	if useTLS && network[:3] != "tcp" {
+		return nil, errors.New("TLS is only supported over TCP")
+	}
+
+	if state.Options.SystemTags["proto"] {
+		tags["proto"] = network[:3]
+	}
+	if state.Options.SystemTags["group"] {
+		tags["group"] = state.Group.Path
+	}
+
+	dialCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	conn, err := state.Dialer.DialContext(dialCtx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	connectionEnd := time.Now()
+	defer func() { _ = conn.Close() }()
+
+	if state.Options.SystemTags["ip"] && conn.RemoteAddr() != nil {
+		if ip, _, err := gonet.SplitHostPort(conn.RemoteAddr().String()); err == nil {
+			tags["ip"] = ip
+		}
+	}
+
+	var tlsHandshakeDuration time.Duration
+	if useTLS {
+		var tlsConfig *tls.Config
+		if state.TLSConfig != nil {
+			tlsConfig = state.TLSConfig.Clone()
+			tlsConfig.NextProtos = nil
+		} else {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			if host, _, err := gonet.SplitHostPort(addr); err == nil {
+				tlsConfig.ServerName = host
+			}
+		}
+
+		tlsConn := tls.Client(conn, tlsConfig)
+		if timeout > 0 {
+			_ = tlsConn.SetDeadline(time.Now().Add(timeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+		_ = tlsConn.SetDeadline(time.Time{})
+		tlsHandshakeDuration = time.Since(connectionEnd)
+		conn = tlsConn
+	}
+
+	c := &Conn{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}
+
+	// Unblock any pending reads or writes if the VU is interrupted.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	ret, fnErr := fn(goja.Undefined(), rt.ToValue(c))
+	_ = c.Close()
+
+	end := time.Now()
+	sampleTags := stats.IntoSampleTags(&tags)
+	samples := []stats.Sample{
+		{Metric: metrics.NetSessions, Time: start, Tags: sampleTags, Value: 1},
+		{Metric: metrics.NetConnecting, Time: start, Tags: sampleTags, Value: stats.D(connectionEnd.Sub(start))},
+		{Metric: metrics.NetSessionDuration, Time: start, Tags: sampleTags, Value: stats.D(end.Sub(start))},
+	}
+	if useTLS {
+		samples = append(samples, stats.Sample{
+			Metric: metrics.NetTLSHandshaking, Time: start, Tags: sampleTags, Value: stats.D(tlsHandshakeDuration),
+		})
+	}
+	stats.PushIfNotCancelled(ctx, state.Samples, stats.ConnectedSamples{
+		Samples: samples,
+		Tags:    sampleTags,
+		Time:    start,
+	})
+
+	if fnErr != nil {
+		return nil, fnErr
+	}
+	return ret, nil
+}
+
+// deadline returns the deadline for an operation starting now, or the zero time if there's no
+// timeout.
+func (c *Conn) deadline() time.Time {
+	if c.timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(c.timeout)
+}
+
+// Write sends a string or an array of bytes, and returns the number of bytes written.
+func (c *Conn) Write(data goja.Value) (int, error) {
+	var b []byte
+	switch v := data.Export().(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	case []interface{}:
+		b = make([]byte, len(v))
+		for i, e := range v {
+			n, ok := e.(int64)
+			if !ok || n < 0 || n > 255 {
+				return 0, errors.Errorf("invalid byte at index %d: %v", i, e)
+			}
+			b[i] = byte(n)
+		}
+	default:
+		return 0, errors.Errorf("can only write strings and arrays of bytes, not %T", v)
+	}
+
+	_ = c.conn.SetWriteDeadline(c.deadline())
+	return c.conn.Write(b)
+}
+
+// Read reads up to size bytes (4096 by default) and returns them as a string. It returns as soon
+// as any data is available, and returns an empty string if the connection was closed.
+func (c *Conn) Read(size int) (string, error) {
+	b, err := c.ReadBytes(size)
+	return string(b), err
+}
+
+// ReadBytes is like Read, but returns an array of bytes.
+func (c *Conn) ReadBytes(size int) ([]byte, error) {
+	if size <= 0 {
+		size = defaultReadSize
+	}
+	_ = c.conn.SetReadDeadline(c.deadline())
+	b := make([]byte, size)
+	n, err := c.reader.Read(b)
+	if err == io.EOF {
+		err = nil
+	}
+	return b[:n], err
+}
+
+// ReadUntil reads until the given delimiter, and returns everything read including it.
+func (c *Conn) ReadUntil(delim string) (string, error) {
+	if delim == "" {
+		return "", errors.New("readUntil needs a delimiter")
+	}
+
+	_ = c.conn.SetReadDeadline(c.deadline())
+	last := delim[len(delim)-1]
+	var buf []byte
+	for {
+		chunk, err := c.reader.ReadSlice(last)
+		buf = append(buf, chunk...)
+		if err == nil && bytes.HasSuffix(buf, []byte(delim)) {
+			return string(buf), nil
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			if err == io.EOF {
+				err = errors.New("the connection was closed before the delimiter was read")
+			}
+			return "", err
+		}
+		if len(buf) > maxReadUntilSize {
+			return "", errors.Errorf("the delimiter wasn't found in the first %d bytes", maxReadUntilSize)
+		}
+	}
+}
+
+// Close closes the connection. It's closed automatically when the net.connect() callback returns.
+func (c *Conn) Close() error {
+	err := c.conn.Close()
+	if err != nil && isClosedErr(err) {
+		return nil
+	}
+	return err
+}
+
+// isClosedErr returns whether err is the error returned when closing an already closed connection.
+func isClosedErr(err error) bool {
+	opErr, ok := err.(*gonet.OpError)
+	return ok && opErr.Err.Error() == "use of closed network connection"
+}
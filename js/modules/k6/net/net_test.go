@@ -0,0 +1,233 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package net
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	gonet "net"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/testutils"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveTCP accepts connections on l, and replies to each line with the line upper cased.
+func serveTCP(l gonet.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer func() { _ = conn.Close() }()
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == "QUIT\n" {
+					return
+				}
+				if line == "WAIT\n" {
+					continue
+				}
+				_, _ = io.WriteString(conn, fmt.Sprintf("%X", line[:len(line)-1])+"\r\n")
+			}
+		}()
+	}
+}
+
+func TestConnect(t *testing.T) {
+	tcpL, err := gonet.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = tcpL.Close() }()
+	go serveTCP(tcpL)
+
+	tlsL, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{testutils.NewTestCerts(t).Server},
+	})
+	require.NoError(t, err)
+	defer func() { _ = tlsL.Close() }()
+	go serveTCP(tlsL)
+
+	udpConn, err := gonet.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = udpConn.Close() }()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := udpConn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = udpConn.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &common.State{
+		Group:     root,
+		Dialer:    netext.NewDialer(gonet.Dialer{Timeout: 10 * time.Second}),
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+		Options: lib.Options{
+			SystemTags: lib.GetTagSet("proto", "group", "ip"),
+		},
+		Samples: samples,
+	}
+
+	ctx := context.Background()
+	ctx = common.WithState(ctx, state)
+	ctx = common.WithRuntime(ctx, rt)
+
+	rt.Set("net", common.Bind(rt, New(), &ctx))
+	rt.Set("tcpAddr", tcpL.Addr().String())
+	rt.Set("tlsAddr", tlsL.Addr().String())
+	rt.Set("udpAddr", udpConn.LocalAddr().String())
+
+	assertSessionMetrics := func(t *testing.T, proto string, tls bool) {
+		seen := map[*stats.Metric]bool{}
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				seen[sample.Metric] = true
+				tags := sample.Tags.CloneTags()
+				assert.Equal(t, proto, tags["proto"])
+				assert.Equal(t, "127.0.0.1", tags["ip"])
+			}
+		}
+		assert.True(t, seen[metrics.NetSessions])
+		assert.True(t, seen[metrics.NetConnecting])
+		assert.True(t, seen[metrics.NetSessionDuration])
+		assert.Equal(t, tls, seen[metrics.NetTLSHandshaking])
+	}
+
+	t.Run("TCP", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		net.connect("tcp", tcpAddr, function(conn) {
+			conn.write("hello\n");
+			return conn.readUntil("\r\n");
+		});
+		`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "68656C6C6F\r\n", v.String())
+		}
+		assertSessionMetrics(t, "tcp", false)
+	})
+	t.Run("TLS", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		net.connect("tcp", tlsAddr, { tls: true, timeout: 5000 }, function(conn) {
+			conn.write([104, 105, 10]);
+			return conn.readUntil("\r\n");
+		});
+		`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "6869\r\n", v.String())
+		}
+		assertSessionMetrics(t, "tcp", true)
+	})
+	t.Run("UDP", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		net.connect("udp", udpAddr, { timeout: 5000 }, function(conn) {
+			conn.write("ping");
+			return conn.read();
+		});
+		`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "ping", v.String())
+		}
+		assertSessionMetrics(t, "udp", false)
+	})
+	t.Run("ReadBytes", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		net.connect("tcp", tcpAddr, function(conn) {
+			conn.write("A\n");
+			let bytes = conn.readBytes(4);
+			return bytes.join(",");
+		});
+		`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "52,49,13,10", v.String())
+		}
+		stats.GetBufferedSamples(samples)
+	})
+	t.Run("Closed", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		net.connect("tcp", tcpAddr, function(conn) {
+			conn.write("QUIT\n");
+			return conn.read(10);
+		});
+		`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "", v.String())
+		}
+		stats.GetBufferedSamples(samples)
+	})
+	t.Run("Timeout", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		net.connect("tcp", tcpAddr, { timeout: 100 }, function(conn) {
+			conn.write("WAIT\n");
+			conn.read();
+		});
+		`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "i/o timeout")
+		}
+		stats.GetBufferedSamples(samples)
+	})
+	t.Run("Errors", func(t *testing.T) {
+		testdata := map[string]string{
+			"bad network":  `net.connect("unix", "/tmp/sock", function() {})`,
+			"no callback":  `net.connect("tcp", tcpAddr)`,
+			"TLS over UDP": `net.connect("udp", udpAddr, { tls: true }, function() {})`,
+			"bad byte":     `net.connect("tcp", tcpAddr, function(conn) { conn.write([256]) })`,
+			"no delimiter": `net.connect("tcp", tcpAddr, function(conn) { conn.readUntil("") })`,
+		}
+		for name, script := range testdata {
+			t.Run(name, func(t *testing.T) {
+				_, err := common.RunString(rt, script)
+				assert.Error(t, err)
+			})
+		}
+		stats.GetBufferedSamples(samples)
+	})
+	t.Run("InitContext", func(t *testing.T) {
+		initCtx := common.WithRuntime(context.Background(), rt)
+		_, err := New().Connect(initCtx, "tcp", tcpL.Addr().String())
+		assert.Equal(t, ErrNetInInitContext, err)
+	})
+}
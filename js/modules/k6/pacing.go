@@ -0,0 +1,123 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package k6
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// ErrPaceInInitContext is returned when pace() is used in the init context
+var ErrPaceInInitContext = common.NewInitContextError("Using pace() in the init context is not supported")
+
+// Pace waits until the iteration has taken 60/ratePerMinute seconds since it started, so that each
+// VU runs at most ratePerMinute iterations per minute however fast the target responds. It returns
+// the number of seconds it waited, which is 0 if the iteration already took longer than that.
+func (*K6) Pace(ctx context.Context, ratePerMinute float64) (float64, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return 0, ErrPaceInInitContext
+	}
+	if ratePerMinute <= 0 {
+		return 0, errors.New("pace() requires a positive rate per minute")
+	}
+
+	end := state.IterationStart.Add(time.Duration(float64(time.Minute) / ratePerMinute))
+	d := time.Until(end)
+	if d <= 0 {
+		return 0, nil
+	}
+	return sleepFor(ctx, d), nil
+}
+
+// SleepNormal sleeps for a normally distributed number of seconds, with the given mean and
+// standard deviation. Negative values are cut off at 0. It returns the number of seconds slept.
+func (*K6) SleepNormal(ctx context.Context, mean, stddev float64) (float64, error) {
+	if mean < 0 || stddev < 0 {
+		return 0, errors.New("sleepNormal() requires a non-negative mean and standard deviation")
+	}
+	return sleepSecs(ctx, mean+standardNormal(ctx)*stddev), nil
+}
+
+// SleepExponential sleeps for an exponentially distributed number of seconds with the given
+// mean, like the time between the arrivals of independent users. It returns the number of
+// seconds slept.
+func (*K6) SleepExponential(ctx context.Context, mean float64) (float64, error) {
+	if mean < 0 {
+		return 0, errors.New("sleepExponential() requires a non-negative mean")
+	}
+	return sleepSecs(ctx, -mean*math.Log(1-random(ctx))), nil
+}
+
+// SleepLogNormal sleeps for a log-normally distributed number of seconds, with the given mean
+// and standard deviation of the sleep itself rather than of its logarithm. Real think times are
+// usually skewed like this: mostly short, with a long tail. It returns the number of seconds slept.
+func (*K6) SleepLogNormal(ctx context.Context, mean, stddev float64) (float64, error) {
+	if mean <= 0 || stddev < 0 {
+		return 0, errors.New("sleepLogNormal() requires a positive mean and a non-negative standard deviation")
+	}
+	sigma2 := math.Log(1 + (stddev*stddev)/(mean*mean))
+	mu := math.Log(mean) - sigma2/2
+	return sleepSecs(ctx, math.Exp(mu+standardNormal(ctx)*math.Sqrt(sigma2))), nil
+}
+
+// random returns a number in [0, 1) from the runtime's Math.random(), so that it follows
+// randomSeed().
+func random(ctx context.Context) float64 {
+	rt := common.GetRuntime(ctx)
+	fn, _ := goja.AssertFunction(rt.Get("Math").ToObject(rt).Get("random"))
+	v, err := fn(goja.Undefined())
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return v.ToFloat()
+}
+
+// standardNormal returns a number from the standard normal distribution, using the Box-Muller
+// transform. 1-u is never 0, so the logarithm is always finite.
+func standardNormal(ctx context.Context) float64 {
+	u1, u2 := random(ctx), random(ctx)
+	return math.Sqrt(-2*math.Log(1-u1)) * math.Cos(2*math.Pi*u2)
+}
+
+func sleepSecs(ctx context.Context, secs float64) float64 {
+	if secs <= 0 {
+		return 0
+	}
+	return sleepFor(ctx, time.Duration(secs*float64(time.Second)))
+}
+
+// sleepFor sleeps for d or until the context is done, and returns the number of seconds slept.
+func sleepFor(ctx context.Context, d time.Duration) float64 {
+	start := time.Now()
+	timer := time.NewTimer(d)
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		timer.Stop()
+	}
+	return time.Since(start).Seconds()
+}
@@ -0,0 +1,127 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package k6
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPace(t *testing.T) {
+	rt := goja.New()
+	ctx := new(context.Context)
+	*ctx = common.WithRuntime(context.Background(), rt)
+	rt.Set("k6", common.Bind(rt, New(), ctx))
+
+	t.Run("InitContext", func(t *testing.T) {
+		_, err := common.RunString(rt, `k6.pace(60)`)
+		assert.Contains(t, err.Error(), ErrPaceInInitContext.Error())
+	})
+
+	state := &common.State{}
+	*ctx = common.WithState(common.WithRuntime(context.Background(), rt), state)
+
+	t.Run("Wait", func(t *testing.T) {
+		// Only assert what doesn't depend on scheduling: compiling the script can take a good part
+		// of the remaining 100ms, eg. with the race detector, and the sleep can overrun.
+		startTime := time.Now()
+		state.IterationStart = startTime.Add(-100 * time.Millisecond)
+		v, err := common.RunString(rt, `k6.pace(300)`) // One iteration every 200ms.
+		require.NoError(t, err)
+		assert.True(t, v.ToFloat() > 0)
+		assert.True(t, v.ToFloat() <= time.Since(startTime).Seconds())
+		assert.True(t, time.Since(state.IterationStart) >= 200*time.Millisecond)
+	})
+	t.Run("Late", func(t *testing.T) {
+		state.IterationStart = time.Now().Add(-2 * time.Second)
+		v, err := common.RunString(rt, `k6.pace(60)`)
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, v.ToFloat())
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, `k6.pace(0)`)
+		assert.EqualError(t, err, "GoError: pace() requires a positive rate per minute")
+	})
+}
+
+func TestSleepDistributions(t *testing.T) {
+	rt := goja.New()
+	ctx := common.WithRuntime(context.Background(), rt)
+	rt.Set("k6", common.Bind(rt, New(), &ctx))
+
+	t.Run("Sleep", func(t *testing.T) {
+		for _, code := range []string{
+			`k6.sleepNormal(0.05, 0.01)`,
+			`k6.sleepExponential(0.05)`,
+			`k6.sleepLogNormal(0.05, 0.01)`,
+		} {
+			startTime := time.Now()
+			v, err := common.RunString(rt, code)
+			require.NoError(t, err, code)
+			assert.True(t, v.ToFloat() > 0, code)
+			assert.True(t, v.ToFloat() <= time.Since(startTime).Seconds(), code)
+		}
+	})
+
+	t.Run("Seed", func(t *testing.T) {
+		v1, err := common.RunString(rt, `k6.randomSeed(1); k6.sleepNormal(0.01, 0.005)`)
+		require.NoError(t, err)
+		v2, err := common.RunString(rt, `k6.randomSeed(1); k6.sleepNormal(0.01, 0.005)`)
+		require.NoError(t, err)
+		assert.InDelta(t, v1.ToFloat(), v2.ToFloat(), 0.005)
+	})
+
+	t.Run("StandardNormal", func(t *testing.T) {
+		_, err := common.RunString(rt, `k6.randomSeed(12345)`)
+		require.NoError(t, err)
+
+		const n = 20000
+		var sum, sumSq float64
+		for i := 0; i < n; i++ {
+			z := standardNormal(ctx)
+			sum += z
+			sumSq += z * z
+		}
+		mean := sum / n
+		assert.InDelta(t, 0, mean, 0.05)
+		assert.InDelta(t, 1, math.Sqrt(sumSq/n-mean*mean), 0.05)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		testdata := map[string]string{
+			`k6.sleepNormal(-1, 1)`:    "GoError: sleepNormal() requires a non-negative mean and standard deviation",
+			`k6.sleepExponential(-1)`:  "GoError: sleepExponential() requires a non-negative mean",
+			`k6.sleepLogNormal(0, 1)`:  "GoError: sleepLogNormal() requires a positive mean and a non-negative standard deviation",
+			`k6.sleepLogNormal(1, -1)`: "GoError: sleepLogNormal() requires a positive mean and a non-negative standard deviation",
+		}
+		for code, msg := range testdata {
+			_, err := common.RunString(rt, code)
+			assert.EqualError(t, err, msg, code)
+		}
+	})
+}
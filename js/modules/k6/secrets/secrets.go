@@ -0,0 +1,76 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secrets
+
+import (
+	"sync"
+
+	"github.com/loadimpact/k6/lib"
+)
+
+// Secrets is the k6/secrets module, which registers values in lib.Secrets, so that they're
+// redacted from the console output, logged errors, the HAR recording and metric tags. It keeps
+// track of them, so that they can be removed once the test is over, see Close.
+type Secrets struct {
+	registry *lib.SecretRegistry
+
+	mu         sync.Mutex
+	registered map[string]int
+}
+
+// New returns a new k6/secrets module.
+func New() *Secrets {
+	return &Secrets{registry: lib.Secrets}
+}
+
+// Close removes the secrets registered through the module from the registry. It's a function
+// rather than a method, so that it isn't exposed to scripts.
+func Close(s *Secrets) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for secret, n := range s.registered {
+		for ; n > 0; n-- {
+			s.registry.Remove(secret)
+		}
+	}
+	s.registered = nil
+}
+
+// Register registers a secret and returns it, so that it can be registered where it's read,
+// eg. register(__ENV.API_TOKEN).
+func (s *Secrets) Register(secret string) (string, error) {
+	if err := s.registry.Add(secret); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.registered == nil {
+		s.registered = make(map[string]int)
+	}
+	s.registered[secret]++
+	return secret, nil
+}
+
+// Redact returns a string with the registered secrets in it replaced.
+func (s *Secrets) Redact(str string) string {
+	return s.registry.Redact(str)
+}
@@ -0,0 +1,63 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecrets(t *testing.T) {
+	registry := &lib.SecretRegistry{}
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := common.WithRuntime(context.Background(), rt)
+	module := &Secrets{registry: registry}
+	rt.Set("secrets", common.Bind(rt, module, &ctx))
+
+	_, err := common.RunString(rt, `
+	let token = secrets.register("t0k3n-value");
+	if (token !== "t0k3n-value") { throw new Error("wrong value: " + token); }
+	let redacted = secrets.redact("Authorization: Bearer " + token);
+	if (redacted !== "Authorization: Bearer [SECRET]") { throw new Error("not redacted: " + redacted); }
+	`)
+	assert.NoError(t, err)
+	assert.Equal(t, "[SECRET]", registry.Redact("t0k3n-value"))
+
+	_, err = common.RunString(rt, `secrets.register("")`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "secrets must be at least 4 characters long")
+	}
+
+	// Secrets registered by another test stay registered when this one's are removed.
+	assert.NoError(t, registry.Add("t0k3n-value"))
+	_, err = common.RunString(rt, `secrets.register("t0k3n-value"); secrets.register("other-value")`)
+	assert.NoError(t, err)
+	Close(module)
+	assert.Equal(t, "[SECRET] other-value", registry.Redact("t0k3n-value other-value"))
+	registry.Remove("t0k3n-value")
+	assert.Equal(t, "t0k3n-value", registry.Redact("t0k3n-value"))
+}
@@ -0,0 +1,334 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	gosmtp "net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// ErrSMTPInInitContext is returned when mail is sent from the init context
+var ErrSMTPInInitContext = common.NewInitContextError("sending mail in the init context is not supported")
+
+// Values for the tls param.
+const (
+	TLSNone     = "none"
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+)
+
+type SMTP struct{}
+
+// A Message is a mail to send.
+type Message struct {
+	From    string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+	Body    string
+	Headers map[string]string
+}
+
+type sendParams struct {
+	tls      string
+	username string
+	password string
+	timeout  time.Duration
+	tags     map[string]string
+}
+
+func New() *SMTP {
+	return &SMTP{}
+}
+
+// Send sends a message through the SMTP server at addr ("host:port"). It throws if the server
+// rejects the message; the returned value is always undefined.
+func (*SMTP) Send(ctx context.Context, addr string, msgV goja.Value, paramsV goja.Value) (goja.Value, error) {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, ErrSMTPInInitContext
+	}
+
+	if goja.IsUndefined(msgV) || goja.IsNull(msgV) {
+		return nil, errors.New("smtp.send needs a message")
+	}
+	msg, err := parseMessage(rt, msgV.ToObject(rt))
+	if err != nil {
+		return nil, err
+	}
+	if msg.From == "" {
+		return nil, errors.New("the message has no sender")
+	}
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		return nil, errors.New("the message has no recipients")
+	}
+
+	params := sendParams{tls: TLSStartTLS, tags: state.Options.RunTags.CloneTags()}
+	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		paramsObj := paramsV.ToObject(rt)
+		for _, k := range paramsObj.Keys() {
+			switch k {
+			case "tls":
+				params.tls = paramsObj.Get(k).String()
+			case "username":
+				params.username = paramsObj.Get(k).String()
+			case "password":
+				params.password = paramsObj.Get(k).String()
+			case "timeout":
+				params.timeout = time.Duration(paramsObj.Get(k).ToFloat() * float64(time.Millisecond))
+			case "tags":
+				tagsV := paramsObj.Get(k)
+				if goja.IsUndefined(tagsV) || goja.IsNull(tagsV) {
+					continue
+				}
+				tagObj := tagsV.ToObject(rt)
+				if tagObj == nil {
+					continue
+				}
+				for _, key := range tagObj.Keys() {
+					params.tags[key] = tagObj.Get(key).String()
+				}
+			}
+		}
+	}
+	switch params.tls {
+	case TLSNone, TLSStartTLS, TLSImplicit:
+	default:
+		return nil, errors.Errorf("invalid tls mode '%s', use: '%s', '%s' or '%s'",
+			params.tls, TLSNone, TLSStartTLS, TLSImplicit)
+	}
+
+	if state.Options.SystemTags["group"] {
+		params.tags["group"] = state.Group.Path
+	}
+
+	start := time.Now()
+	err = send(ctx, addr, msg, &params)
+	end := time.Now()
+
+	if state.Options.SystemTags["error"] && err != nil {
+		params.tags["error"] = err.Error()
+	}
+	sampleTags := stats.IntoSampleTags(&params.tags)
+	stats.PushIfNotCancelled(ctx, state.Samples, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Metric: metrics.SMTPSends, Time: start, Tags: sampleTags, Value: 1},
+			{Metric: metrics.SMTPSendDuration, Time: start, Tags: sampleTags, Value: stats.D(end.Sub(start))},
+		},
+		Tags: sampleTags,
+		Time: start,
+	})
+	return goja.Undefined(), err
+}
+
+func parseMessage(rt *goja.Runtime, obj *goja.Object) (*Message, error) {
+	var msg Message
+	for _, k := range obj.Keys() {
+		v := obj.Get(k)
+		if goja.IsUndefined(v) || goja.IsNull(v) {
+			continue
+		}
+		switch k {
+		case "from":
+			msg.From = v.String()
+		case "to", "cc", "bcc":
+			// Allow a single address as a shorthand for a list of one.
+			var addrs []string
+			if _, ok := v.Export().(string); ok {
+				addrs = []string{v.String()}
+			} else if err := rt.ExportTo(v, &addrs); err != nil {
+				return nil, errors.Wrapf(err, "invalid %s", k)
+			}
+			switch k {
+			case "to":
+				msg.To = addrs
+			case "cc":
+				msg.Cc = addrs
+			case "bcc":
+				msg.Bcc = addrs
+			}
+		case "subject":
+			msg.Subject = v.String()
+		case "body":
+			msg.Body = v.String()
+		case "headers":
+			headersObj := v.ToObject(rt)
+			msg.Headers = make(map[string]string)
+			for _, key := range headersObj.Keys() {
+				value := headersObj.Get(key).String()
+				if err := validateHeader(key, value); err != nil {
+					return nil, err
+				}
+				msg.Headers[key] = value
+			}
+		}
+	}
+	return &msg, nil
+}
+
+// validateHeader returns an error if a custom header could break out of its line: its name
+// has to be printable ASCII without colons, the ftext of RFC 5322, and its value can't contain
+// line breaks.
+func validateHeader(name, value string) error {
+	if name == "" {
+		return errors.New("invalid header: the name is empty")
+	}
+	for _, c := range name {
+		if c < 33 || c > 126 || c == ':' {
+			return errors.Errorf("invalid header name '%s'", name)
+		}
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.Errorf("invalid value for header '%s': it contains a line break", name)
+	}
+	return nil
+}
+
+func send(ctx context.Context, addr string, msg *Message, params *sendParams) error {
+	state := common.GetState(ctx)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	var tlsConfig *tls.Config
+	if state.TLSConfig != nil {
+		tlsConfig = state.TLSConfig.Clone()
+		tlsConfig.NextProtos = nil
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+
+	dialCtx := ctx
+	if params.timeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, params.timeout)
+		defer cancel()
+	}
+	conn, err := state.Dialer.DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	if params.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(params.timeout))
+	}
+	if params.tls == TLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := gosmtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if params.tls == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("the server doesn't support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if params.username != "" {
+		if err := c.Auth(gosmtp.PlainAuth("", params.username, params.password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(msg.From); err != nil {
+		return err
+	}
+	for _, rcpts := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		for _, rcpt := range rcpts {
+			if err := c.Rcpt(rcpt); err != nil {
+				return err
+			}
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMessage(msg, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMessage formats a message as RFC 5322 text. Bcc recipients are left out of the headers.
+func buildMessage(msg *Message, now time.Time) []byte {
+	headers := map[string]string{
+		"From":         msg.From,
+		"Date":         now.Format(time.RFC1123Z),
+		"MIME-Version": "1.0",
+		"Content-Type": "text/plain; charset=UTF-8",
+	}
+	if len(msg.To) > 0 {
+		headers["To"] = strings.Join(msg.To, ", ")
+	}
+	if len(msg.Cc) > 0 {
+		headers["Cc"] = strings.Join(msg.Cc, ", ")
+	}
+	if msg.Subject != "" {
+		headers["Subject"] = mime.QEncoding.Encode("UTF-8", msg.Subject)
+	}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(&buf, "%s: %s\r\n", k, headers[k])
+	}
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.Replace(strings.Replace(msg.Body, "\r\n", "\n", -1), "\n", "\r\n", -1))
+	return buf.Bytes()
+}
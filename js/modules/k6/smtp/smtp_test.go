@@ -0,0 +1,282 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A fakeServer is just enough of an SMTP server to accept mail, without STARTTLS support.
+type fakeServer struct {
+	net.Listener
+
+	mu       sync.Mutex
+	commands []string
+	data     []string
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) { _, _ = conn.Write([]byte(strings.Join(lines, "\r\n") + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+
+		switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+		case "EHLO":
+			reply("250-localhost", "250 AUTH PLAIN")
+		case "AUTH":
+			if line == "AUTH PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00user\x00pass")) {
+				reply("235 OK")
+			} else {
+				reply("535 Bad credentials")
+			}
+		case "MAIL", "RCPT":
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			var data []string
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data = append(data, line)
+			}
+			s.mu.Lock()
+			s.data = append(s.data, strings.Join(data, ""))
+			s.mu.Unlock()
+			reply("250 Queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Unknown command")
+		}
+	}
+}
+
+func (s *fakeServer) reset() ([]string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	commands, data := s.commands, s.data
+	s.commands, s.data = nil, nil
+	return commands, data
+}
+
+func TestSend(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &fakeServer{Listener: l}
+	defer func() { _ = srv.Close() }()
+	go srv.serve()
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &common.State{
+		Group:  root,
+		Dialer: netext.NewDialer(net.Dialer{Timeout: 10 * time.Second}),
+		Options: lib.Options{
+			SystemTags: lib.GetTagSet("group", "error"),
+		},
+		Samples: samples,
+	}
+
+	ctx := context.Background()
+	ctx = common.WithState(ctx, state)
+	ctx = common.WithRuntime(ctx, rt)
+
+	rt.Set("smtp", common.Bind(rt, New(), &ctx))
+	rt.Set("addr", l.Addr().String())
+
+	t.Run("Plain", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		smtp.send(addr, {
+			from: "k6@example.com",
+			to: ["a@example.com"],
+			bcc: ["b@example.com"],
+			subject: "Hi",
+			body: "Hello\nWorld",
+			headers: { "X-Test": "yes" },
+		}, { tls: "none", username: "user", password: "pass", tags: { kind: "welcome" } });
+		`)
+		require.NoError(t, err)
+
+		commands, data := srv.reset()
+		assert.Equal(t, []string{
+			"EHLO localhost",
+			"AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00user\x00pass")),
+			"MAIL FROM:<k6@example.com>",
+			"RCPT TO:<a@example.com>",
+			"RCPT TO:<b@example.com>",
+			"DATA",
+			"QUIT",
+		}, commands)
+		if assert.Len(t, data, 1) {
+			assert.Contains(t, data[0], "From: k6@example.com\r\n")
+			assert.Contains(t, data[0], "To: a@example.com\r\n")
+			assert.Contains(t, data[0], "Subject: Hi\r\n")
+			assert.Contains(t, data[0], "X-Test: yes\r\n")
+			assert.NotContains(t, data[0], "b@example.com")
+			assert.True(t, strings.HasSuffix(data[0], "\r\n\r\nHello\r\nWorld\r\n"), data[0])
+		}
+
+		seen := map[*stats.Metric]bool{}
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				seen[sample.Metric] = true
+				tags := sample.Tags.CloneTags()
+				assert.Equal(t, "welcome", tags["kind"])
+				assert.Equal(t, "", tags["error"])
+			}
+		}
+		assert.True(t, seen[metrics.SMTPSends])
+		assert.True(t, seen[metrics.SMTPSendDuration])
+	})
+	t.Run("Bad credentials", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		smtp.send(addr, { from: "k6@example.com", to: ["a@example.com"] },
+			{ tls: "none", username: "user", password: "wrong" });
+		`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "535")
+		}
+		srv.reset()
+
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				tags := sample.Tags.CloneTags()
+				assert.Contains(t, tags["error"], "535")
+			}
+		}
+	})
+	t.Run("No STARTTLS", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		smtp.send(addr, { from: "k6@example.com", to: ["a@example.com"] });
+		`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "the server doesn't support STARTTLS")
+		}
+		srv.reset()
+		stats.GetBufferedSamples(samples)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		testdata := map[string]string{
+			"no message":    `smtp.send(addr)`,
+			"no sender":     `smtp.send(addr, { to: ["a@example.com"] })`,
+			"no recipients": `smtp.send(addr, { from: "k6@example.com" })`,
+			"bad tls mode":  `smtp.send(addr, { from: "k6@example.com", to: ["a@example.com"] }, { tls: "ssl" })`,
+		}
+		for name, script := range testdata {
+			t.Run(name, func(t *testing.T) {
+				_, err := common.RunString(rt, script)
+				assert.Error(t, err)
+			})
+		}
+	})
+	t.Run("Invalid headers", func(t *testing.T) {
+		lineBreak := "invalid value for header 'X-A': it contains a line break"
+		testdata := map[string]struct{ headers, err string }{
+			"empty name":      {`{ "": "a" }`, "invalid header: the name is empty"},
+			"colon in name":   {`{ "X-A: b": "c" }`, "invalid header name 'X-A: b'"},
+			"space in name":   {`{ "X A": "b" }`, "invalid header name 'X A'"},
+			"non-ASCII name":  {`{ "X-Ä": "b" }`, "invalid header name 'X-Ä'"},
+			"newline in name": {`{ "X-A\r\nBcc": "e@example.com" }`, "invalid header name 'X-A\r\nBcc'"},
+			"CRLF in value":   {`{ "X-A": "b\r\nBcc: e@example.com" }`, lineBreak},
+			"LF in value":     {`{ "X-A": "b\nBcc: e@example.com" }`, lineBreak},
+			"CR in value":     {`{ "X-A": "b\rBcc: e@example.com" }`, lineBreak},
+		}
+		for name, data := range testdata {
+			t.Run(name, func(t *testing.T) {
+				_, err := common.RunString(rt,
+					`smtp.send(addr, { from: "k6@example.com", to: ["a@example.com"], headers: `+data.headers+` })`)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), data.err)
+			})
+		}
+		commands, _ := srv.reset()
+		assert.Empty(t, commands)
+	})
+}
+
+func TestBuildMessage(t *testing.T) {
+	now := time.Date(2018, 8, 1, 12, 0, 0, 0, time.UTC)
+	msg := buildMessage(&Message{
+		From:    "k6@example.com",
+		To:      []string{"a@example.com", "b@example.com"},
+		Cc:      []string{"c@example.com"},
+		Bcc:     []string{"d@example.com"},
+		Subject: "Hällo",
+		Body:    "line 1\nline 2",
+		Headers: map[string]string{"Content-Type": "text/html"},
+	}, now)
+	assert.Equal(t, "Cc: c@example.com\r\n"+
+		"Content-Type: text/html\r\n"+
+		"Date: Wed, 01 Aug 2018 12:00:00 +0000\r\n"+
+		"From: k6@example.com\r\n"+
+		"MIME-Version: 1.0\r\n"+
+		"Subject: =?UTF-8?q?H=C3=A4llo?=\r\n"+
+		"To: a@example.com, b@example.com\r\n"+
+		"\r\n"+
+		"line 1\r\nline 2", string(msg))
+}
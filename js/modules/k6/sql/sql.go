@@ -0,0 +1,294 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sql
+
+import (
+	"context"
+	gosql "database/sql"
+	"database/sql/driver"
+	"net"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// ErrSQLInInitContext is returned when opening a database from the init context
+var ErrSQLInInitContext = common.NewInitContextError("using databases in the init context is not supported")
+
+// mysqlNet is the network that the MySQL driver makes TCP connections over, through the VU's dialer.
+const mysqlNet = "k6-tcp"
+
+// connectors make connectors for the supported drivers from their data source names.
+var connectors = map[string]func(dsn string) (driver.Connector, error){
+	"postgres": func(dsn string) (driver.Connector, error) {
+		c, err := pq.NewConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		c.Dialer(vuDialer{})
+		return c, nil
+	},
+	"mysql": func(dsn string) (driver.Connector, error) {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Net == "tcp" {
+			cfg.Net = mysqlNet
+		}
+		return mysql.NewConnector(cfg)
+	},
+}
+
+func init() {
+	mysql.RegisterDialContext(mysqlNet, func(ctx context.Context, addr string) (net.Conn, error) {
+		return vuDialer{}.DialContext(ctx, "tcp", addr)
+	})
+}
+
+// vuDialer connects with the dialer of the VU that's running the query, so that the hosts,
+// blacklistIPs, allowedHosts and blockedHosts options apply to databases as well.
+type vuDialer struct{}
+
+func (vuDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, ErrSQLInInitContext
+	}
+	return state.Dialer.DialContext(ctx, network, addr)
+}
+
+func (d vuDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d vuDialer) DialTimeout(network, addr string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, addr)
+}
+
+type SQL struct{}
+
+type openParams struct {
+	timeout time.Duration
+	tags    map[string]string
+}
+
+// DB is a database opened by sql.open(); it's only valid inside the callback.
+type DB struct {
+	ctx     context.Context
+	rt      *goja.Runtime
+	state   *common.State
+	timeout time.Duration
+	tags    map[string]string
+	db      *gosql.DB
+}
+
+func New() *SQL {
+	return &SQL{}
+}
+
+// Open connects to a database with the given driver, "postgres" or "mysql", and data source name,
+// passes it to the given function, and closes it when the function returns. It returns whatever
+// the function returns.
+func (*SQL) Open(ctx context.Context, driverName, dsn string, args ...goja.Value) (goja.Value, error) {
+	rt := common.GetRuntime(ctx)
+	state := common.GetState(ctx)
+	if state == nil {
+		return nil, ErrSQLInInitContext
+	}
+
+	// The params argument is optional
+	var callableV, paramsV goja.Value
+	switch len(args) {
+	case 2:
+		paramsV = args[0]
+		callableV = args[1]
+	case 1:
+		paramsV = goja.Undefined()
+		callableV = args[0]
+	default:
+		return nil, errors.New("invalid number of arguments to sql.open")
+	}
+
+	fn, isFunc := goja.AssertFunction(callableV)
+	if !isFunc {
+		return nil, errors.New("last argument to sql.open must be a function")
+	}
+
+	params := openParams{tags: state.Options.RunTags.CloneTags()}
+	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		paramsObj := paramsV.ToObject(rt)
+		for _, k := range paramsObj.Keys() {
+			switch k {
+			case "timeout":
+				params.timeout = time.Duration(paramsObj.Get(k).ToFloat() * float64(time.Millisecond))
+			case "tags":
+				tagsV := paramsObj.Get(k)
+				if goja.IsUndefined(tagsV) || goja.IsNull(tagsV) {
+					continue
+				}
+				tagObj := tagsV.ToObject(rt)
+				if tagObj == nil {
+					continue
+				}
+				for _, key := range tagObj.Keys() {
+					params.tags[key] = tagObj.Get(key).String()
+				}
+			}
+		}
+	}
+	if state.Options.SystemTags["group"] {
+		params.tags["group"] = state.Group.Path
+	}
+
+	newConnector, ok := connectors[driverName]
+	if !ok {
+		return nil, errors.Errorf("unsupported database driver '%s', use: 'postgres' or 'mysql'", driverName)
+	}
+	connector, err := newConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// A VU runs one query at a time, and the rows are read before a query returns, so a single
+	// connection is all it ever needs.
+	db := gosql.OpenDB(connector)
+	db.SetMaxOpenConns(1)
+	defer func() { _ = db.Close() }()
+
+	d := &DB{ctx: ctx, rt: rt, state: state, timeout: params.timeout, tags: params.tags, db: db}
+	if err := d.withTimeout(func(ctx context.Context) error { return db.PingContext(ctx) }); err != nil {
+		return nil, err
+	}
+	return fn(goja.Undefined(), rt.ToValue(d))
+}
+
+// Exec runs a statement that doesn't return rows, with the given arguments for its placeholders,
+// and returns the number of rows it affected.
+func (d *DB) Exec(query string, args ...goja.Value) (int64, error) {
+	var affected int64
+	err := d.request("exec", func(ctx context.Context) error {
+		res, err := d.db.ExecContext(ctx, query, exportArgs(args)...)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	return affected, err
+}
+
+// Query runs a query with the given arguments for its placeholders, and returns its rows as
+// objects that map column names to values.
+func (d *DB) Query(query string, args ...goja.Value) ([]map[string]interface{}, error) {
+	var result []map[string]interface{}
+	err := d.request("query", func(ctx context.Context) error {
+		rows, err := d.db.QueryContext(ctx, query, exportArgs(args)...)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rows.Close() }()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		result = make([]map[string]interface{}, 0)
+		for rows.Next() {
+			values := make([]interface{}, len(columns))
+			ptrs := make([]interface{}, len(columns))
+			for i := range values {
+				ptrs[i] = &values[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				return err
+			}
+			row := make(map[string]interface{}, len(columns))
+			for i, name := range columns {
+				// Text is returned as bytes by some drivers, and MySQL returns most values as text.
+				if b, ok := values[i].([]byte); ok {
+					row[name] = string(b)
+				} else {
+					row[name] = values[i]
+				}
+			}
+			result = append(result, row)
+		}
+		return rows.Err()
+	})
+	return result, err
+}
+
+// withTimeout calls fn with the VU's context, limited to the timeout if there is one.
+func (d *DB) withTimeout(fn func(ctx context.Context) error) error {
+	ctx := d.ctx
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+// request times an operation, and emits its metrics.
+func (d *DB) request(operation string, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	err := d.withTimeout(fn)
+	end := time.Now()
+
+	tags := make(map[string]string, len(d.tags)+2)
+	for k, v := range d.tags {
+		tags[k] = v
+	}
+	tags["operation"] = operation
+	if d.state.Options.SystemTags["error"] && err != nil {
+		tags["error"] = err.Error()
+	}
+	sampleTags := stats.IntoSampleTags(&tags)
+	stats.PushIfNotCancelled(d.ctx, d.state.Samples, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Metric: metrics.SQLQueries, Time: start, Tags: sampleTags, Value: 1},
+			{Metric: metrics.SQLQueryDuration, Time: start, Tags: sampleTags, Value: stats.D(end.Sub(start))},
+		},
+		Tags: sampleTags,
+		Time: start,
+	})
+	return err
+}
+
+// exportArgs returns the arguments for a query's placeholders as Go values.
+func exportArgs(args []goja.Value) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if arg != nil {
+			values[i] = arg.Export()
+		}
+	}
+	return values
+}
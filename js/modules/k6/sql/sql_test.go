@@ -0,0 +1,245 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A fakeDB is just enough of a database to test against: one table of accounts, with an id and a
+// name, that "INSERT" adds a row to, "DELETE" empties and "SELECT" returns, optionally only the
+// rows with an id over the argument. "SLEEP" waits for its context to be done.
+type fakeDB struct {
+	mu       sync.Mutex
+	accounts [][]driver.Value
+	conns    int
+}
+
+func (db *fakeDB) Connect(ctx context.Context) (driver.Conn, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.conns++
+	return &fakeConn{db}, nil
+}
+
+func (db *fakeDB) Driver() driver.Driver { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	switch {
+	case strings.HasPrefix(query, "INSERT"):
+		row := make([]driver.Value, len(args))
+		for i, arg := range args {
+			row[i] = arg.Value
+		}
+		c.db.accounts = append(c.db.accounts, row)
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(query, "DELETE"):
+		n := len(c.db.accounts)
+		c.db.accounts = nil
+		return driver.RowsAffected(n), nil
+	}
+	return nil, errors.Errorf("syntax error at '%s'", query)
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.HasPrefix(query, "SLEEP"):
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case strings.HasPrefix(query, "SELECT"):
+		c.db.mu.Lock()
+		defer c.db.mu.Unlock()
+		rows := &fakeRows{}
+		for _, row := range c.db.accounts {
+			if len(args) == 0 || row[0].(int64) > args[0].Value.(int64) {
+				rows.rows = append(rows.rows, row)
+			}
+		}
+		return rows, nil
+	}
+	return nil, errors.Errorf("syntax error at '%s'", query)
+}
+
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string { return []string{"id", "name"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	dest[0] = r.rows[0][0]
+	dest[1] = []byte(r.rows[0][1].(string))
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestOpen(t *testing.T) {
+	db := &fakeDB{}
+	connectors["fake"] = func(dsn string) (driver.Connector, error) {
+		if dsn != "k6" {
+			return nil, errors.Errorf("unknown database '%s'", dsn)
+		}
+		return db, nil
+	}
+	defer delete(connectors, "fake")
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &common.State{
+		Group:  root,
+		Dialer: netext.NewDialer(net.Dialer{Timeout: 10 * time.Second}),
+		Options: lib.Options{
+			SystemTags: lib.GetTagSet("group"),
+		},
+		Samples: samples,
+	}
+
+	ctx := context.Background()
+	ctx = common.WithState(ctx, state)
+	ctx = common.WithRuntime(ctx, rt)
+
+	rt.Set("sql", common.Bind(rt, New(), &ctx))
+
+	t.Run("Query", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		sql.open("fake", "k6", function(db) {
+			if (db.exec("INSERT INTO accounts VALUES (?, ?)", 1, "alice") !== 1) {
+				throw new Error("Wrong rows affected");
+			}
+			db.exec("INSERT INTO accounts VALUES (?, ?)", 2, "bob");
+
+			let rows = db.query("SELECT id, name FROM accounts WHERE id > ?", 1);
+			if (rows.length !== 1 || rows[0].id !== 2 || rows[0].name !== "bob") {
+				throw new Error("Wrong rows: " + JSON.stringify(rows));
+			}
+			if (db.query("SELECT id, name FROM accounts WHERE id > ?", 2).length !== 0) {
+				throw new Error("Expected no rows");
+			}
+			if (db.exec("DELETE FROM accounts") !== 2) {
+				throw new Error("Wrong rows affected");
+			}
+		});`)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, db.conns)
+
+		var queries []string
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				if sample.Metric == metrics.SQLQueries {
+					op, _ := sample.Tags.Get("operation")
+					queries = append(queries, op)
+					group, _ := sample.Tags.Get("group")
+					assert.Equal(t, "", group)
+				}
+			}
+		}
+		assert.Equal(t, []string{"exec", "exec", "query", "query", "exec"}, queries)
+	})
+
+	t.Run("Return", func(t *testing.T) {
+		v, err := common.RunString(rt, `sql.open("fake", "k6", function(db) { return "hello"; })`)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", v.Export())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		sql.open("fake", "k6", function(db) {
+			db.exec("UPDATE accounts");
+		});`)
+		assert.EqualError(t, err, "GoError: syntax error at 'UPDATE accounts'")
+
+		_, err = common.RunString(rt, `sql.open("fake", "nope", function(db) {});`)
+		assert.EqualError(t, err, "GoError: unknown database 'nope'")
+
+		_, err = common.RunString(rt, `sql.open("sqlite", "k6", function(db) {});`)
+		assert.EqualError(t, err, "GoError: unsupported database driver 'sqlite', use: 'postgres' or 'mysql'")
+
+		_, err = common.RunString(rt, `sql.open("fake", "k6", {});`)
+		assert.EqualError(t, err, "GoError: last argument to sql.open must be a function")
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		sql.open("fake", "k6", { timeout: 50 }, function(db) {
+			db.query("SLEEP");
+		});`)
+		assert.EqualError(t, err, "GoError: context deadline exceeded")
+	})
+
+	t.Run("Dialer", func(t *testing.T) {
+		// The drivers connect through the VU's dialer, so the blacklist applies to them.
+		_, ipNet, err := net.ParseCIDR("127.0.0.0/8")
+		require.NoError(t, err)
+		state.Dialer.Blacklist = []*net.IPNet{ipNet}
+		defer func() { state.Dialer.Blacklist = nil }()
+
+		_, err = common.RunString(rt, `
+		sql.open("postgres", "postgres://k6@127.0.0.1:5432/k6?sslmode=disable", function(db) {});`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "IP (127.0.0.1) is in a blacklisted range (127.0.0.0/8)")
+		}
+
+		_, err = common.RunString(rt, `sql.open("mysql", "k6@tcp(127.0.0.1:3306)/k6", function(db) {});`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "IP (127.0.0.1) is in a blacklisted range (127.0.0.0/8)")
+		}
+	})
+
+	t.Run("InitContext", func(t *testing.T) {
+		initCtx := common.WithRuntime(context.Background(), rt)
+		rt.Set("initSQL", common.Bind(rt, New(), &initCtx))
+		_, err := common.RunString(rt, `initSQL.open("fake", "k6", function(db) {});`)
+		assert.Contains(t, err.Error(), "using databases in the init context is not supported")
+	})
+}
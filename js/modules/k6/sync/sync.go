@@ -0,0 +1,255 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// ErrSyncInInitContext is returned when waiting on a primitive in the init context, which
+// would block the initialization of the other VUs.
+var ErrSyncInInitContext = common.NewInitContextError("Synchronization primitives can't be used in the init context")
+
+// timeoutFrom converts an optional timeout in milliseconds to a channel that fires when it
+// expires; without a timeout, the channel is nil and never fires.
+func timeoutFrom(v goja.Value) (<-chan time.Time, func()) {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) || v.ToFloat() <= 0 {
+		return nil, func() {}
+	}
+	timer := time.NewTimer(time.Duration(v.ToFloat() * float64(time.Millisecond)))
+	return timer.C, func() { timer.Stop() }
+}
+
+// A barrier is a cyclic barrier shared between all VUs: waiting VUs are released together once
+// the given number of them have arrived, after which the barrier can be used again.
+type barrier struct {
+	mu      sync.Mutex
+	parties int64
+	waiting int64
+	release chan struct{}
+}
+
+// Barrier is the JS handle to a barrier.
+type Barrier struct {
+	b *barrier
+}
+
+// Wait blocks until enough VUs are waiting for the barrier, returning true, or until the
+// timeout in milliseconds expires or the test ends, returning false.
+func (b Barrier) Wait(ctx context.Context, timeout goja.Value) (bool, error) {
+	if common.GetState(ctx) == nil {
+		return false, ErrSyncInInitContext
+	}
+
+	b.b.mu.Lock()
+	release := b.b.release
+	b.b.waiting++
+	if b.b.waiting >= b.b.parties {
+		close(release)
+		b.b.release = make(chan struct{})
+		b.b.waiting = 0
+		b.b.mu.Unlock()
+		return true, nil
+	}
+	b.b.mu.Unlock()
+
+	expired, stop := timeoutFrom(timeout)
+	defer stop()
+	select {
+	case <-release:
+		return true, nil
+	case <-expired:
+	case <-ctx.Done():
+	}
+
+	// Give up our place, unless the barrier was tripped in the meantime.
+	b.b.mu.Lock()
+	defer b.b.mu.Unlock()
+	select {
+	case <-release:
+		return true, nil
+	default:
+		b.b.waiting--
+		return false, nil
+	}
+}
+
+// Waiting returns the number of VUs currently waiting for the barrier.
+func (b Barrier) Waiting() int64 {
+	b.b.mu.Lock()
+	defer b.b.mu.Unlock()
+	return b.b.waiting
+}
+
+// A semaphore limits how many VUs can hold one of its permits at the same time.
+type semaphore struct {
+	permits chan struct{}
+}
+
+// Semaphore is the JS handle to a semaphore.
+type Semaphore struct {
+	s *semaphore
+}
+
+// Acquire blocks until a permit is available, returning true, or until the timeout in
+// milliseconds expires or the test ends, returning false.
+func (s Semaphore) Acquire(ctx context.Context, timeout goja.Value) (bool, error) {
+	if common.GetState(ctx) == nil {
+		return false, ErrSyncInInitContext
+	}
+
+	expired, stop := timeoutFrom(timeout)
+	defer stop()
+	select {
+	case s.s.permits <- struct{}{}:
+		return true, nil
+	case <-expired:
+		return false, nil
+	case <-ctx.Done():
+		return false, nil
+	}
+}
+
+// TryAcquire takes a permit if one is available right away.
+func (s Semaphore) TryAcquire(ctx context.Context) (bool, error) {
+	if common.GetState(ctx) == nil {
+		return false, ErrSyncInInitContext
+	}
+
+	select {
+	case s.s.permits <- struct{}{}:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// Release gives back a permit.
+func (s Semaphore) Release() error {
+	select {
+	case <-s.s.permits:
+		return nil
+	default:
+		return errors.New("semaphore released more times than it was acquired")
+	}
+}
+
+// Available returns the number of permits that are currently free.
+func (s Semaphore) Available() int {
+	return cap(s.s.permits) - len(s.s.permits)
+}
+
+// Sync is the k6/sync module. Primitives are identified by name, and shared between all VUs
+// of the test that declare one with the same name. They're kept in memory, so they aren't shared
+// with the other instances of a distributed test.
+type Sync struct {
+	mu         sync.Mutex
+	barriers   map[string]*barrier
+	semaphores map[string]*semaphore
+	onces      map[string]chan struct{}
+}
+
+// New returns a new k6/sync module.
+func New() *Sync {
+	return &Sync{
+		barriers:   make(map[string]*barrier),
+		semaphores: make(map[string]*semaphore),
+		onces:      make(map[string]chan struct{}),
+	}
+}
+
+// XBarrier declares a barrier that releases waiting VUs once the given number have arrived.
+func (s *Sync) XBarrier(ctxPtr *context.Context, name string, parties int64) (interface{}, error) {
+	if common.GetState(*ctxPtr) != nil {
+		return nil, errors.New("barriers must be declared in the init context")
+	}
+	if parties < 1 {
+		return nil, errors.Errorf("barrier '%s' needs at least one party", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.barriers[name]
+	if !ok {
+		b = &barrier{parties: parties, release: make(chan struct{})}
+		s.barriers[name] = b
+	} else if b.parties != parties {
+		return nil, errors.Errorf("barrier '%s' was already declared with %d parties", name, b.parties)
+	}
+	return common.Bind(common.GetRuntime(*ctxPtr), Barrier{b}, ctxPtr), nil
+}
+
+// XSemaphore declares a semaphore with the given number of permits.
+func (s *Sync) XSemaphore(ctxPtr *context.Context, name string, permits int64) (interface{}, error) {
+	if common.GetState(*ctxPtr) != nil {
+		return nil, errors.New("semaphores must be declared in the init context")
+	}
+	if permits < 1 {
+		return nil, errors.Errorf("semaphore '%s' needs at least one permit", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sem, ok := s.semaphores[name]
+	if !ok {
+		sem = &semaphore{permits: make(chan struct{}, permits)}
+		s.semaphores[name] = sem
+	} else if int64(cap(sem.permits)) != permits {
+		return nil, errors.Errorf("semaphore '%s' was already declared with %d permits", name, cap(sem.permits))
+	}
+	return common.Bind(common.GetRuntime(*ctxPtr), Semaphore{sem}, ctxPtr), nil
+}
+
+// Once calls fn if no VU has called once() with the same name before, and returns whether it
+// did. VUs calling it while fn is running wait for it to finish, or for the test to end.
+func (s *Sync) Once(ctx context.Context, name string, fn goja.Callable) (bool, error) {
+	if common.GetState(ctx) == nil {
+		return false, ErrSyncInInitContext
+	}
+
+	s.mu.Lock()
+	done, ok := s.onces[name]
+	if !ok {
+		done = make(chan struct{})
+		s.onces[name] = done
+	}
+	s.mu.Unlock()
+
+	if ok {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		return false, nil
+	}
+
+	defer close(done)
+	if _, err := fn(goja.Undefined()); err != nil {
+		return true, err
+	}
+	return true, nil
+}
@@ -0,0 +1,183 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sync
+
+import (
+	"context"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newVUs returns n runtimes with the module bound that have run the init code, and a function
+// to leave the init context with the given parent context.
+func newVUs(t *testing.T, n int, init string) ([]*goja.Runtime, func(context.Context)) {
+	module := New()
+	var rts []*goja.Runtime
+	var ctxPtrs []*context.Context
+	for i := 0; i < n; i++ {
+		rt := goja.New()
+		rt.SetFieldNameMapper(common.FieldNameMapper{})
+		ctxPtr := new(context.Context)
+		*ctxPtr = common.WithRuntime(context.Background(), rt)
+		rt.Set("sync", common.Bind(rt, module, ctxPtr))
+		_, err := common.RunString(rt, init)
+		require.NoError(t, err)
+		rts = append(rts, rt)
+		ctxPtrs = append(ctxPtrs, ctxPtr)
+	}
+	return rts, func(ctx context.Context) {
+		for i, ctxPtr := range ctxPtrs {
+			*ctxPtr = common.WithState(common.WithRuntime(ctx, rts[i]), &common.State{Vu: int64(i + 1)})
+		}
+	}
+}
+
+// runAll runs the script in every runtime concurrently, returning the results in order.
+func runAll(t *testing.T, rts []*goja.Runtime, script string) []goja.Value {
+	results := make([]goja.Value, len(rts))
+	var wg gosync.WaitGroup
+	for i, rt := range rts {
+		wg.Add(1)
+		go func(i int, rt *goja.Runtime) {
+			defer wg.Done()
+			v, err := common.RunString(rt, script)
+			assert.NoError(t, err)
+			results[i] = v
+		}(i, rt)
+	}
+	wg.Wait()
+	return results
+}
+
+func TestBarrier(t *testing.T) {
+	rts, start := newVUs(t, 3, `let b = new sync.Barrier("login", 3);`)
+
+	_, err := common.RunString(rts[0], `b.wait()`)
+	assert.Contains(t, err.Error(), "Synchronization primitives can't be used in the init context")
+
+	start(context.Background())
+	for i := 0; i < 2; i++ {
+		for _, v := range runAll(t, rts, `b.wait(5000)`) {
+			assert.True(t, v.ToBoolean())
+		}
+	}
+
+	v, err := common.RunString(rts[0], `b.wait(50)`)
+	require.NoError(t, err)
+	assert.False(t, v.ToBoolean())
+	v, err = common.RunString(rts[0], `b.waiting()`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), v.ToInteger(), "a timed out VU gives up its place")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start(ctx)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	v, err = common.RunString(rts[0], `b.wait()`)
+	require.NoError(t, err)
+	assert.False(t, v.ToBoolean())
+
+	t.Run("Redeclared", func(t *testing.T) {
+		rts, _ := newVUs(t, 1, `let b = new sync.Barrier("login", 3);`)
+		_, err := common.RunString(rts[0], `new sync.Barrier("login", 4)`)
+		assert.Contains(t, err.Error(), "barrier 'login' was already declared with 3 parties")
+		_, err = common.RunString(rts[0], `new sync.Barrier("other", 0)`)
+		assert.Contains(t, err.Error(), "barrier 'other' needs at least one party")
+	})
+}
+
+func TestSemaphore(t *testing.T) {
+	rts, start := newVUs(t, 4, `let s = new sync.Semaphore("checkout", 2);`)
+	start(context.Background())
+
+	var lock gosync.Mutex
+	var current, max int64
+	for _, rt := range rts {
+		rt.Set("enter", func() {
+			lock.Lock()
+			current++
+			if current > max {
+				max = current
+			}
+			lock.Unlock()
+			time.Sleep(100 * time.Millisecond)
+			lock.Lock()
+			current--
+			lock.Unlock()
+		})
+	}
+	runAll(t, rts, `for (let i = 0; i < 3; i++) { s.acquire(); try { enter(); } finally { s.release(); } }`)
+	assert.Equal(t, int64(2), max)
+
+	v, err := common.RunString(rts[0], `[s.tryAcquire(), s.tryAcquire(), s.tryAcquire(), s.acquire(10), s.available()]`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{true, true, false, false, int64(0)}, v.Export())
+
+	_, err = common.RunString(rts[0], `s.release(); s.release(); s.release()`)
+	assert.Contains(t, err.Error(), "semaphore released more times than it was acquired")
+}
+
+func TestOnce(t *testing.T) {
+	rts, start := newVUs(t, 5, `let calls = 0;`)
+	start(context.Background())
+
+	results := runAll(t, rts, `sync.once("seed", () => { calls++; })`)
+	var ran, calls int
+	for i, v := range results {
+		if v.ToBoolean() {
+			ran++
+		}
+		calls += int(rts[i].Get("calls").ToInteger())
+	}
+	assert.Equal(t, 1, ran)
+	assert.Equal(t, 1, calls)
+
+	_, err := common.RunString(rts[0], `sync.once("fail", () => { throw new Error("oops"); })`)
+	assert.Contains(t, err.Error(), "oops")
+	v, err := common.RunString(rts[1], `sync.once("fail", () => {})`)
+	require.NoError(t, err)
+	assert.False(t, v.ToBoolean())
+
+	t.Run("Interrupted", func(t *testing.T) {
+		rts, start := newVUs(t, 2, ``)
+		release := make(chan struct{})
+		defer close(release)
+		rts[0].Set("block", func() { <-release })
+		ctx, cancel := context.WithCancel(context.Background())
+		start(ctx)
+		go func() { _, _ = common.RunString(rts[0], `sync.once("slow", block)`) }()
+		time.Sleep(50 * time.Millisecond)
+
+		// A VU waiting for fn can be stopped while it's still running.
+		time.AfterFunc(50*time.Millisecond, cancel)
+		v, err := common.RunString(rts[1], `sync.once("slow", () => {})`)
+		require.NoError(t, err)
+		assert.False(t, v.ToBoolean())
+	})
+}
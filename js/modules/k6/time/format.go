@@ -0,0 +1,102 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package time
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The strftime directives that map directly to a Go layout.
+var strftimeLayouts = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'L': ".000",
+	'f': ".000000",
+	'p': "PM",
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'z': "-0700",
+	'Z': "MST",
+	'F': "2006-01-02",
+	'T': "15:04:05",
+}
+
+// strftime formats a time with the directives of C's strftime that are commonly used: %Y, %y,
+// %m, %d, %e, %H, %I, %M, %S, %p, %a, %A, %b, %B, %z, %Z, %F and %T, %j for the day of the year,
+// %u and %w for the day of the week, %s for the Unix time, %L and %f for milliseconds and
+// microseconds, and %% for a literal "%". Errors don't quote the layout, since goja formats the
+// messages of errors thrown to scripts as format strings.
+func strftime(t time.Time, layout string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			b.WriteByte(layout[i])
+			continue
+		}
+		i++
+		if i == len(layout) {
+			return "", errors.New("invalid layout: it ends in the middle of a directive")
+		}
+
+		switch c := layout[i]; c {
+		case '%':
+			b.WriteByte('%')
+		case 'j':
+			b.WriteString(pad3(t.YearDay()))
+		case 'u':
+			wd := int(t.Weekday())
+			if wd == 0 {
+				wd = 7
+			}
+			b.WriteString(strconv.Itoa(wd))
+		case 'w':
+			b.WriteString(strconv.Itoa(int(t.Weekday())))
+		case 's':
+			b.WriteString(strconv.FormatInt(t.Unix(), 10))
+		default:
+			goLayout, ok := strftimeLayouts[c]
+			if !ok {
+				return "", errors.Errorf("invalid layout: unknown directive '%c' at position %d", c, i)
+			}
+			// Fractions of a second are only formatted with their leading dot in Go layouts.
+			b.WriteString(strings.TrimPrefix(t.Format(goLayout), "."))
+		}
+	}
+	return b.String(), nil
+}
+
+func pad3(n int) string {
+	s := strconv.Itoa(n)
+	return strings.Repeat("0", 3-len(s)) + s
+}
@@ -0,0 +1,200 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package time
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/loadimpact/k6/lib/cron"
+	"github.com/pkg/errors"
+)
+
+// Scripts only use a handful of schedules; the cache is emptied if it grows past this, rather
+// than growing without bound.
+const maxCachedSchedules = 1000
+
+// The point monotonic() counts from. time.Now() carries a monotonic clock reading, which
+// time.Since() uses, so the result doesn't jump if the wall clock is adjusted.
+var start = time.Now()
+
+// Time is the k6/time module.
+type Time struct {
+	mu        sync.RWMutex
+	schedules map[string]*cron.Schedule
+
+	// The clock timers measure with, which tests can replace.
+	now func() time.Time
+}
+
+// New returns a new k6/time module.
+func New() *Time {
+	return &Time{schedules: make(map[string]*cron.Schedule), now: time.Now}
+}
+
+// Now returns the current time in milliseconds since the Unix epoch, like Date.now(), but with
+// microsecond precision.
+func (*Time) Now() float64 {
+	return float64(time.Now().UnixNano()/int64(time.Microsecond)) / 1e3
+}
+
+// Monotonic returns the milliseconds since k6 started, with nanosecond precision. Unlike the
+// wall clock, it never goes back or jumps, so it's what durations should be measured with.
+func (*Time) Monotonic() float64 {
+	return float64(time.Since(start)) / float64(time.Millisecond)
+}
+
+// Timer measures durations on the monotonic clock.
+type Timer struct {
+	now        func() time.Time
+	start, lap time.Time
+}
+
+// XTimer returns a new timer, which starts right away.
+func (m *Time) XTimer(ctxPtr *context.Context) interface{} {
+	now := m.now()
+	return common.Bind(common.GetRuntime(*ctxPtr), &Timer{now: m.now, start: now, lap: now}, ctxPtr)
+}
+
+// Elapsed returns the milliseconds since the timer started.
+func (t *Timer) Elapsed() float64 {
+	return float64(t.now().Sub(t.start)) / float64(time.Millisecond)
+}
+
+// Lap returns the milliseconds since the last lap, or since the timer started for the first
+// one, and starts a new lap.
+func (t *Timer) Lap() float64 {
+	now := t.now()
+	d := now.Sub(t.lap)
+	t.lap = now
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Reset restarts the timer.
+func (t *Timer) Reset() {
+	t.start = t.now()
+	t.lap = t.start
+}
+
+// CronMatches returns whether a date, or the current time if none is given, is in the schedule
+// of a cron expression. The schedule is in the given time zone, or else in the local one.
+func (m *Time) CronMatches(expr string, date goja.Value, tz string) (bool, error) {
+	s, err := m.schedule(expr)
+	if err != nil {
+		return false, err
+	}
+	t, err := toTime(date, tz)
+	if err != nil {
+		return false, err
+	}
+	return s.Matches(t), nil
+}
+
+// CronNext returns the next time in the schedule of a cron expression after a date, or the
+// current time if none is given, in milliseconds since the Unix epoch. The schedule is in the
+// given time zone, or else in the local one. It returns null if there's no such time in the next
+// five years.
+func (m *Time) CronNext(expr string, date goja.Value, tz string) (interface{}, error) {
+	s, err := m.schedule(expr)
+	if err != nil {
+		return nil, err
+	}
+	t, err := toTime(date, tz)
+	if err != nil {
+		return nil, err
+	}
+	next, ok := s.Next(t)
+	if !ok {
+		return nil, nil
+	}
+	return next.UnixNano() / int64(time.Millisecond), nil
+}
+
+// Format formats a date, or the current time if none is given, with a strftime-like layout, in
+// the given time zone, or else in the local one. Without a layout, it's formatted as RFC 3339
+// with milliseconds.
+func (*Time) Format(date goja.Value, layout, tz string) (string, error) {
+	t, err := toTime(date, tz)
+	if err != nil {
+		return "", err
+	}
+	if layout == "" {
+		return t.Format("2006-01-02T15:04:05.000Z07:00"), nil
+	}
+	return strftime(t, layout)
+}
+
+// schedule returns the parsed schedule for a cron expression, parsing and caching it if needed.
+func (m *Time) schedule(expr string) (*cron.Schedule, error) {
+	m.mu.RLock()
+	s, ok := m.schedules[expr]
+	m.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	s, err := cron.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	if len(m.schedules) >= maxCachedSchedules {
+		m.schedules = make(map[string]*cron.Schedule)
+	}
+	m.schedules[expr] = s
+	m.mu.Unlock()
+	return s, nil
+}
+
+// toTime converts a date given to a function, which may be a Date, milliseconds since the Unix
+// epoch or an RFC 3339 string, to a time in a time zone. Without a date, it's the current time.
+func toTime(v goja.Value, tz string) (time.Time, error) {
+	loc := time.Local
+	if tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return time.Time{}, errors.Errorf("unknown time zone '%s'", tz)
+		}
+	}
+
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return time.Now().In(loc), nil
+	}
+	switch date := v.Export().(type) {
+	case time.Time:
+		return date.In(loc), nil
+	case int64:
+		return time.Unix(0, date*int64(time.Millisecond)).In(loc), nil
+	case float64:
+		return time.Unix(0, int64(date*float64(time.Millisecond))).In(loc), nil
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, date)
+		if err != nil {
+			return time.Time{}, errors.Errorf("invalid date '%s', expected an RFC 3339 date", date)
+		}
+		return t.In(loc), nil
+	default:
+		return time.Time{}, errors.Errorf("invalid date '%s', expected a Date, a number or a string", v.String())
+	}
+}
@@ -0,0 +1,174 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package time
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRuntime() *goja.Runtime {
+	return newRuntimeWith(New())
+}
+
+func newRuntimeWith(module *Time) *goja.Runtime {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := common.WithRuntime(context.Background(), rt)
+	rt.Set("time", common.Bind(rt, module, &ctx))
+	return rt
+}
+
+func TestClocks(t *testing.T) {
+	rt := newRuntime()
+
+	before := float64(time.Now().UnixNano()) / 1e6
+	v, err := common.RunString(rt, `time.now()`)
+	require.NoError(t, err)
+	after := float64(time.Now().UnixNano()) / 1e6
+	assert.True(t, v.ToFloat() >= before-0.001 && v.ToFloat() <= after, "%f not in [%f, %f]", v.ToFloat(), before, after)
+
+	_, err = common.RunString(rt, `
+	let a = time.monotonic();
+	let b = time.monotonic();
+	if (!(a > 0 && b >= a)) { throw new Error("monotonic() went back: " + a + ", " + b); }
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTimer(t *testing.T) {
+	// The clock only moves when the test moves it, so the results don't depend on how long
+	// running the scripts takes.
+	now := time.Unix(1000, 0)
+	module := New()
+	module.now = func() time.Time { return now }
+	rt := newRuntimeWith(module)
+	run := func(script string) float64 {
+		v, err := common.RunString(rt, script)
+		require.NoError(t, err)
+		return v.ToFloat()
+	}
+
+	run(`let timer = new time.Timer();`)
+	now = now.Add(20 * time.Millisecond)
+	assert.Equal(t, 20.0, run(`timer.lap()`))
+	now = now.Add(5500 * time.Microsecond)
+	assert.Equal(t, 5.5, run(`timer.lap()`))
+	assert.Equal(t, 0.0, run(`timer.lap()`))
+	assert.Equal(t, 25.5, run(`timer.elapsed()`))
+
+	run(`timer.reset()`)
+	now = now.Add(time.Millisecond)
+	assert.Equal(t, 1.0, run(`timer.elapsed()`))
+	assert.Equal(t, 1.0, run(`timer.lap()`))
+}
+
+func TestCron(t *testing.T) {
+	rt := newRuntime()
+
+	t.Run("Matches", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		if (!time.cronMatches("*/15 9-17 * * MON-FRI", new Date("2018-06-01T09:15:30Z"), "UTC")) {
+			throw new Error("a Date didn't match");
+		}
+		if (time.cronMatches("*/15 9-17 * * MON-FRI", Date.UTC(2018, 5, 2, 9, 15), "UTC")) {
+			throw new Error("a Saturday matched");
+		}
+		if (!time.cronMatches("0 9 * * *", "2018-06-01T09:00:00+02:00", "Europe/Stockholm")) {
+			throw new Error("the time zone wasn't used");
+		}
+		if (time.cronMatches("0 9 * * *", "2018-06-01T09:00:00+02:00", "UTC")) {
+			throw new Error("a string matched in the wrong time zone");
+		}
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Next", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let next = time.cronNext("0 0 29 2 *", Date.UTC(2018, 2, 1), "UTC");
+		if (new Date(next).toISOString() !== "2020-02-29T00:00:00.000Z") {
+			throw new Error("wrong next time: " + new Date(next).toISOString());
+		}
+		if (time.cronNext("0 0 30 2 *") !== null) {
+			throw new Error("a schedule that never matches has a next time");
+		}
+		if (!(time.cronNext("* * * * * *") > Date.now())) {
+			throw new Error("the next time isn't in the future");
+		}
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, `time.cronMatches("* * *")`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "invalid cron expression '* * *': expected 5 or 6 fields, got 3")
+		}
+		_, err = common.RunString(rt, `time.cronNext("* * * * *", null, "Mars/Olympus_Mons")`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "unknown time zone 'Mars/Olympus_Mons'")
+		}
+		_, err = common.RunString(rt, `time.cronMatches("* * * * *", "yesterday")`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "invalid date 'yesterday', expected an RFC 3339 date")
+		}
+	})
+}
+
+func TestFormat(t *testing.T) {
+	rt := newRuntime()
+
+	testdata := map[string]string{
+		`time.format(date, "", "UTC")`:                                   "2018-06-03T04:05:06.789Z",
+		`time.format(date, "%Y-%m-%d %H:%M:%S.%L", "UTC")`:               "2018-06-03 04:05:06.789",
+		`time.format(date, "%F %T%z %Z", "Europe/Stockholm")`:            "2018-06-03 06:05:06+0200 CEST",
+		`time.format(date, "%a %A %b %B %e %y %I%p %j %u %w", "UTC")`:    "Sun Sunday Jun June  3 18 04AM 154 7 0",
+		`time.format(date, "%s %f 100%%", "UTC")`:                        "1527998706 789000 100%",
+		`time.format(date.getTime(), "%T", "America/New_York")`:          "00:05:06",
+		`time.format("2018-06-03T04:05:06.789Z", "%H:%M", "Asia/Tokyo")`: "13:05",
+	}
+	for script, expected := range testdata {
+		t.Run(script, func(t *testing.T) {
+			v, err := common.RunString(rt, `let date = new Date(Date.UTC(2018, 5, 3, 4, 5, 6, 789));`+script)
+			if assert.NoError(t, err) {
+				assert.Equal(t, expected, v.String())
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := common.RunString(rt, `time.format(null, "%Q")`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "invalid layout: unknown directive 'Q' at position 1")
+		}
+		_, err = common.RunString(rt, `time.format(null, "100%")`)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "invalid layout: it ends in the middle of a directive")
+		}
+	})
+}
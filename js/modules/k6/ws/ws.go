@@ -23,7 +23,6 @@ package ws
 import (
 	"context"
 	"crypto/tls"
-	"errors"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -37,6 +36,7 @@ import (
 	"github.com/loadimpact/k6/js/common"
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
 )
 
 // ErrWSInInitContext is returned when websockets are using in the init context
@@ -109,6 +109,7 @@ func (*WS) Connect(ctx context.Context, url string, args ...goja.Value) (*WSHTTP
 	var header http.Header
 
 	tags := state.Options.RunTags.CloneTags()
+	enableCompression := false
 
 	// Parse the optional second argument (params)
 	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
@@ -140,6 +141,18 @@ func (*WS) Connect(ctx context.Context, url string, args ...goja.Value) (*WSHTTP
 				for _, key := range tagObj.Keys() {
 					tags[key] = tagObj.Get(key).String()
 				}
+			case "compression":
+				compressionV := params.Get(k)
+				if goja.IsUndefined(compressionV) || goja.IsNull(compressionV) {
+					continue
+				}
+				switch compression := compressionV.String(); compression {
+				case "deflate":
+					enableCompression = true
+				case "":
+				default:
+					return nil, errors.Errorf("unsupported compression algorithm '%s'", compression)
+				}
 			}
 		}
 
@@ -169,6 +182,8 @@ func (*WS) Connect(ctx context.Context, url string, args ...goja.Value) (*WSHTTP
 		NetDial:         netDial,
 		Proxy:           http.ProxyFromEnvironment,
 		TLSClientConfig: tlsConfig,
+		// Only negotiates permessage-deflate, the server may still refuse it
+		EnableCompression: enableCompression,
 	}
 
 	start := time.Now()
@@ -227,7 +242,7 @@ func (*WS) Connect(ctx context.Context, url string, args ...goja.Value) (*WSHTTP
 	conn.SetPingHandler(func(msg string) error { pingChan <- msg; return nil })
 	conn.SetPongHandler(func(pingID string) error { pongChan <- pingID; return nil })
 
-	readDataChan := make(chan []byte)
+	readDataChan := make(chan message)
 	readCloseChan := make(chan int)
 	readErrChan := make(chan error)
 
@@ -255,7 +270,11 @@ func (*WS) Connect(ctx context.Context, url string, args ...goja.Value) (*WSHTTP
 
 		case readData := <-readDataChan:
 			socket.msgReceivedTimestamps = append(socket.msgReceivedTimestamps, time.Now())
-			socket.handleEvent("message", rt.ToValue(string(readData)))
+			if readData.mtype == websocket.BinaryMessage && len(socket.eventHandlers["binaryMessage"]) > 0 {
+				socket.handleEvent("binaryMessage", rt.ToValue(readData.data))
+			} else {
+				socket.handleEvent("message", rt.ToValue(string(readData.data)))
+			}
 
 		case readErr := <-readErrChan:
 			socket.handleEvent("error", rt.ToValue(readErr))
@@ -340,8 +359,6 @@ func (s *Socket) handleEvent(event string, args ...goja.Value) {
 }
 
 func (s *Socket) Send(message string) {
-	// NOTE: No binary message support for the time being since goja doesn't
-	// support typed arrays.
 	rt := common.GetRuntime(s.ctx)
 
 	writeData := []byte(message)
@@ -352,6 +369,33 @@ func (s *Socket) Send(message string) {
 	s.msgSentTimestamps = append(s.msgSentTimestamps, time.Now())
 }
 
+// SendBinary sends a binary frame. Since goja doesn't support typed arrays, the
+// data can be given either as a byte slice or as an array of byte values.
+func (s *Socket) SendBinary(data goja.Value) {
+	rt := common.GetRuntime(s.ctx)
+
+	var writeData []byte
+	switch v := data.Export().(type) {
+	case []byte:
+		writeData = v
+	case string:
+		writeData = []byte(v)
+	case []interface{}:
+		writeData = make([]byte, len(v))
+		for i, b := range v {
+			writeData[i] = byte(rt.ToValue(b).ToInteger())
+		}
+	default:
+		common.Throw(rt, errors.Errorf("unsupported binary data type %T", v))
+	}
+
+	if err := s.conn.WriteMessage(websocket.BinaryMessage, writeData); err != nil {
+		s.handleEvent("error", rt.ToValue(err))
+	}
+
+	s.msgSentTimestamps = append(s.msgSentTimestamps, time.Now())
+}
+
 func (s *Socket) Ping() {
 	rt := common.GetRuntime(s.ctx)
 	deadline := time.Now().Add(writeWait)
@@ -451,12 +495,18 @@ func (s *Socket) closeConnection(code int) error {
 	return err
 }
 
+// A single message read from the connection, along with its frame type
+type message struct {
+	mtype int
+	data  []byte
+}
+
 // Wraps conn.ReadMessage in a channel
-func readPump(conn *websocket.Conn, readChan chan []byte, errorChan chan error, closeChan chan int) {
+func readPump(conn *websocket.Conn, readChan chan message, errorChan chan error, closeChan chan int) {
 	defer func() { _ = conn.Close() }()
 
 	for {
-		_, message, err := conn.ReadMessage()
+		mtype, data, err := conn.ReadMessage()
 		if err != nil {
 
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
@@ -471,7 +521,7 @@ func readPump(conn *websocket.Conn, readChan chan []byte, errorChan chan error,
 			return
 		}
 
-		readChan <- message
+		readChan <- message{mtype, data}
 	}
 }
 
@@ -467,3 +467,97 @@ func TestTLSConfig(t *testing.T) {
 	})
 	assertSessionMetricsEmitted(t, stats.GetBufferedSamples(samples), "", url, 101, "")
 }
+
+func TestBinaryAndCompression(t *testing.T) {
+	tb := testutils.NewHTTPMultiBin(t)
+	defer tb.Cleanup()
+
+	root, err := lib.NewGroup("", nil)
+	assert.NoError(t, err)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &common.State{
+		Group:  root,
+		Dialer: tb.Dialer,
+		Options: lib.Options{
+			SystemTags: lib.GetTagSet("url", "proto", "status", "subproto"),
+		},
+		Samples: samples,
+	}
+
+	ctx := context.Background()
+	ctx = common.WithState(ctx, state)
+	ctx = common.WithRuntime(ctx, rt)
+
+	rt.Set("ws", common.Bind(rt, New(), &ctx))
+
+	t.Run("binary", func(t *testing.T) {
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let received = null;
+		let res = ws.connect("ws://HTTPBIN_IP:HTTPBIN_PORT/ws-echo", function(socket){
+			socket.on("open", function() {
+				socket.sendBinary([1, 2, 254, 255]);
+			});
+			socket.on("binaryMessage", function(data) {
+				received = data;
+				socket.close();
+			});
+			socket.on("message", function(data) {
+				throw new Error("unexpected text message: " + data);
+			});
+		});
+		if (received === null) { throw new Error("no binary message received"); }
+		if (received.length !== 4 || received[0] !== 1 || received[3] !== 255) {
+			throw new Error("unexpected binary message: " + received);
+		}
+		`))
+		assert.NoError(t, err)
+		assertSessionMetricsEmitted(t, stats.GetBufferedSamples(samples), "", "ws://"+tb.ServerHTTP.Listener.Addr().String()+"/ws-echo", 101, "")
+	})
+
+	t.Run("binary as text", func(t *testing.T) {
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let received = null;
+		let res = ws.connect("ws://HTTPBIN_IP:HTTPBIN_PORT/ws-echo", function(socket){
+			socket.on("open", function() {
+				socket.sendBinary("hello");
+			});
+			socket.on("message", function(data) {
+				received = data;
+				socket.close();
+			});
+		});
+		if (received !== "hello") { throw new Error("unexpected message: " + received); }
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("compression", func(t *testing.T) {
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		let received = null;
+		let res = ws.connect("ws://HTTPBIN_IP:HTTPBIN_PORT/ws-echo", { compression: "deflate" }, function(socket){
+			socket.on("open", function() {
+				socket.send("compressed");
+			});
+			socket.on("message", function(data) {
+				received = data;
+				socket.close();
+			});
+		});
+		if (res.headers["Sec-Websocket-Extensions"].indexOf("permessage-deflate") === -1) {
+			throw new Error("compression wasn't negotiated: " + JSON.stringify(res.headers));
+		}
+		if (received !== "compressed") { throw new Error("unexpected message: " + received); }
+		`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("unsupported compression", func(t *testing.T) {
+		_, err := common.RunString(rt, tb.Replacer.Replace(`
+		ws.connect("ws://HTTPBIN_IP:HTTPBIN_PORT/ws-echo", { compression: "brotli" }, function(socket){});
+		`))
+		assert.EqualError(t, err, "GoError: unsupported compression algorithm 'brotli'")
+	})
+}
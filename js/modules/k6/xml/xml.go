@@ -0,0 +1,368 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package xml
+
+import (
+	"bytes"
+	"context"
+	goxml "encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/pkg/errors"
+)
+
+// SOAP envelope namespaces.
+const (
+	SOAP11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	SOAP12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+type XML struct{}
+
+// An Element is an XML element. Parsed documents are trees of these, and stringify() accepts
+// either Elements or plain objects with the same properties; in the latter, children without a
+// namespace property are in the same namespace as their parent.
+type Element struct {
+	Name       string            `js:"name"`
+	Namespace  string            `js:"namespace"`
+	Attributes map[string]string `js:"attributes"`
+	Text       string            `js:"text"`
+	Children   []*Element        `js:"children"`
+}
+
+// A Fault is a SOAP fault, with the differences between SOAP 1.1 and 1.2 smoothed over.
+type Fault struct {
+	Code    string   `js:"code"`
+	Message string   `js:"message"`
+	Detail  *Element `js:"detail"`
+}
+
+func New() *XML {
+	return &XML{}
+}
+
+// Parse parses an XML document into a tree of Elements, and returns the root one. Text that only
+// contains whitespace is dropped, and the rest is trimmed.
+func (*XML) Parse(data string) (*Element, error) {
+	return parse(data)
+}
+
+// Stringify serializes an Element (or an object that looks like one) to XML.
+func (*XML) Stringify(v goja.Value) (string, error) {
+	el, err := toElement(v.Export(), "")
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	writeElement(&buf, el, "")
+	return buf.String(), nil
+}
+
+// SoapEnvelope wraps a body, given as an XML string or an Element, in a SOAP envelope. The
+// optional params can set the SOAP version ("1.1" or "1.2", defaulting to "1.1") and a header.
+func (*XML) SoapEnvelope(ctx context.Context, body goja.Value, paramsV goja.Value) (string, error) {
+	rt := common.GetRuntime(ctx)
+	ns := SOAP11Namespace
+	var header goja.Value
+	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
+		params := paramsV.ToObject(rt)
+		for _, k := range params.Keys() {
+			switch k {
+			case "version":
+				switch version := params.Get(k).String(); version {
+				case "1.1":
+					ns = SOAP11Namespace
+				case "1.2":
+					ns = SOAP12Namespace
+				default:
+					return "", errors.Errorf("unsupported SOAP version '%s', use: '1.1' or '1.2'", version)
+				}
+			case "header":
+				header = params.Get(k)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(goxml.Header)
+	buf.WriteString(`<soap:Envelope xmlns:soap="` + ns + `">`)
+	if header != nil && !goja.IsUndefined(header) && !goja.IsNull(header) {
+		buf.WriteString("<soap:Header>")
+		if err := writeFragment(&buf, header); err != nil {
+			return "", errors.Wrap(err, "invalid header")
+		}
+		buf.WriteString("</soap:Header>")
+	}
+	buf.WriteString("<soap:Body>")
+	if !goja.IsUndefined(body) && !goja.IsNull(body) {
+		if err := writeFragment(&buf, body); err != nil {
+			return "", errors.Wrap(err, "invalid body")
+		}
+	}
+	buf.WriteString("</soap:Body></soap:Envelope>")
+	return buf.String(), nil
+}
+
+// SoapFault parses a SOAP response and returns the fault in it, or null if there isn't one.
+func (*XML) SoapFault(data string) (interface{}, error) {
+	root, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if root.Name != "Envelope" || (root.Namespace != SOAP11Namespace && root.Namespace != SOAP12Namespace) {
+		return nil, errors.New("not a SOAP envelope")
+	}
+	body := root.child("Body")
+	if body == nil {
+		return nil, errors.New("the SOAP envelope has no body")
+	}
+	faultEl := body.child("Fault")
+	if faultEl == nil {
+		return nil, nil
+	}
+
+	var fault Fault
+	if root.Namespace == SOAP12Namespace {
+		if code := faultEl.child("Code"); code != nil {
+			fault.Code = code.childText("Value")
+		}
+		if reason := faultEl.child("Reason"); reason != nil {
+			fault.Message = reason.childText("Text")
+		}
+		fault.Detail = faultEl.child("Detail")
+	} else {
+		fault.Code = faultEl.childText("faultcode")
+		fault.Message = faultEl.childText("faultstring")
+		fault.Detail = faultEl.child("detail")
+	}
+	return &fault, nil
+}
+
+// Find returns the first descendant element with the given (local) name, or null.
+func (e *Element) Find(name string) interface{} {
+	if el := e.find(name); el != nil {
+		return el
+	}
+	return nil
+}
+
+// FindAll returns all descendant elements with the given (local) name, in document order.
+func (e *Element) FindAll(name string) []*Element {
+	var els []*Element
+	e.walk(func(el *Element) bool {
+		if el.Name == name {
+			els = append(els, el)
+		}
+		return true
+	})
+	return els
+}
+
+func (e *Element) find(name string) *Element {
+	var found *Element
+	e.walk(func(el *Element) bool {
+		if el.Name == name {
+			found = el
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// walk calls fn for every descendant in document order, until it returns false.
+func (e *Element) walk(fn func(el *Element) bool) bool {
+	for _, child := range e.Children {
+		if !fn(child) || !child.walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Element) child(name string) *Element {
+	for _, child := range e.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+func (e *Element) childText(name string) string {
+	if child := e.child(name); child != nil {
+		return child.Text
+	}
+	return ""
+}
+
+func parse(data string) (*Element, error) {
+	d := goxml.NewDecoder(strings.NewReader(data))
+	var root *Element
+	var stack []*Element
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case goxml.StartElement:
+			el := &Element{Name: t.Name.Local, Namespace: t.Name.Space, Attributes: make(map[string]string)}
+			for _, attr := range t.Attr {
+				// Namespace declarations are already resolved into the elements' namespaces.
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				el.Attributes[attr.Name.Local] = attr.Value
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, el)
+			} else {
+				root = el
+			}
+			stack = append(stack, el)
+		case goxml.EndElement:
+			el := stack[len(stack)-1]
+			el.Text = strings.TrimSpace(el.Text)
+			stack = stack[:len(stack)-1]
+		case goxml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("the document has no root element")
+	}
+	return root, nil
+}
+
+// toElement converts an exported JS value to an Element. Plain objects without a namespace
+// inherit the given parent's one.
+func toElement(v interface{}, parentNS string) (*Element, error) {
+	switch v := v.(type) {
+	case *Element:
+		return v, nil
+	case map[string]interface{}:
+		el := &Element{}
+		if name, ok := v["name"].(string); ok && name != "" {
+			el.Name = name
+		} else {
+			return nil, errors.New("an element needs a name")
+		}
+		if ns, ok := v["namespace"].(string); ok {
+			el.Namespace = ns
+		} else {
+			el.Namespace = parentNS
+		}
+		if text, ok := v["text"]; ok && text != nil {
+			el.Text = toString(text)
+		}
+		if attrs, ok := v["attributes"].(map[string]interface{}); ok {
+			el.Attributes = make(map[string]string, len(attrs))
+			for k, attr := range attrs {
+				el.Attributes[k] = toString(attr)
+			}
+		}
+		switch children := v["children"].(type) {
+		case []interface{}:
+			for i, c := range children {
+				child, err := toElement(c, el.Namespace)
+				if err != nil {
+					return nil, errors.Wrapf(err, "children[%d]", i)
+				}
+				el.Children = append(el.Children, child)
+			}
+		case []*Element:
+			el.Children = children
+		}
+		return el, nil
+	default:
+		return nil, errors.Errorf("can't convert %T to an XML element", v)
+	}
+}
+
+func toString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// writeFragment writes a SOAP header or body, given either as an XML string or an element.
+func writeFragment(buf *bytes.Buffer, v goja.Value) error {
+	if s, ok := v.Export().(string); ok {
+		buf.WriteString(s)
+		return nil
+	}
+	el, err := toElement(v.Export(), "")
+	if err != nil {
+		return err
+	}
+	writeElement(buf, el, "")
+	return nil
+}
+
+func writeElement(buf *bytes.Buffer, el *Element, parentNS string) {
+	buf.WriteByte('<')
+	buf.WriteString(el.Name)
+	if el.Namespace != parentNS {
+		buf.WriteString(` xmlns="`)
+		_ = goxml.EscapeText(buf, []byte(el.Namespace))
+		buf.WriteByte('"')
+	}
+
+	keys := make([]string, 0, len(el.Attributes))
+	for k := range el.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteByte(' ')
+		buf.WriteString(k)
+		buf.WriteString(`="`)
+		_ = goxml.EscapeText(buf, []byte(el.Attributes[k]))
+		buf.WriteByte('"')
+	}
+
+	if el.Text == "" && len(el.Children) == 0 {
+		buf.WriteString("/>")
+		return
+	}
+	buf.WriteByte('>')
+	_ = goxml.EscapeText(buf, []byte(el.Text))
+	for _, child := range el.Children {
+		writeElement(buf, child, el.Namespace)
+	}
+	buf.WriteString("</")
+	buf.WriteString(el.Name)
+	buf.WriteByte('>')
+}
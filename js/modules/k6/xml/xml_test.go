@@ -0,0 +1,170 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package xml
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/js/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSOAP11Fault = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <soap:Fault>
+      <faultcode>soap:Client</faultcode>
+      <faultstring>Invalid account</faultstring>
+      <detail><error code="42"/></detail>
+    </soap:Fault>
+  </soap:Body>
+</soap:Envelope>`
+
+const testSOAP12Fault = `<?xml version="1.0"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope">
+  <env:Body>
+    <env:Fault>
+      <env:Code><env:Value>env:Sender</env:Value></env:Code>
+      <env:Reason><env:Text xml:lang="en">Invalid account</env:Text></env:Reason>
+    </env:Fault>
+  </env:Body>
+</env:Envelope>`
+
+func TestParse(t *testing.T) {
+	el, err := parse(`<?xml version="1.0"?>
+<a:root xmlns:a="urn:a" xmlns="urn:b" id="1">
+  <item n="1">one</item>
+  <item n="2">two &amp; a <b>half</b></item>
+  <a:other/>
+</a:root>`)
+	require.NoError(t, err)
+	assert.Equal(t, &Element{
+		Name:       "root",
+		Namespace:  "urn:a",
+		Attributes: map[string]string{"id": "1"},
+		Children: []*Element{
+			{Name: "item", Namespace: "urn:b", Attributes: map[string]string{"n": "1"}, Text: "one"},
+			{Name: "item", Namespace: "urn:b", Attributes: map[string]string{"n": "2"}, Text: "two & a",
+				Children: []*Element{{Name: "b", Namespace: "urn:b", Attributes: map[string]string{}, Text: "half"}}},
+			{Name: "other", Namespace: "urn:a", Attributes: map[string]string{}},
+		},
+	}, el)
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, data := range []string{"", "text", "<a>", "<a></b>"} {
+			_, err := parse(data)
+			assert.Error(t, err, data)
+		}
+	})
+}
+
+func TestXML(t *testing.T) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	ctx := context.Background()
+	ctx = common.WithRuntime(ctx, rt)
+	rt.Set("xml", common.Bind(rt, New(), &ctx))
+	rt.Set("soap11Fault", testSOAP11Fault)
+	rt.Set("soap12Fault", testSOAP12Fault)
+
+	t.Run("Parse", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let doc = xml.parse('<list><item id="a">1</item><group><item id="b">2</item></group></list>');
+		if (doc.name !== "list") { throw new Error("wrong name: " + doc.name); }
+		if (doc.find("item").attributes.id !== "a") { throw new Error("wrong first item"); }
+		let ids = doc.findAll("item").map(function(item) { return item.attributes.id; }).join(",");
+		if (ids !== "a,b") { throw new Error("wrong items: " + ids); }
+		if (doc.find("missing") !== null) { throw new Error("found a missing element"); }
+		`)
+		assert.NoError(t, err)
+	})
+	t.Run("Stringify", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		xml.stringify({
+			name: "order",
+			namespace: "urn:shop",
+			attributes: { id: 7, note: "a \"quoted\" <note>" },
+			children: [
+				{ name: "item", text: "Fish & Chips" },
+				{ name: "empty", namespace: "" },
+			],
+		});
+		`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `<order xmlns="urn:shop" id="7" note="a &#34;quoted&#34; &lt;note&gt;">`+
+				`<item>Fish &amp; Chips</item><empty xmlns=""/></order>`, v.String())
+		}
+	})
+	t.Run("Roundtrip", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		xml.stringify(xml.parse('<a xmlns="urn:x"><b c="d">e</b></a>'));
+		`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `<a xmlns="urn:x"><b c="d">e</b></a>`, v.String())
+		}
+	})
+	t.Run("SoapEnvelope", func(t *testing.T) {
+		v, err := common.RunString(rt, `
+		xml.soapEnvelope({ name: "GetBalance", namespace: "urn:bank", children: [{ name: "Account", text: "42" }] });
+		`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"+
+				`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+				`<GetBalance xmlns="urn:bank"><Account>42</Account></GetBalance>`+
+				`</soap:Body></soap:Envelope>`, v.String())
+		}
+
+		v, err = common.RunString(rt, `
+		xml.soapEnvelope("<Ping/>", { version: "1.2", header: "<Auth>token</Auth>" });
+		`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"+
+				`<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope">`+
+				`<soap:Header><Auth>token</Auth></soap:Header><soap:Body><Ping/></soap:Body></soap:Envelope>`,
+				v.String())
+		}
+
+		_, err = common.RunString(rt, `xml.soapEnvelope("<Ping/>", { version: "2.0" });`)
+		assert.Error(t, err)
+	})
+	t.Run("SoapFault", func(t *testing.T) {
+		_, err := common.RunString(rt, `
+		let fault = xml.soapFault(soap11Fault);
+		if (fault.code !== "soap:Client") { throw new Error("wrong 1.1 code: " + fault.code); }
+		if (fault.message !== "Invalid account") { throw new Error("wrong 1.1 message: " + fault.message); }
+		if (fault.detail.find("error").attributes.code !== "42") { throw new Error("wrong 1.1 detail"); }
+
+		fault = xml.soapFault(soap12Fault);
+		if (fault.code !== "env:Sender") { throw new Error("wrong 1.2 code: " + fault.code); }
+		if (fault.message !== "Invalid account") { throw new Error("wrong 1.2 message: " + fault.message); }
+
+		let ok = xml.soapEnvelope("<Pong/>");
+		if (xml.soapFault(ok) !== null) { throw new Error("found a fault in a successful response"); }
+		`)
+		assert.NoError(t, err)
+
+		_, err = common.RunString(rt, `xml.soapFault("<html/>");`)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,75 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package modules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ExtensionPrefix is the prefix of the names of the JS modules registered by extensions, so that
+// they can't shadow the built-in ones, or the ones added to k6 later.
+const ExtensionPrefix = "k6/x/"
+
+var (
+	mu         sync.Mutex
+	extensions = make(map[string]interface{})
+)
+
+// Register registers a JS module from an extension, to be imported by scripts with its name,
+// which must start with "k6/x/". It's meant to be called from the init() of the extension's
+// package, and panics if the name is invalid or already taken, like database/sql.Register.
+//
+// The module is shared by all VUs, and by all the tests in the process, unlike the built-in ones.
+// Its methods are bound like the built-in modules' ones: they're exposed to JS with their first
+// letter lowercased, they may take a context.Context as their first argument, to get the VU's
+// state with common.GetState(), and methods prefixed with X are constructors, eg. XClient is
+// `new Client()`.
+func Register(name string, mod interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !strings.HasPrefix(name, ExtensionPrefix) || len(name) == len(ExtensionPrefix) {
+		panic(fmt.Sprintf("modules: the name of the JS module '%s' must start with '%s'", name, ExtensionPrefix))
+	}
+	if mod == nil {
+		panic(fmt.Sprintf("modules: the JS module '%s' is nil", name))
+	}
+	if _, ok := extensions[name]; ok {
+		panic(fmt.Sprintf("modules: the JS module '%s' is already registered", name))
+	}
+	extensions[name] = mod
+}
+
+// Extensions returns the names of the JS modules registered by extensions, sorted.
+func Extensions() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var names []string
+	for name := range extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
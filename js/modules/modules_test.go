@@ -0,0 +1,61 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package modules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testModule struct{}
+
+func TestRegister(t *testing.T) {
+	defer func() {
+		mu.Lock()
+		delete(extensions, "k6/x/test")
+		delete(extensions, "k6/x/test/nested")
+		mu.Unlock()
+	}()
+
+	Register("k6/x/test/nested", &testModule{})
+	Register("k6/x/test", &testModule{})
+	assert.Equal(t, []string{"k6/x/test", "k6/x/test/nested"}, Extensions())
+	index, _ := New()
+	assert.IsType(t, &testModule{}, index["k6/x/test"])
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert.PanicsWithValue(t, "modules: the JS module 'k6/x/test' is already registered", func() {
+			Register("k6/x/test", &testModule{})
+		})
+		assert.PanicsWithValue(t, "modules: the JS module 'k6/x/nil' is nil", func() {
+			Register("k6/x/nil", nil)
+		})
+		for _, name := range []string{"k6/http", "k6/x/", "test", "x/test"} {
+			t.Run(name, func(t *testing.T) {
+				assert.PanicsWithValue(t, "modules: the name of the JS module '"+name+"' must start with 'k6/x/'", func() {
+					Register(name, &testModule{})
+				})
+			})
+		}
+		assert.Equal(t, []string{"k6/x/test", "k6/x/test/nested"}, Extensions())
+	})
+}
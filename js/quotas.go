@@ -0,0 +1,286 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dustin/go-humanize"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// Rough sizes of JS values, for estimating how much memory a script keeps.
+const (
+	retainedObjectSize   = 64
+	retainedPropertySize = 16
+	retainedValueSize    = 8
+
+	// Objects nested deeper than this aren't counted, to bound the recursion.
+	retainedMaxDepth = 64
+
+	// The memory quota is checked at most 1/retainedCheckCost of the time.
+	retainedCheckCost = 100
+)
+
+// How often the CPU time of an iteration is checked, at most and at least.
+const (
+	minCPUCheckInterval = time.Millisecond
+	maxCPUCheckInterval = 100 * time.Millisecond
+)
+
+// The types JS objects and arrays are exported as.
+var (
+	jsObjectType = reflect.TypeOf(map[string]interface{}{})
+	jsArrayType  = reflect.TypeOf([]interface{}{})
+)
+
+// errCPUQuotaExceeded interrupts an iteration that used too much CPU time.
+var errCPUQuotaExceeded = errors.New("CPU time quota exceeded")
+
+// runIteration runs an iteration of the default function, within the VU's quotas, if any.
+func (u *VU) runIteration(ctx context.Context) error {
+	quotas := u.Runner.Bundle.Options.VUQuotas
+	if quotas == nil {
+		_, _, err := u.runFn(ctx, u.Runner.defaultGroup, u.Default, u.setupData)
+		return err
+	}
+
+	maxMemory := quotas.GetMaxMemory()
+	if maxMemory > 0 && !u.retainedMeasured {
+		u.retainedBase, u.retainedMeasured = retainedSize(u.Runtime, math.MaxUint64), true
+	}
+
+	var cpuExceeded func() bool
+	if quotas.MaxIterationCPU.Valid {
+		cpuExceeded = u.watchCPU(time.Duration(quotas.MaxIterationCPU.Duration))
+	}
+	_, _, err := u.runFn(ctx, u.Runner.defaultGroup, u.Default, u.setupData)
+
+	violations := make(map[string]int64)
+	if cpuExceeded != nil && cpuExceeded() {
+		violations["cpu"] = 1
+		if ierr, ok := err.(*goja.InterruptedError); !ok || ierr.Value() != errCPUQuotaExceeded {
+			// The iteration ended before the interrupt was seen; don't let it end the next one.
+			_, _ = u.Runtime.RunString("undefined")
+		}
+		err = errors.Errorf("the iteration used more than %s of CPU time, the VU's quota",
+			time.Duration(quotas.MaxIterationCPU.Duration))
+	}
+	if n := u.handles.TakeViolations(); n > 0 {
+		violations["handles"] = n
+	}
+	if maxMemory > 0 && u.retainedOver(maxMemory) {
+		violations["memory"] = 1
+		err = errors.Errorf("the VU's global variables grew by more than %s, its quota", humanize.Bytes(maxMemory))
+
+		// Start over with a new runtime, so that the memory is freed.
+		if rerr := u.restart(); rerr != nil {
+			return rerr
+		}
+	}
+	if len(violations) == 0 {
+		return err
+	}
+
+	u.emitQuotaViolations(violations)
+	if quotas.GetPolicy() == lib.VUQuotaPolicyAbort {
+		reason := "VU quota exceeded"
+		if err != nil {
+			reason = err.Error()
+		}
+		return lib.NewAbortError(reason, 0)
+	}
+	return err
+}
+
+// watchCPU interrupts the running iteration if it uses more than quota of CPU time. It returns a
+// function that stops watching, and returns whether the quota was exceeded.
+func (u *VU) watchCPU(quota time.Duration) func() bool {
+	clock, unlock := lockThreadCPUClock()
+	if clock == nil {
+		return func() bool { return false }
+	}
+
+	interval := quota / 10
+	if interval < minCPUCheckInterval {
+		interval = minCPUCheckInterval
+	} else if interval > maxCPUCheckInterval {
+		interval = maxCPUCheckInterval
+	}
+
+	rt := u.Runtime
+	start := clock()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	var exceeded bool
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if clock()-start > quota {
+					exceeded = true
+					rt.Interrupt(errCPUQuotaExceeded)
+					return
+				}
+			}
+		}
+	}()
+
+	return func() bool {
+		close(done)
+		wg.Wait()
+		exceeded = exceeded || clock()-start > quota
+		unlock()
+		return exceeded
+	}
+}
+
+func (u *VU) emitQuotaViolations(violations map[string]int64) {
+	opts := u.Runner.Bundle.Options
+	now := time.Now()
+	samples := make(stats.Samples, 0, len(violations))
+	for quota, n := range violations {
+		tags := opts.RunTags.CloneTags()
+		if opts.SystemTags["vu"] {
+			tags["vu"] = strconv.FormatInt(u.ID, 10)
+		}
+		tags["quota"] = quota
+		samples = append(samples, stats.Sample{
+			Time:   now,
+			Metric: metrics.VUQuotaViolations,
+			Tags:   stats.IntoSampleTags(&tags),
+			Value:  float64(n),
+		})
+	}
+	u.Samples <- samples
+}
+
+// restart replaces the VU's runtime with a new one, which runs the init code again, as it does
+// for a new VU: feeders, sync primitives and secrets are declared again, which finds the ones
+// that the first run declared, since they're kept by the Runner. The files the old runtime left
+// open are closed, and the setup data is converted again for the new runtime.
+func (u *VU) restart() error {
+	bi, err := u.Runner.Bundle.Instantiate()
+	if err != nil {
+		return err
+	}
+	if u.interruptCancel != nil {
+		u.interruptCancel()
+	}
+	u.interruptTrackedCtx, u.interruptCancel = nil, nil
+	u.handles.CloseAll()
+
+	u.BundleInstance = *bi
+	u.setupData = nil
+	u.retainedMeasured = false
+	u.retainedNextCheck = time.Time{}
+	u.bindGlobals()
+	u.Runtime.Set("__VU", u.ID)
+	return nil
+}
+
+// retainedOver returns whether the VU's global variables grew by more than maxMemory since the
+// init code. Estimating their size walks all of the data they keep, so it's only done once the
+// VU has run for retainedCheckCost times as long as the last estimate took since then.
+func (u *VU) retainedOver(maxMemory uint64) bool {
+	start := time.Now()
+	if start.Before(u.retainedNextCheck) {
+		return false
+	}
+	limit := u.retainedBase + maxMemory
+	over := retainedSize(u.Runtime, limit) > limit
+	u.retainedNextCheck = time.Now().Add(time.Since(start) * retainedCheckCost)
+	return over
+}
+
+// retainedSize estimates the memory taken by the data reachable from the global variables of a
+// runtime, up to limit: it stops counting once it's over it. Closures aren't looked into.
+func retainedSize(rt *goja.Runtime, limit uint64) uint64 {
+	e := sizeEstimator{seen: make(map[*goja.Object]bool), limit: limit}
+	e.add(rt.GlobalObject(), 0)
+	return e.size
+}
+
+type sizeEstimator struct {
+	seen        map[*goja.Object]bool
+	size, limit uint64
+}
+
+func (e *sizeEstimator) add(v goja.Value, depth int) {
+	if v == nil || e.size > e.limit {
+		return
+	}
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		if t := v.ExportType(); t != nil && t.Kind() == reflect.String {
+			e.size += uint64(len(v.String()))
+		} else {
+			e.size += retainedValueSize
+		}
+		return
+	}
+
+	if e.seen[obj] || depth > retainedMaxDepth {
+		return
+	}
+	e.seen[obj] = true
+	e.size += retainedObjectSize
+	if _, isFunc := goja.AssertFunction(obj); isFunc {
+		return
+	}
+
+	// Only JS objects and arrays are looked into; Go values exposed to JS are counted as objects,
+	// except for bytes, eg. from open(name, "b").
+	switch t := obj.ExportType(); {
+	case t == nil:
+		return
+	case t == jsObjectType, t == jsArrayType:
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		if b, ok := obj.Export().([]byte); ok {
+			e.size += uint64(len(b))
+		}
+		return
+	default:
+		return
+	}
+	for _, key := range obj.Keys() {
+		e.size += retainedPropertySize + uint64(len(key))
+		e.add(obj.Get(key), depth+1)
+		if e.size > e.limit {
+			return
+		}
+	}
+}
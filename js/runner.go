@@ -23,16 +23,19 @@ package js
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/lib/netext"
 	"github.com/loadimpact/k6/stats"
 	"github.com/oxtoacart/bpool"
@@ -48,6 +51,8 @@ var errInterrupt = errors.New("context cancelled")
 
 // Ensure Runner implements the lib.Runner interface
 var _ lib.Runner = &Runner{}
+var _ lib.RPSLimiter = &Runner{}
+var _ lib.ConnectionCounter = &Runner{}
 
 type Runner struct {
 	Bundle       *Bundle
@@ -58,6 +63,29 @@ type Runner struct {
 	Resolver   *dnscache.Resolver
 	RPSLimit   *rate.Limiter
 
+	// Built from the tlsRootCAs option, if set; otherwise the system's root CAs are used.
+	rootCAs *x509.CertPool
+
+	// Shared between all VUs if the tlsSessionCache option is "shared".
+	tlsSessionCache tls.ClientSessionCache
+
+	// Records the HTTP traffic of all VUs if the harOutput option is set.
+	httpRecorder *netext.Recorder
+
+	// Shared between all VUs if the connectionReuse option is "shared".
+	sharedConns      *connPool
+	sharedConnsMutex sync.Mutex
+
+	// Shared between all VUs if the cookieJar option is "shared".
+	sharedCookieJar      *netext.CookieJar
+	sharedCookieJarMutex sync.Mutex
+
+	// Connections all VUs have open, for the resourceLimits option.
+	openConns int64
+
+	// Injected into the HTTP requests of all VUs by the k6/chaos module.
+	faults *lib.FaultInjector
+
 	console   *console
 	setupData []byte
 }
@@ -95,6 +123,7 @@ func NewFromBundle(b *Bundle) (*Runner, error) {
 		},
 		console:  newConsole(),
 		Resolver: dnscache.New(0),
+		faults:   lib.NewFaultInjector(),
 	}
 
 	err = r.SetOptions(r.Bundle.Options)
@@ -120,16 +149,6 @@ func (r *Runner) newVU(samplesOut chan<- stats.SampleContainer) (*VU, error) {
 		return nil, err
 	}
 
-	var cipherSuites []uint16
-	if r.Bundle.Options.TLSCipherSuites != nil {
-		cipherSuites = *r.Bundle.Options.TLSCipherSuites
-	}
-
-	var tlsVersions lib.TLSVersions
-	if r.Bundle.Options.TLSVersion != nil {
-		tlsVersions = *r.Bundle.Options.TLSVersion
-	}
-
 	tlsAuth := r.Bundle.Options.TLSAuth
 	certs := make([]tls.Certificate, len(tlsAuth))
 	nameToCert := make(map[string]*tls.Certificate)
@@ -144,11 +163,105 @@ func (r *Runner) newVU(samplesOut chan<- stats.SampleContainer) (*VU, error) {
 		}
 	}
 
+	var sessionCache tls.ClientSessionCache
+	switch r.Bundle.Options.TLSSessionCache.String {
+	case lib.TLSSessionCacheVU:
+		sessionCache = tls.NewLRUClientSessionCache(0)
+	case lib.TLSSessionCacheShared:
+		sessionCache = r.tlsSessionCache
+	}
+
+	var conns *connPool
+	if r.Bundle.Options.GetConnectionReuse() == lib.ConnectionReuseShared {
+		conns, err = r.getSharedConns(certs, nameToCert, sessionCache)
+	} else {
+		conns, err = r.newConnPool(certs, nameToCert, sessionCache)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cookieJar, err := r.vuCookieJar()
+	if err != nil {
+		return nil, err
+	}
+
+	// A shared pool's connections don't belong to any VU, so they don't count against its quota.
+	handles := &lib.HandleCounter{}
+	if quotas := r.Bundle.Options.VUQuotas; quotas != nil {
+		handles.Max = quotas.MaxHandles.Int64
+	}
+	if r.Bundle.Options.GetConnectionReuse() != lib.ConnectionReuseShared {
+		conns.dialer.Handles = handles
+	}
+
+	vu := &VU{
+		BundleInstance:  *bi,
+		Runner:          r,
+		Transport:       conns.transport,
+		Dialer:          conns.dialer,
+		CookieJar:       cookieJar,
+		TLSConfig:       conns.tlsConfig,
+		HTTPRetryBudget: &lib.HTTPRetryBudget{},
+		Console:         r.console,
+		BPool:           bpool.NewBufferPool(100),
+		Samples:         samplesOut,
+		tlsAuthCerts:    certs,
+		handles:         handles,
+	}
+	if r.Bundle.Options.HTTPCache.String == lib.HTTPCacheVU {
+		vu.HTTPCache = netext.NewHTTPCache()
+	}
+	vu.bindGlobals()
+
+	// Give the VU an initial sense of identity.
+	if err := vu.Reconfigure(0); err != nil {
+		return nil, err
+	}
+
+	return vu, nil
+}
+
+// bindGlobals sets the globals the VU's runtime has outside of the init context.
+func (u *VU) bindGlobals() {
+	rt := u.Runtime
+	rt.Set("console", common.Bind(rt, u.Console, u.Context))
+	common.BindToGlobal(rt, map[string]interface{}{
+		"open": func() {
+			common.Throw(rt, errors.New("\"open\" function is only available to the init code (aka global scope), see https://docs.k6.io/docs/test-life-cycle for more information"))
+		},
+	})
+}
+
+// A connPool is what a VU makes its connections with. Each VU has its own, unless the
+// connectionReuse option is "shared".
+type connPool struct {
+	dialer    *netext.Dialer
+	tlsConfig *tls.Config
+	transport *http.Transport
+}
+
+func (r *Runner) newConnPool(
+	certs []tls.Certificate, nameToCert map[string]*tls.Certificate, sessionCache tls.ClientSessionCache,
+) (*connPool, error) {
+	var cipherSuites []uint16
+	if r.Bundle.Options.TLSCipherSuites != nil {
+		cipherSuites = *r.Bundle.Options.TLSCipherSuites
+	}
+
+	var tlsVersions lib.TLSVersions
+	if r.Bundle.Options.TLSVersion != nil {
+		tlsVersions = *r.Bundle.Options.TLSVersion
+	}
+
 	dialer := &netext.Dialer{
-		Dialer:    r.BaseDialer,
-		Resolver:  r.Resolver,
-		Blacklist: r.Bundle.Options.BlacklistIPs,
-		Hosts:     r.Bundle.Options.Hosts,
+		Dialer:       r.BaseDialer,
+		Resolver:     r.Resolver,
+		Blacklist:    r.Bundle.Options.BlacklistIPs,
+		AllowedHosts: r.Bundle.Options.AllowedHosts,
+		BlockedHosts: r.Bundle.Options.BlockedHosts,
+		Hosts:        r.Bundle.Options.Hosts,
+		OpenConns:    &r.openConns,
 	}
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: r.Bundle.Options.InsecureSkipTLSVerify.Bool,
@@ -158,47 +271,62 @@ func (r *Runner) newVU(samplesOut chan<- stats.SampleContainer) (*VU, error) {
 		Certificates:       certs,
 		NameToCertificate:  nameToCert,
 		Renegotiation:      tls.RenegotiateFreelyAsClient,
+		ClientSessionCache: sessionCache,
+		RootCAs:            r.rootCAs,
+		CurvePreferences:   r.Bundle.Options.TLSGroups,
+	}
+	if err := configureECH(tlsConfig, r.Bundle.Options.TLSECHConfigList); err != nil {
+		return nil, err
 	}
 	transport := &http.Transport{
 		Proxy:               http.ProxyFromEnvironment,
 		TLSClientConfig:     tlsConfig,
 		DialContext:         dialer.DialContext,
 		DisableCompression:  true,
-		DisableKeepAlives:   r.Bundle.Options.NoConnectionReuse.Bool,
+		DisableKeepAlives:   r.Bundle.Options.GetConnectionReuse() == lib.ConnectionReuseNone,
 		MaxIdleConns:        int(r.Bundle.Options.Batch.Int64),
 		MaxIdleConnsPerHost: int(r.Bundle.Options.BatchPerHost.Int64),
 	}
 	_ = http2.ConfigureTransport(transport)
 
-	cookieJar, err := cookiejar.New(nil)
-	if err != nil {
-		return nil, err
-	}
+	return &connPool{dialer: dialer, tlsConfig: tlsConfig, transport: transport}, nil
+}
 
-	vu := &VU{
-		BundleInstance: *bi,
-		Runner:         r,
-		Transport:      transport,
-		Dialer:         dialer,
-		CookieJar:      cookieJar,
-		TLSConfig:      tlsConfig,
-		Console:        r.console,
-		BPool:          bpool.NewBufferPool(100),
-		Samples:        samplesOut,
-	}
-	vu.Runtime.Set("console", common.Bind(vu.Runtime, vu.Console, vu.Context))
-	common.BindToGlobal(vu.Runtime, map[string]interface{}{
-		"open": func() {
-			common.Throw(vu.Runtime, errors.New("\"open\" function is only available to the init code (aka global scope), see https://docs.k6.io/docs/test-life-cycle for more information"))
-		},
-	})
+// getSharedConns returns the connPool all VUs share, creating it for the first one. Later VUs'
+// TLS settings are the same as the first's, so there's nothing to lose by ignoring them.
+func (r *Runner) getSharedConns(
+	certs []tls.Certificate, nameToCert map[string]*tls.Certificate, sessionCache tls.ClientSessionCache,
+) (*connPool, error) {
+	r.sharedConnsMutex.Lock()
+	defer r.sharedConnsMutex.Unlock()
 
-	// Give the VU an initial sense of identity.
-	if err := vu.Reconfigure(0); err != nil {
-		return nil, err
+	if r.sharedConns == nil {
+		conns, err := r.newConnPool(certs, nameToCert, sessionCache)
+		if err != nil {
+			return nil, err
+		}
+		r.sharedConns = conns
 	}
+	return r.sharedConns, nil
+}
 
-	return vu, nil
+// vuCookieJar returns the cookie jar a new VU keeps across iterations, which is the one all VUs
+// share if the cookieJar option is "shared".
+func (r *Runner) vuCookieJar() (*netext.CookieJar, error) {
+	if r.Bundle.Options.GetCookieJar() != lib.CookieJarShared {
+		return netext.NewCookieJar()
+	}
+
+	r.sharedCookieJarMutex.Lock()
+	defer r.sharedCookieJarMutex.Unlock()
+	if r.sharedCookieJar == nil {
+		jar, err := netext.NewCookieJar()
+		if err != nil {
+			return nil, err
+		}
+		r.sharedCookieJar = jar
+	}
+	return r.sharedCookieJar, nil
 }
 
 func (r *Runner) Setup(ctx context.Context, out chan<- stats.SampleContainer) error {
@@ -266,23 +394,125 @@ func (r *Runner) GetOptions() lib.Options {
 func (r *Runner) SetOptions(opts lib.Options) error {
 	r.Bundle.Options = opts
 
-	r.RPSLimit = nil
+	if err := r.Bundle.BaseInitContext.loadAssets(opts.Assets); err != nil {
+		return err
+	}
+
+	if err := lib.ValidateModulePatterns(opts.AllowedModules); err != nil {
+		return err
+	}
+	if err := lib.ValidateModulePatterns(opts.BlockedModules); err != nil {
+		return err
+	}
+	if err := r.Bundle.BaseInitContext.checkBuiltinImports(opts); err != nil {
+		return err
+	}
+	if err := lib.ValidateHostPatterns(opts.AllowedHosts); err != nil {
+		return err
+	}
+	if err := lib.ValidateHostPatterns(opts.BlockedHosts); err != nil {
+		return err
+	}
+	if opts.VUQuotas != nil && opts.VUQuotas.MaxIterationCPU.Valid && !hasThreadCPUClock {
+		return errors.New("the max iteration CPU VU quota is only supported on Linux")
+	}
+
+	// The limiter is always there, so that the limit can be changed while VUs are using it.
+	if r.RPSLimit == nil {
+		r.RPSLimit = rate.NewLimiter(rate.Inf, 1)
+	}
+	r.RPSLimit.SetLimit(rate.Inf)
 	if rps := opts.RPS; rps.Valid {
-		r.RPSLimit = rate.NewLimiter(rate.Limit(rps.Int64), 1)
+		r.RPSLimit.SetLimit(rate.Limit(rps.Int64))
+	}
+
+	r.rootCAs = nil
+	if len(opts.TLSRootCAs) > 0 {
+		pool, err := opts.TLSRootCAs.CertPool()
+		if err != nil {
+			return err
+		}
+		r.rootCAs = pool
+	}
+
+	if err := lib.ValidateTLSSessionCache(opts.TLSSessionCache.String); err != nil {
+		return err
+	}
+	if opts.TLSSessionCache.String == lib.TLSSessionCacheShared && r.tlsSessionCache == nil {
+		r.tlsSessionCache = tls.NewLRUClientSessionCache(0)
 	}
 
-	if consoleOutputFile := opts.ConsoleOutput; consoleOutputFile.Valid {
-		c, err := newFileConsole(consoleOutputFile.String)
+	if err := lib.ValidateConnectionReuse(opts.ConnectionReuse.String); err != nil {
+		return err
+	}
+	if opts.GetConnectionReuse() == lib.ConnectionReuseShared && len(opts.TLSAuthPool) > 0 {
+		return errors.New("a shared connection pool can't be used with tlsAuthPool, which gives VUs their own identity")
+	}
+	if err := lib.ValidateCookieJar(opts.CookieJar.String); err != nil {
+		return err
+	}
+	if err := lib.ValidateHTTPCache(opts.HTTPCache.String); err != nil {
+		return err
+	}
+
+	if opts.HAROutput.String != "" && r.httpRecorder == nil {
+		r.httpRecorder = netext.NewRecorder()
+	}
+
+	if consoleOutput := opts.ConsoleOutput; consoleOutput.Valid {
+		c, err := newOutputConsole(consoleOutput.String)
 		if err != nil {
 			return err
 		}
 
+		r.console.close()
 		r.console = c
 	}
+	if opts.ConsoleLevel.Valid {
+		if err := lib.ValidateConsoleLevel(opts.ConsoleLevel.String); err != nil {
+			return err
+		}
+		level, _ := log.ParseLevel(opts.ConsoleLevel.String)
+		r.console.setLevel(level)
+	}
+	if opts.ConsoleRateLimit.Int64 > 0 {
+		r.console.limiter = newConsoleLimiter(opts.ConsoleRateLimit.Int64)
+	}
 
 	return nil
 }
 
+// CloseConsole pushes the console messages that haven't been yet, if they go to Loki.
+func (r *Runner) CloseConsole() {
+	r.console.close()
+}
+
+// CloseModules releases what the built-in modules of the test hold on to, once it's over: the
+// secrets registered with k6/secrets stop being redacted and the k6/kv connections are closed.
+func (r *Runner) CloseModules() {
+	r.Bundle.BaseInitContext.closeModules()
+}
+
+// SetRPSLimit changes the RPS limit while the test is running; 0 removes it.
+func (r *Runner) SetRPSLimit(rps int64) {
+	if rps <= 0 {
+		r.RPSLimit.SetLimit(rate.Inf)
+		return
+	}
+	r.RPSLimit.SetLimit(rate.Limit(rps))
+}
+
+// OpenConnections returns the number of connections all VUs have open right now.
+func (r *Runner) OpenConnections() int64 {
+	return atomic.LoadInt64(&r.openConns)
+}
+
+// HTTPRecorder returns the recorder of the HTTP traffic made by all VUs, or nil
+// if the harOutput option isn't set.
+func (r *Runner) HTTPRecorder() *netext.Recorder {
+	return r.httpRecorder
+}
+
 // Runs an exported function in its own temporary VU, optionally with an argument. Execution is
 // interrupted if the context expires. No error is returned if the part does not exist.
 func (r *Runner) runPart(ctx context.Context, out chan<- stats.SampleContainer, name string, arg interface{}) (goja.Value, error) {
@@ -331,10 +561,14 @@ type VU struct {
 	Runner    *Runner
 	Transport *http.Transport
 	Dialer    *netext.Dialer
-	CookieJar *cookiejar.Jar
+	CookieJar *netext.CookieJar
+	HTTPCache *netext.HTTPCache
 	TLSConfig *tls.Config
 	ID        int64
-	Iteration int64
+
+	// Shared by all of the VU's iterations, so its retries are limited across the whole test.
+	HTTPRetryBudget *lib.HTTPRetryBudget
+	Iteration       int64
 
 	Console *console
 	BPool   *bpool.BufferPool
@@ -343,6 +577,23 @@ type VU struct {
 
 	setupData goja.Value
 
+	// Whether the VU has run its warmup iterations yet.
+	warmedUp bool
+
+	// Counts the connections and files the VU has open against its quota.
+	handles *lib.HandleCounter
+
+	// The estimated size of the global variables after the init code, for the memory quota.
+	retainedBase     uint64
+	retainedMeasured bool
+
+	// When the memory quota is checked next, after an iteration.
+	retainedNextCheck time.Time
+
+	// Client certificates from the tlsAuth option, which are presented alongside the one the
+	// VU is assigned from the tlsAuthPool option, if any.
+	tlsAuthCerts []tls.Certificate
+
 	// A VU will track the last context it was called with for cancellation.
 	// Note that interruptTrackedCtx is the context that is currently being tracked, while
 	// interruptCancel cancels an unrelated context that terminates the tracking goroutine
@@ -361,6 +612,40 @@ func (u *VU) Reconfigure(id int64) error {
 	u.ID = id
 	u.Iteration = 0
 	u.Runtime.Set("__VU", u.ID)
+	return u.assignTLSAuthPoolCert()
+}
+
+// Picks the VU's client certificate out of the tlsAuthPool option, based on its ID. VU IDs start
+// at 1; the VU used for setup() and teardown() has ID 0 and is given the first certificate. With
+// an execution segment, only the segment's part of the pool is used, so that instances of a
+// distributed test don't present the same identities.
+func (u *VU) assignTLSAuthPoolCert() error {
+	opts := u.Runner.Bundle.Options
+	if len(opts.TLSAuthPool) == 0 {
+		return nil
+	}
+	start, end := opts.ExecutionSegment.Range(len(opts.TLSAuthPool))
+	if start == end {
+		return errors.Errorf("the tlsAuthPool has no client certificates for execution segment %s", opts.ExecutionSegment)
+	}
+	pool := opts.TLSAuthPool[start:end]
+
+	var idx int64
+	if u.ID > 0 {
+		idx = (u.ID - 1) % int64(len(pool))
+	}
+	cert, err := pool[idx].Certificate()
+	if err != nil {
+		return err
+	}
+
+	// The TLS client presents the first certificate in the list that the server will accept.
+	certs := make([]tls.Certificate, 0, len(u.tlsAuthCerts)+1)
+	certs = append(certs, *cert)
+	u.TLSConfig.Certificates = append(certs, u.tlsAuthCerts...)
+
+	// Don't reuse connections that were authenticated as a previous identity.
+	u.Transport.CloseIdleConnections()
 	return nil
 }
 
@@ -374,11 +659,12 @@ func (u *VU) RunOnce(ctx context.Context) error {
 		u.interruptCancel = interCancel
 		u.interruptTrackedCtx = ctx
 		defer interCancel()
+		rt := u.Runtime
 		go func() {
 			select {
 			case <-interCtx.Done():
 			case <-ctx.Done():
-				u.Runtime.Interrupt(errInterrupt)
+				rt.Interrupt(errInterrupt)
 			}
 		}()
 	}
@@ -397,34 +683,91 @@ func (u *VU) RunOnce(ctx context.Context) error {
 		}
 	}
 
+	if !u.warmedUp {
+		u.warmedUp = true
+		if err := u.warmup(ctx); err != nil {
+			return err
+		}
+	}
+
 	// Call the default function.
-	_, _, err := u.runFn(ctx, u.Runner.defaultGroup, u.Default, u.setupData)
-	return err
+	return u.runIteration(ctx)
+}
+
+// warmup runs the number of iterations given by the warmupIterations option, discarding all
+// of their samples, so that eg. JIT compilation and connection pool setup don't skew the
+// results. Iteration numbers start from 0 again afterwards.
+func (u *VU) warmup(ctx context.Context) error {
+	n := u.Runner.Bundle.Options.WarmupIterations.Int64
+	if n <= 0 {
+		return nil
+	}
+
+	discard := make(chan stats.SampleContainer, 100)
+	drained := make(chan struct{})
+	go func() {
+		for range discard {
+		}
+		close(drained)
+	}()
+
+	samples := u.Samples
+	u.Samples = discard
+	defer func() {
+		u.Samples = samples
+		u.Iteration = 0
+		close(discard)
+		<-drained
+	}()
+
+	for i := int64(0); i < n; i++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+		if _, _, err := u.runFn(ctx, u.Runner.defaultGroup, u.Default, u.setupData); err != nil {
+			return errors.Wrap(err, "warmup iteration")
+		}
+	}
+	return nil
 }
 
 func (u *VU) runFn(ctx context.Context, group *lib.Group, fn goja.Callable, args ...goja.Value) (goja.Value, *common.State, error) {
-	cookieJar, err := cookiejar.New(nil)
-	if err != nil {
-		return goja.Undefined(), nil, err
+	cookieJar := u.CookieJar
+	if u.Runner.Bundle.Options.GetCookieJar() == lib.CookieJarIteration {
+		var err error
+		if cookieJar, err = netext.NewCookieJar(); err != nil {
+			return goja.Undefined(), nil, err
+		}
 	}
 
-	if u.Runner.Bundle.Options.NoCookiesReset.Valid && u.Runner.Bundle.Options.NoCookiesReset.Bool {
-		cookieJar = u.CookieJar
+	var httpCache *netext.HTTPCache
+	switch u.Runner.Bundle.Options.HTTPCache.String {
+	case lib.HTTPCacheVU:
+		httpCache = u.HTTPCache
+	case lib.HTTPCacheIteration:
+		httpCache = netext.NewHTTPCache()
 	}
 
 	state := &common.State{
-		Logger:    u.Runner.Logger,
-		Options:   u.Runner.Bundle.Options,
-		Group:     group,
-		Transport: u.Transport,
-		Dialer:    u.Dialer,
-		TLSConfig: u.TLSConfig,
-		CookieJar: cookieJar,
-		RPSLimit:  u.Runner.RPSLimit,
-		BPool:     u.BPool,
-		Vu:        u.ID,
-		Samples:   u.Samples,
-		Iteration: u.Iteration,
+		Logger:          u.Runner.Logger,
+		Options:         u.Runner.Bundle.Options,
+		Group:           group,
+		Transport:       u.Transport,
+		Dialer:          u.Dialer,
+		TLSConfig:       u.TLSConfig,
+		CookieJar:       cookieJar,
+		HTTPCache:       httpCache,
+		HTTPRetryBudget: u.HTTPRetryBudget,
+		RPSLimit:        u.Runner.RPSLimit,
+		BPool:           u.BPool,
+		HTTPRecorder:    u.Runner.httpRecorder,
+		Handles:         u.handles,
+		Faults:          u.Runner.faults,
+		Vu:              u.ID,
+		Samples:         u.Samples,
+		Iteration:       u.Iteration,
 	}
 
 	newctx := common.WithRuntime(ctx, u.Runtime)
@@ -436,9 +779,17 @@ func (u *VU) runFn(ctx context.Context, group *lib.Group, fn goja.Callable, args
 	u.Iteration++
 
 	startTime := time.Now()
+	state.IterationStart = startTime
 	v, err := fn(goja.Undefined(), args...) // Actually run the JS script
 	endTime := time.Now()
 
+	// The script aborted the test by interrupting its own runtime; pass on the reason.
+	if ierr, ok := err.(*goja.InterruptedError); ok {
+		if aerr, ok := ierr.Value().(lib.AbortError); ok {
+			err = aerr
+		}
+	}
+
 	var isFullIteration bool
 	select {
 	case <-ctx.Done():
@@ -447,6 +798,13 @@ func (u *VU) runFn(ctx context.Context, group *lib.Group, fn goja.Callable, args
 		isFullIteration = true
 	}
 
+	// An iteration that made a request to an endpoint whose circuit is open is skipped, not failed.
+	circuitErr, skipped := circuitOpenError(err)
+	if skipped {
+		isFullIteration = false
+		err = nil
+	}
+
 	tags := state.Options.RunTags.CloneTags()
 	if state.Options.SystemTags["vu"] {
 		tags["vu"] = strconv.FormatInt(u.ID, 10)
@@ -458,7 +816,21 @@ func (u *VU) runFn(ctx context.Context, group *lib.Group, fn goja.Callable, args
 		tags["group"] = group.Path
 	}
 
-	if u.Runner.Bundle.Options.NoVUConnectionReuse.Bool {
+	if skipped {
+		skipTags := make(map[string]string, len(tags)+1)
+		for k, v := range tags {
+			skipTags[k] = v
+		}
+		skipTags["circuit_breaker"] = circuitErr.Name
+		state.Samples <- stats.Sample{
+			Time:   endTime,
+			Metric: metrics.SkippedIterations,
+			Tags:   stats.IntoSampleTags(&skipTags),
+			Value:  1,
+		}
+	}
+
+	if u.Runner.Bundle.Options.GetConnectionReuse() == lib.ConnectionReuseIteration {
 		u.Transport.CloseIdleConnections()
 	}
 
@@ -475,3 +847,17 @@ func (u *VU) runFn(ctx context.Context, group *lib.Group, fn goja.Callable, args
 
 	return v, state, err
 }
+
+// circuitOpenError returns the error a script failed with, if it failed because it made a request
+// to an endpoint whose circuit is open.
+func circuitOpenError(err error) (lib.CircuitOpenError, bool) {
+	if exc, ok := err.(*goja.Exception); ok {
+		if obj, ok := exc.Value().(*goja.Object); ok {
+			if v := obj.Get("value"); v != nil {
+				err, _ = v.Export().(error)
+			}
+		}
+	}
+	cerr, ok := err.(lib.CircuitOpenError)
+	return cerr, ok
+}
@@ -23,14 +23,22 @@ package js
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	stdlog "log"
+	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -47,10 +55,12 @@ import (
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats"
 	"github.com/loadimpact/k6/stats/dummy"
+	"github.com/pkg/errors"
 	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 	null "gopkg.in/guregu/null.v3"
 )
 
@@ -133,6 +143,25 @@ func TestRunnerOptions(t *testing.T) {
 	}
 }
 
+func TestRunnerSetRPSLimit(t *testing.T) {
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data:     []byte(`export default function() {};`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+	limiter := r.RPSLimit
+	assert.Equal(t, rate.Inf, limiter.Limit())
+
+	require.NoError(t, r.SetOptions(lib.Options{RPS: null.IntFrom(10)}))
+	assert.Equal(t, rate.Limit(10), limiter.Limit())
+
+	r.SetRPSLimit(100)
+	assert.Equal(t, rate.Limit(100), limiter.Limit())
+	r.SetRPSLimit(0)
+	assert.Equal(t, rate.Inf, limiter.Limit())
+	assert.True(t, limiter == r.RPSLimit, "VUs must keep using the same limiter")
+}
+
 func TestOptionsSettingToScript(t *testing.T) {
 	t.Parallel()
 
@@ -412,6 +441,34 @@ func TestSetupDataNoReturn(t *testing.T) {
 	}
 	testSetupDataHelper(t, src)
 }
+func TestVUAbort(t *testing.T) {
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+			import execution from "k6/execution";
+			export let options = { setupTimeout: "1s" };
+			export function setup() {
+				if (__ENV.ABORT_IN_SETUP) { execution.abort("setup", 3); }
+			}
+			export default function() {
+				try { execution.abort("default", 4); } catch (e) {}
+				throw new Error("not aborted");
+			}
+		`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{Env: map[string]string{"ABORT_IN_SETUP": ""}})
+	require.NoError(t, err)
+
+	samples := make(chan stats.SampleContainer, 100)
+	require.NoError(t, r.Setup(context.Background(), samples))
+	vu, err := r.NewVU(samples)
+	require.NoError(t, err)
+	assert.Equal(t, lib.NewAbortError("default", 4), vu.RunOnce(context.Background()))
+
+	r.Bundle.Env["ABORT_IN_SETUP"] = "1"
+	err = r.Setup(context.Background(), samples)
+	assert.Equal(t, lib.NewAbortError("setup", 3), errors.Cause(err))
+}
+
 func TestRunnerIntegrationImports(t *testing.T) {
 	t.Run("Modules", func(t *testing.T) {
 		modules := []string{
@@ -677,6 +734,45 @@ func TestVUIntegrationMetrics(t *testing.T) {
 	}
 }
 
+func TestVUIntegrationWarmupIterations(t *testing.T) {
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(`
+		import { Counter } from "k6/metrics";
+		let iters = new Counter("iters");
+		let runs = 0;
+		export default function() {
+			runs++;
+			iters.add(1, { iter: String(__ITER), runs: String(runs) });
+		}
+		`),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+	r.SetOptions(lib.Options{WarmupIterations: null.IntFrom(3)})
+
+	samples := make(chan stats.SampleContainer, 100)
+	vu, err := r.newVU(samples)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, vu.RunOnce(context.Background()))
+	}
+
+	var iters []map[string]string
+	for _, sampleC := range stats.GetBufferedSamples(samples) {
+		for _, s := range sampleC.GetSamples() {
+			if s.Metric.Name == "iters" {
+				tags := s.Tags.CloneTags()
+				iters = append(iters, map[string]string{"iter": tags["iter"], "runs": tags["runs"]})
+			}
+		}
+	}
+	assert.Equal(t, []map[string]string{
+		{"iter": "0", "runs": "4"},
+		{"iter": "1", "runs": "5"},
+	}, iters)
+}
+
 func TestVUIntegrationInsecureRequests(t *testing.T) {
 	testdata := map[string]struct {
 		opts   lib.Options
@@ -823,6 +919,222 @@ func TestVUIntegrationHosts(t *testing.T) {
 	}
 }
 
+func TestVUIntegrationSandbox(t *testing.T) {
+	tb := testutils.NewHTTPMultiBin(t)
+	defer tb.Cleanup()
+
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(tb.Replacer.Replace(`
+			import http from "k6/http";
+			export default function() { http.get("HTTPBIN_URL/get"); }
+		`)),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	t.Run("Modules", func(t *testing.T) {
+		assert.EqualError(t, r.SetOptions(lib.Options{AllowedModules: []string{"k6", "k6/x/*"}}),
+			"the module 'k6/http' isn't in the allowed modules")
+		assert.EqualError(t, r.SetOptions(lib.Options{BlockedModules: []string{"k6/*"}}),
+			"the module 'k6/http' is in the blocked modules")
+		assert.EqualError(t, r.SetOptions(lib.Options{BlockedModules: []string{"http"}}),
+			"invalid module pattern 'http', use the name of a k6 module, eg. 'k6/http', "+
+				"or a prefix of them ending in '/*', eg. 'k6/x/*'")
+		require.NoError(t, r.SetOptions(lib.Options{AllowedModules: []string{"k6/http"}}))
+
+		// VUs check the modules they import themselves, in case they aren't the same.
+		r.Bundle.Options.AllowedModules = []string{"k6/fs"}
+		_, err := r.NewVU(make(chan stats.SampleContainer, 100))
+		assert.Contains(t, err.Error(), "the module 'k6/http' isn't in the allowed modules")
+	})
+
+	t.Run("Hosts", func(t *testing.T) {
+		testdata := map[string]struct {
+			opts lib.Options
+			err  string
+		}{
+			"allowed":     {lib.Options{AllowedHosts: []string{"httpbin.local"}}, ""},
+			"allowed net": {lib.Options{AllowedHosts: []string{"127.0.0.0/8"}}, ""},
+			"not allowed": {
+				lib.Options{AllowedHosts: []string{"*.example.com"}},
+				"the host 'httpbin.local' isn't in the allowed hosts",
+			},
+			"blocked": {
+				lib.Options{BlockedHosts: []string{"*"}},
+				"the host 'httpbin.local' is in the blocked hosts",
+			},
+		}
+		for name, data := range testdata {
+			t.Run(name, func(t *testing.T) {
+				opts := data.opts
+				opts.Throw = null.BoolFrom(true)
+				opts.Hosts = tb.Dialer.Hosts
+				require.NoError(t, r.SetOptions(opts))
+				vu, err := r.NewVU(make(chan stats.SampleContainer, 100))
+				require.NoError(t, err)
+				err = vu.RunOnce(context.Background())
+				if data.err == "" {
+					assert.NoError(t, err)
+				} else if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), data.err)
+				}
+			})
+		}
+
+		assert.EqualError(t, r.SetOptions(lib.Options{AllowedHosts: []string{"example.com:443"}}),
+			"invalid host pattern 'example.com:443', use a host name, '*.' followed by a domain, "+
+				"'*', an IP or a CIDR range")
+	})
+}
+
+func TestVUQuotas(t *testing.T) {
+	quotaViolations := func(samples chan stats.SampleContainer) map[string]float64 {
+		violations := make(map[string]float64)
+		for len(samples) > 0 {
+			for _, s := range (<-samples).GetSamples() {
+				if s.Metric == metrics.VUQuotaViolations {
+					quota, _ := s.Tags.Get("quota")
+					violations[quota] += s.Value
+				}
+			}
+		}
+		return violations
+	}
+	newQuotaVU := func(t *testing.T, src string, opts lib.Options) (*VU, chan stats.SampleContainer) {
+		r, err := New(&lib.SourceData{Filename: "/script.js", Data: []byte(src)}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+		require.NoError(t, err)
+		require.NoError(t, r.SetOptions(opts))
+		samples := make(chan stats.SampleContainer, 1000)
+		vu, err := r.NewVU(samples)
+		require.NoError(t, err)
+		return vu.(*VU), samples
+	}
+
+	t.Run("Memory", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "k6-quotas")
+		require.NoError(t, err)
+		defer func() { _ = os.RemoveAll(dir) }()
+
+		src := `
+			import fs from "k6/fs";
+			var kept = [];
+			var log;
+			export default function() {
+				if (!log) {
+					log = new fs.Open("kept.log", "w");
+				}
+				kept.push("x".repeat(1024));
+			}
+		`
+		vu, samples := newQuotaVU(t, src, lib.Options{
+			ArtifactsDir: null.StringFrom(dir),
+			VUQuotas:     &lib.VUQuotas{MaxMemory: null.StringFrom("4KB")},
+		})
+		for i := 0; i < 3; i++ {
+			vu.retainedNextCheck = time.Time{}
+			require.NoError(t, vu.RunOnce(context.Background()))
+		}
+		assert.Empty(t, quotaViolations(samples))
+		assert.Equal(t, int64(1), vu.handles.Open())
+
+		vu.retainedNextCheck = time.Time{}
+		assert.EqualError(t, vu.RunOnce(context.Background()),
+			"the VU's global variables grew by more than 4.0 kB, its quota")
+		assert.Equal(t, map[string]float64{"memory": 1}, quotaViolations(samples))
+
+		// The VU starts over with a new runtime, without the memory it kept or the files it opened.
+		assert.Equal(t, int64(0), vu.Runtime.Get("kept").ToObject(vu.Runtime).Get("length").ToInteger())
+		assert.Equal(t, int64(0), vu.handles.Open())
+		require.NoError(t, vu.RunOnce(context.Background()))
+	})
+
+	t.Run("MemorySampling", func(t *testing.T) {
+		src := `
+			var kept = [];
+			export default function() {
+				if (__ITER > 0) {
+					kept.push("x".repeat(1024));
+				}
+			}
+		`
+		vu, samples := newQuotaVU(t, src, lib.Options{VUQuotas: &lib.VUQuotas{MaxMemory: null.StringFrom("1KB")}})
+		before := time.Now()
+		require.NoError(t, vu.RunOnce(context.Background()))
+		assert.True(t, vu.retainedNextCheck.After(before))
+
+		// The globals aren't walked again until the next check is due.
+		vu.retainedNextCheck = time.Now().Add(time.Hour)
+		require.NoError(t, vu.RunOnce(context.Background()))
+		assert.Empty(t, quotaViolations(samples))
+
+		vu.retainedNextCheck = time.Time{}
+		assert.EqualError(t, vu.RunOnce(context.Background()),
+			"the VU's global variables grew by more than 1.0 kB, its quota")
+	})
+
+	t.Run("Handles", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "k6-quotas")
+		require.NoError(t, err)
+		defer func() { _ = os.RemoveAll(dir) }()
+
+		src := `
+			import fs from "k6/fs";
+			export default function() {
+				let a = new fs.Open("a.txt", "w");
+				try {
+					new fs.Open("b.txt", "w");
+				} finally {
+					a.close();
+				}
+			}
+		`
+		vu, samples := newQuotaVU(t, src, lib.Options{
+			ArtifactsDir: null.StringFrom(dir),
+			VUQuotas:     &lib.VUQuotas{MaxHandles: null.IntFrom(1)},
+		})
+		err = vu.RunOnce(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the VU can't have more than 1 connections and files open")
+		assert.Equal(t, map[string]float64{"handles": 1}, quotaViolations(samples))
+		assert.Equal(t, int64(0), vu.handles.Open())
+	})
+
+	t.Run("CPU", func(t *testing.T) {
+		if !hasThreadCPUClock {
+			t.Skip("the CPU time of threads can't be read on this system")
+		}
+		src := `
+			export default function() {
+				if (__ITER == 0) {
+					for (;;) {}
+				}
+			}
+		`
+		vu, samples := newQuotaVU(t, src, lib.Options{
+			VUQuotas: &lib.VUQuotas{MaxIterationCPU: types.NullDurationFrom(50 * time.Millisecond)},
+		})
+		assert.EqualError(t, vu.RunOnce(context.Background()),
+			"the iteration used more than 50ms of CPU time, the VU's quota")
+		assert.Equal(t, map[string]float64{"cpu": 1}, quotaViolations(samples))
+		require.NoError(t, vu.RunOnce(context.Background()))
+		assert.Empty(t, quotaViolations(samples))
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		src := `
+			var kept = "";
+			export default function() { kept += "x".repeat(4096); }
+		`
+		vu, _ := newQuotaVU(t, src, lib.Options{VUQuotas: &lib.VUQuotas{
+			MaxMemory: null.StringFrom("1KB"),
+			Policy:    null.StringFrom(lib.VUQuotaPolicyAbort),
+		}})
+		err := vu.RunOnce(context.Background())
+		require.IsType(t, lib.AbortError{}, err)
+		assert.Equal(t, "the VU's global variables grew by more than 1.0 kB, its quota", err.(lib.AbortError).Reason)
+	})
+}
+
 func TestVUIntegrationTLSConfig(t *testing.T) {
 	testdata := map[string]struct {
 		opts   lib.Options
@@ -1072,6 +1384,131 @@ func TestVUIntegrationCookiesNoReset(t *testing.T) {
 	}
 }
 
+func TestVUIntegrationCookiesShared(t *testing.T) {
+	tb := testutils.NewHTTPMultiBin(t)
+	defer tb.Cleanup()
+
+	r1, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(tb.Replacer.Replace(`
+			import http from "k6/http";
+			export default function() {
+				let url = "HTTPBIN_URL";
+				if (__VU == 1) {
+					let res = http.get(url + "/cookies/set?k2=v2&k1=v1");
+					if (res.status != 200) { throw new Error("wrong status: " + res.status) }
+				}
+
+				if (__VU == 2) {
+					let res = http.get(url + "/cookies");
+					if (res.status != 200) { throw new Error("wrong status (pre): " + res.status); }
+					if (res.json().k1 != "v1" || res.json().k2 != "v2") {
+						throw new Error("wrong cookies: " + res.body);
+					}
+				}
+			}
+		`)),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	r1.SetOptions(lib.Options{
+		Throw:        null.BoolFrom(true),
+		MaxRedirects: null.IntFrom(10),
+		Hosts:        tb.Dialer.Hosts,
+		CookieJar:    null.StringFrom(lib.CookieJarShared),
+	})
+
+	r2, err := NewFromArchive(r1.MakeArchive(), lib.RuntimeOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	runners := map[string]*Runner{"Source": r1, "Archive": r2}
+	for name, r := range runners {
+		t.Run(name, func(t *testing.T) {
+			vu1, err := r.NewVU(make(chan stats.SampleContainer, 100))
+			if !assert.NoError(t, err) {
+				return
+			}
+			vu2, err := r.NewVU(make(chan stats.SampleContainer, 100))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.NoError(t, vu1.Reconfigure(1))
+			assert.NoError(t, vu2.Reconfigure(2))
+
+			err = vu1.RunOnce(context.Background())
+			assert.NoError(t, err)
+
+			err = vu2.RunOnce(context.Background())
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestVUIntegrationCircuitBreaker(t *testing.T) {
+	tb := testutils.NewHTTPMultiBin(t)
+	defer tb.Cleanup()
+
+	// The circuits are part of the options, which an archive shares with its source runner, so
+	// every runner gets its own breaker.
+	newRunner := func(t *testing.T) *Runner {
+		r, err := New(&lib.SourceData{
+			Filename: "/script.js",
+			Data: []byte(tb.Replacer.Replace(`
+				import http from "k6/http";
+				export default function() {
+					http.get("HTTPBIN_URL/status/500", { tags: { name: "broken" } });
+					http.get("HTTPBIN_URL/status/500", { tags: { name: "broken" } });
+					if (__ITER > 0) { throw new Error("the iteration wasn't skipped"); }
+				}
+			`)),
+		}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+		require.NoError(t, err)
+		breaker := &lib.CircuitBreaker{}
+		require.NoError(t, breaker.UnmarshalText([]byte("errorRate=0.5,minRequests=2,cooldown=1h")))
+		r.SetOptions(lib.Options{
+			Hosts:          tb.Dialer.Hosts,
+			CircuitBreaker: breaker,
+		})
+		return r
+	}
+
+	runners := map[string]func(t *testing.T) *Runner{
+		"Source": newRunner,
+		"Archive": func(t *testing.T) *Runner {
+			r, err := NewFromArchive(newRunner(t).MakeArchive(), lib.RuntimeOptions{})
+			require.NoError(t, err)
+			return r
+		},
+	}
+	for name, newRunner := range runners {
+		t.Run(name, func(t *testing.T) {
+			samples := make(chan stats.SampleContainer, 100)
+			vu, err := newRunner(t).NewVU(samples)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.NoError(t, vu.RunOnce(context.Background()))
+			assert.NoError(t, vu.RunOnce(context.Background()))
+
+			var skipped int
+			for _, container := range stats.GetBufferedSamples(samples) {
+				for _, sample := range container.GetSamples() {
+					if sample.Metric == metrics.SkippedIterations {
+						skipped++
+						tag, _ := sample.Tags.Get("circuit_breaker")
+						assert.Equal(t, "broken", tag)
+					}
+				}
+			}
+			assert.Equal(t, 1, skipped)
+		})
+	}
+}
+
 func TestVUIntegrationVUID(t *testing.T) {
 	r1, err := New(&lib.SourceData{
 		Filename: "/script.js",
@@ -1248,6 +1685,352 @@ func TestVUIntegrationClientCerts(t *testing.T) {
 	})
 }
 
+func generateTestClientCert(t *testing.T, certs *testutils.TestCerts, cn string) *lib.TLSAuth {
+	cert, key := testutils.CertificatePEM(t, certs.NewClientCert(t, cn))
+	return &lib.TLSAuth{TLSAuthFields: lib.TLSAuthFields{Cert: cert, Key: key}}
+}
+
+func TestVUIntegrationTLSAuthPool(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprint(w, req.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.Config.ErrorLog = stdlog.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	certs := testutils.NewTestCerts(t)
+
+	r1, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(fmt.Sprintf(`
+			import http from "k6/http";
+			export default function() {
+				let res = http.get("%s");
+				if (res.body !== "client" + __ITER) {
+					throw new Error("wrong client certificate: " + res.body);
+				}
+			}
+		`, srv.URL)),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+	require.NoError(t, r1.SetOptions(lib.Options{
+		Throw:                 null.BoolFrom(true),
+		InsecureSkipTLSVerify: null.BoolFrom(true),
+		TLSAuthPool: []*lib.TLSAuth{
+			generateTestClientCert(t, certs, "client0"),
+			generateTestClientCert(t, certs, "client1"),
+		},
+	}))
+
+	r2, err := NewFromArchive(r1.MakeArchive(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	runners := map[string]*Runner{"Source": r1, "Archive": r2}
+	for name, r := range runners {
+		t.Run(name, func(t *testing.T) {
+			// The script expects the certificate to match the iteration number, so reassigning
+			// the VU's ID before each iteration must switch it to the matching certificate.
+			vu, err := r.newVU(make(chan stats.SampleContainer, 100))
+			require.NoError(t, err)
+			for _, id := range []int64{1, 2, 3} {
+				require.NoError(t, vu.Reconfigure(id))
+				vu.Iteration = (id - 1) % 2
+				assert.NoError(t, vu.RunOnce(context.Background()), "VU %d", id)
+			}
+		})
+	}
+
+	t.Run("Segment", func(t *testing.T) {
+		// The second of two instances only hands out the second half of the pool.
+		opts := r1.GetOptions()
+		opts.TLSAuthPool = append(opts.TLSAuthPool,
+			generateTestClientCert(t, certs, "client2"),
+			generateTestClientCert(t, certs, "client3"),
+		)
+		opts.ExecutionSegment = &lib.ExecutionSegment{Index: 1, Count: 2}
+		require.NoError(t, r1.SetOptions(opts))
+
+		vu, err := r1.newVU(make(chan stats.SampleContainer, 100))
+		require.NoError(t, err)
+		for _, id := range []int64{1, 2, 3} {
+			require.NoError(t, vu.Reconfigure(id))
+			vu.Iteration = 2 + (id-1)%2
+			assert.NoError(t, vu.RunOnce(context.Background()), "VU %d", id)
+		}
+
+		// With fewer certificates than instances, some instances get none.
+		opts.TLSAuthPool = opts.TLSAuthPool[:1]
+		opts.ExecutionSegment = &lib.ExecutionSegment{Index: 0, Count: 2}
+		require.NoError(t, r1.SetOptions(opts))
+		assert.EqualError(t, vu.Reconfigure(1), "the tlsAuthPool has no client certificates for execution segment 0/2")
+	})
+}
+
+func TestVUIntegrationTLSSessionCache(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprint(w, "ok")
+	}))
+	srv.Config.ErrorLog = stdlog.New(ioutil.Discard, "", 0)
+	defer srv.Close()
+
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(fmt.Sprintf(`
+			import http from "k6/http";
+			export default function() {
+				return http.get("%s").tls_resumed;
+			}
+		`, srv.URL)),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	// Returns whether each iteration of each VU resumed a TLS session, according to the response
+	// and to the tls_handshakes_resumed metric.
+	run := func(t *testing.T, mode string, vus, iterations int) [][]bool {
+		require.NoError(t, r.SetOptions(lib.Options{
+			Throw:                 null.BoolFrom(true),
+			InsecureSkipTLSVerify: null.BoolFrom(true),
+			NoConnectionReuse:     null.BoolFrom(true),
+			TLSSessionCache:       null.NewString(mode, mode != ""),
+		}))
+		results := make([][]bool, vus)
+		for i := range results {
+			samples := make(chan stats.SampleContainer, 100)
+			vu, err := r.newVU(samples)
+			require.NoError(t, err)
+			for j := 0; j < iterations; j++ {
+				v, _, err := vu.runFn(context.Background(), r.defaultGroup, vu.Default)
+				require.NoError(t, err)
+				results[i] = append(results[i], v.ToBoolean())
+			}
+
+			var resumed []bool
+			for _, sample := range stats.GetBufferedSamples(samples) {
+				for _, s := range sample.GetSamples() {
+					if s.Metric == metrics.TLSHandshakesResumed {
+						resumed = append(resumed, s.Value == 1)
+					}
+				}
+			}
+			assert.Equal(t, results[i], resumed)
+		}
+		return results
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		assert.Equal(t, [][]bool{{false, false}}, run(t, "", 1, 2))
+	})
+	t.Run("None", func(t *testing.T) {
+		assert.Equal(t, [][]bool{{false, false}}, run(t, "none", 1, 2))
+	})
+	t.Run("VU", func(t *testing.T) {
+		assert.Equal(t, [][]bool{{false, true}, {false, true}}, run(t, "vu", 2, 2))
+	})
+	t.Run("Shared", func(t *testing.T) {
+		assert.Equal(t, [][]bool{{false, true}, {true, true}}, run(t, "shared", 2, 2))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		err := r.SetOptions(lib.Options{TLSSessionCache: null.StringFrom("global")})
+		assert.EqualError(t, err, "invalid TLS session cache mode 'global', use: 'none', 'vu' or 'shared'")
+	})
+}
+
+func TestVUIntegrationConnectionReuse(t *testing.T) {
+	var newConns int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprint(w, "ok")
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&newConns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(fmt.Sprintf(`
+			import http from "k6/http";
+			export default function() {
+				http.get("%s");
+				http.get("%s");
+			}
+		`, srv.URL, srv.URL)),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	// Returns how many connections 2 VUs open over 2 iterations each, of 2 requests each.
+	run := func(t *testing.T, opts lib.Options) int64 {
+		opts.Throw = null.BoolFrom(true)
+		require.NoError(t, r.SetOptions(opts))
+		atomic.StoreInt64(&newConns, 0)
+		for i := 0; i < 2; i++ {
+			vu, err := r.newVU(make(chan stats.SampleContainer, 100))
+			require.NoError(t, err)
+			for j := 0; j < 2; j++ {
+				_, _, err := vu.runFn(context.Background(), r.defaultGroup, vu.Default)
+				require.NoError(t, err)
+			}
+		}
+		return atomic.LoadInt64(&newConns)
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		assert.Equal(t, int64(2), run(t, lib.Options{}))
+	})
+	t.Run("VU", func(t *testing.T) {
+		assert.Equal(t, int64(2), run(t, lib.Options{ConnectionReuse: null.StringFrom("vu")}))
+	})
+	t.Run("Iteration", func(t *testing.T) {
+		assert.Equal(t, int64(4), run(t, lib.Options{ConnectionReuse: null.StringFrom("iteration")}))
+		assert.Equal(t, int64(4), run(t, lib.Options{NoVUConnectionReuse: null.BoolFrom(true)}))
+	})
+	t.Run("None", func(t *testing.T) {
+		assert.Equal(t, int64(8), run(t, lib.Options{ConnectionReuse: null.StringFrom("none")}))
+		assert.Equal(t, int64(8), run(t, lib.Options{NoConnectionReuse: null.BoolFrom(true)}))
+	})
+	t.Run("Shared", func(t *testing.T) {
+		assert.Equal(t, int64(1), run(t, lib.Options{ConnectionReuse: null.StringFrom("shared")}))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		err := r.SetOptions(lib.Options{ConnectionReuse: null.StringFrom("global")})
+		assert.EqualError(t, err, "invalid connection reuse mode 'global', use: 'vu', 'iteration', 'none' or 'shared'")
+	})
+	t.Run("SharedWithTLSAuthPool", func(t *testing.T) {
+		err := r.SetOptions(lib.Options{
+			ConnectionReuse: null.StringFrom("shared"),
+			TLSAuthPool:     []*lib.TLSAuth{{}},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestVUIntegrationTLSGroups(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprint(w, "ok")
+	}))
+	srv.TLS = &tls.Config{CurvePreferences: []tls.CurveID{tls.CurveP384}}
+	srv.Config.ErrorLog = stdlog.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(fmt.Sprintf(`
+			import http from "k6/http";
+			export default function() {
+				let res = http.get("%s");
+				if (res.error) {
+					throw new Error(res.error);
+				}
+			}
+		`, srv.URL)),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+	r.Logger, _ = logtest.NewNullLogger()
+
+	testdata := map[string]struct {
+		groups lib.TLSGroups
+		ok     bool
+	}{
+		"Default":  {nil, true},
+		"Match":    {lib.TLSGroups{tls.X25519, tls.CurveP384}, true},
+		"No match": {lib.TLSGroups{tls.CurveP256}, false},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, r.SetOptions(lib.Options{
+				InsecureSkipTLSVerify: null.BoolFrom(true),
+				TLSGroups:             data.groups,
+			}))
+			vu, err := r.newVU(make(chan stats.SampleContainer, 100))
+			require.NoError(t, err)
+			_, _, err = vu.runFn(context.Background(), r.defaultGroup, vu.Default)
+			if data.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVUIntegrationTLSRootCAs(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Staging CA"},
+		NotBefore:             time.Now().Add(-1 * time.Hour),
+		NotAfter:              time.Now().Add(1 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	srvKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	srvDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-1 * time.Hour),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}, caCert, &srvKey.PublicKey, caKey)
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprint(w, "ok")
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{srvDER}, PrivateKey: srvKey}}}
+	srv.Config.ErrorLog = stdlog.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	r1, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(fmt.Sprintf(`
+			import http from "k6/http";
+			export default function() { http.get("%s"); }
+		`, srv.URL)),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	t.Run("Untrusted", func(t *testing.T) {
+		r1.Logger, _ = logtest.NewNullLogger()
+		require.NoError(t, r1.SetOptions(lib.Options{Throw: null.BoolFrom(true)}))
+		vu, err := r1.NewVU(make(chan stats.SampleContainer, 100))
+		require.NoError(t, err)
+		err = vu.RunOnce(context.Background())
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "certificate signed by unknown authority")
+		}
+	})
+
+	require.NoError(t, r1.SetOptions(lib.Options{
+		Throw:      null.BoolFrom(true),
+		TLSRootCAs: lib.TLSRootCAs{string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))},
+	}))
+	r2, err := NewFromArchive(r1.MakeArchive(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	runners := map[string]*Runner{"Source": r1, "Archive": r2}
+	for name, r := range runners {
+		t.Run("Trusted/"+name, func(t *testing.T) {
+			vu, err := r.NewVU(make(chan stats.SampleContainer, 100))
+			require.NoError(t, err)
+			assert.NoError(t, vu.RunOnce(context.Background()))
+		})
+	}
+}
+
 func TestHTTPRequestInInitContext(t *testing.T) {
 	tb := testutils.NewHTTPMultiBin(t)
 	defer tb.Cleanup()
@@ -1430,6 +2213,45 @@ func TestArchiveNotPanicking(t *testing.T) {
 	}
 }
 
+func TestArchiveAssets(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/path/to/media/a.bin", []byte{1, 2}, os.ModePerm))
+	require.NoError(t, afero.WriteFile(fs, "/path/to/media/b.bin", []byte{1, 2, 3}, os.ModePerm))
+	require.NoError(t, afero.WriteFile(fs, "/path/to/other.txt", []byte(`other`), os.ModePerm))
+	r1, err := New(&lib.SourceData{
+		Filename: "/path/to/script.js",
+		Data: []byte(`
+			let media = open("./media/" + (__ENV.MEDIA || "a.bin"), "b");
+			export default function() {
+				if (media.length != __ENV.SIZE) {
+					throw new Error("unexpected media size: " + media.length);
+				}
+			}
+		`),
+	}, fs, lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	assert.EqualError(t, r1.SetOptions(lib.Options{Assets: []string{"./certs/*.pem"}}),
+		"the asset pattern '/path/to/certs/*.pem' doesn't match any files")
+	require.NoError(t, r1.SetOptions(lib.Options{Assets: []string{"./media/*.bin"}}))
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, r1.MakeArchive().Write(buf))
+	arc, err := lib.ReadArchive(buf)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"/path/to/media/a.bin": {1, 2},
+		"/path/to/media/b.bin": {1, 2, 3},
+	}, arc.Files)
+
+	// The archive doesn't need the filesystem to open the file the script didn't open before.
+	r2, err := NewFromArchive(arc, lib.RuntimeOptions{Env: map[string]string{"MEDIA": "b.bin", "SIZE": "3"}})
+	require.NoError(t, err)
+	vu, err := r2.NewVU(make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+	assert.NoError(t, vu.RunOnce(context.Background()))
+}
+
 func TestStuffNotPanicking(t *testing.T) {
 	tb := testutils.NewHTTPMultiBin(t)
 	defer tb.Cleanup()
@@ -1495,3 +2317,47 @@ func TestStuffNotPanicking(t *testing.T) {
 		require.NoError(t, err)
 	}
 }
+
+func TestVUIntegrationHTTPRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(fmt.Sprintf(`
+			import http from "k6/http";
+			export default function() {
+				http.post("%s/path?token=secret", "data", { headers: { Authorization: "Bearer secret" } });
+			}
+		`, srv.URL)),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+	r.Logger, _ = logtest.NewNullLogger()
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.Nil(t, r.HTTPRecorder())
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		require.NoError(t, r.SetOptions(lib.Options{HAROutput: null.StringFrom("out.har")}))
+		require.NotNil(t, r.HTTPRecorder())
+
+		vu, err := r.newVU(make(chan stats.SampleContainer, 100))
+		require.NoError(t, err)
+		_, _, err = vu.runFn(context.Background(), r.defaultGroup, vu.Default)
+		require.NoError(t, err)
+
+		exchanges := r.HTTPRecorder().Exchanges()
+		require.Len(t, exchanges, 1)
+		assert.Equal(t, "POST", exchanges[0].Method)
+		assert.Equal(t, srv.URL+"/path?token=secret", exchanges[0].URL)
+		assert.Equal(t, "Bearer secret", exchanges[0].RequestHeaders.Get("Authorization"))
+		assert.Equal(t, "data", exchanges[0].RequestBody)
+		assert.Equal(t, 200, exchanges[0].Status)
+		assert.Equal(t, []byte("ok"), exchanges[0].ResponseBody)
+		assert.NotNil(t, exchanges[0].Trail)
+	})
+}
@@ -0,0 +1,43 @@
+// +build go1.23
+
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"crypto/tls"
+
+	"github.com/pkg/errors"
+)
+
+// configureECH enables Encrypted Client Hello with the given ECHConfigList, if there is one.
+func configureECH(tlsConfig *tls.Config, configList []byte) error {
+	if len(configList) == 0 {
+		return nil
+	}
+	// ECH is a TLS 1.3 extension; crypto/tls refuses to use it if older versions are allowed.
+	if tlsConfig.MaxVersion != 0 && tlsConfig.MaxVersion < tls.VersionTLS13 {
+		return errors.New("tlsECHConfigList requires TLS 1.3, which tlsVersion doesn't allow")
+	}
+	tlsConfig.MinVersion = tls.VersionTLS13
+	tlsConfig.EncryptedClientHelloConfigList = configList
+	return nil
+}
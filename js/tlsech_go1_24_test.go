@@ -0,0 +1,125 @@
+// +build go1.24
+
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// appendUint16Prefixed appends data to b, prefixed with its length as a big-endian uint16.
+func appendUint16Prefixed(b []byte, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+// newTestECHConfig builds an ECHConfig for DHKEM(X25519, HKDF-SHA256), HKDF-SHA256 and
+// AES-128-GCM, as defined in draft-ietf-tls-esni.
+func newTestECHConfig(t *testing.T, publicName string) ([]byte, *ecdh.PrivateKey) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var contents []byte
+	contents = append(contents, 1)          // config_id
+	contents = append(contents, 0x00, 0x20) // kem_id
+	contents = appendUint16Prefixed(contents, key.PublicKey().Bytes())
+	contents = appendUint16Prefixed(contents, []byte{0x00, 0x01, 0x00, 0x01}) // cipher_suites
+	contents = append(contents, 0)                                            // maximum_name_length
+	contents = append(contents, byte(len(publicName)))
+	contents = append(contents, publicName...)
+	contents = appendUint16Prefixed(contents, nil) // extensions
+
+	config := []byte{0xfe, 0x0d} // version
+	config = appendUint16Prefixed(config, contents)
+	return config, key
+}
+
+func TestVUIntegrationTLSECH(t *testing.T) {
+	config, key := newTestECHConfig(t, "public.example.com")
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprint(w, req.TLS.ECHAccepted)
+	}))
+	srv.TLS = &tls.Config{
+		EncryptedClientHelloKeys: []tls.EncryptedClientHelloKey{
+			{Config: config, PrivateKey: key.Bytes(), SendAsRetry: true},
+		},
+	}
+	srv.Config.ErrorLog = stdlog.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	r, err := New(&lib.SourceData{
+		Filename: "/script.js",
+		Data: []byte(fmt.Sprintf(`
+			import http from "k6/http";
+			export default function() {
+				return http.get("%s").body;
+			}
+		`, srv.URL)),
+	}, afero.NewMemMapFs(), lib.RuntimeOptions{})
+	require.NoError(t, err)
+
+	run := func(t *testing.T, configList lib.TLSECHConfigList) string {
+		require.NoError(t, r.SetOptions(lib.Options{
+			Throw:                 null.BoolFrom(true),
+			InsecureSkipTLSVerify: null.BoolFrom(true),
+			TLSECHConfigList:      configList,
+		}))
+		vu, err := r.newVU(make(chan stats.SampleContainer, 100))
+		require.NoError(t, err)
+		v, _, err := vu.runFn(context.Background(), r.defaultGroup, vu.Default)
+		require.NoError(t, err)
+		return v.String()
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.Equal(t, "false", run(t, nil))
+	})
+	t.Run("Enabled", func(t *testing.T) {
+		assert.Equal(t, "true", run(t, appendUint16Prefixed(nil, config)))
+	})
+	t.Run("TLS 1.2", func(t *testing.T) {
+		require.NoError(t, r.SetOptions(lib.Options{
+			TLSVersion:       &lib.TLSVersions{Max: tls.VersionTLS12},
+			TLSECHConfigList: appendUint16Prefixed(nil, config),
+		}))
+		_, err := r.newVU(make(chan stats.SampleContainer, 100))
+		assert.EqualError(t, err, "tlsECHConfigList requires TLS 1.3, which tlsVersion doesn't allow")
+	})
+}
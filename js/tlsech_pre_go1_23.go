@@ -0,0 +1,38 @@
+// +build !go1.23
+
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"crypto/tls"
+
+	"github.com/pkg/errors"
+)
+
+// configureECH returns an error if an ECHConfigList is given; crypto/tls only supports Encrypted
+// Client Hello since Go 1.23.
+func configureECH(tlsConfig *tls.Config, configList []byte) error {
+	if len(configList) == 0 {
+		return nil
+	}
+	return errors.New("tlsECHConfigList requires k6 to be built with Go 1.23 or newer")
+}
@@ -0,0 +1,24 @@
+package lib
+
+// AbortError is used when the script aborts the test
+type AbortError struct {
+	Reason string
+	// ExitCode is the exit code k6 should use, or 0 for the default one
+	ExitCode int
+}
+
+// NewAbortError returns a new AbortError with the provided reason and exit code
+func NewAbortError(reason string, exitCode int) AbortError {
+	return AbortError{Reason: reason, ExitCode: exitCode}
+}
+
+func (a AbortError) String() string {
+	if a.Reason == "" {
+		return "Test aborted by the script"
+	}
+	return "Test aborted by the script: " + a.Reason
+}
+
+func (a AbortError) Error() string {
+	return a.String()
+}
@@ -0,0 +1,206 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// Defaults for the AdaptiveRate fields that aren't set.
+const (
+	DefaultAdaptiveRateStart      = 1
+	DefaultAdaptiveRateMetric     = "http_req_duration"
+	DefaultAdaptiveRatePercentile = 95
+	DefaultAdaptiveRateInterval   = 10 * time.Second
+)
+
+// AdaptiveRateFields defines the fields used for an AdaptiveRate; see StageFields for why this
+// is a separate type.
+type AdaptiveRateFields struct {
+	// Iterations per second to start at, and the most to ever go up to (0 for no limit).
+	StartRate null.Int `json:"startRate"`
+	MaxRate   null.Int `json:"maxRate"`
+
+	// How many iterations per second to add after every interval in which the SLO held.
+	// Defaults to the start rate.
+	Step null.Int `json:"step"`
+
+	// The SLO: the given percentile of a trend metric must stay below the target...
+	Metric     null.String        `json:"metric"`
+	Percentile null.Float         `json:"percentile"`
+	Target     types.NullDuration `json:"target"`
+
+	// ...and at most this fraction of the HTTP requests may fail.
+	MaxErrorRate null.Float `json:"maxErrorRate"`
+
+	// How long to hold each rate before evaluating the SLO and adjusting it.
+	Interval types.NullDuration `json:"interval"`
+}
+
+// An AdaptiveRate starts iterations at a rate that's adjusted to hold a latency and error rate
+// SLO, eg. to find the maximum throughput a system can sustain with p(95) < 300ms.
+type AdaptiveRate AdaptiveRateFields
+
+func (r *AdaptiveRate) UnmarshalJSON(b []byte) error {
+	var fields AdaptiveRateFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*r = AdaptiveRate(fields)
+	return r.Validate()
+}
+
+func (r AdaptiveRate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(AdaptiveRateFields(r))
+}
+
+// UnmarshalText parses a comma-separated list of key=value pairs, using the same keys as the
+// JSON representation, eg. "target=300ms,percentile=99,maxRate=500". An empty string unsets it.
+func (r *AdaptiveRate) UnmarshalText(b []byte) error {
+	var rate AdaptiveRate
+	if strings.TrimSpace(string(b)) == "" {
+		*r = rate
+		return nil
+	}
+	for _, part := range strings.Split(string(b), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("invalid adaptive rate parameter '%s', expected key=value", part)
+		}
+		key, value := kv[0], kv[1]
+
+		var err error
+		switch key {
+		case "startRate", "maxRate", "step":
+			var i int64
+			if i, err = strconv.ParseInt(value, 10, 64); err == nil {
+				switch key {
+				case "startRate":
+					rate.StartRate = null.IntFrom(i)
+				case "maxRate":
+					rate.MaxRate = null.IntFrom(i)
+				case "step":
+					rate.Step = null.IntFrom(i)
+				}
+			}
+		case "percentile", "maxErrorRate":
+			var f float64
+			if f, err = strconv.ParseFloat(value, 64); err == nil {
+				if key == "percentile" {
+					rate.Percentile = null.FloatFrom(f)
+				} else {
+					rate.MaxErrorRate = null.FloatFrom(f)
+				}
+			}
+		case "target", "interval":
+			var d time.Duration
+			if d, err = time.ParseDuration(value); err == nil {
+				if key == "target" {
+					rate.Target = types.NullDurationFrom(d)
+				} else {
+					rate.Interval = types.NullDurationFrom(d)
+				}
+			}
+		case "metric":
+			rate.Metric = null.StringFrom(value)
+		default:
+			return errors.Errorf("unknown adaptive rate parameter '%s'", key)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "adaptive rate parameter '%s'", key)
+		}
+	}
+	*r = rate
+	return r.Validate()
+}
+
+// Validate checks that the SLO is defined and that all values are in range.
+func (r AdaptiveRate) Validate() error {
+	if !r.Target.Valid && !r.MaxErrorRate.Valid {
+		return errors.New("an adaptive rate needs a latency target, a max error rate or both")
+	}
+	if r.StartRate.Valid && r.StartRate.Int64 <= 0 {
+		return errors.New("the adaptive start rate must be positive")
+	}
+	if r.MaxRate.Valid && r.MaxRate.Int64 < 0 {
+		return errors.New("the adaptive max rate can't be negative")
+	}
+	if r.Step.Valid && r.Step.Int64 <= 0 {
+		return errors.New("the adaptive rate step must be positive")
+	}
+	if r.Percentile.Valid && (r.Percentile.Float64 <= 0 || r.Percentile.Float64 > 100) {
+		return errors.New("the adaptive rate percentile must be in (0, 100]")
+	}
+	if r.MaxErrorRate.Valid && (r.MaxErrorRate.Float64 < 0 || r.MaxErrorRate.Float64 > 1) {
+		return errors.New("the adaptive max error rate must be in [0, 1]")
+	}
+	if r.Interval.Valid && r.Interval.Duration <= 0 {
+		return errors.New("the adaptive rate interval must be positive")
+	}
+	return nil
+}
+
+// GetStartRate returns the start rate, or its default.
+func (r AdaptiveRate) GetStartRate() int64 {
+	if r.StartRate.Valid {
+		return r.StartRate.Int64
+	}
+	return DefaultAdaptiveRateStart
+}
+
+// GetStep returns the rate step, defaulting to the start rate.
+func (r AdaptiveRate) GetStep() int64 {
+	if r.Step.Valid {
+		return r.Step.Int64
+	}
+	return r.GetStartRate()
+}
+
+// GetMetric returns the name of the metric the latency target applies to, or its default.
+func (r AdaptiveRate) GetMetric() string {
+	if r.Metric.Valid {
+		return r.Metric.String
+	}
+	return DefaultAdaptiveRateMetric
+}
+
+// GetPercentile returns the percentile of the latency target, or its default.
+func (r AdaptiveRate) GetPercentile() float64 {
+	if r.Percentile.Valid {
+		return r.Percentile.Float64
+	}
+	return DefaultAdaptiveRatePercentile
+}
+
+// GetInterval returns the adjustment interval, or its default.
+func (r AdaptiveRate) GetInterval() time.Duration {
+	if r.Interval.Valid {
+		return time.Duration(r.Interval.Duration)
+	}
+	return DefaultAdaptiveRateInterval
+}
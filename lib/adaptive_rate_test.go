@@ -0,0 +1,84 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestAdaptiveRate(t *testing.T) {
+	full := AdaptiveRate{
+		StartRate:    null.IntFrom(10),
+		MaxRate:      null.IntFrom(500),
+		Step:         null.IntFrom(5),
+		Metric:       null.StringFrom("iteration_duration"),
+		Percentile:   null.FloatFrom(99),
+		Target:       types.NullDurationFrom(300 * time.Millisecond),
+		MaxErrorRate: null.FloatFrom(0.01),
+		Interval:     types.NullDurationFrom(5 * time.Second),
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(full)
+		assert.NoError(t, err)
+
+		var rate AdaptiveRate
+		assert.NoError(t, json.Unmarshal(data, &rate))
+		assert.Equal(t, full, rate)
+
+		assert.EqualError(t, json.Unmarshal([]byte(`{"startRate": 10}`), &rate),
+			"an adaptive rate needs a latency target, a max error rate or both")
+	})
+	t.Run("Text", func(t *testing.T) {
+		var rate AdaptiveRate
+		assert.NoError(t, rate.UnmarshalText([]byte(
+			"startRate=10,maxRate=500,step=5,metric=iteration_duration,percentile=99,"+
+				"target=300ms,maxErrorRate=0.01,interval=5s",
+		)))
+		assert.Equal(t, full, rate)
+
+		assert.EqualError(t, rate.UnmarshalText([]byte("target")),
+			"invalid adaptive rate parameter 'target', expected key=value")
+		assert.EqualError(t, rate.UnmarshalText([]byte("rate=1")),
+			"unknown adaptive rate parameter 'rate'")
+		assert.EqualError(t, rate.UnmarshalText([]byte("target=fast")),
+			"adaptive rate parameter 'target': time: invalid duration \"fast\"")
+		assert.EqualError(t, rate.UnmarshalText([]byte("maxErrorRate=2")),
+			"the adaptive max error rate must be in [0, 1]")
+	})
+	t.Run("Defaults", func(t *testing.T) {
+		rate := AdaptiveRate{Target: types.NullDurationFrom(time.Second)}
+		assert.Equal(t, int64(DefaultAdaptiveRateStart), rate.GetStartRate())
+		assert.Equal(t, int64(DefaultAdaptiveRateStart), rate.GetStep())
+		assert.Equal(t, DefaultAdaptiveRateMetric, rate.GetMetric())
+		assert.Equal(t, float64(DefaultAdaptiveRatePercentile), rate.GetPercentile())
+		assert.Equal(t, DefaultAdaptiveRateInterval, rate.GetInterval())
+
+		assert.Equal(t, int64(5), full.GetStep())
+		assert.Equal(t, 5*time.Second, full.GetInterval())
+	})
+}
@@ -23,6 +23,8 @@ package lib
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -34,6 +36,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 )
 
@@ -84,6 +87,10 @@ type Archive struct {
 
 	FS afero.Fs `json:"-"`
 
+	// Integrity hashes of the scripts and files, by their path; only used in the written archive,
+	// and checked when it's read, so that a damaged or tampered with archive isn't run.
+	Integrity map[string]string `json:"integrity,omitempty"`
+
 	// Environment variables
 	Env map[string]string `json:"env"`
 }
@@ -157,9 +164,46 @@ func ReadArchive(in io.Reader) (*Archive, error) {
 		}
 	}
 
+	if err := arc.checkIntegrity(); err != nil {
+		return nil, err
+	}
+	arc.Integrity = nil
 	return arc, nil
 }
 
+// IntegrityHash returns the hash of a file in an archive, in the Subresource Integrity format.
+func IntegrityHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// checkIntegrity checks the scripts and files against their integrity hashes. Older archives
+// don't have any, so there's nothing to check.
+func (arc *Archive) checkIntegrity() error {
+	if arc.Integrity == nil {
+		return nil
+	}
+	for name, hash := range arc.Integrity {
+		data, ok := arc.Scripts[name]
+		if !ok {
+			if data, ok = arc.Files[name]; !ok {
+				return errors.Errorf("archive integrity check failed: '%s' is missing", name)
+			}
+		}
+		if IntegrityHash(data) != hash {
+			return errors.Errorf("archive integrity check failed: '%s' was modified", name)
+		}
+	}
+	for _, files := range []map[string][]byte{arc.Scripts, arc.Files} {
+		for name := range files {
+			if _, ok := arc.Integrity[name]; !ok {
+				return errors.Errorf("archive integrity check failed: '%s' was added", name)
+			}
+		}
+	}
+	return nil
+}
+
 // Write serialises the archive to a writer.
 //
 // The format should be treated as opaque; currently it is simply a TAR rollup, but this may
@@ -172,6 +216,12 @@ func (arc *Archive) Write(out io.Writer) error {
 	metaArc := *arc
 	metaArc.Filename = NormalizeAndAnonymizePath(metaArc.Filename)
 	metaArc.Pwd = NormalizeAndAnonymizePath(metaArc.Pwd)
+	metaArc.Integrity = make(map[string]string, len(arc.Scripts)+len(arc.Files))
+	for _, files := range []map[string][]byte{arc.Scripts, arc.Files} {
+		for filePath, data := range files {
+			metaArc.Integrity[NormalizeAndAnonymizePath(filePath)] = IntegrityHash(data)
+		}
+	}
 	metadata, err := metaArc.json()
 	if err != nil {
 		return err
@@ -21,12 +21,17 @@
 package lib
 
 import (
+	"archive/tar"
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	null "gopkg.in/guregu/null.v3"
 )
 
@@ -150,6 +155,98 @@ func TestArchiveReadWrite(t *testing.T) {
 	})
 }
 
+func TestArchiveIntegrity(t *testing.T) {
+	arc := &Archive{
+		Type:     "js",
+		Filename: "/path/to/script.js",
+		Data:     []byte(`// contents...`),
+		Pwd:      "/path/to",
+		Scripts:  map[string][]byte{"/path/to/a.js": []byte(`// a contents`)},
+		Files:    map[string][]byte{"/path/to/cert.der": {0x30, 0x82, 0x01}},
+	}
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, arc.Write(buf))
+	written := buf.Bytes()
+
+	// rewrite copies the written archive, passing the contents of every entry through fn.
+	rewrite := func(fn func(name string, data []byte) []byte) *bytes.Buffer {
+		out := bytes.NewBuffer(nil)
+		r, w := tar.NewReader(bytes.NewReader(written)), tar.NewWriter(out)
+		for {
+			hdr, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			data, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			if data = fn(hdr.Name, data); data == nil {
+				continue
+			}
+			hdr.Size = int64(len(data))
+			require.NoError(t, w.WriteHeader(hdr))
+			_, err = w.Write(data)
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+		return out
+	}
+
+	t.Run("Intact", func(t *testing.T) {
+		var metadata map[string]interface{}
+		rewrite(func(name string, data []byte) []byte {
+			if name == "metadata.json" {
+				require.NoError(t, json.Unmarshal(data, &metadata))
+			}
+			return data
+		})
+		assert.Equal(t, map[string]interface{}{
+			"/path/to/a.js":     IntegrityHash([]byte(`// a contents`)),
+			"/path/to/cert.der": IntegrityHash([]byte{0x30, 0x82, 0x01}),
+		}, metadata["integrity"])
+		assert.Equal(t, "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", IntegrityHash(nil))
+
+		arc2, err := ReadArchive(bytes.NewReader(written))
+		require.NoError(t, err)
+		assert.Equal(t, arc.Files, arc2.Files)
+		assert.Nil(t, arc2.Integrity)
+	})
+	t.Run("Modified", func(t *testing.T) {
+		_, err := ReadArchive(rewrite(func(name string, data []byte) []byte {
+			if name == "files/_/path/to/cert.der" {
+				return []byte{0x30, 0x82, 0x02}
+			}
+			return data
+		}))
+		assert.EqualError(t, err, "archive integrity check failed: '/path/to/cert.der' was modified")
+	})
+	t.Run("Missing", func(t *testing.T) {
+		_, err := ReadArchive(rewrite(func(name string, data []byte) []byte {
+			if name == "scripts/_/path/to/a.js" {
+				return nil
+			}
+			return data
+		}))
+		assert.EqualError(t, err, "archive integrity check failed: '/path/to/a.js' is missing")
+	})
+	t.Run("Old", func(t *testing.T) {
+		// Archives written before there were integrity hashes are read as they are.
+		arc2, err := ReadArchive(rewrite(func(name string, data []byte) []byte {
+			if name == "metadata.json" {
+				var metadata map[string]interface{}
+				require.NoError(t, json.Unmarshal(data, &metadata))
+				delete(metadata, "integrity")
+				data, err := json.Marshal(metadata)
+				require.NoError(t, err)
+				return data
+			}
+			return data
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, arc.Scripts, arc2.Scripts)
+	})
+}
+
 func TestArchiveJSONEscape(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,307 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// Defaults for the fields of CircuitBreaker that aren't set.
+const (
+	DefaultCircuitBreakerMinRequests = 20
+	DefaultCircuitBreakerWindow      = 10 * time.Second
+	DefaultCircuitBreakerCooldown    = 30 * time.Second
+)
+
+// The error rate of a circuit is tracked over this many slices of the window, which are
+// dropped as a whole once they're older than it.
+const circuitBuckets = 10
+
+// CircuitBreakerFields defines the fields used for a CircuitBreaker; see StageFields for why
+// this is a separate type.
+type CircuitBreakerFields struct {
+	// The rate of failed requests, ie. ones with an error or a 5xx status, at which a circuit
+	// opens, eg. 0.5. Required.
+	ErrorRate null.Float `json:"errorRate"`
+
+	// The fewest requests in the window before a circuit can open.
+	MinRequests null.Int `json:"minRequests"`
+
+	// How far back the error rate is computed over.
+	Window types.NullDuration `json:"window"`
+
+	// How long a circuit stays open before a single request is let through to probe whether
+	// the endpoint recovered, which closes it again if it succeeds.
+	Cooldown types.NullDuration `json:"cooldown"`
+
+	// The names of the endpoints that get a circuit, which may contain "*" wildcards. All of
+	// them do if none are set.
+	Names []string `json:"names"`
+}
+
+// CircuitBreaker stops sending requests to an endpoint, as identified by the name tag of its
+// requests, once too many of them fail, so that one failing dependency doesn't invalidate a
+// whole test. The circuits are shared by all VUs.
+type CircuitBreaker struct {
+	CircuitBreakerFields
+
+	circuits *circuitSet
+}
+
+// CircuitOpenError is returned for requests to an endpoint whose circuit is open.
+type CircuitOpenError struct {
+	Name string
+}
+
+func (e CircuitOpenError) Error() string {
+	return fmt.Sprintf("the circuit breaker of '%s' is open", e.Name)
+}
+
+type circuitSet struct {
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type circuit struct {
+	state    circuitState
+	openedAt time.Time
+	buckets  [circuitBuckets]circuitBucket
+}
+
+type circuitBucket struct {
+	start           time.Time
+	requests, fails int64
+}
+
+func (cb *CircuitBreaker) UnmarshalJSON(b []byte) error {
+	var fields CircuitBreakerFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*cb = CircuitBreaker{CircuitBreakerFields: fields}
+	return cb.compile()
+}
+
+func (cb CircuitBreaker) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cb.CircuitBreakerFields)
+}
+
+// UnmarshalText parses a comma-separated list of "errorRate=F", "minRequests=N", "window=D",
+// "cooldown=D" and "names=pattern", where the latter may be repeated, eg.
+// "errorRate=0.5,window=30s". An empty string unsets it.
+func (cb *CircuitBreaker) UnmarshalText(b []byte) error {
+	var fields CircuitBreakerFields
+	if strings.TrimSpace(string(b)) == "" {
+		*cb = CircuitBreaker{}
+		return nil
+	}
+	for _, part := range strings.Split(string(b), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return errors.Errorf("circuit breaker parameter '%s' needs a value", kv[0])
+		}
+		var err error
+		switch kv[0] {
+		case "errorRate":
+			var f float64
+			f, err = strconv.ParseFloat(kv[1], 64)
+			fields.ErrorRate = null.FloatFrom(f)
+		case "minRequests":
+			var i int64
+			i, err = strconv.ParseInt(kv[1], 10, 64)
+			fields.MinRequests = null.IntFrom(i)
+		case "window":
+			err = fields.Window.UnmarshalText([]byte(kv[1]))
+		case "cooldown":
+			err = fields.Cooldown.UnmarshalText([]byte(kv[1]))
+		case "names":
+			fields.Names = append(fields.Names, kv[1])
+		default:
+			return errors.Errorf("unknown circuit breaker parameter '%s'", kv[0])
+		}
+		if err != nil {
+			return errors.Wrapf(err, "circuit breaker parameter '%s'", kv[0])
+		}
+	}
+	*cb = CircuitBreaker{CircuitBreakerFields: fields}
+	return cb.compile()
+}
+
+// compile validates the fields and resets the circuits.
+func (cb *CircuitBreaker) compile() error {
+	if cb.IsSet() && (!cb.ErrorRate.Valid || cb.ErrorRate.Float64 <= 0 || cb.ErrorRate.Float64 > 1) {
+		return errors.New("the circuit breaker error rate must be between 0 and 1")
+	}
+	if cb.MinRequests.Valid && cb.MinRequests.Int64 < 1 {
+		return errors.New("the circuit breaker min requests must be at least 1")
+	}
+	if cb.Window.Valid && cb.Window.Duration <= 0 || cb.Cooldown.Valid && cb.Cooldown.Duration <= 0 {
+		return errors.New("the circuit breaker window and cooldown must be positive")
+	}
+	for _, pattern := range cb.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "circuit breaker name '%s'", pattern)
+		}
+	}
+	cb.circuits = &circuitSet{circuits: make(map[string]*circuit)}
+	return nil
+}
+
+// IsSet returns whether any of the fields are set.
+func (cb CircuitBreaker) IsSet() bool {
+	return cb.ErrorRate.Valid || cb.MinRequests.Valid || cb.Window.Valid || cb.Cooldown.Valid ||
+		cb.Names != nil
+}
+
+// GetMinRequests returns the fewest requests before a circuit can open, or its default.
+func (cb CircuitBreaker) GetMinRequests() int64 {
+	if cb.MinRequests.Valid {
+		return cb.MinRequests.Int64
+	}
+	return DefaultCircuitBreakerMinRequests
+}
+
+// GetWindow returns how far back the error rate is computed over, or its default.
+func (cb CircuitBreaker) GetWindow() time.Duration {
+	if cb.Window.Valid {
+		return time.Duration(cb.Window.Duration)
+	}
+	return DefaultCircuitBreakerWindow
+}
+
+// GetCooldown returns how long a circuit stays open, or its default.
+func (cb CircuitBreaker) GetCooldown() time.Duration {
+	if cb.Cooldown.Valid {
+		return time.Duration(cb.Cooldown.Duration)
+	}
+	return DefaultCircuitBreakerCooldown
+}
+
+// circuit returns the circuit of an endpoint, or nil if it doesn't get one.
+func (cb *CircuitBreaker) circuit(name string) *circuit {
+	if cb.circuits == nil || !cb.ErrorRate.Valid || len(cb.Names) > 0 && !matchesAny(cb.Names, name) {
+		return nil
+	}
+	c := cb.circuits.circuits[name]
+	if c == nil {
+		c = &circuit{}
+		cb.circuits.circuits[name] = c
+	}
+	return c
+}
+
+// Allow returns whether a request to an endpoint may be sent. Once the cooldown of an open
+// circuit is over, a single request is allowed to probe the endpoint.
+func (cb *CircuitBreaker) Allow(name string, now time.Time) bool {
+	if cb.circuits == nil {
+		return true
+	}
+	cb.circuits.mu.Lock()
+	defer cb.circuits.mu.Unlock()
+	c := cb.circuit(name)
+	if c == nil {
+		return true
+	}
+	switch c.state {
+	case circuitOpen:
+		if now.Sub(c.openedAt) < cb.GetCooldown() {
+			return false
+		}
+		c.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false // The probe is still in flight.
+	default:
+		return true
+	}
+}
+
+// Record records the outcome of a request to an endpoint, which may open or close its circuit.
+func (cb *CircuitBreaker) Record(name string, failed bool, now time.Time) {
+	if cb.circuits == nil {
+		return
+	}
+	cb.circuits.mu.Lock()
+	defer cb.circuits.mu.Unlock()
+	c := cb.circuit(name)
+	if c == nil {
+		return
+	}
+
+	switch c.state {
+	case circuitHalfOpen:
+		if failed {
+			c.state, c.openedAt = circuitOpen, now
+			return
+		}
+		c.state, c.buckets = circuitClosed, [circuitBuckets]circuitBucket{}
+		log.WithField("name", name).Info("Circuit breaker closed, the endpoint recovered")
+		return
+	case circuitOpen:
+		return // A request that was sent before the circuit opened.
+	}
+
+	bucketLen := cb.GetWindow() / circuitBuckets
+	if bucketLen <= 0 {
+		bucketLen = 1
+	}
+	start := now.Truncate(bucketLen)
+	b := &c.buckets[(start.UnixNano()/int64(bucketLen))%circuitBuckets]
+	if !b.start.Equal(start) {
+		*b = circuitBucket{start: start}
+	}
+	b.requests++
+	if failed {
+		b.fails++
+	}
+
+	var requests, fails int64
+	for _, b := range c.buckets {
+		if now.Sub(b.start) < cb.GetWindow() {
+			requests += b.requests
+			fails += b.fails
+		}
+	}
+	if requests >= cb.GetMinRequests() && float64(fails)/float64(requests) >= cb.ErrorRate.Float64 {
+		c.state, c.openedAt = circuitOpen, now
+		log.WithFields(log.Fields{"name": name, "requests": requests, "failed": fails}).Warnf(
+			"Circuit breaker opened, skipping iterations that make requests to it for %s", cb.GetCooldown())
+	}
+}
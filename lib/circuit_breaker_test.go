@@ -0,0 +1,106 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestCircuitBreakerUnmarshal(t *testing.T) {
+	var cb CircuitBreaker
+	require.NoError(t, json.Unmarshal([]byte(`{"errorRate": 0.5, "minRequests": 5, "cooldown": "1m", "names": ["api/*"]}`), &cb))
+	assert.Equal(t, CircuitBreakerFields{
+		ErrorRate:   null.FloatFrom(0.5),
+		MinRequests: null.IntFrom(5),
+		Cooldown:    types.NullDurationFrom(time.Minute),
+		Names:       []string{"api/*"},
+	}, cb.CircuitBreakerFields)
+	assert.NotNil(t, cb.circuits)
+
+	data, err := json.Marshal(cb)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"errorRate": 0.5, "minRequests": 5, "window": null, "cooldown": "1m0s", "names": ["api/*"]}`, string(data))
+
+	for text, msg := range map[string]string{
+		"window=10s":                  "the circuit breaker error rate must be between 0 and 1",
+		"errorRate=2":                 "the circuit breaker error rate must be between 0 and 1",
+		"errorRate=0.5,minRequests=0": "the circuit breaker min requests must be at least 1",
+		"errorRate=0.5,cooldown=0s":   "the circuit breaker window and cooldown must be positive",
+		"errorRate=0.5,names=[":       "circuit breaker name '[': syntax error in pattern",
+		"errorRate":                   "circuit breaker parameter 'errorRate' needs a value",
+		"rate=0.5":                    "unknown circuit breaker parameter 'rate'",
+	} {
+		err := cb.UnmarshalText([]byte(text))
+		if assert.Error(t, err, text) {
+			assert.Contains(t, err.Error(), msg, text)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var cb CircuitBreaker
+	require.NoError(t, cb.UnmarshalText([]byte("errorRate=0.5,minRequests=4,window=10s,cooldown=30s,names=api*")))
+	now := time.Now()
+
+	// Too few requests to open, and other names don't get a circuit.
+	for i := 0; i < 3; i++ {
+		cb.Record("api", true, now)
+		cb.Record("static", true, now)
+	}
+	assert.True(t, cb.Allow("api", now))
+	assert.True(t, cb.Allow("static", now))
+
+	// Failures older than the window don't count.
+	cb.Record("api", true, now.Add(15*time.Second))
+	assert.True(t, cb.Allow("api", now.Add(15*time.Second)))
+
+	now = now.Add(15 * time.Second)
+	cb.Record("api", false, now)
+	cb.Record("api", true, now)
+	cb.Record("api", false, now)
+	assert.False(t, cb.Allow("api", now))
+	assert.False(t, cb.Allow("api", now.Add(29*time.Second)))
+
+	// A single probe is let through after the cooldown; it failing reopens the circuit.
+	now = now.Add(30 * time.Second)
+	assert.True(t, cb.Allow("api", now))
+	assert.False(t, cb.Allow("api", now))
+	cb.Record("api", true, now)
+	assert.False(t, cb.Allow("api", now.Add(time.Second)))
+
+	// And it succeeding closes it.
+	now = now.Add(30 * time.Second)
+	assert.True(t, cb.Allow("api", now))
+	cb.Record("api", false, now)
+	assert.True(t, cb.Allow("api", now))
+	assert.True(t, cb.Allow("api", now))
+
+	var unset CircuitBreaker
+	unset.Record("api", true, now)
+	assert.True(t, unset.Allow("api", now))
+}
@@ -0,0 +1,44 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import "context"
+
+type ctxKey int
+
+const (
+	ctxKeyExecutor ctxKey = iota
+)
+
+// WithExecutor returns a context carrying the executor that's running a VU, so that the script
+// can control the test it's part of.
+func WithExecutor(ctx context.Context, ex Executor) context.Context {
+	return context.WithValue(ctx, ctxKeyExecutor, ex)
+}
+
+// GetExecutor returns the executor running the current VU, or nil outside of a running test.
+func GetExecutor(ctx context.Context) Executor {
+	v := ctx.Value(ctxKeyExecutor)
+	if v == nil {
+		return nil
+	}
+	return v.(Executor)
+}
@@ -0,0 +1,256 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package cron parses cron-like schedule expressions and matches times against them.
+//
+// An expression has five fields: minute, hour, day of month, month and day of week, or six with
+// a leading seconds field. Every field is a comma separated list of values, "a-b" ranges or "*",
+// each optionally followed by a "/step". Months and days of week may also be given by their
+// three letter English names, and 7 is Sunday, like 0. If both the day of month and the day of
+// week are restricted, a day matches if either does, like in the classic cron. Without a seconds
+// field, an expression matches whole minutes, and starts at their first second. The @yearly,
+// @annually, @monthly, @weekly, @daily, @midnight and @hourly macros are also supported.
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// How far ahead Next looks for a match, so that schedules that never match, eg. "0 0 30 2 *",
+// don't loop forever.
+const maxLookahead = 5 * 366 * 24 * time.Hour
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var fields = []field{
+	{name: "second", min: 0, max: 59},
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: monthNames},
+	{name: "day of week", min: 0, max: 7, names: dayNames},
+}
+
+// A Schedule is a parsed cron expression.
+type Schedule struct {
+	expr       string
+	hasSeconds bool
+
+	// Bit sets of the values every field matches.
+	seconds, minutes, hours, days, months, weekdays uint64
+
+	// Whether the day fields are "*", which matters for how they're combined.
+	anyDay, anyWeekday bool
+}
+
+// Parse parses a cron expression.
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, ok := macros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+
+	parts := strings.Fields(spec)
+	hasSeconds := len(parts) == 6
+	switch len(parts) {
+	case 5:
+		parts = append([]string{"0"}, parts...)
+	case 6:
+	default:
+		return nil, errors.Errorf("invalid cron expression '%s': expected 5 or 6 fields, got %d", expr, len(parts))
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, f := range fields {
+		set, err := f.parse(parts[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cron expression '%s'", expr)
+		}
+		sets[i] = set
+	}
+	// Sunday is both 0 and 7.
+	if sets[5]&(1<<7) != 0 {
+		sets[5] |= 1
+	}
+
+	return &Schedule{
+		expr:       expr,
+		hasSeconds: hasSeconds,
+		seconds:    sets[0],
+		minutes:    sets[1],
+		hours:      sets[2],
+		days:       sets[3],
+		months:     sets[4],
+		weekdays:   sets[5],
+		anyDay:     parts[3] == "*" || parts[3] == "?",
+		anyWeekday: parts[5] == "*" || parts[5] == "?",
+	}, nil
+}
+
+// parse returns the set of values a field of an expression matches.
+func (f field) parse(s string) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(s, ",") {
+		rangePart, step := item, 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			var err error
+			rangePart = item[:i]
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step < 1 {
+				return 0, errors.Errorf("invalid step '%s' in the %s field", item[i+1:], f.name)
+			}
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, errors.Errorf("invalid range '%s' in the %s field", rangePart, f.name)
+			}
+		default:
+			v, err := f.value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// "5/10" means every 10 starting from 5, while a plain "5" is only 5.
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// value parses a single value of a field, which may be a name.
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, errors.Errorf("invalid value '%s' in the %s field, expected %d-%d", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// String returns the expression the schedule was parsed from.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Matches returns whether a time is in the schedule. The fields are matched against the time in
+// its own location.
+func (s *Schedule) Matches(t time.Time) bool {
+	return s.matchesDay(t) &&
+		has(s.hours, t.Hour()) &&
+		has(s.minutes, t.Minute()) &&
+		(!s.hasSeconds || has(s.seconds, t.Second()))
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	if !has(s.months, int(t.Month())) {
+		return false
+	}
+	day, weekday := has(s.days, t.Day()), has(s.weekdays, int(t.Weekday()))
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// Next returns the first time in the schedule after the given one, in its location, or false if
+// there's none in the next five years.
+func (s *Schedule) Next(after time.Time) (time.Time, bool) {
+	loc := after.Location()
+	t := after.Truncate(time.Second).Add(time.Second)
+	limit := after.Add(maxLookahead)
+
+	// Skip ahead by the largest unit that doesn't match, so that this takes at most a few
+	// thousand steps.
+	for t.Before(limit) {
+		y, mo, d := t.Date()
+		h, mi, sec := t.Clock()
+		switch {
+		case !has(s.months, int(mo)):
+			t = time.Date(y, mo+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchesDay(t):
+			t = time.Date(y, mo, d+1, 0, 0, 0, 0, loc)
+		case !has(s.hours, h):
+			t = time.Date(y, mo, d, h+1, 0, 0, 0, loc)
+		case !has(s.minutes, mi):
+			t = t.Add(time.Duration(60-sec) * time.Second)
+		case !has(s.seconds, sec):
+			t = t.Add(time.Second)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
@@ -0,0 +1,155 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04:05", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParse(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		testdata := map[string]string{
+			"":              "expected 5 or 6 fields, got 0",
+			"* * * *":       "expected 5 or 6 fields, got 4",
+			"60 * * * *":    "invalid value '60' in the minute field, expected 0-59",
+			"* 24 * * *":    "invalid value '24' in the hour field, expected 0-23",
+			"* * 0 * *":     "invalid value '0' in the day of month field, expected 1-31",
+			"* * * foo *":   "invalid value 'foo' in the month field, expected 1-12",
+			"* * * * 8":     "invalid value '8' in the day of week field, expected 0-7",
+			"*/0 * * * *":   "invalid step '0' in the minute field",
+			"10-5 * * * *":  "invalid range '10-5' in the minute field",
+			"60 * * * * *":  "invalid value '60' in the second field, expected 0-59",
+			"@fortnightly":  "expected 5 or 6 fields, got 1",
+			"1,,2 * * * *":  "invalid value '' in the minute field, expected 0-59",
+			"* * * JAN-X *": "invalid value 'X' in the month field, expected 1-12",
+		}
+		for expr, msg := range testdata {
+			t.Run(expr, func(t *testing.T) {
+				_, err := Parse(expr)
+				if assert.Error(t, err) {
+					assert.Equal(t, "invalid cron expression '"+expr+"': "+msg, err.Error())
+				}
+			})
+		}
+	})
+	t.Run("Macro", func(t *testing.T) {
+		s, err := Parse("@Daily")
+		require.NoError(t, err)
+		assert.Equal(t, "@Daily", s.String())
+		assert.True(t, s.Matches(date("2018-06-01 00:00:00")))
+		assert.True(t, s.Matches(date("2018-06-01 00:00:30")))
+		assert.False(t, s.Matches(date("2018-06-01 00:01:00")))
+	})
+}
+
+func TestMatches(t *testing.T) {
+	testdata := map[string]struct {
+		matches, doesnt []string
+	}{
+		"5 * * * *": {
+			matches: []string{"2018-06-01 12:05:00", "2018-06-01 12:05:59"},
+			doesnt:  []string{"2018-06-01 12:06:00"},
+		},
+		"*/15 9-17 * * MON-FRI": {
+			matches: []string{"2018-06-01 09:00:00", "2018-06-04 17:45:00"},
+			doesnt:  []string{"2018-06-02 09:00:00", "2018-06-01 18:00:00", "2018-06-01 09:10:00"},
+		},
+		"0 0 1,15 * *": {
+			matches: []string{"2018-06-01 00:00:00", "2018-06-15 00:00:00"},
+			doesnt:  []string{"2018-06-02 00:00:00"},
+		},
+		"30 5/10 * * * *": {
+			matches: []string{"2018-06-01 12:05:30", "2018-06-01 12:55:30"},
+			doesnt:  []string{"2018-06-01 12:00:30", "2018-06-01 12:05:00"},
+		},
+		"0 12 * dec 7": {
+			matches: []string{"2018-12-02 12:00:00"},
+			doesnt:  []string{"2018-12-03 12:00:00", "2018-11-04 12:00:00"},
+		},
+		// Either the day of month or the day of week.
+		"0 0 13 * FRI": {
+			matches: []string{"2018-06-13 00:00:00", "2018-06-01 00:00:00"},
+			doesnt:  []string{"2018-06-02 00:00:00"},
+		},
+	}
+	for expr, data := range testdata {
+		t.Run(expr, func(t *testing.T) {
+			s, err := Parse(expr)
+			require.NoError(t, err)
+			for _, d := range data.matches {
+				assert.True(t, s.Matches(date(d)), d)
+			}
+			for _, d := range data.doesnt {
+				assert.False(t, s.Matches(date(d)), d)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	testdata := []struct {
+		expr, after, next string
+	}{
+		{"* * * * *", "2018-06-01 12:34:56", "2018-06-01 12:35:00"},
+		{"*/15 9-17 * * MON-FRI", "2018-06-01 17:50:00", "2018-06-04 09:00:00"},
+		{"0 0 29 2 *", "2018-03-01 00:00:00", "2020-02-29 00:00:00"},
+		{"*/10 * * * * *", "2018-06-01 12:00:00", "2018-06-01 12:00:10"},
+		{"@yearly", "2018-12-31 23:59:59", "2019-01-01 00:00:00"},
+		{"0 0 31 * *", "2018-04-01 00:00:00", "2018-05-31 00:00:00"},
+	}
+	for _, data := range testdata {
+		t.Run(data.expr, func(t *testing.T) {
+			s, err := Parse(data.expr)
+			require.NoError(t, err)
+			next, ok := s.Next(date(data.after))
+			assert.True(t, ok)
+			assert.Equal(t, date(data.next), next)
+		})
+	}
+
+	t.Run("Never", func(t *testing.T) {
+		s, err := Parse("0 0 30 2 *")
+		require.NoError(t, err)
+		_, ok := s.Next(date("2018-01-01 00:00:00"))
+		assert.False(t, ok)
+	})
+
+	t.Run("Location", func(t *testing.T) {
+		s, err := Parse("0 9 * * *")
+		require.NoError(t, err)
+		loc := time.FixedZone("UTC+2", 2*60*60)
+		next, ok := s.Next(date("2018-06-01 08:00:00").In(loc))
+		assert.True(t, ok)
+		assert.Equal(t, date("2018-06-02 07:00:00"), next.UTC())
+	})
+}
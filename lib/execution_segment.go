@@ -0,0 +1,92 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package lib
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ExecutionSegment is the part of a test run by one of several k6 instances, as "index/count",
+// eg. "1/4" for the second of four equal parts. Instances are counted from 0.
+//
+// It doesn't split the load, which each instance is given by its own options, but the data:
+// feeders, client certificate pools and unique IDs are partitioned by it, so that no two
+// instances hand out the same record, certificate or ID.
+type ExecutionSegment struct {
+	Index int64
+	Count int64
+}
+
+// NewExecutionSegment returns the index'th of count segments.
+func NewExecutionSegment(index, count int64) (*ExecutionSegment, error) {
+	if count < 1 {
+		return nil, errors.New("an execution segment's count must be at least 1")
+	}
+	if index < 0 || index >= count {
+		return nil, errors.Errorf("an execution segment's index must be between 0 and %d", count-1)
+	}
+	return &ExecutionSegment{Index: index, Count: count}, nil
+}
+
+// UnmarshalText parses an "index/count" string, eg. "0/2". An empty string unsets it.
+func (s *ExecutionSegment) UnmarshalText(b []byte) error {
+	if strings.TrimSpace(string(b)) == "" {
+		*s = ExecutionSegment{}
+		return nil
+	}
+	parts := strings.SplitN(strings.TrimSpace(string(b)), "/", 2)
+	if len(parts) != 2 {
+		return errors.Errorf("invalid execution segment '%s', expected 'index/count'", string(b))
+	}
+	index, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "execution segment index")
+	}
+	count, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "execution segment count")
+	}
+	seg, err := NewExecutionSegment(index, count)
+	if err != nil {
+		return err
+	}
+	*s = *seg
+	return nil
+}
+
+func (s ExecutionSegment) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s ExecutionSegment) String() string {
+	return strconv.FormatInt(s.Index, 10) + "/" + strconv.FormatInt(s.Count, 10)
+}
+
+// Range returns the segment's part [start, end) of n items, which are split into contiguous
+// parts that differ in length by at most one. A nil or unset segment is the whole test.
+func (s *ExecutionSegment) Range(n int) (start, end int) {
+	if s == nil || s.Count <= 1 {
+		return 0, n
+	}
+	return int(int64(n) * s.Index / s.Count), int(int64(n) * (s.Index + 1) / s.Count)
+}
@@ -0,0 +1,75 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionSegment(t *testing.T) {
+	t.Run("Parse", func(t *testing.T) {
+		var s ExecutionSegment
+		require.NoError(t, s.UnmarshalText([]byte("1/4")))
+		assert.Equal(t, ExecutionSegment{Index: 1, Count: 4}, s)
+		assert.Equal(t, "1/4", s.String())
+
+		require.NoError(t, s.UnmarshalText([]byte("")))
+		assert.Equal(t, ExecutionSegment{}, s)
+
+		for _, text := range []string{"1", "a/4", "1/b", "4/4", "-1/4", "0/0"} {
+			assert.Error(t, s.UnmarshalText([]byte(text)), text)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var opts Options
+		require.NoError(t, json.Unmarshal([]byte(`{"executionSegment": "2/3"}`), &opts))
+		assert.Equal(t, &ExecutionSegment{Index: 2, Count: 3}, opts.ExecutionSegment)
+
+		data, err := json.Marshal(opts.ExecutionSegment)
+		require.NoError(t, err)
+		assert.Equal(t, `"2/3"`, string(data))
+
+		assert.Error(t, json.Unmarshal([]byte(`{"executionSegment": "3/3"}`), &opts))
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		var nilSegment *ExecutionSegment
+		start, end := nilSegment.Range(10)
+		assert.Equal(t, [2]int{0, 10}, [2]int{start, end})
+
+		// The parts cover every item exactly once.
+		next := 0
+		for i := int64(0); i < 3; i++ {
+			start, end := (&ExecutionSegment{Index: i, Count: 3}).Range(10)
+			assert.Equal(t, next, start)
+			assert.True(t, end-start == 3 || end-start == 4)
+			next = end
+		}
+		assert.Equal(t, 10, next)
+
+		start, end = (&ExecutionSegment{Index: 0, Count: 4}).Range(2)
+		assert.Equal(t, start, end)
+	})
+}
@@ -0,0 +1,132 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package extract extracts values from response bodies with JSONPath, XPath, regular expressions
+// and CSS selectors, for correlating them into later requests. Expressions are compiled once and
+// cached, since a script uses the same few over and over.
+package extract
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/pkg/errors"
+)
+
+// Scripts only use a handful of expressions, unless they build them dynamically; the cache is
+// emptied if it grows past this, rather than growing without bound.
+const maxCachedExpressions = 1000
+
+type expressionCache struct {
+	mu sync.RWMutex
+	m  map[string]interface{}
+}
+
+var cache = expressionCache{m: make(map[string]interface{})}
+
+// get returns the compiled expression for a key, compiling and caching it if needed.
+func (c *expressionCache) get(key string, compile func() (interface{}, error)) (interface{}, error) {
+	c.mu.RLock()
+	v, ok := c.m[key]
+	c.mu.RUnlock()
+	if ok {
+		return v, nil
+	}
+
+	v, err := compile()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if len(c.m) >= maxCachedExpressions {
+		c.m = make(map[string]interface{})
+	}
+	c.m[key] = v
+	c.mu.Unlock()
+	return v, nil
+}
+
+// JSON returns the values a JSONPath selects in a decoded JSON document.
+func JSON(doc interface{}, expr string) ([]interface{}, error) {
+	p, err := cache.get("jsonpath:"+expr, func() (interface{}, error) { return CompileJSONPath(expr) })
+	if err != nil {
+		return nil, err
+	}
+	return p.(*JSONPath).Find(doc), nil
+}
+
+// XML returns the string values of the nodes an XPath selects in a parsed XML document.
+func XML(doc *XMLNode, expr string) ([]string, error) {
+	x, err := cache.get("xpath:"+expr, func() (interface{}, error) { return CompileXPath(expr) })
+	if err != nil {
+		return nil, err
+	}
+	nodes := x.(*XPath).Find(doc)
+	values := make([]string, len(nodes))
+	for i, n := range nodes {
+		values[i] = n.Text()
+	}
+	return values, nil
+}
+
+// Regex returns the matches of a regular expression in a string: the first capture group of
+// every match, or the whole match if the expression doesn't have any.
+func Regex(s, expr string) ([]string, error) {
+	re, err := cache.get("regex:"+expr, func() (interface{}, error) {
+		re, err := regexp.Compile(expr)
+		return re, errors.Wrapf(err, "invalid regular expression '%s'", expr)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, m := range re.(*regexp.Regexp).FindAllStringSubmatch(s, -1) {
+		if len(m) > 1 {
+			values = append(values, m[1])
+		} else {
+			values = append(values, m[0])
+		}
+	}
+	return values, nil
+}
+
+// CSS returns the text of the elements a CSS selector selects in a parsed HTML document, or the
+// values of an attribute of them, if one is given; elements without it are skipped.
+func CSS(doc *goquery.Document, selector, attr string) ([]string, error) {
+	sel, err := cache.get("css:"+selector, func() (interface{}, error) {
+		sel, err := cascadia.Compile(selector)
+		return sel, errors.Wrapf(err, "invalid CSS selector '%s'", selector)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	doc.FindMatcher(sel.(cascadia.Selector)).Each(func(_ int, s *goquery.Selection) {
+		if attr == "" {
+			values = append(values, strings.TrimSpace(s.Text()))
+		} else if v, ok := s.Attr(attr); ok {
+			values = append(values, v)
+		}
+	})
+	return values, nil
+}
@@ -0,0 +1,88 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package extract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegex(t *testing.T) {
+	values, err := Regex(`token=abc; token=def`, `token=(\w+)`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", "def"}, values)
+
+	values, err = Regex(`id 12, id 34`, `\d+`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"12", "34"}, values)
+
+	values, err = Regex(`nothing`, `\d+`)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	_, err = Regex(`x`, `(`)
+	assert.Contains(t, err.Error(), "invalid regular expression '('")
+}
+
+func TestCSS(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+		<form><input name="csrf" value="t0k3n"><input name="user"></form>
+		<ul><li><a href="/a"> A </a></li><li><a href="/b">B</a></li><li>C</li></ul>
+	</body></html>`))
+	require.NoError(t, err)
+
+	values, err := CSS(doc, "input[name=csrf]", "value")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t0k3n"}, values)
+
+	values, err = CSS(doc, "input", "value")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t0k3n"}, values)
+
+	values, err = CSS(doc, "li", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A", "B", "C"}, values)
+
+	_, err = CSS(doc, "li[", "")
+	assert.Contains(t, err.Error(), "invalid CSS selector 'li['")
+}
+
+func TestExpressionCache(t *testing.T) {
+	c := expressionCache{m: make(map[string]interface{})}
+	compiled := 0
+	compile := func() (interface{}, error) {
+		compiled++
+		return compiled, nil
+	}
+	for i := 0; i < 3; i++ {
+		v, err := c.get("a", compile)
+		require.NoError(t, err)
+		assert.Equal(t, 1, v)
+	}
+	for i := 0; i < maxCachedExpressions+1; i++ {
+		_, _ = c.get(strings.Repeat("b", i+1), compile)
+	}
+	assert.True(t, len(c.m) <= maxCachedExpressions)
+}
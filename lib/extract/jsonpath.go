@@ -0,0 +1,486 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package extract
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A JSONPath selects values in a decoded JSON document. The supported syntax is:
+//
+//	$                 the root; may be left out, eg. "data.items[0]"
+//	.name, ['name']   a property
+//	[0], [-1]         an element of an array, counting from the end if negative
+//	[0,2], ['a','b']  several elements or properties
+//	[1:3], [::2]      a slice of an array
+//	.*, [*]           all properties or elements
+//	..name, ..*       recursive descent
+//	[?(@.a > 1)]      the elements or properties for which a filter is true; a filter compares
+//	                  a relative path to a literal with ==, !=, <, <=, > or >=, or checks that a
+//	                  path exists, and filters can be combined with && and ||.
+type JSONPath struct {
+	expr  string
+	steps []jsonPathStep
+}
+
+type jsonPathStep struct {
+	recursive bool
+	wildcard  bool
+	names     []string
+	indexes   []int
+	slice     *[3]*int
+	filter    *jsonPathFilter
+}
+
+// A filter is a disjunction of conjunctions of comparisons.
+type jsonPathFilter struct {
+	or [][]jsonPathComparison
+}
+
+type jsonPathComparison struct {
+	path  *JSONPath
+	op    string
+	value interface{}
+}
+
+// CompileJSONPath parses a JSONPath expression.
+func CompileJSONPath(expr string) (*JSONPath, error) {
+	p := &JSONPath{expr: expr}
+	s := strings.TrimSpace(expr)
+	switch {
+	case strings.HasPrefix(s, "$"):
+		s = s[1:]
+	case s != "" && s[0] != '.' && s[0] != '[':
+		s = "." + s
+	}
+
+	for s != "" {
+		var step jsonPathStep
+		switch {
+		case strings.HasPrefix(s, ".."):
+			step.recursive = true
+			s = s[2:]
+			if s != "" && s[0] == '[' {
+				break
+			}
+			fallthrough
+		case s[0] == '.':
+			s = strings.TrimPrefix(s, ".")
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := strings.TrimSpace(s[:end])
+			if name == "" {
+				return nil, errors.Errorf("invalid JSONPath '%s': empty property name", expr)
+			}
+			if name == "*" {
+				step.wildcard = true
+			} else {
+				step.names = []string{name}
+			}
+			s = s[end:]
+			p.steps = append(p.steps, step)
+			continue
+		case s[0] != '[':
+			return nil, errors.Errorf("invalid JSONPath '%s': unexpected '%s'", expr, s)
+		}
+
+		end := closingBracket(s)
+		if end < 0 {
+			return nil, errors.Errorf("invalid JSONPath '%s': unclosed '['", expr)
+		}
+		if err := parseBracket(&step, strings.TrimSpace(s[1:end])); err != nil {
+			return nil, errors.Wrapf(err, "invalid JSONPath '%s'", expr)
+		}
+		s = s[end+1:]
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+// closingBracket returns the index of the "]" that closes the "[" that s starts with, skipping
+// quoted strings and nested brackets, or -1.
+func closingBracket(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitOutsideQuotes splits s on sep, except where it's quoted or in parentheses.
+func splitOutsideQuotes(s, sep string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case depth == 0 && strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseBracket(step *jsonPathStep, s string) error {
+	switch {
+	case s == "*":
+		step.wildcard = true
+		return nil
+	case strings.HasPrefix(s, "?(") && strings.HasSuffix(s, ")"):
+		filter, err := parseFilter(strings.TrimSpace(s[2 : len(s)-1]))
+		step.filter = filter
+		return err
+	case len(splitOutsideQuotes(s, ":")) > 1:
+		parts := splitOutsideQuotes(s, ":")
+		if len(parts) > 3 {
+			return errors.Errorf("invalid slice '%s'", s)
+		}
+		var slice [3]*int
+		for i, part := range parts {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return errors.Errorf("invalid slice '%s'", s)
+			}
+			slice[i] = &n
+		}
+		if slice[2] != nil && *slice[2] <= 0 {
+			return errors.Errorf("invalid slice '%s': the step must be positive", s)
+		}
+		step.slice = &slice
+		return nil
+	}
+
+	for _, item := range splitOutsideQuotes(s, ",") {
+		item = strings.TrimSpace(item)
+		if name, ok := unquote(item); ok {
+			step.names = append(step.names, name)
+			continue
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			return errors.Errorf("invalid selector '%s'", item)
+		}
+		step.indexes = append(step.indexes, n)
+	}
+	if len(step.names) > 0 && len(step.indexes) > 0 {
+		return errors.Errorf("can't select both properties and elements with '%s'", s)
+	}
+	return nil
+}
+
+// unquote returns the contents of a single or double quoted string.
+func unquote(s string) (string, bool) {
+	if len(s) < 2 || (s[0] != '\'' && s[0] != '"') || s[len(s)-1] != s[0] {
+		return "", false
+	}
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), true
+}
+
+var jsonPathOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseFilter(s string) (*jsonPathFilter, error) {
+	filter := &jsonPathFilter{}
+	for _, disjunct := range splitOutsideQuotes(s, "||") {
+		var and []jsonPathComparison
+		for _, expr := range splitOutsideQuotes(disjunct, "&&") {
+			cmp, err := parseComparison(strings.TrimSpace(expr))
+			if err != nil {
+				return nil, err
+			}
+			and = append(and, cmp)
+		}
+		filter.or = append(filter.or, and)
+	}
+	return filter, nil
+}
+
+func parseComparison(s string) (jsonPathComparison, error) {
+	var cmp jsonPathComparison
+	left := s
+	for _, op := range jsonPathOps {
+		parts := splitOutsideQuotes(s, op)
+		if len(parts) != 2 {
+			continue
+		}
+		left, cmp.op = strings.TrimSpace(parts[0]), op
+		right := strings.TrimSpace(parts[1])
+		if str, ok := unquote(right); ok {
+			cmp.value = str
+		} else if err := json.Unmarshal([]byte(right), &cmp.value); err != nil {
+			return cmp, errors.Errorf("invalid value '%s' in filter '%s'", right, s)
+		}
+		break
+	}
+	if !strings.HasPrefix(left, "@") {
+		return cmp, errors.Errorf("invalid filter '%s': it must start with '@'", s)
+	}
+	path, err := CompileJSONPath("$" + left[1:])
+	if err != nil {
+		return cmp, err
+	}
+	cmp.path = path
+	return cmp, nil
+}
+
+// String returns the expression the path was compiled from.
+func (p *JSONPath) String() string {
+	return p.expr
+}
+
+// Find returns all the values the path selects in a document, in document order; the
+// properties of an object are in the order of their names.
+func (p *JSONPath) Find(doc interface{}) []interface{} {
+	nodes := []interface{}{doc}
+	for _, step := range p.steps {
+		var next []interface{}
+		for _, node := range nodes {
+			if step.recursive {
+				walkJSON(node, func(v interface{}) {
+					next = step.apply(v, next)
+				})
+			} else {
+				next = step.apply(node, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// walkJSON calls fn for a value and all the values in it, depth first.
+func walkJSON(v interface{}, fn func(interface{})) {
+	fn(v)
+	switch v := v.(type) {
+	case []interface{}:
+		for _, e := range v {
+			walkJSON(e, fn)
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			walkJSON(v[k], fn)
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// apply appends what the step selects in a value to out.
+func (step jsonPathStep) apply(v interface{}, out []interface{}) []interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(step.names) > 0 {
+			for _, name := range step.names {
+				if e, ok := v[name]; ok {
+					out = append(out, e)
+				}
+			}
+			return out
+		}
+		if step.wildcard || step.filter != nil {
+			for _, k := range sortedKeys(v) {
+				if step.filter == nil || step.filter.matches(v[k]) {
+					out = append(out, v[k])
+				}
+			}
+		}
+	case []interface{}:
+		switch {
+		case step.wildcard:
+			out = append(out, v...)
+		case step.filter != nil:
+			for _, e := range v {
+				if step.filter.matches(e) {
+					out = append(out, e)
+				}
+			}
+		case len(step.indexes) > 0:
+			for _, i := range step.indexes {
+				if i < 0 {
+					i += len(v)
+				}
+				if i >= 0 && i < len(v) {
+					out = append(out, v[i])
+				}
+			}
+		case step.slice != nil:
+			start, end, inc := 0, len(v), 1
+			if s := step.slice[0]; s != nil {
+				start = clampIndex(*s, len(v))
+			}
+			if e := step.slice[1]; e != nil {
+				end = clampIndex(*e, len(v))
+			}
+			if i := step.slice[2]; i != nil {
+				inc = *i
+			}
+			for i := start; i < end; i += inc {
+				out = append(out, v[i])
+			}
+		}
+	}
+	return out
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+func (f *jsonPathFilter) matches(v interface{}) bool {
+	for _, and := range f.or {
+		ok := true
+		for _, cmp := range and {
+			if !cmp.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmp jsonPathComparison) matches(v interface{}) bool {
+	found := cmp.path.Find(v)
+	if cmp.op == "" {
+		return len(found) > 0
+	}
+	for _, actual := range found {
+		if compareJSON(actual, cmp.op, cmp.value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareJSON(a interface{}, op string, b interface{}) bool {
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			switch op {
+			case "==":
+				return x == y
+			case "!=":
+				return x != y
+			case "<":
+				return x < y
+			case "<=":
+				return x <= y
+			case ">":
+				return x > y
+			case ">=":
+				return x >= y
+			}
+		}
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			switch op {
+			case "<":
+				return x < y
+			case "<=":
+				return x <= y
+			case ">":
+				return x > y
+			case ">=":
+				return x >= y
+			}
+		}
+	}
+	// Only scalars can be compared; objects and arrays aren't equal to any literal.
+	switch a.(type) {
+	case map[string]interface{}, []interface{}:
+		return op == "!="
+	}
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package extract
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testStore = `{
+	"store": {
+		"books": [
+			{"title": "Sayings", "author": "Rees", "price": 8.95, "tags": ["ref"]},
+			{"title": "Sword", "author": "Waugh", "price": 12.99},
+			{"title": "Moby Dick", "author": "Melville", "price": 8.99, "isbn": "0-553"},
+			{"title": "The Lord", "author": "Tolkien", "price": 22.99, "isbn": "0-395"}
+		],
+		"bicycle": {"color": "red", "price": 19.95}
+	},
+	"odd key": 1
+}`
+
+func TestJSONPath(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(testStore), &doc))
+
+	testdata := map[string][]interface{}{
+		"$.store.bicycle.color":                                        {"red"},
+		"store.bicycle.color":                                          {"red"},
+		"$['store']['bicycle']['color']":                               {"red"},
+		"$[\"odd key\"]":                                               {1.0},
+		"$.store.books[0].title":                                       {"Sayings"},
+		"$.store.books[-1].title":                                      {"The Lord"},
+		"$.store.books[0,2].author":                                    {"Rees", "Melville"},
+		"$.store.books[1:3].author":                                    {"Waugh", "Melville"},
+		"$.store.books[::2].author":                                    {"Rees", "Melville"},
+		"$.store.books[-2:].author":                                    {"Melville", "Tolkien"},
+		"$.store.books[*].price":                                       {8.95, 12.99, 8.99, 22.99},
+		"$.store.bicycle.*":                                            {"red", 19.95},
+		"$..author":                                                    {"Rees", "Waugh", "Melville", "Tolkien"},
+		"$..price":                                                     {19.95, 8.95, 12.99, 8.99, 22.99},
+		"$.store.books[?(@.isbn)].title":                               {"Moby Dick", "The Lord"},
+		"$.store.books[?(@.price < 10)].title":                         {"Sayings", "Moby Dick"},
+		"$.store.books[?(@.author == 'Waugh')].title":                  {"Sword"},
+		"$.store.books[?(@.price > 10 && @.isbn)].title":               {"The Lord"},
+		"$.store.books[?(@.author == \"Rees\" || @.price > 20)].title": {"Sayings", "The Lord"},
+		"$.store.books[?(@.tags[0] == 'ref')].title":                   {"Sayings"},
+		"$.store.books[9].title":                                       {},
+		"$.missing":                                                    {},
+		"$":                                                            {doc},
+	}
+	for expr, expected := range testdata {
+		t.Run(expr, func(t *testing.T) {
+			p, err := CompileJSONPath(expr)
+			require.NoError(t, err)
+			values := p.Find(doc)
+			if len(expected) == 0 {
+				assert.Empty(t, values)
+			} else {
+				assert.Equal(t, expected, values)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, expr := range []string{"$.", "$[", "$[abc]", "$[1:2:0]", "$[?(@.a == nope)]", "$[?(a == 1)]", "$['a',1]"} {
+			_, err := CompileJSONPath(expr)
+			assert.Error(t, err, expr)
+		}
+	})
+}
@@ -0,0 +1,640 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// An XMLNode is an element, attribute or text node of a parsed XML document. Names are local,
+// without their namespace prefix.
+type XMLNode struct {
+	Kind     XMLNodeKind
+	Name     string
+	Value    string // Of an attribute or a text node.
+	Parent   *XMLNode
+	Attrs    []*XMLNode
+	Children []*XMLNode
+}
+
+// XMLNodeKind is the kind of an XMLNode.
+type XMLNodeKind int
+
+// The kinds of XMLNodes.
+const (
+	XMLDocument XMLNodeKind = iota
+	XMLElement
+	XMLAttribute
+	XMLText
+)
+
+// ParseXML parses an XML document. Text nodes that only contain whitespace are dropped.
+func ParseXML(data []byte) (*XMLNode, error) {
+	doc := &XMLNode{Kind: XMLDocument}
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Entity = xml.HTMLEntity
+	cur := doc
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid XML")
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			el := &XMLNode{Kind: XMLElement, Name: tok.Name.Local, Parent: cur}
+			for _, attr := range tok.Attr {
+				el.Attrs = append(el.Attrs, &XMLNode{Kind: XMLAttribute, Name: attr.Name.Local, Value: attr.Value, Parent: el})
+			}
+			cur.Children = append(cur.Children, el)
+			cur = el
+		case xml.EndElement:
+			if cur.Parent != nil {
+				cur = cur.Parent
+			}
+		case xml.CharData:
+			if text := string(tok); strings.TrimSpace(text) != "" {
+				cur.Children = append(cur.Children, &XMLNode{Kind: XMLText, Value: text, Parent: cur})
+			}
+		}
+	}
+	return doc, nil
+}
+
+// Text returns the string value of a node: the value of an attribute or a text node, or all the
+// text in an element.
+func (n *XMLNode) Text() string {
+	if n.Kind == XMLAttribute || n.Kind == XMLText {
+		return n.Value
+	}
+	var b strings.Builder
+	n.walk(func(c *XMLNode) {
+		if c.Kind == XMLText {
+			b.WriteString(c.Value)
+		}
+	})
+	return b.String()
+}
+
+// walk calls fn for every descendant of a node, in document order.
+func (n *XMLNode) walk(fn func(*XMLNode)) {
+	for _, c := range n.Children {
+		fn(c)
+		c.walk(fn)
+	}
+}
+
+// An XPath selects nodes in a parsed XML document. It supports location paths with the child
+// ("/"), descendant ("//"), self ("."), parent ("..") and attribute ("@") axes, name tests, "*",
+// "text()" and "node()", and predicates that are positions, "last()", or expressions comparing
+// paths, strings and numbers with =, !=, <, <=, > and >=, combined with "and", "or" and "not()",
+// and the functions "contains()", "starts-with()", "position()", "text()" and "count()".
+type XPath struct {
+	expr string
+	path *xpathPath
+}
+
+type xpathPath struct {
+	absolute bool
+	steps    []xpathStep
+}
+
+type xpathStep struct {
+	descendant bool // Preceded by "//".
+	axis       string
+	test       string
+	predicates []xpathExpr
+}
+
+// An xpathExpr is a node of the expression tree of a predicate.
+type xpathExpr struct {
+	op   string // "or", "and", a comparison, "path", "literal", "number" or a function name.
+	args []xpathExpr
+	path *xpathPath
+	str  string
+	num  float64
+}
+
+// CompileXPath parses an XPath expression.
+func CompileXPath(expr string) (*XPath, error) {
+	p := &xpathParser{tokens: tokenizeXPath(expr)}
+	path, err := p.parsePath()
+	if err == nil && p.pos < len(p.tokens) {
+		err = errors.Errorf("unexpected '%s'", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid XPath '%s'", expr)
+	}
+	return &XPath{expr: expr, path: path}, nil
+}
+
+// String returns the expression the path was compiled from.
+func (x *XPath) String() string {
+	return x.expr
+}
+
+// Find returns the nodes the path selects in a document, in document order.
+func (x *XPath) Find(doc *XMLNode) []*XMLNode {
+	return x.path.eval(doc)
+}
+
+// tokenizeXPath splits an expression into names, operators, literals and numbers.
+func tokenizeXPath(expr string) []string {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case strings.HasPrefix(expr[i:], "//") || strings.HasPrefix(expr[i:], "..") ||
+			strings.HasPrefix(expr[i:], "!=") || strings.HasPrefix(expr[i:], "<=") ||
+			strings.HasPrefix(expr[i:], ">="):
+			tokens = append(tokens, expr[i:i+2])
+			i += 2
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				// Unclosed; the parser reports it.
+				tokens = append(tokens, expr[i:])
+				i = len(expr)
+				break
+			}
+			tokens = append(tokens, expr[i:i+end+2])
+			i += end + 2
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(expr) && (expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		case isNameChar(rune(c)):
+			j := i
+			for j < len(expr) && (isNameChar(rune(expr[j])) || expr[j] == ':' && j+1 < len(expr) && expr[j+1] != ':') {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			tokens = append(tokens, expr[i:i+1])
+			i++
+		}
+	}
+	return tokens
+}
+
+func isNameChar(r rune) bool {
+	return r == '_' || r == '-' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) || r > 127
+}
+
+type xpathParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *xpathParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *xpathParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *xpathParser) expect(t string) error {
+	if got := p.next(); got != t {
+		return errors.Errorf("expected '%s', got '%s'", t, got)
+	}
+	return nil
+}
+
+func (p *xpathParser) parsePath() (*xpathPath, error) {
+	path := &xpathPath{}
+	descendant := false
+	switch p.peek() {
+	case "/":
+		path.absolute = true
+		p.next()
+		if p.peek() == "" {
+			return path, nil
+		}
+	case "//":
+		path.absolute = true
+		descendant = true
+		p.next()
+	}
+	for {
+		step, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		step.descendant = descendant
+		path.steps = append(path.steps, step)
+
+		switch p.peek() {
+		case "/":
+			descendant = false
+		case "//":
+			descendant = true
+		default:
+			return path, nil
+		}
+		p.next()
+	}
+}
+
+func (p *xpathParser) parseStep() (xpathStep, error) {
+	step := xpathStep{axis: "child"}
+	switch t := p.next(); {
+	case t == ".":
+		step.axis, step.test = "self", "node()"
+	case t == "..":
+		step.axis, step.test = "parent", "node()"
+	case t == "@":
+		step.axis = "attribute"
+		step.test = p.next()
+		if step.test != "*" && !isName(step.test) {
+			return step, errors.Errorf("invalid attribute name '%s'", step.test)
+		}
+	case t == "*":
+		step.test = "*"
+	case isName(t) && (t == "text" || t == "node") && p.peek() == "(":
+		p.next()
+		if err := p.expect(")"); err != nil {
+			return step, err
+		}
+		step.test = t + "()"
+	case isName(t):
+		step.test = localName(t)
+	default:
+		return step, errors.Errorf("unexpected '%s'", t)
+	}
+
+	for p.peek() == "[" {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return step, err
+		}
+		if err := p.expect("]"); err != nil {
+			return step, err
+		}
+		step.predicates = append(step.predicates, expr)
+	}
+	return step, nil
+}
+
+func isName(t string) bool {
+	return t != "" && isNameChar(rune(t[0])) && !(t[0] >= '0' && t[0] <= '9') && t != "." && t != ".."
+}
+
+func localName(name string) string {
+	if i := strings.LastIndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func (p *xpathParser) parseOr() (xpathExpr, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek() == "or" {
+		p.next()
+		var right xpathExpr
+		right, err = p.parseAnd()
+		left = xpathExpr{op: "or", args: []xpathExpr{left, right}}
+	}
+	return left, err
+}
+
+func (p *xpathParser) parseAnd() (xpathExpr, error) {
+	left, err := p.parseComparison()
+	for err == nil && p.peek() == "and" {
+		p.next()
+		var right xpathExpr
+		right, err = p.parseComparison()
+		left = xpathExpr{op: "and", args: []xpathExpr{left, right}}
+	}
+	return left, err
+}
+
+func (p *xpathParser) parseComparison() (xpathExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return left, err
+	}
+	switch op := p.peek(); op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.parseOperand()
+		return xpathExpr{op: op, args: []xpathExpr{left, right}}, err
+	}
+	return left, nil
+}
+
+var xpathFunctions = map[string]int{
+	"contains": 2, "starts-with": 2, "not": 1, "position": 0, "last": 0, "count": 1,
+}
+
+func (p *xpathParser) parseOperand() (xpathExpr, error) {
+	t := p.peek()
+	switch {
+	case t == "(":
+		p.next()
+		expr, err := p.parseOr()
+		if err == nil {
+			err = p.expect(")")
+		}
+		return expr, err
+	case t != "" && (t[0] == '\'' || t[0] == '"'):
+		p.next()
+		if len(t) < 2 || t[len(t)-1] != t[0] {
+			return xpathExpr{}, errors.Errorf("unclosed string %s", t)
+		}
+		return xpathExpr{op: "literal", str: t[1 : len(t)-1]}, nil
+	case t != "" && t[0] >= '0' && t[0] <= '9':
+		p.next()
+		n, err := strconv.ParseFloat(t, 64)
+		return xpathExpr{op: "number", num: n}, err
+	case p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] == "(" && t != "text" && t != "node":
+		arity, ok := xpathFunctions[t]
+		if !ok {
+			return xpathExpr{}, errors.Errorf("unsupported function '%s()'", t)
+		}
+		p.pos += 2
+		expr := xpathExpr{op: t}
+		for i := 0; i < arity; i++ {
+			if i > 0 {
+				if err := p.expect(","); err != nil {
+					return expr, err
+				}
+			}
+			arg, err := p.parseOr()
+			if err != nil {
+				return expr, err
+			}
+			expr.args = append(expr.args, arg)
+		}
+		return expr, p.expect(")")
+	}
+	path, err := p.parsePath()
+	return xpathExpr{op: "path", path: path}, err
+}
+
+// eval returns the nodes a path selects from a context node.
+func (path *xpathPath) eval(ctx *XMLNode) []*XMLNode {
+	if path.absolute {
+		for ctx.Parent != nil {
+			ctx = ctx.Parent
+		}
+	}
+	var order map[*XMLNode]int
+	nodes := []*XMLNode{ctx}
+	for _, step := range path.steps {
+		var next []*XMLNode
+		seen := make(map[*XMLNode]bool)
+		for _, node := range nodes {
+			contexts := []*XMLNode{node}
+			if step.descendant {
+				node.walk(func(n *XMLNode) {
+					if n.Kind == XMLElement {
+						contexts = append(contexts, n)
+					}
+				})
+			}
+			for _, c := range contexts {
+				for _, n := range step.eval(c) {
+					if !seen[n] {
+						seen[n] = true
+						next = append(next, n)
+					}
+				}
+			}
+		}
+		if step.descendant && len(next) > 1 {
+			if order == nil {
+				order = documentOrder(ctx)
+			}
+			sort.SliceStable(next, func(i, j int) bool { return order[next[i]] < order[next[j]] })
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// documentOrder returns the positions of all the nodes in the document of a node.
+func documentOrder(n *XMLNode) map[*XMLNode]int {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	order := map[*XMLNode]int{n: 0}
+	n.walk(func(c *XMLNode) {
+		order[c] = len(order)
+		for _, attr := range c.Attrs {
+			order[attr] = len(order)
+		}
+	})
+	return order
+}
+
+// eval returns the nodes a step selects from a context node.
+func (step xpathStep) eval(ctx *XMLNode) []*XMLNode {
+	var candidates []*XMLNode
+	switch step.axis {
+	case "self":
+		candidates = []*XMLNode{ctx}
+	case "parent":
+		if ctx.Parent != nil {
+			candidates = []*XMLNode{ctx.Parent}
+		}
+	case "attribute":
+		for _, attr := range ctx.Attrs {
+			if step.test == "*" || attr.Name == step.test {
+				candidates = append(candidates, attr)
+			}
+		}
+	default:
+		for _, c := range ctx.Children {
+			if step.matches(c) {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+
+	for _, pred := range step.predicates {
+		var filtered []*XMLNode
+		for i, n := range candidates {
+			v := pred.eval(n, i+1, len(candidates))
+			if num, ok := v.(float64); ok {
+				if num == float64(i+1) {
+					filtered = append(filtered, n)
+				}
+			} else if xpathBool(v) {
+				filtered = append(filtered, n)
+			}
+		}
+		candidates = filtered
+	}
+	return candidates
+}
+
+func (step xpathStep) matches(n *XMLNode) bool {
+	switch step.test {
+	case "node()":
+		return true
+	case "text()":
+		return n.Kind == XMLText
+	case "*":
+		return n.Kind == XMLElement
+	default:
+		return n.Kind == XMLElement && n.Name == step.test
+	}
+}
+
+// eval evaluates an expression for a node at a position of the nodes being filtered; the
+// result is a []*XMLNode, a string, a float64 or a bool.
+func (e xpathExpr) eval(n *XMLNode, pos, size int) interface{} {
+	switch e.op {
+	case "literal":
+		return e.str
+	case "number":
+		return e.num
+	case "path":
+		return e.path.eval(n)
+	case "position":
+		return float64(pos)
+	case "last":
+		return float64(size)
+	case "count":
+		nodes, _ := e.args[0].eval(n, pos, size).([]*XMLNode)
+		return float64(len(nodes))
+	case "not":
+		return !xpathBool(e.args[0].eval(n, pos, size))
+	case "contains":
+		return strings.Contains(xpathString(e.args[0].eval(n, pos, size)), xpathString(e.args[1].eval(n, pos, size)))
+	case "starts-with":
+		return strings.HasPrefix(xpathString(e.args[0].eval(n, pos, size)), xpathString(e.args[1].eval(n, pos, size)))
+	case "or":
+		return xpathBool(e.args[0].eval(n, pos, size)) || xpathBool(e.args[1].eval(n, pos, size))
+	case "and":
+		return xpathBool(e.args[0].eval(n, pos, size)) && xpathBool(e.args[1].eval(n, pos, size))
+	}
+	return xpathCompare(e.op, e.args[0].eval(n, pos, size), e.args[1].eval(n, pos, size))
+}
+
+// xpathCompare compares two values; a node set compares true if any of its nodes does.
+func xpathCompare(op string, a, b interface{}) bool {
+	if nodes, ok := a.([]*XMLNode); ok {
+		for _, n := range nodes {
+			if xpathCompare(op, n.Text(), b) {
+				return true
+			}
+		}
+		return false
+	}
+	if nodes, ok := b.([]*XMLNode); ok {
+		for _, n := range nodes {
+			if xpathCompare(op, a, n.Text()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	_, aNum := a.(float64)
+	_, bNum := b.(float64)
+	if aNum || bNum || (op != "=" && op != "!=") {
+		x, y := xpathNumber(a), xpathNumber(b)
+		switch op {
+		case "=":
+			return x == y
+		case "!=":
+			return x != y
+		case "<":
+			return x < y
+		case "<=":
+			return x <= y
+		case ">":
+			return x > y
+		case ">=":
+			return x >= y
+		}
+	}
+	if op == "=" {
+		return xpathString(a) == xpathString(b)
+	}
+	return xpathString(a) != xpathString(b)
+}
+
+func xpathBool(v interface{}) bool {
+	switch v := v.(type) {
+	case []*XMLNode:
+		return len(v) > 0
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case bool:
+		return v
+	}
+	return false
+}
+
+func xpathString(v interface{}) string {
+	switch v := v.(type) {
+	case []*XMLNode:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].Text()
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+func xpathNumber(v interface{}) float64 {
+	if n, ok := v.(float64); ok {
+		return n
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(xpathString(v)), 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
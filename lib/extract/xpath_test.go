@@ -0,0 +1,91 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCatalog = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+	<soap:Body>
+		<catalog session="abc123">
+			<book id="1" lang="en"><title>Sayings</title><price>8.95</price></book>
+			<book id="2" lang="fr"><title>Sword</title><price>12.99</price></book>
+			<book id="3" lang="en"><title>Moby Dick</title><price>8.99</price>
+				<related><book id="4"><title>Typee</title></book></related>
+			</book>
+		</catalog>
+	</soap:Body>
+</soap:Envelope>`
+
+func TestXPath(t *testing.T) {
+	doc, err := ParseXML([]byte(testCatalog))
+	require.NoError(t, err)
+
+	testdata := map[string][]string{
+		"/Envelope/Body/catalog/@session":           {"abc123"},
+		"/soap:Envelope/soap:Body/catalog/@session": {"abc123"},
+		"//catalog/book/title":                      {"Sayings", "Sword", "Moby Dick"},
+		"//book/title":                              {"Sayings", "Sword", "Moby Dick", "Typee"},
+		"//book/@id":                                {"1", "2", "3", "4"},
+		"//catalog/book[2]/title":                   {"Sword"},
+		"//catalog/book[last()]/@id":                {"3"},
+		"//book[@lang='fr']/title/text()":           {"Sword"},
+		"//book[@lang!='fr' and price < 9]/@id":     {"1", "3"},
+		"//book[price > 10 or title = 'Typee']/@id": {"2", "4"},
+		"//book[contains(title, 'Dick')]/@id":       {"3"},
+		"//book[starts-with(title, 'S')]/@id":       {"1", "2"},
+		"//book[not(@lang)]/title":                  {"Typee"},
+		"//book[count(related) = 1]/@id":            {"3"},
+		"//book[position() < 3]/@id":                {"1", "2", "4"},
+		"//title[. = 'Typee']/../@id":               {"4"},
+		"//catalog/*[1]/@*":                         {"1", "en"},
+		"/Envelope/Body/catalog/book[1]":            {"Sayings8.95"},
+		"//book[@id='9']":                           {},
+	}
+	for expr, expected := range testdata {
+		t.Run(expr, func(t *testing.T) {
+			values, err := XML(doc, expr)
+			require.NoError(t, err)
+			if len(expected) == 0 {
+				assert.Empty(t, values)
+			} else {
+				assert.Equal(t, expected, values)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, expr := range []string{"//book[", "//book[@id='1]", "//book[foo(1)]", "/a/", "//@"} {
+			_, err := CompileXPath(expr)
+			assert.Error(t, err, expr)
+		}
+	})
+
+	t.Run("InvalidXML", func(t *testing.T) {
+		_, err := ParseXML([]byte("<a><b></a>"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,193 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The kinds of faults that can be injected into HTTP requests.
+const (
+	// Fail requests with an error, without sending them.
+	FaultAbort = "abort"
+	// Delay requests, as if the server took longer to respond.
+	FaultLatency = "latency"
+	// Respond to requests with an error status, without sending them.
+	FaultError = "error"
+)
+
+// A Fault is injected into a fraction of the HTTP requests made while it's active.
+type Fault struct {
+	Kind string
+
+	// The fraction of the matching requests the fault is injected into, between 0 and 1.
+	Rate float64
+
+	// How long requests are delayed, for FaultLatency.
+	Delay time.Duration
+
+	// The status requests are responded to with, for FaultError.
+	Status int
+
+	// Only requests that have all of these tags are matched, eg. {"name": "checkout"}.
+	Tags map[string]string
+
+	// When the fault stops being injected; it's injected until it's removed if it's zero.
+	Until time.Time
+}
+
+// Validate returns an error if the fault's kind or parameters aren't valid.
+func (f Fault) Validate() error {
+	if f.Rate <= 0 || f.Rate > 1 {
+		return errors.Errorf("the rate of a fault must be between 0 and 1, not %g", f.Rate)
+	}
+	switch f.Kind {
+	case FaultAbort:
+	case FaultLatency:
+		if f.Delay <= 0 {
+			return errors.New("the delay of a latency fault must be positive")
+		}
+	case FaultError:
+		if f.Status < 100 || f.Status > 599 {
+			return errors.Errorf("invalid status %d for an error fault", f.Status)
+		}
+	default:
+		return errors.Errorf("unknown fault '%s'", f.Kind)
+	}
+	return nil
+}
+
+func (f Fault) matches(tags map[string]string) bool {
+	for k, v := range f.Tags {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+type activeFault struct {
+	Fault
+	matched int64
+}
+
+// A FaultInjector keeps track of the faults that are injected into the HTTP requests of all VUs.
+// Which of the matching requests a fault is injected into is deterministic, eg. every 4th one for
+// a rate of 0.25, so that experiments can be reproduced.
+type FaultInjector struct {
+	mu     sync.Mutex
+	nextID int64
+	faults map[int64]*activeFault
+}
+
+// NewFaultInjector returns a FaultInjector without any faults.
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{faults: make(map[int64]*activeFault)}
+}
+
+// Add starts injecting a fault, and returns its ID.
+func (fi *FaultInjector) Add(f Fault) (int64, error) {
+	if err := f.Validate(); err != nil {
+		return 0, err
+	}
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.nextID++
+	fi.faults[fi.nextID] = &activeFault{Fault: f}
+	return fi.nextID, nil
+}
+
+// Remove stops injecting a fault, and returns it, or false if it wasn't being injected.
+func (fi *FaultInjector) Remove(id int64) (Fault, bool) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	f, ok := fi.faults[id]
+	if !ok {
+		return Fault{}, false
+	}
+	delete(fi.faults, id)
+	return f.Fault, true
+}
+
+// Clear stops injecting all faults, and returns them in the order they were added.
+func (fi *FaultInjector) Clear() []Fault {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	ids := fi.sortedIDs()
+	faults := make([]Fault, len(ids))
+	for i, id := range ids {
+		faults[i] = fi.faults[id].Fault
+	}
+	fi.faults = make(map[int64]*activeFault)
+	return faults
+}
+
+func (fi *FaultInjector) sortedIDs() []int64 {
+	ids := make([]int64, 0, len(fi.faults))
+	for id := range fi.faults {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Inject returns the faults to inject into a request with the given tags, in the order they
+// were added. Faults that are over are removed.
+func (fi *FaultInjector) Inject(tags map[string]string, now time.Time) []Fault {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	if len(fi.faults) == 0 {
+		return nil
+	}
+
+	var faults []Fault
+	for _, id := range fi.sortedIDs() {
+		f := fi.faults[id]
+		if !f.Until.IsZero() && !now.Before(f.Until) {
+			delete(fi.faults, id)
+			continue
+		}
+		if !f.matches(tags) {
+			continue
+		}
+		// The nth matching request gets the fault if that makes the count reach the next whole
+		// number, which spreads the faulty requests out evenly.
+		f.matched++
+		if int64(float64(f.matched)*f.Rate) > int64(float64(f.matched-1)*f.Rate) {
+			faults = append(faults, f.Fault)
+		}
+	}
+	return faults
+}
+
+// FaultKinds returns the kinds of the given faults, as the value of the fault tag.
+func FaultKinds(faults []Fault) string {
+	kinds := make([]string, len(faults))
+	for i, f := range faults {
+		kinds[i] = f.Kind
+	}
+	return strings.Join(kinds, ",")
+}
@@ -0,0 +1,117 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultValidate(t *testing.T) {
+	testdata := map[string]struct {
+		fault Fault
+		err   string
+	}{
+		"abort":        {Fault{Kind: FaultAbort, Rate: 0.1}, ""},
+		"latency":      {Fault{Kind: FaultLatency, Rate: 1, Delay: time.Second}, ""},
+		"error":        {Fault{Kind: FaultError, Rate: 1, Status: 503}, ""},
+		"zero rate":    {Fault{Kind: FaultAbort}, "the rate of a fault must be between 0 and 1, not 0"},
+		"high rate":    {Fault{Kind: FaultAbort, Rate: 1.5}, "the rate of a fault must be between 0 and 1, not 1.5"},
+		"no delay":     {Fault{Kind: FaultLatency, Rate: 1}, "the delay of a latency fault must be positive"},
+		"bad status":   {Fault{Kind: FaultError, Rate: 1, Status: 700}, "invalid status 700 for an error fault"},
+		"unknown kind": {Fault{Kind: "flood", Rate: 1}, "unknown fault 'flood'"},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			err := data.fault.Validate()
+			if data.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, data.err)
+			}
+		})
+	}
+}
+
+func TestFaultInjector(t *testing.T) {
+	now := time.Now()
+
+	t.Run("Rate", func(t *testing.T) {
+		fi := NewFaultInjector()
+		_, err := fi.Add(Fault{Kind: FaultAbort, Rate: 0.25})
+		require.NoError(t, err)
+
+		var injected []int
+		for i := 1; i <= 12; i++ {
+			if len(fi.Inject(nil, now)) > 0 {
+				injected = append(injected, i)
+			}
+		}
+		assert.Equal(t, []int{4, 8, 12}, injected)
+	})
+	t.Run("Tags", func(t *testing.T) {
+		fi := NewFaultInjector()
+		_, err := fi.Add(Fault{Kind: FaultError, Rate: 1, Status: 503, Tags: map[string]string{"scenario": "checkout"}})
+		require.NoError(t, err)
+		_, err = fi.Add(Fault{Kind: FaultLatency, Rate: 1, Delay: time.Second})
+		require.NoError(t, err)
+
+		faults := fi.Inject(map[string]string{"scenario": "checkout", "name": "pay"}, now)
+		assert.Equal(t, "error,latency", FaultKinds(faults))
+		faults = fi.Inject(map[string]string{"scenario": "browse"}, now)
+		assert.Equal(t, "latency", FaultKinds(faults))
+	})
+	t.Run("Until", func(t *testing.T) {
+		fi := NewFaultInjector()
+		id, err := fi.Add(Fault{Kind: FaultAbort, Rate: 1, Until: now.Add(time.Second)})
+		require.NoError(t, err)
+
+		assert.Len(t, fi.Inject(nil, now), 1)
+		assert.Len(t, fi.Inject(nil, now.Add(time.Second)), 0)
+		_, ok := fi.Remove(id)
+		assert.False(t, ok, "faults that are over are removed")
+	})
+	t.Run("Remove", func(t *testing.T) {
+		fi := NewFaultInjector()
+		id1, err := fi.Add(Fault{Kind: FaultAbort, Rate: 1})
+		require.NoError(t, err)
+		_, err = fi.Add(Fault{Kind: FaultError, Rate: 1, Status: 500})
+		require.NoError(t, err)
+		_, err = fi.Add(Fault{Kind: FaultLatency, Rate: 1, Delay: time.Second})
+		require.NoError(t, err)
+
+		f, ok := fi.Remove(id1)
+		assert.True(t, ok)
+		assert.Equal(t, FaultAbort, f.Kind)
+		_, ok = fi.Remove(id1)
+		assert.False(t, ok)
+
+		assert.Equal(t, "error,latency", FaultKinds(fi.Clear()))
+		assert.Empty(t, fi.Inject(nil, now))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewFaultInjector().Add(Fault{Kind: FaultAbort})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,346 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// Defaults for the fields of HTTPRetry that aren't set.
+const (
+	DefaultHTTPRetryMaxAttempts = 1
+	DefaultHTTPRetryBackoff     = 100 * time.Millisecond
+	DefaultHTTPRetryMaxBackoff  = 10 * time.Second
+)
+
+// DefaultHTTPRetryStatuses are the response statuses that are retried if none are set.
+var DefaultHTTPRetryStatuses = []string{"429", "502", "503", "504"}
+
+// DefaultHTTPRetryErrors are the classes of request errors that are retried if none are set.
+var DefaultHTTPRetryErrors = []string{"connect", "reset", "timeout"}
+
+// HTTPRetryErrorClasses are the classes of request errors, as returned by netext.ErrorClass,
+// that can be retried; "any" retries all errors.
+var HTTPRetryErrorClasses = []string{"dns", "connect", "tls", "reset", "timeout", "any"}
+
+// HTTPRetryFields defines the fields used for an HTTPRetry; see StageFields for why this is a
+// separate type.
+type HTTPRetryFields struct {
+	// The most times a request is sent, including the first one; 1 means it's never retried.
+	MaxAttempts null.Int `json:"maxAttempts"`
+
+	// Response statuses that are retried, either exactly, eg. "503", or by class, eg. "5xx".
+	Statuses []string `json:"statuses"`
+
+	// Classes of request errors that are retried.
+	Errors []string `json:"errors"`
+
+	// The delay before the first retry, which is doubled for every following one, up to the
+	// max backoff. A longer Retry-After header of the response takes precedence.
+	Backoff    types.NullDuration `json:"backoff"`
+	MaxBackoff types.NullDuration `json:"maxBackoff"`
+
+	// Randomize delays to between half and all of their length, so that VUs don't retry in
+	// lockstep. On by default.
+	Jitter null.Bool `json:"jitter"`
+
+	// Also retry POST and PATCH requests without an Idempotency-Key header, which may repeat
+	// their side effects.
+	NonIdempotent null.Bool `json:"nonIdempotent"`
+
+	// The most retries a VU makes, as a fraction of the retryable requests it made, eg. 0.2, so
+	// that retries don't pile even more load onto a struggling system. 0 means no limit.
+	Budget null.Float `json:"budget"`
+}
+
+// HTTPRetry is a policy for automatically retrying failed HTTP requests.
+type HTTPRetry struct {
+	HTTPRetryFields
+}
+
+func (r *HTTPRetry) UnmarshalJSON(b []byte) error {
+	var fields HTTPRetryFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*r = HTTPRetry{fields}
+	return r.validate()
+}
+
+func (r HTTPRetry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.HTTPRetryFields)
+}
+
+// UnmarshalText parses a comma-separated list of "maxAttempts=N", "statuses=S", "errors=E",
+// "backoff=D", "maxBackoff=D", "jitter=B", "nonIdempotent" and "budget=F", where statuses and
+// errors may be repeated, eg. "maxAttempts=3,statuses=5xx,backoff=200ms". An empty string unsets it.
+func (r *HTTPRetry) UnmarshalText(b []byte) error {
+	var fields HTTPRetryFields
+	if strings.TrimSpace(string(b)) == "" {
+		*r = HTTPRetry{}
+		return nil
+	}
+	for _, part := range strings.Split(string(b), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if kv[0] == "nonIdempotent" && len(kv) == 1 {
+			fields.NonIdempotent = null.BoolFrom(true)
+			continue
+		}
+		if len(kv) != 2 || kv[1] == "" {
+			return errors.Errorf("HTTP retry parameter '%s' needs a value", kv[0])
+		}
+		var err error
+		switch kv[0] {
+		case "maxAttempts":
+			var i int64
+			i, err = strconv.ParseInt(kv[1], 10, 64)
+			fields.MaxAttempts = null.IntFrom(i)
+		case "statuses":
+			fields.Statuses = append(fields.Statuses, kv[1])
+		case "errors":
+			fields.Errors = append(fields.Errors, kv[1])
+		case "backoff":
+			err = fields.Backoff.UnmarshalText([]byte(kv[1]))
+		case "maxBackoff":
+			err = fields.MaxBackoff.UnmarshalText([]byte(kv[1]))
+		case "jitter", "nonIdempotent":
+			var v bool
+			v, err = strconv.ParseBool(kv[1])
+			if kv[0] == "jitter" {
+				fields.Jitter = null.BoolFrom(v)
+			} else {
+				fields.NonIdempotent = null.BoolFrom(v)
+			}
+		case "budget":
+			var f float64
+			f, err = strconv.ParseFloat(kv[1], 64)
+			fields.Budget = null.FloatFrom(f)
+		default:
+			return errors.Errorf("unknown HTTP retry parameter '%s'", kv[0])
+		}
+		if err != nil {
+			return errors.Wrapf(err, "HTTP retry parameter '%s'", kv[0])
+		}
+	}
+	*r = HTTPRetry{fields}
+	return r.validate()
+}
+
+func (r HTTPRetry) validate() error {
+	if r.MaxAttempts.Valid && r.MaxAttempts.Int64 < 1 {
+		return errors.New("the HTTP retry max attempts must be at least 1")
+	}
+	for _, status := range r.Statuses {
+		if !validRetryStatus(status) {
+			return errors.Errorf("invalid HTTP retry status '%s', use eg. '503' or '5xx'", status)
+		}
+	}
+	for _, class := range r.Errors {
+		if !containsString(HTTPRetryErrorClasses, class) {
+			return errors.Errorf("invalid HTTP retry error class '%s', use: '%s'",
+				class, strings.Join(HTTPRetryErrorClasses, "', '"))
+		}
+	}
+	if r.Backoff.Valid && r.Backoff.Duration < 0 || r.MaxBackoff.Valid && r.MaxBackoff.Duration < 0 {
+		return errors.New("the HTTP retry backoff can't be negative")
+	}
+	if r.Budget.Valid && r.Budget.Float64 < 0 {
+		return errors.New("the HTTP retry budget can't be negative")
+	}
+	return nil
+}
+
+func validRetryStatus(status string) bool {
+	if len(status) != 3 || status[0] < '1' || status[0] > '5' {
+		return false
+	}
+	if strings.ToLower(status[1:]) == "xx" {
+		return true
+	}
+	_, err := strconv.Atoi(status)
+	return err == nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSet returns whether any of the fields are set.
+func (r HTTPRetry) IsSet() bool {
+	return r.MaxAttempts.Valid || r.Statuses != nil || r.Errors != nil || r.Backoff.Valid ||
+		r.MaxBackoff.Valid || r.Jitter.Valid || r.NonIdempotent.Valid || r.Budget.Valid
+}
+
+// Apply returns the policy with the fields that are set in another one overwritten, eg. to
+// override the global policy for a single request.
+func (r HTTPRetry) Apply(o HTTPRetry) HTTPRetry {
+	if o.MaxAttempts.Valid {
+		r.MaxAttempts = o.MaxAttempts
+	}
+	if o.Statuses != nil {
+		r.Statuses = o.Statuses
+	}
+	if o.Errors != nil {
+		r.Errors = o.Errors
+	}
+	if o.Backoff.Valid {
+		r.Backoff = o.Backoff
+	}
+	if o.MaxBackoff.Valid {
+		r.MaxBackoff = o.MaxBackoff
+	}
+	if o.Jitter.Valid {
+		r.Jitter = o.Jitter
+	}
+	if o.NonIdempotent.Valid {
+		r.NonIdempotent = o.NonIdempotent
+	}
+	if o.Budget.Valid {
+		r.Budget = o.Budget
+	}
+	return r
+}
+
+// GetMaxAttempts returns the most times a request is sent, or its default.
+func (r HTTPRetry) GetMaxAttempts() int64 {
+	if r.MaxAttempts.Valid {
+		return r.MaxAttempts.Int64
+	}
+	return DefaultHTTPRetryMaxAttempts
+}
+
+// RetriesMethod returns whether requests with a method and headers may be retried at all.
+// Requests with a non-idempotent method only are if they have an Idempotency-Key header, or
+// the policy allows it.
+func (r HTTPRetry) RetriesMethod(method string, header http.Header) bool {
+	switch method {
+	case http.MethodPost, http.MethodPatch:
+		return r.NonIdempotent.Bool || header.Get("Idempotency-Key") != ""
+	default:
+		return true
+	}
+}
+
+// RetriesStatus returns whether a response with a status is retried.
+func (r HTTPRetry) RetriesStatus(status int) bool {
+	statuses := r.Statuses
+	if statuses == nil {
+		statuses = DefaultHTTPRetryStatuses
+	}
+	code := strconv.Itoa(status)
+	for _, s := range statuses {
+		if s == code || len(code) == 3 && strings.EqualFold(s, code[:1]+"xx") {
+			return true
+		}
+	}
+	return false
+}
+
+// RetriesError returns whether a request error of a class is retried.
+func (r HTTPRetry) RetriesError(class string) bool {
+	classes := r.Errors
+	if classes == nil {
+		classes = DefaultHTTPRetryErrors
+	}
+	return containsString(classes, "any") || class != "" && containsString(classes, class)
+}
+
+// Delay returns how long to wait before a retry, given how many attempts were made so far and
+// the delay the server asked for with a Retry-After header, if any.
+func (r HTTPRetry) Delay(attempts int64, retryAfter time.Duration) time.Duration {
+	backoff, maxBackoff := DefaultHTTPRetryBackoff, DefaultHTTPRetryMaxBackoff
+	if r.Backoff.Valid {
+		backoff = time.Duration(r.Backoff.Duration)
+	}
+	if r.MaxBackoff.Valid {
+		maxBackoff = time.Duration(r.MaxBackoff.Duration)
+	}
+
+	delay := backoff
+	for i := int64(1); i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	if (!r.Jitter.Valid || r.Jitter.Bool) && delay > 1 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+	if retryAfter > delay {
+		delay = retryAfter
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+	}
+	return delay
+}
+
+// MinHTTPRetryBudget is how many retries a VU can make regardless of the retry budget, so that
+// the first failures of a test can be retried, too.
+const MinHTTPRetryBudget = 10
+
+// HTTPRetryBudget keeps track of the retryable requests and the retries of a VU, to limit the
+// latter to a fraction of the former. It's safe for concurrent use.
+type HTTPRetryBudget struct {
+	mu       sync.Mutex
+	requests int64
+	retries  int64
+}
+
+// AddRequest counts a retryable request.
+func (b *HTTPRetryBudget) AddRequest() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.requests++
+	b.mu.Unlock()
+}
+
+// Allow returns whether another retry fits in a budget, which is a fraction of the requests, and
+// counts it if it does. A budget of 0 allows all retries.
+func (b *HTTPRetryBudget) Allow(budget float64) bool {
+	if b == nil || budget <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if float64(b.retries+1) > budget*float64(b.requests)+MinHTTPRetryBudget {
+		return false
+	}
+	b.retries++
+	return true
+}
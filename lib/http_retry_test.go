@@ -0,0 +1,133 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestHTTPRetryUnmarshal(t *testing.T) {
+	var r HTTPRetry
+	require.NoError(t, json.Unmarshal([]byte(`{"maxAttempts": 3, "statuses": ["5xx"], "errors": ["any"], "backoff": "1s", "budget": 0.2}`), &r))
+	assert.Equal(t, HTTPRetry{HTTPRetryFields{
+		MaxAttempts: null.IntFrom(3),
+		Statuses:    []string{"5xx"},
+		Errors:      []string{"any"},
+		Backoff:     types.NullDurationFrom(time.Second),
+		Budget:      null.FloatFrom(0.2),
+	}}, r)
+
+	require.NoError(t, r.UnmarshalText([]byte("maxAttempts=2,errors=dns,errors=tls,maxBackoff=2s,jitter=false,nonIdempotent")))
+	assert.Equal(t, HTTPRetry{HTTPRetryFields{
+		MaxAttempts:   null.IntFrom(2),
+		Errors:        []string{"dns", "tls"},
+		MaxBackoff:    types.NullDurationFrom(2 * time.Second),
+		Jitter:        null.BoolFrom(false),
+		NonIdempotent: null.BoolFrom(true),
+	}}, r)
+
+	for text, msg := range map[string]string{
+		"maxAttempts=0":  "the HTTP retry max attempts must be at least 1",
+		"maxAttempts":    "HTTP retry parameter 'maxAttempts' needs a value",
+		"statuses=50":    "invalid HTTP retry status '50'",
+		"statuses=6xx":   "invalid HTTP retry status '6xx'",
+		"errors=network": "invalid HTTP retry error class 'network'",
+		"budget=-1":      "the HTTP retry budget can't be negative",
+		"attempts=3":     "unknown HTTP retry parameter 'attempts'",
+		"backoff=lots":   "HTTP retry parameter 'backoff'",
+	} {
+		err := r.UnmarshalText([]byte(text))
+		if assert.Error(t, err, text) {
+			assert.Contains(t, err.Error(), msg, text)
+		}
+	}
+}
+
+func TestHTTPRetryPolicy(t *testing.T) {
+	var r HTTPRetry
+	assert.Equal(t, int64(1), r.GetMaxAttempts())
+	assert.True(t, r.RetriesStatus(503))
+	assert.True(t, r.RetriesStatus(429))
+	assert.False(t, r.RetriesStatus(500))
+	assert.True(t, r.RetriesError("connect"))
+	assert.False(t, r.RetriesError("dns"))
+	assert.False(t, r.RetriesError(""))
+
+	r = r.Apply(HTTPRetry{HTTPRetryFields{Statuses: []string{"5XX"}, Errors: []string{"any"}}})
+	assert.True(t, r.RetriesStatus(500))
+	assert.False(t, r.RetriesStatus(429))
+	assert.True(t, r.RetriesError("dns"))
+	assert.True(t, r.RetriesError(""))
+
+	t.Run("Methods", func(t *testing.T) {
+		assert.True(t, r.RetriesMethod("GET", http.Header{}))
+		assert.True(t, r.RetriesMethod("PUT", http.Header{}))
+		assert.False(t, r.RetriesMethod("POST", http.Header{}))
+		assert.True(t, r.RetriesMethod("POST", http.Header{"Idempotency-Key": {"abc"}}))
+		nonIdempotent := r.Apply(HTTPRetry{HTTPRetryFields{NonIdempotent: null.BoolFrom(true)}})
+		assert.True(t, nonIdempotent.RetriesMethod("PATCH", http.Header{}))
+	})
+
+	t.Run("Delay", func(t *testing.T) {
+		r := HTTPRetry{HTTPRetryFields{
+			Backoff:    types.NullDurationFrom(100 * time.Millisecond),
+			MaxBackoff: types.NullDurationFrom(time.Second),
+			Jitter:     null.BoolFrom(false),
+		}}
+		assert.Equal(t, 100*time.Millisecond, r.Delay(1, 0))
+		assert.Equal(t, 400*time.Millisecond, r.Delay(3, 0))
+		assert.Equal(t, time.Second, r.Delay(10, 0))
+		assert.Equal(t, 500*time.Millisecond, r.Delay(1, 500*time.Millisecond))
+		assert.Equal(t, time.Second, r.Delay(1, time.Minute))
+
+		r.Jitter = null.Bool{}
+		for i := 0; i < 100; i++ {
+			d := r.Delay(2, 0)
+			assert.True(t, d >= 100*time.Millisecond && d <= 200*time.Millisecond, d)
+		}
+	})
+}
+
+func TestHTTPRetryBudget(t *testing.T) {
+	var b HTTPRetryBudget
+	for i := 0; i < MinHTTPRetryBudget; i++ {
+		assert.True(t, b.Allow(0.5))
+	}
+	assert.False(t, b.Allow(0.5))
+	assert.True(t, b.Allow(0))
+
+	b.AddRequest()
+	b.AddRequest()
+	assert.True(t, b.Allow(0.5))
+	assert.False(t, b.Allow(0.5))
+
+	var nilBudget *HTTPRetryBudget
+	nilBudget.AddRequest()
+	assert.True(t, nilBudget.Allow(0.5))
+}
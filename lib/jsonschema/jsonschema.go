@@ -0,0 +1,563 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package jsonschema validates values against JSON schemas. It supports the validation keywords
+// of drafts 4 to 7 that are relevant to API responses, plus the "nullable" keyword of OpenAPI 3.0.
+// References are only resolved within the document the schema is part of.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// A Schema is a JSON schema, possibly part of a larger document, such as an OpenAPI spec.
+type Schema struct {
+	doc    *document
+	schema interface{}
+}
+
+// The document a schema is part of, which references are resolved against.
+type document struct {
+	root interface{}
+
+	patternsMutex sync.Mutex
+	patterns      map[string]*regexp.Regexp
+}
+
+// New makes a Schema out of a decoded JSON document.
+func New(doc interface{}) (*Schema, error) {
+	switch doc.(type) {
+	case map[string]interface{}, bool:
+	default:
+		return nil, errors.Errorf("a schema must be an object or a boolean, not %s", typeOf(doc))
+	}
+	return &Schema{doc: &document{root: doc, patterns: make(map[string]*regexp.Regexp)}, schema: doc}, nil
+}
+
+// Parse parses a JSON document as a Schema.
+func Parse(data []byte) (*Schema, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "invalid schema")
+	}
+	return New(doc)
+}
+
+// At returns the schema at a JSON pointer into the document, eg. "#/components/schemas/User".
+func (s *Schema) At(pointer string) (*Schema, error) {
+	v, err := s.doc.resolve(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{doc: s.doc, schema: v}, nil
+}
+
+// A ValidationError is a way in which a value doesn't match a schema.
+type ValidationError struct {
+	// JSON pointer to the part of the value that doesn't match, eg. "/items/0/id"; "" is the
+	// value itself.
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks a decoded JSON value against the schema, and returns all the ways in which
+// it doesn't match, if any. Numbers may be of any Go numeric type, or json.Number.
+func (s *Schema) Validate(v interface{}) []ValidationError {
+	var errs []ValidationError
+	s.doc.validate(s.schema, normalize(v), "", &errs, 0)
+	return errs
+}
+
+// ValidateJSON is like Validate, for a value that has yet to be decoded.
+func (s *Schema) ValidateJSON(data []byte) ([]ValidationError, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return s.Validate(v), nil
+}
+
+// References can be circular; a value can't be nested this deep in practice.
+const maxDepth = 256
+
+func (d *document) resolve(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, errors.Errorf("unsupported reference '%s', only references within the document are", ref)
+	}
+	v := d.root
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return v, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.Errorf("invalid reference '%s'", ref)
+	}
+	for _, token := range strings.Split(pointer[1:], "/") {
+		if unescaped, err := url.PathUnescape(token); err == nil {
+			token = unescaped
+		}
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[token]
+			if !ok {
+				return nil, errors.Errorf("reference '%s' doesn't exist", ref)
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, errors.Errorf("reference '%s' doesn't exist", ref)
+			}
+			v = node[i]
+		default:
+			return nil, errors.Errorf("reference '%s' doesn't exist", ref)
+		}
+	}
+	return v, nil
+}
+
+func (d *document) pattern(expr string) (*regexp.Regexp, error) {
+	d.patternsMutex.Lock()
+	defer d.patternsMutex.Unlock()
+	if re, ok := d.patterns[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	d.patterns[expr] = re
+	return re, nil
+}
+
+func (d *document) validate(schema, v interface{}, path string, errs *[]ValidationError, depth int) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if depth > maxDepth {
+		fail("schema references are nested too deep")
+		return
+	}
+
+	var s map[string]interface{}
+	switch schema := schema.(type) {
+	case bool:
+		if !schema {
+			fail("no value is allowed here")
+		}
+		return
+	case map[string]interface{}:
+		s = schema
+	default:
+		fail("invalid schema: %v", schema)
+		return
+	}
+
+	// Other keywords next to a $ref are ignored, as of draft 7.
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := d.resolve(ref)
+		if err != nil {
+			fail("%s", err)
+			return
+		}
+		d.validate(target, v, path, errs, depth+1)
+		return
+	}
+
+	if v == nil {
+		if nullable, _ := s["nullable"].(bool); nullable {
+			return
+		}
+	}
+
+	if t, ok := s["type"]; ok {
+		var types []string
+		switch t := t.(type) {
+		case string:
+			types = []string{t}
+		case []interface{}:
+			for _, tt := range t {
+				if tt, ok := tt.(string); ok {
+					types = append(types, tt)
+				}
+			}
+		}
+		if !matchesType(v, types) {
+			fail("expected %s, got %s", strings.Join(types, " or "), typeOf(v))
+			return
+		}
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if equal(v, normalize(e)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %s", formatValues(enum))
+		}
+	}
+	if c, ok := s["const"]; ok && !equal(v, normalize(c)) {
+		fail("must be %s", formatValue(c))
+	}
+
+	switch v := v.(type) {
+	case float64:
+		d.validateNumber(s, v, fail)
+	case string:
+		d.validateString(s, v, fail)
+	case []interface{}:
+		d.validateArray(s, v, path, errs, depth, fail)
+	case map[string]interface{}:
+		d.validateObject(s, v, path, errs, depth, fail)
+	}
+
+	if allOf, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			d.validate(sub, v, path, errs, depth+1)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if d.matches(sub, v, path, depth) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("doesn't match any of the allowed schemas")
+		}
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		n := 0
+		for _, sub := range oneOf {
+			if d.matches(sub, v, path, depth) {
+				n++
+			}
+		}
+		if n != 1 {
+			fail("must match exactly one of the allowed schemas, but matches %d", n)
+		}
+	}
+	if not, ok := s["not"]; ok && d.matches(not, v, path, depth) {
+		fail("matches a schema it mustn't")
+	}
+}
+
+func (d *document) matches(schema, v interface{}, path string, depth int) bool {
+	var errs []ValidationError
+	d.validate(schema, v, path, &errs, depth+1)
+	return len(errs) == 0
+}
+
+func (d *document) validateNumber(s map[string]interface{}, v float64, fail func(string, ...interface{})) {
+	if min, ok := number(s["minimum"]); ok {
+		// Draft 4 has a boolean exclusiveMinimum, later drafts a number.
+		if excl, _ := s["exclusiveMinimum"].(bool); excl && v <= min {
+			fail("must be greater than %v", min)
+		} else if v < min {
+			fail("must be at least %v", min)
+		}
+	}
+	if min, ok := number(s["exclusiveMinimum"]); ok && v <= min {
+		fail("must be greater than %v", min)
+	}
+	if max, ok := number(s["maximum"]); ok {
+		if excl, _ := s["exclusiveMaximum"].(bool); excl && v >= max {
+			fail("must be less than %v", max)
+		} else if v > max {
+			fail("must be at most %v", max)
+		}
+	}
+	if max, ok := number(s["exclusiveMaximum"]); ok && v >= max {
+		fail("must be less than %v", max)
+	}
+	if m, ok := number(s["multipleOf"]); ok && m > 0 {
+		if q := v / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			fail("must be a multiple of %v", m)
+		}
+	}
+}
+
+func (d *document) validateString(s map[string]interface{}, v string, fail func(string, ...interface{})) {
+	length := utf8.RuneCountInString(v)
+	if min, ok := number(s["minLength"]); ok && float64(length) < min {
+		fail("must be at least %v characters long", min)
+	}
+	if max, ok := number(s["maxLength"]); ok && float64(length) > max {
+		fail("must be at most %v characters long", max)
+	}
+	if expr, ok := s["pattern"].(string); ok {
+		re, err := d.pattern(expr)
+		if err != nil {
+			fail("invalid pattern '%s': %s", expr, err)
+		} else if !re.MatchString(v) {
+			fail("must match the pattern '%s'", expr)
+		}
+	}
+	if format, ok := s["format"].(string); ok {
+		if check, ok := formats[format]; ok && !check(v) {
+			fail("must be a valid %s", format)
+		}
+	}
+}
+
+func (d *document) validateArray(
+	s map[string]interface{}, v []interface{}, path string, errs *[]ValidationError, depth int,
+	fail func(string, ...interface{}),
+) {
+	if min, ok := number(s["minItems"]); ok && float64(len(v)) < min {
+		fail("must have at least %v items", min)
+	}
+	if max, ok := number(s["maxItems"]); ok && float64(len(v)) > max {
+		fail("must have at most %v items", max)
+	}
+	if unique, _ := s["uniqueItems"].(bool); unique {
+	outer:
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if equal(v[i], v[j]) {
+					fail("items %d and %d are the same, but items must be unique", i, j)
+					break outer
+				}
+			}
+		}
+	}
+	switch items := s["items"].(type) {
+	case []interface{}:
+		// A tuple: every item has its own schema, and additionalItems the rest.
+		for i, item := range v {
+			if i < len(items) {
+				d.validate(items[i], item, path+"/"+strconv.Itoa(i), errs, depth+1)
+			} else if additional, ok := s["additionalItems"]; ok {
+				d.validate(additional, item, path+"/"+strconv.Itoa(i), errs, depth+1)
+			}
+		}
+	case nil:
+	default:
+		for i, item := range v {
+			d.validate(items, item, path+"/"+strconv.Itoa(i), errs, depth+1)
+		}
+	}
+}
+
+func (d *document) validateObject(
+	s map[string]interface{}, v map[string]interface{}, path string, errs *[]ValidationError, depth int,
+	fail func(string, ...interface{}),
+) {
+	if min, ok := number(s["minProperties"]); ok && float64(len(v)) < min {
+		fail("must have at least %v properties", min)
+	}
+	if max, ok := number(s["maxProperties"]); ok && float64(len(v)) > max {
+		fail("must have at most %v properties", max)
+	}
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, ok := v[name]; !ok {
+					fail("missing required property '%s'", name)
+				}
+			}
+		}
+	}
+
+	// Go through the properties in order, so that errors are reported consistently.
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	props, _ := s["properties"].(map[string]interface{})
+	patternProps, _ := s["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := s["additionalProperties"]
+	for _, name := range names {
+		propPath := path + "/" + strings.Replace(strings.Replace(name, "~", "~0", -1), "/", "~1", -1)
+		matched := false
+		if sub, ok := props[name]; ok {
+			matched = true
+			d.validate(sub, v[name], propPath, errs, depth+1)
+		}
+		for expr, sub := range patternProps {
+			if re, err := d.pattern(expr); err == nil && re.MatchString(name) {
+				matched = true
+				d.validate(sub, v[name], propPath, errs, depth+1)
+			}
+		}
+		if !matched && hasAdditional {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				fail("property '%s' isn't allowed", name)
+			} else if !ok {
+				d.validate(additional, v[name], propPath, errs, depth+1)
+			}
+		}
+	}
+}
+
+// Checks for the values of the "format" keyword; unknown formats are always valid.
+var formats = map[string]func(string) bool{
+	"date-time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339Nano, s)
+		return err == nil
+	},
+	"date": func(s string) bool {
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	},
+	"email": func(s string) bool {
+		at := strings.LastIndex(s, "@")
+		return at > 0 && at < len(s)-1
+	},
+	"uuid": regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	"ipv4": func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	},
+	"ipv6": func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && strings.Contains(s, ":")
+	},
+	"uri": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs()
+	},
+}
+
+func matchesType(v interface{}, types []string) bool {
+	for _, t := range types {
+		switch t {
+		case "integer":
+			if f, ok := v.(float64); ok && f == math.Trunc(f) && !math.IsInf(f, 0) {
+				return true
+			}
+		default:
+			if typeOf(v) == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON type of a normalized value.
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func number(v interface{}) (float64, bool) {
+	f, ok := normalize(v).(float64)
+	return f, ok
+}
+
+// normalize converts all numbers in a value to float64, the way encoding/json decodes them.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			out[k] = normalize(vv)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, vv := range v {
+			out[i] = normalize(vv)
+		}
+		return out
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	}
+	return v
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func formatValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func formatValues(vs []interface{}) string {
+	parts := make([]string, len(vs))
+	for i, v := range vs {
+		parts[i] = formatValue(v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,122 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	testdata := map[string]struct {
+		schema string
+		value  string
+		errs   []string
+	}{
+		"true":                 {`true`, `1`, nil},
+		"false":                {`false`, `1`, []string{"no value is allowed here"}},
+		"type":                 {`{"type": "string"}`, `1`, []string{"expected string, got number"}},
+		"type list":            {`{"type": ["string", "null"]}`, `null`, nil},
+		"integer":              {`{"type": "integer"}`, `1.5`, []string{"expected integer, got number"}},
+		"integer valid":        {`{"type": "integer"}`, `2`, nil},
+		"nullable":             {`{"type": "string", "nullable": true}`, `null`, nil},
+		"enum":                 {`{"enum": ["a", 1]}`, `"b"`, []string{`must be one of "a", 1`}},
+		"enum valid":           {`{"enum": ["a", 1]}`, `1`, nil},
+		"const":                {`{"const": {"a": 1}}`, `{"a": 2}`, []string{`must be {"a":1}`}},
+		"minimum":              {`{"minimum": 1}`, `0`, []string{"must be at least 1"}},
+		"exclusive minimum":    {`{"exclusiveMinimum": 1}`, `1`, []string{"must be greater than 1"}},
+		"draft 4 exclusive":    {`{"minimum": 1, "exclusiveMinimum": true}`, `1`, []string{"must be greater than 1"}},
+		"maximum":              {`{"maximum": 1}`, `2`, []string{"must be at most 1"}},
+		"multipleOf":           {`{"multipleOf": 0.5}`, `1.25`, []string{"must be a multiple of 0.5"}},
+		"minLength":            {`{"minLength": 3}`, `"äö"`, []string{"must be at least 3 characters long"}},
+		"maxLength":            {`{"maxLength": 2}`, `"äö"`, nil},
+		"pattern":              {`{"pattern": "^a+$"}`, `"ab"`, []string{"must match the pattern '^a+$'"}},
+		"format":               {`{"format": "date-time"}`, `"yesterday"`, []string{"must be a valid date-time"}},
+		"format uuid":          {`{"format": "uuid"}`, `"123e4567-e89b-12d3-a456-426614174000"`, nil},
+		"unknown format":       {`{"format": "color"}`, `"red"`, nil},
+		"items":                {`{"items": {"type": "number"}}`, `[1, "2"]`, []string{"/1: expected number, got string"}},
+		"tuple":                {`{"items": [{"type": "number"}], "additionalItems": false}`, `[1, 2]`, []string{"/1: no value is allowed here"}},
+		"minItems":             {`{"minItems": 1}`, `[]`, []string{"must have at least 1 items"}},
+		"uniqueItems":          {`{"uniqueItems": true}`, `[1, 2, 1]`, []string{"items 0 and 2 are the same, but items must be unique"}},
+		"required":             {`{"required": ["id"]}`, `{}`, []string{"missing required property 'id'"}},
+		"properties":           {`{"properties": {"a/b": {"type": "string"}}}`, `{"a/b": 1}`, []string{"/a~1b: expected string, got number"}},
+		"additionalProperties": {`{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1, "b": 2}`, []string{"property 'b' isn't allowed"}},
+		"additional schema":    {`{"additionalProperties": {"type": "string"}}`, `{"b": 2}`, []string{"/b: expected string, got number"}},
+		"patternProperties":    {`{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": false}`, `{"x-a": "1"}`, nil},
+		"allOf":                {`{"allOf": [{"minimum": 1}, {"maximum": 2}]}`, `3`, []string{"must be at most 2"}},
+		"anyOf":                {`{"anyOf": [{"type": "string"}, {"type": "boolean"}]}`, `1`, []string{"doesn't match any of the allowed schemas"}},
+		"oneOf":                {`{"oneOf": [{"minimum": 1}, {"minimum": 2}]}`, `3`, []string{"must match exactly one of the allowed schemas, but matches 2"}},
+		"not":                  {`{"not": {"type": "string"}}`, `"a"`, []string{"matches a schema it mustn't"}},
+		"ref":                  {`{"definitions": {"id": {"type": "integer"}}, "properties": {"id": {"$ref": "#/definitions/id"}}}`, `{"id": "1"}`, []string{"/id: expected integer, got string"}},
+		"missing ref":          {`{"$ref": "#/definitions/nope"}`, `1`, []string{"reference '#/definitions/nope' doesn't exist"}},
+		"remote ref":           {`{"$ref": "http://example.com/schema.json"}`, `1`, []string{"unsupported reference 'http://example.com/schema.json', only references within the document are"}},
+		"recursive ref":        {`{"properties": {"child": {"$ref": "#"}}, "required": ["name"]}`, `{"name": "a", "child": {"name": "b", "child": {}}}`, []string{"/child/child: missing required property 'name'"}},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			s, err := Parse([]byte(data.schema))
+			require.NoError(t, err)
+			errs, err := s.ValidateJSON([]byte(data.value))
+			require.NoError(t, err)
+
+			var msgs []string
+			for _, e := range errs {
+				msgs = append(msgs, e.Error())
+			}
+			assert.Equal(t, data.errs, msgs)
+		})
+	}
+
+	t.Run("GoValues", func(t *testing.T) {
+		s, err := Parse([]byte(`{"properties": {"n": {"type": "integer", "maximum": 10}}}`))
+		require.NoError(t, err)
+		assert.Len(t, s.Validate(map[string]interface{}{"n": int64(5)}), 0)
+		assert.Len(t, s.Validate(map[string]interface{}{"n": json.Number("11")}), 1)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := Parse([]byte(`{`))
+		assert.Error(t, err)
+		_, err = Parse([]byte(`"string"`))
+		assert.EqualError(t, err, "a schema must be an object or a boolean, not string")
+	})
+}
+
+func TestAt(t *testing.T) {
+	s, err := Parse([]byte(`{
+		"components": {"schemas": {
+			"User": {"type": "object", "properties": {"group": {"$ref": "#/components/schemas/Group"}}},
+			"Group": {"type": "object", "required": ["id"]}
+		}}
+	}`))
+	require.NoError(t, err)
+
+	user, err := s.At("#/components/schemas/User")
+	require.NoError(t, err)
+	errs := user.Validate(map[string]interface{}{"group": map[string]interface{}{}})
+	assert.Equal(t, []ValidationError{{Path: "/group", Message: "missing required property 'id'"}}, errs)
+
+	_, err = s.At("#/components/schemas/Nope")
+	assert.Error(t, err)
+}
@@ -0,0 +1,58 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"github.com/loadimpact/k6/stats"
+)
+
+// The metadata about the git state of the script.
+const (
+	MetadataGitCommit = "git_commit"
+	MetadataGitBranch = "git_branch"
+	MetadataGitDirty  = "git_dirty"
+)
+
+// WithMetadata returns the options with extra metadata, eg. the git state of the script, for the
+// keys that the metadata option doesn't have already, and with all of the metadata added to the
+// run tags, unless they're set explicitly, so that every sample of the test run is tagged with it.
+func (o Options) WithMetadata(extra map[string]string) Options {
+	if len(o.Metadata) == 0 && len(extra) == 0 {
+		return o
+	}
+	metadata := make(map[string]string, len(o.Metadata)+len(extra))
+	for k, v := range extra {
+		metadata[k] = v
+	}
+	for k, v := range o.Metadata {
+		metadata[k] = v
+	}
+	o.Metadata = metadata
+
+	tags := o.RunTags.CloneTags()
+	for k, v := range metadata {
+		if _, ok := tags[k]; !ok {
+			tags[k] = v
+		}
+	}
+	o.RunTags = stats.IntoSampleTags(&tags)
+	return o
+}
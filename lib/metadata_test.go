@@ -0,0 +1,65 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"testing"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsWithMetadata(t *testing.T) {
+	git := map[string]string{MetadataGitCommit: "4f1c2e9", MetadataGitBranch: "master", MetadataGitDirty: "false"}
+
+	t.Run("none", func(t *testing.T) {
+		opts := Options{}.WithMetadata(nil)
+		assert.Nil(t, opts.Metadata)
+		assert.Nil(t, opts.RunTags)
+	})
+
+	t.Run("git", func(t *testing.T) {
+		opts := Options{}.WithMetadata(git)
+		assert.Equal(t, git, opts.Metadata)
+		assert.Equal(t, git, opts.RunTags.CloneTags())
+	})
+
+	t.Run("user", func(t *testing.T) {
+		opts := Options{
+			Metadata: map[string]string{"release": "1.2.0", MetadataGitBranch: "release-1.2"},
+			RunTags:  stats.IntoSampleTags(&map[string]string{"release": "next", "env": "staging"}),
+		}.WithMetadata(git)
+
+		assert.Equal(t, map[string]string{
+			"release":         "1.2.0",
+			MetadataGitCommit: "4f1c2e9",
+			MetadataGitBranch: "release-1.2",
+			MetadataGitDirty:  "false",
+		}, opts.Metadata)
+		assert.Equal(t, map[string]string{
+			"release":         "next",
+			"env":             "staging",
+			MetadataGitCommit: "4f1c2e9",
+			MetadataGitBranch: "release-1.2",
+			MetadataGitDirty:  "false",
+		}, opts.RunTags.CloneTags())
+	})
+}
@@ -34,6 +34,29 @@ var (
 	IterationDuration = stats.New("iteration_duration", stats.Trend, stats.Time)
 	Errors            = stats.New("errors", stats.Counter)
 
+	// Iterations skipped because a resource limit was exceeded.
+	DroppedIterations = stats.New("dropped_iterations", stats.Counter)
+
+	// Iterations ended early because they made a request to an endpoint whose circuit was open.
+	SkippedIterations = stats.New("skipped_iterations", stats.Counter)
+
+	// Times a VU exceeded one of its quotas, tagged with which one.
+	VUQuotaViolations = stats.New("vu_quota_violations", stats.Counter)
+
+	// Faults started and stopped with k6/chaos, tagged with the fault and its parameters.
+	FaultInjections = stats.New("fault_injections", stats.Counter)
+
+	// Adaptive rate: the current target rate and the highest rate at which the SLO held.
+	AdaptiveRate         = stats.New("adaptive_rate", stats.Gauge)
+	AdaptiveRateCapacity = stats.New("adaptive_rate_capacity", stats.Gauge)
+
+	// Load generator health, to tell when the bottleneck was k6 itself rather than the target.
+	GeneratorCPU          = stats.New("generator_cpu", stats.Gauge)
+	GeneratorMemory       = stats.New("generator_memory", stats.Gauge, stats.Data)
+	GeneratorGoroutines   = stats.New("generator_goroutines", stats.Gauge)
+	GeneratorGCPause      = stats.New("generator_gc_pause", stats.Trend, stats.Time)
+	GeneratorEventLoopLag = stats.New("generator_event_loop_lag", stats.Trend, stats.Time)
+
 	// Runner-emitted.
 	Checks        = stats.New("checks", stats.Rate)
 	GroupDuration = stats.New("group_duration", stats.Trend, stats.Time)
@@ -47,6 +70,24 @@ var (
 	HTTPReqSending        = stats.New("http_req_sending", stats.Trend, stats.Time)
 	HTTPReqWaiting        = stats.New("http_req_waiting", stats.Trend, stats.Time)
 	HTTPReqReceiving      = stats.New("http_req_receiving", stats.Trend, stats.Time)
+	HTTPReqChunks         = stats.New("http_req_chunks", stats.Counter)
+	HTTPReqChunkInterval  = stats.New("http_req_chunk_interval", stats.Trend, stats.Time)
+	HTTPUploadThroughput  = stats.New("http_upload_throughput", stats.Trend, stats.Data)
+
+	// Cacheable requests served from the HTTP cache, and stale ones revalidated with a 304.
+	HTTPCacheHits          = stats.New("http_cache_hits", stats.Rate)
+	HTTPCacheRevalidations = stats.New("http_cache_revalidations", stats.Counter)
+
+	// Retries of failed requests, and the ones that weren't made because the budget ran out.
+	HTTPReqRetries              = stats.New("http_req_retries", stats.Counter)
+	HTTPReqRetryBudgetExhausted = stats.New("http_req_retry_budget_exhausted", stats.Counter)
+
+	// Ways in which validated response bodies didn't match their schema.
+	SchemaViolations = stats.New("schema_violations", stats.Counter)
+
+	// TLS-related.
+	OCSPStapleAge        = stats.New("ocsp_staple_age", stats.Trend, stats.Time)
+	TLSHandshakesResumed = stats.New("tls_handshakes_resumed", stats.Rate)
 
 	// Websocket-related
 	WSSessions         = stats.New("ws_sessions", stats.Counter)
@@ -56,6 +97,56 @@ var (
 	WSSessionDuration  = stats.New("ws_session_duration", stats.Trend, stats.Time)
 	WSConnecting       = stats.New("ws_connecting", stats.Trend, stats.Time)
 
+	// Raw socket-related (k6/net).
+	NetSessions        = stats.New("net_sessions", stats.Counter)
+	NetConnecting      = stats.New("net_connecting", stats.Trend, stats.Time)
+	NetTLSHandshaking  = stats.New("net_tls_handshaking", stats.Trend, stats.Time)
+	NetSessionDuration = stats.New("net_session_duration", stats.Trend, stats.Time)
+
+	// SMTP-related (k6/smtp).
+	SMTPSends        = stats.New("smtp_sends", stats.Counter)
+	SMTPSendDuration = stats.New("smtp_send_duration", stats.Trend, stats.Time)
+
+	// MQTT-related (k6/mqtt).
+	MQTTSessions         = stats.New("mqtt_sessions", stats.Counter)
+	MQTTConnecting       = stats.New("mqtt_connecting", stats.Trend, stats.Time)
+	MQTTSessionDuration  = stats.New("mqtt_session_duration", stats.Trend, stats.Time)
+	MQTTMessagesSent     = stats.New("mqtt_msgs_sent", stats.Counter)
+	MQTTMessagesReceived = stats.New("mqtt_msgs_received", stats.Counter)
+	MQTTPublishDuration  = stats.New("mqtt_publish_duration", stats.Trend, stats.Time)
+
+	// AMQP-related (k6/amqp).
+	AMQPSessions          = stats.New("amqp_sessions", stats.Counter)
+	AMQPConnecting        = stats.New("amqp_connecting", stats.Trend, stats.Time)
+	AMQPSessionDuration   = stats.New("amqp_session_duration", stats.Trend, stats.Time)
+	AMQPMessagesPublished = stats.New("amqp_msgs_published", stats.Counter)
+	AMQPMessagesReceived  = stats.New("amqp_msgs_received", stats.Counter)
+	AMQPPublishDuration   = stats.New("amqp_publish_duration", stats.Trend, stats.Time)
+
+	// LDAP-related (k6/ldap).
+	LDAPSessions        = stats.New("ldap_sessions", stats.Counter)
+	LDAPConnecting      = stats.New("ldap_connecting", stats.Trend, stats.Time)
+	LDAPSessionDuration = stats.New("ldap_session_duration", stats.Trend, stats.Time)
+	LDAPRequests        = stats.New("ldap_reqs", stats.Counter)
+	LDAPRequestDuration = stats.New("ldap_req_duration", stats.Trend, stats.Time)
+
+	// SQL-related (k6/sql).
+	SQLQueries       = stats.New("sql_queries", stats.Counter)
+	SQLQueryDuration = stats.New("sql_query_duration", stats.Trend, stats.Time)
+
+	// CoAP-related (k6/coap).
+	CoAPRequests        = stats.New("coap_reqs", stats.Counter)
+	CoAPRequestDuration = stats.New("coap_req_duration", stats.Trend, stats.Time)
+	CoAPRetransmissions = stats.New("coap_retransmissions", stats.Counter)
+
+	// Browser-related (k6/browser).
+	BrowserSessions = stats.New("browser_sessions", stats.Counter)
+	BrowserPageLoad = stats.New("browser_page_load", stats.Trend, stats.Time)
+	BrowserFCP      = stats.New("browser_web_vital_fcp", stats.Trend, stats.Time)
+	BrowserLCP      = stats.New("browser_web_vital_lcp", stats.Trend, stats.Time)
+	BrowserCLS      = stats.New("browser_web_vital_cls", stats.Trend)
+	BrowserTTFB     = stats.New("browser_web_vital_ttfb", stats.Trend, stats.Time)
+
 	// Network-related; used for future protocols as well.
 	DataSent     = stats.New("data_sent", stats.Counter, stats.Data)
 	DataReceived = stats.New("data_received", stats.Counter, stats.Data)
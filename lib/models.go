@@ -182,6 +182,28 @@ func (g *Group) Check(name string) (*Check, error) {
 	return check, nil
 }
 
+// Severities of checks. A failed check with the "warn" severity is counted and reported like any
+// other, but doesn't make check() return false.
+const (
+	CheckSeverityError = "error"
+	CheckSeverityWarn  = "warn"
+)
+
+// MaxCheckFailureSamples is how many failures a Check keeps the details of.
+const MaxCheckFailureSamples = 10
+
+// A CheckFailure holds the details of one failure of a check, for triage after the test.
+type CheckFailure struct {
+	Time      time.Time `json:"time"`
+	VU        int64     `json:"vu"`
+	Iteration int64     `json:"iter"`
+
+	// What the script said was expected and what it got instead, if it returned them.
+	Expected interface{} `json:"expected,omitempty"`
+	Actual   interface{} `json:"actual,omitempty"`
+	Message  string      `json:"message,omitempty"`
+}
+
 // A Check stores a series of successful or failing tests against a value.
 //
 // For more information, refer to the js/modules/k6.K6.Check() function.
@@ -205,6 +227,14 @@ type Check struct {
 	// Counters for how many times this check has passed and failed respectively.
 	Passes int64 `json:"passes"`
 	Fails  int64 `json:"fails"`
+
+	// The severity of the check's failures; empty if the script didn't set one, which means "error".
+	Severity string `json:"severity,omitempty"`
+
+	// The first MaxCheckFailureSamples failures of the check.
+	FailureSamples []CheckFailure `json:"failureSamples,omitempty"`
+
+	mutex sync.Mutex
 }
 
 // Creates a new check with the given name and parent group. The group may not be nil.
@@ -224,3 +254,38 @@ func NewCheck(name string, group *Group) (*Check, error) {
 		Name:  name,
 	}, nil
 }
+
+// SetSeverity sets the severity of the check's failures.
+// This is safe to call from multiple goroutines simultaneously.
+func (c *Check) SetSeverity(severity string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Severity = severity
+}
+
+// GetSeverity returns the severity of the check's failures, "error" if none was set.
+func (c *Check) GetSeverity() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.Severity == "" {
+		return CheckSeverityError
+	}
+	return c.Severity
+}
+
+// AddFailure keeps the details of a failure, unless MaxCheckFailureSamples are already kept.
+// This is safe to call from multiple goroutines simultaneously.
+func (c *Check) AddFailure(f CheckFailure) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.FailureSamples) < MaxCheckFailureSamples {
+		c.FailureSamples = append(c.FailureSamples, f)
+	}
+}
+
+// GetFailureSamples returns the details of the failures kept so far.
+func (c *Check) GetFailureSamples() []CheckFailure {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]CheckFailure(nil), c.FailureSamples...)
+}
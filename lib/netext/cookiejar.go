@@ -0,0 +1,195 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Cookie is a cookie stored in a CookieJar, with the domain and path it applies to resolved.
+type Cookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	HostOnly bool   `json:"host_only"`
+	// Zero for a session cookie.
+	Expires  time.Time `json:"expires"`
+	Secure   bool      `json:"secure"`
+	HTTPOnly bool      `json:"http_only"`
+}
+
+// URL returns a URL the cookie is sent to.
+func (c Cookie) URL() *url.URL {
+	scheme := "http"
+	if c.Secure {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: c.Domain, Path: c.Path}
+}
+
+// A CookieJar is a cookiejar.Jar that keeps track of the cookies in it, so they can be listed,
+// exported and imported. It's safe for concurrent use.
+type CookieJar struct {
+	*cookiejar.Jar
+
+	mu      sync.Mutex
+	cookies map[string]Cookie
+}
+
+// NewCookieJar returns a new, empty CookieJar.
+func NewCookieJar() (*CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieJar{Jar: jar, cookies: make(map[string]Cookie)}, nil
+}
+
+// Clear removes all cookies from the jar.
+func (j *CookieJar) Clear() {
+	jar, _ := cookiejar.New(nil) // Never fails without options.
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Jar = jar
+	j.cookies = make(map[string]Cookie)
+}
+
+func (j *CookieJar) jar() *cookiejar.Jar {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.Jar
+}
+
+// Cookies implements http.CookieJar.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar().Cookies(u)
+}
+
+// SetCookies implements http.CookieJar. Cookies the jar rejects aren't tracked.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	jar := j.jar()
+	jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, hc := range cookies {
+		c := Cookie{
+			Name:     hc.Name,
+			Value:    hc.Value,
+			Domain:   strings.ToLower(strings.TrimPrefix(hc.Domain, ".")),
+			Path:     hc.Path,
+			Secure:   hc.Secure,
+			HTTPOnly: hc.HttpOnly,
+		}
+		if c.Domain == "" {
+			c.Domain, c.HostOnly = u.Hostname(), true
+		}
+		if c.Path == "" || c.Path[0] != '/' {
+			c.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case hc.MaxAge < 0:
+			c.Expires = now
+		case hc.MaxAge > 0:
+			c.Expires = now.Add(time.Duration(hc.MaxAge) * time.Second)
+		case !hc.Expires.IsZero():
+			c.Expires = hc.Expires
+		}
+
+		key := c.Domain + ";" + c.Path + ";" + c.Name
+		if !c.Expires.IsZero() && !c.Expires.After(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		if hasCookie(jar.Cookies(c.URL()), c.Name, c.Value) {
+			j.cookies[key] = c
+		}
+	}
+}
+
+// defaultCookiePath is the path a cookie without one applies to, as per RFC 6265 section 5.1.4.
+func defaultCookiePath(urlPath string) string {
+	if urlPath == "" || urlPath[0] != '/' || strings.Count(urlPath, "/") == 1 {
+		return "/"
+	}
+	return path.Dir(urlPath)
+}
+
+func hasCookie(cookies []*http.Cookie, name, value string) bool {
+	for _, c := range cookies {
+		if c.Name == name && c.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// All returns all the cookies in the jar that haven't expired, ordered by domain, path and name.
+func (j *CookieJar) All() []Cookie {
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	cookies := make([]Cookie, 0, len(j.cookies))
+	for key, c := range j.cookies {
+		if !c.Expires.IsZero() && !c.Expires.After(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		cookies = append(cookies, c)
+	}
+	sort.Slice(cookies, func(a, b int) bool {
+		if cookies[a].Domain != cookies[b].Domain {
+			return cookies[a].Domain < cookies[b].Domain
+		}
+		if cookies[a].Path != cookies[b].Path {
+			return cookies[a].Path < cookies[b].Path
+		}
+		return cookies[a].Name < cookies[b].Name
+	})
+	return cookies
+}
+
+// Add adds cookies, eg. ones exported from another jar with All().
+func (j *CookieJar) Add(cookies []Cookie) {
+	for _, c := range cookies {
+		hc := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+		}
+		if !c.HostOnly {
+			hc.Domain = c.Domain
+		}
+		j.SetCookies(c.URL(), []*http.Cookie{hc})
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookieJar(t *testing.T) {
+	u, err := url.Parse("http://example.com/a/b")
+	require.NoError(t, err)
+
+	jar, err := NewCookieJar()
+	require.NoError(t, err)
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".Example.com", Path: "/"},
+		{Name: "foreign", Value: "3", Domain: "other.com"},
+		{Name: "expired", Value: "4", MaxAge: -1},
+	})
+
+	cookies := jar.All()
+	assert.Equal(t, []Cookie{
+		{Name: "domain", Value: "2", Domain: "example.com", Path: "/"},
+		{Name: "host", Value: "1", Domain: "example.com", Path: "/a", HostOnly: true},
+	}, cookies)
+
+	t.Run("Delete", func(t *testing.T) {
+		jar2, err := NewCookieJar()
+		require.NoError(t, err)
+		jar2.Add(cookies)
+		jar2.SetCookies(u, []*http.Cookie{{Name: "host", MaxAge: -1}})
+		assert.Len(t, jar2.All(), 1)
+		assert.Len(t, jar2.Cookies(u), 1)
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		expires := time.Now().Add(time.Hour).Truncate(time.Second)
+		jar2, err := NewCookieJar()
+		require.NoError(t, err)
+		jar2.Add(cookies)
+		jar2.Add([]Cookie{{Name: "secure", Value: "5", Domain: "example.com", Path: "/", Secure: true, Expires: expires}})
+		assert.Len(t, jar2.Cookies(u), 2)
+
+		secure, err := url.Parse("https://sub.example.com/")
+		require.NoError(t, err)
+		assert.Len(t, jar2.Cookies(secure), 2)
+		all := jar2.All()
+		if assert.Len(t, all, 3) {
+			assert.Equal(t, "secure", all[1].Name)
+			assert.True(t, expires.Equal(all[1].Expires))
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		jar2, err := NewCookieJar()
+		require.NoError(t, err)
+		jar2.Add(cookies)
+		jar2.Clear()
+		assert.Empty(t, jar2.All())
+		assert.Empty(t, jar2.Cookies(u))
+	})
+}
@@ -27,6 +27,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/stats"
 
@@ -35,7 +36,7 @@ import (
 )
 
 // Dialer wraps net.Dialer and provides k6 specific functionality -
-// tracing, blacklists and DNS cache and aliases.
+// tracing, blacklists, allowed and blocked hosts, and DNS cache and aliases.
 type Dialer struct {
 	net.Dialer
 
@@ -43,8 +44,18 @@ type Dialer struct {
 	Blacklist []*net.IPNet
 	Hosts     map[string]net.IP
 
+	// Patterns of the hosts that may be connected to, if any, and of those that may not.
+	AllowedHosts []string
+	BlockedHosts []string
+
 	BytesRead    int64
 	BytesWritten int64
+
+	// Count of connections that are open right now, shared between Dialers, if not nil.
+	OpenConns *int64
+
+	// Counts the connections of the VU the Dialer belongs to against its quota, if not nil.
+	Handles *lib.HandleCounter
 }
 
 // NewDialer constructs a new Dialer and initializes its cache.
@@ -75,15 +86,32 @@ func (d *Dialer) DialContext(ctx context.Context, proto, addr string) (net.Conn,
 			return nil, errors.Errorf("IP (%s) is in a blacklisted range (%s)", ip, net)
 		}
 	}
+	if err := lib.CheckHost(d.AllowedHosts, d.BlockedHosts, host, ip); err != nil {
+		return nil, err
+	}
 	ipStr := ip.String()
 	if strings.ContainsRune(ipStr, ':') {
 		ipStr = "[" + ipStr + "]"
 	}
+	if d.Handles != nil {
+		if err := d.Handles.Acquire(); err != nil {
+			return nil, err
+		}
+	}
 	conn, err := d.Dialer.DialContext(ctx, proto, ipStr+":"+addr[delimiter+1:])
 	if err != nil {
+		if d.Handles != nil {
+			d.Handles.Release()
+		}
 		return nil, err
 	}
-	conn = &Conn{conn, &d.BytesRead, &d.BytesWritten}
+	if d.OpenConns != nil {
+		atomic.AddInt64(d.OpenConns, 1)
+	}
+	conn = &Conn{
+		Conn: conn, BytesRead: &d.BytesRead, BytesWritten: &d.BytesWritten,
+		OpenConns: d.OpenConns, Handles: d.Handles,
+	}
 	return conn, err
 }
 
@@ -161,6 +189,23 @@ type Conn struct {
 	net.Conn
 
 	BytesRead, BytesWritten *int64
+
+	// Decremented when the connection is closed, if not nil.
+	OpenConns *int64
+	Handles   *lib.HandleCounter
+	closed    int32
+}
+
+func (c *Conn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		if c.OpenConns != nil {
+			atomic.AddInt64(c.OpenConns, -1)
+		}
+		if c.Handles != nil {
+			c.Handles.Release()
+		}
+	}
+	return c.Conn.Close()
 }
 
 func (c *Conn) Read(b []byte) (int, error) {
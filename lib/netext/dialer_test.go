@@ -0,0 +1,61 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package netext
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialerOpenConns(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			if _, err := ln.Accept(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Dialers share the count.
+	var openConns int64
+	d1, d2 := NewDialer(net.Dialer{}), NewDialer(net.Dialer{})
+	d1.OpenConns, d2.OpenConns = &openConns, &openConns
+
+	c1, err := d1.DialContext(context.Background(), "tcp", ln.Addr().String())
+	require.NoError(t, err)
+	c2, err := d2.DialContext(context.Background(), "tcp", ln.Addr().String())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&openConns))
+
+	// Closing a connection twice only counts once.
+	assert.NoError(t, c1.Close())
+	assert.Error(t, c1.Close())
+	assert.Equal(t, int64(1), atomic.LoadInt64(&openConns))
+	assert.NoError(t, c2.Close())
+	assert.Equal(t, int64(0), atomic.LoadInt64(&openConns))
+}
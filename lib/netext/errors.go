@@ -0,0 +1,89 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// ErrorClass returns the class of a request error, for deciding whether to retry it: "dns",
+// "connect", "tls", "reset" or "timeout", or "" if it's none of those.
+func ErrorClass(err error) string {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if err == context.DeadlineExceeded {
+		return "timeout"
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return "timeout"
+	}
+
+	switch e := err.(type) {
+	case *net.DNSError:
+		return "dns"
+	case tls.RecordHeaderError, x509.CertificateInvalidError, x509.HostnameError, x509.UnknownAuthorityError:
+		return "tls"
+	case *net.OpError:
+		if _, ok := e.Err.(*net.DNSError); ok {
+			return "dns"
+		}
+		if e.Op == "dial" {
+			return "connect"
+		}
+		if isReset(e.Err) {
+			return "reset"
+		}
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF || isReset(err) {
+		return "reset"
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "tls: ") || strings.Contains(msg, "x509: "):
+		return "tls"
+	case strings.Contains(msg, "connection reset") || strings.Contains(msg, "broken pipe") ||
+		strings.Contains(msg, "server closed idle connection"):
+		return "reset"
+	case strings.Contains(msg, "connection refused"):
+		return "connect"
+	}
+	return ""
+}
+
+func isReset(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+	return err == syscall.ECONNRESET || err == syscall.EPIPE
+}
@@ -0,0 +1,56 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClass(t *testing.T) {
+	testdata := map[string]struct {
+		err   error
+		class string
+	}{
+		"dns":      {&net.DNSError{Err: "no such host", Name: "nope"}, "dns"},
+		"dial dns": {&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host"}}, "dns"},
+		"refused":  {&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, "connect"},
+		"reset":    {&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, "reset"},
+		"eof":      {&url.Error{Op: "Get", URL: "http://example.com", Err: io.EOF}, "reset"},
+		"deadline": {context.DeadlineExceeded, "timeout"},
+		"tls":      {errors.New("tls: handshake failure"), "tls"},
+		"x509":     {errors.New("x509: certificate signed by unknown authority"), "tls"},
+		"other":    {errors.New("oops"), ""},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, data.class, ErrorClass(data.err))
+		})
+	}
+}
@@ -0,0 +1,258 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An HTTPCache is a private HTTP cache, like a browser's, that honors Cache-Control, Expires and
+// the ETag and Last-Modified validators of the responses to GET requests. It's safe for
+// concurrent use.
+type HTTPCache struct {
+	mu      sync.Mutex
+	entries map[string][]*CachedResponse
+}
+
+// A CachedResponse is a response stored in an HTTPCache.
+type CachedResponse struct {
+	URL    string
+	Status int
+	Proto  string
+	Header http.Header
+	Body   []byte
+
+	// The values of the request headers named in the Vary header of the response.
+	vary map[string]string
+	// When the response was received, how old it already was then, and how long it stays fresh.
+	received time.Time
+	age      time.Duration
+	lifetime time.Duration
+}
+
+// NewHTTPCache returns a new, empty HTTPCache.
+func NewHTTPCache() *HTTPCache {
+	return &HTTPCache{entries: make(map[string][]*CachedResponse)}
+}
+
+// Cacheable returns whether a response to a request may be looked up in or stored in the cache.
+func Cacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet && !parseCacheControl(req.Header).has("no-store")
+}
+
+// Lookup returns the cached response for a request, if there is one. It may need revalidating.
+func (c *HTTPCache) Lookup(req *http.Request) *CachedResponse {
+	if !Cacheable(req) {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range c.entries[req.URL.String()] {
+		if r.matches(req) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Store stores a response to a request, with its body, if its headers allow it. Any cached
+// response it replaces is removed.
+func (c *HTTPCache) Store(req *http.Request, res *http.Response, body []byte) bool {
+	if !Cacheable(req) || !storable(res) {
+		return false
+	}
+
+	now := time.Now()
+	r := &CachedResponse{
+		URL:    req.URL.String(),
+		Status: res.StatusCode,
+		Proto:  res.Proto,
+		Header: res.Header,
+		Body:   append([]byte(nil), body...),
+	}
+	if r.Header.Get("Vary") != "" {
+		r.vary = make(map[string]string)
+		for _, name := range strings.Split(r.Header.Get("Vary"), ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			r.vary[name] = req.Header.Get(name)
+		}
+	}
+	r.freshen(now)
+	if r.lifetime <= 0 && r.Header.Get("ETag") == "" && r.Header.Get("Last-Modified") == "" {
+		return false // It could never be used.
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := c.entries[r.URL]
+	for i, e := range entries {
+		if e.matches(req) {
+			entries = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+	c.entries[r.URL] = append(entries, r)
+	return true
+}
+
+// Revalidated updates a cached response with the headers of the 304 Not Modified response that
+// revalidated it.
+func (c *HTTPCache) Revalidated(r *CachedResponse, res *http.Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	header := make(http.Header, len(r.Header))
+	for k, vs := range r.Header {
+		header[k] = vs
+	}
+	for k, vs := range res.Header {
+		if k != "Content-Length" {
+			header[k] = vs
+		}
+	}
+	r.Header = header
+	r.freshen(time.Now())
+}
+
+// Fresh returns whether a cached response can be used without revalidating it, for a request.
+func (r *CachedResponse) Fresh(req *http.Request) bool {
+	reqCC := parseCacheControl(req.Header)
+	if reqCC.has("no-cache") || req.Header.Get("Pragma") == "no-cache" || reqCC.has("max-age") && reqCC.seconds("max-age") == 0 {
+		return false
+	}
+	if parseCacheControl(r.Header).has("no-cache") {
+		return false
+	}
+	current := r.age + time.Since(r.received)
+	return current < r.lifetime
+}
+
+// AddConditions makes a request conditional on the validators of a cached response, unless it
+// already is conditional on others or there are none. It returns whether it did.
+func (r *CachedResponse) AddConditions(req *http.Request) bool {
+	etag, lastModified := r.Header.Get("ETag"), r.Header.Get("Last-Modified")
+	if v := req.Header.Get("If-None-Match"); v != "" && v != etag {
+		return false
+	}
+	if v := req.Header.Get("If-Modified-Since"); v != "" && v != lastModified {
+		return false
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	return etag != "" || lastModified != ""
+}
+
+func (r *CachedResponse) matches(req *http.Request) bool {
+	for name, value := range r.vary {
+		if name == "*" || req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// freshen computes how old a response is and how long it stays fresh, as of when it was received,
+// as per RFC 7234 section 4.2.
+func (r *CachedResponse) freshen(received time.Time) {
+	r.received = received
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		date = received
+	}
+	r.age = received.Sub(date)
+	if age, err := strconv.ParseInt(r.Header.Get("Age"), 10, 64); err == nil && time.Duration(age)*time.Second > r.age {
+		r.age = time.Duration(age) * time.Second
+	}
+	if r.age < 0 {
+		r.age = 0
+	}
+
+	cc := parseCacheControl(r.Header)
+	switch {
+	case cc.has("max-age"):
+		r.lifetime = cc.seconds("max-age")
+	case r.Header.Get("Expires") != "":
+		// An invalid date, eg. "0", means it has already expired.
+		if expires, err := http.ParseTime(r.Header.Get("Expires")); err == nil {
+			r.lifetime = expires.Sub(date)
+		} else {
+			r.lifetime = 0
+		}
+	default:
+		// The heuristic browsers use: a tenth of the time since it was last modified.
+		if lastModified, err := http.ParseTime(r.Header.Get("Last-Modified")); err == nil && date.After(lastModified) {
+			r.lifetime = date.Sub(lastModified) / 10
+		} else {
+			r.lifetime = 0
+		}
+	}
+}
+
+// storable returns whether a response may be stored in a private cache.
+func storable(res *http.Response) bool {
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMovedPermanently, http.StatusGone:
+	default:
+		return false
+	}
+	return !parseCacheControl(res.Header).has("no-store") && strings.TrimSpace(res.Header.Get("Vary")) != "*"
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, value := range h["Cache-Control"] {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg := directive, ""
+			if i := strings.IndexByte(directive, '='); i >= 0 {
+				name, arg = directive[:i], strings.Trim(directive[i+1:], `"`)
+			}
+			cc[strings.ToLower(name)] = arg
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds returns the value of a directive in seconds, or 0 if it isn't a valid number.
+func (cc cacheControl) seconds(name string) time.Duration {
+	n, err := strconv.ParseInt(cc[name], 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
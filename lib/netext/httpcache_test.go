@@ -0,0 +1,139 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPCache(t *testing.T) {
+	newRequest := func(method string, header http.Header) *http.Request {
+		req, err := http.NewRequest(method, "http://example.com/a", nil)
+		require.NoError(t, err)
+		for k, vs := range header {
+			req.Header[k] = vs
+		}
+		return req
+	}
+	newResponse := func(status int, header http.Header) *http.Response {
+		if header.Get("Date") == "" {
+			header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		}
+		return &http.Response{StatusCode: status, Proto: "HTTP/1.1", Header: header}
+	}
+
+	t.Run("Freshness", func(t *testing.T) {
+		now := time.Now().UTC()
+		testdata := map[string]struct {
+			header http.Header
+			fresh  bool
+		}{
+			"max-age":       {http.Header{"Cache-Control": {"public, max-age=60"}}, true},
+			"max-age age":   {http.Header{"Cache-Control": {"max-age=60"}, "Age": {"120"}}, false},
+			"no-cache":      {http.Header{"Cache-Control": {"max-age=60, no-cache"}}, false},
+			"expires":       {http.Header{"Expires": {now.Add(time.Hour).Format(http.TimeFormat)}}, true},
+			"expired":       {http.Header{"Expires": {now.Add(-time.Hour).Format(http.TimeFormat)}, "Etag": {`"a"`}}, false},
+			"invalid":       {http.Header{"Expires": {"0"}, "Etag": {`"a"`}}, false},
+			"last-modified": {http.Header{"Last-Modified": {now.Add(-100 * time.Hour).Format(http.TimeFormat)}}, true},
+		}
+		for name, data := range testdata {
+			t.Run(name, func(t *testing.T) {
+				c := NewHTTPCache()
+				req := newRequest("GET", nil)
+				assert.True(t, c.Store(req, newResponse(200, data.header), []byte("body")))
+				cached := c.Lookup(req)
+				require.NotNil(t, cached)
+				assert.Equal(t, data.fresh, cached.Fresh(req))
+				assert.Equal(t, []byte("body"), cached.Body)
+			})
+		}
+
+		c := NewHTTPCache()
+		req := newRequest("GET", nil)
+		require.True(t, c.Store(req, newResponse(200, http.Header{"Cache-Control": {"max-age=60"}}), nil))
+		assert.False(t, c.Lookup(req).Fresh(newRequest("GET", http.Header{"Cache-Control": {"no-cache"}})))
+		assert.False(t, c.Lookup(req).Fresh(newRequest("GET", http.Header{"Pragma": {"no-cache"}})))
+	})
+
+	t.Run("NotStored", func(t *testing.T) {
+		testdata := map[string]struct {
+			method string
+			req    http.Header
+			status int
+			res    http.Header
+		}{
+			"post":         {"POST", nil, 200, http.Header{"Cache-Control": {"max-age=60"}}},
+			"status":       {"GET", nil, 500, http.Header{"Cache-Control": {"max-age=60"}}},
+			"no-store":     {"GET", nil, 200, http.Header{"Cache-Control": {"no-store, max-age=60"}}},
+			"req no-store": {"GET", http.Header{"Cache-Control": {"no-store"}}, 200, http.Header{"Cache-Control": {"max-age=60"}}},
+			"vary *":       {"GET", nil, 200, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}}},
+			"unusable":     {"GET", nil, 200, http.Header{}},
+		}
+		for name, data := range testdata {
+			t.Run(name, func(t *testing.T) {
+				c := NewHTTPCache()
+				req := newRequest(data.method, data.req)
+				assert.False(t, c.Store(req, newResponse(data.status, data.res), nil))
+				assert.Nil(t, c.Lookup(newRequest(data.method, nil)))
+			})
+		}
+	})
+
+	t.Run("Vary", func(t *testing.T) {
+		c := NewHTTPCache()
+		gzip := newRequest("GET", http.Header{"Accept-Encoding": {"gzip"}})
+		plain := newRequest("GET", nil)
+		require.True(t, c.Store(gzip, newResponse(200, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Encoding"}}), []byte("gz")))
+		assert.Nil(t, c.Lookup(plain))
+		require.True(t, c.Store(plain, newResponse(200, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Encoding"}}), []byte("plain")))
+		assert.Equal(t, []byte("gz"), c.Lookup(gzip).Body)
+		assert.Equal(t, []byte("plain"), c.Lookup(plain).Body)
+	})
+
+	t.Run("Revalidate", func(t *testing.T) {
+		c := NewHTTPCache()
+		req := newRequest("GET", nil)
+		require.True(t, c.Store(req, newResponse(200, http.Header{
+			"Etag":          {`"v1"`},
+			"Last-Modified": {"Mon, 02 Jan 2006 15:04:05 GMT"},
+			"Cache-Control": {"max-age=0"},
+		}), []byte("body")))
+		cached := c.Lookup(req)
+		require.NotNil(t, cached)
+		assert.False(t, cached.Fresh(req))
+
+		assert.True(t, cached.AddConditions(req))
+		assert.Equal(t, `"v1"`, req.Header.Get("If-None-Match"))
+		assert.Equal(t, "Mon, 02 Jan 2006 15:04:05 GMT", req.Header.Get("If-Modified-Since"))
+		assert.True(t, cached.AddConditions(req))
+		assert.False(t, cached.AddConditions(newRequest("GET", http.Header{"If-None-Match": {`"v0"`}})))
+
+		c.Revalidated(cached, newResponse(304, http.Header{"Cache-Control": {"max-age=60"}}))
+		assert.True(t, cached.Fresh(newRequest("GET", nil)))
+		assert.Equal(t, `"v1"`, cached.Header.Get("ETag"))
+		assert.Equal(t, []byte("body"), cached.Body)
+	})
+}
@@ -0,0 +1,104 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/loadimpact/k6/lib"
+)
+
+// HTTPExchange is an HTTP request made by a VU, along with its response
+type HTTPExchange struct {
+	Method         string
+	URL            string
+	RequestHeaders http.Header
+	RequestBody    string
+
+	Status          int
+	Proto           string
+	ResponseHeaders http.Header
+	// Only set if the response body was kept, i.e. not discarded or streamed
+	ResponseBody     []byte
+	ResponseBodySize int64
+	// Set if the request failed, in which case there's no response
+	Error string
+
+	Trail *Trail
+}
+
+// Recorder keeps the HTTP exchanges of all VUs in a test run, so they can be
+// written out when it's over. It's safe for concurrent use.
+type Recorder struct {
+	mutex     sync.Mutex
+	exchanges []*HTTPExchange
+}
+
+// NewRecorder returns an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record adds an exchange to the recording, with the registered secrets redacted
+func (r *Recorder) Record(exchange *HTTPExchange) {
+	redactExchange(exchange)
+	r.mutex.Lock()
+	r.exchanges = append(r.exchanges, exchange)
+	r.mutex.Unlock()
+}
+
+// Exchanges returns the exchanges recorded so far, in the order they finished
+func (r *Recorder) Exchanges() []*HTTPExchange {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*HTTPExchange{}, r.exchanges...)
+}
+
+func redactExchange(exchange *HTTPExchange) {
+	exchange.URL = lib.Secrets.Redact(exchange.URL)
+	exchange.RequestHeaders = redactHeaders(exchange.RequestHeaders)
+	exchange.RequestBody = lib.Secrets.Redact(exchange.RequestBody)
+	exchange.ResponseHeaders = redactHeaders(exchange.ResponseHeaders)
+	if exchange.ResponseBody != nil {
+		body := string(exchange.ResponseBody)
+		if redacted := lib.Secrets.Redact(body); redacted != body {
+			exchange.ResponseBody = []byte(redacted)
+		}
+	}
+	exchange.Error = lib.Secrets.Redact(exchange.Error)
+}
+
+// redactHeaders returns a copy of headers with the registered secrets redacted, since the
+// recorded ones may be shared with the request or response.
+func redactHeaders(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	res := make(http.Header, len(h))
+	for name, values := range h {
+		res[name] = make([]string, len(values))
+		for i, v := range values {
+			res[name][i] = lib.Secrets.Redact(v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,59 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderSecrets(t *testing.T) {
+	require.NoError(t, lib.Secrets.Add("rec0rder-s3cr3t"))
+	defer lib.Secrets.Reset()
+
+	reqHeaders := http.Header{"Authorization": []string{"Bearer rec0rder-s3cr3t"}}
+	r := NewRecorder()
+	r.Record(&HTTPExchange{
+		Method:          "POST",
+		URL:             "http://example.com/?token=rec0rder-s3cr3t",
+		RequestHeaders:  reqHeaders,
+		RequestBody:     `{"token":"rec0rder-s3cr3t"}`,
+		Status:          200,
+		ResponseHeaders: http.Header{"Content-Type": []string{"text/plain"}},
+		ResponseBody:    []byte("hello rec0rder-s3cr3t"),
+	})
+
+	exchanges := r.Exchanges()
+	if assert.Len(t, exchanges, 1) {
+		e := exchanges[0]
+		assert.Equal(t, "http://example.com/?token=[SECRET]", e.URL)
+		assert.Equal(t, "Bearer [SECRET]", e.RequestHeaders.Get("Authorization"))
+		assert.Equal(t, `{"token":"[SECRET]"}`, e.RequestBody)
+		assert.Equal(t, "text/plain", e.ResponseHeaders.Get("Content-Type"))
+		assert.Equal(t, "hello [SECRET]", string(e.ResponseBody))
+	}
+	// The headers of the request itself aren't changed.
+	assert.Equal(t, "Bearer rec0rder-s3cr3t", reqHeaders.Get("Authorization"))
+}
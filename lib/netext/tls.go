@@ -2,8 +2,11 @@ package netext
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"time"
 
 	"github.com/loadimpact/k6/lib"
+	"github.com/pkg/errors"
 	"golang.org/x/crypto/ocsp"
 )
 
@@ -31,6 +34,8 @@ const (
 type TLSInfo struct {
 	Version     string
 	CipherSuite string
+	Resumed     bool
+	Group       string
 }
 type OCSP struct {
 	ProducedAt       int64  `json:"produced_at"`
@@ -55,19 +60,12 @@ func ParseTLSConnState(tlsState *tls.ConnectionState) (TLSInfo, OCSP) {
 	}
 
 	tlsInfo.CipherSuite = lib.SupportedTLSCipherSuitesToString[tlsState.CipherSuite]
+	tlsInfo.Resumed = tlsState.DidResume
+	tlsInfo.Group = negotiatedGroup(tlsState)
 	ocspStapledRes := OCSP{Status: OCSP_STATUS_UNKNOWN}
 
 	if ocspRes, err := ocsp.ParseResponse(tlsState.OCSPResponse, nil); err == nil {
-		switch ocspRes.Status {
-		case ocsp.Good:
-			ocspStapledRes.Status = OCSP_STATUS_GOOD
-		case ocsp.Revoked:
-			ocspStapledRes.Status = OCSP_STATUS_REVOKED
-		case ocsp.ServerFailed:
-			ocspStapledRes.Status = OCSP_STATUS_SERVER_FAILED
-		case ocsp.Unknown:
-			ocspStapledRes.Status = OCSP_STATUS_UNKNOWN
-		}
+		ocspStapledRes.Status = ocspStatus(ocspRes.Status)
 		switch ocspRes.RevocationReason {
 		case ocsp.Unspecified:
 			ocspStapledRes.RevocationReason = OCSP_REASON_UNSPECIFIED
@@ -98,3 +96,52 @@ func ParseTLSConnState(tlsState *tls.ConnectionState) (TLSInfo, OCSP) {
 
 	return tlsInfo, ocspStapledRes
 }
+
+func ocspStatus(status int) string {
+	switch status {
+	case ocsp.Good:
+		return OCSP_STATUS_GOOD
+	case ocsp.Revoked:
+		return OCSP_STATUS_REVOKED
+	case ocsp.ServerFailed:
+		return OCSP_STATUS_SERVER_FAILED
+	default:
+		return OCSP_STATUS_UNKNOWN
+	}
+}
+
+// ErrNoOCSPStaple is returned by VerifyOCSPStaple if the server didn't staple an OCSP response.
+var ErrNoOCSPStaple = errors.New("the server didn't staple an OCSP response to the TLS handshake")
+
+// VerifyOCSPStaple checks that the server stapled a valid OCSP response for its certificate to
+// the TLS handshake: one signed by the certificate's issuer, saying that it's good, and that
+// hasn't expired at the given time.
+func VerifyOCSPStaple(tlsState *tls.ConnectionState, now time.Time) error {
+	if len(tlsState.OCSPResponse) == 0 {
+		return ErrNoOCSPStaple
+	}
+
+	// Prefer the verified chain, which includes the root if the issuer is one; it's not there
+	// if certificate verification is disabled, but the server may still have sent the issuer.
+	var chain []*x509.Certificate
+	if len(tlsState.VerifiedChains) > 0 {
+		chain = tlsState.VerifiedChains[0]
+	} else {
+		chain = tlsState.PeerCertificates
+	}
+	if len(chain) < 2 {
+		return errors.New("can't verify the stapled OCSP response without the server certificate's issuer")
+	}
+
+	res, err := ocsp.ParseResponseForCert(tlsState.OCSPResponse, chain[0], chain[1])
+	if err != nil {
+		return errors.Wrap(err, "invalid stapled OCSP response")
+	}
+	if res.Status != ocsp.Good {
+		return errors.Errorf("the stapled OCSP response has status '%s'", ocspStatus(res.Status))
+	}
+	if !res.NextUpdate.IsZero() && now.After(res.NextUpdate) {
+		return errors.Errorf("the stapled OCSP response expired at %s", res.NextUpdate.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext_test
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+func newTestCA(t *testing.T, cn string) (*x509.Certificate, crypto.Signer) {
+	return testutils.NewTestCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: cn}}, nil, nil)
+}
+
+func TestVerifyOCSPStaple(t *testing.T) {
+	ca, caKey := newTestCA(t, "CA")
+	leaf, _ := testutils.NewTestCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "leaf"}}, ca, caKey)
+	otherCA, otherCAKey := newTestCA(t, "Other CA")
+	now := time.Now()
+
+	staple := func(status int, nextUpdate time.Time, issuer *x509.Certificate, key crypto.Signer) []byte {
+		res, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+			Status:       status,
+			SerialNumber: leaf.SerialNumber,
+			ThisUpdate:   now.Add(-1 * time.Hour),
+			NextUpdate:   nextUpdate,
+			RevokedAt:    now.Add(-1 * time.Hour),
+		}, key)
+		require.NoError(t, err)
+		return res
+	}
+	tomorrow := now.Add(24 * time.Hour)
+
+	testdata := map[string]struct {
+		state tls.ConnectionState
+		err   string
+	}{
+		"Good": {
+			tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf, ca},
+				OCSPResponse:     staple(ocsp.Good, tomorrow, ca, caKey),
+			},
+			"",
+		},
+		"Good, verified chain": {
+			tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf},
+				VerifiedChains:   [][]*x509.Certificate{{leaf, ca}},
+				OCSPResponse:     staple(ocsp.Good, tomorrow, ca, caKey),
+			},
+			"",
+		},
+		"Good, no next update": {
+			tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf, ca},
+				OCSPResponse:     staple(ocsp.Good, time.Time{}, ca, caKey),
+			},
+			"",
+		},
+		"Missing": {
+			tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca}},
+			netext.ErrNoOCSPStaple.Error(),
+		},
+		"No issuer": {
+			tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf},
+				OCSPResponse:     staple(ocsp.Good, tomorrow, ca, caKey),
+			},
+			"can't verify the stapled OCSP response without the server certificate's issuer",
+		},
+		"Garbage": {
+			tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf, ca},
+				OCSPResponse:     []byte("garbage"),
+			},
+			"invalid stapled OCSP response: ",
+		},
+		"Wrong issuer": {
+			tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf, ca},
+				OCSPResponse:     staple(ocsp.Good, tomorrow, otherCA, otherCAKey),
+			},
+			"invalid stapled OCSP response: ",
+		},
+		"Revoked": {
+			tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf, ca},
+				OCSPResponse:     staple(ocsp.Revoked, tomorrow, ca, caKey),
+			},
+			"the stapled OCSP response has status 'revoked'",
+		},
+		"Expired": {
+			tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf, ca},
+				OCSPResponse:     staple(ocsp.Good, now.Add(-1*time.Minute), ca, caKey),
+			},
+			"the stapled OCSP response expired at ",
+		},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			err := netext.VerifyOCSPStaple(&data.state, now)
+			if data.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), data.err)
+			}
+		})
+	}
+}
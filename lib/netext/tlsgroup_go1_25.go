@@ -0,0 +1,41 @@
+// +build go1.25
+
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"crypto/tls"
+
+	"github.com/loadimpact/k6/lib"
+)
+
+// negotiatedGroup returns the name of the key exchange group used for the handshake, or an empty
+// string if it's unknown, eg. because the session was resumed without a new key exchange.
+func negotiatedGroup(tlsState *tls.ConnectionState) string {
+	if tlsState.CurveID == 0 {
+		return ""
+	}
+	if name, ok := lib.SupportedTLSGroupsToString[tlsState.CurveID]; ok {
+		return name
+	}
+	return tlsState.CurveID.String()
+}
@@ -0,0 +1,43 @@
+// +build go1.25
+
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiatedGroup(t *testing.T) {
+	testdata := map[tls.CurveID]string{
+		0:                   "",
+		tls.X25519:          "X25519",
+		tls.CurveP384:       "P384",
+		tls.X25519MLKEM768:  "X25519MLKEM768",
+		tls.CurveID(0x1234): "CurveID(4660)",
+	}
+	for id, name := range testdata {
+		assert.Equal(t, name, negotiatedGroup(&tls.ConnectionState{CurveID: id}), id.String())
+	}
+}
@@ -56,6 +56,13 @@ type Trail struct {
 	ConnRemoteAddr net.Addr
 	Errors         []error
 
+	// The W3C trace context propagated in the request, as hex strings; only set with the
+	// traceContext option.
+	TraceID, SpanID string
+
+	// The metadata of the request, added to its samples by SaveSamples().
+	Metadata map[string]string
+
 	// Populated by SaveSamples()
 	Tags    *stats.SampleTags
 	Samples []stats.Sample
@@ -65,15 +72,15 @@ type Trail struct {
 func (tr *Trail) SaveSamples(tags *stats.SampleTags) {
 	tr.Tags = tags
 	tr.Samples = []stats.Sample{
-		{Metric: metrics.HTTPReqs, Time: tr.EndTime, Tags: tags, Value: 1},
-		{Metric: metrics.HTTPReqDuration, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Duration)},
-
-		{Metric: metrics.HTTPReqBlocked, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Blocked)},
-		{Metric: metrics.HTTPReqConnecting, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Connecting)},
-		{Metric: metrics.HTTPReqTLSHandshaking, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.TLSHandshaking)},
-		{Metric: metrics.HTTPReqSending, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Sending)},
-		{Metric: metrics.HTTPReqWaiting, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Waiting)},
-		{Metric: metrics.HTTPReqReceiving, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Receiving)},
+		{Metric: metrics.HTTPReqs, Time: tr.EndTime, Tags: tags, Value: 1, Metadata: tr.Metadata},
+		{Metric: metrics.HTTPReqDuration, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Duration), Metadata: tr.Metadata},
+
+		{Metric: metrics.HTTPReqBlocked, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Blocked), Metadata: tr.Metadata},
+		{Metric: metrics.HTTPReqConnecting, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Connecting), Metadata: tr.Metadata},
+		{Metric: metrics.HTTPReqTLSHandshaking, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.TLSHandshaking), Metadata: tr.Metadata},
+		{Metric: metrics.HTTPReqSending, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Sending), Metadata: tr.Metadata},
+		{Metric: metrics.HTTPReqWaiting, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Waiting), Metadata: tr.Metadata},
+		{Metric: metrics.HTTPReqReceiving, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Receiving), Metadata: tr.Metadata},
 	}
 }
 
@@ -92,8 +99,42 @@ func (tr *Trail) GetTime() time.Time {
 	return tr.EndTime
 }
 
+// MapTags implements the stats.TagMapper interface: it returns a copy of the trail, with its tags
+// and the tags of its samples mapped.
+func (tr *Trail) MapTags(fn func(*stats.SampleTags) *stats.SampleTags) stats.SampleContainer {
+	res := *tr
+	res.Tags = fn(tr.Tags)
+	res.Samples = make([]stats.Sample, len(tr.Samples))
+	for i, sample := range tr.Samples {
+		if sample.Tags == tr.Tags {
+			sample.Tags = res.Tags
+		} else {
+			sample.Tags = fn(sample.Tags)
+		}
+		res.Samples[i] = sample
+	}
+	return &res
+}
+
+// GetSpan implements the stats.TracedSampleContainer interface; there's only a span if the
+// request propagated a trace context.
+func (tr *Trail) GetSpan() (stats.Span, bool) {
+	name := "HTTP"
+	if method, ok := tr.Tags.Get("method"); ok && method != "" {
+		name += " " + method
+	}
+	return stats.Span{
+		TraceID: tr.TraceID,
+		SpanID:  tr.SpanID,
+		Name:    name,
+		Start:   tr.StartTime,
+		End:     tr.EndTime,
+	}, tr.TraceID != ""
+}
+
 // Ensure that interfaces are implemented correctly
 var _ stats.ConnectedSampleContainer = &Trail{}
+var _ stats.TracedSampleContainer = &Trail{}
 
 // A Tracer wraps "net/http/httptrace" to collect granular timings for HTTP requests.
 // Note that since there is not yet an event for the end of a request (there's a PR to
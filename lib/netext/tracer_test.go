@@ -34,6 +34,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/stats"
 	"github.com/mccutchen/go-httpbin/httpbin"
@@ -237,3 +238,30 @@ func TestCancelledRequest(t *testing.T) {
 		}
 	})
 }
+
+func TestTrailRedactSamples(t *testing.T) {
+	r := &lib.SecretRegistry{}
+	require.NoError(t, r.Add("s3cr3t"))
+
+	tags := stats.IntoSampleTags(&map[string]string{"url": "http://example.com/?token=s3cr3t", "method": "GET"})
+	trail := &Trail{EndTime: time.Now(), Duration: time.Second, TraceID: "0af7651916cd43dd8448eb211c80319c"}
+	trail.SaveSamples(tags)
+
+	redacted := r.RedactSamples([]stats.SampleContainer{trail})
+	// The cloud collector aggregates the HTTP trails, so they have to stay trails.
+	redactedTrail, ok := redacted[0].(*Trail)
+	require.True(t, ok)
+	assert.Equal(t, "http://example.com/?token=[SECRET]", redactedTrail.Tags.CloneTags()["url"])
+	assert.Equal(t, trail.Duration, redactedTrail.Duration)
+	assert.Equal(t, trail.TraceID, redactedTrail.TraceID)
+	if assert.Len(t, redactedTrail.Samples, len(trail.Samples)) {
+		for i, sample := range redactedTrail.Samples {
+			assert.True(t, redactedTrail.Tags == sample.Tags)
+			assert.Equal(t, trail.Samples[i].Value, sample.Value)
+		}
+	}
+
+	// The original trail isn't changed.
+	assert.True(t, tags == trail.Tags)
+	assert.True(t, tags == trail.Samples[0].Tags)
+}
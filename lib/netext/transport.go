@@ -1,11 +1,17 @@
 package netext
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/stats"
 	"github.com/pkg/errors"
 )
@@ -16,6 +22,9 @@ type Transport struct {
 	tags         map[string]string
 	trail        *Trail
 	tlsInfo      TLSInfo
+	traceID      string // Shared by all round trips of a request, eg. redirects.
+	faults       *lib.FaultInjector
+	metadata     map[string]string
 	samplesCh    chan<- stats.SampleContainer
 }
 
@@ -32,6 +41,16 @@ func (t *Transport) SetOptions(options *lib.Options) {
 	t.options = options
 }
 
+// SetFaultInjector sets the faults that are injected into requests, if any.
+func (t *Transport) SetFaultInjector(faults *lib.FaultInjector) {
+	t.faults = faults
+}
+
+// SetMetadata sets the metadata that's added to the samples of requests, if any.
+func (t *Transport) SetMetadata(metadata map[string]string) {
+	t.metadata = metadata
+}
+
 func (t *Transport) GetTrail() *Trail {
 	return t.trail
 }
@@ -50,12 +69,54 @@ func (t *Transport) RoundTrip(req *http.Request) (res *http.Response, err error)
 		tags[k] = v
 	}
 
+	var ocspThisUpdate int64
+
+	var traceID, spanID string
+	if t.options.TraceContext.Bool && req.Header.Get("traceparent") == "" {
+		if t.traceID == "" {
+			t.traceID = NewTraceID()
+		}
+		traceID, spanID = t.traceID, NewSpanID()
+
+		// RoundTrippers mustn't modify the request they're given.
+		r := *req
+		r.Header = make(http.Header, len(req.Header)+1)
+		for k, v := range req.Header {
+			r.Header[k] = v
+		}
+		r.Header.Set("traceparent", TraceParent(traceID, spanID))
+		req = &r
+	}
+
 	ctx := req.Context()
 	tracer := Tracer{}
 	reqWithTracer := req.WithContext(WithTracer(ctx, &tracer))
 
-	resp, err := t.roundTripper.RoundTrip(reqWithTracer)
+	var faults []lib.Fault
+	if t.faults != nil {
+		faults = t.faults.Inject(tags, time.Now())
+	}
+	resp, delay, err := t.injectFaults(reqWithTracer, faults)
+	if resp == nil && err == nil {
+		resp, err = t.roundTripper.RoundTrip(reqWithTracer)
+	}
 	trail := tracer.Done()
+	trail.TraceID, trail.SpanID = traceID, spanID
+	if delay > 0 {
+		// Injected latency is counted as waiting for the server.
+		trail.Waiting += delay
+		trail.Duration += delay
+		trail.StartTime = trail.StartTime.Add(-delay)
+	}
+	if len(faults) > 0 {
+		tags["fault"] = lib.FaultKinds(faults)
+	}
+	if err == nil && resp.TLS != nil && t.options.RequireStapling.Bool {
+		if err = VerifyOCSPStaple(resp.TLS, trail.EndTime); err != nil {
+			_ = resp.Body.Close()
+			resp = nil
+		}
+	}
 	if err != nil {
 		if t.options.SystemTags["error"] {
 			tags["error"] = err.Error()
@@ -79,12 +140,19 @@ func (t *Transport) RoundTrip(req *http.Request) (res *http.Response, err error)
 
 		if resp.TLS != nil {
 			tlsInfo, oscp := ParseTLSConnState(resp.TLS)
+			ocspThisUpdate = oscp.ThisUpdate
 			if t.options.SystemTags["tls_version"] {
 				tags["tls_version"] = tlsInfo.Version
 			}
 			if t.options.SystemTags["ocsp_status"] {
 				tags["ocsp_status"] = oscp.Status
 			}
+			if t.options.SystemTags["tls_resumed"] {
+				tags["tls_resumed"] = strconv.FormatBool(tlsInfo.Resumed)
+			}
+			if t.options.SystemTags["tls_group"] && tlsInfo.Group != "" {
+				tags["tls_group"] = tlsInfo.Group
+			}
 
 			t.tlsInfo = tlsInfo
 		}
@@ -96,8 +164,93 @@ func (t *Transport) RoundTrip(req *http.Request) (res *http.Response, err error)
 	}
 
 	t.trail = trail
+	trail.Metadata = t.metadata
 	trail.SaveSamples(stats.IntoSampleTags(&tags))
 	stats.PushIfNotCancelled(ctx, t.samplesCh, trail)
 
+	// A TLS handshake was only made if the request opened a new connection.
+	if err == nil && resp.TLS != nil && !trail.ConnReused {
+		resumed := 0.0
+		if resp.TLS.DidResume {
+			resumed = 1
+		}
+		stats.PushIfNotCancelled(ctx, t.samplesCh, stats.Sample{
+			Metric: metrics.TLSHandshakesResumed,
+			Time:   trail.EndTime,
+			Tags:   trail.Tags,
+			Value:  resumed,
+		})
+	}
+
+	// The OCSP fields are only set if a stapled response could be parsed.
+	if ocspThisUpdate > 0 {
+		stats.PushIfNotCancelled(ctx, t.samplesCh, stats.Sample{
+			Metric: metrics.OCSPStapleAge,
+			Time:   trail.EndTime,
+			Tags:   trail.Tags,
+			Value:  stats.D(trail.EndTime.Sub(time.Unix(ocspThisUpdate, 0))),
+		})
+	}
+
 	return resp, err
 }
+
+// injectFaults delays a request for the latency faults, and then returns an error for an abort
+// fault or a response for an error fault, without sending it.
+func (t *Transport) injectFaults(req *http.Request, faults []lib.Fault) (*http.Response, time.Duration, error) {
+	var delay time.Duration
+	for _, f := range faults {
+		if f.Kind == lib.FaultLatency {
+			delay += f.Delay
+		}
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, 0, req.Context().Err()
+		}
+	}
+
+	for _, f := range faults {
+		switch f.Kind {
+		case lib.FaultAbort:
+			return nil, delay, errors.New("the request was aborted by an injected fault")
+		case lib.FaultError:
+			return &http.Response{
+				Status:     strconv.Itoa(f.Status) + " " + http.StatusText(f.Status),
+				StatusCode: f.Status,
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, delay, nil
+		}
+	}
+	return nil, delay, nil
+}
+
+// NewTraceID returns a random W3C trace ID.
+func NewTraceID() string {
+	return randomHex(16)
+}
+
+// NewSpanID returns a random W3C span ID.
+func NewSpanID() string {
+	return randomHex(8)
+}
+
+// TraceParent returns the value of the traceparent header of a sampled W3C trace context.
+func TraceParent(traceID, spanID string) string {
+	return "00-" + traceID + "-" + spanID + "-01"
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
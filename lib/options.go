@@ -22,10 +22,13 @@ package lib
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net"
 	"reflect"
+	"strings"
 
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/stats"
@@ -34,11 +37,16 @@ import (
 )
 
 // DefaultSystemTagList includes all of the system tags emitted with metrics by default.
-// Other tags that are not enabled by default include: iter, vu, ocsp_status, ip
+// Other tags that are not enabled by default include: iter, vu, ocsp_status, ip, tls_resumed,
+// tls_group, run_id
 var DefaultSystemTagList = []string{
 	"proto", "subproto", "status", "method", "url", "name", "group", "check", "error", "tls_version",
 }
 
+// DefaultHARSanitize lists the headers whose values are redacted by default in the
+// HAR file recorded with the harOutput option.
+var DefaultHARSanitize = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
 // TagSet is a string to bool map (for lookup efficiency) that is used to keep track
 // which system tags should be included with with metrics.
 type TagSet map[string]bool
@@ -145,6 +153,215 @@ func (s *TLSCipherSuites) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// A list of TLS key exchange groups (named curves), in order of preference.
+// Marshals and unmarshals from a list of names, eg. "X25519".
+type TLSGroups []tls.CurveID
+
+func (g TLSGroups) MarshalJSON() ([]byte, error) {
+	if g == nil {
+		return []byte("null"), nil
+	}
+	names := make([]string, len(g))
+	for i, id := range g {
+		names[i] = SupportedTLSGroupsToString[id]
+	}
+	return json.Marshal(names)
+}
+
+func (g *TLSGroups) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	if names == nil {
+		*g = nil
+		return nil
+	}
+	return g.set(names)
+}
+
+// Decode parses a comma-separated list of group names, eg. "X25519,P256", for K6_TLS_GROUPS.
+func (g *TLSGroups) Decode(value string) error {
+	if value == "" {
+		*g = nil
+		return nil
+	}
+	names := strings.Split(value, ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+	}
+	return g.set(names)
+}
+
+func (g *TLSGroups) set(names []string) error {
+	ids := make([]tls.CurveID, len(names))
+	for i, name := range names {
+		id, ok := SupportedTLSGroups[name]
+		if !ok {
+			return errors.Errorf("unknown TLS group: %s", name)
+		}
+		ids[i] = id
+	}
+	*g = ids
+	return nil
+}
+
+// An ECHConfigList for Encrypted Client Hello, as published in the "ech" parameter of a DNS
+// HTTPS record. Marshals and unmarshals from a base64 string.
+type TLSECHConfigList []byte
+
+func (l TLSECHConfigList) MarshalText() ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(l)), nil
+}
+
+func (l *TLSECHConfigList) UnmarshalText(data []byte) error {
+	if len(data) == 0 {
+		*l = nil
+		return nil
+	}
+	list, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return errors.Wrap(err, "tlsECHConfigList must be base64-encoded")
+	}
+	*l = list
+	return nil
+}
+
+// A list of PEM-encoded CA certificates to trust, on top of the system's root CAs. Each entry may
+// contain more than one certificate.
+type TLSRootCAs []string
+
+func (cas *TLSRootCAs) UnmarshalJSON(data []byte) error {
+	var certs []string
+	if err := json.Unmarshal(data, &certs); err != nil {
+		return err
+	}
+	for i, cert := range certs {
+		if !x509.NewCertPool().AppendCertsFromPEM([]byte(cert)) {
+			return errors.Errorf("tlsRootCAs[%d] doesn't contain any valid PEM-encoded certificates", i)
+		}
+	}
+	*cas = certs
+	return nil
+}
+
+// CertPool returns a pool of the system's root CAs, with the listed ones added to it.
+func (cas TLSRootCAs) CertPool() (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		// The system pool isn't available on all platforms (eg. Windows).
+		pool = x509.NewCertPool()
+	}
+	for i, cert := range cas {
+		if !pool.AppendCertsFromPEM([]byte(cert)) {
+			return nil, errors.Errorf("tlsRootCAs[%d] doesn't contain any valid PEM-encoded certificates", i)
+		}
+	}
+	return pool, nil
+}
+
+// Values for the tlsSessionCache option.
+const (
+	// TLSSessionCacheNone disables TLS session resumption; every new connection does a full
+	// handshake. This is the default.
+	TLSSessionCacheNone = "none"
+	// TLSSessionCacheVU gives each VU its own session cache, so VUs only resume sessions they
+	// have established themselves.
+	TLSSessionCacheVU = "vu"
+	// TLSSessionCacheShared shares a single session cache between all VUs.
+	TLSSessionCacheShared = "shared"
+)
+
+// ValidateTLSSessionCache returns an error if the given value isn't a valid tlsSessionCache mode.
+func ValidateTLSSessionCache(mode string) error {
+	switch mode {
+	case "", TLSSessionCacheNone, TLSSessionCacheVU, TLSSessionCacheShared:
+		return nil
+	default:
+		return errors.Errorf("invalid TLS session cache mode '%s', use: '%s', '%s' or '%s'",
+			mode, TLSSessionCacheNone, TLSSessionCacheVU, TLSSessionCacheShared)
+	}
+}
+
+// Values for the connectionReuse option.
+const (
+	// ConnectionReuseVU makes each VU keep its own connections open across iterations. This is
+	// the default.
+	ConnectionReuseVU = "vu"
+	// ConnectionReuseIteration closes a VU's connections at the end of every iteration, so that
+	// each iteration opens new ones.
+	ConnectionReuseIteration = "iteration"
+	// ConnectionReuseNone opens a new connection for every request.
+	ConnectionReuseNone = "none"
+	// ConnectionReuseShared makes all VUs share a single pool of connections.
+	ConnectionReuseShared = "shared"
+)
+
+// ValidateConnectionReuse returns an error if the given value isn't a valid connectionReuse mode.
+func ValidateConnectionReuse(mode string) error {
+	switch mode {
+	case "", ConnectionReuseVU, ConnectionReuseIteration, ConnectionReuseNone, ConnectionReuseShared:
+		return nil
+	default:
+		return errors.Errorf("invalid connection reuse mode '%s', use: '%s', '%s', '%s' or '%s'",
+			mode, ConnectionReuseVU, ConnectionReuseIteration, ConnectionReuseNone, ConnectionReuseShared)
+	}
+}
+
+// Values for the cookieJar option.
+const (
+	// CookieJarIteration gives each VU an empty cookie jar at the start of every iteration. This
+	// is the default.
+	CookieJarIteration = "iteration"
+	// CookieJarVU makes each VU keep its cookies across iterations.
+	CookieJarVU = "vu"
+	// CookieJarShared makes all VUs share a single cookie jar.
+	CookieJarShared = "shared"
+)
+
+// ValidateCookieJar returns an error if the given value isn't a valid cookieJar mode.
+func ValidateCookieJar(mode string) error {
+	switch mode {
+	case "", CookieJarIteration, CookieJarVU, CookieJarShared:
+		return nil
+	default:
+		return errors.Errorf("invalid cookie jar mode '%s', use: '%s', '%s' or '%s'",
+			mode, CookieJarIteration, CookieJarVU, CookieJarShared)
+	}
+}
+
+// Values for the httpCache option.
+const (
+	// HTTPCacheNone doesn't cache responses. This is the default.
+	HTTPCacheNone = "none"
+	// HTTPCacheIteration gives each VU an empty HTTP cache at the start of every iteration, like
+	// a new visitor would have.
+	HTTPCacheIteration = "iteration"
+	// HTTPCacheVU makes each VU keep its HTTP cache across iterations, like a returning visitor.
+	HTTPCacheVU = "vu"
+)
+
+// ValidateHTTPCache returns an error if the given value isn't a valid httpCache mode.
+func ValidateHTTPCache(mode string) error {
+	switch mode {
+	case "", HTTPCacheNone, HTTPCacheIteration, HTTPCacheVU:
+		return nil
+	default:
+		return errors.Errorf("invalid HTTP cache mode '%s', use: '%s', '%s' or '%s'",
+			mode, HTTPCacheNone, HTTPCacheIteration, HTTPCacheVU)
+	}
+}
+
+// ValidateConsoleLevel returns an error if the given value isn't a valid consoleLevel.
+func ValidateConsoleLevel(level string) error {
+	switch level {
+	case "", "debug", "info", "warn", "error":
+		return nil
+	default:
+		return errors.Errorf("invalid console level '%s', use: 'debug', 'info', 'warn' or 'error'", level)
+	}
+}
+
 // Fields for TLSAuth. Unmarshalling hack.
 type TLSAuthFields struct {
 	// Certificate and key as a PEM-encoded string, including "-----BEGIN CERTIFICATE-----".
@@ -201,6 +418,20 @@ type Options struct {
 	// Limit HTTP requests per second.
 	RPS null.Int `json:"rps" envconfig:"rps"`
 
+	// Start iterations at a rate that's adjusted to hold a latency and error rate SLO.
+	AdaptiveRate *AdaptiveRate `json:"adaptiveRate" envconfig:"adaptive_rate"`
+
+	// Shed iterations or stop the test when the load generator uses too much memory or too many
+	// connections.
+	ResourceLimits *ResourceLimits `json:"resourceLimits" envconfig:"resource_limits"`
+
+	// Fail iterations or stop the test when a VU keeps too much memory, opens too many connections
+	// and files, or uses too much CPU time in an iteration.
+	VUQuotas *VUQuotas `json:"vuQuotas" envconfig:"vu_quotas"`
+
+	// The part of a distributed test this instance runs, which data is partitioned by.
+	ExecutionSegment *ExecutionSegment `json:"executionSegment" envconfig:"execution_segment"`
+
 	// How many HTTP redirects do we follow?
 	MaxRedirects null.Int `json:"maxRedirects" envconfig:"max_redirects"`
 
@@ -222,6 +453,27 @@ type Options struct {
 	TLSVersion      *TLSVersions     `json:"tlsVersion" envconfig:"tls_version"`
 	TLSAuth         []*TLSAuth       `json:"tlsAuth" envconfig:"tlsauth"`
 
+	// Key exchange groups to offer, in order of preference; eg. "X25519MLKEM768" for a
+	// post-quantum hybrid, where the Go version k6 is built with supports it.
+	TLSGroups TLSGroups `json:"tlsGroups" envconfig:"tls_groups"`
+
+	// A pool of client certificates to hand out to VUs. Each VU is assigned one of them based
+	// on its ID, wrapping around if there are more VUs than certificates, and presents it on
+	// every connection for as long as it keeps that ID.
+	TLSAuthPool []*TLSAuth `json:"tlsAuthPool" envconfig:"tls_auth_pool"`
+
+	// Fail requests to servers that don't staple a valid OCSP response to the TLS handshake.
+	RequireStapling null.Bool `json:"requireStapling" envconfig:"require_stapling"`
+
+	// Encrypt the ClientHello with this ECH configuration, hiding the server name from observers.
+	TLSECHConfigList TLSECHConfigList `json:"tlsECHConfigList" envconfig:"tls_ech_config_list"`
+
+	// Additional CA certificates to trust when verifying servers.
+	TLSRootCAs TLSRootCAs `json:"tlsRootCAs" envconfig:"tls_root_cas"`
+
+	// How TLS sessions are cached for resumption: "none", "vu" or "shared".
+	TLSSessionCache null.String `json:"tlsSessionCache" envconfig:"tls_session_cache"`
+
 	// Throw warnings (eg. failed HTTP requests) as errors instead of simply logging them.
 	Throw null.Bool `json:"throw" envconfig:"throw"`
 
@@ -230,12 +482,26 @@ type Options struct {
 	// metric on a nonexistent metric named 'real_metric{tagA:valueA,tagB:valueB}'.
 	Thresholds map[string]stats.Thresholds `json:"thresholds" envconfig:"thresholds"`
 
+	// Define thresholds that combine several metrics, eg. 'name=["a{tag:x} / b{tag:x} < 0.01"]'.
+	// Each one shows up as its own entry in the end-of-test summary.
+	ThresholdExpressions map[string]stats.ThresholdExpressions `json:"thresholdExpressions" envconfig:"threshold_expressions"`
+
+	// Load the values of a previous run from this file, for thresholds to refer to as "baseline".
+	Baseline null.String `json:"baseline" envconfig:"baseline"`
+
+	// Write the values of this run to this file at the end, as a candidate for a new baseline.
+	BaselineOutput null.String `json:"baselineOutput" envconfig:"baseline_output"`
+
 	// Blacklist IP ranges that tests may not contact. Mainly useful in hosted setups.
 	BlacklistIPs []*net.IPNet `json:"blacklistIPs" envconfig:"blacklist_ips"`
 
 	// Hosts overrides dns entries for given hosts
 	Hosts map[string]net.IP `json:"hosts" envconfig:"hosts"`
 
+	// How connections are reused: "vu", "iteration", "none" or "shared". Takes precedence over
+	// noConnectionReuse and noVUConnectionReuse, which are the same as "none" and "iteration".
+	ConnectionReuse null.String `json:"connectionReuse" envconfig:"connection_reuse"`
+
 	// Disable keep-alive connections
 	NoConnectionReuse null.Bool `json:"noConnectionReuse" envconfig:"no_connection_reuse"`
 
@@ -248,6 +514,10 @@ type Options struct {
 	// iteration is shorter than the specified value.
 	MinIterationDuration types.NullDuration `json:"minIterationDuration" envconfig:"min_iteration_duration"`
 
+	// Run this many iterations in every VU before its first measured one, and discard all of
+	// their samples, so that warming up doesn't skew the metrics and thresholds.
+	WarmupIterations null.Int `json:"warmupIterations" envconfig:"warmup_iterations"`
+
 	// These values are for third party collectors' benefit.
 	// Can't be set through env vars.
 	External map[string]json.RawMessage `json:"ext" ignored:"true"`
@@ -261,20 +531,93 @@ type Options struct {
 	// Which system tags to include with metrics ("method", "vu" etc.)
 	SystemTags TagSet `json:"systemTags" envconfig:"system_tags"`
 
+	// Propagate a W3C trace context in every HTTP request, so tracing outputs can emit a span
+	// for it that backend traces are correlated with.
+	TraceContext null.Bool `json:"traceContext" envconfig:"trace_context"`
+
+	// Derive the name tag of HTTP requests from their URLs by collapsing IDs and applying rules.
+	URLGrouping *URLGrouping `json:"urlGrouping" envconfig:"url_grouping"`
+
 	// Tags to be applied to all samples for this running
 	RunTags *stats.SampleTags `json:"tags" envconfig:"tags"`
 
+	// Identifies the test run in the run_id tag, to correlate all of its metrics, eg. the ones of
+	// browser pages and HTTP requests, or of the instances of a distributed test; a random one is
+	// used if it's not set
+	RunID null.String `json:"runID" envconfig:"run_id"`
+
+	// Metadata about the test run, eg. who ran it or for which release, that's added to the tags
+	// of all samples and shown in the summary
+	Metadata map[string]string `json:"metadata" envconfig:"metadata"`
+
+	// Don't add the git commit, branch and dirty state of the script to the metadata
+	NoGitMetadata null.Bool `json:"noGitMetadata" envconfig:"no_git_metadata"`
+
 	// Buffer size of the channel for metric samples; 0 means unbuffered
 	MetricSamplesBufferSize null.Int `json:"metricSamplesBufferSize" envconfig:"metric_samples_buffer_size"`
 
+	// Drop or pre-aggregate samples before they're passed to outputs.
+	OutputAggregation *OutputAggregation `json:"outputAggregation" envconfig:"output_aggregation"`
+
 	// Do not reset cookies after a VU iteration
 	NoCookiesReset null.Bool `json:"noCookiesReset" envconfig:"no_cookies_reset"`
 
+	// Which cookie jar VUs use: "iteration", "vu" or "shared". Takes precedence over
+	// noCookiesReset, which is the same as "vu".
+	CookieJar null.String `json:"cookieJar" envconfig:"cookie_jar"`
+
+	// Whether VUs cache HTTP responses like a browser would: "none", "iteration" or "vu".
+	HTTPCache null.String `json:"httpCache" envconfig:"http_cache"`
+
+	// Automatically retry failed HTTP requests; can be overridden per request.
+	HTTPRetry *HTTPRetry `json:"httpRetry" envconfig:"http_retry"`
+
+	// Stop sending requests to endpoints that fail too often, skipping the iterations that would.
+	CircuitBreaker *CircuitBreaker `json:"circuitBreaker" envconfig:"circuit_breaker"`
+
 	// Discard Http Responses Body
 	DiscardResponseBodies null.Bool `json:"discardResponseBodies" envconfig:"discard_response_bodies"`
 
-	// Redirect console logging to a file
+	// Record all HTTP requests and responses made during the test into this HAR file
+	HAROutput null.String `json:"harOutput" envconfig:"har_output"`
+
+	// Names of the headers, cookies and query parameters whose values are redacted in the
+	// recorded HAR file; if not set, DefaultHARSanitize is used
+	HARSanitize []string `json:"harSanitize" envconfig:"har_sanitize"`
+
+	// The directory the k6/fs module reads and writes files in; it can't be used without one
+	ArtifactsDir null.String `json:"artifactsDir" envconfig:"artifacts_dir"`
+
+	// Glob patterns, relative to the script, of files that are bundled in archives along with
+	// the ones the script open()s, for scripts that open files based on eg. environment variables
+	Assets []string `json:"assets" envconfig:"assets"`
+
+	// Restrict the built-in modules scripts may import, eg. "k6/http", or "k6/x/*" for all of the
+	// extensions' ones; a module has to be allowed, if any are, and not blocked
+	AllowedModules []string `json:"allowedModules" envconfig:"allowed_modules"`
+	BlockedModules []string `json:"blockedModules" envconfig:"blocked_modules"`
+
+	// Restrict the hosts tests may connect to, by name, eg. "*.example.com", IP or CIDR range; a
+	// host has to be allowed, if any are, and not blocked. Mainly useful in hosted setups.
+	AllowedHosts []string `json:"allowedHosts" envconfig:"allowed_hosts"`
+	BlockedHosts []string `json:"blockedHosts" envconfig:"blocked_hosts"`
+
+	// The Chrome or Chromium executable the k6/browser module runs; it's looked up in the PATH
+	// if it's not set
+	BrowserExecutable null.String `json:"browserExecutable" envconfig:"browser_executable"`
+
+	// How many browser sessions may be open at once, across all VUs; pages wait for a free one
+	BrowserSessions null.Int `json:"browserSessions" envconfig:"browser_sessions"`
+
+	// Redirect console logging to a file, or push it to Loki with "loki=<url>"
 	ConsoleOutput null.String `json:"-" envconfig:"console_output"`
+
+	// The least severe level of console messages that are logged: "debug", "info", "warn" or
+	// "error"
+	ConsoleLevel null.String `json:"consoleLevel" envconfig:"console_level"`
+
+	// The most console messages that are logged per second, by all VUs together
+	ConsoleRateLimit null.Int `json:"consoleRateLimit" envconfig:"console_rate_limit"`
 }
 
 // Returns the result of overwriting any fields with any that are set on the argument.
@@ -340,21 +683,51 @@ func (o Options) Apply(opts Options) Options {
 	if opts.TLSVersion != nil {
 		o.TLSVersion = opts.TLSVersion
 	}
+	if opts.TLSGroups != nil {
+		o.TLSGroups = opts.TLSGroups
+	}
 	if opts.TLSAuth != nil {
 		o.TLSAuth = opts.TLSAuth
 	}
+	if opts.TLSAuthPool != nil {
+		o.TLSAuthPool = opts.TLSAuthPool
+	}
+	if opts.RequireStapling.Valid {
+		o.RequireStapling = opts.RequireStapling
+	}
+	if opts.TLSECHConfigList != nil {
+		o.TLSECHConfigList = opts.TLSECHConfigList
+	}
+	if opts.TLSRootCAs != nil {
+		o.TLSRootCAs = opts.TLSRootCAs
+	}
+	if opts.TLSSessionCache.Valid {
+		o.TLSSessionCache = opts.TLSSessionCache
+	}
 	if opts.Throw.Valid {
 		o.Throw = opts.Throw
 	}
 	if opts.Thresholds != nil {
 		o.Thresholds = opts.Thresholds
 	}
+	if opts.Baseline.Valid {
+		o.Baseline = opts.Baseline
+	}
+	if opts.BaselineOutput.Valid {
+		o.BaselineOutput = opts.BaselineOutput
+	}
+	if opts.ThresholdExpressions != nil {
+		o.ThresholdExpressions = opts.ThresholdExpressions
+	}
 	if opts.BlacklistIPs != nil {
 		o.BlacklistIPs = opts.BlacklistIPs
 	}
 	if opts.Hosts != nil {
 		o.Hosts = opts.Hosts
 	}
+	if opts.ConnectionReuse.Valid {
+		o.ConnectionReuse = opts.ConnectionReuse
+	}
 	if opts.NoConnectionReuse.Valid {
 		o.NoConnectionReuse = opts.NoConnectionReuse
 	}
@@ -364,9 +737,37 @@ func (o Options) Apply(opts Options) Options {
 	if opts.MinIterationDuration.Valid {
 		o.MinIterationDuration = opts.MinIterationDuration
 	}
+	if opts.WarmupIterations.Valid {
+		o.WarmupIterations = opts.WarmupIterations
+	}
+	// envconfig allocates nil struct pointers, so an empty AdaptiveRate is the same as no value
+	if opts.AdaptiveRate != nil && *opts.AdaptiveRate != (AdaptiveRate{}) {
+		o.AdaptiveRate = opts.AdaptiveRate
+	}
+	if opts.ResourceLimits != nil && *opts.ResourceLimits != (ResourceLimits{}) {
+		o.ResourceLimits = opts.ResourceLimits
+	}
+	if opts.VUQuotas != nil && *opts.VUQuotas != (VUQuotas{}) {
+		o.VUQuotas = opts.VUQuotas
+	}
+	if opts.ExecutionSegment != nil && *opts.ExecutionSegment != (ExecutionSegment{}) {
+		o.ExecutionSegment = opts.ExecutionSegment
+	}
 	if opts.NoCookiesReset.Valid {
 		o.NoCookiesReset = opts.NoCookiesReset
 	}
+	if opts.CookieJar.Valid {
+		o.CookieJar = opts.CookieJar
+	}
+	if opts.HTTPCache.Valid {
+		o.HTTPCache = opts.HTTPCache
+	}
+	if opts.HTTPRetry != nil && opts.HTTPRetry.IsSet() {
+		o.HTTPRetry = opts.HTTPRetry
+	}
+	if opts.CircuitBreaker != nil && opts.CircuitBreaker.IsSet() {
+		o.CircuitBreaker = opts.CircuitBreaker
+	}
 	if opts.External != nil {
 		o.External = opts.External
 	}
@@ -379,22 +780,104 @@ func (o Options) Apply(opts Options) Options {
 	if opts.SystemTags != nil {
 		o.SystemTags = opts.SystemTags
 	}
+	if opts.TraceContext.Valid {
+		o.TraceContext = opts.TraceContext
+	}
+	if opts.URLGrouping != nil && opts.URLGrouping.IsSet() {
+		o.URLGrouping = opts.URLGrouping
+	}
 	if !opts.RunTags.IsEmpty() {
 		o.RunTags = opts.RunTags
 	}
+	if opts.RunID.Valid {
+		o.RunID = opts.RunID
+	}
+	if opts.Metadata != nil {
+		o.Metadata = opts.Metadata
+	}
+	if opts.NoGitMetadata.Valid {
+		o.NoGitMetadata = opts.NoGitMetadata
+	}
 	if opts.MetricSamplesBufferSize.Valid {
 		o.MetricSamplesBufferSize = opts.MetricSamplesBufferSize
 	}
+	if opts.OutputAggregation != nil && opts.OutputAggregation.IsSet() {
+		o.OutputAggregation = opts.OutputAggregation
+	}
 	if opts.DiscardResponseBodies.Valid {
 		o.DiscardResponseBodies = opts.DiscardResponseBodies
 	}
+	if opts.HAROutput.Valid {
+		o.HAROutput = opts.HAROutput
+	}
+	if opts.ArtifactsDir.Valid {
+		o.ArtifactsDir = opts.ArtifactsDir
+	}
+	if opts.HARSanitize != nil {
+		o.HARSanitize = opts.HARSanitize
+	}
+	if opts.Assets != nil {
+		o.Assets = opts.Assets
+	}
+	if opts.AllowedModules != nil {
+		o.AllowedModules = opts.AllowedModules
+	}
+	if opts.BlockedModules != nil {
+		o.BlockedModules = opts.BlockedModules
+	}
+	if opts.AllowedHosts != nil {
+		o.AllowedHosts = opts.AllowedHosts
+	}
+	if opts.BlockedHosts != nil {
+		o.BlockedHosts = opts.BlockedHosts
+	}
+	if opts.BrowserExecutable.Valid {
+		o.BrowserExecutable = opts.BrowserExecutable
+	}
+	if opts.BrowserSessions.Valid {
+		o.BrowserSessions = opts.BrowserSessions
+	}
 	if opts.ConsoleOutput.Valid {
 		o.ConsoleOutput = opts.ConsoleOutput
 	}
+	if opts.ConsoleLevel.Valid {
+		o.ConsoleLevel = opts.ConsoleLevel
+	}
+	if opts.ConsoleRateLimit.Valid {
+		o.ConsoleRateLimit = opts.ConsoleRateLimit
+	}
 
 	return o
 }
 
+// GetConnectionReuse returns the connection reuse mode, falling back to the one implied by the
+// older noConnectionReuse and noVUConnectionReuse options.
+func (o Options) GetConnectionReuse() string {
+	switch {
+	case o.ConnectionReuse.Valid && o.ConnectionReuse.String != "":
+		return o.ConnectionReuse.String
+	case o.NoConnectionReuse.Bool:
+		return ConnectionReuseNone
+	case o.NoVUConnectionReuse.Bool:
+		return ConnectionReuseIteration
+	default:
+		return ConnectionReuseVU
+	}
+}
+
+// GetCookieJar returns the cookie jar mode, falling back to the one implied by the older
+// noCookiesReset option.
+func (o Options) GetCookieJar() string {
+	switch {
+	case o.CookieJar.Valid && o.CookieJar.String != "":
+		return o.CookieJar.String
+	case o.NoCookiesReset.Bool:
+		return CookieJarVU
+	default:
+		return CookieJarIteration
+	}
+}
+
 // ForEachValid enumerates all struct fields and calls the supplied function with each
 // element that is valid. It panics for any unfamiliar or unexpected fields, so make sure
 // new fields in Options are accounted for.
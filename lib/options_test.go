@@ -278,6 +278,124 @@ func TestOptions(t *testing.T) {
 			assert.Error(t, json.Unmarshal([]byte(jsonStr), &opts))
 		})
 	})
+	t.Run("TLSAuthPool", func(t *testing.T) {
+		tlsAuthPool := []*TLSAuth{{}, {}}
+		opts := Options{}.Apply(Options{TLSAuthPool: tlsAuthPool})
+		assert.Equal(t, tlsAuthPool, opts.TLSAuthPool)
+
+		t.Run("Certificate error", func(t *testing.T) {
+			var opts Options
+			jsonStr := `{"tlsAuthPool":[{"cert":"","key":""}]}`
+			assert.Error(t, json.Unmarshal([]byte(jsonStr), &opts))
+		})
+	})
+	t.Run("TLSGroups", func(t *testing.T) {
+		groups := TLSGroups{tls.X25519, tls.CurveP256}
+		opts := Options{}.Apply(Options{TLSGroups: groups})
+		assert.Equal(t, groups, opts.TLSGroups)
+
+		t.Run("JSON", func(t *testing.T) {
+			t.Run("Roundtrip", func(t *testing.T) {
+				data, err := json.Marshal(opts.TLSGroups)
+				assert.NoError(t, err)
+				assert.JSONEq(t, `["X25519","P256"]`, string(data))
+
+				var groups2 TLSGroups
+				assert.NoError(t, json.Unmarshal(data, &groups2))
+				assert.Equal(t, groups, groups2)
+			})
+			t.Run("Unknown group", func(t *testing.T) {
+				var opts Options
+				jsonStr := `{"tlsGroups":["P224"]}`
+				assert.EqualError(t, json.Unmarshal([]byte(jsonStr), &opts), "unknown TLS group: P224")
+			})
+		})
+	})
+	t.Run("TLSECHConfigList", func(t *testing.T) {
+		opts := Options{}.Apply(Options{TLSECHConfigList: TLSECHConfigList{1, 2, 3}})
+		assert.Equal(t, TLSECHConfigList{1, 2, 3}, opts.TLSECHConfigList)
+
+		t.Run("JSON", func(t *testing.T) {
+			var opts Options
+			assert.NoError(t, json.Unmarshal([]byte(`{"tlsECHConfigList":"AQID"}`), &opts))
+			assert.Equal(t, TLSECHConfigList{1, 2, 3}, opts.TLSECHConfigList)
+
+			data, err := json.Marshal(opts.TLSECHConfigList)
+			assert.NoError(t, err)
+			assert.Equal(t, `"AQID"`, string(data))
+		})
+		t.Run("Invalid", func(t *testing.T) {
+			var opts Options
+			assert.Error(t, json.Unmarshal([]byte(`{"tlsECHConfigList":"not base64!"}`), &opts))
+		})
+	})
+	t.Run("RequireStapling", func(t *testing.T) {
+		opts := Options{}.Apply(Options{RequireStapling: null.BoolFrom(true)})
+		assert.True(t, opts.RequireStapling.Valid)
+		assert.True(t, opts.RequireStapling.Bool)
+	})
+	t.Run("TLSRootCAs", func(t *testing.T) {
+		ca := "-----BEGIN CERTIFICATE-----\n" +
+			"MIIBYzCCAQqgAwIBAgIUMYw1pqZ1XhXdFG0S2ITXhfHBsWgwCgYIKoZIzj0EAwIw\n" +
+			"EDEOMAwGA1UEAxMFTXkgQ0EwHhcNMTcwODE1MTYxODAwWhcNMjIwODE0MTYxODAw\n" +
+			"WjAQMQ4wDAYDVQQDEwVNeSBDQTBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABFWO\n" +
+			"fg4dgL8cdvjoSWDQFLBJxlbQFlZfOSyUR277a4g91BD07KWX+9ny+Q8WuUODog06\n" +
+			"xH1g8fc6zuaejllfzM6jQjBAMA4GA1UdDwEB/wQEAwIBBjAPBgNVHRMBAf8EBTAD\n" +
+			"AQH/MB0GA1UdDgQWBBTeoSFylGCmyqj1X4sWez1r6hkhjDAKBggqhkjOPQQDAgNH\n" +
+			"ADBEAiAfuKi6u/BVXenCkgnU2sfXsYjel6rACuXEcx01yaaWuQIgXAtjrDisdlf4\n" +
+			"0ZdoIoYjNhDAXUtnyRBt+V6+rIklv/8=\n" +
+			"-----END CERTIFICATE-----"
+		opts := Options{}.Apply(Options{TLSRootCAs: TLSRootCAs{ca}})
+		assert.Equal(t, TLSRootCAs{ca}, opts.TLSRootCAs)
+
+		pool, err := opts.TLSRootCAs.CertPool()
+		assert.NoError(t, err)
+		assert.NotNil(t, pool)
+
+		t.Run("Roundtrip", func(t *testing.T) {
+			optsData, err := json.Marshal(opts)
+			assert.NoError(t, err)
+
+			var opts2 Options
+			assert.NoError(t, json.Unmarshal(optsData, &opts2))
+			assert.Equal(t, opts.TLSRootCAs, opts2.TLSRootCAs)
+		})
+
+		t.Run("Invalid", func(t *testing.T) {
+			var opts Options
+			jsonStr := `{"tlsRootCAs":["not a certificate"]}`
+			assert.EqualError(t, json.Unmarshal([]byte(jsonStr), &opts),
+				"tlsRootCAs[0] doesn't contain any valid PEM-encoded certificates")
+		})
+	})
+	t.Run("TLSSessionCache", func(t *testing.T) {
+		opts := Options{}.Apply(Options{TLSSessionCache: null.StringFrom(TLSSessionCacheShared)})
+		assert.True(t, opts.TLSSessionCache.Valid)
+		assert.Equal(t, "shared", opts.TLSSessionCache.String)
+
+		for _, mode := range []string{"", "none", "vu", "shared"} {
+			assert.NoError(t, ValidateTLSSessionCache(mode), mode)
+		}
+		assert.Error(t, ValidateTLSSessionCache("global"))
+	})
+	t.Run("ConnectionReuse", func(t *testing.T) {
+		opts := Options{}.Apply(Options{ConnectionReuse: null.StringFrom(ConnectionReuseShared)})
+		assert.True(t, opts.ConnectionReuse.Valid)
+		assert.Equal(t, "shared", opts.ConnectionReuse.String)
+
+		for _, mode := range []string{"", "vu", "iteration", "none", "shared"} {
+			assert.NoError(t, ValidateConnectionReuse(mode), mode)
+		}
+		assert.Error(t, ValidateConnectionReuse("global"))
+
+		assert.Equal(t, ConnectionReuseVU, Options{}.GetConnectionReuse())
+		assert.Equal(t, ConnectionReuseNone, Options{NoConnectionReuse: null.BoolFrom(true)}.GetConnectionReuse())
+		assert.Equal(t, ConnectionReuseIteration, Options{NoVUConnectionReuse: null.BoolFrom(true)}.GetConnectionReuse())
+		assert.Equal(t, ConnectionReuseShared, Options{
+			ConnectionReuse:   null.StringFrom(ConnectionReuseShared),
+			NoConnectionReuse: null.BoolFrom(true),
+		}.GetConnectionReuse())
+	})
 	t.Run("NoConnectionReuse", func(t *testing.T) {
 		opts := Options{}.Apply(Options{NoConnectionReuse: null.BoolFrom(true)})
 		assert.True(t, opts.NoConnectionReuse.Valid)
@@ -288,6 +406,48 @@ func TestOptions(t *testing.T) {
 		assert.True(t, opts.NoVUConnectionReuse.Valid)
 		assert.True(t, opts.NoVUConnectionReuse.Bool)
 	})
+	t.Run("CookieJar", func(t *testing.T) {
+		opts := Options{}.Apply(Options{CookieJar: null.StringFrom(CookieJarShared)})
+		assert.True(t, opts.CookieJar.Valid)
+		assert.Equal(t, "shared", opts.CookieJar.String)
+
+		for _, mode := range []string{"", "iteration", "vu", "shared"} {
+			assert.NoError(t, ValidateCookieJar(mode), mode)
+		}
+		assert.Error(t, ValidateCookieJar("global"))
+
+		assert.Equal(t, CookieJarIteration, Options{}.GetCookieJar())
+		assert.Equal(t, CookieJarVU, Options{NoCookiesReset: null.BoolFrom(true)}.GetCookieJar())
+		assert.Equal(t, CookieJarShared, Options{
+			CookieJar:      null.StringFrom(CookieJarShared),
+			NoCookiesReset: null.BoolFrom(true),
+		}.GetCookieJar())
+	})
+	t.Run("HTTPCache", func(t *testing.T) {
+		opts := Options{}.Apply(Options{HTTPCache: null.StringFrom(HTTPCacheVU)})
+		assert.True(t, opts.HTTPCache.Valid)
+		assert.Equal(t, "vu", opts.HTTPCache.String)
+
+		for _, mode := range []string{"", "none", "iteration", "vu"} {
+			assert.NoError(t, ValidateHTTPCache(mode), mode)
+		}
+		assert.Error(t, ValidateHTTPCache("shared"))
+	})
+	t.Run("ConsoleLevel", func(t *testing.T) {
+		opts := Options{}.Apply(Options{ConsoleLevel: null.StringFrom("warn")})
+		assert.True(t, opts.ConsoleLevel.Valid)
+		assert.Equal(t, "warn", opts.ConsoleLevel.String)
+
+		for _, level := range []string{"", "debug", "info", "warn", "error"} {
+			assert.NoError(t, ValidateConsoleLevel(level), level)
+		}
+		assert.Error(t, ValidateConsoleLevel("trace"))
+	})
+	t.Run("ConsoleRateLimit", func(t *testing.T) {
+		opts := Options{}.Apply(Options{ConsoleRateLimit: null.IntFrom(100)})
+		assert.True(t, opts.ConsoleRateLimit.Valid)
+		assert.Equal(t, int64(100), opts.ConsoleRateLimit.Int64)
+	})
 	t.Run("NoCookiesReset", func(t *testing.T) {
 		opts := Options{}.Apply(Options{NoCookiesReset: null.BoolFrom(true)})
 		assert.True(t, opts.NoCookiesReset.Valid)
@@ -330,6 +490,16 @@ func TestOptions(t *testing.T) {
 		assert.NotNil(t, opts.Thresholds)
 		assert.NotEmpty(t, opts.Thresholds)
 	})
+	t.Run("ThresholdExpressions", func(t *testing.T) {
+		opts := Options{}.Apply(Options{ThresholdExpressions: map[string]stats.ThresholdExpressions{
+			"ratio": {
+				Thresholds: stats.Thresholds{Thresholds: []*stats.Threshold{{}}},
+				Refs:       []stats.MetricRef{{Metric: "a"}, {Metric: "b"}},
+			},
+		}})
+		assert.NotNil(t, opts.ThresholdExpressions)
+		assert.NotEmpty(t, opts.ThresholdExpressions)
+	})
 	t.Run("External", func(t *testing.T) {
 		ext := map[string]json.RawMessage{"a": json.RawMessage("1")}
 		opts := Options{}.Apply(Options{External: ext})
@@ -383,11 +553,141 @@ func TestOptions(t *testing.T) {
 		opts := Options{}.Apply(Options{RunTags: tags})
 		assert.Equal(t, tags, opts.RunTags)
 	})
+	t.Run("RunID", func(t *testing.T) {
+		opts := Options{}.Apply(Options{RunID: null.StringFrom("8f2c1a")})
+		assert.Equal(t, null.StringFrom("8f2c1a"), opts.RunID)
+	})
+	t.Run("Metadata", func(t *testing.T) {
+		metadata := map[string]string{"release": "1.2.0"}
+		opts := Options{}.Apply(Options{Metadata: metadata})
+		assert.Equal(t, metadata, opts.Metadata)
+	})
+	t.Run("NoGitMetadata", func(t *testing.T) {
+		opts := Options{}.Apply(Options{NoGitMetadata: null.BoolFrom(true)})
+		assert.Equal(t, null.BoolFrom(true), opts.NoGitMetadata)
+	})
 	t.Run("DiscardResponseBodies", func(t *testing.T) {
 		opts := Options{}.Apply(Options{DiscardResponseBodies: null.BoolFrom(true)})
 		assert.True(t, opts.DiscardResponseBodies.Valid)
 		assert.True(t, opts.DiscardResponseBodies.Bool)
 	})
+	t.Run("HAROutput", func(t *testing.T) {
+		opts := Options{}.Apply(Options{HAROutput: null.StringFrom("out.har")})
+		assert.Equal(t, null.StringFrom("out.har"), opts.HAROutput)
+	})
+	t.Run("HARSanitize", func(t *testing.T) {
+		opts := Options{}.Apply(Options{HARSanitize: []string{"X-Api-Key"}})
+		assert.Equal(t, []string{"X-Api-Key"}, opts.HARSanitize)
+	})
+	t.Run("ArtifactsDir", func(t *testing.T) {
+		opts := Options{}.Apply(Options{ArtifactsDir: null.StringFrom("artifacts")})
+		assert.Equal(t, null.StringFrom("artifacts"), opts.ArtifactsDir)
+	})
+	t.Run("Assets", func(t *testing.T) {
+		opts := Options{}.Apply(Options{Assets: []string{"./certs/*.pem"}})
+		assert.Equal(t, []string{"./certs/*.pem"}, opts.Assets)
+	})
+	t.Run("AllowedModules", func(t *testing.T) {
+		opts := Options{}.Apply(Options{AllowedModules: []string{"k6/http", "k6/x/*"}})
+		assert.Equal(t, []string{"k6/http", "k6/x/*"}, opts.AllowedModules)
+	})
+	t.Run("BlockedModules", func(t *testing.T) {
+		opts := Options{}.Apply(Options{BlockedModules: []string{"k6/fs"}})
+		assert.Equal(t, []string{"k6/fs"}, opts.BlockedModules)
+	})
+	t.Run("AllowedHosts", func(t *testing.T) {
+		opts := Options{}.Apply(Options{AllowedHosts: []string{"*.example.com", "10.0.0.0/8"}})
+		assert.Equal(t, []string{"*.example.com", "10.0.0.0/8"}, opts.AllowedHosts)
+	})
+	t.Run("BlockedHosts", func(t *testing.T) {
+		opts := Options{}.Apply(Options{BlockedHosts: []string{"169.254.169.254"}})
+		assert.Equal(t, []string{"169.254.169.254"}, opts.BlockedHosts)
+	})
+	t.Run("BrowserExecutable", func(t *testing.T) {
+		opts := Options{}.Apply(Options{BrowserExecutable: null.StringFrom("/usr/bin/chromium")})
+		assert.Equal(t, null.StringFrom("/usr/bin/chromium"), opts.BrowserExecutable)
+	})
+	t.Run("BrowserSessions", func(t *testing.T) {
+		opts := Options{}.Apply(Options{BrowserSessions: null.IntFrom(4)})
+		assert.Equal(t, null.IntFrom(4), opts.BrowserSessions)
+	})
+	t.Run("WarmupIterations", func(t *testing.T) {
+		opts := Options{}.Apply(Options{WarmupIterations: null.IntFrom(5)})
+		assert.Equal(t, null.IntFrom(5), opts.WarmupIterations)
+	})
+	t.Run("AdaptiveRate", func(t *testing.T) {
+		rate := &AdaptiveRate{Target: types.NullDurationFrom(300 * time.Millisecond)}
+		opts := Options{}.Apply(Options{AdaptiveRate: rate})
+		assert.Equal(t, rate, opts.AdaptiveRate)
+
+		opts = opts.Apply(Options{AdaptiveRate: &AdaptiveRate{}})
+		assert.Equal(t, rate, opts.AdaptiveRate)
+	})
+	t.Run("ResourceLimits", func(t *testing.T) {
+		limits := &ResourceLimits{MaxMemory: null.StringFrom("2GB")}
+		opts := Options{}.Apply(Options{ResourceLimits: limits})
+		assert.Equal(t, limits, opts.ResourceLimits)
+
+		opts = opts.Apply(Options{ResourceLimits: &ResourceLimits{}})
+		assert.Equal(t, limits, opts.ResourceLimits)
+	})
+	t.Run("VUQuotas", func(t *testing.T) {
+		quotas := &VUQuotas{MaxHandles: null.IntFrom(100)}
+		opts := Options{}.Apply(Options{VUQuotas: quotas})
+		assert.Equal(t, quotas, opts.VUQuotas)
+
+		opts = opts.Apply(Options{VUQuotas: &VUQuotas{}})
+		assert.Equal(t, quotas, opts.VUQuotas)
+	})
+	t.Run("ExecutionSegment", func(t *testing.T) {
+		seg := &ExecutionSegment{Index: 1, Count: 2}
+		opts := Options{}.Apply(Options{ExecutionSegment: seg})
+		assert.Equal(t, seg, opts.ExecutionSegment)
+
+		opts = opts.Apply(Options{ExecutionSegment: &ExecutionSegment{}})
+		assert.Equal(t, seg, opts.ExecutionSegment)
+	})
+	t.Run("TraceContext", func(t *testing.T) {
+		opts := Options{}.Apply(Options{TraceContext: null.BoolFrom(true)})
+		assert.Equal(t, null.BoolFrom(true), opts.TraceContext)
+	})
+	t.Run("URLGrouping", func(t *testing.T) {
+		grouping := &URLGrouping{URLGroupingFields{Collapse: null.BoolFrom(true)}, nil}
+		opts := Options{}.Apply(Options{URLGrouping: grouping})
+		assert.Equal(t, grouping, opts.URLGrouping)
+
+		opts = opts.Apply(Options{URLGrouping: &URLGrouping{}})
+		assert.Equal(t, grouping, opts.URLGrouping)
+	})
+	t.Run("HTTPRetry", func(t *testing.T) {
+		retry := &HTTPRetry{HTTPRetryFields{MaxAttempts: null.IntFrom(3)}}
+		opts := Options{}.Apply(Options{HTTPRetry: retry})
+		assert.Equal(t, retry, opts.HTTPRetry)
+
+		opts = opts.Apply(Options{HTTPRetry: &HTTPRetry{}})
+		assert.Equal(t, retry, opts.HTTPRetry)
+	})
+	t.Run("CircuitBreaker", func(t *testing.T) {
+		breaker := &CircuitBreaker{CircuitBreakerFields{ErrorRate: null.FloatFrom(0.5)}, nil}
+		opts := Options{}.Apply(Options{CircuitBreaker: breaker})
+		assert.Equal(t, breaker, opts.CircuitBreaker)
+
+		opts = opts.Apply(Options{CircuitBreaker: &CircuitBreaker{}})
+		assert.Equal(t, breaker, opts.CircuitBreaker)
+	})
+	t.Run("Baseline", func(t *testing.T) {
+		opts := Options{}.Apply(Options{Baseline: null.StringFrom("baseline.json"), BaselineOutput: null.StringFrom("new.json")})
+		assert.Equal(t, null.StringFrom("baseline.json"), opts.Baseline)
+		assert.Equal(t, null.StringFrom("new.json"), opts.BaselineOutput)
+	})
+	t.Run("OutputAggregation", func(t *testing.T) {
+		aggregation := &OutputAggregation{OutputAggregationFields{Drop: []string{"vus"}}}
+		opts := Options{}.Apply(Options{OutputAggregation: aggregation})
+		assert.Equal(t, aggregation, opts.OutputAggregation)
+
+		opts = opts.Apply(Options{OutputAggregation: &OutputAggregation{}})
+		assert.Equal(t, aggregation, opts.OutputAggregation)
+	})
 
 }
 
@@ -414,6 +714,89 @@ func TestOptionsEnv(t *testing.T) {
 			"":    null.Int{},
 			"123": null.IntFrom(123),
 		},
+		{"WarmupIterations", "K6_WARMUP_ITERATIONS"}: {
+			"":  null.Int{},
+			"5": null.IntFrom(5),
+		},
+		{"AdaptiveRate", "K6_ADAPTIVE_RATE"}: {
+			"": &AdaptiveRate{},
+			"target=300ms,maxRate=100": &AdaptiveRate{
+				Target:  types.NullDurationFrom(300 * time.Millisecond),
+				MaxRate: null.IntFrom(100),
+			},
+		},
+		{"VUQuotas", "K6_VU_QUOTAS"}: {
+			"": &VUQuotas{},
+			"maxMemory=64MB,maxIterationCPU=500ms": &VUQuotas{
+				MaxMemory:       null.StringFrom("64MB"),
+				MaxIterationCPU: types.NullDurationFrom(500 * time.Millisecond),
+			},
+		},
+		{"ResourceLimits", "K6_RESOURCE_LIMITS"}: {
+			"": &ResourceLimits{},
+			"maxMemory=2GB,maxConnections=100,policy=abort": &ResourceLimits{
+				MaxMemory:      null.StringFrom("2GB"),
+				MaxConnections: null.IntFrom(100),
+				Policy:         null.StringFrom("abort"),
+			},
+		},
+		{"ExecutionSegment", "K6_EXECUTION_SEGMENT"}: {
+			"":    &ExecutionSegment{},
+			"1/4": &ExecutionSegment{Index: 1, Count: 4},
+		},
+		{"RunID", "K6_RUN_ID"}: {
+			"":       null.String{},
+			"8f2c1a": null.StringFrom("8f2c1a"),
+		},
+		{"Metadata", "K6_METADATA"}: {
+			"release:1.2.0,team:checkout": map[string]string{"release": "1.2.0", "team": "checkout"},
+		},
+		{"NoGitMetadata", "K6_NO_GIT_METADATA"}: {
+			"":     null.Bool{},
+			"true": null.BoolFrom(true),
+		},
+		{"TraceContext", "K6_TRACE_CONTEXT"}: {
+			"":      null.Bool{},
+			"true":  null.BoolFrom(true),
+			"false": null.BoolFrom(false),
+		},
+		{"URLGrouping", "K6_URL_GROUPING"}: {
+			"": &URLGrouping{},
+			"collapse,maxNames=10": &URLGrouping{
+				URLGroupingFields{Collapse: null.BoolFrom(true), MaxNames: null.IntFrom(10)},
+				&urlNameSet{names: map[string]bool{}},
+			},
+		},
+		{"HTTPRetry", "K6_HTTP_RETRY"}: {
+			"": &HTTPRetry{},
+			"maxAttempts=3,statuses=5xx,statuses=429,backoff=200ms": &HTTPRetry{
+				HTTPRetryFields{
+					MaxAttempts: null.IntFrom(3),
+					Statuses:    []string{"5xx", "429"},
+					Backoff:     types.NullDurationFrom(200 * time.Millisecond),
+				},
+			},
+		},
+		{"CircuitBreaker", "K6_CIRCUIT_BREAKER"}: {
+			"": &CircuitBreaker{},
+			"errorRate=0.5,window=30s": &CircuitBreaker{
+				CircuitBreakerFields{
+					ErrorRate: null.FloatFrom(0.5),
+					Window:    types.NullDurationFrom(30 * time.Second),
+				},
+				&circuitSet{circuits: map[string]*circuit{}},
+			},
+		},
+		{"OutputAggregation", "K6_OUTPUT_AGGREGATION"}: {
+			"": &OutputAggregation{},
+			"period=5s,metrics=http_req_*,metrics=iterations,drop=vus": &OutputAggregation{
+				OutputAggregationFields{
+					Period:  types.NullDurationFrom(5 * time.Second),
+					Metrics: []string{"http_req_*", "iterations"},
+					Drop:    []string{"vus"},
+				},
+			},
+		},
 		{"Stages", "K6_STAGES"}: {
 			// "": []Stage{},
 			"1s": []Stage{{
@@ -439,6 +822,23 @@ func TestOptionsEnv(t *testing.T) {
 		// TLSCipherSuites
 		// TLSVersion
 		// TLSAuth
+		{"TLSSessionCache", "K6_TLS_SESSION_CACHE"}: {
+			"":   null.String{},
+			"vu": null.StringFrom("vu"),
+		},
+		{"TLSECHConfigList", "K6_TLS_ECH_CONFIG_LIST"}: {
+			"":     TLSECHConfigList(nil),
+			"AQID": TLSECHConfigList{1, 2, 3},
+		},
+		{"RequireStapling", "K6_REQUIRE_STAPLING"}: {
+			"":      null.Bool{},
+			"true":  null.BoolFrom(true),
+			"false": null.BoolFrom(false),
+		},
+		{"ConnectionReuse", "K6_CONNECTION_REUSE"}: {
+			"":          null.String{},
+			"iteration": null.StringFrom("iteration"),
+		},
 		{"NoConnectionReuse", "K6_NO_CONNECTION_REUSE"}: {
 			"":      null.Bool{},
 			"true":  null.BoolFrom(true),
@@ -463,6 +863,65 @@ func TestOptionsEnv(t *testing.T) {
 			"true":  null.BoolFrom(true),
 			"false": null.BoolFrom(false),
 		},
+		{"CookieJar", "K6_COOKIE_JAR"}: {
+			"":       null.String{},
+			"shared": null.StringFrom("shared"),
+		},
+		{"HTTPCache", "K6_HTTP_CACHE"}: {
+			"":   null.String{},
+			"vu": null.StringFrom("vu"),
+		},
+		{"ConsoleLevel", "K6_CONSOLE_LEVEL"}: {
+			"":     null.String{},
+			"warn": null.StringFrom("warn"),
+		},
+		{"ConsoleRateLimit", "K6_CONSOLE_RATE_LIMIT"}: {
+			"":    null.Int{},
+			"100": null.IntFrom(100),
+		},
+		{"Baseline", "K6_BASELINE"}: {
+			"":              null.String{},
+			"baseline.json": null.StringFrom("baseline.json"),
+		},
+		{"BaselineOutput", "K6_BASELINE_OUTPUT"}: {
+			"":         null.String{},
+			"new.json": null.StringFrom("new.json"),
+		},
+		{"HAROutput", "K6_HAR_OUTPUT"}: {
+			"":        null.String{},
+			"out.har": null.StringFrom("out.har"),
+		},
+		{"ArtifactsDir", "K6_ARTIFACTS_DIR"}: {
+			"":          null.String{},
+			"artifacts": null.StringFrom("artifacts"),
+		},
+		{"HARSanitize", "K6_HAR_SANITIZE"}: {
+			"":                  []string{""}, // disables the default sanitization
+			"X-Api-Key,session": []string{"X-Api-Key", "session"},
+		},
+		{"Assets", "K6_ASSETS"}: {
+			"./certs/*.pem,./protos/service.desc": []string{"./certs/*.pem", "./protos/service.desc"},
+		},
+		{"AllowedModules", "K6_ALLOWED_MODULES"}: {
+			"k6/http,k6/x/*": []string{"k6/http", "k6/x/*"},
+		},
+		{"BlockedModules", "K6_BLOCKED_MODULES"}: {
+			"k6/fs": []string{"k6/fs"},
+		},
+		{"AllowedHosts", "K6_ALLOWED_HOSTS"}: {
+			"*.example.com,10.0.0.0/8": []string{"*.example.com", "10.0.0.0/8"},
+		},
+		{"BlockedHosts", "K6_BLOCKED_HOSTS"}: {
+			"169.254.169.254": []string{"169.254.169.254"},
+		},
+		{"BrowserExecutable", "K6_BROWSER_EXECUTABLE"}: {
+			"":                  null.String{},
+			"/usr/bin/chromium": null.StringFrom("/usr/bin/chromium"),
+		},
+		{"BrowserSessions", "K6_BROWSER_SESSIONS"}: {
+			"":  null.Int{},
+			"4": null.IntFrom(4),
+		},
 		// Thresholds
 		// External
 	}
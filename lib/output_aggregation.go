@@ -0,0 +1,146 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+)
+
+// DefaultOutputAggregationPeriod is the length of the aggregation windows if it isn't set.
+const DefaultOutputAggregationPeriod = 1 * time.Second
+
+// OutputAggregationFields defines the fields used for an OutputAggregation; see StageFields for
+// why this is a separate type.
+type OutputAggregationFields struct {
+	// Length of the windows samples are aggregated over.
+	Period types.NullDuration `json:"period"`
+
+	// Metrics whose samples are aggregated instead of being passed on one by one; names may
+	// contain "*" wildcards. If a period is set but no metrics are, all metrics are aggregated.
+	Metrics []string `json:"metrics"`
+
+	// Metrics that aren't passed to outputs at all; names may contain "*" wildcards.
+	Drop []string `json:"drop"`
+}
+
+// OutputAggregation thins out the samples passed to outputs, by dropping some metrics and
+// pre-aggregating others per tag set, so that high-RPS tests don't overwhelm metric backends.
+// The end-of-test summary and thresholds still see every sample.
+type OutputAggregation struct {
+	OutputAggregationFields
+}
+
+func (a *OutputAggregation) UnmarshalJSON(b []byte) error {
+	var fields OutputAggregationFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*a = OutputAggregation{fields}
+	return a.validate()
+}
+
+func (a OutputAggregation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.OutputAggregationFields)
+}
+
+// UnmarshalText parses a comma-separated list of "period=D", "metrics=name" and "drop=name",
+// where the latter two may be repeated, eg. "period=5s,metrics=http_req_*,drop=data_*".
+// An empty string unsets it.
+func (a *OutputAggregation) UnmarshalText(b []byte) error {
+	var fields OutputAggregationFields
+	if strings.TrimSpace(string(b)) == "" {
+		*a = OutputAggregation{}
+		return nil
+	}
+	for _, part := range strings.Split(string(b), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return errors.Errorf("output aggregation parameter '%s' needs a value", kv[0])
+		}
+		switch kv[0] {
+		case "period":
+			if err := fields.Period.UnmarshalText([]byte(kv[1])); err != nil {
+				return errors.Wrap(err, "output aggregation parameter 'period'")
+			}
+		case "metrics":
+			fields.Metrics = append(fields.Metrics, kv[1])
+		case "drop":
+			fields.Drop = append(fields.Drop, kv[1])
+		default:
+			return errors.Errorf("unknown output aggregation parameter '%s'", kv[0])
+		}
+	}
+	*a = OutputAggregation{fields}
+	return a.validate()
+}
+
+func (a OutputAggregation) validate() error {
+	if a.Period.Valid && a.Period.Duration <= 0 {
+		return errors.New("the output aggregation period must be positive")
+	}
+	for _, pattern := range append(append([]string{}, a.Metrics...), a.Drop...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "output aggregation metric '%s'", pattern)
+		}
+	}
+	return nil
+}
+
+// IsSet returns whether any of the fields are set.
+func (a OutputAggregation) IsSet() bool {
+	return a.Period.Valid || a.Metrics != nil || a.Drop != nil
+}
+
+// GetPeriod returns the length of the aggregation windows, or its default.
+func (a OutputAggregation) GetPeriod() time.Duration {
+	if a.Period.Valid {
+		return time.Duration(a.Period.Duration)
+	}
+	return DefaultOutputAggregationPeriod
+}
+
+// ShouldAggregate returns whether samples of the given metric should be aggregated.
+func (a OutputAggregation) ShouldAggregate(metric string) bool {
+	if len(a.Metrics) == 0 {
+		return a.Period.Valid
+	}
+	return matchesAny(a.Metrics, metric)
+}
+
+// ShouldDrop returns whether samples of the given metric shouldn't be passed to outputs.
+func (a OutputAggregation) ShouldDrop(metric string) bool {
+	return matchesAny(a.Drop, metric)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
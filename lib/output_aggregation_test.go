@@ -0,0 +1,74 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputAggregationUnmarshal(t *testing.T) {
+	var a OutputAggregation
+	require.NoError(t, json.Unmarshal([]byte(`{"period": "5s", "metrics": ["http_req_*"], "drop": ["vus"]}`), &a))
+	assert.Equal(t, OutputAggregation{OutputAggregationFields{
+		Period:  types.NullDurationFrom(5 * time.Second),
+		Metrics: []string{"http_req_*"},
+		Drop:    []string{"vus"},
+	}}, a)
+
+	data, err := json.Marshal(a)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"period": "5s", "metrics": ["http_req_*"], "drop": ["vus"]}`, string(data))
+
+	for text, msg := range map[string]string{
+		"period=0s":   "the output aggregation period must be positive",
+		"period":      "output aggregation parameter 'period' needs a value",
+		"metrics=[":   "output aggregation metric '[': syntax error in pattern",
+		"interval=1s": "unknown output aggregation parameter 'interval'",
+		"period=lots": "output aggregation parameter 'period'",
+	} {
+		err := a.UnmarshalText([]byte(text))
+		if assert.Error(t, err, text) {
+			assert.Contains(t, err.Error(), msg, text)
+		}
+	}
+}
+
+func TestOutputAggregationMatching(t *testing.T) {
+	a := OutputAggregation{OutputAggregationFields{Metrics: []string{"http_req_*", "iterations"}, Drop: []string{"vus*"}}}
+	assert.True(t, a.ShouldAggregate("http_req_duration"))
+	assert.True(t, a.ShouldAggregate("iterations"))
+	assert.False(t, a.ShouldAggregate("http_reqs"))
+	assert.True(t, a.ShouldDrop("vus_max"))
+	assert.False(t, a.ShouldDrop("http_reqs"))
+	assert.Equal(t, DefaultOutputAggregationPeriod, a.GetPeriod())
+
+	// With a period but no metrics, everything's aggregated.
+	a = OutputAggregation{OutputAggregationFields{Period: types.NullDurationFrom(time.Second)}}
+	assert.True(t, a.ShouldAggregate("http_reqs"))
+	a = OutputAggregation{OutputAggregationFields{Drop: []string{"vus"}}}
+	assert.False(t, a.ShouldAggregate("http_reqs"))
+}
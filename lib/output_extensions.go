@@ -0,0 +1,93 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// OutputParams are what an output extension is created with.
+type OutputParams struct {
+	// The argument of the output, eg. "localhost:4317" for "--out myoutput=localhost:4317".
+	Arg string
+
+	// The consolidated options of the test.
+	Options Options
+
+	// The script or archive being run.
+	Source *SourceData
+
+	// The version of k6, eg. "0.22.1".
+	Version string
+}
+
+// An OutputConstructor creates an output from an extension.
+type OutputConstructor func(params OutputParams) (Collector, error)
+
+var outputNameRE = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+var outputExtensions = struct {
+	sync.Mutex
+	constructors map[string]OutputConstructor
+}{constructors: make(map[string]OutputConstructor)}
+
+// RegisterOutput registers an output from an extension, to be used with "--out <name>[=<arg>]".
+// It's meant to be called from the init() of the extension's package, and panics if the name is
+// invalid or already taken, like database/sql.Register. The built-in outputs take precedence, so
+// an extension should use a name that's specific to it.
+func RegisterOutput(name string, constructor OutputConstructor) {
+	outputExtensions.Lock()
+	defer outputExtensions.Unlock()
+
+	if !outputNameRE.MatchString(name) {
+		panic(fmt.Sprintf("lib: invalid output name '%s', it must be lowercase letters, digits, '-' and '_'", name))
+	}
+	if constructor == nil {
+		panic(fmt.Sprintf("lib: the constructor of the output '%s' is nil", name))
+	}
+	if _, ok := outputExtensions.constructors[name]; ok {
+		panic(fmt.Sprintf("lib: the output '%s' is already registered", name))
+	}
+	outputExtensions.constructors[name] = constructor
+}
+
+// GetOutputExtension returns the constructor of an output registered by an extension.
+func GetOutputExtension(name string) (OutputConstructor, bool) {
+	outputExtensions.Lock()
+	defer outputExtensions.Unlock()
+	constructor, ok := outputExtensions.constructors[name]
+	return constructor, ok
+}
+
+// OutputExtensions returns the names of the outputs registered by extensions, sorted.
+func OutputExtensions() []string {
+	outputExtensions.Lock()
+	defer outputExtensions.Unlock()
+	names := make([]string, 0, len(outputExtensions.constructors))
+	for name := range outputExtensions.constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
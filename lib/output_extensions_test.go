@@ -0,0 +1,71 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterOutput(t *testing.T) {
+	defer func() {
+		outputExtensions.Lock()
+		delete(outputExtensions.constructors, "test-output")
+		delete(outputExtensions.constructors, "test_output2")
+		outputExtensions.Unlock()
+	}()
+
+	var got OutputParams
+	RegisterOutput("test-output", func(params OutputParams) (Collector, error) {
+		got = params
+		return nil, errors.New("test")
+	})
+	RegisterOutput("test_output2", func(params OutputParams) (Collector, error) { return nil, nil })
+	assert.Equal(t, []string{"test-output", "test_output2"}, OutputExtensions())
+
+	constructor, ok := GetOutputExtension("test-output")
+	require.True(t, ok)
+	_, err := constructor(OutputParams{Arg: "localhost:4317", Version: "0.22.1"})
+	assert.EqualError(t, err, "test")
+	assert.Equal(t, OutputParams{Arg: "localhost:4317", Version: "0.22.1"}, got)
+
+	_, ok = GetOutputExtension("missing")
+	assert.False(t, ok)
+
+	t.Run("Invalid", func(t *testing.T) {
+		noop := func(params OutputParams) (Collector, error) { return nil, nil }
+		assert.PanicsWithValue(t, "lib: the output 'test-output' is already registered", func() {
+			RegisterOutput("test-output", noop)
+		})
+		assert.PanicsWithValue(t, "lib: the constructor of the output 'nil' is nil", func() {
+			RegisterOutput("nil", nil)
+		})
+		for _, name := range []string{"", "Upper", "1st", "with space", "with=arg"} {
+			t.Run(name, func(t *testing.T) {
+				assert.Panics(t, func() { RegisterOutput(name, noop) })
+			})
+		}
+		assert.Equal(t, []string{"test-output", "test_output2"}, OutputExtensions())
+	})
+}
@@ -0,0 +1,141 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package lib
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// What happens when a resource limit is exceeded.
+const (
+	// Skip iterations until usage is back under the limits, counting them as dropped_iterations.
+	ResourcePolicyShed = "shed"
+	// Stop the test with an error.
+	ResourcePolicyAbort = "abort"
+)
+
+// ResourceLimitsFields defines the fields used for a ResourceLimits; see StageFields for why
+// this is a separate type.
+type ResourceLimitsFields struct {
+	// The most heap memory the load generator may use, eg. "2GB".
+	MaxMemory null.String `json:"maxMemory"`
+
+	// The most connections that may be open across all VUs.
+	MaxConnections null.Int `json:"maxConnections"`
+
+	// What to do when a limit is exceeded, ResourcePolicyShed by default.
+	Policy null.String `json:"policy"`
+}
+
+// ResourceLimits protects the load generator from running out of memory or file descriptors,
+// eg. in a long soak test where responses pile up, by shedding iterations or stopping the test
+// when they're exceeded.
+type ResourceLimits ResourceLimitsFields
+
+func (l *ResourceLimits) UnmarshalJSON(b []byte) error {
+	var fields ResourceLimitsFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*l = ResourceLimits(fields)
+	return l.Validate()
+}
+
+func (l ResourceLimits) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ResourceLimitsFields(l))
+}
+
+// UnmarshalText parses a comma-separated list of key=value pairs, using the same keys as the
+// JSON representation, eg. "maxMemory=2GB,maxConnections=5000". An empty string unsets it.
+func (l *ResourceLimits) UnmarshalText(b []byte) error {
+	var limits ResourceLimits
+	if strings.TrimSpace(string(b)) == "" {
+		*l = limits
+		return nil
+	}
+	for _, part := range strings.Split(string(b), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("invalid resource limit parameter '%s', expected key=value", part)
+		}
+		key, value := kv[0], kv[1]
+
+		switch key {
+		case "maxMemory":
+			limits.MaxMemory = null.StringFrom(value)
+		case "maxConnections":
+			i, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errors.Wrapf(err, "resource limit parameter '%s'", key)
+			}
+			limits.MaxConnections = null.IntFrom(i)
+		case "policy":
+			limits.Policy = null.StringFrom(value)
+		default:
+			return errors.Errorf("unknown resource limit parameter '%s'", key)
+		}
+	}
+	*l = limits
+	return l.Validate()
+}
+
+// Validate checks that at least one limit is set and that all values are in range.
+func (l ResourceLimits) Validate() error {
+	if !l.MaxMemory.Valid && !l.MaxConnections.Valid {
+		return errors.New("resource limits need a max memory, a max number of connections or both")
+	}
+	if l.MaxMemory.Valid {
+		if _, err := humanize.ParseBytes(l.MaxMemory.String); err != nil {
+			return errors.Wrap(err, "the max memory resource limit")
+		}
+	}
+	if l.MaxConnections.Valid && l.MaxConnections.Int64 <= 0 {
+		return errors.New("the max connections resource limit must be positive")
+	}
+	switch l.GetPolicy() {
+	case ResourcePolicyShed, ResourcePolicyAbort:
+	default:
+		return errors.Errorf("unknown resource limit policy '%s'", l.Policy.String)
+	}
+	return nil
+}
+
+// GetMaxMemory returns the memory limit in bytes, or 0 if there isn't one.
+func (l ResourceLimits) GetMaxMemory() uint64 {
+	if !l.MaxMemory.Valid {
+		return 0
+	}
+	b, _ := humanize.ParseBytes(l.MaxMemory.String)
+	return b
+}
+
+// GetPolicy returns the policy, or its default.
+func (l ResourceLimits) GetPolicy() string {
+	if l.Policy.Valid {
+		return l.Policy.String
+	}
+	return ResourcePolicyShed
+}
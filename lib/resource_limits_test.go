@@ -0,0 +1,72 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestResourceLimits(t *testing.T) {
+	full := ResourceLimits{
+		MaxMemory:      null.StringFrom("2GB"),
+		MaxConnections: null.IntFrom(5000),
+		Policy:         null.StringFrom(ResourcePolicyAbort),
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(full)
+		assert.NoError(t, err)
+
+		var limits ResourceLimits
+		assert.NoError(t, json.Unmarshal(data, &limits))
+		assert.Equal(t, full, limits)
+
+		assert.EqualError(t, json.Unmarshal([]byte(`{"policy": "shed"}`), &limits),
+			"resource limits need a max memory, a max number of connections or both")
+	})
+	t.Run("Text", func(t *testing.T) {
+		var limits ResourceLimits
+		assert.NoError(t, limits.UnmarshalText([]byte("maxMemory=2GB,maxConnections=5000,policy=abort")))
+		assert.Equal(t, full, limits)
+
+		assert.EqualError(t, limits.UnmarshalText([]byte("maxMemory")),
+			"invalid resource limit parameter 'maxMemory', expected key=value")
+		assert.EqualError(t, limits.UnmarshalText([]byte("maxCPU=1")),
+			"unknown resource limit parameter 'maxCPU'")
+		if err := limits.UnmarshalText([]byte("maxMemory=lots")); assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "the max memory resource limit: ")
+		}
+		assert.EqualError(t, limits.UnmarshalText([]byte("maxConnections=0")),
+			"the max connections resource limit must be positive")
+		assert.EqualError(t, limits.UnmarshalText([]byte("maxConnections=1,policy=panic")),
+			"unknown resource limit policy 'panic'")
+	})
+	t.Run("Defaults", func(t *testing.T) {
+		limits := ResourceLimits{MaxConnections: null.IntFrom(1)}
+		assert.Equal(t, uint64(0), limits.GetMaxMemory())
+		assert.Equal(t, ResourcePolicyShed, limits.GetPolicy())
+		assert.Equal(t, uint64(2000000000), full.GetMaxMemory())
+		assert.Equal(t, uint64(512<<20), ResourceLimits{MaxMemory: null.StringFrom("512MiB")}.GetMaxMemory())
+	})
+}
@@ -0,0 +1,51 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/loadimpact/k6/stats"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// NewRunID returns a random ID for the runID option.
+func NewRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRunID returns the options with a random run ID if the runID option isn't set, and with it
+// added to the run tags if the run_id system tag is enabled, so that every metric of the test run
+// is tagged with it.
+func (o Options) WithRunID() Options {
+	if !o.RunID.Valid || o.RunID.String == "" {
+		o.RunID = null.StringFrom(NewRunID())
+	}
+	if o.SystemTags["run_id"] {
+		tags := o.RunTags.CloneTags()
+		tags["run_id"] = o.RunID.String
+		o.RunTags = stats.IntoSampleTags(&tags)
+	}
+	return o
+}
@@ -0,0 +1,50 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"testing"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestWithRunID(t *testing.T) {
+	t.Run("Random", func(t *testing.T) {
+		a, b := Options{}.WithRunID(), Options{}.WithRunID()
+		assert.Len(t, a.RunID.String, 16)
+		assert.NotEqual(t, a.RunID, b.RunID)
+		assert.Nil(t, a.RunTags)
+	})
+	t.Run("Given", func(t *testing.T) {
+		opts := Options{RunID: null.StringFrom("nightly-42")}.WithRunID()
+		assert.Equal(t, null.StringFrom("nightly-42"), opts.RunID)
+	})
+	t.Run("Tagged", func(t *testing.T) {
+		opts := Options{
+			RunID:      null.StringFrom("nightly-42"),
+			SystemTags: GetTagSet("run_id"),
+			RunTags:    stats.IntoSampleTags(&map[string]string{"env": "staging"}),
+		}.WithRunID()
+		assert.Equal(t, map[string]string{"env": "staging", "run_id": "nightly-42"}, opts.RunTags.CloneTags())
+	})
+}
@@ -68,6 +68,17 @@ type Runner interface {
 	SetOptions(opts Options) error
 }
 
+// An RPSLimiter is a Runner whose RPS limit can be changed while the test is running.
+type RPSLimiter interface {
+	// Sets the limit for requests per second across all VUs, or removes it if it's 0.
+	SetRPSLimit(rps int64)
+}
+
+// A ConnectionCounter is a Runner that keeps count of the connections its VUs have open.
+type ConnectionCounter interface {
+	OpenConnections() int64
+}
+
 // A VU is a Virtual User, that can be scheduled by an Executor.
 type VU interface {
 	// Runs the VU once. The VU is responsible for handling the Halting Problem, eg. making sure
@@ -0,0 +1,125 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateModulePatterns returns an error if one of the patterns of the allowedModules or
+// blockedModules options isn't valid: the name of a built-in module, eg. "k6/http", or a prefix
+// of them followed by "/*", eg. "k6/x/*".
+func ValidateModulePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		name := strings.TrimSuffix(pattern, "/*")
+		if (name != "k6" && !strings.HasPrefix(name, "k6/")) || strings.ContainsAny(name, "* ") ||
+			strings.HasSuffix(name, "/") {
+			return errors.Errorf("invalid module pattern '%s', use the name of a k6 module, eg. 'k6/http', "+
+				"or a prefix of them ending in '/*', eg. 'k6/x/*'", pattern)
+		}
+	}
+	return nil
+}
+
+// ValidateHostPatterns returns an error if one of the patterns of the allowedHosts or
+// blockedHosts options isn't valid: a host name, "*." followed by a domain, "*", an IP or a
+// CIDR range.
+func ValidateHostPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		valid := pattern != "" && !strings.ContainsAny(pattern, " :/*")
+		switch {
+		case pattern == "*":
+			valid = true
+		case strings.HasPrefix(pattern, "*."):
+			valid = len(pattern) > 2 && !strings.ContainsAny(pattern[2:], " :/*")
+		case strings.Contains(pattern, "/"):
+			_, _, err := net.ParseCIDR(pattern)
+			valid = err == nil
+		case net.ParseIP(pattern) != nil:
+			valid = true
+		}
+		if !valid {
+			return errors.Errorf("invalid host pattern '%s', use a host name, '*.' followed by a domain, "+
+				"'*', an IP or a CIDR range", pattern)
+		}
+	}
+	return nil
+}
+
+// CheckModule returns an error if the allowedModules and blockedModules options, as given, don't
+// let scripts import the named built-in module.
+func CheckModule(allowed, blocked []string, name string) error {
+	if len(allowed) > 0 && !matchModule(allowed, name) {
+		return errors.Errorf("the module '%s' isn't in the allowed modules", name)
+	}
+	if matchModule(blocked, name) {
+		return errors.Errorf("the module '%s' is in the blocked modules", name)
+	}
+	return nil
+}
+
+func matchModule(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == name || (strings.HasSuffix(pattern, "/*") && strings.HasPrefix(name, pattern[:len(pattern)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckHost returns an error if the allowedHosts and blockedHosts options, as given, don't let
+// tests connect to the host, which is matched by its name, and by the IP it resolved to.
+func CheckHost(allowed, blocked []string, host string, ip net.IP) error {
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+	if len(allowed) > 0 && !matchHost(allowed, host, ip) {
+		return errors.Errorf("the host '%s' isn't in the allowed hosts", host)
+	}
+	if matchHost(blocked, host, ip) {
+		return errors.Errorf("the host '%s' is in the blocked hosts", host)
+	}
+	return nil
+}
+
+func matchHost(patterns []string, host string, ip net.IP) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		switch {
+		case pattern == "*", pattern == host:
+			return true
+		case strings.HasPrefix(pattern, "*."):
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		case strings.Contains(pattern, "/"):
+			if _, ipnet, err := net.ParseCIDR(pattern); err == nil && ip != nil && ipnet.Contains(ip) {
+				return true
+			}
+		default:
+			if patternIP := net.ParseIP(pattern); patternIP != nil && patternIP.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
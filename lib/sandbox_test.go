@@ -0,0 +1,101 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateModulePatterns(t *testing.T) {
+	assert.NoError(t, ValidateModulePatterns(nil))
+	assert.NoError(t, ValidateModulePatterns([]string{"k6", "k6/http", "k6/*", "k6/x/*", "k6/x/redis"}))
+	for _, pattern := range []string{"", "*", "http", "k6/", "k6/x/", "k6/x*", "k6/*/redis", "./lib.js"} {
+		t.Run(pattern, func(t *testing.T) {
+			assert.Error(t, ValidateModulePatterns([]string{"k6/http", pattern}))
+		})
+	}
+}
+
+func TestValidateHostPatterns(t *testing.T) {
+	assert.NoError(t, ValidateHostPatterns(nil))
+	assert.NoError(t, ValidateHostPatterns([]string{
+		"*", "example.com", "*.example.com", "localhost", "10.1.2.3", "10.0.0.0/8", "::1", "fd00::/8",
+	}))
+	for _, pattern := range []string{"", "*.", "*example.com", "api.*.com", "example.com:443", "10.0.0.0/33", "a b"} {
+		t.Run(pattern, func(t *testing.T) {
+			assert.Error(t, ValidateHostPatterns([]string{"example.com", pattern}))
+		})
+	}
+}
+
+func TestCheckModule(t *testing.T) {
+	assert.NoError(t, CheckModule(nil, nil, "k6/http"))
+
+	allowed := []string{"k6", "k6/http", "k6/x/*"}
+	for _, name := range []string{"k6", "k6/http", "k6/x/redis", "k6/x/redis/cluster"} {
+		assert.NoError(t, CheckModule(allowed, nil, name), name)
+	}
+	assert.EqualError(t, CheckModule(allowed, nil, "k6/fs"), "the module 'k6/fs' isn't in the allowed modules")
+	assert.EqualError(t, CheckModule(allowed, nil, "k6/http/extra"),
+		"the module 'k6/http/extra' isn't in the allowed modules")
+
+	assert.EqualError(t, CheckModule(allowed, []string{"k6/x/*"}, "k6/x/redis"),
+		"the module 'k6/x/redis' is in the blocked modules")
+	assert.NoError(t, CheckModule(nil, []string{"k6/x/*"}, "k6/http"))
+}
+
+func TestCheckHost(t *testing.T) {
+	ip := net.ParseIP("10.1.2.3")
+	assert.NoError(t, CheckHost(nil, nil, "example.com", ip))
+
+	testdata := map[string]struct {
+		allowed, blocked []string
+		host             string
+		err              string
+	}{
+		"name":               {[]string{"example.com"}, nil, "Example.COM.", ""},
+		"subdomain":          {[]string{"*.example.com"}, nil, "api.example.com", ""},
+		"not subdomain":      {[]string{"*.example.com"}, nil, "example.com", "the host 'example.com' isn't in the allowed hosts"},
+		"not suffix":         {[]string{"*.example.com"}, nil, "badexample.com", "the host 'badexample.com' isn't in the allowed hosts"},
+		"ip":                 {[]string{"10.1.2.3"}, nil, "example.com", ""},
+		"ip literal":         {[]string{"10.1.2.3"}, nil, "[10.1.2.3]", ""},
+		"cidr":               {[]string{"10.0.0.0/8"}, nil, "example.com", ""},
+		"not cidr":           {[]string{"192.168.0.0/16"}, nil, "example.com", "the host 'example.com' isn't in the allowed hosts"},
+		"blocked":            {nil, []string{"*"}, "example.com", "the host 'example.com' is in the blocked hosts"},
+		"blocked subdomain":  {[]string{"*.example.com"}, []string{"admin.example.com"}, "admin.example.com", "the host 'admin.example.com' is in the blocked hosts"},
+		"blocked cidr":       {[]string{"*.example.com"}, []string{"10.0.0.0/8"}, "api.example.com", "the host 'api.example.com' is in the blocked hosts"},
+		"not blocked":        {nil, []string{"*.internal"}, "example.com", ""},
+		"allowed not listed": {[]string{"other.com"}, []string{"*.internal"}, "example.com", "the host 'example.com' isn't in the allowed hosts"},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			err := CheckHost(data.allowed, data.blocked, data.host, ip)
+			if data.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, data.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// RedactedSecret replaces the secrets in everything that's redacted.
+const RedactedSecret = "[SECRET]"
+
+// MinSecretLength is the length of the shortest secret that can be registered; redacting
+// shorter ones would mangle everything else they happen to appear in.
+const MinSecretLength = 4
+
+// Secrets is the registry of the secrets of the tests in the process, which all VUs share.
+var Secrets = &SecretRegistry{}
+
+// A SecretRegistry holds secrets, eg. tokens and private keys, and redacts them from the strings
+// that are logged, recorded or sent to outputs. It's safe for concurrent use, and it's also a
+// logrus hook, which redacts the messages and fields of the entries logged.
+//
+// Secrets are counted, so that a test can remove the ones it added when it's over, without
+// removing the same ones added by another test that's still running.
+type SecretRegistry struct {
+	mu       sync.RWMutex
+	secrets  map[string]int
+	replacer *strings.Replacer
+}
+
+// secretForms returns a secret and its URL-encoded forms, since that's how it appears in query
+// strings and form bodies.
+func secretForms(secret string) []string {
+	forms := []string{secret}
+	for _, s := range []string{url.QueryEscape(secret), url.PathEscape(secret)} {
+		if s != forms[len(forms)-1] && s != secret {
+			forms = append(forms, s)
+		}
+	}
+	return forms
+}
+
+// Add registers a secret, along with its URL-encoded forms.
+func (r *SecretRegistry) Add(secret string) error {
+	if len(secret) < MinSecretLength {
+		return errors.Errorf("secrets must be at least %d characters long", MinSecretLength)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.secrets == nil {
+		r.secrets = make(map[string]int)
+	}
+	for _, s := range secretForms(secret) {
+		r.secrets[s]++
+	}
+	r.updateReplacer()
+	return nil
+}
+
+// Remove unregisters a secret added before; it's still redacted if it was added more times than
+// it's been removed.
+func (r *SecretRegistry) Remove(secret string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range secretForms(secret) {
+		if r.secrets[s] <= 1 {
+			delete(r.secrets, s)
+		} else {
+			r.secrets[s]--
+		}
+	}
+	r.updateReplacer()
+}
+
+// updateReplacer rebuilds the replacer for the registered secrets; the lock must be held.
+func (r *SecretRegistry) updateReplacer() {
+	if len(r.secrets) == 0 {
+		r.replacer = nil
+		return
+	}
+
+	// Longer secrets first, so that one containing another is redacted as a whole.
+	secrets := make([]string, 0, len(r.secrets))
+	for s := range r.secrets {
+		secrets = append(secrets, s)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		if len(secrets[i]) != len(secrets[j]) {
+			return len(secrets[i]) > len(secrets[j])
+		}
+		return secrets[i] < secrets[j]
+	})
+	oldnew := make([]string, 0, 2*len(secrets))
+	for _, s := range secrets {
+		oldnew = append(oldnew, s, RedactedSecret)
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+}
+
+// Reset removes all secrets.
+func (r *SecretRegistry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets = nil
+	r.replacer = nil
+}
+
+func (r *SecretRegistry) getReplacer() *strings.Replacer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.replacer
+}
+
+// Redact replaces the secrets in a string.
+func (r *SecretRegistry) Redact(s string) string {
+	replacer := r.getReplacer()
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// RedactValue replaces the secrets in the strings of a value, which may be a map or slice of
+// them, like the ones JS values are exported as; other values are returned as they are.
+func (r *SecretRegistry) RedactValue(v interface{}) interface{} {
+	if r.getReplacer() == nil {
+		return v
+	}
+	switch v := v.(type) {
+	case string:
+		return r.Redact(v)
+	case error:
+		if redacted := r.Redact(v.Error()); redacted != v.Error() {
+			return errors.New(redacted)
+		}
+		return v
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			res[k] = r.RedactValue(e)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			res[i] = r.RedactValue(e)
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// RedactTags returns the tags with the secrets in their values replaced, or the same tags if
+// there aren't any in them.
+func (r *SecretRegistry) RedactTags(tags *stats.SampleTags) *stats.SampleTags {
+	replacer := r.getReplacer()
+	if replacer == nil || tags.IsEmpty() {
+		return tags
+	}
+	redacted, changed := tags.CloneTags(), false
+	for k, v := range redacted {
+		if rv := replacer.Replace(v); rv != v {
+			redacted[k] = rv
+			changed = true
+		}
+	}
+	if !changed {
+		return tags
+	}
+	return stats.IntoSampleTags(&redacted)
+}
+
+// RedactSamples returns the sample containers with the secrets in their tags, and the tags of
+// their samples, replaced. Containers without any are returned as they are. The others are copied:
+// the ones that implement stats.TagMapper, eg. HTTP trails, keep their type, the rest become plain
+// Samples.
+func (r *SecretRegistry) RedactSamples(containers []stats.SampleContainer) []stats.SampleContainer {
+	if r.getReplacer() == nil {
+		return containers
+	}
+	var res []stats.SampleContainer
+	for i, container := range containers {
+		if !r.hasSecretTags(container) {
+			continue
+		}
+		if res == nil {
+			res = append([]stats.SampleContainer{}, containers...)
+		}
+		if mapper, ok := container.(stats.TagMapper); ok {
+			res[i] = mapper.MapTags(r.RedactTags)
+			continue
+		}
+		redacted := append(stats.Samples{}, container.GetSamples()...)
+		for j := range redacted {
+			redacted[j].Tags = r.RedactTags(redacted[j].Tags)
+		}
+		res[i] = redacted
+	}
+	if res == nil {
+		return containers
+	}
+	return res
+}
+
+// hasSecretTags returns whether there are secrets in the tags of the container or its samples.
+func (r *SecretRegistry) hasSecretTags(container stats.SampleContainer) bool {
+	if connected, ok := container.(stats.ConnectedSampleContainer); ok {
+		if tags := connected.GetTags(); r.RedactTags(tags) != tags {
+			return true
+		}
+	}
+	for _, sample := range container.GetSamples() {
+		if r.RedactTags(sample.Tags) != sample.Tags {
+			return true
+		}
+	}
+	return false
+}
+
+// Levels implements log.Hook.
+func (r *SecretRegistry) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements log.Hook.
+func (r *SecretRegistry) Fire(e *log.Entry) error {
+	if r.getReplacer() == nil {
+		return nil
+	}
+	e.Message = r.Redact(e.Message)
+	for k, v := range e.Data {
+		e.Data[k] = r.RedactValue(v)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretRegistry(t *testing.T) {
+	r := &SecretRegistry{}
+	assert.Equal(t, "token abc", r.Redact("token abc"))
+
+	assert.EqualError(t, r.Add("abc"), "secrets must be at least 4 characters long")
+	require.NoError(t, r.Add("s3cr3t"))
+	require.NoError(t, r.Add("s3cr3t/more"))
+	require.NoError(t, r.Add("a b&c"))
+
+	assert.Equal(t, "Bearer [SECRET], again [SECRET]", r.Redact("Bearer s3cr3t, again s3cr3t"))
+	assert.Equal(t, "path [SECRET]", r.Redact("path s3cr3t/more"))
+	assert.Equal(t, "?q=[SECRET]&p=[SECRET]", r.Redact("?q=a+b%26c&p=a%20b&c"))
+
+	assert.Equal(t, map[string]interface{}{
+		"token": "[SECRET]",
+		"list":  []interface{}{"[SECRET]", int64(1)},
+	}, r.RedactValue(map[string]interface{}{
+		"token": "s3cr3t",
+		"list":  []interface{}{"s3cr3t", int64(1)},
+	}))
+	assert.EqualError(t, r.RedactValue(errors.New("bad token s3cr3t")).(error), "bad token [SECRET]")
+
+	r.Reset()
+	assert.Equal(t, "s3cr3t", r.Redact("s3cr3t"))
+}
+
+func TestSecretRegistryRemove(t *testing.T) {
+	r := &SecretRegistry{}
+	require.NoError(t, r.Add("s3cr3t"))
+	require.NoError(t, r.Add("s3cr3t"))
+	require.NoError(t, r.Add("a b&c"))
+
+	r.Remove("s3cr3t")
+	assert.Equal(t, "[SECRET] [SECRET]", r.Redact("s3cr3t a+b%26c"), "it was added twice")
+	r.Remove("s3cr3t")
+	assert.Equal(t, "s3cr3t [SECRET]", r.Redact("s3cr3t a+b%26c"))
+	r.Remove("a b&c")
+	assert.Equal(t, "s3cr3t a+b%26c a%20b&c", r.Redact("s3cr3t a+b%26c a%20b&c"))
+	assert.Nil(t, r.getReplacer())
+
+	r.Remove("never added")
+	assert.Nil(t, r.getReplacer())
+}
+
+func TestSecretRegistryTags(t *testing.T) {
+	r := &SecretRegistry{}
+	require.NoError(t, r.Add("s3cr3t"))
+
+	clean := stats.IntoSampleTags(&map[string]string{"url": "http://example.com/"})
+	assert.True(t, clean == r.RedactTags(clean))
+
+	tags := stats.IntoSampleTags(&map[string]string{"url": "http://example.com/?token=s3cr3t", "method": "GET"})
+	assert.Equal(t, map[string]string{"url": "http://example.com/?token=[SECRET]", "method": "GET"},
+		r.RedactTags(tags).CloneTags())
+
+	now := time.Now()
+	containers := []stats.SampleContainer{
+		stats.Sample{Metric: metrics.HTTPReqs, Time: now, Tags: clean, Value: 1},
+		stats.Samples{
+			{Metric: metrics.HTTPReqs, Time: now, Tags: clean, Value: 1},
+			{Metric: metrics.HTTPReqs, Time: now, Tags: tags, Value: 2},
+		},
+	}
+	redacted := r.RedactSamples(containers)
+	assert.Equal(t, containers[0], redacted[0])
+	samples := redacted[1].GetSamples()
+	if assert.Len(t, samples, 2) {
+		assert.True(t, clean == samples[0].Tags)
+		assert.Equal(t, "http://example.com/?token=[SECRET]", samples[1].Tags.CloneTags()["url"])
+		assert.Equal(t, float64(2), samples[1].Value)
+	}
+	// The original containers aren't changed.
+	assert.True(t, tags == containers[1].GetSamples()[1].Tags)
+
+	// Connected samples keep their type.
+	connected := stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Metric: metrics.HTTPReqs, Time: now, Tags: tags, Value: 1},
+			{Metric: metrics.HTTPReqDuration, Time: now, Tags: tags, Value: 2},
+		},
+		Tags: tags,
+		Time: now,
+	}
+	redacted = r.RedactSamples([]stats.SampleContainer{connected})
+	if redactedConnected, ok := redacted[0].(stats.ConnectedSamples); assert.True(t, ok) {
+		assert.Equal(t, now, redactedConnected.Time)
+		assert.Equal(t, "http://example.com/?token=[SECRET]", redactedConnected.Tags.CloneTags()["url"])
+		if assert.Len(t, redactedConnected.Samples, 2) {
+			assert.True(t, redactedConnected.Tags == redactedConnected.Samples[0].Tags)
+			assert.True(t, redactedConnected.Tags == redactedConnected.Samples[1].Tags)
+			assert.Equal(t, float64(2), redactedConnected.Samples[1].Value)
+		}
+	}
+	assert.True(t, tags == connected.Tags)
+	assert.True(t, tags == connected.Samples[0].Tags)
+}
+
+func TestSecretRegistryHook(t *testing.T) {
+	r := &SecretRegistry{}
+	require.NoError(t, r.Add("s3cr3t"))
+
+	// The secrets must be redacted before the entry reaches any other hook.
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	logger.AddHook(r)
+	hook := logtest.NewLocal(logger)
+	logger.WithError(errors.New("invalid token s3cr3t")).WithField("token", "s3cr3t").Warn("request with s3cr3t failed")
+
+	if entry := hook.LastEntry(); assert.NotNil(t, entry) {
+		assert.Equal(t, "request with [SECRET] failed", entry.Message)
+		assert.Equal(t, "[SECRET]", entry.Data["token"])
+		assert.EqualError(t, entry.Data[log.ErrorKey].(error), "invalid token [SECRET]")
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package testutils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCerts is a throwaway CA, along with a server and a client certificate signed by it.
+type TestCerts struct {
+	CA    *x509.Certificate
+	CAKey crypto.Signer
+
+	// Server is valid for 127.0.0.1, ::1 and localhost.
+	Server tls.Certificate
+	Client tls.Certificate
+}
+
+// NewTestCerts generates a new CA, and a server and a client certificate signed by it.
+func NewTestCerts(t *testing.T) *TestCerts {
+	ca, caKey := NewTestCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "k6 test CA"}}, nil, nil)
+	certs := &TestCerts{CA: ca, CAKey: caKey}
+
+	server, serverKey := NewTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		DNSNames:    []string{"localhost"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	certs.Server = tls.Certificate{Certificate: [][]byte{server.Raw}, PrivateKey: serverKey, Leaf: server}
+	certs.Client = certs.NewClientCert(t, "k6")
+	return certs
+}
+
+// CAPool returns a pool containing only the CA, for use as RootCAs or ClientCAs.
+func (c *TestCerts) CAPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.CA)
+	return pool
+}
+
+// CAPEM returns the CA certificate as PEM.
+func (c *TestCerts) CAPEM() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.CA.Raw}))
+}
+
+// NewClientCert issues another client certificate with the given common name.
+func (c *TestCerts) NewClientCert(t *testing.T, cn string) tls.Certificate {
+	cert, key := NewTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: cn},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, c.CA, c.CAKey)
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+}
+
+// NewTestCert issues a certificate from tmpl with a fresh P-256 key, signed by parent. If parent
+// is nil, it's a self-signed CA instead. The serial number and validity period are filled in if
+// tmpl doesn't set them.
+func NewTestCert(t *testing.T, tmpl *x509.Certificate, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	if tmpl.SerialNumber == nil {
+		tmpl.SerialNumber, err = rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+		require.NoError(t, err)
+	}
+	if tmpl.NotBefore.IsZero() {
+		tmpl.NotBefore = time.Now().Add(-1 * time.Hour)
+	}
+	if tmpl.NotAfter.IsZero() {
+		tmpl.NotAfter = time.Now().Add(1 * time.Hour)
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// CertificatePEM encodes a certificate (with an ECDSA key) as PEM, the way tlsAuth expects it.
+func CertificatePEM(t *testing.T, cert tls.Certificate) (certPEM, keyPEM string) {
+	key, ok := cert.PrivateKey.(*ecdsa.PrivateKey)
+	require.True(t, ok, "not an ECDSA key")
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	for _, der := range cert.Certificate {
+		certPEM += string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	}
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM
+}
@@ -90,7 +90,7 @@ type HTTPMultiBin struct {
 func getWebsocketEchoHandler(t *testing.T) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		t.Logf("[%p %s] Upgrading to websocket connection...", req, req.URL)
-		conn, err := (&websocket.Upgrader{}).Upgrade(w, req, w.Header())
+		conn, err := (&websocket.Upgrader{EnableCompression: true}).Upgrade(w, req, w.Header())
 		if !assert.NoError(t, err) {
 			return
 		}
@@ -0,0 +1,32 @@
+// +build go1.24
+
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import "crypto/tls"
+
+// Go 1.24 replaced the X25519Kyber768Draft00 hybrid with the standardized X25519MLKEM768 one,
+// which is the only post-quantum key exchange the standard library supports.
+func init() {
+	SupportedTLSGroups["X25519MLKEM768"] = tls.X25519MLKEM768
+	SupportedTLSGroupsToString[tls.X25519MLKEM768] = "X25519MLKEM768"
+}
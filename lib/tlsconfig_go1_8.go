@@ -83,3 +83,19 @@ var SupportedTLSCipherSuitesToString = map[uint16]string{
 	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:   "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
 	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
 }
+
+// String-to-constant map of available TLS key exchange groups (named curves).
+var SupportedTLSGroups = map[string]tls.CurveID{
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+	"X25519": tls.X25519,
+}
+
+// Constant-to-string map of available TLS key exchange groups (named curves).
+var SupportedTLSGroupsToString = map[tls.CurveID]string{
+	tls.CurveP256: "P256",
+	tls.CurveP384: "P384",
+	tls.CurveP521: "P521",
+	tls.X25519:    "X25519",
+}
@@ -0,0 +1,275 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// DefaultURLGroupingMaxNames is the default cap on the number of distinct names URL grouping
+// generates.
+const DefaultURLGroupingMaxNames = 1000
+
+// URLGroupingOtherName is the name given to URLs once the cap on distinct names has been hit.
+const URLGroupingOtherName = "other"
+
+// URLGroupingPlaceholder replaces the dynamic parts of grouped URLs, the same way as in names
+// generated by http.url`...`.
+const URLGroupingPlaceholder = "${}"
+
+var uuidRE = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// URLGroupingRule names all URLs matching a regex, eg. `{"match": "^(.*)/users/[^/]+", "name":
+// "$1/users/${}"}`. Name may refer to the regex's capture groups.
+type URLGroupingRule struct {
+	Match string `json:"match"`
+	Name  string `json:"name"`
+
+	re *regexp.Regexp
+}
+
+// URLGroupingFields defines the fields used for a URLGrouping; see StageFields for why this is a
+// separate type.
+type URLGroupingFields struct {
+	// Replace path segments that look like IDs (numbers, UUIDs, hashes, tokens) and query
+	// parameter values with a placeholder.
+	Collapse null.Bool `json:"collapse"`
+
+	// Rules are checked in order before collapsing; the first one that matches names the URL.
+	Rules []URLGroupingRule `json:"rules"`
+
+	// The most distinct names to generate, after which URLs are named URLGroupingOtherName.
+	// Defaults to DefaultURLGroupingMaxNames, 0 means no limit.
+	MaxNames null.Int `json:"maxNames"`
+}
+
+// URLGrouping derives the name tag of HTTP requests from their URLs, so that eg. REST APIs with
+// IDs in their paths don't create a separate set of metrics for every single request.
+type URLGrouping struct {
+	URLGroupingFields
+
+	names *urlNameSet
+}
+
+// The names generated so far, shared by all VUs.
+type urlNameSet struct {
+	mu     sync.Mutex
+	names  map[string]bool
+	capped bool
+}
+
+func (g *URLGrouping) UnmarshalJSON(b []byte) error {
+	var fields URLGroupingFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*g = URLGrouping{URLGroupingFields: fields}
+	return g.compile()
+}
+
+func (g URLGrouping) MarshalJSON() ([]byte, error) {
+	return json.Marshal(g.URLGroupingFields)
+}
+
+// UnmarshalText parses a comma-separated list of "collapse" and "maxNames=N", eg.
+// "collapse,maxNames=200". Rules can only be given in JSON. An empty string unsets it.
+func (g *URLGrouping) UnmarshalText(b []byte) error {
+	var fields URLGroupingFields
+	if strings.TrimSpace(string(b)) == "" {
+		*g = URLGrouping{}
+		return nil
+	}
+	for _, part := range strings.Split(string(b), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		switch kv[0] {
+		case "collapse":
+			collapse := true
+			if len(kv) == 2 {
+				var err error
+				if collapse, err = strconv.ParseBool(kv[1]); err != nil {
+					return errors.Wrap(err, "URL grouping parameter 'collapse'")
+				}
+			}
+			fields.Collapse = null.BoolFrom(collapse)
+		case "maxNames":
+			if len(kv) != 2 {
+				return errors.New("URL grouping parameter 'maxNames' needs a value")
+			}
+			i, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return errors.Wrap(err, "URL grouping parameter 'maxNames'")
+			}
+			fields.MaxNames = null.IntFrom(i)
+		default:
+			return errors.Errorf("unknown URL grouping parameter '%s'", kv[0])
+		}
+	}
+	*g = URLGrouping{URLGroupingFields: fields}
+	return g.compile()
+}
+
+// compile validates the fields, compiles the rules and resets the generated names.
+func (g *URLGrouping) compile() error {
+	if g.MaxNames.Valid && g.MaxNames.Int64 < 0 {
+		return errors.New("the URL grouping max names can't be negative")
+	}
+	for i := range g.Rules {
+		re, err := regexp.Compile(g.Rules[i].Match)
+		if err != nil {
+			return errors.Wrapf(err, "URL grouping rule %d", i)
+		}
+		g.Rules[i].re = re
+	}
+	g.names = &urlNameSet{names: make(map[string]bool)}
+	return nil
+}
+
+// IsSet returns whether any of the fields are set.
+func (g URLGrouping) IsSet() bool {
+	return g.Collapse.Valid || len(g.Rules) > 0 || g.MaxNames.Valid
+}
+
+// GetMaxNames returns the cap on distinct names, or its default.
+func (g URLGrouping) GetMaxNames() int64 {
+	if g.MaxNames.Valid {
+		return g.MaxNames.Int64
+	}
+	return DefaultURLGroupingMaxNames
+}
+
+// Name returns the name of the given URL.
+func (g *URLGrouping) Name(u string) string {
+	name, matched := u, false
+	for _, rule := range g.Rules {
+		if rule.re == nil {
+			continue
+		}
+		if loc := rule.re.FindStringSubmatchIndex(u); loc != nil {
+			name, matched = string(rule.re.ExpandString(nil, rule.Name, u, loc)), true
+			break
+		}
+	}
+	if !matched && g.Collapse.Bool {
+		name = CollapseURL(u)
+	}
+	return g.limit(name)
+}
+
+func (g *URLGrouping) limit(name string) string {
+	maxNames := g.GetMaxNames()
+	if g.names == nil || maxNames == 0 {
+		return name
+	}
+
+	g.names.mu.Lock()
+	defer g.names.mu.Unlock()
+	if g.names.names[name] {
+		return name
+	}
+	if int64(len(g.names.names)) >= maxNames {
+		if !g.names.capped {
+			g.names.capped = true
+			log.Warnf("URL grouping generated %d distinct names, further ones will be named '%s'",
+				maxNames, URLGroupingOtherName)
+		}
+		return URLGroupingOtherName
+	}
+	g.names.names[name] = true
+	return name
+}
+
+// CollapseURL replaces the path segments of a URL that look like IDs and its query parameter
+// values with URLGroupingPlaceholder, and strips its fragment.
+func CollapseURL(u string) string {
+	if i := strings.IndexByte(u, '#'); i != -1 {
+		u = u[:i]
+	}
+	query := ""
+	if i := strings.IndexByte(u, '?'); i != -1 {
+		u, query = u[:i], u[i+1:]
+	}
+
+	// Leave the scheme and host alone.
+	pathStart := 0
+	if i := strings.Index(u, "://"); i != -1 {
+		pathStart = i + 3
+		if j := strings.IndexByte(u[pathStart:], '/'); j != -1 {
+			pathStart += j
+		} else {
+			pathStart = len(u)
+		}
+	}
+	segments := strings.Split(u[pathStart:], "/")
+	for i, seg := range segments {
+		if isIDSegment(seg) {
+			segments[i] = URLGroupingPlaceholder
+		}
+	}
+	u = u[:pathStart] + strings.Join(segments, "/")
+
+	if query != "" {
+		params := strings.Split(query, "&")
+		for i, param := range params {
+			if j := strings.IndexByte(param, '='); j != -1 {
+				params[i] = param[:j+1] + URLGroupingPlaceholder
+			}
+		}
+		u += "?" + strings.Join(params, "&")
+	}
+	return u
+}
+
+// isIDSegment returns whether a path segment looks like an ID: a number, a UUID, a hex string
+// such as a hash, or a long token with digits in it.
+func isIDSegment(s string) bool {
+	digits, hex := 0, true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'):
+		default:
+			hex = false
+		}
+	}
+
+	switch {
+	case digits == 0:
+		return false
+	case digits == len(s):
+		return true
+	case hex && len(s) >= 8:
+		return true
+	case uuidRE.MatchString(s):
+		return true
+	default:
+		return len(s) >= 20
+	}
+}
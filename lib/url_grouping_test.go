@@ -0,0 +1,103 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestCollapseURL(t *testing.T) {
+	testdata := map[string]string{
+		"https://example.com":                                          "https://example.com",
+		"https://example.com/":                                         "https://example.com/",
+		"https://example.com/v1/users/123":                             "https://example.com/v1/users/${}",
+		"https://example.com/users/123/orders/456/":                    "https://example.com/users/${}/orders/${}/",
+		"https://example.com/u/3f2a9c1be4d05678":                       "https://example.com/u/${}",
+		"https://example.com/u/deadbeef":                               "https://example.com/u/deadbeef",
+		"https://example.com/u/0b7a5d3e-2c4f-4e8a-9b1d-6f0e2a7c8d9b/x": "https://example.com/u/${}/x",
+		"https://example.com/t/AbCdEfGhIjKlMnOp12345":                  "https://example.com/t/${}",
+		"https://example.com/search?q=k6&page=2&raw":                   "https://example.com/search?q=${}&page=${}&raw",
+		"https://example.com/docs/2018-01-01#intro":                    "https://example.com/docs/2018-01-01",
+		"https://123.example.com:8080/a":                               "https://123.example.com:8080/a",
+	}
+	for u, name := range testdata {
+		assert.Equal(t, name, CollapseURL(u), u)
+	}
+}
+
+func TestURLGrouping(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		var g URLGrouping
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"rules": [
+				{"match": "^(https://[^/]+)/users/[^/]+/avatar", "name": "$1/users/${}/avatar"},
+				{"match": "/static/", "name": "static"}
+			]
+		}`), &g))
+		assert.Equal(t, "https://a.com/users/${}/avatar", g.Name("https://a.com/users/alice/avatar.png"))
+		assert.Equal(t, "static", g.Name("https://a.com/static/app.js"))
+		assert.Equal(t, "https://a.com/users/42", g.Name("https://a.com/users/42"))
+
+		data, err := json.Marshal(g)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"collapse": null,
+			"rules": [
+				{"match": "^(https://[^/]+)/users/[^/]+/avatar", "name": "$1/users/${}/avatar"},
+				{"match": "/static/", "name": "static"}
+			],
+			"maxNames": null
+		}`, string(data))
+
+		assert.EqualError(t, json.Unmarshal([]byte(`{"rules": [{"match": "("}]}`), &g),
+			"URL grouping rule 0: error parsing regexp: missing closing ): `(`")
+	})
+	t.Run("Text", func(t *testing.T) {
+		var g URLGrouping
+		require.NoError(t, g.UnmarshalText([]byte("collapse,maxNames=2")))
+		assert.Equal(t, null.BoolFrom(true), g.Collapse)
+		assert.Equal(t, int64(2), g.GetMaxNames())
+
+		require.NoError(t, g.UnmarshalText([]byte("collapse=false")))
+		assert.Equal(t, null.BoolFrom(false), g.Collapse)
+		assert.Equal(t, int64(DefaultURLGroupingMaxNames), g.GetMaxNames())
+
+		require.NoError(t, g.UnmarshalText([]byte("")))
+		assert.False(t, g.IsSet())
+
+		assert.EqualError(t, g.UnmarshalText([]byte("rules=x")), "unknown URL grouping parameter 'rules'")
+		assert.EqualError(t, g.UnmarshalText([]byte("maxNames=-1")), "the URL grouping max names can't be negative")
+	})
+	t.Run("MaxNames", func(t *testing.T) {
+		var g URLGrouping
+		require.NoError(t, g.UnmarshalText([]byte("collapse,maxNames=2")))
+		assert.Equal(t, "https://a.com/${}", g.Name("https://a.com/1"))
+		assert.Equal(t, "https://a.com/b", g.Name("https://a.com/b"))
+		assert.Equal(t, URLGroupingOtherName, g.Name("https://a.com/c"))
+		assert.Equal(t, "https://a.com/${}", g.Name("https://a.com/2"))
+		assert.Equal(t, "https://a.com/b", g.Name("https://a.com/b"))
+	})
+}
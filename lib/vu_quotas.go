@@ -0,0 +1,224 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dustin/go-humanize"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// What happens when a VU exceeds one of its quotas.
+const (
+	// Fail the iteration; a VU that keeps too much memory also starts over with a new runtime.
+	VUQuotaPolicyFail = "fail"
+	// Stop the test with an error.
+	VUQuotaPolicyAbort = "abort"
+)
+
+// VUQuotasFields defines the fields used for a VUQuotas; see StageFields for why this is a
+// separate type.
+type VUQuotasFields struct {
+	// The most memory a VU's script may add to its global variables after the init code, eg. "64MB".
+	MaxMemory null.String `json:"maxMemory"`
+
+	// The most connections and files a VU may have open at once.
+	MaxHandles null.Int `json:"maxHandles"`
+
+	// The most CPU time a VU may use in an iteration, eg. "500ms".
+	MaxIterationCPU types.NullDuration `json:"maxIterationCPU"`
+
+	// What to do when a quota is exceeded, VUQuotaPolicyFail by default.
+	Policy null.String `json:"policy"`
+}
+
+// VUQuotas limit what a single VU may use, so that a runaway script, eg. one that keeps every
+// response it gets or loops forever, shows up in the vu_quota_violations metric instead of
+// taking the load generator down.
+type VUQuotas VUQuotasFields
+
+func (q *VUQuotas) UnmarshalJSON(b []byte) error {
+	var fields VUQuotasFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*q = VUQuotas(fields)
+	return q.Validate()
+}
+
+func (q VUQuotas) MarshalJSON() ([]byte, error) {
+	return json.Marshal(VUQuotasFields(q))
+}
+
+// UnmarshalText parses a comma-separated list of key=value pairs, using the same keys as the
+// JSON representation, eg. "maxMemory=64MB,maxIterationCPU=500ms". An empty string unsets it.
+func (q *VUQuotas) UnmarshalText(b []byte) error {
+	var quotas VUQuotas
+	if strings.TrimSpace(string(b)) == "" {
+		*q = quotas
+		return nil
+	}
+	for _, part := range strings.Split(string(b), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("invalid VU quota parameter '%s', expected key=value", part)
+		}
+		key, value := kv[0], kv[1]
+
+		switch key {
+		case "maxMemory":
+			quotas.MaxMemory = null.StringFrom(value)
+		case "maxHandles":
+			i, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errors.Wrapf(err, "VU quota parameter '%s'", key)
+			}
+			quotas.MaxHandles = null.IntFrom(i)
+		case "maxIterationCPU":
+			if err := quotas.MaxIterationCPU.UnmarshalText([]byte(value)); err != nil {
+				return errors.Wrapf(err, "VU quota parameter '%s'", key)
+			}
+		case "policy":
+			quotas.Policy = null.StringFrom(value)
+		default:
+			return errors.Errorf("unknown VU quota parameter '%s'", key)
+		}
+	}
+	*q = quotas
+	return q.Validate()
+}
+
+// Validate checks that at least one quota is set and that all values are in range.
+func (q VUQuotas) Validate() error {
+	if !q.MaxMemory.Valid && !q.MaxHandles.Valid && !q.MaxIterationCPU.Valid {
+		return errors.New("VU quotas need a max memory, a max number of handles, a max iteration CPU time, or several")
+	}
+	if q.MaxMemory.Valid {
+		if _, err := humanize.ParseBytes(q.MaxMemory.String); err != nil {
+			return errors.Wrap(err, "the max memory VU quota")
+		}
+	}
+	if q.MaxHandles.Valid && q.MaxHandles.Int64 <= 0 {
+		return errors.New("the max handles VU quota must be positive")
+	}
+	if q.MaxIterationCPU.Valid && q.MaxIterationCPU.Duration <= 0 {
+		return errors.New("the max iteration CPU VU quota must be positive")
+	}
+	switch q.GetPolicy() {
+	case VUQuotaPolicyFail, VUQuotaPolicyAbort:
+	default:
+		return errors.Errorf("unknown VU quota policy '%s'", q.Policy.String)
+	}
+	return nil
+}
+
+// GetMaxMemory returns the memory quota in bytes, or 0 if there isn't one.
+func (q VUQuotas) GetMaxMemory() uint64 {
+	if !q.MaxMemory.Valid {
+		return 0
+	}
+	b, _ := humanize.ParseBytes(q.MaxMemory.String)
+	return b
+}
+
+// GetPolicy returns the policy, or its default.
+func (q VUQuotas) GetPolicy() string {
+	if q.Policy.Valid {
+		return q.Policy.String
+	}
+	return VUQuotaPolicyFail
+}
+
+// A HandleCounter counts the connections and files a VU has open, and refuses to open more
+// than its max, if it's positive. It also keeps track of the handles that have to be closed if
+// the script doesn't, like files.
+type HandleCounter struct {
+	Max int64
+
+	open       int64
+	violations int64
+
+	mu        sync.Mutex
+	closers   map[int64]func()
+	lastClose int64
+}
+
+// Acquire counts a handle that's about to be opened, or returns an error if the max is reached.
+func (c *HandleCounter) Acquire() error {
+	if n := atomic.AddInt64(&c.open, 1); c.Max > 0 && n > c.Max {
+		atomic.AddInt64(&c.open, -1)
+		atomic.AddInt64(&c.violations, 1)
+		return errors.Errorf("the VU can't have more than %d connections and files open", c.Max)
+	}
+	return nil
+}
+
+// Release uncounts a handle that's been closed, or that failed to open.
+func (c *HandleCounter) Release() {
+	atomic.AddInt64(&c.open, -1)
+}
+
+// Open returns the number of handles that are open.
+func (c *HandleCounter) Open() int64 {
+	return atomic.LoadInt64(&c.open)
+}
+
+// Track registers closer, a function that closes a handle, which CloseAll calls unless untrack is
+// called first, ie. when the handle's closed otherwise.
+func (c *HandleCounter) Track(closer func()) (untrack func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closers == nil {
+		c.closers = make(map[int64]func())
+	}
+	c.lastClose++
+	id := c.lastClose
+	c.closers[id] = closer
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.closers, id)
+	}
+}
+
+// CloseAll closes the handles that are tracked, eg. when the runtime that opened them is thrown
+// away.
+func (c *HandleCounter) CloseAll() {
+	c.mu.Lock()
+	closers := c.closers
+	c.closers = nil
+	c.mu.Unlock()
+	for _, closer := range closers {
+		closer()
+	}
+}
+
+// TakeViolations returns the number of times a handle was refused since it was last called.
+func (c *HandleCounter) TakeViolations() int64 {
+	return atomic.SwapInt64(&c.violations, 0)
+}
@@ -0,0 +1,120 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestVUQuotas(t *testing.T) {
+	full := VUQuotas{
+		MaxMemory:       null.StringFrom("64MB"),
+		MaxHandles:      null.IntFrom(100),
+		MaxIterationCPU: types.NullDurationFrom(500 * time.Millisecond),
+		Policy:          null.StringFrom(VUQuotaPolicyAbort),
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(full)
+		assert.NoError(t, err)
+
+		var quotas VUQuotas
+		assert.NoError(t, json.Unmarshal(data, &quotas))
+		assert.Equal(t, full, quotas)
+
+		assert.EqualError(t, json.Unmarshal([]byte(`{"policy": "fail"}`), &quotas),
+			"VU quotas need a max memory, a max number of handles, a max iteration CPU time, or several")
+	})
+	t.Run("Text", func(t *testing.T) {
+		var quotas VUQuotas
+		assert.NoError(t, quotas.UnmarshalText([]byte("maxMemory=64MB,maxHandles=100,maxIterationCPU=500ms,policy=abort")))
+		assert.Equal(t, full, quotas)
+
+		assert.EqualError(t, quotas.UnmarshalText([]byte("maxMemory")),
+			"invalid VU quota parameter 'maxMemory', expected key=value")
+		assert.EqualError(t, quotas.UnmarshalText([]byte("maxThreads=1")),
+			"unknown VU quota parameter 'maxThreads'")
+		if err := quotas.UnmarshalText([]byte("maxMemory=lots")); assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "the max memory VU quota: ")
+		}
+		if err := quotas.UnmarshalText([]byte("maxIterationCPU=lots")); assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "VU quota parameter 'maxIterationCPU': ")
+		}
+		assert.EqualError(t, quotas.UnmarshalText([]byte("maxHandles=0")),
+			"the max handles VU quota must be positive")
+		assert.EqualError(t, quotas.UnmarshalText([]byte("maxIterationCPU=0s")),
+			"the max iteration CPU VU quota must be positive")
+		assert.EqualError(t, quotas.UnmarshalText([]byte("maxHandles=1,policy=shed")),
+			"unknown VU quota policy 'shed'")
+	})
+	t.Run("Defaults", func(t *testing.T) {
+		quotas := VUQuotas{MaxHandles: null.IntFrom(1)}
+		assert.Equal(t, uint64(0), quotas.GetMaxMemory())
+		assert.Equal(t, VUQuotaPolicyFail, quotas.GetPolicy())
+		assert.Equal(t, uint64(64000000), full.GetMaxMemory())
+	})
+}
+
+func TestHandleCounter(t *testing.T) {
+	c := &HandleCounter{Max: 10}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = c.Acquire()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(10), c.Open())
+	assert.Equal(t, int64(10), c.TakeViolations())
+	assert.Equal(t, int64(0), c.TakeViolations())
+
+	assert.EqualError(t, c.Acquire(), "the VU can't have more than 10 connections and files open")
+	c.Release()
+	assert.NoError(t, c.Acquire())
+
+	unlimited := &HandleCounter{}
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, unlimited.Acquire())
+	}
+	assert.Equal(t, int64(100), unlimited.Open())
+}
+
+func TestHandleCounterCloseAll(t *testing.T) {
+	c := &HandleCounter{}
+	var closed []string
+	c.Track(func() { closed = append(closed, "a") })
+	untrack := c.Track(func() { closed = append(closed, "b") })
+	untrack()
+	c.CloseAll()
+	assert.Equal(t, []string{"a"}, closed)
+
+	c.CloseAll()
+	assert.Equal(t, []string{"a"}, closed)
+}
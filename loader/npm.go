@@ -0,0 +1,304 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package loader
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+const (
+	nodeModules     = "node_modules"
+	packageJSON     = "package.json"
+	packageLockJSON = "package-lock.json"
+)
+
+// nodeBuiltins are the modules built into Node.js, which npm packages may require but k6 doesn't
+// have, since it's not Node.js.
+var nodeBuiltins = map[string]bool{
+	"assert": true, "async_hooks": true, "buffer": true, "child_process": true, "cluster": true,
+	"console": true, "constants": true, "crypto": true, "dgram": true, "dns": true, "domain": true,
+	"events": true, "fs": true, "http": true, "http2": true, "https": true, "inspector": true,
+	"module": true, "net": true, "os": true, "path": true, "perf_hooks": true, "process": true,
+	"punycode": true, "querystring": true, "readline": true, "repl": true, "stream": true,
+	"string_decoder": true, "timers": true, "tls": true, "tty": true, "url": true, "util": true,
+	"v8": true, "vm": true, "worker_threads": true, "zlib": true,
+}
+
+// packageExportConditions are the conditions of the "exports" of a package.json that are used,
+// in order of preference.
+var packageExportConditions = []string{"require", "default", "import"}
+
+// A ReadFileFunc reads a file; it lets the caller of ResolvePackage keep the package.json and
+// lockfiles it reads, eg. to bundle them in archives.
+type ReadFileFunc func(filename string) ([]byte, error)
+
+type packageManifest struct {
+	Name    string          `json:"name"`
+	Version string          `json:"version"`
+	Main    string          `json:"main"`
+	Exports json.RawMessage `json:"exports"`
+	Gypfile bool            `json:"gypfile"`
+}
+
+type packageLock struct {
+	Packages     map[string]packageLockEntry `json:"packages"`
+	Dependencies map[string]packageLockEntry `json:"dependencies"`
+}
+
+type packageLockEntry struct {
+	Version      string                      `json:"version"`
+	Dependencies map[string]packageLockEntry `json:"dependencies"`
+}
+
+// ResolvePackage resolves an import the way Node.js does for pure JS npm packages: a bare name,
+// eg. "lodash" or "lodash/fp", is looked up in the node_modules directories from pwd up, and
+// relative imports inside node_modules may leave out the ".js" extension or "/index.js". If a
+// package-lock.json is next to node_modules, the installed version must be the one it pins.
+//
+// It returns the absolute path of the file to load, or an empty string if the import isn't for
+// a package, eg. for local files and remote URLs, which are loaded as always.
+func ResolvePackage(fs afero.Fs, readFile ReadFileFunc, pwd, name string) (string, error) {
+	if name == "" || pwd == "" || (pwd[0] != '/' && filepath.VolumeName(pwd) == "") {
+		return "", nil
+	}
+	pwd = filepath.ToSlash(pwd)
+
+	if name[0] == '.' {
+		if !isInNodeModules(pwd) {
+			return "", nil
+		}
+		return resolvePackageFile(fs, readFile, path.Join(pwd, name))
+	}
+	if name[0] == '/' || filepath.VolumeName(name) != "" || strings.Contains(name, "://") {
+		return "", nil
+	}
+
+	pkgName, subpath := splitPackageName(name)
+	for dir := pwd; ; dir = path.Dir(dir) {
+		if path.Base(dir) != nodeModules {
+			pkgDir := path.Join(dir, nodeModules, pkgName)
+			if isDir(fs, pkgDir) {
+				return resolveInPackage(fs, readFile, pkgDir, pkgName, subpath)
+			}
+		}
+		if parent := path.Dir(dir); parent == dir || parent == "." {
+			break
+		}
+	}
+
+	if nodeBuiltins[strings.TrimPrefix(pkgName, "node:")] {
+		return "", errors.Errorf("'%s' is a Node.js built-in module, which isn't available in k6", name)
+	}
+	return "", nil
+}
+
+// splitPackageName splits an import into the package name, which is scoped, eg. "@scope/pkg",
+// or not, and the path of the imported file inside of it, if any.
+func splitPackageName(name string) (string, string) {
+	parts := strings.SplitN(name, "/", 3)
+	if strings.HasPrefix(name, "@") && len(parts) > 1 {
+		if len(parts) == 3 {
+			return parts[0] + "/" + parts[1], parts[2]
+		}
+		return name, ""
+	}
+	if len(parts) == 1 {
+		return name, ""
+	}
+	return parts[0], strings.Join(parts[1:], "/")
+}
+
+func resolveInPackage(fs afero.Fs, readFile ReadFileFunc, pkgDir, pkgName, subpath string) (string, error) {
+	manifest, err := readPackageManifest(readFile, pkgDir)
+	if err != nil {
+		return "", err
+	}
+	if manifest == nil {
+		manifest = &packageManifest{}
+	}
+	if manifest.Gypfile || isFile(fs, path.Join(pkgDir, "binding.gyp")) {
+		return "", errors.Errorf("the npm package '%s' has a native addon, only pure JS packages can be imported", pkgName)
+	}
+	if err := checkPackageLock(readFile, pkgDir, pkgName, manifest.Version); err != nil {
+		return "", err
+	}
+
+	if target := packageExport(manifest.Exports, subpath); target != "" {
+		return resolvePackageFile(fs, readFile, path.Join(pkgDir, target))
+	}
+	if subpath != "" {
+		return resolvePackageFile(fs, readFile, path.Join(pkgDir, subpath))
+	}
+	main := manifest.Main
+	if main == "" {
+		main = "index.js"
+	}
+	return resolvePackageFile(fs, readFile, path.Join(pkgDir, main))
+}
+
+// packageExport returns the file a package.json "exports" maps a subpath to, if it does.
+func packageExport(raw json.RawMessage, subpath string) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	key := "."
+	if subpath != "" {
+		key = "./" + subpath
+	}
+
+	var exports interface{}
+	if err := json.Unmarshal(raw, &exports); err != nil {
+		return ""
+	}
+	if m, ok := exports.(map[string]interface{}); ok {
+		// Either a map of subpaths, or of conditions for ".".
+		isSubpaths := false
+		for k := range m {
+			isSubpaths = isSubpaths || strings.HasPrefix(k, ".")
+		}
+		if isSubpaths {
+			exports = m[key]
+		} else if key != "." {
+			return ""
+		}
+	} else if key != "." {
+		return ""
+	}
+	return exportTarget(exports)
+}
+
+func exportTarget(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		for _, cond := range packageExportConditions {
+			if target := exportTarget(v[cond]); target != "" {
+				return target
+			}
+		}
+	}
+	return ""
+}
+
+// resolvePackageFile resolves a file in a package like Node.js does, trying the name as it is,
+// with a ".js" extension, and as a directory with a package.json or an index.js.
+func resolvePackageFile(fs afero.Fs, readFile ReadFileFunc, name string) (string, error) {
+	for _, candidate := range []string{name, name + ".js", name + ".cjs"} {
+		if isFile(fs, candidate) {
+			return candidate, nil
+		}
+	}
+	if isDir(fs, name) {
+		manifest, err := readPackageManifest(readFile, name)
+		if err != nil {
+			return "", err
+		}
+		if manifest != nil && manifest.Main != "" {
+			return resolvePackageFile(fs, readFile, path.Join(name, manifest.Main))
+		}
+		if index := path.Join(name, "index.js"); isFile(fs, index) {
+			return index, nil
+		}
+	}
+	return "", errors.Errorf("couldn't find '%s' in the npm package", name)
+}
+
+func readPackageManifest(readFile ReadFileFunc, dir string) (*packageManifest, error) {
+	filename := path.Join(dir, packageJSON)
+	data, err := readFile(filename)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var manifest packageManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", filename)
+	}
+	return &manifest, nil
+}
+
+// checkPackageLock checks that the version of an installed package is the one the lockfile of
+// the project, next to its top node_modules directory, pins, if there is one.
+func checkPackageLock(readFile ReadFileFunc, pkgDir, pkgName, version string) error {
+	idx := strings.Index(pkgDir, "/"+nodeModules+"/")
+	root, rel := pkgDir[:idx], pkgDir[idx+1:]
+	filename := path.Join(root, packageLockJSON)
+	data, err := readFile(filename)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil
+		}
+		return err
+	}
+	var lock packageLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return errors.Wrapf(err, "invalid %s", filename)
+	}
+
+	// Lockfiles since npm 7 list packages by their path, older ones nest their dependencies.
+	var pinned string
+	if entry, ok := lock.Packages[rel]; ok {
+		pinned = entry.Version
+	} else if lock.Packages == nil {
+		deps := lock.Dependencies
+		for _, name := range strings.Split(strings.TrimPrefix(rel, nodeModules+"/"), "/"+nodeModules+"/") {
+			entry, ok := deps[name]
+			if !ok {
+				pinned = ""
+				break
+			}
+			pinned, deps = entry.Version, entry.Dependencies
+		}
+	}
+
+	if pinned == "" {
+		return errors.Errorf("the npm package '%s' isn't in %s, run 'npm install'", pkgName, filename)
+	}
+	if pinned != version {
+		return errors.Errorf("the npm package '%s' is installed at version %s, but %s pins %s, run 'npm ci'",
+			pkgName, version, filename, pinned)
+	}
+	return nil
+}
+
+func isInNodeModules(dir string) bool {
+	return strings.Contains(dir+"/", "/"+nodeModules+"/")
+}
+
+func isFile(fs afero.Fs, name string) bool {
+	fi, err := fs.Stat(name)
+	return err == nil && !fi.IsDir()
+}
+
+func isDir(fs afero.Fs, name string) bool {
+	fi, err := fs.Stat(name)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,164 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package loader
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPackageFS(t *testing.T, files map[string]string) afero.Fs {
+	fs := afero.NewMemMapFs()
+	for name, data := range files {
+		require.NoError(t, afero.WriteFile(fs, name, []byte(data), 0644))
+	}
+	return fs
+}
+
+func readFileFrom(fs afero.Fs) ReadFileFunc {
+	return func(filename string) ([]byte, error) {
+		return afero.ReadFile(fs, filename)
+	}
+}
+
+func TestResolvePackage(t *testing.T) {
+	fs := newPackageFS(t, map[string]string{
+		"/proj/node_modules/lodash/package.json": `{"name": "lodash", "version": "4.17.21", "main": "lodash.js"}`,
+		"/proj/node_modules/lodash/lodash.js":    `module.exports = {};`,
+		"/proj/node_modules/lodash/fp.js":        `module.exports = {};`,
+		"/proj/node_modules/lodash/fp/map.js":    `module.exports = {};`,
+
+		"/proj/node_modules/zod/package.json": `{"name": "zod", "version": "3.22.4", "exports": {
+			".": {"import": "./lib/index.mjs", "require": "./lib/index.js"},
+			"./locales/en": "./lib/locales/en.js"
+		}}`,
+		"/proj/node_modules/zod/lib/index.js":         `require("./types"); require("./helpers");`,
+		"/proj/node_modules/zod/lib/types.js":         ``,
+		"/proj/node_modules/zod/lib/helpers/index.js": ``,
+		"/proj/node_modules/zod/lib/locales/en.js":    ``,
+
+		"/proj/node_modules/@scope/util/package.json":      `{"version": "1.0.0", "exports": "./main.js"}`,
+		"/proj/node_modules/@scope/util/main.js":           ``,
+		"/proj/node_modules/noversion/index.js":            ``,
+		"/proj/node_modules/native/package.json":           `{"version": "1.0.0", "gypfile": true}`,
+		"/proj/node_modules/native/index.js":               ``,
+		"/proj/node_modules/events/package.json":           `{"version": "3.3.0"}`,
+		"/proj/node_modules/events/events.js":              ``,
+		"/proj/node_modules/events/index.js":               ``,
+		"/proj/node_modules/dep/package.json":              `{"version": "1.0.0"}`,
+		"/proj/node_modules/dep/index.js":                  ``,
+		"/proj/node_modules/dep/node_modules/sub/index.js": ``,
+		"/proj/tests/script.js":                            ``,
+	})
+
+	testdata := map[string]struct{ pwd, name, filename string }{
+		"main":           {"/proj", "lodash", "/proj/node_modules/lodash/lodash.js"},
+		"subpath":        {"/proj", "lodash/fp", "/proj/node_modules/lodash/fp.js"},
+		"subpath file":   {"/proj", "lodash/fp/map.js", "/proj/node_modules/lodash/fp/map.js"},
+		"parent dir":     {"/proj/tests", "lodash", "/proj/node_modules/lodash/lodash.js"},
+		"exports":        {"/proj", "zod", "/proj/node_modules/zod/lib/index.js"},
+		"exports path":   {"/proj", "zod/locales/en", "/proj/node_modules/zod/lib/locales/en.js"},
+		"exports string": {"/proj", "@scope/util", "/proj/node_modules/@scope/util/main.js"},
+		"index":          {"/proj", "noversion", "/proj/node_modules/noversion/index.js"},
+		"polyfill":       {"/proj", "events", "/proj/node_modules/events/index.js"},
+		"relative":       {"/proj/node_modules/zod/lib", "./types", "/proj/node_modules/zod/lib/types.js"},
+		"relative dir":   {"/proj/node_modules/zod/lib", "./helpers", "/proj/node_modules/zod/lib/helpers/index.js"},
+		"nested":         {"/proj/node_modules/dep", "sub", "/proj/node_modules/dep/node_modules/sub/index.js"},
+		"nested parent":  {"/proj/node_modules/dep", "lodash", "/proj/node_modules/lodash/lodash.js"},
+		"local file":     {"/proj", "./tests/script.js", ""},
+		"absolute file":  {"/proj", "/proj/tests/script.js", ""},
+		"remote":         {"/proj", "github.com/loadimpact/k6/samples/http_get.js", ""},
+		"remote script":  {"github.com/loadimpact/k6/samples", "lodash", ""},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			filename, err := ResolvePackage(fs, readFileFrom(fs), data.pwd, data.name)
+			require.NoError(t, err)
+			assert.Equal(t, data.filename, filename)
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		errors := map[string]struct{ pwd, name, err string }{
+			"native":  {"/proj", "native", "the npm package 'native' has a native addon, only pure JS packages can be imported"},
+			"builtin": {"/proj", "fs", "'fs' is a Node.js built-in module, which isn't available in k6"},
+			"node":    {"/proj", "node:crypto", "'node:crypto' is a Node.js built-in module, which isn't available in k6"},
+			"missing": {"/proj", "lodash/missing", "couldn't find '/proj/node_modules/lodash/missing' in the npm package"},
+		}
+		for name, data := range errors {
+			t.Run(name, func(t *testing.T) {
+				_, err := ResolvePackage(fs, readFileFrom(fs), data.pwd, data.name)
+				assert.EqualError(t, err, data.err)
+			})
+		}
+	})
+}
+
+func TestResolvePackageLock(t *testing.T) {
+	files := map[string]string{
+		"/proj/node_modules/lodash/package.json":                  `{"version": "4.17.21"}`,
+		"/proj/node_modules/lodash/index.js":                      ``,
+		"/proj/node_modules/dep/package.json":                     `{"version": "1.0.0"}`,
+		"/proj/node_modules/dep/index.js":                         ``,
+		"/proj/node_modules/dep/node_modules/lodash/package.json": `{"version": "3.10.1"}`,
+		"/proj/node_modules/dep/node_modules/lodash/index.js":     ``,
+		"/proj/node_modules/unlocked/package.json":                `{"version": "1.0.0"}`,
+		"/proj/node_modules/unlocked/index.js":                    ``,
+	}
+
+	lockfiles := map[string]string{
+		"v3": `{"lockfileVersion": 3, "packages": {
+			"": {"name": "proj"},
+			"node_modules/lodash": {"version": "4.17.21"},
+			"node_modules/dep": {"version": "1.0.1"},
+			"node_modules/dep/node_modules/lodash": {"version": "3.10.1"}
+		}}`,
+		"v1": `{"lockfileVersion": 1, "dependencies": {
+			"lodash": {"version": "4.17.21"},
+			"dep": {"version": "1.0.1", "dependencies": {"lodash": {"version": "3.10.1"}}}
+		}}`,
+	}
+	for version, lockfile := range lockfiles {
+		t.Run(version, func(t *testing.T) {
+			files["/proj/package-lock.json"] = lockfile
+			fs := newPackageFS(t, files)
+			readFile := readFileFrom(fs)
+
+			filename, err := ResolvePackage(fs, readFile, "/proj", "lodash")
+			require.NoError(t, err)
+			assert.Equal(t, "/proj/node_modules/lodash/index.js", filename)
+
+			filename, err = ResolvePackage(fs, readFile, "/proj/node_modules/dep", "lodash")
+			require.NoError(t, err)
+			assert.Equal(t, "/proj/node_modules/dep/node_modules/lodash/index.js", filename)
+
+			_, err = ResolvePackage(fs, readFile, "/proj", "dep")
+			assert.EqualError(t, err, "the npm package 'dep' is installed at version 1.0.0, "+
+				"but /proj/package-lock.json pins 1.0.1, run 'npm ci'")
+
+			_, err = ResolvePackage(fs, readFile, "/proj", "unlocked")
+			assert.EqualError(t, err, "the npm package 'unlocked' isn't in /proj/package-lock.json, run 'npm install'")
+		})
+	}
+}
@@ -0,0 +1,114 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// baselinePercentiles are the percentiles stored for trend metrics in a baseline; others are
+// interpolated between them.
+var baselinePercentiles = func() []float64 {
+	pcts := make([]float64, 0, 101)
+	for pct := 1.0; pct < 100; pct++ {
+		pcts = append(pcts, pct)
+	}
+	return append(pcts, 99.9, 99.99)
+}()
+
+// Baseline holds the values of the metrics of a previous test run, for thresholds to be
+// expressed relative to, eg. "p(95) < baseline.p(95) * 1.1".
+type Baseline struct {
+	Created time.Time                 `json:"created"`
+	Metrics map[string]BaselineValues `json:"metrics"`
+}
+
+// BaselineValues are the values of a single metric in a baseline: the ones in its sink's
+// Format(), plus a range of percentiles for trends, as "p(N)".
+type BaselineValues map[string]float64
+
+// NewBaseline creates a baseline from the given metrics, at the given time into the test.
+func NewBaseline(metrics map[string]*Metric, t time.Duration) Baseline {
+	b := Baseline{Created: time.Now(), Metrics: make(map[string]BaselineValues, len(metrics))}
+	for name, m := range metrics {
+		values := BaselineValues{}
+		for k, v := range m.Sink.Format(t) {
+			// JSON can't represent these; thresholds comparing against them would fail anyway.
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				values[k] = v
+			}
+		}
+		if sink, ok := m.Sink.(PercentileSink); ok {
+			for _, pct := range baselinePercentiles {
+				values[percentileKey(pct)] = sink.P(pct / 100)
+			}
+		}
+		b.Metrics[name] = values
+	}
+	return b
+}
+
+func percentileKey(pct float64) string {
+	return fmt.Sprintf("p(%g)", pct)
+}
+
+// P returns the given percentile (0-100), interpolated linearly between the stored ones.
+func (v BaselineValues) P(pct float64) float64 {
+	if value, ok := v[percentileKey(pct)]; ok {
+		return value
+	}
+
+	pcts := make([]float64, 0, len(baselinePercentiles))
+	for _, p := range baselinePercentiles {
+		if _, ok := v[percentileKey(p)]; ok {
+			pcts = append(pcts, p)
+		}
+	}
+	if len(pcts) == 0 {
+		return v["med"]
+	}
+	sort.Float64s(pcts)
+
+	i := sort.SearchFloat64s(pcts, pct)
+	switch {
+	case i == 0:
+		return v[percentileKey(pcts[0])]
+	case i == len(pcts):
+		return v[percentileKey(pcts[len(pcts)-1])]
+	}
+	lo, hi := pcts[i-1], pcts[i]
+	loV, hiV := v[percentileKey(lo)], v[percentileKey(hi)]
+	return loV + (hiV-loV)*(pct-lo)/(hi-lo)
+}
+
+// jsObject returns the values as an object for the thresholds' JS runtime, with a p() function
+// that works like the one for the current values.
+func (v BaselineValues) jsObject() map[string]interface{} {
+	obj := make(map[string]interface{}, len(v)+1)
+	for k, value := range v {
+		obj[k] = value
+	}
+	obj["p"] = v.P
+	return obj
+}
@@ -0,0 +1,68 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBaseline(t *testing.T) {
+	trend := New("trend", Trend)
+	for i := 1; i <= 1000; i++ {
+		trend.Sink.Add(Sample{Value: float64(i)})
+	}
+	counter := New("counter", Counter)
+	counter.Sink.Add(Sample{Value: 10})
+	gauge := New("gauge", Gauge)
+
+	b := NewBaseline(map[string]*Metric{"trend": trend, "counter": counter, "gauge": gauge}, 10*time.Second)
+	require.Len(t, b.Metrics, 3)
+	assert.Equal(t, 500.5, b.Metrics["trend"]["avg"])
+	assert.InDelta(t, 950, b.Metrics["trend"]["p(95)"], 1)
+	assert.InDelta(t, 999, b.Metrics["trend"]["p(99.9)"], 1)
+	assert.Equal(t, 10.0, b.Metrics["counter"]["count"])
+	assert.Equal(t, 1.0, b.Metrics["counter"]["rate"])
+	assert.NotContains(t, b.Metrics["counter"], "p(95)")
+
+	_, err := json.Marshal(b)
+	assert.NoError(t, err)
+}
+
+func TestNewBaselineNonFinite(t *testing.T) {
+	b := NewBaseline(map[string]*Metric{"m": {Name: "m", Sink: DummySink{"nan": math.NaN(), "inf": math.Inf(1), "ok": 1}}}, 0)
+	assert.Equal(t, BaselineValues{"ok": 1}, b.Metrics["m"])
+}
+
+func TestBaselineValuesP(t *testing.T) {
+	v := BaselineValues{"med": 5, "p(90)": 90, "p(95)": 100, "p(99)": 200}
+	assert.Equal(t, 100.0, v.P(95))
+	assert.Equal(t, 94.0, v.P(92))
+	assert.Equal(t, 125.0, v.P(96))
+	assert.Equal(t, 90.0, v.P(50))
+	assert.Equal(t, 200.0, v.P(99.9))
+	assert.Equal(t, 5.0, BaselineValues{"med": 5}.P(95))
+}
@@ -0,0 +1,243 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Identifiers in threshold expressions that aren't metric references.
+var expressionGlobals = map[string]bool{
+	"Math":      true,
+	"true":      true,
+	"false":     true,
+	"null":      true,
+	"undefined": true,
+	"Infinity":  true,
+	"NaN":       true,
+}
+
+// MetricRef is a reference to a metric's value in a threshold expression, eg.
+// `http_reqs{scenario:checkout}` or `http_req_duration.p(99)`.
+type MetricRef struct {
+	Metric string // Metric or submetric name.
+	Stat   string // A key in the metric's Format(), or empty for its main value.
+}
+
+func (r MetricRef) String() string {
+	if r.Stat == "" {
+		return r.Metric
+	}
+	return r.Metric + "." + r.Stat
+}
+
+// Value returns the referenced value of the given metric.
+func (r MetricRef) Value(m *Metric, t time.Duration) float64 {
+	stat := r.Stat
+	if stat == "" {
+		switch m.Type {
+		case Counter:
+			stat = "count"
+		case Gauge:
+			stat = "value"
+		case Rate:
+			stat = "rate"
+		default:
+			stat = "avg"
+		}
+	}
+
+	if sink, ok := m.Sink.(PercentileSink); ok && strings.HasPrefix(stat, "p(") && strings.HasSuffix(stat, ")") {
+		if pct, err := strconv.ParseFloat(stat[2:len(stat)-1], 64); err == nil {
+			return sink.P(pct / 100)
+		}
+	}
+	if v, ok := m.Sink.Format(t)[stat]; ok {
+		return v
+	}
+	return math.NaN()
+}
+
+func expressionRefVar(i int) string {
+	return fmt.Sprintf("__ref%d__", i)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// parseThresholdExpression replaces the metric references in src with JS variables, adding any
+// new ones to refs. References are a metric name, optionally followed by submetric tags in curly
+// braces and a `.stat`, eg. `http_req_duration{status:200}.p(95)`.
+func parseThresholdExpression(src string, refs []MetricRef) (string, []MetricRef, error) {
+	var out []byte
+	for i := 0; i < len(src); {
+		c := src[i]
+		if !isIdentStart(c) || (i > 0 && (isIdentChar(src[i-1]) || src[i-1] == '.')) {
+			out = append(out, c)
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(src) && isIdentChar(src[i]) {
+			i++
+		}
+		if expressionGlobals[src[start:i]] {
+			out = append(out, src[start:i]...)
+			continue
+		}
+
+		if i < len(src) && src[i] == '{' {
+			end := strings.IndexByte(src[i:], '}')
+			if end == -1 {
+				return "", nil, errors.Errorf("unterminated tags for metric '%s'", src[start:i])
+			}
+			i += end + 1
+		}
+		ref := MetricRef{Metric: src[start:i]}
+
+		if i+1 < len(src) && src[i] == '.' && isIdentStart(src[i+1]) {
+			statStart := i + 1
+			for i++; i < len(src) && isIdentChar(src[i]); i++ {
+			}
+			if src[statStart:i] == "p" && i < len(src) && src[i] == '(' {
+				end := strings.IndexByte(src[i:], ')')
+				if end == -1 {
+					return "", nil, errors.Errorf("unterminated percentile for metric '%s'", ref.Metric)
+				}
+				i += end + 1
+			}
+			ref.Stat = src[statStart:i]
+		}
+
+		idx := -1
+		for j, r := range refs {
+			if r == ref {
+				idx = j
+				break
+			}
+		}
+		if idx == -1 {
+			idx = len(refs)
+			refs = append(refs, ref)
+		}
+		out = append(out, expressionRefVar(idx)...)
+	}
+	return string(out), refs, nil
+}
+
+// ThresholdExpressions are thresholds that combine the values of several metrics, eg.
+// `http_req_failed{scenario:checkout} / http_reqs{scenario:checkout} < 0.01`.
+type ThresholdExpressions struct {
+	Thresholds
+	Refs []MetricRef
+}
+
+// NewThresholdExpressions returns ThresholdExpressions representing the provided sources.
+func NewThresholdExpressions(sources []string) (ThresholdExpressions, error) {
+	tcs := make([]thresholdConfig, len(sources))
+	for i, source := range sources {
+		tcs[i].Threshold = source
+	}
+
+	return newThresholdExpressionsWithConfig(tcs)
+}
+
+func newThresholdExpressionsWithConfig(configs []thresholdConfig) (ThresholdExpressions, error) {
+	var refs []MetricRef
+	parsed := make([]thresholdConfig, len(configs))
+	for i, config := range configs {
+		src, newRefs, err := parseThresholdExpression(config.Threshold, refs)
+		if err != nil {
+			return ThresholdExpressions{}, errors.Wrapf(err, "%d", i)
+		}
+		refs = newRefs
+		parsed[i] = config
+		parsed[i].Threshold = src
+	}
+
+	ts, err := newThresholdsWithConfig(parsed)
+	if err != nil {
+		return ThresholdExpressions{}, err
+	}
+	for i, t := range ts.Thresholds {
+		t.Source = configs[i].Threshold
+	}
+	return ThresholdExpressions{ts, refs}, nil
+}
+
+// UnmarshalJSON is implementation of json.Unmarshaler
+func (te *ThresholdExpressions) UnmarshalJSON(data []byte) error {
+	var configs []thresholdConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return err
+	}
+	newte, err := newThresholdExpressionsWithConfig(configs)
+	if err != nil {
+		return err
+	}
+	*te = newte
+	return nil
+}
+
+var _ json.Unmarshaler = &ThresholdExpressions{}
+var _ Sink = &ExpressionSink{}
+
+// ExpressionSink provides the values of the metrics referenced by threshold expressions. It
+// doesn't take any samples itself.
+type ExpressionSink struct {
+	Refs    []MetricRef
+	Metrics []*Metric
+}
+
+// Add is a no-op, the sink's values come from the referenced metrics.
+func (e *ExpressionSink) Add(s Sample) {}
+
+func (e *ExpressionSink) Calc() {
+	for _, m := range e.Metrics {
+		m.Sink.Calc()
+	}
+}
+
+// Value returns the current value of the i-th reference.
+func (e *ExpressionSink) Value(i int, t time.Duration) float64 {
+	return e.Refs[i].Value(e.Metrics[i], t)
+}
+
+func (e *ExpressionSink) Format(t time.Duration) map[string]float64 {
+	f := make(map[string]float64, len(e.Refs))
+	for i := range e.Refs {
+		f[expressionRefVar(i)] = e.Value(i, t)
+	}
+	return f
+}
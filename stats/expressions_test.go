@@ -0,0 +1,116 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseThresholdExpression(t *testing.T) {
+	testdata := map[string]struct {
+		src  string
+		js   string
+		refs []MetricRef
+	}{
+		"metrics": {
+			"failed / reqs < 0.01",
+			"__ref0__ / __ref1__ < 0.01",
+			[]MetricRef{{Metric: "failed"}, {Metric: "reqs"}},
+		},
+		"submetrics": {
+			"http_req_failed{scenario:checkout} / http_reqs{scenario:checkout} < 1e-2",
+			"__ref0__ / __ref1__ < 1e-2",
+			[]MetricRef{{Metric: "http_req_failed{scenario:checkout}"}, {Metric: "http_reqs{scenario:checkout}"}},
+		},
+		"stats": {
+			"a.count > 0 && b{x:y}.p(99.9) < Math.max(a.p(95), 100)",
+			"__ref0__ > 0 && __ref1__ < Math.max(__ref2__, 100)",
+			[]MetricRef{{Metric: "a", Stat: "count"}, {Metric: "b{x:y}", Stat: "p(99.9)"}, {Metric: "a", Stat: "p(95)"}},
+		},
+		"repeated": {
+			"a / (a + b) < 0.5 || b == 0",
+			"__ref0__ / (__ref0__ + __ref1__) < 0.5 || __ref1__ == 0",
+			[]MetricRef{{Metric: "a"}, {Metric: "b"}},
+		},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			js, refs, err := parseThresholdExpression(data.src, nil)
+			require.NoError(t, err)
+			assert.Equal(t, data.js, js)
+			assert.Equal(t, data.refs, refs)
+		})
+	}
+
+	t.Run("unterminated", func(t *testing.T) {
+		_, _, err := parseThresholdExpression("a{x:y < 1", nil)
+		assert.EqualError(t, err, "unterminated tags for metric 'a'")
+		_, _, err = parseThresholdExpression("a.p(99 < 1", nil)
+		assert.EqualError(t, err, "unterminated percentile for metric 'a'")
+	})
+}
+
+func TestThresholdExpressions(t *testing.T) {
+	reqs := New("reqs", Counter)
+	failed := New("failed", Rate)
+	duration := New("duration", Trend)
+	for i := 0; i < 10; i++ {
+		reqs.Sink.Add(Sample{Value: 1})
+		failed.Sink.Add(Sample{Value: float64(i % 2)})
+		duration.Sink.Add(Sample{Value: float64(i)})
+	}
+
+	exprs, err := NewThresholdExpressions([]string{
+		"failed * reqs == 5",
+		"duration > 4 && duration.p(90) > 8 && duration.max == 9",
+		"duration.nope < 1",
+	})
+	require.NoError(t, err)
+	require.Len(t, exprs.Refs, 6)
+
+	metrics := []*Metric{failed, reqs, duration, duration, duration, duration}
+	sink := &ExpressionSink{Refs: exprs.Refs, Metrics: metrics}
+	assert.True(t, math.IsNaN(sink.Value(5, 0)))
+
+	ok, err := exprs.Run(sink, 0)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, exprs.Thresholds.Thresholds[0].LastFailed)
+	assert.False(t, exprs.Thresholds.Thresholds[1].LastFailed)
+	assert.True(t, exprs.Thresholds.Thresholds[2].LastFailed)
+
+	t.Run("JSON", func(t *testing.T) {
+		var te ThresholdExpressions
+		src := `["a{x:y} / b < 1",{"threshold":"a > 1","abortOnFail":true}]`
+		require.NoError(t, json.Unmarshal([]byte(src), &te))
+		assert.Equal(t, []MetricRef{{Metric: "a{x:y}"}, {Metric: "b"}, {Metric: "a"}}, te.Refs)
+		assert.True(t, te.Thresholds.Thresholds[1].AbortOnFail)
+
+		data, err := json.Marshal(te)
+		require.NoError(t, err)
+		assert.JSONEq(t, `["a{x:y} / b < 1",{"threshold":"a > 1","abortOnFail":true,"delayAbortEval":null}]`, string(data))
+	})
+}
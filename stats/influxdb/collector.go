@@ -152,7 +152,17 @@ func (c *Collector) batchFromSamples(samples []stats.Sample) (client.BatchPoints
 		} else {
 			tags = sample.Tags.CloneTags()
 			c.extractTagsToValues(tags, values)
-			cache[sample.Tags] = cacheItem{tags, values}
+			cached := cacheItem{tags, make(map[string]interface{}, len(values))}
+			for k, v := range values {
+				cached.values[k] = v
+			}
+			cache[sample.Tags] = cached
+		}
+		// Metadata isn't indexed, so it's stored in fields, without replacing the other ones.
+		for k, v := range sample.Metadata {
+			if _, ok := values[k]; !ok && k != "value" {
+				values[k] = v
+			}
 		}
 		values["value"] = sample.Value
 		p, err := client.NewPoint(
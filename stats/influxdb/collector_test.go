@@ -0,0 +1,53 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatMetadata(t *testing.T) {
+	metric := stats.New("my_metric", stats.Trend)
+	tags := stats.NewSampleTags(map[string]string{"status": "200"})
+	samples := []stats.Sample{
+		{
+			Metric:   metric,
+			Time:     time.Unix(1, 0),
+			Value:    1,
+			Tags:     tags,
+			Metadata: map[string]string{"row": "5", "value": "ignored"},
+		},
+		{Metric: metric, Time: time.Unix(2, 0), Value: 2, Tags: tags},
+	}
+
+	c := &Collector{}
+	lines, err := c.Format(samples)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`my_metric,status=200 row="5",value=1 1000000000`,
+		`my_metric,status=200 value=2 2000000000`,
+	}, lines)
+}
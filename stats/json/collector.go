@@ -21,10 +21,15 @@
 package json
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/stats"
@@ -33,9 +38,16 @@ import (
 )
 
 type Collector struct {
+	fs          afero.Fs
+	conf        Config
 	outfile     io.WriteCloser
 	fname       string
 	seenMetrics []string
+
+	// Rotation state; the size is counted after compression.
+	maxFileSize int64
+	fileIndex   int
+	written     *countingWriter
 }
 
 // Verify that Collector implements lib.Collector
@@ -48,6 +60,32 @@ type nopCloser struct {
 
 func (nopCloser) Close() error { return nil }
 
+// Counts the bytes written through it, to know when to rotate the output file.
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// Closes the gzip stream before the file under it.
+type gzipFile struct {
+	*gzip.Writer
+	file io.Closer
+}
+
+func (f gzipFile) Close() error {
+	if err := f.Writer.Close(); err != nil {
+		_ = f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
+
 func (c *Collector) HasSeenMetric(str string) bool {
 	for _, n := range c.seenMetrics {
 		if n == str {
@@ -57,22 +95,82 @@ func (c *Collector) HasSeenMetric(str string) bool {
 	return false
 }
 
-func New(fs afero.Fs, fname string) (*Collector, error) {
-	if fname == "" || fname == "-" {
-		return &Collector{
-			outfile: nopCloser{os.Stdout},
-			fname:   "-",
-		}, nil
+func New(fs afero.Fs, conf Config) (*Collector, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	maxFileSize, _ := conf.maxFileSize()
+	c := &Collector{fs: fs, conf: conf, maxFileSize: maxFileSize}
+
+	if conf.isStdout() {
+		c.fname = "-"
+		c.written = &countingWriter{Writer: os.Stdout}
+		c.outfile = nopCloser{c.written}
+		if conf.compression() == CompressionGzip {
+			c.outfile = gzipFile{gzip.NewWriter(c.written), nopCloser{}}
+		}
+		return c, nil
 	}
 
-	logfile, err := fs.Create(fname)
-	if err != nil {
+	if err := c.openFile(); err != nil {
 		return nil, err
 	}
-	return &Collector{
-		outfile: logfile,
-		fname:   fname,
-	}, nil
+	return c, nil
+}
+
+// rotatedFileName returns the name of the n-th output file: the first one is the configured
+// name, later ones get the index before the extension, eg. "results.json.gz", "results.1.json.gz".
+func rotatedFileName(fname string, n int) string {
+	if n == 0 {
+		return fname
+	}
+	dir, base := filepath.Split(fname)
+	ext := ""
+	if strings.HasSuffix(base, ".gz") {
+		base, ext = strings.TrimSuffix(base, ".gz"), ".gz"
+	}
+	if e := filepath.Ext(base); e != "" && e != base {
+		base, ext = strings.TrimSuffix(base, e), e+ext
+	}
+	return fmt.Sprintf("%s%s.%d%s", dir, base, n, ext)
+}
+
+func (c *Collector) openFile() error {
+	fname := rotatedFileName(c.conf.FileName.String, c.fileIndex)
+	logfile, err := c.fs.Create(fname)
+	if err != nil {
+		return err
+	}
+	c.fname = fname
+	c.written = &countingWriter{Writer: logfile}
+	if c.conf.compression() == CompressionGzip {
+		c.outfile = gzipFile{gzip.NewWriter(c.written), logfile}
+	} else {
+		c.outfile = struct {
+			io.Writer
+			io.Closer
+		}{c.written, logfile}
+	}
+	return nil
+}
+
+// Starts a new output file if the current one is over the size limit. Metric envelopes
+// are written again, so that every file can be read on its own.
+func (c *Collector) rotate() {
+	if c.maxFileSize <= 0 || c.written.n < c.maxFileSize {
+		return
+	}
+	if err := c.outfile.Close(); err != nil {
+		log.WithError(err).WithField("filename", c.fname).Error("JSON: Error closing file")
+	}
+	c.fileIndex++
+	if err := c.openFile(); err != nil {
+		log.WithError(err).WithField("filename", c.fname).Error("JSON: Error creating file, discarding samples")
+		c.outfile = nopCloser{&countingWriter{Writer: ioutil.Discard}}
+		c.maxFileSize = 0
+		return
+	}
+	c.seenMetrics = nil
 }
 
 func (c *Collector) Init() error {
@@ -112,9 +210,13 @@ func (c *Collector) HandleMetric(m *stats.Metric) {
 func (c *Collector) Collect(scs []stats.SampleContainer) {
 	for _, sc := range scs {
 		for _, sample := range sc.GetSamples() {
+			c.rotate()
 			c.HandleMetric(sample.Metric)
 
 			env := WrapSample(&sample)
+			if env != nil && len(c.conf.Fields) > 0 {
+				env.Data = selectFields(&sample, c.conf.Fields)
+			}
 			row, err := json.Marshal(env)
 
 			if err != nil || env == nil {
@@ -21,11 +21,19 @@
 package json
 
 import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/loadimpact/k6/stats"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
 )
 
 func TestNew(t *testing.T) {
@@ -39,7 +47,7 @@ func TestNew(t *testing.T) {
 		t.Run("path="+path, func(t *testing.T) {
 			defer func() { _ = os.Remove(path) }()
 
-			collector, err := New(afero.NewOsFs(), path)
+			collector, err := New(afero.NewOsFs(), Config{FileName: null.StringFrom(path)})
 			if succ {
 				assert.NoError(t, err)
 				assert.NotNil(t, collector)
@@ -50,3 +58,126 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func readLines(t *testing.T, fs afero.Fs, fname string, gzipped bool) []map[string]interface{} {
+	f, err := fs.Open(fname)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		r = gz
+	}
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+func testSamples(n int) stats.Samples {
+	metric := stats.New("my_metric", stats.Trend)
+	samples := make(stats.Samples, n)
+	for i := range samples {
+		samples[i] = stats.Sample{
+			Metric: metric,
+			Time:   time.Unix(int64(i), 0),
+			Value:  float64(i),
+			Tags:   stats.NewSampleTags(map[string]string{"status": "200", "url": "http://example.com/"}),
+		}
+	}
+	return samples
+}
+
+func TestCollectorGzip(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	c, err := New(fs, Config{FileName: null.StringFrom("out.json.gz")})
+	require.NoError(t, err)
+	c.Collect([]stats.SampleContainer{testSamples(3)})
+	require.NoError(t, c.outfile.Close())
+
+	lines := readLines(t, fs, "out.json.gz", true)
+	require.Len(t, lines, 4)
+	assert.Equal(t, "Metric", lines[0]["type"])
+	assert.Equal(t, "Point", lines[3]["type"])
+}
+
+func TestCollectorRotation(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	c, err := New(fs, Config{FileName: null.StringFrom("out/results.json"), MaxFileSize: null.StringFrom("300B")})
+	require.NoError(t, err)
+	c.Collect([]stats.SampleContainer{testSamples(6)})
+	require.NoError(t, c.outfile.Close())
+
+	var points, files int
+	for ; ; files++ {
+		fname := rotatedFileName("out/results.json", files)
+		if ok, _ := afero.Exists(fs, fname); !ok {
+			break
+		}
+		lines := readLines(t, fs, fname, false)
+		require.NotEmpty(t, lines, fname)
+		assert.Equal(t, "Metric", lines[0]["type"], fname)
+		points += len(lines) - 1
+	}
+	assert.True(t, files > 1, "the output should've been rotated")
+	assert.Equal(t, 6, points)
+}
+
+func TestCollectorFields(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	c, err := New(fs, Config{FileName: null.StringFrom("out.json"), Fields: []string{"value", "tags.status"}})
+	require.NoError(t, err)
+	c.Collect([]stats.SampleContainer{testSamples(1)})
+	require.NoError(t, c.outfile.Close())
+
+	lines := readLines(t, fs, "out.json", false)
+	require.Len(t, lines, 2)
+	assert.Equal(t, map[string]interface{}{
+		"value": 0.0,
+		"tags":  map[string]interface{}{"status": "200"},
+	}, lines[1]["data"])
+}
+
+func TestCollectorMetadata(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	c, err := New(fs, Config{FileName: null.StringFrom("out.json"), Fields: []string{"value", "metadata.row"}})
+	require.NoError(t, err)
+	samples := testSamples(2)
+	samples[1].Metadata = map[string]string{"row": "5", "testCase": "TC-12"}
+	c.Collect([]stats.SampleContainer{samples})
+	require.NoError(t, c.outfile.Close())
+
+	lines := readLines(t, fs, "out.json", false)
+	require.Len(t, lines, 3)
+	assert.Equal(t, map[string]interface{}{"value": 0.0}, lines[1]["data"])
+	assert.Equal(t, map[string]interface{}{
+		"value":    1.0,
+		"metadata": map[string]interface{}{"row": "5"},
+	}, lines[2]["data"])
+
+	env := WrapSample(&samples[1])
+	assert.Equal(t, samples[1].Metadata, env.Data.(*JSONSample).Metadata)
+}
+
+func TestRotatedFileName(t *testing.T) {
+	testdata := map[string]string{
+		"results.json":        "results.2.json",
+		"results.json.gz":     "results.2.json.gz",
+		"results":             "results.2",
+		"out/my.results.json": "out/my.results.2.json",
+		"out.d/results":       "out.d/results.2",
+		".results.json":       ".results.2.json",
+	}
+	for fname, rotated := range testdata {
+		assert.Equal(t, fname, rotatedFileName(fname, 0))
+		assert.Equal(t, rotated, rotatedFileName(fname, 2), fname)
+	}
+}
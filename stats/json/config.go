@@ -0,0 +1,194 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/kubernetes/helm/pkg/strvals"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Compression types for the output file.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// Config is the config for the JSON collector
+type Config struct {
+	// Name of the output file, or "-" for stdout.
+	FileName null.String `json:"file_name" envconfig:"JSON_FILE_NAME"`
+
+	// Compression of the output, "gzip" or "none". Defaults to gzip for file names ending in ".gz".
+	Compression null.String `json:"compression" envconfig:"JSON_COMPRESSION"`
+
+	// Size after which a new output file is started, eg. "500MB". Not set means no rotation.
+	MaxFileSize null.String `json:"max_file_size" envconfig:"JSON_MAX_FILE_SIZE"`
+
+	// Sample fields to write: "time", "value", "tags" and "tags.<name>" for single tags.
+	// Not set means all of them.
+	Fields []string `json:"fields" envconfig:"JSON_FIELDS"`
+}
+
+// NewConfig creates a new Config instance with default values for some fields.
+func NewConfig() Config {
+	return Config{
+		FileName: null.StringFrom("-"),
+	}
+}
+
+func (c Config) Apply(cfg Config) Config {
+	if cfg.FileName.Valid {
+		c.FileName = cfg.FileName
+	}
+	if cfg.Compression.Valid {
+		c.Compression = cfg.Compression
+	}
+	if cfg.MaxFileSize.Valid {
+		c.MaxFileSize = cfg.MaxFileSize
+	}
+	if cfg.Fields != nil {
+		c.Fields = cfg.Fields
+	}
+	return c
+}
+
+// ParseArg takes the collector's argument and converts it to a config. A plain file name is
+// still accepted; otherwise the argument is a list of key=value pairs, eg.
+// "file=results.json.gz,max_file_size=1GB,fields={time,value,tags.status}".
+func ParseArg(arg string) (Config, error) {
+	c := Config{}
+	if !strings.Contains(arg, "=") {
+		c.FileName = null.StringFrom(arg)
+		return c, nil
+	}
+
+	params, err := strvals.Parse(arg)
+	if err != nil {
+		return c, err
+	}
+	for k, v := range params {
+		switch k {
+		case "file":
+			c.FileName = null.StringFrom(toString(v))
+		case "compression":
+			c.Compression = null.StringFrom(toString(v))
+		case "max_file_size":
+			c.MaxFileSize = null.StringFrom(toString(v))
+		case "fields":
+			switch fields := v.(type) {
+			case []interface{}:
+				c.Fields = make([]string, len(fields))
+				for i, field := range fields {
+					c.Fields[i] = toString(field)
+				}
+			default:
+				c.Fields = []string{toString(fields)}
+			}
+		default:
+			return c, errors.Errorf("unknown JSON output option '%s'", k)
+		}
+	}
+	return c, nil
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return ""
+	}
+}
+
+// Validate checks the config for invalid values.
+func (c Config) Validate() error {
+	switch c.compression() {
+	case CompressionNone, CompressionGzip:
+	default:
+		return errors.Errorf("unknown JSON output compression '%s'", c.Compression.String)
+	}
+	if _, err := c.maxFileSize(); err != nil {
+		return err
+	}
+	if c.MaxFileSize.Valid && c.isStdout() {
+		return errors.New("the JSON output can only be rotated when writing to a file")
+	}
+	for _, field := range c.Fields {
+		switch {
+		case field == "time", field == "value", field == "tags", field == "metadata":
+		case strings.HasPrefix(field, "tags.") && len(field) > len("tags."):
+		case strings.HasPrefix(field, "metadata.") && len(field) > len("metadata."):
+		default:
+			return errors.Errorf("unknown JSON output field '%s'", field)
+		}
+	}
+	return nil
+}
+
+func (c Config) isStdout() bool {
+	return c.FileName.String == "" || c.FileName.String == "-"
+}
+
+func (c Config) compression() string {
+	if c.Compression.Valid && c.Compression.String != "" {
+		return c.Compression.String
+	}
+	if strings.HasSuffix(c.FileName.String, ".gz") {
+		return CompressionGzip
+	}
+	return CompressionNone
+}
+
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// maxFileSize returns the rotation size in bytes, or 0 if the output shouldn't be rotated.
+func (c Config) maxFileSize() (int64, error) {
+	if !c.MaxFileSize.Valid || c.MaxFileSize.String == "" {
+		return 0, nil
+	}
+	s := strings.ToUpper(strings.TrimSpace(c.MaxFileSize.String))
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 {
+		return 0, errors.Errorf("invalid JSON output max file size '%s'", c.MaxFileSize.String)
+	}
+	return int64(n * float64(unit)), nil
+}
@@ -0,0 +1,115 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestParseArg(t *testing.T) {
+	testdata := map[string]Config{
+		"results.json": {FileName: null.StringFrom("results.json")},
+		"file=results.json.gz,max_file_size=1GB": {
+			FileName:    null.StringFrom("results.json.gz"),
+			MaxFileSize: null.StringFrom("1GB"),
+		},
+		"file=results.json,compression=gzip,fields={time,tags.status}": {
+			FileName:    null.StringFrom("results.json"),
+			Compression: null.StringFrom("gzip"),
+			Fields:      []string{"time", "tags.status"},
+		},
+		"file=-,fields=value": {
+			FileName: null.StringFrom("-"),
+			Fields:   []string{"value"},
+		},
+	}
+	for arg, config := range testdata {
+		t.Run(arg, func(t *testing.T) {
+			c, err := ParseArg(arg)
+			assert.NoError(t, err)
+			assert.Equal(t, config, c)
+		})
+	}
+
+	_, err := ParseArg("file=results.json,rotate=1GB")
+	assert.EqualError(t, err, "unknown JSON output option 'rotate'")
+}
+
+func TestConfigValidate(t *testing.T) {
+	testdata := map[string]struct {
+		config Config
+		err    string
+	}{
+		"file": {Config{FileName: null.StringFrom("out.json")}, ""},
+		"gzip": {Config{FileName: null.StringFrom("out.json"), Compression: null.StringFrom("gzip")}, ""},
+		"zstd": {
+			Config{FileName: null.StringFrom("out.json"), Compression: null.StringFrom("zstd")},
+			"unknown JSON output compression 'zstd'",
+		},
+		"size": {Config{FileName: null.StringFrom("out.json"), MaxFileSize: null.StringFrom("1.5 GB")}, ""},
+		"bad size": {
+			Config{FileName: null.StringFrom("out.json"), MaxFileSize: null.StringFrom("lots")},
+			"invalid JSON output max file size 'lots'",
+		},
+		"stdout rotation": {
+			Config{FileName: null.StringFrom("-"), MaxFileSize: null.StringFrom("1GB")},
+			"the JSON output can only be rotated when writing to a file",
+		},
+		"fields": {Config{Fields: []string{"time", "value", "tags", "tags.url", "metadata", "metadata.row"}}, ""},
+		"bad field": {
+			Config{Fields: []string{"tags."}},
+			"unknown JSON output field 'tags.'",
+		},
+		"bad metadata field": {
+			Config{Fields: []string{"metadata."}},
+			"unknown JSON output field 'metadata.'",
+		},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			err := data.config.Validate()
+			if data.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, data.err)
+			}
+		})
+	}
+}
+
+func TestConfigMaxFileSize(t *testing.T) {
+	testdata := map[string]int64{
+		"":      0,
+		"1024":  1024,
+		"10KB":  10 << 10,
+		"500mb": 500 << 20,
+		"1.5GB": 3 << 29,
+		"100 B": 100,
+	}
+	for size, expected := range testdata {
+		n, err := Config{MaxFileSize: null.StringFrom(size)}.maxFileSize()
+		assert.NoError(t, err, size)
+		assert.Equal(t, expected, n, size)
+	}
+}
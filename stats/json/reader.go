@@ -0,0 +1,165 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// Run is a test run read back from the JSON output.
+type Run struct {
+	// Metrics and the requested submetrics, by name.
+	Metrics map[string]*stats.Metric
+
+	// Times of the first and last samples.
+	Start, End time.Time
+
+	submetrics map[string][]*stats.Submetric
+}
+
+// NewRun creates an empty run, which will also track the given submetrics, eg.
+// "http_req_duration{status:200}", when samples are read into it.
+func NewRun(submetrics []string) *Run {
+	run := &Run{
+		Metrics:    make(map[string]*stats.Metric),
+		submetrics: make(map[string][]*stats.Submetric),
+	}
+	for _, name := range submetrics {
+		parent, sm := stats.NewSubmetric(name)
+		run.submetrics[parent] = append(run.submetrics[parent], sm)
+	}
+	return run
+}
+
+// Duration returns the time between the first and last samples.
+func (run *Run) Duration() time.Duration {
+	return run.End.Sub(run.Start)
+}
+
+type readEnvelope struct {
+	Type   string          `json:"type"`
+	Metric string          `json:"metric"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type readMetric struct {
+	Type     stats.MetricType `json:"type"`
+	Contains stats.ValueType  `json:"contains"`
+}
+
+type readSample struct {
+	Time  time.Time         `json:"time"`
+	Value float64           `json:"value"`
+	Tags  *stats.SampleTags `json:"tags"`
+}
+
+// Read adds the samples in a JSON output file, which may be gzipped, to the run. A run can be
+// read from several files, eg. the ones the output was rotated into.
+//
+// Trend metrics are read into histogram sinks, so memory use doesn't grow with the size of the
+// output, at the cost of percentiles having a small relative error.
+func (run *Run) Read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = gz.Close() }()
+		br = bufio.NewReader(gz)
+	}
+
+	dec := json.NewDecoder(br)
+	for line := 1; ; line++ {
+		var env readEnvelope
+		if err := dec.Decode(&env); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "line %d", line)
+		}
+
+		switch env.Type {
+		case "Metric":
+			if _, ok := run.Metrics[env.Metric]; ok {
+				continue
+			}
+			var m readMetric
+			if err := json.Unmarshal(env.Data, &m); err != nil {
+				return errors.Wrapf(err, "line %d", line)
+			}
+			run.addMetric(env.Metric, m.Type, m.Contains)
+		case "Point":
+			var s readSample
+			if err := json.Unmarshal(env.Data, &s); err != nil {
+				return errors.Wrapf(err, "line %d", line)
+			}
+			m, ok := run.Metrics[env.Metric]
+			if !ok {
+				return errors.Errorf("line %d: sample of undefined metric '%s'", line, env.Metric)
+			}
+			run.addSample(m, stats.Sample{Metric: m, Time: s.Time, Value: s.Value, Tags: s.Tags})
+		}
+	}
+}
+
+func (run *Run) addMetric(name string, typ stats.MetricType, contains stats.ValueType) {
+	m := newRunMetric(name, typ, contains)
+	m.Submetrics = run.submetrics[name]
+	for _, sm := range m.Submetrics {
+		sm.Metric = newRunMetric(sm.Name, typ, contains)
+		sm.Metric.Sub = *sm
+		run.Metrics[sm.Name] = sm.Metric
+	}
+	run.Metrics[name] = m
+}
+
+func newRunMetric(name string, typ stats.MetricType, contains stats.ValueType) *stats.Metric {
+	m := stats.New(name, typ, contains)
+	if typ == stats.Trend {
+		m.Sink = &stats.HistogramSink{}
+	}
+	return m
+}
+
+func (run *Run) addSample(m *stats.Metric, sample stats.Sample) {
+	if !sample.Time.IsZero() {
+		if run.Start.IsZero() || sample.Time.Before(run.Start) {
+			run.Start = sample.Time
+		}
+		if sample.Time.After(run.End) {
+			run.End = sample.Time
+		}
+	}
+
+	m.Sink.Add(sample)
+	for _, sm := range m.Submetrics {
+		if sample.Tags.Contains(sm.Tags) {
+			sm.Metric.Sink.Add(sample)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestRunRead(t *testing.T) {
+	for _, fname := range []string{"out.json", "out.json.gz"} {
+		t.Run(fname, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			c, err := New(fs, Config{FileName: null.StringFrom(fname)})
+			require.NoError(t, err)
+			c.Collect([]stats.SampleContainer{testSamples(10)})
+			require.NoError(t, c.outfile.Close())
+
+			data, err := afero.ReadFile(fs, fname)
+			require.NoError(t, err)
+			run := NewRun([]string{"my_metric{status:200}", "my_metric{status:500}"})
+			require.NoError(t, run.Read(bytes.NewReader(data)))
+
+			assert.True(t, time.Unix(0, 0).Equal(run.Start))
+			assert.Equal(t, 9*time.Second, run.Duration())
+			require.Len(t, run.Metrics, 3)
+
+			m := run.Metrics["my_metric"]
+			assert.Equal(t, stats.Trend, m.Type)
+			sink, ok := m.Sink.(*stats.HistogramSink)
+			require.True(t, ok)
+			assert.Equal(t, uint64(10), sink.Count)
+			assert.Equal(t, 4.5, sink.Avg)
+
+			assert.Equal(t, uint64(10), run.Metrics["my_metric{status:200}"].Sink.(*stats.HistogramSink).Count)
+			assert.Equal(t, uint64(0), run.Metrics["my_metric{status:500}"].Sink.(*stats.HistogramSink).Count)
+			assert.Equal(t, "my_metric", run.Metrics["my_metric{status:500}"].Sub.Parent)
+		})
+	}
+}
+
+func TestRunReadRotated(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	c, err := New(fs, Config{FileName: null.StringFrom("out.json"), MaxFileSize: null.StringFrom("300B")})
+	require.NoError(t, err)
+	c.Collect([]stats.SampleContainer{testSamples(6)})
+	require.NoError(t, c.outfile.Close())
+
+	run := NewRun(nil)
+	for i := 0; ; i++ {
+		data, err := afero.ReadFile(fs, rotatedFileName("out.json", i))
+		if err != nil {
+			break
+		}
+		require.NoError(t, run.Read(bytes.NewReader(data)))
+	}
+	assert.Equal(t, uint64(6), run.Metrics["my_metric"].Sink.(*stats.HistogramSink).Count)
+}
+
+func TestRunReadErrors(t *testing.T) {
+	testdata := map[string]string{
+		"undefined metric": `{"type":"Point","metric":"nope","data":{"value":1}}`,
+		"bad json":         `{"type":"Point"`,
+		"bad metric type":  `{"type":"Metric","metric":"m","data":{"type":"nope"}}`,
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			err := NewRun(nil).Read(strings.NewReader(data))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "line 1")
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@
 package json
 
 import (
+	"strings"
 	"time"
 
 	"github.com/loadimpact/k6/stats"
@@ -33,16 +34,18 @@ type Envelope struct {
 }
 
 type JSONSample struct {
-	Time  time.Time         `json:"time"`
-	Value float64           `json:"value"`
-	Tags  *stats.SampleTags `json:"tags"`
+	Time     time.Time         `json:"time"`
+	Value    float64           `json:"value"`
+	Tags     *stats.SampleTags `json:"tags"`
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 func NewJSONSample(sample *stats.Sample) *JSONSample {
 	return &JSONSample{
-		Time:  sample.Time,
-		Value: sample.Value,
-		Tags:  sample.Tags,
+		Time:     sample.Time,
+		Value:    sample.Value,
+		Tags:     sample.Tags,
+		Metadata: sample.Metadata,
 	}
 }
 
@@ -68,3 +71,54 @@ func WrapMetric(metric *stats.Metric) *Envelope {
 		Data:   metric,
 	}
 }
+
+// selectFields returns the sample's data with only the given fields, see Config.Fields.
+func selectFields(sample *stats.Sample, fields []string) map[string]interface{} {
+	data := make(map[string]interface{}, len(fields))
+	var tags, metadata map[string]string
+	allMetadata := false
+	for _, field := range fields {
+		allMetadata = allMetadata || field == "metadata"
+	}
+	for _, field := range fields {
+		switch {
+		case field == "time":
+			data["time"] = sample.Time
+		case field == "value":
+			data["value"] = sample.Value
+		case field == "tags":
+			data["tags"] = sample.Tags
+		case strings.HasPrefix(field, "tags."):
+			if _, ok := data["tags"].(*stats.SampleTags); ok {
+				continue
+			}
+			if tags == nil {
+				tags = make(map[string]string)
+				data["tags"] = tags
+			}
+			if sample.Tags == nil {
+				continue
+			}
+			name := strings.TrimPrefix(field, "tags.")
+			if v, ok := sample.Tags.Get(name); ok {
+				tags[name] = v
+			}
+		case field == "metadata":
+			if len(sample.Metadata) > 0 {
+				data["metadata"] = sample.Metadata
+			}
+		case strings.HasPrefix(field, "metadata."):
+			name := strings.TrimPrefix(field, "metadata.")
+			v, ok := sample.Metadata[name]
+			if !ok || allMetadata {
+				continue
+			}
+			if metadata == nil {
+				metadata = make(map[string]string)
+				data["metadata"] = metadata
+			}
+			metadata[name] = v
+		}
+	}
+	return data
+}
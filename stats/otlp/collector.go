@@ -0,0 +1,357 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Bucket boundaries of the histograms trends are exported as; the OpenTelemetry SDK defaults.
+var histogramBounds = []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000}
+
+// Collector sends metrics and, with the traceContext option, a span per HTTP request and browser
+// page load to an OpenTelemetry collector using OTLP/HTTP with JSON encoding.
+type Collector struct {
+	Config Config
+	Client *http.Client
+
+	buffer     []stats.SampleContainer
+	bufferLock sync.Mutex
+
+	// Start of the current aggregation interval.
+	start time.Time
+}
+
+var _ lib.Collector = &Collector{}
+
+// New creates an instance of the collector
+func New(conf Config) (*Collector, error) {
+	if _, err := ParseArg(conf.Endpoint.String); err != nil {
+		return nil, err
+	}
+	return &Collector{
+		Config: conf,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Init does nothing, it's only included to satisfy the lib.Collector interface
+func (c *Collector) Init() error { return nil }
+
+// Run pushes the collected samples every PushInterval, until the context is done
+func (c *Collector) Run(ctx context.Context) {
+	log.Debug("OTLP: Running!")
+	c.start = time.Now()
+	ticker := time.NewTicker(time.Duration(c.Config.PushInterval.Duration))
+	for {
+		select {
+		case <-ticker.C:
+			c.push()
+		case <-ctx.Done():
+			c.push()
+			return
+		}
+	}
+}
+
+// Collect buffers the samples until the next push
+func (c *Collector) Collect(scs []stats.SampleContainer) {
+	c.bufferLock.Lock()
+	c.buffer = append(c.buffer, scs...)
+	c.bufferLock.Unlock()
+}
+
+// Link returns the endpoint the data is sent to
+func (c *Collector) Link() string {
+	return c.Config.Endpoint.String
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+// SetRunStatus does nothing in the OTLP collector
+func (c *Collector) SetRunStatus(status lib.RunStatus) {}
+
+func (c *Collector) push() {
+	c.bufferLock.Lock()
+	containers := c.buffer
+	c.buffer = nil
+	c.bufferLock.Unlock()
+
+	start, end := c.start, time.Now()
+	c.start = end
+
+	resource := resource{Attributes: []keyValue{stringAttr("service.name", c.Config.ServiceName.String)}}
+	scope := scope{Name: "k6"}
+
+	if metrics := aggregate(containers, start, end); len(metrics) > 0 {
+		c.send("/v1/metrics", metricsRequest{ResourceMetrics: []resourceMetrics{{
+			Resource:     resource,
+			ScopeMetrics: []scopeMetrics{{Scope: scope, Metrics: metrics}},
+		}}})
+	}
+	if spans := spansFromTraces(containers); len(spans) > 0 {
+		c.send("/v1/traces", tracesRequest{ResourceSpans: []resourceSpans{{
+			Resource:   resource,
+			ScopeSpans: []scopeSpans{{Scope: scope, Spans: spans}},
+		}}})
+	}
+}
+
+func (c *Collector) send(path string, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.WithError(err).Error("OTLP: Couldn't encode the payload")
+		return
+	}
+
+	url := strings.TrimSuffix(c.Config.Endpoint.String, "/") + path
+	res, err := c.Client.Post(url, "application/json", bytes.NewReader(body))
+	if err == nil {
+		_ = res.Body.Close()
+		if res.StatusCode/100 != 2 {
+			err = errors.Errorf("unexpected response status %s", res.Status)
+		}
+	}
+	if err != nil {
+		log.WithError(err).WithField("url", url).Error("OTLP: Couldn't send data")
+		return
+	}
+	log.WithField("url", url).Debug("OTLP: Delivered!")
+}
+
+// A series is the samples of a metric with a particular set of tags, over one push interval.
+type series struct {
+	metric *stats.Metric
+	attrs  []keyValue
+	last   time.Time
+
+	count    uint64
+	sum      float64
+	min, max float64
+	value    float64 // Gauges only.
+	nonzero  uint64  // Rates only.
+	buckets  []uint64
+}
+
+func (s *series) add(sample stats.Sample) {
+	if s.count == 0 || sample.Value < s.min {
+		s.min = sample.Value
+	}
+	if s.count == 0 || sample.Value > s.max {
+		s.max = sample.Value
+	}
+	s.count++
+	s.sum += sample.Value
+	if !sample.Time.Before(s.last) {
+		s.last = sample.Time
+		s.value = sample.Value
+	}
+	if sample.Value != 0 {
+		s.nonzero++
+	}
+	if s.metric.Type == stats.Trend || s.metric.Type == stats.Histogram {
+		if s.buckets == nil {
+			s.buckets = make([]uint64, len(histogramBounds)+1)
+		}
+		s.buckets[sort.SearchFloat64s(histogramBounds, sample.Value)]++
+	}
+}
+
+func seriesKey(name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(tags[k])
+	}
+	return b.String()
+}
+
+// aggregate turns the samples collected over an interval into delta metrics.
+func aggregate(containers []stats.SampleContainer, start, end time.Time) []metric {
+	seriesByKey := make(map[string]*series)
+	var keys []string
+	for _, sc := range containers {
+		for _, sample := range sc.GetSamples() {
+			tags := sample.Tags.CloneTags()
+			key := seriesKey(sample.Metric.Name, tags)
+			s, ok := seriesByKey[key]
+			if !ok {
+				s = &series{metric: sample.Metric, attrs: attributes(tags)}
+				seriesByKey[key] = s
+				keys = append(keys, key)
+			}
+			s.add(sample)
+		}
+	}
+	sort.Strings(keys)
+
+	startNano, endNano := unixNano(start), unixNano(end)
+	var metrics []metric
+	metricIndexes := make(map[string]int)
+	for _, key := range keys {
+		s := seriesByKey[key]
+		i, ok := metricIndexes[s.metric.Name]
+		if !ok {
+			i = len(metrics)
+			metrics = append(metrics, metric{Name: s.metric.Name, Unit: unit(s.metric)})
+			metricIndexes[s.metric.Name] = i
+		}
+		m := &metrics[i]
+
+		point := numberDataPoint{Attributes: s.attrs, StartTimeUnixNano: startNano, TimeUnixNano: endNano}
+		switch s.metric.Type {
+		case stats.Counter:
+			if m.Sum == nil {
+				m.Sum = &sum{AggregationTemporality: temporalityDelta, IsMonotonic: true}
+			}
+			point.AsDouble = s.sum
+			m.Sum.DataPoints = append(m.Sum.DataPoints, point)
+		case stats.Gauge, stats.Rate:
+			if m.Gauge == nil {
+				m.Gauge = &gauge{}
+			}
+			point.AsDouble = s.value
+			if s.metric.Type == stats.Rate {
+				point.AsDouble = float64(s.nonzero) / float64(s.count)
+			}
+			m.Gauge.DataPoints = append(m.Gauge.DataPoints, point)
+		default:
+			if m.Histogram == nil {
+				m.Histogram = &histogram{AggregationTemporality: temporalityDelta}
+			}
+			counts := make([]string, len(s.buckets))
+			for i, n := range s.buckets {
+				counts[i] = strconv.FormatUint(n, 10)
+			}
+			m.Histogram.DataPoints = append(m.Histogram.DataPoints, histogramDataPoint{
+				Attributes:        s.attrs,
+				StartTimeUnixNano: startNano,
+				TimeUnixNano:      endNano,
+				Count:             strconv.FormatUint(s.count, 10),
+				Sum:               s.sum,
+				Min:               s.min,
+				Max:               s.max,
+				BucketCounts:      counts,
+				ExplicitBounds:    histogramBounds,
+			})
+		}
+	}
+
+	return metrics
+}
+
+// spansFromTraces returns a client span for every operation that propagated a trace context, eg.
+// HTTP requests and browser page loads.
+func spansFromTraces(containers []stats.SampleContainer) []span {
+	var spans []span
+	for _, sc := range containers {
+		traced, ok := sc.(stats.TracedSampleContainer)
+		if !ok {
+			continue
+		}
+		s, ok := traced.GetSpan()
+		if !ok {
+			continue
+		}
+
+		tags := traced.GetTags().CloneTags()
+		attrs := tags
+		// Span attributes aren't indexed like metric attributes, so they can have the metadata.
+		if samples := traced.GetSamples(); len(samples) > 0 && len(samples[0].Metadata) > 0 {
+			attrs = make(map[string]string, len(tags)+len(samples[0].Metadata))
+			for k, v := range samples[0].Metadata {
+				attrs[k] = v
+			}
+			for k, v := range tags {
+				attrs[k] = v
+			}
+		}
+		sp := span{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			Name:              s.Name,
+			Kind:              spanKindClient,
+			StartTimeUnixNano: unixNano(s.Start),
+			EndTimeUnixNano:   unixNano(s.End),
+			Attributes:        attributes(attrs),
+		}
+		if tags["error"] != "" || tags["status"] == "0" || strings.HasPrefix(tags["status"], "5") {
+			sp.Status.Code = statusCodeError
+			sp.Status.Message = tags["error"]
+		}
+		spans = append(spans, sp)
+	}
+	return spans
+}
+
+func attributes(tags map[string]string) []keyValue {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]keyValue, len(keys))
+	for i, k := range keys {
+		attrs[i] = stringAttr(k, tags[k])
+	}
+	return attrs
+}
+
+func unit(m *stats.Metric) string {
+	switch m.Contains {
+	case stats.Time:
+		return "ms"
+	case stats.Data:
+		return "By"
+	default:
+		return ""
+	}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
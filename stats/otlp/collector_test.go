@@ -0,0 +1,166 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestCollector(t *testing.T) {
+	var mu sync.Mutex
+	payloads := make(map[string][]byte)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		mu.Lock()
+		payloads[r.URL.Path] = body
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	c, err := New(NewConfig().Apply(Config{Endpoint: null.StringFrom(srv.URL + "/")}))
+	require.NoError(t, err)
+
+	counter := stats.New("my_counter", stats.Counter)
+	gauge := stats.New("my_gauge", stats.Gauge)
+	rate := stats.New("my_rate", stats.Rate)
+	trend := stats.New("my_trend", stats.Trend, stats.Time)
+	tagsA := stats.IntoSampleTags(&map[string]string{"a": "1"})
+	tagsB := stats.IntoSampleTags(&map[string]string{"b": "2"})
+	now := time.Now()
+
+	trail := &netext.Trail{
+		StartTime: now.Add(-100 * time.Millisecond),
+		EndTime:   now,
+		Duration:  100 * time.Millisecond,
+		TraceID:   "0af7651916cd43dd8448eb211c80319c",
+		SpanID:    "b7ad6b7169203331",
+		Metadata:  map[string]string{"row": "17", "method": "ignored"},
+	}
+	trail.SaveSamples(stats.IntoSampleTags(&map[string]string{"method": "GET", "status": "503"}))
+
+	c.Collect([]stats.SampleContainer{
+		stats.Sample{Metric: counter, Value: 1, Tags: tagsA, Time: now},
+		stats.Sample{Metric: counter, Value: 2, Tags: tagsA, Time: now},
+		stats.Sample{Metric: counter, Value: 5, Tags: tagsB, Time: now},
+		stats.Sample{Metric: gauge, Value: 3, Tags: tagsA, Time: now.Add(-time.Second)},
+		stats.Sample{Metric: gauge, Value: 4, Tags: tagsA, Time: now},
+		stats.Sample{Metric: rate, Value: 1, Tags: tagsA, Time: now},
+		stats.Sample{Metric: rate, Value: 0, Tags: tagsA, Time: now},
+		stats.Sample{Metric: trend, Value: 7, Tags: tagsA, Time: now},
+		stats.Sample{Metric: trend, Value: 300, Tags: tagsA, Time: now},
+		trail,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Run(ctx)
+
+	var metrics metricsRequest
+	require.NoError(t, json.Unmarshal(payloads["/v1/metrics"], &metrics))
+	require.Len(t, metrics.ResourceMetrics, 1)
+	assert.Equal(t, []keyValue{stringAttr("service.name", "k6")}, metrics.ResourceMetrics[0].Resource.Attributes)
+
+	byName := make(map[string]metric)
+	for _, m := range metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		byName[m.Name] = m
+	}
+
+	sum := byName["my_counter"].Sum
+	require.NotNil(t, sum)
+	assert.Equal(t, temporalityDelta, sum.AggregationTemporality)
+	require.Len(t, sum.DataPoints, 2)
+	assert.Equal(t, []keyValue{stringAttr("a", "1")}, sum.DataPoints[0].Attributes)
+	assert.Equal(t, 3.0, sum.DataPoints[0].AsDouble)
+	assert.Equal(t, 5.0, sum.DataPoints[1].AsDouble)
+
+	require.NotNil(t, byName["my_gauge"].Gauge)
+	assert.Equal(t, 4.0, byName["my_gauge"].Gauge.DataPoints[0].AsDouble)
+	require.NotNil(t, byName["my_rate"].Gauge)
+	assert.Equal(t, 0.5, byName["my_rate"].Gauge.DataPoints[0].AsDouble)
+
+	hist := byName["my_trend"].Histogram
+	require.NotNil(t, hist)
+	assert.Equal(t, "ms", byName["my_trend"].Unit)
+	point := hist.DataPoints[0]
+	assert.Equal(t, "2", point.Count)
+	assert.Equal(t, 307.0, point.Sum)
+	assert.Equal(t, 7.0, point.Min)
+	assert.Equal(t, 300.0, point.Max)
+	assert.Equal(t, histogramBounds, point.ExplicitBounds)
+	assert.Equal(t, []string{"0", "0", "1", "0", "0", "0", "0", "0", "1", "0", "0", "0", "0", "0", "0", "0"}, point.BucketCounts)
+
+	require.Contains(t, byName, "http_req_duration")
+	assert.Equal(t,
+		[]keyValue{stringAttr("method", "GET"), stringAttr("status", "503")},
+		byName["http_req_duration"].Histogram.DataPoints[0].Attributes,
+	)
+
+	var traces tracesRequest
+	require.NoError(t, json.Unmarshal(payloads["/v1/traces"], &traces))
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 1)
+	assert.Equal(t, trail.TraceID, spans[0].TraceID)
+	assert.Equal(t, trail.SpanID, spans[0].SpanID)
+	assert.Equal(t, "HTTP GET", spans[0].Name)
+	assert.Equal(t, spanKindClient, spans[0].Kind)
+	assert.Equal(t, unixNano(trail.StartTime), spans[0].StartTimeUnixNano)
+	assert.Equal(t, unixNano(trail.EndTime), spans[0].EndTimeUnixNano)
+	assert.Equal(t, statusCodeError, spans[0].Status.Code)
+	assert.Equal(t,
+		[]keyValue{stringAttr("method", "GET"), stringAttr("row", "17"), stringAttr("status", "503")},
+		spans[0].Attributes,
+	)
+}
+
+func TestCollectorNoTraces(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	c, err := New(NewConfig().Apply(Config{Endpoint: null.StringFrom(srv.URL)}))
+	require.NoError(t, err)
+
+	trail := &netext.Trail{EndTime: time.Now()}
+	trail.SaveSamples(stats.IntoSampleTags(&map[string]string{}))
+	c.Collect([]stats.SampleContainer{trail})
+	c.push()
+	assert.Equal(t, []string{"/v1/metrics"}, paths)
+
+	c.push()
+	assert.Equal(t, []string{"/v1/metrics"}, paths)
+}
@@ -0,0 +1,77 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otlp
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Config is the config for the OTLP collector
+type Config struct {
+	// Base URL of an OTLP/HTTP receiver; metrics are sent to /v1/metrics, spans to /v1/traces.
+	Endpoint null.String `json:"endpoint" envconfig:"OTLP_ENDPOINT"`
+
+	// The service.name resource attribute of everything that's sent.
+	ServiceName null.String `json:"service_name" envconfig:"OTLP_SERVICE_NAME"`
+
+	PushInterval types.NullDuration `json:"push_interval" envconfig:"OTLP_PUSH_INTERVAL"`
+}
+
+// NewConfig creates a new Config instance with default values for some fields.
+func NewConfig() Config {
+	return Config{
+		Endpoint:     null.StringFrom("http://localhost:4318"),
+		ServiceName:  null.StringFrom("k6"),
+		PushInterval: types.NullDurationFrom(1 * time.Second),
+	}
+}
+
+func (c Config) Apply(cfg Config) Config {
+	if cfg.Endpoint.Valid {
+		c.Endpoint = cfg.Endpoint
+	}
+	if cfg.ServiceName.Valid {
+		c.ServiceName = cfg.ServiceName
+	}
+	if cfg.PushInterval.Valid {
+		c.PushInterval = cfg.PushInterval
+	}
+	return c
+}
+
+// ParseArg takes the endpoint URL given as the collector's argument and converts it to a config
+func ParseArg(arg string) (Config, error) {
+	c := Config{}
+	u, err := url.Parse(arg)
+	if err != nil {
+		return c, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return c, errors.Errorf("invalid OTLP endpoint '%s', expected an http:// or https:// URL", arg)
+	}
+	c.Endpoint = null.StringFrom(arg)
+	return c, nil
+}
@@ -0,0 +1,44 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestConfigParseArg(t *testing.T) {
+	c, err := ParseArg("https://otel.example.com:4318")
+	assert.Nil(t, err)
+	assert.Equal(t, null.StringFrom("https://otel.example.com:4318"), c.Endpoint)
+	assert.False(t, c.ServiceName.Valid)
+
+	_, err = ParseArg("otel.example.com:4318")
+	assert.EqualError(t, err, "invalid OTLP endpoint 'otel.example.com:4318', expected an http:// or https:// URL")
+}
+
+func TestConfigApply(t *testing.T) {
+	c := NewConfig().Apply(Config{ServiceName: null.StringFrom("checkout-test")})
+	assert.Equal(t, null.StringFrom("http://localhost:4318"), c.Endpoint)
+	assert.Equal(t, null.StringFrom("checkout-test"), c.ServiceName)
+}
@@ -31,8 +31,12 @@ var (
 	_ Sink = &CounterSink{}
 	_ Sink = &GaugeSink{}
 	_ Sink = &TrendSink{}
+	_ Sink = &HistogramSink{}
 	_ Sink = &RateSink{}
 	_ Sink = &DummySink{}
+
+	_ PercentileSink = &TrendSink{}
+	_ PercentileSink = &HistogramSink{}
 )
 
 type Sink interface {
@@ -41,6 +45,21 @@ type Sink interface {
 	Format(t time.Duration) map[string]float64 // Data for thresholds.
 }
 
+// A PercentileSink is a sink that tracks the distribution of its values.
+type PercentileSink interface {
+	Sink
+	Summary() TrendSummary // Aggregates of all values added so far.
+	P(pct float64) float64 // Value at the given percentile, 0.0-1.0.
+}
+
+// TrendSummary holds the aggregates of a distribution.
+type TrendSummary struct {
+	Count    uint64
+	Min, Max float64
+	Sum, Avg float64
+	Med      float64
+}
+
 type CounterSink struct {
 	Value float64
 	First time.Time
@@ -146,6 +165,12 @@ func (t *TrendSink) Calc() {
 	}
 }
 
+// Summary returns the aggregates of the sink's values.
+func (t *TrendSink) Summary() TrendSummary {
+	t.Calc()
+	return TrendSummary{Count: t.Count, Min: t.Min, Max: t.Max, Sum: t.Sum, Avg: t.Avg, Med: t.Med}
+}
+
 func (t *TrendSink) Format(tt time.Duration) map[string]float64 {
 	t.Calc()
 	return map[string]float64{
@@ -158,6 +183,158 @@ func (t *TrendSink) Format(tt time.Duration) map[string]float64 {
 	}
 }
 
+// HistogramRelativeError is the worst-case relative error of percentiles reported by a
+// HistogramSink; values are counted in logarithmically sized buckets, so memory use depends on
+// the range of the values rather than on how many of them there are.
+const HistogramRelativeError = 0.01
+
+// Values closer to zero than this are counted as zero by a HistogramSink.
+const histogramMinValue = 1e-9
+
+var (
+	histogramGamma    = (1 + HistogramRelativeError) / (1 - HistogramRelativeError)
+	histogramLogGamma = math.Log(histogramGamma)
+)
+
+// A HistogramSink counts values into logarithmic buckets, giving percentiles with a bounded
+// relative error (see HistogramRelativeError) no matter how many values are added.
+type HistogramSink struct {
+	Count    uint64
+	Min, Max float64
+	Sum, Avg float64
+	Med      float64
+
+	positive, negative map[int]uint64
+	zeros              uint64
+
+	// Bucket indexes in ascending order, rebuilt by Calc() when new buckets were created.
+	positiveKeys, negativeKeys []int
+	jumbled                    bool
+}
+
+func histogramIndex(v float64) int {
+	return int(math.Ceil(math.Log(v) / histogramLogGamma))
+}
+
+func histogramValue(i int) float64 {
+	return 2 * math.Pow(histogramGamma, float64(i)) / (histogramGamma + 1)
+}
+
+func (h *HistogramSink) Add(s Sample) {
+	switch {
+	case s.Value > histogramMinValue:
+		if h.positive == nil {
+			h.positive = make(map[int]uint64)
+		}
+		i := histogramIndex(s.Value)
+		if _, ok := h.positive[i]; !ok {
+			h.jumbled = true
+		}
+		h.positive[i]++
+	case s.Value < -histogramMinValue:
+		if h.negative == nil {
+			h.negative = make(map[int]uint64)
+		}
+		i := histogramIndex(-s.Value)
+		if _, ok := h.negative[i]; !ok {
+			h.jumbled = true
+		}
+		h.negative[i]++
+	default:
+		h.zeros++
+	}
+
+	h.Count++
+	h.Sum += s.Value
+	h.Avg = h.Sum / float64(h.Count)
+	if s.Value > h.Max || h.Count == 1 {
+		h.Max = s.Value
+	}
+	if s.Value < h.Min || h.Count == 1 {
+		h.Min = s.Value
+	}
+	h.Med = 0
+}
+
+// P estimates the given percentile from the sink's buckets.
+func (h *HistogramSink) P(pct float64) float64 {
+	switch {
+	case h.Count == 0:
+		return 0
+	case pct <= 0:
+		return h.Min
+	case pct >= 1:
+		return h.Max
+	}
+	h.sortKeys()
+
+	// Bucket midpoints may fall outside of the actual range of the values.
+	v := h.rankValue(uint64(pct * float64(h.Count-1)))
+	return math.Min(math.Max(v, h.Min), h.Max)
+}
+
+// rankValue returns the midpoint of the bucket holding the value with the given rank.
+func (h *HistogramSink) rankValue(rank uint64) float64 {
+	var seen uint64
+	// Negative values are ordered by descending magnitude.
+	for i := len(h.negativeKeys) - 1; i >= 0; i-- {
+		k := h.negativeKeys[i]
+		if seen += h.negative[k]; seen > rank {
+			return -histogramValue(k)
+		}
+	}
+	if seen += h.zeros; seen > rank {
+		return 0
+	}
+	for _, k := range h.positiveKeys {
+		if seen += h.positive[k]; seen > rank {
+			return histogramValue(k)
+		}
+	}
+	return h.Max
+}
+
+func (h *HistogramSink) sortKeys() {
+	if !h.jumbled {
+		return
+	}
+	h.positiveKeys = h.positiveKeys[:0]
+	for k := range h.positive {
+		h.positiveKeys = append(h.positiveKeys, k)
+	}
+	sort.Ints(h.positiveKeys)
+	h.negativeKeys = h.negativeKeys[:0]
+	for k := range h.negative {
+		h.negativeKeys = append(h.negativeKeys, k)
+	}
+	sort.Ints(h.negativeKeys)
+	h.jumbled = false
+}
+
+func (h *HistogramSink) Calc() {
+	h.Med = h.P(0.5)
+}
+
+// Summary returns the aggregates of the sink's values.
+func (h *HistogramSink) Summary() TrendSummary {
+	h.Calc()
+	return TrendSummary{Count: h.Count, Min: h.Min, Max: h.Max, Sum: h.Sum, Avg: h.Avg, Med: h.Med}
+}
+
+func (h *HistogramSink) Format(tt time.Duration) map[string]float64 {
+	h.Calc()
+	return map[string]float64{
+		"min":     h.Min,
+		"max":     h.Max,
+		"avg":     h.Avg,
+		"med":     h.Med,
+		"p(90)":   h.P(0.90),
+		"p(95)":   h.P(0.95),
+		"p(99)":   h.P(0.99),
+		"p(99.9)": h.P(0.999),
+	}
+}
+
 type RateSink struct {
 	Trues int64
 	Total int64
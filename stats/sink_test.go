@@ -21,6 +21,7 @@
 package stats
 
 import (
+	"math/rand"
 	"testing"
 	"time"
 
@@ -214,6 +215,59 @@ func TestTrendSink(t *testing.T) {
 	})
 }
 
+func TestHistogramSink(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		sink := HistogramSink{}
+		sink.Calc()
+		assert.Equal(t, uint64(0), sink.Count)
+		assert.Equal(t, 0.0, sink.P(0.99))
+	})
+	t.Run("add", func(t *testing.T) {
+		sink := HistogramSink{}
+		for _, v := range []float64{-5.0, 0.0, 10.0, 3.0, 1.0} {
+			sink.Add(Sample{Metric: &Metric{}, Value: v})
+		}
+		assert.Equal(t, uint64(5), sink.Count)
+		assert.Equal(t, -5.0, sink.Min)
+		assert.Equal(t, 10.0, sink.Max)
+		assert.Equal(t, 9.0, sink.Sum)
+		assert.Equal(t, 1.8, sink.Avg)
+		assert.Equal(t, -5.0, sink.P(0))
+		assert.Equal(t, 10.0, sink.P(1))
+		assert.InEpsilon(t, -5.0, sink.P(0.1), HistogramRelativeError)
+		assert.Equal(t, 0.0, sink.P(0.25))
+		assert.InEpsilon(t, 1.0, sink.Summary().Med, HistogramRelativeError)
+	})
+	t.Run("percentiles", func(t *testing.T) {
+		sink := HistogramSink{}
+		trend := TrendSink{}
+		r := rand.New(rand.NewSource(42))
+		for i := 0; i < 100000; i++ {
+			s := Sample{Metric: &Metric{}, Value: r.ExpFloat64() * 100}
+			sink.Add(s)
+			trend.Add(s)
+		}
+		assert.True(t, len(sink.positive) < 2000)
+		for _, pct := range []float64{0.5, 0.9, 0.95, 0.99, 0.999, 0.9999} {
+			assert.InEpsilon(t, trend.P(pct), sink.P(pct), HistogramRelativeError*1.5, "p(%v)", pct*100)
+		}
+	})
+	t.Run("format", func(t *testing.T) {
+		sink := HistogramSink{}
+		for i := 1; i <= 1000; i++ {
+			sink.Add(Sample{Metric: &Metric{}, Value: float64(i)})
+		}
+		f := sink.Format(0)
+		assert.Len(t, f, 8)
+		assert.Equal(t, 1.0, f["min"])
+		assert.Equal(t, 1000.0, f["max"])
+		assert.Equal(t, 500.5, f["avg"])
+		assert.InEpsilon(t, 500.0, f["med"], HistogramRelativeError)
+		assert.InEpsilon(t, 990.0, f["p(99)"], HistogramRelativeError)
+		assert.InEpsilon(t, 999.0, f["p(99.9)"], HistogramRelativeError)
+	})
+}
+
 func TestRateSink(t *testing.T) {
 	samples6 := []float64{1.0, 0.0, 1.0, 0.0, 0.0, 1.0}
 
@@ -34,10 +34,11 @@ import (
 )
 
 const (
-	counterString = `"counter"`
-	gaugeString   = `"gauge"`
-	trendString   = `"trend"`
-	rateString    = `"rate"`
+	counterString   = `"counter"`
+	gaugeString     = `"gauge"`
+	trendString     = `"trend"`
+	rateString      = `"rate"`
+	histogramString = `"histogram"`
 
 	defaultString = `"default"`
 	timeString    = `"time"`
@@ -46,10 +47,11 @@ const (
 
 // Possible values for MetricType.
 const (
-	Counter = MetricType(iota) // A counter that sums its data points
-	Gauge                      // A gauge that displays the latest value
-	Trend                      // A trend, min/max/avg/med are interesting
-	Rate                       // A rate, displays % of values that aren't 0
+	Counter   = MetricType(iota) // A counter that sums its data points
+	Gauge                        // A gauge that displays the latest value
+	Trend                        // A trend, min/max/avg/med are interesting
+	Rate                         // A rate, displays % of values that aren't 0
+	Histogram                    // A trend with bounded memory use and percentile error
 )
 
 // Possible values for ValueType.
@@ -79,6 +81,8 @@ func (t MetricType) MarshalJSON() ([]byte, error) {
 		return []byte(trendString), nil
 	case Rate:
 		return []byte(rateString), nil
+	case Histogram:
+		return []byte(histogramString), nil
 	default:
 		return nil, ErrInvalidMetricType
 	}
@@ -95,6 +99,8 @@ func (t *MetricType) UnmarshalJSON(data []byte) error {
 		*t = Trend
 	case rateString:
 		*t = Rate
+	case histogramString:
+		*t = Histogram
 	default:
 		return ErrInvalidMetricType
 	}
@@ -112,6 +118,8 @@ func (t MetricType) String() string {
 		return trendString
 	case Rate:
 		return rateString
+	case Histogram:
+		return histogramString
 	default:
 		return "[INVALID]"
 	}
@@ -295,6 +303,12 @@ type Sample struct {
 	Time   time.Time
 	Tags   *SampleTags
 	Value  float64
+
+	// Metadata is passed on to the outputs that can store it, but unlike tags, it isn't indexed:
+	// submetrics, thresholds and aggregation ignore it. This makes it a fit for values that are
+	// unique to a sample, eg. the ID of a test case or data row, which would make too many
+	// distinct tag sets.
+	Metadata map[string]string
 }
 
 // SampleContainer is a simple abstraction that allows sample
@@ -346,6 +360,28 @@ func (cs ConnectedSamples) GetTime() time.Time {
 	return cs.Time
 }
 
+// A TagMapper is a sample container that can be copied with the tags of it and its samples
+// mapped, eg. to redact them, without losing its type.
+type TagMapper interface {
+	SampleContainer
+	MapTags(fn func(*SampleTags) *SampleTags) SampleContainer
+}
+
+// MapTags implements the TagMapper interface.
+func (cs ConnectedSamples) MapTags(fn func(*SampleTags) *SampleTags) SampleContainer {
+	res := ConnectedSamples{Samples: make([]Sample, len(cs.Samples)), Tags: fn(cs.Tags), Time: cs.Time}
+	for i, sample := range cs.Samples {
+		// The samples usually share the tags of the container.
+		if sample.Tags == cs.Tags {
+			sample.Tags = res.Tags
+		} else {
+			sample.Tags = fn(sample.Tags)
+		}
+		res.Samples[i] = sample
+	}
+	return res
+}
+
 // GetSamples implement the ConnectedSampleContainer interface
 // for a single Sample, since it's obviously connected with itself :)
 func (s Sample) GetSamples() []Sample {
@@ -364,6 +400,22 @@ func (s Sample) GetTime() time.Time {
 	return s.Time
 }
 
+// A Span identifies an operation that propagated a W3C trace context, eg. an HTTP request.
+type Span struct {
+	TraceID, SpanID string
+	Name            string
+	Start, End      time.Time
+}
+
+// TracedSampleContainer is an extension of the ConnectedSampleContainer
+// interface that should be implemented when emitted samples measure an
+// operation that may have propagated a trace context, so outputs can
+// correlate them with the traces of the system under test.
+type TracedSampleContainer interface {
+	ConnectedSampleContainer
+	GetSpan() (Span, bool)
+}
+
 // Ensure that interfaces are implemented correctly
 var _ SampleContainer = Sample{}
 var _ SampleContainer = Samples{}
@@ -426,6 +478,8 @@ func New(name string, typ MetricType, t ...ValueType) *Metric {
 		sink = &TrendSink{}
 	case Rate:
 		sink = &RateSink{}
+	case Histogram:
+		sink = &HistogramSink{}
 	default:
 		return nil
 	}
@@ -149,10 +149,11 @@ func TestNew(t *testing.T) {
 		Type     MetricType
 		SinkType Sink
 	}{
-		"Counter": {Counter, &CounterSink{}},
-		"Gauge":   {Gauge, &GaugeSink{}},
-		"Trend":   {Trend, &TrendSink{}},
-		"Rate":    {Rate, &RateSink{}},
+		"Counter":   {Counter, &CounterSink{}},
+		"Gauge":     {Gauge, &GaugeSink{}},
+		"Trend":     {Trend, &TrendSink{}},
+		"Rate":      {Rate, &RateSink{}},
+		"Histogram": {Histogram, &HistogramSink{}},
 	}
 
 	for name, data := range testdata {
@@ -0,0 +1,188 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	log "github.com/sirupsen/logrus"
+)
+
+// Characters with a meaning in the StatsD line protocol, replaced in names and tags.
+var sanitizer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", "\n", "_")
+
+// Collector sends samples to a StatsD server or, in DogStatsD mode, a Datadog agent, which also
+// receives tags and trends as distributions.
+type Collector struct {
+	Config    Config
+	DogStatsD bool
+
+	conn      net.Conn
+	blacklist map[string]bool
+
+	buffer     []stats.Sample
+	bufferLock sync.Mutex
+}
+
+var _ lib.Collector = &Collector{}
+
+// New creates an instance of the collector
+func New(conf Config, dogStatsD bool) (*Collector, error) {
+	blacklist := make(map[string]bool, len(conf.TagBlacklist))
+	for _, tag := range conf.TagBlacklist {
+		blacklist[tag] = true
+	}
+	return &Collector{Config: conf, DogStatsD: dogStatsD, blacklist: blacklist}, nil
+}
+
+// Init connects the collector's UDP socket
+func (c *Collector) Init() (err error) {
+	c.conn, err = net.Dial("udp", c.Config.Addr.String)
+	return err
+}
+
+// Run pushes the collected samples every PushInterval, until the context is done
+func (c *Collector) Run(ctx context.Context) {
+	log.Debug("StatsD: Running!")
+	ticker := time.NewTicker(time.Duration(c.Config.PushInterval.Duration))
+	for {
+		select {
+		case <-ticker.C:
+			c.push()
+		case <-ctx.Done():
+			c.push()
+			_ = c.conn.Close()
+			return
+		}
+	}
+}
+
+// Collect buffers the samples until the next push
+func (c *Collector) Collect(scs []stats.SampleContainer) {
+	c.bufferLock.Lock()
+	for _, sc := range scs {
+		c.buffer = append(c.buffer, sc.GetSamples()...)
+	}
+	c.bufferLock.Unlock()
+}
+
+// Link returns the address the samples are sent to
+func (c *Collector) Link() string {
+	return c.Config.Addr.String
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+// SetRunStatus does nothing in the StatsD collector
+func (c *Collector) SetRunStatus(status lib.RunStatus) {}
+
+func (c *Collector) push() {
+	c.bufferLock.Lock()
+	samples := c.buffer
+	c.buffer = nil
+	c.bufferLock.Unlock()
+
+	for _, datagram := range c.datagrams(samples) {
+		if _, err := c.conn.Write(datagram); err != nil {
+			log.WithError(err).Error("StatsD: Couldn't send metrics")
+			return
+		}
+	}
+}
+
+// datagrams formats the samples, batching as many lines as fit into each datagram.
+func (c *Collector) datagrams(samples []stats.Sample) [][]byte {
+	maxSize := int(c.Config.BufferSize.Int64)
+	var datagrams [][]byte
+	var buf bytes.Buffer
+	for _, sample := range samples {
+		line := c.format(sample)
+		if buf.Len() > 0 && buf.Len()+1+len(line) > maxSize {
+			datagrams = append(datagrams, append([]byte(nil), buf.Bytes()...))
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		datagrams = append(datagrams, buf.Bytes())
+	}
+	return datagrams
+}
+
+// format returns the line for a sample, eg. "k6.http_req_duration:123.4|d|#method:GET".
+func (c *Collector) format(sample stats.Sample) string {
+	var line bytes.Buffer
+	line.WriteString(sanitizer.Replace(c.Config.Namespace.String + sample.Metric.Name))
+	line.WriteByte(':')
+	line.WriteString(strconv.FormatFloat(sample.Value, 'f', -1, 64))
+	line.WriteByte('|')
+
+	switch sample.Metric.Type {
+	case stats.Counter:
+		line.WriteString("c")
+	case stats.Gauge:
+		line.WriteString("g")
+	default:
+		// Trends and rates; the average of a rate's 0 and 1 values is the rate itself.
+		if c.DogStatsD {
+			line.WriteString("d")
+		} else {
+			line.WriteString("ms")
+		}
+	}
+
+	if c.DogStatsD {
+		tags := sample.Tags.CloneTags()
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			if !c.blacklist[k] {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			if i == 0 {
+				line.WriteString("|#")
+			} else {
+				line.WriteByte(',')
+			}
+			line.WriteString(sanitizer.Replace(k))
+			line.WriteByte(':')
+			line.WriteString(sanitizer.Replace(tags[k]))
+		}
+	}
+	return line.String()
+}
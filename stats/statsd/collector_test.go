@@ -0,0 +1,116 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statsd
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+)
+
+func testSamples() []stats.SampleContainer {
+	tags := stats.IntoSampleTags(&map[string]string{"method": "GET", "status": "200", "vu": "1", "name": "a|b"})
+	return []stats.SampleContainer{
+		stats.Sample{Metric: stats.New("reqs", stats.Counter), Value: 1, Tags: tags},
+		stats.Sample{Metric: stats.New("vus", stats.Gauge), Value: 10},
+		stats.Sample{Metric: stats.New("duration", stats.Trend, stats.Time), Value: 123.25, Tags: tags},
+		stats.Sample{Metric: stats.New("checks", stats.Rate), Value: 1},
+	}
+}
+
+func TestFormat(t *testing.T) {
+	var samples []stats.Sample
+	for _, sc := range testSamples() {
+		samples = append(samples, sc.GetSamples()...)
+	}
+
+	t.Run("StatsD", func(t *testing.T) {
+		c, err := New(NewConfig(), false)
+		require.NoError(t, err)
+		var lines []string
+		for _, s := range samples {
+			lines = append(lines, c.format(s))
+		}
+		assert.Equal(t, []string{
+			"k6.reqs:1|c",
+			"k6.vus:10|g",
+			"k6.duration:123.25|ms",
+			"k6.checks:1|ms",
+		}, lines)
+	})
+	t.Run("DogStatsD", func(t *testing.T) {
+		c, err := New(NewConfig().Apply(Config{Namespace: null.StringFrom("")}), true)
+		require.NoError(t, err)
+		var lines []string
+		for _, s := range samples {
+			lines = append(lines, c.format(s))
+		}
+		assert.Equal(t, []string{
+			"reqs:1|c|#method:GET,name:a_b,status:200",
+			"vus:10|g",
+			"duration:123.25|d|#method:GET,name:a_b,status:200",
+			"checks:1|d",
+		}, lines)
+	})
+}
+
+func TestDatagrams(t *testing.T) {
+	c, err := New(NewConfig().Apply(Config{BufferSize: null.IntFrom(30)}), false)
+	require.NoError(t, err)
+
+	metric := stats.New("metric", stats.Counter)
+	samples := []stats.Sample{{Metric: metric, Value: 1}, {Metric: metric, Value: 2}, {Metric: metric, Value: 3}}
+	var datagrams []string
+	for _, d := range c.datagrams(samples) {
+		datagrams = append(datagrams, string(d))
+	}
+	assert.Equal(t, []string{"k6.metric:1|c\nk6.metric:2|c", "k6.metric:3|c"}, datagrams)
+}
+
+func TestCollector(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	c, err := New(NewConfig().Apply(Config{Addr: null.StringFrom(conn.LocalAddr().String())}), true)
+	require.NoError(t, err)
+	require.NoError(t, c.Init())
+
+	c.Collect(testSamples())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Run(ctx)
+
+	buf := make([]byte, 2048)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	lines := strings.Split(string(buf[:n]), "\n")
+	assert.Len(t, lines, 4)
+	assert.Equal(t, "k6.reqs:1|c|#method:GET,name:a_b,status:200", lines[0])
+}
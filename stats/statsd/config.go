@@ -0,0 +1,87 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statsd
+
+import (
+	"net"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Config is the config for the StatsD and Datadog collectors
+type Config struct {
+	// Address of the StatsD server or Datadog agent.
+	Addr null.String `json:"addr" envconfig:"STATSD_ADDR"`
+
+	// Prefix for all metric names, eg. "k6.".
+	Namespace null.String `json:"namespace" envconfig:"STATSD_NAMESPACE"`
+
+	PushInterval types.NullDuration `json:"push_interval" envconfig:"STATSD_PUSH_INTERVAL"`
+
+	// Largest datagram to send; metrics are batched into datagrams of up to this size.
+	BufferSize null.Int `json:"buffer_size" envconfig:"STATSD_BUFFER_SIZE"`
+
+	// Tags that aren't sent to Datadog, to keep the number of distinct series down.
+	TagBlacklist []string `json:"tag_blacklist" envconfig:"STATSD_TAG_BLACKLIST"`
+}
+
+// NewConfig creates a new Config instance with default values for some fields.
+func NewConfig() Config {
+	return Config{
+		Addr:         null.StringFrom("localhost:8125"),
+		Namespace:    null.StringFrom("k6."),
+		PushInterval: types.NullDurationFrom(1 * time.Second),
+		BufferSize:   null.IntFrom(1432),
+		TagBlacklist: []string{"vu", "iter", "url"},
+	}
+}
+
+func (c Config) Apply(cfg Config) Config {
+	if cfg.Addr.Valid {
+		c.Addr = cfg.Addr
+	}
+	if cfg.Namespace.Valid {
+		c.Namespace = cfg.Namespace
+	}
+	if cfg.PushInterval.Valid {
+		c.PushInterval = cfg.PushInterval
+	}
+	if cfg.BufferSize.Valid {
+		c.BufferSize = cfg.BufferSize
+	}
+	if cfg.TagBlacklist != nil {
+		c.TagBlacklist = cfg.TagBlacklist
+	}
+	return c
+}
+
+// ParseArg takes the address given as the collector's argument and converts it to a config
+func ParseArg(arg string) (Config, error) {
+	c := Config{}
+	if _, _, err := net.SplitHostPort(arg); err != nil {
+		return c, errors.Wrapf(err, "invalid StatsD address '%s'", arg)
+	}
+	c.Addr = null.StringFrom(arg)
+	return c, nil
+}
@@ -0,0 +1,43 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestConfigParseArg(t *testing.T) {
+	c, err := ParseArg("datadog-agent:8125")
+	assert.Nil(t, err)
+	assert.Equal(t, null.StringFrom("datadog-agent:8125"), c.Addr)
+
+	_, err = ParseArg("datadog-agent")
+	assert.EqualError(t, err, "invalid StatsD address 'datadog-agent': address datadog-agent: missing port in address")
+}
+
+func TestConfigApply(t *testing.T) {
+	c := NewConfig().Apply(Config{TagBlacklist: []string{}})
+	assert.Equal(t, null.StringFrom("localhost:8125"), c.Addr)
+	assert.Equal(t, []string{}, c.TagBlacklist)
+}
@@ -22,6 +22,7 @@ package stats
 
 import (
 	"encoding/json"
+	"regexp"
 	"time"
 
 	"github.com/dop251/goja"
@@ -37,6 +38,9 @@ function p(pct) {
 
 var jsEnv *goja.Program
 
+// Matches threshold sources that refer to the baseline.
+var baselineRefRE = regexp.MustCompile(`\bbaseline\b`)
+
 func init() {
 	pgm, err := goja.Compile("__env__", jsEnvSrc, true)
 	if err != nil {
@@ -56,12 +60,17 @@ type Threshold struct {
 	// AbortGracePeriod is a the minimum amount of time a test should be running before a failing
 	// this threshold will abort the test
 	AbortGracePeriod types.NullDuration
+	// ExitCode is the exit code k6 should use if this threshold fails, or 0 for the default one
+	ExitCode int
 
 	pgm *goja.Program
 	rt  *goja.Runtime
+
+	// Whether the source refers to the baseline, and so can only be run if there is one.
+	usesBaseline bool
 }
 
-func newThreshold(src string, newThreshold *goja.Runtime, abortOnFail bool, gracePeriod types.NullDuration) (*Threshold, error) {
+func newThreshold(src string, newThreshold *goja.Runtime, abortOnFail bool, gracePeriod types.NullDuration, exitCode int) (*Threshold, error) {
 	pgm, err := goja.Compile("__threshold__", src, true)
 	if err != nil {
 		return nil, err
@@ -71,8 +80,10 @@ func newThreshold(src string, newThreshold *goja.Runtime, abortOnFail bool, grac
 		Source:           src,
 		AbortOnFail:      abortOnFail,
 		AbortGracePeriod: gracePeriod,
+		ExitCode:         exitCode,
 		pgm:              pgm,
 		rt:               newThreshold,
+		usesBaseline:     baselineRefRE.MatchString(src),
 	}, nil
 }
 
@@ -94,6 +105,7 @@ type thresholdConfig struct {
 	Threshold        string             `json:"threshold"`
 	AbortOnFail      bool               `json:"abortOnFail"`
 	AbortGracePeriod types.NullDuration `json:"delayAbortEval"`
+	ExitCode         int                `json:"exitCode,omitempty"`
 }
 
 //used internally for JSON marshalling
@@ -110,7 +122,7 @@ func (tc *thresholdConfig) UnmarshalJSON(data []byte) error {
 }
 
 func (tc thresholdConfig) MarshalJSON() ([]byte, error) {
-	if tc.AbortOnFail {
+	if tc.AbortOnFail || tc.ExitCode != 0 {
 		return json.Marshal(rawThresholdConfig(tc))
 	}
 	return json.Marshal(tc.Threshold)
@@ -121,6 +133,9 @@ type Thresholds struct {
 	Runtime    *goja.Runtime
 	Thresholds []*Threshold
 	Abort      bool
+
+	// Whether baseline values were set; thresholds referring to them are skipped otherwise.
+	hasBaseline bool
 }
 
 // NewThresholds returns Thresholds objects representing the provided source strings
@@ -141,14 +156,17 @@ func newThresholdsWithConfig(configs []thresholdConfig) (Thresholds, error) {
 
 	ts := make([]*Threshold, len(configs))
 	for i, config := range configs {
-		t, err := newThreshold(config.Threshold, rt, config.AbortOnFail, config.AbortGracePeriod)
+		if config.ExitCode < 0 || config.ExitCode > 255 {
+			return Thresholds{}, errors.Errorf("%d: exit code %d is out of range", i, config.ExitCode)
+		}
+		t, err := newThreshold(config.Threshold, rt, config.AbortOnFail, config.AbortGracePeriod, config.ExitCode)
 		if err != nil {
 			return Thresholds{}, errors.Wrapf(err, "%d", i)
 		}
 		ts[i] = t
 	}
 
-	return Thresholds{rt, ts, false}, nil
+	return Thresholds{Runtime: rt, Thresholds: ts}, nil
 }
 
 func (ts *Thresholds) updateVM(sink Sink, t time.Duration) error {
@@ -163,6 +181,9 @@ func (ts *Thresholds) updateVM(sink Sink, t time.Duration) error {
 func (ts *Thresholds) runAll(t time.Duration) (bool, error) {
 	succ := true
 	for i, th := range ts.Thresholds {
+		if th.usesBaseline && !ts.hasBaseline {
+			continue
+		}
 		b, err := th.run()
 		if err != nil {
 			return false, errors.Wrapf(err, "%d", i)
@@ -181,6 +202,32 @@ func (ts *Thresholds) runAll(t time.Duration) (bool, error) {
 	return succ, nil
 }
 
+// UsesBaseline returns whether any of the thresholds refer to the baseline.
+func (ts *Thresholds) UsesBaseline() bool {
+	for _, th := range ts.Thresholds {
+		if th.usesBaseline {
+			return true
+		}
+	}
+	return false
+}
+
+// ExitCode returns the exit code of the first failed threshold that has one, or 0 if none do.
+func (ts *Thresholds) ExitCode() int {
+	for _, th := range ts.Thresholds {
+		if th.LastFailed && th.ExitCode != 0 {
+			return th.ExitCode
+		}
+	}
+	return 0
+}
+
+// SetBaseline makes the given values available to the thresholds as "baseline".
+func (ts *Thresholds) SetBaseline(values BaselineValues) {
+	ts.Runtime.Set("baseline", values.jsObject())
+	ts.hasBaseline = true
+}
+
 // Run processes all the thresholds with the provided Sink at the provided time and returns if any
 // of them fails
 func (ts *Thresholds) Run(sink Sink, t time.Duration) (bool, error) {
@@ -211,6 +258,7 @@ func (ts Thresholds) MarshalJSON() ([]byte, error) {
 		configs[i].Threshold = t.Source
 		configs[i].AbortOnFail = t.AbortOnFail
 		configs[i].AbortGracePeriod = t.AbortGracePeriod
+		configs[i].ExitCode = t.ExitCode
 	}
 	return json.Marshal(configs)
 }
@@ -28,6 +28,7 @@ import (
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewThreshold(t *testing.T) {
@@ -35,7 +36,7 @@ func TestNewThreshold(t *testing.T) {
 	rt := goja.New()
 	abortOnFail := false
 	gracePeriod := types.NullDurationFrom(2 * time.Second)
-	th, err := newThreshold(src, rt, abortOnFail, gracePeriod)
+	th, err := newThreshold(src, rt, abortOnFail, gracePeriod, 0)
 	assert.NoError(t, err)
 
 	assert.Equal(t, src, th.Source)
@@ -48,7 +49,7 @@ func TestNewThreshold(t *testing.T) {
 
 func TestThresholdRun(t *testing.T) {
 	t.Run("true", func(t *testing.T) {
-		th, err := newThreshold(`1+1==2`, goja.New(), false, types.NullDuration{})
+		th, err := newThreshold(`1+1==2`, goja.New(), false, types.NullDuration{}, 0)
 		assert.NoError(t, err)
 
 		t.Run("no taint", func(t *testing.T) {
@@ -67,7 +68,7 @@ func TestThresholdRun(t *testing.T) {
 	})
 
 	t.Run("false", func(t *testing.T) {
-		th, err := newThreshold(`1+1==4`, goja.New(), false, types.NullDuration{})
+		th, err := newThreshold(`1+1==4`, goja.New(), false, types.NullDuration{}, 0)
 		assert.NoError(t, err)
 
 		t.Run("no taint", func(t *testing.T) {
@@ -115,8 +116,8 @@ func TestNewThresholdsWithConfig(t *testing.T) {
 	})
 	t.Run("two", func(t *testing.T) {
 		configs := []thresholdConfig{
-			{`1+1==2`, false, types.NullDuration{}},
-			{`1+1==4`, true, types.NullDuration{}},
+			{`1+1==2`, false, types.NullDuration{}, 0},
+			{`1+1==4`, true, types.NullDuration{}, 0},
 		}
 		ts, err := newThresholdsWithConfig(configs)
 		assert.NoError(t, err)
@@ -216,6 +217,74 @@ func TestThresholdsRun(t *testing.T) {
 	})
 }
 
+func TestThresholdsBaseline(t *testing.T) {
+	ts, err := NewThresholds([]string{"avg>0", "avg < baseline.avg * 1.1", "p(95) < baseline.p(95)"})
+	assert.NoError(t, err)
+	assert.True(t, ts.UsesBaseline())
+	assert.False(t, ts.Thresholds[0].usesBaseline)
+
+	sink := &TrendSink{}
+	for i := 1; i <= 100; i++ {
+		sink.Add(Sample{Value: float64(i)})
+	}
+	sink.Calc()
+
+	t.Run("no baseline", func(t *testing.T) {
+		b, err := ts.Run(sink, 0)
+		assert.NoError(t, err)
+		assert.True(t, b)
+		assert.False(t, ts.Thresholds[1].LastFailed)
+	})
+
+	t.Run("pass", func(t *testing.T) {
+		ts.SetBaseline(BaselineValues{"avg": 50, "p(90)": 90, "p(99)": 100})
+		b, err := ts.Run(sink, 0)
+		assert.NoError(t, err)
+		assert.True(t, b)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		ts.SetBaseline(BaselineValues{"avg": 50, "p(95)": 90})
+		b, err := ts.Run(sink, 0)
+		assert.NoError(t, err)
+		assert.False(t, b)
+		assert.True(t, ts.Thresholds[2].LastFailed)
+	})
+
+	noBaseline, err := NewThresholds([]string{"baselines > 0", "a.baseline > 0"})
+	assert.NoError(t, err)
+	assert.True(t, noBaseline.UsesBaseline(), "matches a property named baseline, which is harmless")
+	assert.False(t, noBaseline.Thresholds[0].usesBaseline)
+}
+
+func TestThresholdsExitCode(t *testing.T) {
+	var ts Thresholds
+	src := `[{"threshold":"1+1==2","exitCode":10},{"threshold":"1+1==3","exitCode":20},"1+1==4"]`
+	require.NoError(t, json.Unmarshal([]byte(src), &ts))
+	assert.Equal(t, 10, ts.Thresholds[0].ExitCode)
+	assert.Equal(t, 20, ts.Thresholds[1].ExitCode)
+	assert.Equal(t, 0, ts.Thresholds[2].ExitCode)
+
+	data, err := json.Marshal(ts)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"threshold":"1+1==2","abortOnFail":false,"delayAbortEval":null,"exitCode":10},`+
+		`{"threshold":"1+1==3","abortOnFail":false,"delayAbortEval":null,"exitCode":20},"1+1==4"]`, string(data))
+
+	assert.Equal(t, 0, ts.ExitCode())
+	_, err = ts.runAll(0)
+	require.NoError(t, err)
+	assert.Equal(t, 20, ts.ExitCode())
+
+	ts.Thresholds[1].LastFailed = false
+	assert.Equal(t, 0, ts.ExitCode(), "thresholds without an exit code use the default one")
+
+	t.Run("out of range", func(t *testing.T) {
+		var ts Thresholds
+		assert.Error(t, json.Unmarshal([]byte(`[{"threshold":"1+1==2","exitCode":256}]`), &ts))
+		assert.Error(t, json.Unmarshal([]byte(`[{"threshold":"1+1==2","exitCode":-1}]`), &ts))
+	})
+}
+
 func TestThresholdsJSON(t *testing.T) {
 	var testdata = []struct {
 		JSON        string
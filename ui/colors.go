@@ -30,6 +30,7 @@ var (
 
 	SuccColor     = color.New(color.FgGreen)             // Successful stuff.
 	FailColor     = color.New(color.FgRed)               // Failed stuff.
+	WarnColor     = color.New(color.FgYellow)            // Failed stuff that's only a warning.
 	GrayColor     = color.New(color.Faint)               // Padding and disabled stuff.
 	ValueColor    = color.New(color.FgCyan)              // Values of all kinds.
 	ExtraColor    = color.New(color.FgCyan, color.Faint) // Extra annotations for values.
@@ -0,0 +1,149 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package export writes the threshold and check results of a test run in formats that CI systems
+// and code review tools understand, JUnit XML and SARIF, so they can show them natively.
+package export
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/loadimpact/k6/ui"
+)
+
+// Data is everything the results are exported from.
+type Data struct {
+	Script  string
+	Version string
+	Opts    lib.Options
+	Root    *lib.Group
+	Metrics map[string]*stats.Metric
+	Time    time.Duration
+}
+
+type thresholdResult struct {
+	Metric string
+	Source string
+	Failed bool
+
+	// The metric's values, as in the summary.
+	Values string
+}
+
+// thresholdResults returns the results of all thresholds, sorted by metric.
+func thresholdResults(data Data) []thresholdResult {
+	names := make([]string, 0, len(data.Metrics))
+	for name, m := range data.Metrics {
+		if len(m.Thresholds.Thresholds) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var results []thresholdResult
+	for _, name := range names {
+		m := data.Metrics[name]
+		values := metricValues(m, data.Time, data.Opts.SummaryTimeUnit.String)
+		for _, th := range m.Thresholds.Thresholds {
+			results = append(results, thresholdResult{Metric: name, Source: th.Source, Failed: th.LastFailed, Values: values})
+		}
+	}
+	return results
+}
+
+func metricValues(m *stats.Metric, t time.Duration, timeUnit string) string {
+	m.Sink.Calc()
+	if sink, ok := m.Sink.(stats.PercentileSink); ok {
+		cols := make([]string, len(ui.TrendColumns))
+		for i, col := range ui.TrendColumns {
+			cols[i] = col.Key + "=" + m.HumanizeValue(col.Get(sink), timeUnit)
+		}
+		return strings.Join(cols, " ")
+	}
+	value, extra := ui.NonTrendMetricValueForSum(t, timeUnit, m)
+	return strings.Join(append([]string{value}, extra...), " ")
+}
+
+// checks returns all checks in the group and the groups in it, sorted by group and name.
+func checks(group *lib.Group, result []*lib.Check) []*lib.Check {
+	if group == nil {
+		return result
+	}
+	names := make([]string, 0, len(group.Checks))
+	for name := range group.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result = append(result, group.Checks[name])
+	}
+
+	groupNames := make([]string, 0, len(group.Groups))
+	for name := range group.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+	for _, name := range groupNames {
+		result = checks(group.Groups[name], result)
+	}
+	return result
+}
+
+// checkFailed returns whether a check failed in a way that should fail the build; failures of
+// checks with the "warn" severity don't.
+func checkFailed(c *lib.Check) bool {
+	return c.Fails > 0 && c.GetSeverity() != lib.CheckSeverityWarn
+}
+
+func checkMessage(c *lib.Check) string {
+	if c.Fails == 0 {
+		return fmt.Sprintf("The check '%s' passed %d times", c.Name, c.Passes)
+	}
+	return fmt.Sprintf("The check '%s' failed %d of %d times", c.Name, c.Fails, c.Passes+c.Fails)
+}
+
+func thresholdMessage(th thresholdResult) string {
+	status := "passed"
+	if th.Failed {
+		status = "failed"
+	}
+	return fmt.Sprintf("The threshold '%s' on %s %s: %s", th.Source, th.Metric, status, th.Values)
+}
+
+// failureDetails describes the failures of a check that were kept, one per line.
+func failureDetails(c *lib.Check) string {
+	var lines []string
+	for _, f := range c.GetFailureSamples() {
+		line := fmt.Sprintf("vu %d, iter %d", f.VU, f.Iteration)
+		if f.Message != "" {
+			line += ": " + f.Message
+		}
+		if f.Expected != nil || f.Actual != nil {
+			line += fmt.Sprintf(" (expected %v, got %v)", f.Expected, f.Actual)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
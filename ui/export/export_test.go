@@ -0,0 +1,163 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestData(t *testing.T) Data {
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	ths, err := stats.NewThresholds([]string{"p(95)<100", "avg<500"})
+	require.NoError(t, err)
+	ths.Thresholds[0].LastFailed = true
+	duration.Thresholds = ths
+	for _, v := range []float64{20, 200, 400} {
+		duration.Sink.Add(stats.Sample{Metric: duration, Value: v})
+	}
+	reqs := stats.New("http_reqs", stats.Counter)
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	ok, err := root.Check("homepage loads")
+	require.NoError(t, err)
+	ok.Passes = 10
+	group, err := root.Group("login")
+	require.NoError(t, err)
+	status, err := group.Check("status is <200>")
+	require.NoError(t, err)
+	status.Passes, status.Fails = 3, 1
+	status.AddFailure(lib.CheckFailure{VU: 2, Iteration: 7, Expected: 200, Actual: 503, Message: "unavailable"})
+	slow, err := group.Check("fast enough")
+	require.NoError(t, err)
+	slow.SetSeverity(lib.CheckSeverityWarn)
+	slow.Passes, slow.Fails = 2, 2
+
+	return Data{
+		Script:  "tests/script.js",
+		Version: "0.22.0",
+		Root:    root,
+		Metrics: map[string]*stats.Metric{duration.Name: duration, reqs.Name: reqs},
+		Time:    10 * time.Second,
+	}
+}
+
+func TestJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, JUnit(&buf, newTestData(t)))
+	assert.Contains(t, buf.String(), xml.Header)
+
+	var doc junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "k6", doc.Name)
+	assert.Equal(t, 5, doc.Tests)
+	assert.Equal(t, 2, doc.Failures)
+	assert.Equal(t, "10.000", doc.Time)
+	require.Len(t, doc.Suites, 2)
+
+	thresholds := doc.Suites[0]
+	assert.Equal(t, "thresholds", thresholds.Name)
+	assert.Equal(t, 2, thresholds.Tests)
+	assert.Equal(t, 1, thresholds.Failures)
+	require.Len(t, thresholds.Cases, 2)
+	assert.Equal(t, "p(95)<100", thresholds.Cases[0].Name)
+	assert.Equal(t, "thresholds.http_req_duration", thresholds.Cases[0].ClassName)
+	if assert.NotNil(t, thresholds.Cases[0].Failure) {
+		assert.Equal(t, "threshold", thresholds.Cases[0].Failure.Type)
+		assert.Contains(t, thresholds.Cases[0].Failure.Message, "The threshold 'p(95)<100' on http_req_duration failed")
+		assert.Contains(t, thresholds.Cases[0].Failure.Details, "p(95)=380ms")
+	}
+	assert.Nil(t, thresholds.Cases[1].Failure)
+
+	checks := doc.Suites[1]
+	assert.Equal(t, "checks", checks.Name)
+	assert.Equal(t, 3, checks.Tests)
+	assert.Equal(t, 1, checks.Failures)
+	require.Len(t, checks.Cases, 3)
+	assert.Equal(t, "homepage loads", checks.Cases[0].Name)
+	assert.Equal(t, "checks", checks.Cases[0].ClassName)
+	assert.Nil(t, checks.Cases[0].Failure)
+
+	assert.Equal(t, "fast enough", checks.Cases[1].Name)
+	assert.Equal(t, "checks.login", checks.Cases[1].ClassName)
+	assert.Nil(t, checks.Cases[1].Failure)
+	assert.Equal(t, "The check 'fast enough' failed 2 of 4 times", checks.Cases[1].SystemOut)
+
+	assert.Equal(t, "status is <200>", checks.Cases[2].Name)
+	if assert.NotNil(t, checks.Cases[2].Failure) {
+		assert.Equal(t, "The check 'status is <200>' failed 1 of 4 times", checks.Cases[2].Failure.Message)
+		assert.Equal(t, "vu 2, iter 7: unavailable (expected 200, got 503)", checks.Cases[2].Failure.Details)
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, SARIF(&buf, newTestData(t)))
+
+	var doc sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, SARIFVersion, doc.Version)
+	assert.Contains(t, buf.String(), `"$schema": "`+SARIFSchema+`"`)
+	require.Len(t, doc.Runs, 1)
+	driver := doc.Runs[0].Tool.Driver
+	assert.Equal(t, "k6", driver.Name)
+	assert.Equal(t, "0.22.0", driver.Version)
+	require.Len(t, driver.Rules, 2)
+
+	results := doc.Runs[0].Results
+	require.Len(t, results, 5)
+	levels := make([]string, len(results))
+	for i, r := range results {
+		levels[i] = r.Level
+		require.Len(t, r.Locations, 1)
+		assert.Equal(t, "tests/script.js", r.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	}
+	assert.Equal(t, []string{"error", "none", "none", "warning", "error"}, levels)
+
+	assert.Equal(t, SARIFThresholdRule, results[0].RuleID)
+	assert.Equal(t, "fail", results[0].Kind)
+	assert.Equal(t, "http_req_duration", results[0].Locations[0].LogicalLocations[0].Name)
+	assert.Equal(t, "pass", results[1].Kind)
+
+	status := results[4]
+	assert.Equal(t, SARIFCheckRule, status.RuleID)
+	assert.Equal(t, "The check 'status is <200>' failed 1 of 4 times", status.Message.Text)
+	assert.Equal(t, "::login::status is <200>", status.Locations[0].LogicalLocations[0].FullyQualifiedName)
+	assert.Equal(t, "::login", status.Properties["group"])
+	assert.Equal(t, float64(1), status.Properties["fails"])
+	assert.Equal(t, "vu 2, iter 7: unavailable (expected 200, got 503)", status.Properties["failures"])
+
+	t.Run("no results", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, SARIF(&buf, Data{Script: "-"}))
+		assert.Contains(t, buf.String(), `"results": []`)
+		assert.NotContains(t, buf.String(), "physicalLocation")
+	})
+}
@@ -0,0 +1,106 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Details string `xml:",chardata"`
+}
+
+// JUnit writes the results as JUnit XML, with a test suite for the thresholds, where every
+// threshold is a test case named after its source in a class named after its metric, and one
+// for the checks, in classes named after their groups. Failed checks with the "warn" severity
+// pass, with their failures in the system-out.
+func JUnit(w io.Writer, data Data) error {
+	thresholds := junitTestSuite{Name: "thresholds"}
+	for _, th := range thresholdResults(data) {
+		tc := junitTestCase{Name: th.Source, ClassName: "thresholds." + th.Metric}
+		if th.Failed {
+			tc.Failure = &junitFailure{Message: thresholdMessage(th), Type: "threshold", Details: th.Values}
+			thresholds.Failures++
+		}
+		thresholds.Cases = append(thresholds.Cases, tc)
+	}
+	thresholds.Tests = len(thresholds.Cases)
+
+	checkSuite := junitTestSuite{Name: "checks"}
+	for _, c := range checks(data.Root, nil) {
+		tc := junitTestCase{Name: c.Name, ClassName: "checks" + strings.Replace(c.Group.Path, "::", ".", -1)}
+		switch {
+		case checkFailed(c):
+			tc.Failure = &junitFailure{Message: checkMessage(c), Type: "check", Details: failureDetails(c)}
+			checkSuite.Failures++
+		case c.Fails > 0:
+			tc.SystemOut = strings.TrimSpace(checkMessage(c) + "\n" + failureDetails(c))
+		}
+		checkSuite.Cases = append(checkSuite.Cases, tc)
+	}
+	checkSuite.Tests = len(checkSuite.Cases)
+
+	doc := junitTestSuites{
+		Name:     "k6",
+		Tests:    thresholds.Tests + checkSuite.Tests,
+		Failures: thresholds.Failures + checkSuite.Failures,
+		Time:     fmt.Sprintf("%.3f", data.Time.Seconds()),
+		Suites:   []junitTestSuite{thresholds, checkSuite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
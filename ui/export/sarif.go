@@ -0,0 +1,164 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+
+	"github.com/loadimpact/k6/lib"
+)
+
+// The SARIF version that's written, and its schema.
+const (
+	SARIFVersion = "2.1.0"
+	SARIFSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// The IDs of the rules that results are reported for.
+const (
+	SARIFThresholdRule = "k6/threshold"
+	SARIFCheckRule     = "k6/check"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Kind       string                 `json:"kind"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName,omitempty"`
+	Kind               string `json:"kind"`
+}
+
+// SARIF writes the results as a SARIF log, with a result for every threshold and check. Failures
+// are errors, or warnings for checks with the "warn" severity, and the rest are passes. They're
+// all located in the script, since it's what a code review tool can point at.
+func SARIF(w io.Writer, data Data) error {
+	var results []sarifResult
+	for _, th := range thresholdResults(data) {
+		r := newSARIFResult(data, SARIFThresholdRule, !th.Failed, lib.CheckSeverityError, thresholdMessage(th))
+		r.Locations[0].LogicalLocations = []sarifLogicalLocation{{Name: th.Metric, Kind: "member"}}
+		r.Properties = map[string]interface{}{"metric": th.Metric, "threshold": th.Source}
+		results = append(results, r)
+	}
+	for _, c := range checks(data.Root, nil) {
+		r := newSARIFResult(data, SARIFCheckRule, c.Fails == 0, c.GetSeverity(), checkMessage(c))
+		r.Locations[0].LogicalLocations = []sarifLogicalLocation{{Name: c.Name, FullyQualifiedName: c.Path, Kind: "member"}}
+		r.Properties = map[string]interface{}{"group": c.Group.Path, "passes": c.Passes, "fails": c.Fails}
+		if details := failureDetails(c); details != "" {
+			r.Properties["failures"] = details
+		}
+		results = append(results, r)
+	}
+	if results == nil {
+		results = []sarifResult{}
+	}
+
+	doc := sarifLog{
+		Version: SARIFVersion,
+		Schema:  SARIFSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "k6",
+				Version:        data.Version,
+				InformationURI: "https://k6.io",
+				Rules: []sarifRule{
+					{ID: SARIFThresholdRule, Name: "Threshold", ShortDescription: sarifMessage{"Thresholds on the test's metrics must pass"}},
+					{ID: SARIFCheckRule, Name: "Check", ShortDescription: sarifMessage{"Checks in the script must not fail"}},
+				},
+			}},
+			Results: results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func newSARIFResult(data Data, rule string, passed bool, severity, message string) sarifResult {
+	r := sarifResult{RuleID: rule, Kind: "pass", Level: "none", Message: sarifMessage{message}}
+	switch {
+	case passed:
+	case severity == lib.CheckSeverityWarn:
+		r.Kind, r.Level = "fail", "warning"
+	default:
+		r.Kind, r.Level = "fail", "error"
+	}
+
+	loc := sarifLocation{}
+	if data.Script != "" && data.Script != "-" {
+		loc.PhysicalLocation = &sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(data.Script)}}
+	}
+	r.Locations = []sarifLocation{loc}
+	return r
+}
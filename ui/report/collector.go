@@ -0,0 +1,129 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package report
+
+import (
+	"context"
+	"sync"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+)
+
+// DistributionBounds are the upper bounds, in milliseconds, of the buckets that time-based
+// trend samples are counted into for the latency distribution charts. Samples above the last
+// bound are counted in an overflow bucket.
+var DistributionBounds = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Distribution counts the samples of a trend metric into DistributionBounds buckets.
+type Distribution struct {
+	Counts []uint64
+	Total  uint64
+}
+
+func newDistribution() *Distribution {
+	return &Distribution{Counts: make([]uint64, len(DistributionBounds)+1)}
+}
+
+// Add counts a single value into the matching bucket.
+func (d *Distribution) Add(v float64) {
+	i := 0
+	for i < len(DistributionBounds) && v > DistributionBounds[i] {
+		i++
+	}
+	d.Counts[i]++
+	d.Total++
+}
+
+// Collector implements the lib.Collector interface and gathers the data the HTML report needs
+// that the engine doesn't keep by itself: latency distributions for time-based trend metrics,
+// and per-group breakdowns of all trend metrics. Breakdowns are kept in histogram sinks, so
+// memory use doesn't grow with the number of samples.
+type Collector struct {
+	mutex         sync.Mutex
+	distributions map[string]*Distribution
+	groups        map[string]map[string]*stats.HistogramSink
+}
+
+// Verify that Collector implements lib.Collector
+var _ lib.Collector = &Collector{}
+
+// New creates a new report collector.
+func New() *Collector {
+	return &Collector{
+		distributions: make(map[string]*Distribution),
+		groups:        make(map[string]map[string]*stats.HistogramSink),
+	}
+}
+
+// Init does nothing, it's only included to satisfy the lib.Collector interface
+func (c *Collector) Init() error { return nil }
+
+// Run just blocks until the context is done, all of the work is done in Collect
+func (c *Collector) Run(ctx context.Context) { <-ctx.Done() }
+
+// Collect adds the trend samples to the distributions and group breakdowns.
+func (c *Collector) Collect(scs []stats.SampleContainer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, sc := range scs {
+		for _, sample := range sc.GetSamples() {
+			if sample.Metric.Type != stats.Trend {
+				continue
+			}
+			name := sample.Metric.Name
+
+			if sample.Metric.Contains == stats.Time {
+				dist, ok := c.distributions[name]
+				if !ok {
+					dist = newDistribution()
+					c.distributions[name] = dist
+				}
+				dist.Add(sample.Value)
+			}
+
+			group, _ := sample.Tags.Get("group")
+			sinks, ok := c.groups[group]
+			if !ok {
+				sinks = make(map[string]*stats.HistogramSink)
+				c.groups[group] = sinks
+			}
+			sink, ok := sinks[name]
+			if !ok {
+				sink = &stats.HistogramSink{}
+				sinks[name] = sink
+			}
+			sink.Add(sample)
+		}
+	}
+}
+
+// Link returns nothing, the report is written to a file after the test
+func (c *Collector) Link() string { return "" }
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{"group": true}
+}
+
+// SetRunStatus does nothing, it's only included to satisfy the lib.Collector interface
+func (c *Collector) SetRunStatus(status lib.RunStatus) {}
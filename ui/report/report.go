@@ -0,0 +1,282 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/loadimpact/k6/ui"
+)
+
+// Data is everything the report is rendered from, besides what the Collector gathered.
+type Data struct {
+	Script  string
+	Opts    lib.Options
+	Root    *lib.Group
+	Metrics map[string]*stats.Metric
+	Time    time.Duration
+}
+
+type metricRow struct {
+	Name   string
+	Sub    bool
+	Status thresholdStatus
+	Values string
+}
+
+// thresholdStatus mirrors the three states of a metric's Tainted flag, so the template doesn't
+// have to deal with null.Bool.
+type thresholdStatus int
+
+const (
+	noThresholds thresholdStatus = iota
+	passed
+	failed
+)
+
+func (s thresholdStatus) Class() string {
+	switch s {
+	case passed:
+		return "pass"
+	case failed:
+		return "fail"
+	default:
+		return ""
+	}
+}
+
+type thresholdRow struct {
+	Metric string
+	Source string
+	Failed bool
+}
+
+type checkRow struct {
+	Group  string
+	Name   string
+	Passes int64
+	Fails  int64
+	Rate   string
+}
+
+type bucketRow struct {
+	Label   string
+	Count   uint64
+	Percent float64
+}
+
+type distribution struct {
+	Metric  string
+	Buckets []bucketRow
+}
+
+type groupRow struct {
+	Metric string
+	Count  uint64
+	Values []string
+}
+
+type groupBreakdown struct {
+	Group string
+	Rows  []groupRow
+}
+
+type view struct {
+	Script        string
+	Duration      string
+	Generated     string
+	Passed        bool
+	TrendColumns  []string
+	Metrics       []metricRow
+	Thresholds    []thresholdRow
+	Checks        []checkRow
+	Distributions []distribution
+	Groups        []groupBreakdown
+}
+
+// Render writes a self-contained HTML report to w.
+func Render(w io.Writer, data Data, c *Collector) error {
+	return reportTemplate.Execute(w, newView(data, c))
+}
+
+func newView(data Data, c *Collector) view {
+	timeUnit := data.Opts.SummaryTimeUnit.String
+	v := view{
+		Script:    data.Script,
+		Duration:  data.Time.String(),
+		Generated: time.Now().Format(time.RFC1123),
+		Passed:    true,
+	}
+	for _, col := range ui.TrendColumns {
+		v.TrendColumns = append(v.TrendColumns, col.Key)
+	}
+
+	names := make([]string, 0, len(data.Metrics))
+	for name := range data.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m := data.Metrics[name]
+		row := metricRow{Name: ui.DisplayNameForMetric(m), Sub: m.Sub.Parent != ""}
+		if m.Tainted.Valid {
+			row.Status = passed
+			if m.Tainted.Bool {
+				row.Status = failed
+				v.Passed = false
+			}
+		}
+
+		m.Sink.Calc()
+		if sink, ok := m.Sink.(stats.PercentileSink); ok {
+			cols := make([]string, len(ui.TrendColumns))
+			for i, col := range ui.TrendColumns {
+				cols[i] = col.Key + "=" + m.HumanizeValue(col.Get(sink), timeUnit)
+			}
+			row.Values = strings.Join(cols, " ")
+		} else {
+			value, extra := ui.NonTrendMetricValueForSum(data.Time, timeUnit, m)
+			row.Values = strings.Join(append([]string{value}, extra...), " ")
+		}
+		v.Metrics = append(v.Metrics, row)
+
+		for _, th := range m.Thresholds.Thresholds {
+			v.Thresholds = append(v.Thresholds, thresholdRow{Metric: name, Source: th.Source, Failed: th.LastFailed})
+		}
+	}
+
+	if data.Root != nil {
+		v.Checks = checkRows(data.Root, nil)
+	}
+
+	if c != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		v.Distributions = c.distributionViews()
+		v.Groups = c.groupViews(data.Metrics, timeUnit)
+	}
+	return v
+}
+
+func checkRows(group *lib.Group, rows []checkRow) []checkRow {
+	names := make([]string, 0, len(group.Checks))
+	for name := range group.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check := group.Checks[name]
+		rate := "-"
+		if total := check.Passes + check.Fails; total > 0 {
+			rate = fmt.Sprintf("%.2f%%", 100*float64(check.Passes)/float64(total))
+		}
+		rows = append(rows, checkRow{Group: group.Path, Name: name, Passes: check.Passes, Fails: check.Fails, Rate: rate})
+	}
+
+	groupNames := make([]string, 0, len(group.Groups))
+	for name := range group.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+	for _, name := range groupNames {
+		rows = checkRows(group.Groups[name], rows)
+	}
+	return rows
+}
+
+func (c *Collector) distributionViews() []distribution {
+	names := make([]string, 0, len(c.distributions))
+	for name := range c.distributions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	views := make([]distribution, 0, len(names))
+	for _, name := range names {
+		dist := c.distributions[name]
+		var most uint64
+		for _, n := range dist.Counts {
+			if n > most {
+				most = n
+			}
+		}
+		buckets := make([]bucketRow, len(dist.Counts))
+		for i, n := range dist.Counts {
+			label := "> " + formatBound(DistributionBounds[len(DistributionBounds)-1])
+			if i < len(DistributionBounds) {
+				label = "≤ " + formatBound(DistributionBounds[i])
+			}
+			buckets[i] = bucketRow{Label: label, Count: n}
+			if most > 0 {
+				buckets[i].Percent = 100 * float64(n) / float64(most)
+			}
+		}
+		views = append(views, distribution{Metric: name, Buckets: buckets})
+	}
+	return views
+}
+
+func formatBound(ms float64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+func (c *Collector) groupViews(metrics map[string]*stats.Metric, timeUnit string) []groupBreakdown {
+	groups := make([]string, 0, len(c.groups))
+	for group := range c.groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	views := make([]groupBreakdown, 0, len(groups))
+	for _, group := range groups {
+		sinks := c.groups[group]
+		names := make([]string, 0, len(sinks))
+		for name := range sinks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		breakdown := groupBreakdown{Group: group}
+		if group == "" {
+			breakdown.Group = "(root)"
+		}
+		for _, name := range names {
+			sink := sinks[name]
+			m := metrics[name]
+			if m == nil {
+				m = stats.New(name, stats.Trend)
+			}
+			values := make([]string, len(ui.TrendColumns))
+			for i, col := range ui.TrendColumns {
+				values[i] = m.HumanizeValue(col.Get(sink), timeUnit)
+			}
+			breakdown.Rows = append(breakdown.Rows, groupRow{Metric: name, Count: sink.Count, Values: values})
+		}
+		views = append(views, breakdown)
+	}
+	return views
+}
@@ -0,0 +1,115 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestDistribution(t *testing.T) {
+	d := newDistribution()
+	for _, v := range []float64{0, 1, 3, 5, 9000, 20000} {
+		d.Add(v)
+	}
+	assert.Equal(t, uint64(6), d.Total)
+	assert.Equal(t, uint64(2), d.Counts[0])
+	assert.Equal(t, uint64(2), d.Counts[1])
+	assert.Equal(t, uint64(1), d.Counts[len(DistributionBounds)-1])
+	assert.Equal(t, uint64(1), d.Counts[len(DistributionBounds)])
+}
+
+func TestCollector(t *testing.T) {
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	size := stats.New("size", stats.Trend)
+	reqs := stats.New("http_reqs", stats.Counter)
+
+	c := New()
+	c.Collect([]stats.SampleContainer{stats.Samples{
+		{Metric: duration, Value: 20, Tags: stats.NewSampleTags(map[string]string{"group": ""})},
+		{Metric: duration, Value: 200, Tags: stats.NewSampleTags(map[string]string{"group": "::login"})},
+		{Metric: duration, Value: 400, Tags: stats.NewSampleTags(map[string]string{"group": "::login"})},
+		{Metric: size, Value: 1024, Tags: stats.NewSampleTags(map[string]string{"group": "::login"})},
+		{Metric: reqs, Value: 1, Tags: stats.NewSampleTags(map[string]string{"group": "::login"})},
+	}})
+
+	require.Len(t, c.distributions, 1)
+	assert.Equal(t, uint64(3), c.distributions["http_req_duration"].Total)
+
+	require.Len(t, c.groups, 2)
+	assert.Equal(t, uint64(1), c.groups[""]["http_req_duration"].Count)
+	assert.Equal(t, uint64(2), c.groups["::login"]["http_req_duration"].Count)
+	assert.Equal(t, uint64(1), c.groups["::login"]["size"].Count)
+	assert.NotContains(t, c.groups["::login"], "http_reqs")
+}
+
+func TestRender(t *testing.T) {
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	ths, err := stats.NewThresholds([]string{"p(95)<100"})
+	require.NoError(t, err)
+	ths.Thresholds[0].LastFailed = true
+	duration.Thresholds = ths
+	duration.Tainted = null.BoolFrom(true)
+	reqs := stats.New("http_reqs", stats.Counter)
+
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	group, err := root.Group("login")
+	require.NoError(t, err)
+	check, err := group.Check("status is <200>")
+	require.NoError(t, err)
+	check.Passes, check.Fails = 3, 1
+
+	c := New()
+	samples := stats.Samples{}
+	for _, v := range []float64{20, 200, 400} {
+		sample := stats.Sample{Metric: duration, Value: v, Tags: stats.NewSampleTags(map[string]string{"group": group.Path})}
+		duration.Sink.Add(sample)
+		samples = append(samples, sample)
+	}
+	reqs.Sink.Add(stats.Sample{Metric: reqs, Value: 3})
+	c.Collect([]stats.SampleContainer{samples})
+
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, Data{
+		Script:  "script.js",
+		Root:    root,
+		Metrics: map[string]*stats.Metric{duration.Name: duration, reqs.Name: reqs},
+		Time:    10 * time.Second,
+	}, c))
+
+	html := buf.String()
+	assert.Contains(t, html, "<title>k6 report - script.js</title>")
+	assert.Contains(t, html, `<span class="verdict fail">FAILED</span>`)
+	assert.Contains(t, html, "<td>http_req_duration</td><td>p(95)&lt;100</td><td class=\"fail\">")
+	assert.Contains(t, html, "<td>status is &lt;200&gt;</td>")
+	assert.Contains(t, html, `<td class="num">75.00%</td>`)
+	assert.Contains(t, html, "<h3>::login</h3>")
+	assert.Contains(t, html, `<td class="label">≤ 250ms</td><td class="num count">1</td>`)
+	assert.Contains(t, html, `<td class="values">3 0.3/s</td>`)
+}
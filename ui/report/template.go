@@ -0,0 +1,104 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package report
+
+import "html/template"
+
+// The report is a single file with inline styles and CSS-only charts, so it can be mailed
+// around or attached to a CI run without anything else having to be served alongside it.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>k6 report{{ if .Script }} - {{ .Script }}{{ end }}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 1100px; color: #222; }
+h1 { margin-bottom: 0.2em; }
+h2 { margin-top: 2em; border-bottom: 1px solid #ddd; padding-bottom: 0.2em; }
+.meta { color: #666; }
+.verdict { display: inline-block; padding: 0.2em 0.8em; border-radius: 3px; color: #fff; font-weight: bold; }
+.verdict.pass { background: #2a9d4b; }
+.verdict.fail { background: #d33a2c; }
+table { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #eee; }
+th { background: #f6f6f6; }
+td.num { text-align: right; font-family: monospace; }
+td.values { font-family: monospace; }
+tr.sub td:first-child { padding-left: 2em; }
+.pass { color: #2a9d4b; }
+.fail { color: #d33a2c; }
+.bar { background: #7d64ff; height: 1em; }
+.chart td { border: none; padding: 0.1em 0.6em; }
+.chart td.label { width: 6em; font-family: monospace; }
+.chart td.count { width: 6em; }
+</style>
+</head>
+<body>
+<h1>k6 report</h1>
+<p class="meta">{{ if .Script }}<b>{{ .Script }}</b> &middot; {{ end }}duration {{ .Duration }} &middot; generated {{ .Generated }}</p>
+<p>{{ if .Passed }}<span class="verdict pass">PASSED</span>{{ else }}<span class="verdict fail">FAILED</span>{{ end }}</p>
+
+{{ if .Thresholds }}
+<h2>Thresholds</h2>
+<table>
+<tr><th>Metric</th><th>Threshold</th><th>Result</th></tr>
+{{ range .Thresholds }}<tr><td>{{ .Metric }}</td><td>{{ .Source }}</td>{{ if .Failed }}<td class="fail">&#x2717; failed</td>{{ else }}<td class="pass">&#x2713; passed</td>{{ end }}</tr>
+{{ end }}</table>
+{{ end }}
+
+{{ if .Checks }}
+<h2>Checks</h2>
+<table>
+<tr><th>Group</th><th>Check</th><th>Passes</th><th>Fails</th><th>Pass rate</th></tr>
+{{ range .Checks }}<tr><td>{{ .Group }}</td><td>{{ .Name }}</td><td class="num">{{ .Passes }}</td><td class="num{{ if .Fails }} fail{{ end }}">{{ .Fails }}</td><td class="num">{{ .Rate }}</td></tr>
+{{ end }}</table>
+{{ end }}
+
+<h2>Metrics</h2>
+<table>
+<tr><th>Metric</th><th>Values</th></tr>
+{{ range .Metrics }}<tr{{ if .Sub }} class="sub"{{ end }}><td class="{{ .Status.Class }}">{{ .Name }}</td><td class="values">{{ .Values }}</td></tr>
+{{ end }}</table>
+
+{{ if .Distributions }}
+<h2>Latency distributions</h2>
+{{ range .Distributions }}
+<h3>{{ .Metric }}</h3>
+<table class="chart">
+{{ range .Buckets }}<tr><td class="label">{{ .Label }}</td><td class="num count">{{ .Count }}</td><td><div class="bar" style="width: {{ printf "%.1f" .Percent }}%"></div></td></tr>
+{{ end }}</table>
+{{ end }}
+{{ end }}
+
+{{ if .Groups }}
+<h2>Breakdown by group</h2>
+{{ $cols := .TrendColumns }}
+{{ range .Groups }}
+<h3>{{ .Group }}</h3>
+<table>
+<tr><th>Metric</th><th>count</th>{{ range $cols }}<th>{{ . }}</th>{{ end }}</tr>
+{{ range .Rows }}<tr><td>{{ .Metric }}</td><td class="num">{{ .Count }}</td>{{ range .Values }}<td class="num">{{ . }}</td>{{ end }}</tr>
+{{ end }}</table>
+{{ end }}
+{{ end }}
+</body>
+</html>
+`))
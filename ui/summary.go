@@ -40,6 +40,7 @@ const (
 
 	SuccMark = "✓"
 	FailMark = "✗"
+	WarnMark = "!"
 )
 
 var (
@@ -49,17 +50,17 @@ var (
 )
 
 var TrendColumns = []TrendColumn{
-	{"avg", func(s *stats.TrendSink) float64 { return s.Avg }},
-	{"min", func(s *stats.TrendSink) float64 { return s.Min }},
-	{"med", func(s *stats.TrendSink) float64 { return s.Med }},
-	{"max", func(s *stats.TrendSink) float64 { return s.Max }},
-	{"p(90)", func(s *stats.TrendSink) float64 { return s.P(0.90) }},
-	{"p(95)", func(s *stats.TrendSink) float64 { return s.P(0.95) }},
+	{"avg", func(s stats.PercentileSink) float64 { return s.Summary().Avg }},
+	{"min", func(s stats.PercentileSink) float64 { return s.Summary().Min }},
+	{"med", func(s stats.PercentileSink) float64 { return s.Summary().Med }},
+	{"max", func(s stats.PercentileSink) float64 { return s.Summary().Max }},
+	{"p(90)", func(s stats.PercentileSink) float64 { return s.P(0.90) }},
+	{"p(95)", func(s stats.PercentileSink) float64 { return s.P(0.95) }},
 }
 
 type TrendColumn struct {
 	Key string
-	Get func(s *stats.TrendSink) float64
+	Get func(s stats.PercentileSink) float64
 }
 
 // VerifyTrendColumnStat checks if stat is a valid trend column
@@ -103,7 +104,7 @@ func UpdateTrendColumns(stats []string) {
 	}
 }
 
-func generatePercentileTrendColumn(stat string) (func(s *stats.TrendSink) float64, error) {
+func generatePercentileTrendColumn(stat string) (func(s stats.PercentileSink) float64, error) {
 	if stat == "" {
 		return nil, ErrStatEmptyString
 	}
@@ -120,7 +121,7 @@ func generatePercentileTrendColumn(stat string) (func(s *stats.TrendSink) float6
 
 	percentile = percentile / 100
 
-	return func(s *stats.TrendSink) float64 { return s.P(percentile) }, nil
+	return func(s stats.PercentileSink) float64 { return s.P(percentile) }, nil
 }
 
 // Returns the actual width of the string.
@@ -171,6 +172,10 @@ func SummarizeCheck(w io.Writer, indent string, check *lib.Check) {
 	if check.Fails > 0 {
 		mark = FailMark
 		color = FailColor
+		if check.GetSeverity() == lib.CheckSeverityWarn {
+			mark = WarnMark
+			color = WarnColor
+		}
 	}
 	_, _ = color.Fprintf(w, "%s%s %s\n", indent, mark, check.Name)
 	if check.Fails > 0 {
@@ -179,7 +184,25 @@ func SummarizeCheck(w io.Writer, indent string, check *lib.Check) {
 			int(100*(float64(check.Passes)/float64(check.Fails+check.Passes))),
 			SuccMark, check.Passes, FailMark, check.Fails,
 		)
+		if failures := check.GetFailureSamples(); len(failures) > 0 {
+			if details := FormatCheckFailure(failures[0]); details != "" {
+				_, _ = color.Fprintf(w, "%s %s  first failure: %s\n", indent, DetailsPrefix, details)
+			}
+		}
+	}
+}
+
+// FormatCheckFailure describes the details a failed check returned, or returns "" if it didn't
+// return any.
+func FormatCheckFailure(f lib.CheckFailure) string {
+	var parts []string
+	if f.Message != "" {
+		parts = append(parts, f.Message)
+	}
+	if f.Expected != nil || f.Actual != nil {
+		parts = append(parts, fmt.Sprintf("expected %v, got %v", f.Expected, f.Actual))
 	}
+	return strings.Join(parts, ": ")
 }
 
 func SummarizeGroup(w io.Writer, indent string, group *lib.Group) {
@@ -233,6 +256,12 @@ func NonTrendMetricValueForSum(t time.Duration, timeUnit string, m *stats.Metric
 			"✓ " + strconv.FormatInt(passes, 10),
 			"✗ " + strconv.FormatInt(fails, 10),
 		}
+	case *stats.ExpressionSink:
+		values := make([]string, len(sink.Refs))
+		for i, ref := range sink.Refs {
+			values[i] = ref.String() + "=" + sink.Metrics[i].HumanizeValue(sink.Value(i, t), timeUnit)
+		}
+		return strings.Join(values, " "), nil
 	default:
 		return "[no data]", nil
 	}
@@ -274,7 +303,7 @@ func SummarizeMetrics(w io.Writer, indent string, t time.Duration, timeUnit stri
 		}
 
 		m.Sink.Calc()
-		if sink, ok := m.Sink.(*stats.TrendSink); ok {
+		if sink, ok := m.Sink.(stats.PercentileSink); ok {
 			cols := make([]string, len(TrendColumns))
 			for i, col := range TrendColumns {
 				value := m.HumanizeValue(col.Get(sink), timeUnit)
@@ -351,7 +380,29 @@ func SummarizeMetrics(w io.Writer, indent string, t time.Duration, timeUnit stri
 }
 
 // Summarizes a dataset and returns whether the test run was considered a success.
+// SummarizeMetadata writes the metadata of the test run, eg. its git commit, if there's any.
+func SummarizeMetadata(w io.Writer, indent string, metadata map[string]string) {
+	if len(metadata) == 0 {
+		return
+	}
+	names := make([]string, 0, len(metadata))
+	nameLenMax := 0
+	for name := range metadata {
+		names = append(names, name)
+		if l := StrWidth(name); l > nameLenMax {
+			nameLenMax = l
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmtName := name + GrayColor.Sprint(strings.Repeat(".", nameLenMax-StrWidth(name)+3)+":")
+		_, _ = fmt.Fprint(w, indent+fmtName+" "+ValueColor.Sprint(metadata[name])+"\n")
+	}
+	_, _ = fmt.Fprint(w, "\n")
+}
+
 func Summarize(w io.Writer, indent string, data SummaryData) {
+	SummarizeMetadata(w, indent+"  ", data.Opts.Metadata)
 	if data.Root != nil {
 		SummarizeGroup(w, indent+"    ", data.Root)
 	}
@@ -21,6 +21,7 @@
 package ui
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/loadimpact/k6/stats"
@@ -158,3 +159,12 @@ func TestGeneratePercentileTrendColumn(t *testing.T) {
 		assert.Exactly(t, err, ErrPercentileStatInvalidValue)
 	})
 }
+
+func TestSummarizeMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	SummarizeMetadata(&buf, "  ", nil)
+	assert.Empty(t, buf.String())
+
+	SummarizeMetadata(&buf, "  ", map[string]string{"git_commit": "4f1c2e9", "release": "1.2.0"})
+	assert.Equal(t, "  git_commit...: 4f1c2e9\n  release......: 1.2.0\n\n", buf.String())
+}